@@ -21,6 +21,7 @@ import java.util.List;
 
 import android.content.Context;
 import android.hardware.input.InputManager;
+import android.os.Build;
 import android.os.Handler;
 import android.os.Looper;
 import android.util.AttributeSet;
@@ -117,6 +118,9 @@ public class EbitenView extends ViewGroup implements InputManager.InputDeviceLis
         LayoutParams params = new LayoutParams(LayoutParams.MATCH_PARENT, LayoutParams.MATCH_PARENT);
         addView(this.ebitenSurfaceView, params);
 
+        // The SDK version is required for the default gamepad mappings.
+        Ebitenmobileview.setAndroidSDKVersion(Build.VERSION.SDK_INT);
+
         this.inputManager = (InputManager)context.getSystemService(Context.INPUT_SERVICE);
         this.inputManager.registerInputDeviceListener(this, null);
         for (int id : this.inputManager.getInputDeviceIds()) {
@@ -400,7 +404,14 @@ public class EbitenView extends ViewGroup implements InputManager.InputDeviceLis
 
     @Override
     public void onInputDeviceChanged(int deviceId) {
-        // Do nothing.
+        // The axes and the buttons might be changed, e.g., when the key layout of a Bluetooth gamepad is loaded after pairing.
+        // Enumerate the device again. The gamepad keeps its ID as long as its GUID is the same.
+        this.gamepads.remove(this.getGamepad(deviceId));
+        this.onInputDeviceAdded(deviceId);
+        if (this.getGamepad(deviceId) == null) {
+            // The device is no longer a gamepad.
+            Ebitenmobileview.onInputDeviceRemoved(deviceId);
+        }
     }
 
     @Override
@@ -415,6 +426,8 @@ public class EbitenView extends ViewGroup implements InputManager.InputDeviceLis
     // Activity's onPause is called.
     public void suspendGame() {
         this.inputManager.unregisterInputDeviceListener(this);
+        // The gamepads might be disconnected while the game is suspended. Their inputs are released until they are enumerated again.
+        Ebitenmobileview.onGamepadsSuspended();
         this.ebitenSurfaceView.onPause();
         try {
             Ebitenmobileview.suspend();
@@ -428,6 +441,13 @@ public class EbitenView extends ViewGroup implements InputManager.InputDeviceLis
     // Activity's onResume is called.
     public void resumeGame() {
         this.inputManager.registerInputDeviceListener(this, null);
+        // Enumerate the devices again, as the listener is not notified while the game is suspended.
+        // The gamepads still present keep their IDs, and the others are removed.
+        this.gamepads.clear();
+        for (int id : this.inputManager.getInputDeviceIds()) {
+            this.onInputDeviceAdded(id);
+        }
+        Ebitenmobileview.onGamepadsResumed();
         this.ebitenSurfaceView.onResume();
         try {
             Ebitenmobileview.resume();
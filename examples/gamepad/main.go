@@ -82,7 +82,7 @@ func (g *Game) Update() error {
 		}
 
 		if ebiten.IsStandardGamepadLayoutAvailable(id) {
-			for b := ebiten.StandardGamepadButton(0); b <= ebiten.StandardGamepadButtonMax; b++ {
+			for b := ebiten.StandardGamepadButton(0); b <= ebiten.StandardGamepadButtonExtendedMax; b++ {
 				// Log button events.
 				if inpututil.IsStandardGamepadButtonJustPressed(id, b) {
 					var strong float64
@@ -20,6 +20,9 @@ import (
 )
 
 // GamepadButton represents a gamepad button.
+//
+// A gamepad can have more buttons than GamepadButtonMax, e.g., arcade encoders and flight panels.
+// Such buttons can be specified by GamepadButton values greater than GamepadButtonMax, up to GamepadButtonCount(id)-1.
 type GamepadButton = gamepad.Button
 
 // GamepadButtons
@@ -67,6 +70,7 @@ type GamepadAxisType = int
 //
 // The layout and the button values are based on the web standard.
 // See https://www.w3.org/TR/gamepad/#remapping.
+// The extra buttons of SDL's game controller layout, like the paddles, follow the web standard buttons.
 type StandardGamepadButton = gamepaddb.StandardButton
 
 // StandardGamepadButtons
@@ -88,7 +92,23 @@ const (
 	StandardGamepadButtonLeftLeft         StandardGamepadButton = gamepaddb.StandardButtonLeftLeft
 	StandardGamepadButtonLeftRight        StandardGamepadButton = gamepaddb.StandardButtonLeftRight
 	StandardGamepadButtonCenterCenter     StandardGamepadButton = gamepaddb.StandardButtonCenterCenter
-	StandardGamepadButtonMax              StandardGamepadButton = StandardGamepadButtonCenterCenter
+
+	// The buttons below are not in the web standard, but in SDL's game controller layout.
+	// They are available only on gamepads that have them, like Xbox Elite Wireless Controller and DualSense.
+	// See also IsStandardGamepadButtonAvailable.
+	StandardGamepadButtonMisc1    StandardGamepadButton = gamepaddb.StandardButtonMisc1 // e.g. Xbox Series X share button, Switch Pro capture button
+	StandardGamepadButtonPaddle1  StandardGamepadButton = gamepaddb.StandardButtonPaddle1
+	StandardGamepadButtonPaddle2  StandardGamepadButton = gamepaddb.StandardButtonPaddle2
+	StandardGamepadButtonPaddle3  StandardGamepadButton = gamepaddb.StandardButtonPaddle3
+	StandardGamepadButtonPaddle4  StandardGamepadButton = gamepaddb.StandardButtonPaddle4
+	StandardGamepadButtonTouchpad StandardGamepadButton = gamepaddb.StandardButtonTouchpad
+
+	// StandardGamepadButtonMax is the last button of the web standard.
+	// This doesn't cover the extra buttons above for backward compatibility. Use StandardGamepadButtonExtendedMax to iterate them too.
+	StandardGamepadButtonMax StandardGamepadButton = StandardGamepadButtonCenterCenter
+
+	// StandardGamepadButtonExtendedMax is the last button including the extra buttons of SDL's game controller layout.
+	StandardGamepadButtonExtendedMax StandardGamepadButton = StandardGamepadButtonTouchpad
 )
 
 // StandardGamepadAxis represents a gamepad axis in the standard layout.
@@ -105,3 +125,118 @@ const (
 	StandardGamepadAxisRightStickVertical   StandardGamepadAxis = gamepaddb.StandardAxisRightStickVertical
 	StandardGamepadAxisMax                  StandardGamepadAxis = StandardGamepadAxisRightStickVertical
 )
+
+// StandardGamepadLayoutOverride represents a standard layout mapping for a specific gamepad.
+// Buttons and Axes map the standard buttons and axes to the physical inputs of the gamepad.
+type StandardGamepadLayoutOverride = gamepaddb.Mapping
+
+// GamepadInput represents a physical input of a gamepad, i.e., a button, an axis, or a direction of a hat.
+//
+// For a hat, Index is the index of the hat, and HatState is one of GamepadHatStates.
+// For an axis, Inverted reports whether the axis is inverted.
+type GamepadInput = gamepaddb.Input
+
+// GamepadInputType represents the type of a physical input of a gamepad.
+type GamepadInputType = gamepaddb.InputType
+
+// GamepadInputTypes
+const (
+	GamepadInputTypeButton GamepadInputType = gamepaddb.InputTypeButton
+	GamepadInputTypeAxis   GamepadInputType = gamepaddb.InputTypeAxis
+	GamepadInputTypeHat    GamepadInputType = gamepaddb.InputTypeHat
+)
+
+// GamepadHatStates
+const (
+	GamepadHatStateUp    = gamepaddb.HatUp
+	GamepadHatStateRight = gamepaddb.HatRight
+	GamepadHatStateDown  = gamepaddb.HatDown
+	GamepadHatStateLeft  = gamepaddb.HatLeft
+)
+
+// GamepadPowerStateType represents the power state of a gamepad.
+type GamepadPowerStateType = gamepad.PowerState
+
+// GamepadPowerStates
+const (
+	// GamepadPowerStateUnknown indicates that the power state is unknown.
+	GamepadPowerStateUnknown GamepadPowerStateType = gamepad.PowerStateUnknown
+
+	// GamepadPowerStateOnBattery indicates that the gamepad is running on its battery.
+	GamepadPowerStateOnBattery GamepadPowerStateType = gamepad.PowerStateOnBattery
+
+	// GamepadPowerStateNoBattery indicates that the gamepad doesn't have a battery.
+	GamepadPowerStateNoBattery GamepadPowerStateType = gamepad.PowerStateNoBattery
+
+	// GamepadPowerStateCharging indicates that the battery of the gamepad is being charged.
+	GamepadPowerStateCharging GamepadPowerStateType = gamepad.PowerStateCharging
+
+	// GamepadPowerStateCharged indicates that the gamepad is plugged in and its battery is fully charged.
+	GamepadPowerStateCharged GamepadPowerStateType = gamepad.PowerStateCharged
+)
+
+// GamepadBusType represents how a gamepad is connected.
+type GamepadBusType = gamepad.BusType
+
+// GamepadBusTypes
+const (
+	// GamepadBusTypeUnknown indicates that the connection of the gamepad is unknown.
+	GamepadBusTypeUnknown GamepadBusType = gamepad.BusTypeUnknown
+
+	// GamepadBusTypeUSB indicates that the gamepad is connected via USB.
+	GamepadBusTypeUSB GamepadBusType = gamepad.BusTypeUSB
+
+	// GamepadBusTypeBluetooth indicates that the gamepad is connected via Bluetooth.
+	GamepadBusTypeBluetooth GamepadBusType = gamepad.BusTypeBluetooth
+
+	// GamepadBusTypeVirtual indicates that the gamepad is a virtual device, e.g., created by Steam Input or a remote play software.
+	GamepadBusTypeVirtual GamepadBusType = gamepad.BusTypeVirtual
+)
+
+// GamepadMotionSensorAxis represents an axis of the motion sensors of a gamepad.
+type GamepadMotionSensorAxis = gamepad.MotionSensorAxis
+
+// GamepadMotionSensorAxes
+const (
+	// GamepadMotionSensorAxisAccelerometerX is the acceleration along the X axis in m/s^2.
+	GamepadMotionSensorAxisAccelerometerX GamepadMotionSensorAxis = gamepad.MotionSensorAxisAccelerometerX
+
+	// GamepadMotionSensorAxisAccelerometerY is the acceleration along the Y axis in m/s^2.
+	GamepadMotionSensorAxisAccelerometerY GamepadMotionSensorAxis = gamepad.MotionSensorAxisAccelerometerY
+
+	// GamepadMotionSensorAxisAccelerometerZ is the acceleration along the Z axis in m/s^2.
+	GamepadMotionSensorAxisAccelerometerZ GamepadMotionSensorAxis = gamepad.MotionSensorAxisAccelerometerZ
+
+	// GamepadMotionSensorAxisGyroscopeX is the angular velocity around the X axis in rad/s.
+	GamepadMotionSensorAxisGyroscopeX GamepadMotionSensorAxis = gamepad.MotionSensorAxisGyroscopeX
+
+	// GamepadMotionSensorAxisGyroscopeY is the angular velocity around the Y axis in rad/s.
+	GamepadMotionSensorAxisGyroscopeY GamepadMotionSensorAxis = gamepad.MotionSensorAxisGyroscopeY
+
+	// GamepadMotionSensorAxisGyroscopeZ is the angular velocity around the Z axis in rad/s.
+	GamepadMotionSensorAxisGyroscopeZ GamepadMotionSensorAxis = gamepad.MotionSensorAxisGyroscopeZ
+)
+
+// VirtualGamepadElement represents an element of an on-screen virtual gamepad.
+// The values are bit flags and can be combined with the | operator.
+type VirtualGamepadElement = gamepad.VirtualGamepadElement
+
+// VirtualGamepadElements
+const (
+	VirtualGamepadElementLeftThumbstick  VirtualGamepadElement = gamepad.VirtualGamepadElementLeftThumbstick
+	VirtualGamepadElementRightThumbstick VirtualGamepadElement = gamepad.VirtualGamepadElementRightThumbstick
+	VirtualGamepadElementDirectionPad    VirtualGamepadElement = gamepad.VirtualGamepadElementDirectionPad
+	VirtualGamepadElementButtonA         VirtualGamepadElement = gamepad.VirtualGamepadElementButtonA
+	VirtualGamepadElementButtonB         VirtualGamepadElement = gamepad.VirtualGamepadElementButtonB
+	VirtualGamepadElementButtonX         VirtualGamepadElement = gamepad.VirtualGamepadElementButtonX
+	VirtualGamepadElementButtonY         VirtualGamepadElement = gamepad.VirtualGamepadElementButtonY
+	VirtualGamepadElementLeftShoulder    VirtualGamepadElement = gamepad.VirtualGamepadElementLeftShoulder
+	VirtualGamepadElementRightShoulder   VirtualGamepadElement = gamepad.VirtualGamepadElementRightShoulder
+	VirtualGamepadElementLeftTrigger     VirtualGamepadElement = gamepad.VirtualGamepadElementLeftTrigger
+	VirtualGamepadElementRightTrigger    VirtualGamepadElement = gamepad.VirtualGamepadElementRightTrigger
+)
+
+// VirtualGamepadZone represents the zone of an element of a touch-driven virtual gamepad.
+// The zone is a rectangle in the normalized coordinates of the screen,
+// where (0, 0) is the upper-left corner and (1, 1) is the lower-right corner.
+type VirtualGamepadZone = gamepad.VirtualGamepadZone
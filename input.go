@@ -16,7 +16,9 @@ package ebiten
 
 import (
 	"io/fs"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2/internal/gamepad"
 	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
@@ -122,6 +124,26 @@ func GamepadSDLID(id GamepadID) string {
 	return g.SDLID()
 }
 
+// GamepadSerial returns a string identifying the physical gamepad (id), such as a serial number or a Bluetooth address.
+// This is useful to distinguish gamepads of the same model, e.g., to remember settings for each gamepad.
+//
+// GamepadSerial returns an empty string when the gamepad doesn't provide such information.
+//
+// On Windows, GamepadSerial returns an opaque identifier of the physical gamepad that is stable on the same PC,
+// e.g., across sessions and reconnections, even for multiple identical gamepads.
+// The identifier is available for Xbox-class controllers on Windows 10 version 1803 or later and PlayStation controllers.
+//
+// GamepadSerial works only on Linux and Windows so far.
+//
+// GamepadSerial is concurrent-safe.
+func GamepadSerial(id GamepadID) string {
+	g := gamepad.Get(id)
+	if g == nil {
+		return ""
+	}
+	return g.Serial()
+}
+
 // GamepadName returns a string with the name.
 // This function may vary in how it returns descriptions for the same device across platforms.
 // for example the following drivers/platforms see an Xbox One controller as the following:
@@ -139,6 +161,24 @@ func GamepadName(id GamepadID) string {
 	return g.Name()
 }
 
+// GamepadKernelName returns the name of the gamepad (id) reported by the OS kernel.
+//
+// GamepadName might return a different name, as the name can be resolved from the gamepad database or the USB device.
+// GamepadKernelName is useful e.g. to distinguish gamepads of the same model from different vendors.
+//
+// ok is false when the gamepad doesn't exist or the information is not available.
+// GamepadKernelName works only on Linux so far, and ok is always false on the other platforms.
+//
+// GamepadKernelName is concurrent-safe.
+func GamepadKernelName(id GamepadID) (name string, ok bool) {
+	g := gamepad.Get(id)
+	if g == nil {
+		return "", false
+	}
+	name = g.KernelName()
+	return name, name != ""
+}
+
 // AppendGamepadIDs appends available gamepad IDs to gamepadIDs, and returns the extended buffer.
 // Giving a slice that already has enough capacity works efficiently.
 //
@@ -174,8 +214,25 @@ func GamepadAxisNum(id GamepadID) int {
 
 // GamepadAxisValue returns a float value [-1.0 - 1.0] of the given gamepad (id)'s axis (axis).
 //
+// If SetGamepadTriggerAxisRangeZeroToOne is enabled, GamepadAxisValue returns a float value [0.0 - 1.0] for a trigger axis.
+// See also IsGamepadTriggerAxis.
+//
 // GamepadAxisValue is concurrent-safe.
 func GamepadAxisValue(id GamepadID, axis GamepadAxisType) float64 {
+	g := gamepad.Get(id)
+	if g == nil {
+		return 0
+	}
+	return g.AxisValue(int(axis))
+}
+
+// GamepadAxisRawValue returns a float value [-1.0 - 1.0] of the given gamepad (id)'s axis (axis).
+//
+// Unlike GamepadAxisValue, GamepadAxisRawValue always returns a value in [-1.0 - 1.0] even for a trigger axis,
+// regardless of SetGamepadTriggerAxisRangeZeroToOne.
+//
+// GamepadAxisRawValue is concurrent-safe.
+func GamepadAxisRawValue(id GamepadID, axis GamepadAxisType) float64 {
 	g := gamepad.Get(id)
 	if g == nil {
 		return 0
@@ -183,6 +240,20 @@ func GamepadAxisValue(id GamepadID, axis GamepadAxisType) float64 {
 	return g.Axis(int(axis))
 }
 
+// IsGamepadTriggerAxis reports whether the given gamepad (id)'s axis (axis) is an analog trigger.
+// The value of a trigger axis is the minimum when the trigger is released, unlike a stick axis centered at 0.
+//
+// Trigger axes are detected by the gamepad database, or by the driver on Linux.
+//
+// IsGamepadTriggerAxis is concurrent-safe.
+func IsGamepadTriggerAxis(id GamepadID, axis GamepadAxisType) bool {
+	g := gamepad.Get(id)
+	if g == nil {
+		return false
+	}
+	return g.IsTriggerAxis(int(axis))
+}
+
 // GamepadAxis returns a float value [-1.0 - 1.0] of the given gamepad (id)'s axis (axis).
 //
 // Deprecated: as of v2.2. Use GamepadAxisValue instead.
@@ -190,8 +261,564 @@ func GamepadAxis(id GamepadID, axis GamepadAxisType) float64 {
 	return GamepadAxisValue(id, axis)
 }
 
+// GamepadBatteryLevel returns the battery level of the gamepad (id) in percent [0 - 100].
+//
+// ok is false when the battery level is unknown, e.g., when the gamepad is wired or the platform doesn't provide it.
+// Note that the returned level is not updated every tick.
+//
+// On Windows, the level is coarse for XInput devices, like 10, 40, 70, or 100, as XInput reports only four levels.
+//
+// GamepadBatteryLevel works on Linux, on Windows except for DirectInput devices,
+// and on browsers for DualShock 4 and DualSense after RequestGamepadEnhancedAccess is granted.
+// On macOS, GamepadBatteryLevel works only for DualSense on macOS 11 or older.
+//
+// GamepadBatteryLevel is concurrent-safe.
+func GamepadBatteryLevel(id GamepadID) (level int, ok bool) {
+	g := gamepad.Get(id)
+	if g == nil {
+		return 0, false
+	}
+	return g.BatteryLevel()
+}
+
+// GamepadPowerState returns the power state of the gamepad (id).
+//
+// ok is false when the power state is unknown, e.g., when the platform doesn't provide it.
+//
+// GamepadPowerState works on Linux, on Windows except for DirectInput devices,
+// and on browsers for DualShock 4 and DualSense after RequestGamepadEnhancedAccess is granted.
+// On macOS, GamepadPowerState works only for DualSense on macOS 11 or older.
+//
+// GamepadPowerState is concurrent-safe.
+func GamepadPowerState(id GamepadID) (state GamepadPowerStateType, ok bool) {
+	g := gamepad.Get(id)
+	if g == nil {
+		return GamepadPowerStateUnknown, false
+	}
+	s := g.PowerState()
+	return s, s != GamepadPowerStateUnknown
+}
+
+// GamepadButtonNativeCode returns the platform-specific code of the gamepad (id)'s button and its human-readable label.
+// On Linux, the code is an evdev key code like BTN_SOUTH (0x130).
+// The second value is a label like "South", which is meant to be shown in a button configuration screen.
+//
+// GamepadButtonNativeCode returns false as ok if the code is not available.
+// This is useful to show which physical button a player pressed, for example, to build a mapping for gamepaddb when the gamepad doesn't have the standard layout.
+//
+// GamepadButtonNativeCode works only on Linux so far.
+//
+// GamepadButtonNativeCode is concurrent-safe.
+func GamepadButtonNativeCode(id GamepadID, button GamepadButton) (code int, label string, ok bool) {
+	g := gamepad.Get(id)
+	if g == nil {
+		return 0, "", false
+	}
+	return g.ButtonNativeCode(int(button))
+}
+
+// GamepadAxisNativeCode returns the platform-specific code of the gamepad (id)'s axis and its human-readable label.
+// On Linux, the code is an evdev absolute axis code like ABS_RX (0x03).
+//
+// GamepadAxisNativeCode returns false as ok if the code is not available.
+//
+// GamepadAxisNativeCode works only on Linux so far.
+//
+// GamepadAxisNativeCode is concurrent-safe.
+func GamepadAxisNativeCode(id GamepadID, axis GamepadAxisType) (code int, label string, ok bool) {
+	g := gamepad.Get(id)
+	if g == nil {
+		return 0, "", false
+	}
+	return g.AxisNativeCode(axis)
+}
+
+// GamepadBus returns how the gamepad (id) is connected, e.g., via USB or Bluetooth.
+//
+// This is useful e.g. to warn about the input latency of wireless connections.
+//
+// GamepadBus returns GamepadBusTypeUnknown when the gamepad doesn't exist or the information is not available.
+//
+// GamepadBus works on Linux, FreeBSD, and OpenBSD, on macOS for gamepads not handled by GameController,
+// and on Windows for DualShock 4, DualSense, and Switch Pro Controller.
+// Virtual gamepads like touch gamepads report GamepadBusTypeVirtual on any platform.
+//
+// GamepadBus is concurrent-safe.
+func GamepadBus(id GamepadID) GamepadBusType {
+	g := gamepad.Get(id)
+	if g == nil {
+		return GamepadBusTypeUnknown
+	}
+	return g.BusType()
+}
+
+// GamepadLastActiveTime returns the time when a button, an axis, or a hat of the gamepad (id) changed its state last time.
+//
+// This is useful e.g. to select the gamepad on which a button is pressed, or to detect that the gamepad is idle.
+// Axis values within the dead zones are not treated as changes.
+//
+// GamepadLastActiveTime returns the zero time when the state has never changed.
+// ok is false when the gamepad doesn't exist or the information is not available.
+// GamepadLastActiveTime works only on Linux so far, and ok is always false on the other platforms.
+//
+// GamepadLastActiveTime is concurrent-safe.
+func GamepadLastActiveTime(id GamepadID) (t time.Time, ok bool) {
+	g := gamepad.Get(id)
+	if g == nil {
+		return time.Time{}, false
+	}
+	return g.LastActiveTime()
+}
+
+// GamepadCalibration represents the calibration of the axes of a gamepad.
+// GamepadCalibration can be serialized to JSON to save and restore the calibration.
+type GamepadCalibration = gamepad.Calibration
+
+// GamepadAxisCalibration represents the observed range of an axis of a gamepad.
+type GamepadAxisCalibration = gamepad.AxisCalibration
+
+// StartGamepadCalibration starts recording the observed ranges of the axes of the gamepad (id).
+// While recording, the player should rotate the sticks and press the triggers fully.
+// The sticks and the triggers should be at rest when StartGamepadCalibration is called,
+// as the current values are regarded as the values at rest.
+//
+// StartGamepadCalibration returns false when the gamepad doesn't exist or the calibration is not available.
+// StartGamepadCalibration works only on Linux so far, and always returns false on the other platforms.
+//
+// StartGamepadCalibration is concurrent-safe.
+func StartGamepadCalibration(id GamepadID) bool {
+	g := gamepad.Get(id)
+	if g == nil {
+		return false
+	}
+	return g.StartCalibration()
+}
+
+// FinishGamepadCalibration finishes recording the observed ranges of the axes of the gamepad (id) and returns the result.
+// The result is not applied until SetGamepadCalibration is called.
+//
+// FinishGamepadCalibration returns nil when the gamepad doesn't exist, StartGamepadCalibration is not called,
+// or the calibration is not available.
+//
+// FinishGamepadCalibration works only on Linux so far, and always returns nil on the other platforms.
+//
+// FinishGamepadCalibration is concurrent-safe.
+func FinishGamepadCalibration(id GamepadID) *GamepadCalibration {
+	g := gamepad.Get(id)
+	if g == nil {
+		return nil
+	}
+	return g.FinishCalibration()
+}
+
+// SetGamepadCalibration sets the calibration of the axes of the gamepad (id). nil resets the calibration.
+//
+// The calibrated axes report -1, 0, and 1 at the observed minimum, rest, and maximum values respectively.
+// The calibration is applied again when the same gamepad is reconnected.
+// The same gamepad is identified by GamepadSDLID and the serial number.
+//
+// SetGamepadCalibration returns false and does nothing when the gamepad doesn't exist or the calibration is not available.
+// SetGamepadCalibration works only on Linux so far, and always returns false on the other platforms.
+//
+// SetGamepadCalibration is concurrent-safe.
+func SetGamepadCalibration(id GamepadID, calibration *GamepadCalibration) bool {
+	g := gamepad.Get(id)
+	if g == nil {
+		return false
+	}
+	return g.SetCalibration(calibration)
+}
+
+// SetStandardGamepadLayoutOverride sets the standard layout mapping only for the gamepad (id). nil resets the override.
+//
+// The override is preferred to the mappings of the gamepad database and the platform, and takes effect immediately.
+// Unlike UpdateStandardGamepadLayoutMappings, the override doesn't affect other gamepads even with the same GUID.
+// This is useful for an in-game remapping screen.
+//
+// If persistent is false, the override is discarded when the gamepad is disconnected.
+// If persistent is true, the override is applied again when the same gamepad is reconnected.
+// The same gamepad is identified by GamepadSDLID and the serial number.
+//
+// SetStandardGamepadLayoutOverride returns an error if the override has an invalid input.
+//
+// SetStandardGamepadLayoutOverride is concurrent-safe.
+func SetStandardGamepadLayoutOverride(id GamepadID, override *StandardGamepadLayoutOverride, persistent bool) error {
+	g := gamepad.Get(id)
+	if g == nil {
+		return nil
+	}
+	if override == nil {
+		g.SetStandardLayoutOverride(nil, false)
+		return nil
+	}
+	o, err := gamepaddb.NewOverride(override)
+	if err != nil {
+		return err
+	}
+	g.SetStandardLayoutOverride(o, persistent)
+	return nil
+}
+
+// IsGamepadMotionSensorAvailable reports whether the gamepad (id) has motion sensors, i.e., an accelerometer and a gyroscope.
+//
+// IsGamepadMotionSensorAvailable works only on Linux, and on Windows for DualShock 4, DualSense, and Switch Pro Controller so far.
+// On browsers, motion sensors are available for DualShock 4 and DualSense after RequestGamepadEnhancedAccess is granted.
+// On Linux, motion sensors are available for gamepads whose drivers expose them as separate devices, e.g., DualShock 4, DualSense, and Switch Pro Controller.
+//
+// IsGamepadMotionSensorAvailable is concurrent-safe.
+func IsGamepadMotionSensorAvailable(id GamepadID) bool {
+	g := gamepad.Get(id)
+	if g == nil {
+		return false
+	}
+	return g.IsMotionSensorAvailable()
+}
+
+// GamepadMotionSensorValue returns the value of the given motion sensor axis of the gamepad (id).
+// The orientation of the axes depends on the driver.
+//
+// GamepadMotionSensorValue returns 0 when the gamepad doesn't exist or doesn't have motion sensors.
+//
+// GamepadMotionSensorValue works only on Linux, and on Windows for DualShock 4, DualSense, and Switch Pro Controller so far.
+//
+// GamepadMotionSensorValue is concurrent-safe.
+func GamepadMotionSensorValue(id GamepadID, axis GamepadMotionSensorAxis) float64 {
+	g := gamepad.Get(id)
+	if g == nil {
+		return 0
+	}
+	return g.MotionSensorValue(axis)
+}
+
+// IsGamepadTouchpadAvailable reports whether the gamepad (id) has a touchpad.
+//
+// IsGamepadTouchpadAvailable works on Linux, on Windows for DualShock 4 and DualSense,
+// and on browsers for DualShock 4 and DualSense after RequestGamepadEnhancedAccess is granted.
+// On macOS, IsGamepadTouchpadAvailable works only for DualSense on macOS 11 or older.
+// On Linux, touchpads are available for gamepads whose drivers expose them as separate devices, e.g., DualShock 4 and DualSense.
+//
+// IsGamepadTouchpadAvailable is concurrent-safe.
+func IsGamepadTouchpadAvailable(id GamepadID) bool {
+	g := gamepad.Get(id)
+	if g == nil {
+		return false
+	}
+	return g.IsTouchpadAvailable()
+}
+
+// IsGamepadTouchpadPressed reports whether the touchpad of the gamepad (id) is clicked.
+//
+// IsGamepadTouchpadPressed works on the same platforms as IsGamepadTouchpadAvailable.
+//
+// IsGamepadTouchpadPressed is concurrent-safe.
+func IsGamepadTouchpadPressed(id GamepadID) bool {
+	g := gamepad.Get(id)
+	if g == nil {
+		return false
+	}
+	return g.IsTouchpadPressed()
+}
+
+// GamepadTouchpadTouchCount is the maximum number of touches on the touchpad of a gamepad.
+const GamepadTouchpadTouchCount = gamepad.TouchpadTouchCount
+
+// GamepadTouchpadTouchPosition returns the position of the touch at index on the touchpad of the gamepad (id).
+// index must be in [0, GamepadTouchpadTouchCount).
+//
+// x and y are in the range of [0, 1], where (0, 0) is the upper-left corner of the touchpad.
+// ok is false when the touch at index is not touching, or the gamepad doesn't exist or doesn't have a touchpad.
+//
+// GamepadTouchpadTouchPosition works on the same platforms as IsGamepadTouchpadAvailable.
+//
+// GamepadTouchpadTouchPosition is concurrent-safe.
+func GamepadTouchpadTouchPosition(id GamepadID, index int) (x, y float64, ok bool) {
+	g := gamepad.Get(id)
+	if g == nil {
+		return 0, 0, false
+	}
+	return g.TouchpadTouchPosition(index)
+}
+
+// GamepadPlayerIndex returns the player index shown by the player indicator LEDs of the gamepad (id).
+//
+// By default, player indices are assigned in the connection order.
+// GamepadPlayerIndex returns -1 when the gamepad doesn't exist or the indicator is turned off.
+//
+// GamepadPlayerIndex is concurrent-safe.
+func GamepadPlayerIndex(id GamepadID) int {
+	g := gamepad.Get(id)
+	if g == nil {
+		return -1
+	}
+	return g.PlayerIndex()
+}
+
+// SetGamepadPlayerIndex sets the player index shown by the player indicator LEDs of the gamepad (id).
+// This is useful to reassign players, e.g., after a "press a button to join" screen.
+//
+// A negative index turns the indicator off.
+// If the gamepad has fewer LEDs than the index requires, the index wraps around.
+// SetGamepadPlayerIndex does nothing if the gamepad doesn't have player indicator LEDs.
+//
+// SetGamepadPlayerIndex works on Linux, macOS, and iOS, on Windows for DualShock 4, DualSense, and Switch Pro Controller,
+// and on browsers for DualShock 4 and DualSense after RequestGamepadEnhancedAccess is granted.
+// On Linux, the write permission for the LEDs in /sys/class/leds is required.
+// On macOS and iOS, GameController supports only the indices in [0, 3], and the other indices turn the indicator off.
+//
+// SetGamepadPlayerIndex is concurrent-safe.
+func SetGamepadPlayerIndex(id GamepadID, index int) {
+	g := gamepad.Get(id)
+	if g == nil {
+		return
+	}
+	g.SetPlayerIndex(index)
+}
+
+// GamepadDeviceError represents an error of a device, which made the device unavailable as a gamepad.
+type GamepadDeviceError = gamepad.DeviceError
+
+// AppendGamepadDeviceErrors appends the recent errors of the gamepad devices to errs and returns the extended buffer.
+// Giving a slice that already has enough capacity works efficiently.
+//
+// An error of a gamepad device, e.g., an I/O error of a broken gamepad, doesn't stop the game.
+// The device is closed and the other gamepads keep working.
+// This is useful to tell players why their gamepads don't work.
+//
+// AppendGamepadDeviceErrors is concurrent-safe.
+func AppendGamepadDeviceErrors(errs []GamepadDeviceError) []GamepadDeviceError {
+	return gamepad.AppendDeviceErrors(errs)
+}
+
+// UnmappedGamepad represents a gamepad connected without a standard gamepad layout mapping,
+// i.e., the gamepad is in neither the gamepad database nor mapped by the platform.
+type UnmappedGamepad = gamepad.UnmappedGamepad
+
+// AppendUnmappedGamepads appends the recently connected unmapped gamepads to gamepads and returns the extended buffer.
+// Giving a slice that already has enough capacity works efficiently.
+//
+// A gamepad with the same GUID is reported only once.
+// This is useful to show the GUID and the mapping template in a debug menu or a bug report,
+// so that the player can make a mapping e.g. for UpdateStandardGamepadLayoutMappings and contribute it to SDL_GameControllerDB.
+//
+// AppendUnmappedGamepads is concurrent-safe.
+func AppendUnmappedGamepads(gamepads []UnmappedGamepad) []UnmappedGamepad {
+	return gamepad.AppendUnmappedGamepads(gamepads)
+}
+
+// GamepadMappingError represents an error of gamepad mappings that are not given by UpdateStandardGamepadLayoutMappings,
+// e.g., mappings from an environment variable or a file watched by WatchStandardGamepadLayoutMappingFile.
+type GamepadMappingError = gamepaddb.MappingError
+
+// AppendGamepadMappingErrors appends the errors of gamepad mappings to errs and returns the extended buffer.
+// Giving a slice that already has enough capacity works efficiently.
+//
+// Ebitengine reads the mappings in the environment variable SDL_GAMECONTROLLERCONFIG
+// and the file specified by the environment variable SDL_GAMECONTROLLERCONFIG_FILE in the same way as SDL.
+// These mappings are preferred to the other mappings including ones given by UpdateStandardGamepadLayoutMappings.
+// Invalid mappings in them don't stop the game, and are reported by AppendGamepadMappingErrors instead.
+//
+// AppendGamepadMappingErrors is concurrent-safe.
+func AppendGamepadMappingErrors(errs []GamepadMappingError) []GamepadMappingError {
+	return gamepaddb.AppendMappingErrors(errs)
+}
+
+// GamepadMappingValidationError represents an error of a field in gamepad mappings.
+type GamepadMappingValidationError = gamepaddb.ValidationError
+
+// GamepadMappingValidationErrors represents errors in gamepad mappings.
+//
+// ValidateStandardGamepadLayoutMappings returns an error of this type when the mappings are invalid.
+type GamepadMappingValidationErrors = gamepaddb.ValidationErrors
+
+// GamepadMappingValidationReason represents the reason why a field in gamepad mappings is invalid.
+type GamepadMappingValidationReason = gamepaddb.ValidationReason
+
+// GamepadMappingValidationReasons
+const (
+	GamepadMappingValidationReasonSyntax       GamepadMappingValidationReason = gamepaddb.ValidationReasonSyntax
+	GamepadMappingValidationReasonUnknownKey   GamepadMappingValidationReason = gamepaddb.ValidationReasonUnknownKey
+	GamepadMappingValidationReasonInvalidValue GamepadMappingValidationReason = gamepaddb.ValidationReasonInvalidValue
+	GamepadMappingValidationReasonDuplicate    GamepadMappingValidationReason = gamepaddb.ValidationReasonDuplicate
+	GamepadMappingValidationReasonOutOfRange   GamepadMappingValidationReason = gamepaddb.ValidationReasonOutOfRange
+)
+
+// ValidateStandardGamepadLayoutMappings validates the given mappings in SDL_GameControllerDB format without applying them.
+//
+// ValidateStandardGamepadLayoutMappings returns GamepadMappingValidationErrors when the mappings are invalid,
+// e.g. when a line has an unknown key like "lefttriger", a malformed value, a key assigned more than once,
+// or a reference to an input with a too large index.
+// Each error has the line number and the field so that hand-written mappings can be fixed easily.
+//
+// ValidateStandardGamepadLayoutMappings is concurrent-safe.
+func ValidateStandardGamepadLayoutMappings(mappings string) error {
+	return gamepaddb.Validate(strings.NewReader(mappings))
+}
+
+// SetStandardGamepadLayoutMappingsStrict sets whether gamepad mappings are validated strictly when they are loaded.
+//
+// In the strict mode, UpdateStandardGamepadLayoutMappings and WatchStandardGamepadLayoutMappingFile reject the whole input
+// if ValidateStandardGamepadLayoutMappings reports any error for it.
+// Without the strict mode, invalid fields like unknown keys are just ignored.
+// The mappings in the environment variables are read at the initialization and are not affected by the strict mode.
+//
+// The default value is false.
+//
+// SetStandardGamepadLayoutMappingsStrict is concurrent-safe.
+func SetStandardGamepadLayoutMappingsStrict(strict bool) {
+	gamepaddb.SetStrict(strict)
+}
+
+// GamepadConnectionEvent represents a connection or a disconnection of a gamepad.
+type GamepadConnectionEvent = gamepad.ConnectionEvent
+
+// AppendGamepadConnectionEvents appends the gamepad connection and disconnection events in the current tick to events
+// and returns the extended buffer.
+// Giving a slice that already has enough capacity works efficiently.
+//
+// This is useful to react to a disconnection in the same tick, e.g., to pause the game and show a message to reconnect the gamepad.
+//
+// AppendGamepadConnectionEvents must be called in a game's Update, not Draw.
+//
+// AppendGamepadConnectionEvents is concurrent-safe.
+func AppendGamepadConnectionEvents(events []GamepadConnectionEvent) []GamepadConnectionEvent {
+	return gamepad.AppendConnectionEvents(events)
+}
+
+// SetGamepadReconnectionGracePeriod sets the duration to keep the ID of a disconnected gamepad.
+//
+// When a gamepad is disconnected and then reconnected within the period, e.g., due to an unstable wireless connection,
+// the gamepad gets the same ID as before. The ID is not used for other gamepads during the period.
+// If the period is 0 or negative, a reconnected gamepad always gets a new ID.
+//
+// The default value is 5 seconds.
+//
+// SetGamepadReconnectionGracePeriod works on Linux, FreeBSD, and OpenBSD,
+// on Windows for gamepads handled by Windows.Gaming.Input, DualShock 4, DualSense, and Switch Pro Controller,
+// and on macOS only for DualSense on macOS 11 or older.
+// SetGamepadReconnectionGracePeriod works only for gamepads that can be identified, e.g., by their serials (see GamepadSerial).
+//
+// SetGamepadReconnectionGracePeriod is concurrent-safe.
+func SetGamepadReconnectionGracePeriod(period time.Duration) {
+	gamepad.SetReconnectionGracePeriod(period)
+}
+
+// SetGamepadAxisDeadZoneEnabled sets whether the dead zones and the noise filtering reported by gamepad drivers
+// are applied to gamepad axis values.
+//
+// When enabled, axis values around the center are reported as 0, and the rest are rescaled so that
+// the values can still reach -1 and 1. Disable this if you want raw axis values, e.g., to apply your own filtering.
+//
+// The default value is true.
+//
+// SetGamepadAxisDeadZoneEnabled works only on Linux and Windows (XInput) so far.
+//
+// SetGamepadAxisDeadZoneEnabled is concurrent-safe.
+func SetGamepadAxisDeadZoneEnabled(enabled bool) {
+	gamepad.SetAxisDeadZoneEnabled(enabled)
+}
+
+// SetGamepadTriggerAxisRangeZeroToOne sets whether GamepadAxisValue reports trigger axis values in [0, 1] instead of [-1, 1].
+//
+// When enabled, a released trigger is reported as 0 instead of -1.
+// The standard gamepad layout is not affected, as StandardGamepadButtonValue already reports triggers in [0, 1].
+//
+// The default value is false.
+//
+// SetGamepadTriggerAxisRangeZeroToOne is concurrent-safe.
+func SetGamepadTriggerAxisRangeZeroToOne(enabled bool) {
+	gamepad.SetTriggerAxisRangeZeroToOne(enabled)
+}
+
+// SetStandardGamepadLayoutHeuristicEnabled sets whether the standard gamepad layout is guessed for a gamepad
+// without a standard gamepad layout mapping in the gamepad database or by the platform.
+//
+// When enabled, the layout is guessed in the Xbox controller's convention, which most unknown gamepads follow:
+// the buttons 0-3 are A, B, X, and Y, the axes 0 and 1 are the left stick, the axes 3 and 4 (or 2 and 3) are the right stick,
+// and the first hat is the D-pad. The triggers are guessed as the axes 2 and 5, or as the buttons 6 and 7.
+// Use IsStandardGamepadLayoutHeuristic to know whether the layout is guessed.
+//
+// The default value is false.
+//
+// SetStandardGamepadLayoutHeuristicEnabled is concurrent-safe.
+func SetStandardGamepadLayoutHeuristicEnabled(enabled bool) {
+	gamepad.SetHeuristicMappingEnabled(enabled)
+}
+
+// SetGamepadMicroProfileEnabled sets whether devices with only the micro gamepad profile, like Siri Remote, are treated as gamepads.
+//
+// Such devices have only a few buttons and a touch surface, and confuse e.g. "press any button" screens.
+// Enable this if your application is for tvOS and has to be operable with Siri Remote.
+// The setting affects only the devices connected after SetGamepadMicroProfileEnabled is called.
+//
+// The default value is false.
+//
+// SetGamepadMicroProfileEnabled works only on iOS and tvOS so far.
+// On macOS, such devices are always ignored.
+//
+// SetGamepadMicroProfileEnabled is concurrent-safe.
+func SetGamepadMicroProfileEnabled(enabled bool) {
+	gamepad.SetMicroGamepadEnabled(enabled)
+}
+
+// RequestGamepadEnhancedAccess requests the access to the gamepads' features the platform's regular gamepad API doesn't provide.
+//
+// On browsers, RequestGamepadEnhancedAccess shows the browser's chooser of WebHID devices for DualShock 4 and DualSense.
+// When the user grants the access, the gamepad gets the motion sensors, the touchpad, the battery level, and more reliable vibration,
+// while the buttons and the axes are still read via the Gamepad API.
+// The access granted once is kept by the browser, and is used without the chooser in the later sessions.
+// RequestGamepadEnhancedAccess must be called in response to a user input like a click or a key press,
+// and does nothing if the browser doesn't support WebHID. If the user declines the access, the gamepad works as before.
+//
+// RequestGamepadEnhancedAccess works only on browsers supporting WebHID, like Chrome and Edge, so far.
+//
+// RequestGamepadEnhancedAccess is concurrent-safe.
+func RequestGamepadEnhancedAccess() {
+	gamepad.RequestEnhancedAccess()
+}
+
+// SetVirtualGamepadElements sets the elements of an on-screen virtual gamepad, like VirtualGamepadElementLeftThumbstick | VirtualGamepadElementButtonA.
+//
+// The virtual gamepad is shown only while no physical gamepad is connected, and is dismissed automatically when a physical gamepad connects.
+// The virtual gamepad is also hidden while the application is in background.
+// The virtual gamepad appears as a normal gamepad with the standard layout, and its GamepadBusType is GamepadBusTypeVirtual.
+//
+// If elements is 0, the virtual gamepad is not shown.
+//
+// The default value is 0.
+//
+// On browsers, the virtual gamepad is driven by touches in the zones of the elements, and nothing is rendered.
+// Draw the controls by yourself if needed. See also SetVirtualGamepadElementZone.
+// Only VirtualGamepadElementLeftThumbstick and VirtualGamepadElementButtonA to VirtualGamepadElementButtonY are available on browsers.
+// The thumbstick is operated by a touch that starts in its zone, and the thumbstick's center is where the touch starts.
+// The touches are still reported by the touch functions like AppendTouchIDs.
+//
+// SetVirtualGamepadElements works only on iOS 15.0 or newer and browsers so far.
+//
+// SetVirtualGamepadElements is concurrent-safe.
+func SetVirtualGamepadElements(elements VirtualGamepadElement) {
+	gamepad.SetVirtualGamepadElements(elements)
+}
+
+// SetVirtualGamepadElementZone sets the zone of the element of the touch-driven virtual gamepad.
+// element must be one element, not a combination of elements.
+//
+// By default, the thumbstick is in the lower-left quarter of the screen,
+// and the buttons are in the lower-right quarter in a 2x2 grid: X and Y in the upper row, and A and B in the lower row.
+//
+// SetVirtualGamepadElementZone works only on browsers so far.
+// On iOS, the virtual gamepad has the system's layout.
+//
+// SetVirtualGamepadElementZone is concurrent-safe.
+func SetVirtualGamepadElementZone(element VirtualGamepadElement, zone VirtualGamepadZone) {
+	gamepad.SetVirtualGamepadElementZone(element, zone)
+}
+
 // GamepadButtonCount returns the number of the buttons of the given gamepad (id).
 //
+// The number can be greater than GamepadButtonMax+1, up to 128.
+// A device with more buttons is not treated as a gamepad.
+// The number is also limited by the platforms: at most 32 buttons are available for DirectInput devices on Windows.
+//
 // GamepadButtonCount is concurrent-safe.
 func GamepadButtonCount(id GamepadID) int {
 	g := gamepad.Get(id)
@@ -241,7 +868,10 @@ func IsGamepadButtonPressed(id GamepadID, button GamepadButton) bool {
 
 // StandardGamepadAxisValue returns a float value [-1.0 - 1.0] of the given gamepad (id)'s standard axis (axis).
 //
-// StandardGamepadAxisValue returns 0 when the gamepad doesn't have a standard gamepad layout mapping.
+// StandardGamepadAxisValue returns 0 when the standard axis is not available on the gamepad.
+// See also IsStandardGamepadAxisAvailable.
+//
+// The value is rescaled with the hint for the gamepad if any. See also SetStandardGamepadAxisHint.
 //
 // StandardGamepadAxisValue is concurrent safe.
 func StandardGamepadAxisValue(id GamepadID, axis StandardGamepadAxis) float64 {
@@ -254,7 +884,12 @@ func StandardGamepadAxisValue(id GamepadID, axis StandardGamepadAxis) float64 {
 
 // StandardGamepadButtonValue returns a float value [0.0 - 1.0] of the given gamepad (id)'s standard button (button).
 //
-// StandardGamepadButtonValue returns 0 when the gamepad doesn't have a standard gamepad layout mapping.
+// StandardGamepadButtonValue returns 0 when the standard button is not available on the gamepad.
+// See also IsStandardGamepadButtonAvailable.
+//
+// The value is analog when the standard button is mapped from an axis, e.g., an analog trigger,
+// or from a pressure-sensitive button. Otherwise, the value is 0 or 1.
+// This is useful for analog controls like a throttle with StandardGamepadButtonFrontBottomRight.
 //
 // StandardGamepadButtonValue is concurrent safe.
 func StandardGamepadButtonValue(id GamepadID, button StandardGamepadButton) float64 {
@@ -267,7 +902,11 @@ func StandardGamepadButtonValue(id GamepadID, button StandardGamepadButton) floa
 
 // IsStandardGamepadButtonPressed reports whether the given gamepad (id)'s standard gamepad button (button) is pressed.
 //
-// IsStandardGamepadButtonPressed returns false when the gamepad doesn't have a standard gamepad layout mapping.
+// IsStandardGamepadButtonPressed returns false when the standard button is not available on the gamepad.
+// See also IsStandardGamepadButtonAvailable.
+//
+// A standard button mapped from an axis, e.g., an analog trigger or a D-pad reported as an axis, is released
+// a little below the value where the button is pressed, so that the button doesn't flicker around the threshold.
 //
 // IsStandardGamepadButtonPressed is concurrent safe.
 func IsStandardGamepadButtonPressed(id GamepadID, button StandardGamepadButton) bool {
@@ -280,6 +919,13 @@ func IsStandardGamepadButtonPressed(id GamepadID, button StandardGamepadButton)
 
 // IsStandardGamepadLayoutAvailable reports whether the gamepad (id) has a standard gamepad layout mapping.
 //
+// Even without a standard gamepad layout mapping, the D-pad buttons and the left stick might still work,
+// as they are guessed from the first hat and the first two axes of the gamepad as a heuristic.
+// The guessed buttons and axes are not reported as available by IsStandardGamepadButtonAvailable and IsStandardGamepadAxisAvailable.
+//
+// If SetStandardGamepadLayoutHeuristicEnabled is enabled, IsStandardGamepadLayoutAvailable returns true also for
+// a gamepad whose standard gamepad layout is guessed. See also IsStandardGamepadLayoutHeuristic.
+//
 // IsStandardGamepadLayoutAvailable is concurrent-safe.
 func IsStandardGamepadLayoutAvailable(id GamepadID) bool {
 	g := gamepad.Get(id)
@@ -291,6 +937,10 @@ func IsStandardGamepadLayoutAvailable(id GamepadID) bool {
 
 // IsStandardGamepadAxisAvailable reports whether the standard gamepad axis is available on the gamepad (id).
 //
+// The availability is based on the gamepad database or the mapping the platform provides.
+// IsStandardGamepadAxisAvailable returns false for a gamepad without a standard gamepad layout mapping,
+// even if the axis is guessed as a heuristic, unless SetStandardGamepadLayoutHeuristicEnabled is enabled.
+//
 // IsStandardGamepadAxisAvailable is concurrent-safe.
 func IsStandardGamepadAxisAvailable(id GamepadID, axis StandardGamepadAxis) bool {
 	g := gamepad.Get(id)
@@ -301,6 +951,14 @@ func IsStandardGamepadAxisAvailable(id GamepadID, axis StandardGamepadAxis) bool
 }
 
 // IsStandardGamepadButtonAvailable reports whether the standard gamepad button is available on the gamepad (id).
+// This is useful to hide prompts for buttons the gamepad doesn't have, e.g., the stick buttons of a SNES-style gamepad.
+//
+// The availability is based on the gamepad database or the mapping the platform provides.
+// IsStandardGamepadButtonAvailable returns false for a gamepad without a standard gamepad layout mapping,
+// even if the button is guessed as a heuristic, unless SetStandardGamepadLayoutHeuristicEnabled is enabled.
+//
+// StandardGamepadButtonCenterCenter, i.e., the Guide, Home, or PS button, might not be available even if the gamepad has it,
+// as some platforms don't deliver the button to applications, e.g., XInput without XInputGetStateEx, or browsers reserving the button for the OS.
 //
 // IsStandardGamepadButtonAvailable is concurrent-safe.
 func IsStandardGamepadButtonAvailable(id GamepadID, button StandardGamepadButton) bool {
@@ -311,11 +969,45 @@ func IsStandardGamepadButtonAvailable(id GamepadID, button StandardGamepadButton
 	return g.IsStandardButtonAvailable(button)
 }
 
+// IsStandardGamepadLayoutHeuristic reports whether the standard gamepad layout of the gamepad (id) is just guessed
+// as SetStandardGamepadLayoutHeuristicEnabled is enabled.
+//
+// The guess might be wrong. This is useful to warn the player and to suggest remapping the buttons,
+// e.g., by SetStandardGamepadLayoutOverride.
+//
+// IsStandardGamepadLayoutHeuristic is concurrent-safe.
+func IsStandardGamepadLayoutHeuristic(id GamepadID) bool {
+	g := gamepad.Get(id)
+	if g == nil {
+		return false
+	}
+	return g.IsStandardLayoutHeuristic()
+}
+
+// StandardGamepadLayoutMapping returns the standard gamepad layout mapping of the gamepad (id) in use,
+// in the format of SDL_GameControllerDB.
+//
+// The mapping is either the one in the gamepad database or the one the platform provides.
+// Passing the result to UpdateStandardGamepadLayoutMappings doesn't change how the gamepad works,
+// so the result can be saved to restore the mapping later, or contributed to the database.
+//
+// StandardGamepadLayoutMapping returns an empty string if the gamepad doesn't have a standard gamepad layout mapping.
+//
+// StandardGamepadLayoutMapping is concurrent-safe.
+func StandardGamepadLayoutMapping(id GamepadID) string {
+	g := gamepad.Get(id)
+	if g == nil {
+		return ""
+	}
+	return g.StandardLayoutMapping()
+}
+
 // UpdateStandardGamepadLayoutMappings parses the specified string mappings in SDL_GameControllerDB format and
 // updates the gamepad layout definitions.
 //
 // UpdateStandardGamepadLayoutMappings reports whether the mappings were applied,
 // and returns an error in case any occurred while parsing the mappings.
+// The error reports the line numbers of all the invalid lines.
 //
 // One or more input definitions can be provided separated by newlines.
 // In particular, it is valid to pass an entire gamecontrollerdb.txt file.
@@ -340,15 +1032,87 @@ func IsStandardGamepadButtonAvailable(id GamepadID, button StandardGamepadButton
 // UpdateStandardGamepadLayoutMappings is concurrent-safe.
 //
 // UpdateStandardGamepadLayoutMappings mappings take effect immediately even for already connected gamepads.
+// A mapping overrides the existing mapping, including the embedded one, for the same GUID.
 //
 // UpdateStandardGamepadLayoutMappings works atomically. If an error happens, nothing is updated.
+// In the strict mode set by SetStandardGamepadLayoutMappingsStrict, any invalid field is an error.
 func UpdateStandardGamepadLayoutMappings(mappings string) (bool, error) {
-	if err := gamepaddb.Update([]byte(mappings)); err != nil {
+	if _, err := gamepaddb.Update(strings.NewReader(mappings)); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+// RemoveStandardGamepadLayoutMapping removes the mappings for the GUID added at runtime,
+// and reports whether any mapping is removed.
+//
+// The mappings given by UpdateStandardGamepadLayoutMappings, WatchStandardGamepadLayoutMappingFile,
+// and the environment variables are removed, and then the embedded mapping for the GUID, if any, is used again.
+// The embedded mappings are never removed.
+// The CRC of the name in the GUID is ignored, so the mappings for all the names of the same device are removed.
+//
+// The removal takes effect immediately even for already connected gamepads.
+// Note that a mapping from a watched file is added again when the file is modified.
+//
+// On platforms where gamepad mappings are not managed by Ebitengine, this always returns false.
+//
+// RemoveStandardGamepadLayoutMapping is concurrent-safe.
+func RemoveStandardGamepadLayoutMapping(guid string) bool {
+	return gamepaddb.RemoveMapping(guid)
+}
+
+// ResetStandardGamepadLayoutMappings removes all the mappings added at runtime and restores the embedded mappings.
+// The files watched by WatchStandardGamepadLayoutMappingFile are no longer watched.
+// The hints given by SetStandardGamepadAxisHint are also removed.
+//
+// This is useful for a "restore default controller mapping" button in a game.
+// The reset takes effect immediately even for already connected gamepads.
+//
+// ResetStandardGamepadLayoutMappings is concurrent-safe.
+func ResetStandardGamepadLayoutMappings() {
+	gamepaddb.ResetMappingsToDefault()
+}
+
+// StandardGamepadAxisHint is a hint about the range of a standard axis of a device.
+// A standard axis value is rescaled with the hint so that the value is 0 within the dead zone and reaches 1 at the saturation.
+//
+// DeadZone must be in [0, 1). Saturation must be in (DeadZone, 1], or 0 that means 1.
+// The zero value means no rescaling.
+type StandardGamepadAxisHint = gamepaddb.AxisHint
+
+// SetStandardGamepadAxisHint sets the hint for the standard axis of the gamepads with the GUID in SDL_GameControllerDB format.
+// The CRC of the name in the GUID is ignored.
+//
+// Some devices' sticks never reach -1 or 1, or have large slop at the center.
+// StandardGamepadAxisValue applies the hint for such a device automatically, and
+// Ebitengine has built-in hints for a few notorious devices like Nintendo Switch Pro Controller.
+// A hint given by SetStandardGamepadAxisHint is preferred to the built-in hint. Giving the zero value disables the rescaling.
+// GamepadAxisValue returns the raw value regardless of the hints.
+//
+// The hints are applied only to the gamepads mapped by the gamepad database.
+// On platforms where gamepad mappings are not managed by Ebitengine, the hints are ignored.
+//
+// SetStandardGamepadAxisHint is concurrent-safe.
+func SetStandardGamepadAxisHint(guid string, axis StandardGamepadAxis, hint StandardGamepadAxisHint) error {
+	return gamepaddb.SetAxisHint(guid, axis, hint)
+}
+
+// WatchStandardGamepadLayoutMappingFile loads the mappings in SDL_GameControllerDB format from the file at path,
+// and watches the file to reload the mappings whenever the file is modified.
+//
+// This is useful to iterate on a mapping for a gamepad without restarting the game.
+// The mappings from the file take effect immediately even for already connected gamepads.
+// They are preferred to the mappings given by UpdateStandardGamepadLayoutMappings.
+// On every reload, the mappings previously loaded from the file are replaced with the new ones atomically.
+//
+// WatchStandardGamepadLayoutMappingFile returns an error of the initial load, but the file is watched even in this case.
+// If a reload fails, the last valid mappings are kept, and the error is reported by AppendGamepadMappingErrors.
+//
+// WatchStandardGamepadLayoutMappingFile is concurrent-safe.
+func WatchStandardGamepadLayoutMappingFile(path string) error {
+	return gamepaddb.WatchFile(path)
+}
+
 // TouchID represents a touch's identifier.
 type TouchID = ui.TouchID
 
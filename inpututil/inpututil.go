@@ -16,6 +16,7 @@
 package inpututil
 
 import (
+	"math"
 	"sort"
 	"sync"
 
@@ -44,6 +45,11 @@ type inputState struct {
 	standardGamepadButtonDurations     map[ebiten.GamepadID][]int
 	prevStandardGamepadButtonDurations map[ebiten.GamepadID][]int
 
+	// gamepadAxisRestValues is the axis values when the gamepads are connected.
+	// gamepadAxisDurations is how long the axes are moved away from the rest values.
+	gamepadAxisRestValues map[ebiten.GamepadID][]float64
+	gamepadAxisDurations  map[ebiten.GamepadID][]int
+
 	touchIDs           map[ebiten.TouchID]struct{}
 	touchDurations     map[ebiten.TouchID]int
 	touchPositions     map[ebiten.TouchID]pos
@@ -72,6 +78,9 @@ var theInputState = &inputState{
 	standardGamepadButtonDurations:     map[ebiten.GamepadID][]int{},
 	prevStandardGamepadButtonDurations: map[ebiten.GamepadID][]int{},
 
+	gamepadAxisRestValues: map[ebiten.GamepadID][]float64{},
+	gamepadAxisDurations:  map[ebiten.GamepadID][]int{},
+
 	touchIDs:           map[ebiten.TouchID]struct{}{},
 	touchDurations:     map[ebiten.TouchID]int{},
 	touchPositions:     map[ebiten.TouchID]pos{},
@@ -142,11 +151,16 @@ func (i *inputState) update() {
 	for _, id := range i.gamepadIDsBuf {
 		i.gamepadIDs[id] = struct{}{}
 
-		if _, ok := i.gamepadButtonDurations[id]; !ok {
-			i.gamepadButtonDurations[id] = make([]int, ebiten.GamepadButtonMax+1)
+		// A gamepad can have more buttons than GamepadButtonMax. Track all the buttons.
+		n := ebiten.GamepadButtonCount(id)
+		if n < int(ebiten.GamepadButtonMax)+1 {
+			n = int(ebiten.GamepadButtonMax) + 1
+		}
+		if ds := i.gamepadButtonDurations[id]; len(ds) < n {
+			i.gamepadButtonDurations[id] = append(ds, make([]int, n-len(ds))...)
 		}
-		for b := ebiten.GamepadButton(0); b <= ebiten.GamepadButtonMax; b++ {
-			if ebiten.IsGamepadButtonPressed(id, b) {
+		for b := range i.gamepadButtonDurations[id] {
+			if ebiten.IsGamepadButtonPressed(id, ebiten.GamepadButton(b)) {
 				i.gamepadButtonDurations[id][b]++
 			} else {
 				i.gamepadButtonDurations[id][b] = 0
@@ -154,15 +168,31 @@ func (i *inputState) update() {
 		}
 
 		if _, ok := i.standardGamepadButtonDurations[id]; !ok {
-			i.standardGamepadButtonDurations[id] = make([]int, ebiten.StandardGamepadButtonMax+1)
+			i.standardGamepadButtonDurations[id] = make([]int, ebiten.StandardGamepadButtonExtendedMax+1)
 		}
-		for b := ebiten.StandardGamepadButton(0); b <= ebiten.StandardGamepadButtonMax; b++ {
+		for b := ebiten.StandardGamepadButton(0); b <= ebiten.StandardGamepadButtonExtendedMax; b++ {
 			if ebiten.IsStandardGamepadButtonPressed(id, b) {
 				i.standardGamepadButtonDurations[id][b]++
 			} else {
 				i.standardGamepadButtonDurations[id][b] = 0
 			}
 		}
+
+		if _, ok := i.gamepadAxisRestValues[id]; !ok {
+			vs := make([]float64, ebiten.GamepadAxisCount(id))
+			for a := range vs {
+				vs[a] = ebiten.GamepadAxisValue(id, a)
+			}
+			i.gamepadAxisRestValues[id] = vs
+			i.gamepadAxisDurations[id] = make([]int, len(vs))
+		}
+		for a, v := range i.gamepadAxisRestValues[id] {
+			if math.Abs(ebiten.GamepadAxisValue(id, a)-v) >= gamepadAxisMoveThreshold {
+				i.gamepadAxisDurations[id][a]++
+			} else {
+				i.gamepadAxisDurations[id][a] = 0
+			}
+		}
 	}
 	for id := range i.gamepadButtonDurations {
 		if _, ok := i.gamepadIDs[id]; !ok {
@@ -174,6 +204,12 @@ func (i *inputState) update() {
 			delete(i.standardGamepadButtonDurations, id)
 		}
 	}
+	for id := range i.gamepadAxisRestValues {
+		if _, ok := i.gamepadIDs[id]; !ok {
+			delete(i.gamepadAxisRestValues, id)
+			delete(i.gamepadAxisDurations, id)
+		}
+	}
 
 	// Touches
 
@@ -443,6 +479,33 @@ func AppendJustPressedGamepadButtons(id ebiten.GamepadID, buttons []ebiten.Gamep
 	return buttons
 }
 
+// gamepadAxisMoveThreshold is the difference from the rest value to consider an axis moved.
+const gamepadAxisMoveThreshold = 0.5
+
+// AppendJustMovedGamepadAxes append just moved gamepad axes to axes and returns the extended buffer.
+// Giving a slice that already has enough capacity works efficiently.
+//
+// An axis is regarded as moved when its value differs from the value at the gamepad's connection by 0.5 or more.
+// Thus, an axis that is already moved at the connection is not reported correctly.
+// This is useful to detect which axis a player moves, e.g., in a button configuration screen.
+//
+// AppendJustMovedGamepadAxes must be called in a game's Update, not Draw.
+//
+// AppendJustMovedGamepadAxes is concurrent safe.
+func AppendJustMovedGamepadAxes(id ebiten.GamepadID, axes []ebiten.GamepadAxisType) []ebiten.GamepadAxisType {
+	theInputState.m.RLock()
+	defer theInputState.m.RUnlock()
+
+	for a, d := range theInputState.gamepadAxisDurations[id] {
+		if d != 1 {
+			continue
+		}
+		axes = append(axes, a)
+	}
+
+	return axes
+}
+
 // AppendJustReleasedGamepadButtons append just released gamepad buttons to buttons and returns the extended buffer.
 // Giving a slice that already has enough capacity works efficiently.
 //
@@ -460,16 +523,16 @@ func AppendJustReleasedGamepadButtons(id ebiten.GamepadID, buttons []ebiten.Game
 		return buttons
 	}
 
-	for b := ebiten.GamepadButton(0); b <= ebiten.GamepadButtonMax; b++ {
+	for b := range theInputState.gamepadButtonDurations[id] {
 		if theInputState.gamepadButtonDurations[id][b] == 0 {
 			continue
 		}
 
-		if theInputState.prevGamepadButtonDurations[id][b] > 0 {
+		if gamepadButtonDuration(theInputState.prevGamepadButtonDurations[id], ebiten.GamepadButton(b)) > 0 {
 			continue
 		}
 
-		buttons = append(buttons, b)
+		buttons = append(buttons, ebiten.GamepadButton(b))
 	}
 
 	return buttons
@@ -493,14 +556,8 @@ func IsGamepadButtonJustPressed(id ebiten.GamepadID, button ebiten.GamepadButton
 // IsGamepadButtonJustReleased is concurrent safe.
 func IsGamepadButtonJustReleased(id ebiten.GamepadID, button ebiten.GamepadButton) bool {
 	theInputState.m.RLock()
-	prev := 0
-	if _, ok := theInputState.prevGamepadButtonDurations[id]; ok {
-		prev = theInputState.prevGamepadButtonDurations[id][button]
-	}
-	current := 0
-	if _, ok := theInputState.gamepadButtonDurations[id]; ok {
-		current = theInputState.gamepadButtonDurations[id][button]
-	}
+	prev := gamepadButtonDuration(theInputState.prevGamepadButtonDurations[id], button)
+	current := gamepadButtonDuration(theInputState.gamepadButtonDurations[id], button)
 	theInputState.m.RUnlock()
 	return current == 0 && prev > 0
 }
@@ -512,14 +569,20 @@ func IsGamepadButtonJustReleased(id ebiten.GamepadID, button ebiten.GamepadButto
 // GamepadButtonPressDuration is concurrent safe.
 func GamepadButtonPressDuration(id ebiten.GamepadID, button ebiten.GamepadButton) int {
 	theInputState.m.RLock()
-	s := 0
-	if _, ok := theInputState.gamepadButtonDurations[id]; ok {
-		s = theInputState.gamepadButtonDurations[id][button]
-	}
+	s := gamepadButtonDuration(theInputState.gamepadButtonDurations[id], button)
 	theInputState.m.RUnlock()
 	return s
 }
 
+// gamepadButtonDuration returns the duration of the button in durations, or 0 if the button is out of range.
+// durations is nil for an unknown gamepad, and can be longer than GamepadButtonMax+1 for a gamepad with many buttons.
+func gamepadButtonDuration(durations []int, button ebiten.GamepadButton) int {
+	if button < 0 || int(button) >= len(durations) {
+		return 0
+	}
+	return durations[button]
+}
+
 // AppendPressedStandardGamepadButtons append currently pressed standard gamepad buttons to buttons and returns the extended buffer.
 // Giving a slice that already has enough capacity works efficiently.
 //
@@ -585,7 +648,7 @@ func AppendJustReleasedStandardGamepadButtons(id ebiten.GamepadID, buttons []ebi
 		return buttons
 	}
 
-	for b := ebiten.StandardGamepadButton(0); b <= ebiten.StandardGamepadButtonMax; b++ {
+	for b := ebiten.StandardGamepadButton(0); b <= ebiten.StandardGamepadButtonExtendedMax; b++ {
 		if theInputState.standardGamepadButtonDurations[id][b] == 0 {
 			continue
 		}
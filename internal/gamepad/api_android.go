@@ -0,0 +1,263 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+/*
+#include <jni.h>
+#include <stdint.h>
+
+static int clearException(JNIEnv* env) {
+  if ((*env)->ExceptionCheck(env)) {
+    (*env)->ExceptionClear(env);
+    return 1;
+  }
+  return 0;
+}
+
+static jobject createOneShot(JNIEnv* env, int64_t milliseconds, int amplitude) {
+  const jclass android_os_VibrationEffect = (*env)->FindClass(env, "android/os/VibrationEffect");
+  const jobject effect =
+      (*env)->CallStaticObjectMethod(
+          env, android_os_VibrationEffect,
+          (*env)->GetStaticMethodID(env, android_os_VibrationEffect, "createOneShot", "(JI)Landroid/os/VibrationEffect;"),
+          milliseconds, amplitude);
+  (*env)->DeleteLocalRef(env, android_os_VibrationEffect);
+  if (clearException(env)) {
+    return NULL;
+  }
+  return effect;
+}
+
+// Basically same as:
+//
+//     InputDevice device = InputDevice.getDevice(deviceId);
+//     if (Build.VERSION.SDK_INT >= 31) {
+//       VibratorManager manager = device.getVibratorManager();
+//       int[] ids = manager.getVibratorIds();
+//       CombinedVibration.ParallelCombination c = CombinedVibration.startParallel();
+//       if (ids.length >= 2) {
+//         c.addVibrator(ids[0], VibrationEffect.createOneShot(milliseconds, strong));
+//         c.addVibrator(ids[1], VibrationEffect.createOneShot(milliseconds, weak));
+//       } else if (ids.length == 1) {
+//         c.addVibrator(ids[0], VibrationEffect.createOneShot(milliseconds, max(strong, weak)));
+//       }
+//       manager.vibrate(c.combine());
+//     } else {
+//       Vibrator v = device.getVibrator();
+//       if (Build.VERSION.SDK_INT >= 26) {
+//         v.vibrate(VibrationEffect.createOneShot(milliseconds, max(strong, weak)));
+//       } else {
+//         v.vibrate(milliseconds);
+//       }
+//     }
+//
+// The vibrations are canceled when milliseconds is 0.
+// A motor with an amplitude 0 is not vibrated, as createOneShot doesn't accept 0.
+//
+// Unlike the vibrator of Context, the vibrators of InputDevice never vibrate the device body.
+static void vibrateGamepad(uintptr_t jni_env, int device_id, int64_t milliseconds, int strong, int weak) {
+  JNIEnv* env = (JNIEnv*)jni_env;
+
+  static int apiLevel = 0;
+  if (!apiLevel) {
+    const jclass android_os_Build_VERSION = (*env)->FindClass(env, "android/os/Build$VERSION");
+
+    apiLevel = (*env)->GetStaticIntField(
+        env, android_os_Build_VERSION,
+        (*env)->GetStaticFieldID(env, android_os_Build_VERSION, "SDK_INT", "I"));
+
+    (*env)->DeleteLocalRef(env, android_os_Build_VERSION);
+  }
+
+  const int amplitude = strong > weak ? strong : weak;
+
+  const jclass android_view_InputDevice = (*env)->FindClass(env, "android/view/InputDevice");
+  const jobject device =
+      (*env)->CallStaticObjectMethod(
+          env, android_view_InputDevice,
+          (*env)->GetStaticMethodID(env, android_view_InputDevice, "getDevice", "(I)Landroid/view/InputDevice;"),
+          device_id);
+  if (clearException(env) || !device) {
+    (*env)->DeleteLocalRef(env, android_view_InputDevice);
+    return;
+  }
+
+  if (apiLevel >= 31) {
+    const jclass android_os_VibratorManager = (*env)->FindClass(env, "android/os/VibratorManager");
+    const jobject manager =
+        (*env)->CallObjectMethod(
+            env, device,
+            (*env)->GetMethodID(env, android_view_InputDevice, "getVibratorManager", "()Landroid/os/VibratorManager;"));
+    if (!clearException(env) && manager) {
+      const jintArray ids =
+          (*env)->CallObjectMethod(
+              env, manager,
+              (*env)->GetMethodID(env, android_os_VibratorManager, "getVibratorIds", "()[I"));
+      const jsize count = (!clearException(env) && ids) ? (*env)->GetArrayLength(env, ids) : 0;
+      if (count > 0 && milliseconds <= 0) {
+        (*env)->CallVoidMethod(
+            env, manager,
+            (*env)->GetMethodID(env, android_os_VibratorManager, "cancel", "()V"));
+        clearException(env);
+      } else if (count > 0) {
+        jint vibratorIDs[2] = {0};
+        (*env)->GetIntArrayRegion(env, ids, 0, count >= 2 ? 2 : 1, vibratorIDs);
+
+        const jclass android_os_CombinedVibration = (*env)->FindClass(env, "android/os/CombinedVibration");
+        const jclass android_os_CombinedVibration_ParallelCombination = (*env)->FindClass(env, "android/os/CombinedVibration$ParallelCombination");
+        const jmethodID addVibrator =
+            (*env)->GetMethodID(
+                env, android_os_CombinedVibration_ParallelCombination,
+                "addVibrator", "(ILandroid/os/VibrationEffect;)Landroid/os/CombinedVibration$ParallelCombination;");
+
+        const jobject combination =
+            (*env)->CallStaticObjectMethod(
+                env, android_os_CombinedVibration,
+                (*env)->GetStaticMethodID(env, android_os_CombinedVibration, "startParallel", "()Landroid/os/CombinedVibration$ParallelCombination;"));
+        if (!clearException(env) && combination) {
+          // The first vibrator is the low-frequency (strong) motor, and the second is the high-frequency (weak) motor.
+          // See https://github.com/libsdl-org/SDL/blob/release-2.30.x/android-project/app/src/main/java/org/libsdl/app/SDLControllerManager.java
+          int amplitudes[2] = {strong, weak};
+          if (count < 2) {
+            amplitudes[0] = amplitude;
+          }
+          for (int i = 0; i < (count >= 2 ? 2 : 1); i++) {
+            if (amplitudes[i] <= 0) {
+              continue;
+            }
+            const jobject effect = createOneShot(env, milliseconds, amplitudes[i]);
+            if (!effect) {
+              continue;
+            }
+            const jobject c = (*env)->CallObjectMethod(env, combination, addVibrator, vibratorIDs[i], effect);
+            clearException(env);
+            if (c) {
+              (*env)->DeleteLocalRef(env, c);
+            }
+            (*env)->DeleteLocalRef(env, effect);
+          }
+
+          const jobject vibration =
+              (*env)->CallObjectMethod(
+                  env, combination,
+                  (*env)->GetMethodID(env, android_os_CombinedVibration_ParallelCombination, "combine", "()Landroid/os/CombinedVibration;"));
+          if (!clearException(env) && vibration) {
+            (*env)->CallVoidMethod(
+                env, manager,
+                (*env)->GetMethodID(env, android_os_VibratorManager, "vibrate", "(Landroid/os/CombinedVibration;)V"),
+                vibration);
+            clearException(env);
+            (*env)->DeleteLocalRef(env, vibration);
+          }
+          (*env)->DeleteLocalRef(env, combination);
+        }
+
+        (*env)->DeleteLocalRef(env, android_os_CombinedVibration);
+        (*env)->DeleteLocalRef(env, android_os_CombinedVibration_ParallelCombination);
+      }
+      if (ids) {
+        (*env)->DeleteLocalRef(env, ids);
+      }
+      (*env)->DeleteLocalRef(env, manager);
+    }
+    (*env)->DeleteLocalRef(env, android_os_VibratorManager);
+  } else {
+    const jclass android_os_Vibrator = (*env)->FindClass(env, "android/os/Vibrator");
+    const jobject vibrator =
+        (*env)->CallObjectMethod(
+            env, device,
+            (*env)->GetMethodID(env, android_view_InputDevice, "getVibrator", "()Landroid/os/Vibrator;"));
+    if (!clearException(env) && vibrator) {
+      const jboolean hasVibrator =
+          (*env)->CallBooleanMethod(
+              env, vibrator,
+              (*env)->GetMethodID(env, android_os_Vibrator, "hasVibrator", "()Z"));
+      if (clearException(env) || !hasVibrator) {
+        // Do nothing.
+      } else if (milliseconds <= 0) {
+        (*env)->CallVoidMethod(
+            env, vibrator,
+            (*env)->GetMethodID(env, android_os_Vibrator, "cancel", "()V"));
+        clearException(env);
+      } else if (apiLevel >= 26) {
+        const jboolean hasAmplitudeControl =
+            (*env)->CallBooleanMethod(
+                env, vibrator,
+                (*env)->GetMethodID(env, android_os_Vibrator, "hasAmplitudeControl", "()Z"));
+        clearException(env);
+        // -1 is VibrationEffect.DEFAULT_AMPLITUDE.
+        const jobject effect = createOneShot(env, milliseconds, hasAmplitudeControl ? amplitude : -1);
+        if (effect) {
+          (*env)->CallVoidMethod(
+              env, vibrator,
+              (*env)->GetMethodID(env, android_os_Vibrator, "vibrate", "(Landroid/os/VibrationEffect;)V"),
+              effect);
+          clearException(env);
+          (*env)->DeleteLocalRef(env, effect);
+        }
+      } else {
+        (*env)->CallVoidMethod(
+            env, vibrator,
+            (*env)->GetMethodID(env, android_os_Vibrator, "vibrate", "(J)V"),
+            milliseconds);
+        clearException(env);
+      }
+      (*env)->DeleteLocalRef(env, vibrator);
+    }
+    (*env)->DeleteLocalRef(env, android_os_Vibrator);
+  }
+
+  (*env)->DeleteLocalRef(env, device);
+  (*env)->DeleteLocalRef(env, android_view_InputDevice);
+}
+*/
+import "C"
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/mobile/app"
+)
+
+var (
+	vibrationCh     chan func(env uintptr)
+	vibrationChOnce sync.Once
+)
+
+// runOnJVMAsync runs f on the JVM without blocking the caller.
+// The functions are executed in order so that a cancellation is never overtaken by a previous vibration.
+func runOnJVMAsync(f func(env uintptr)) {
+	vibrationChOnce.Do(func() {
+		vibrationCh = make(chan func(env uintptr), 16)
+		go func() {
+			for f := range vibrationCh {
+				_ = app.RunOnJVM(func(vm, env, ctx uintptr) error {
+					f(env)
+					return nil
+				})
+			}
+		}()
+	})
+	vibrationCh <- f
+}
+
+// vibrateAndroidGamepad vibrates the motors of the input device. The vibrations are canceled when duration is 0.
+// strong and weak are amplitudes in between 0 and 255.
+func vibrateAndroidGamepad(androidDeviceID int, duration time.Duration, strong, weak int) {
+	runOnJVMAsync(func(env uintptr) {
+		C.vibrateGamepad(C.uintptr_t(env), C.int(androidDeviceID), C.int64_t(duration/time.Millisecond), C.int(strong), C.int(weak))
+	})
+}
@@ -23,6 +23,13 @@ import (
 )
 
 const (
+	_DBT_DEVICEARRIVAL          = 0x8000
+	_DBT_DEVICEREMOVECOMPLETE   = 0x8004
+	_DBT_DEVNODES_CHANGED       = 0x0007
+	_DBT_DEVTYP_DEVICEINTERFACE = 0x00000005
+
+	_DEVICE_NOTIFY_WINDOW_HANDLE = 0x00000000
+
 	_DI_OK           = 0
 	_DI_NOEFFECT     = _SI_FALSE
 	_DI_PROPNOEFFECT = _SI_FALSE
@@ -78,6 +85,19 @@ const (
 
 	_WM_DEVICECHANGE = 0x0219
 
+	_BATTERY_DEVTYPE_GAMEPAD = 0x00
+
+	_BATTERY_TYPE_DISCONNECTED = 0x00
+	_BATTERY_TYPE_WIRED        = 0x01
+	_BATTERY_TYPE_ALKALINE     = 0x02
+	_BATTERY_TYPE_NIMH         = 0x03
+	_BATTERY_TYPE_UNKNOWN      = 0xff
+
+	_BATTERY_LEVEL_EMPTY  = 0x00
+	_BATTERY_LEVEL_LOW    = 0x01
+	_BATTERY_LEVEL_MEDIUM = 0x02
+	_BATTERY_LEVEL_FULL   = 0x03
+
 	_XINPUT_CAPS_WIRELESS = 0x0002
 
 	_XINPUT_DEVSUBTYPE_GAMEPAD      = 0x01
@@ -98,10 +118,15 @@ const (
 	_XINPUT_GAMEPAD_RIGHT_THUMB    = 0x0080
 	_XINPUT_GAMEPAD_LEFT_SHOULDER  = 0x0100
 	_XINPUT_GAMEPAD_RIGHT_SHOULDER = 0x0200
+	_XINPUT_GAMEPAD_GUIDE          = 0x0400 // Only reported by XInputGetStateEx.
 	_XINPUT_GAMEPAD_A              = 0x1000
 	_XINPUT_GAMEPAD_B              = 0x2000
 	_XINPUT_GAMEPAD_X              = 0x4000
 	_XINPUT_GAMEPAD_Y              = 0x8000
+
+	_XINPUT_GAMEPAD_LEFT_THUMB_DEADZONE  = 7849
+	_XINPUT_GAMEPAD_RIGHT_THUMB_DEADZONE = 8689
+	_XINPUT_GAMEPAD_TRIGGER_THRESHOLD    = 30
 )
 
 func _DIDFT_GETTYPE(n uint32) byte {
@@ -130,6 +155,9 @@ var (
 	_GUID_RzAxis        = windows.GUID{Data1: 0xa36d02e3, Data2: 0xc9f3, Data3: 0x11cf, Data4: [...]byte{0xbf, 0xc7, 0x44, 0x45, 0x53, 0x54, 0x00, 0x00}}
 	_GUID_Slider        = windows.GUID{Data1: 0xa36d02e4, Data2: 0xc9f3, Data3: 0x11cf, Data4: [...]byte{0xbf, 0xc7, 0x44, 0x45, 0x53, 0x54, 0x00, 0x00}}
 	_GUID_POV           = windows.GUID{Data1: 0xa36d02f2, Data2: 0xc9f3, Data3: 0x11cf, Data4: [...]byte{0xbf, 0xc7, 0x44, 0x45, 0x53, 0x54, 0x00, 0x00}}
+
+	_GUID_DEVINTERFACE_HID  = windows.GUID{Data1: 0x4d1e55b2, Data2: 0xf16f, Data3: 0x11cf, Data4: [...]byte{0x88, 0xcb, 0x00, 0x11, 0x11, 0x00, 0x00, 0x30}}
+	_GUID_DEVINTERFACE_XUSB = windows.GUID{Data1: 0xec87f1e3, Data2: 0xc13b, Data3: 0x4100, Data4: [...]byte{0xb5, 0xf7, 0x8b, 0x84, 0xd5, 0x42, 0x60, 0xcb}}
 )
 
 var (
@@ -138,9 +166,10 @@ var (
 
 	procGetModuleHandleW = kernel32.NewProc("GetModuleHandleW")
 
-	procCallWindowProcW        = user32.NewProc("CallWindowProcW")
-	procGetRawInputDeviceInfoW = user32.NewProc("GetRawInputDeviceInfoW")
-	procGetRawInputDeviceList  = user32.NewProc("GetRawInputDeviceList")
+	procCallWindowProcW             = user32.NewProc("CallWindowProcW")
+	procGetRawInputDeviceInfoW      = user32.NewProc("GetRawInputDeviceInfoW")
+	procGetRawInputDeviceList       = user32.NewProc("GetRawInputDeviceList")
+	procRegisterDeviceNotificationW = user32.NewProc("RegisterDeviceNotificationW")
 
 	procSetWindowLongW    = user32.NewProc("SetWindowLongW")    // 32-Bit Windows version.
 	procSetWindowLongPtrW = user32.NewProc("SetWindowLongPtrW") // 64-Bit Windows version.
@@ -184,6 +213,17 @@ func _GetRawInputDeviceList(pRawInputDeviceList *_RAWINPUTDEVICELIST, puiNumDevi
 	return uint32(r), nil
 }
 
+func _RegisterDeviceNotificationW(hRecipient windows.HWND, notificationFilter unsafe.Pointer, flags uint32) (windows.Handle, error) {
+	r, _, e := procRegisterDeviceNotificationW.Call(uintptr(hRecipient), uintptr(notificationFilter), uintptr(flags))
+	if r == 0 {
+		if e != nil && e != windows.ERROR_SUCCESS {
+			return 0, fmt.Errorf("gamepad: RegisterDeviceNotificationW failed: %w", e)
+		}
+		return 0, fmt.Errorf("gamepad: RegisterDeviceNotificationW returned 0")
+	}
+	return windows.Handle(r), nil
+}
+
 func _SetWindowLongPtrW(hWnd windows.HWND, nIndex int32, dwNewLong uintptr) (uintptr, error) {
 	var p *windows.LazyProc
 	if procSetWindowLongPtrW.Find() == nil {
@@ -203,6 +243,14 @@ func _SetWindowLongPtrW(hWnd windows.HWND, nIndex int32, dwNewLong uintptr) (uin
 	return h, nil
 }
 
+type _DEV_BROADCAST_DEVICEINTERFACE_W struct {
+	dbcc_size       uint32
+	dbcc_devicetype uint32
+	dbcc_reserved   uint32
+	dbcc_classguid  windows.GUID
+	dbcc_name       [1]uint16
+}
+
 type _DIDATAFORMAT struct {
 	dwSize     uint32
 	dwObjSize  uint32
@@ -459,6 +507,11 @@ type _RAWINPUTDEVICELIST struct {
 	dwType  uint32
 }
 
+type _XINPUT_BATTERY_INFORMATION struct {
+	BatteryType  byte
+	BatteryLevel byte
+}
+
 type _XINPUT_CAPABILITIES struct {
 	typ       byte
 	subType   byte
@@ -482,6 +535,13 @@ type _XINPUT_STATE struct {
 	Gamepad        _XINPUT_GAMEPAD
 }
 
+// _XINPUT_STATE_EX is the state for XInputGetStateEx, which has a reserved field after the gamepad state.
+type _XINPUT_STATE_EX struct {
+	dwPacketNumber    uint32
+	Gamepad           _XINPUT_GAMEPAD
+	dwPaddingReserved uint32
+}
+
 type _XINPUT_VIBRATION struct {
 	wLeftMotorSpeed  uint16
 	wRightMotorSpeed uint16
@@ -0,0 +1,149 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nintendosdk && !playstation5
+
+package gamepad
+
+import (
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	_IOC_OUT   = 0x40000000
+	_IOC_IN    = 0x80000000
+	_IOC_INOUT = _IOC_IN | _IOC_OUT
+
+	_IOCPARM_MASK = 0x1fff
+)
+
+// _USB_MAX_REPORT_DESC_SIZE is the maximum size of a report descriptor read from a device.
+// FreeBSD doesn't limit the size, but report descriptors of gamepads are much smaller than this.
+const _USB_MAX_REPORT_DESC_SIZE = 4096
+
+func _IOC(inout, group, num, len uint) uint {
+	return inout | (len&_IOCPARM_MASK)<<16 | group<<8 | num
+}
+
+func _IOR(group, num, len uint) uint {
+	return _IOC(_IOC_OUT, group, num, len)
+}
+
+func _IOWR(group, num, len uint) uint {
+	return _IOC(_IOC_INOUT, group, num, len)
+}
+
+func _USB_GET_REPORT_DESC() uint {
+	return _IOWR('U', 21, uint(unsafe.Sizeof(usb_gen_descriptor{})))
+}
+
+func _USB_GET_REPORT_ID() uint {
+	return _IOR('U', 25, uint(unsafe.Sizeof(int32(0))))
+}
+
+func _USB_GET_DEVICEINFO() uint {
+	return _IOR('U', 112, uint(unsafe.Sizeof(usb_device_info{})))
+}
+
+type usb_gen_descriptor struct {
+	ugd_data         unsafe.Pointer
+	ugd_lang_id      uint16
+	ugd_maxlen       uint16
+	ugd_actlen       uint16
+	ugd_offset       uint16
+	ugd_config_index uint8
+	ugd_string_index uint8
+	ugd_iface_index  uint8
+	ugd_altif_index  uint8
+	ugd_endpt_index  uint8
+	ugd_report_type  uint8
+	reserved         [8]uint8
+}
+
+type usb_device_info struct {
+	udi_productNo    uint16
+	udi_vendorNo     uint16
+	udi_releaseNo    uint16
+	udi_power        uint16
+	udi_bus          uint8
+	udi_addr         uint8
+	udi_index        uint8
+	udi_class        uint8
+	udi_subclass     uint8
+	udi_protocol     uint8
+	udi_config_no    uint8
+	udi_config_index uint8
+	udi_speed        uint8
+	udi_mode         uint8
+	udi_nports       uint8
+	udi_hubaddr      uint8
+	udi_hubindex     uint8
+	udi_hubport      uint8
+	udi_power_mode   uint8
+	udi_suspended    uint8
+	udi_reserved     [16]uint16
+	udi_product      [128]byte
+	udi_vendor       [128]byte
+	udi_serial       [64]byte
+	udi_release      [8]byte
+}
+
+func ioctl(fd int, request uint, ptr unsafe.Pointer) error {
+	if _, _, e := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(request), uintptr(ptr)); e != 0 {
+		return e
+	}
+	return nil
+}
+
+// usbReportDescriptor returns the report descriptor of the uhid device.
+func usbReportDescriptor(fd int) ([]byte, error) {
+	buf := make([]byte, _USB_MAX_REPORT_DESC_SIZE)
+	desc := usb_gen_descriptor{
+		ugd_data:   unsafe.Pointer(&buf[0]),
+		ugd_maxlen: uint16(len(buf)),
+	}
+	err := ioctl(fd, _USB_GET_REPORT_DESC(), unsafe.Pointer(&desc))
+	runtime.KeepAlive(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:desc.ugd_actlen], nil
+}
+
+// usbReportID returns the ID of the input reports read from the uhid device, or 0 if the device doesn't use report IDs.
+func usbReportID(fd int) (uint8, error) {
+	var id int32
+	if err := ioctl(fd, _USB_GET_REPORT_ID(), unsafe.Pointer(&id)); err != nil {
+		return 0, err
+	}
+	return uint8(id), nil
+}
+
+// usbDeviceInfo returns the information of the USB device of the uhid device.
+func usbDeviceInfo(fd int) (usbDevice, error) {
+	var info usb_device_info
+	if err := ioctl(fd, _USB_GET_DEVICEINFO(), unsafe.Pointer(&info)); err != nil {
+		return usbDevice{}, err
+	}
+	return usbDevice{
+		vendor:  info.udi_vendorNo,
+		product: info.udi_productNo,
+		release: info.udi_releaseNo,
+		name:    unix.ByteSliceToString(info.udi_product[:]),
+		serial:  unix.ByteSliceToString(info.udi_serial[:]),
+	}, nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !ios
+
+package gamepad
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+	"github.com/ebitengine/purego/objc"
+)
+
+var (
+	class_CHHapticDynamicParameter objc.Class
+	class_CHHapticEvent            objc.Class
+	class_CHHapticEventParameter   objc.Class
+	class_CHHapticPattern          objc.Class
+	class_GCController             objc.Class
+	class_NSArray                  objc.Class
+	class_NSNotificationCenter     objc.Class
+)
+
+var (
+	sel_addObserverSelectorNameObject    = objc.RegisterName("addObserver:selector:name:object:")
+	sel_alloc                            = objc.RegisterName("alloc")
+	sel_array                            = objc.RegisterName("array")
+	sel_arrayWithObjectsCount            = objc.RegisterName("arrayWithObjects:count:")
+	sel_buttonA                          = objc.RegisterName("buttonA")
+	sel_buttonB                          = objc.RegisterName("buttonB")
+	sel_buttonHome                       = objc.RegisterName("buttonHome")
+	sel_buttonMenu                       = objc.RegisterName("buttonMenu")
+	sel_buttonOptions                    = objc.RegisterName("buttonOptions")
+	sel_buttonShare                      = objc.RegisterName("buttonShare")
+	sel_buttonX                          = objc.RegisterName("buttonX")
+	sel_buttonY                          = objc.RegisterName("buttonY")
+	sel_controllerDidConnect             = objc.RegisterName("controllerDidConnect:")
+	sel_controllerDidDisconnect          = objc.RegisterName("controllerDidDisconnect:")
+	sel_controllers                      = objc.RegisterName("controllers")
+	sel_count                            = objc.RegisterName("count")
+	sel_createAdvancedPlayerWithPattern  = objc.RegisterName("createAdvancedPlayerWithPattern:error:")
+	sel_createEngineWithLocality         = objc.RegisterName("createEngineWithLocality:")
+	sel_defaultCenter                    = objc.RegisterName("defaultCenter")
+	sel_down                             = objc.RegisterName("down")
+	sel_dpad                             = objc.RegisterName("dpad")
+	sel_extendedGamepad                  = objc.RegisterName("extendedGamepad")
+	sel_haptics                          = objc.RegisterName("haptics")
+	sel_initWithEventType                = objc.RegisterName("initWithEventType:parameters:relativeTime:duration:")
+	sel_initWithEventsParameters         = objc.RegisterName("initWithEvents:parameters:error:")
+	sel_initWithParameterIDValue         = objc.RegisterName("initWithParameterID:value:")
+	sel_initWithParameterIDValueTime     = objc.RegisterName("initWithParameterID:value:relativeTime:")
+	sel_isPressed                        = objc.RegisterName("isPressed")
+	sel_left                             = objc.RegisterName("left")
+	sel_leftShoulder                     = objc.RegisterName("leftShoulder")
+	sel_leftThumbstick                   = objc.RegisterName("leftThumbstick")
+	sel_leftThumbstickButton             = objc.RegisterName("leftThumbstickButton")
+	sel_leftTrigger                      = objc.RegisterName("leftTrigger")
+	sel_new                              = objc.RegisterName("new")
+	sel_objectAtIndex                    = objc.RegisterName("objectAtIndex:")
+	sel_paddleButton1                    = objc.RegisterName("paddleButton1")
+	sel_paddleButton2                    = objc.RegisterName("paddleButton2")
+	sel_paddleButton3                    = objc.RegisterName("paddleButton3")
+	sel_paddleButton4                    = objc.RegisterName("paddleButton4")
+	sel_playerIndex                      = objc.RegisterName("playerIndex")
+	sel_productCategory                  = objc.RegisterName("productCategory")
+	sel_release                          = objc.RegisterName("release")
+	sel_respondsToSelector               = objc.RegisterName("respondsToSelector:")
+	sel_retain                           = objc.RegisterName("retain")
+	sel_right                            = objc.RegisterName("right")
+	sel_rightShoulder                    = objc.RegisterName("rightShoulder")
+	sel_rightThumbstick                  = objc.RegisterName("rightThumbstick")
+	sel_rightThumbstickButton            = objc.RegisterName("rightThumbstickButton")
+	sel_rightTrigger                     = objc.RegisterName("rightTrigger")
+	sel_sendParametersAtTime             = objc.RegisterName("sendParameters:atTime:error:")
+	sel_setPlayerIndex                   = objc.RegisterName("setPlayerIndex:")
+	sel_setShouldMonitorBackgroundEvents = objc.RegisterName("setShouldMonitorBackgroundEvents:")
+	sel_startAndReturnError              = objc.RegisterName("startAndReturnError:")
+	sel_startAtTime                      = objc.RegisterName("startAtTime:error:")
+	sel_stopAtTime                       = objc.RegisterName("stopAtTime:error:")
+	sel_stopWithCompletionHandler        = objc.RegisterName("stopWithCompletionHandler:")
+	sel_supportsHIDDevice                = objc.RegisterName("supportsHIDDevice:")
+	sel_touchpadButton                   = objc.RegisterName("touchpadButton")
+	sel_up                               = objc.RegisterName("up")
+	sel_value                            = objc.RegisterName("value")
+	sel_vendorName                       = objc.RegisterName("vendorName")
+	sel_xAxis                            = objc.RegisterName("xAxis")
+	sel_yAxis                            = objc.RegisterName("yAxis")
+)
+
+var (
+	gcControllerDidConnectNotification    objc.ID
+	gcControllerDidDisconnectNotification objc.ID
+	gcHapticsLocalityDefault              objc.ID
+	gcHapticDurationInfinite              float32
+)
+
+var (
+	chHapticEventTypeHapticContinuous                objc.ID
+	chHapticEventParameterIDHapticIntensity          objc.ID
+	chHapticEventParameterIDHapticSharpness          objc.ID
+	chHapticDynamicParameterIDHapticIntensityControl objc.ID
+	chHapticDynamicParameterIDHapticSharpnessControl objc.ID
+)
+
+func initializeGameController() error {
+	if _, err := purego.Dlopen("/System/Library/Frameworks/Foundation.framework/Foundation", purego.RTLD_LAZY|purego.RTLD_GLOBAL); err != nil {
+		return err
+	}
+	gc, err := purego.Dlopen("/System/Library/Frameworks/GameController.framework/GameController", purego.RTLD_LAZY|purego.RTLD_GLOBAL)
+	if err != nil {
+		return err
+	}
+
+	// The notification names are NSString pointers.
+	connect, err := purego.Dlsym(gc, "GCControllerDidConnectNotification")
+	if err != nil {
+		return err
+	}
+	disconnect, err := purego.Dlsym(gc, "GCControllerDidDisconnectNotification")
+	if err != nil {
+		return err
+	}
+	gcControllerDidConnectNotification = **(**objc.ID)(unsafe.Pointer(&connect))
+	gcControllerDidDisconnectNotification = **(**objc.ID)(unsafe.Pointer(&disconnect))
+
+	class_GCController = objc.GetClass("GCController")
+	if class_GCController == 0 {
+		return errors.New("gamepad: GCController is not available")
+	}
+	class_NSNotificationCenter = objc.GetClass("NSNotificationCenter")
+	class_NSArray = objc.GetClass("NSArray")
+
+	return nil
+}
+
+// initializeCoreHaptics loads CoreHaptics for the haptics of GCController, which is available on macOS 11 or later.
+func initializeCoreHaptics() error {
+	gc, err := purego.Dlopen("/System/Library/Frameworks/GameController.framework/GameController", purego.RTLD_LAZY|purego.RTLD_GLOBAL)
+	if err != nil {
+		return err
+	}
+	ch, err := purego.Dlopen("/System/Library/Frameworks/CoreHaptics.framework/CoreHaptics", purego.RTLD_LAZY|purego.RTLD_GLOBAL)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range []struct {
+		lib  uintptr
+		name string
+		ptr  *objc.ID
+	}{
+		{gc, "GCHapticsLocalityDefault", &gcHapticsLocalityDefault},
+		{ch, "CHHapticEventTypeHapticContinuous", &chHapticEventTypeHapticContinuous},
+		{ch, "CHHapticEventParameterIDHapticIntensity", &chHapticEventParameterIDHapticIntensity},
+		{ch, "CHHapticEventParameterIDHapticSharpness", &chHapticEventParameterIDHapticSharpness},
+		{ch, "CHHapticDynamicParameterIDHapticIntensityControl", &chHapticDynamicParameterIDHapticIntensityControl},
+		{ch, "CHHapticDynamicParameterIDHapticSharpnessControl", &chHapticDynamicParameterIDHapticSharpnessControl},
+	} {
+		sym, err := purego.Dlsym(s.lib, s.name)
+		if err != nil {
+			return err
+		}
+		*s.ptr = **(**objc.ID)(unsafe.Pointer(&sym))
+	}
+
+	duration, err := purego.Dlsym(gc, "GCHapticDurationInfinite")
+	if err != nil {
+		return err
+	}
+	gcHapticDurationInfinite = **(**float32)(unsafe.Pointer(&duration))
+
+	class_CHHapticDynamicParameter = objc.GetClass("CHHapticDynamicParameter")
+	class_CHHapticEvent = objc.GetClass("CHHapticEvent")
+	class_CHHapticEventParameter = objc.GetClass("CHHapticEventParameter")
+	class_CHHapticPattern = objc.GetClass("CHHapticPattern")
+	if class_CHHapticPattern == 0 {
+		return errors.New("gamepad: CHHapticPattern is not available")
+	}
+
+	return nil
+}
+
+// nsArray returns an autoreleased NSArray of the objects.
+func nsArray(objs ...objc.ID) objc.ID {
+	if len(objs) == 0 {
+		return objc.ID(class_NSArray).Send(sel_array)
+	}
+	return objc.ID(class_NSArray).Send(sel_arrayWithObjectsCount, unsafe.Pointer(&objs[0]), uint(len(objs)))
+}
+
+// respondsToSelector reports whether id responds to sel.
+// Some properties of the GameController framework are available only on newer versions of macOS.
+func respondsToSelector(id objc.ID, sel objc.SEL) bool {
+	return objc.Send[bool](id, sel_respondsToSelector, sel)
+}
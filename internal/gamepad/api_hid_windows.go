@@ -0,0 +1,280 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	_HIDP_STATUS_SUCCESS = 0x00110000
+)
+
+type _HIDD_ATTRIBUTES struct {
+	Size          uint32
+	VendorID      uint16
+	ProductID     uint16
+	VersionNumber uint16
+}
+
+type _HIDP_CAPS struct {
+	Usage                     uint16
+	UsagePage                 uint16
+	InputReportByteLength     uint16
+	OutputReportByteLength    uint16
+	FeatureReportByteLength   uint16
+	Reserved                  [17]uint16
+	NumberLinkCollectionNodes uint16
+	NumberInputButtonCaps     uint16
+	NumberInputValueCaps      uint16
+	NumberInputDataIndices    uint16
+	NumberOutputButtonCaps    uint16
+	NumberOutputValueCaps     uint16
+	NumberOutputDataIndices   uint16
+	NumberFeatureButtonCaps   uint16
+	NumberFeatureValueCaps    uint16
+	NumberFeatureDataIndices  uint16
+}
+
+var (
+	hid = windows.NewLazySystemDLL("hid.dll")
+
+	procHidD_FreePreparsedData = hid.NewProc("HidD_FreePreparsedData")
+	procHidD_GetAttributes     = hid.NewProc("HidD_GetAttributes")
+	procHidD_GetFeature        = hid.NewProc("HidD_GetFeature")
+	procHidD_GetPreparsedData  = hid.NewProc("HidD_GetPreparsedData")
+	procHidP_GetCaps           = hid.NewProc("HidP_GetCaps")
+)
+
+func isHIDAvailable() bool {
+	for _, p := range []*windows.LazyProc{
+		procHidD_FreePreparsedData,
+		procHidD_GetAttributes,
+		procHidD_GetFeature,
+		procHidD_GetPreparsedData,
+		procHidP_GetCaps,
+	} {
+		if p.Find() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func _HidD_FreePreparsedData(preparsedData uintptr) {
+	_, _, _ = procHidD_FreePreparsedData.Call(preparsedData)
+}
+
+func _HidD_GetAttributes(hidDeviceObject windows.Handle, attributes *_HIDD_ATTRIBUTES) error {
+	r, _, e := procHidD_GetAttributes.Call(uintptr(hidDeviceObject), uintptr(unsafe.Pointer(attributes)))
+	if r == 0 {
+		if e != nil && e != windows.ERROR_SUCCESS {
+			return fmt.Errorf("gamepad: HidD_GetAttributes failed: %w", e)
+		}
+		return fmt.Errorf("gamepad: HidD_GetAttributes returned 0")
+	}
+	return nil
+}
+
+func _HidD_GetFeature(hidDeviceObject windows.Handle, reportBuffer []byte) error {
+	r, _, e := procHidD_GetFeature.Call(uintptr(hidDeviceObject), uintptr(unsafe.Pointer(&reportBuffer[0])), uintptr(len(reportBuffer)))
+	if r == 0 {
+		if e != nil && e != windows.ERROR_SUCCESS {
+			return fmt.Errorf("gamepad: HidD_GetFeature failed: %w", e)
+		}
+		return fmt.Errorf("gamepad: HidD_GetFeature returned 0")
+	}
+	return nil
+}
+
+func _HidD_GetPreparsedData(hidDeviceObject windows.Handle) (uintptr, error) {
+	var preparsedData uintptr
+	r, _, e := procHidD_GetPreparsedData.Call(uintptr(hidDeviceObject), uintptr(unsafe.Pointer(&preparsedData)))
+	if r == 0 {
+		if e != nil && e != windows.ERROR_SUCCESS {
+			return 0, fmt.Errorf("gamepad: HidD_GetPreparsedData failed: %w", e)
+		}
+		return 0, fmt.Errorf("gamepad: HidD_GetPreparsedData returned 0")
+	}
+	return preparsedData, nil
+}
+
+func _HidP_GetCaps(preparsedData uintptr, capabilities *_HIDP_CAPS) error {
+	r, _, _ := procHidP_GetCaps.Call(preparsedData, uintptr(unsafe.Pointer(capabilities)))
+	if uint32(r) != _HIDP_STATUS_SUCCESS {
+		return fmt.Errorf("gamepad: HidP_GetCaps failed: NTSTATUS 0x%08x", uint32(r))
+	}
+	return nil
+}
+
+// hidDevice is a HID device opened for overlapped I/O, so that input reports can be polled without blocking.
+type hidDevice struct {
+	path   string
+	handle windows.Handle
+	attrs  _HIDD_ATTRIBUTES
+	caps   _HIDP_CAPS
+
+	readEvent      windows.Handle
+	readOverlapped windows.Overlapped
+	readBuf        []byte
+	reading        bool
+
+	writeEvent      windows.Handle
+	writeOverlapped windows.Overlapped
+	writeBuf        []byte
+}
+
+func openHIDDevice(path string) (*hidDevice, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateFile(p, windows.GENERIC_READ|windows.GENERIC_WRITE, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return nil, fmt.Errorf("gamepad: CreateFile failed: %w", err)
+	}
+
+	d := &hidDevice{
+		path:   path,
+		handle: h,
+	}
+	success := false
+	defer func() {
+		if !success {
+			d.close()
+		}
+	}()
+
+	d.attrs.Size = uint32(unsafe.Sizeof(d.attrs))
+	if err := _HidD_GetAttributes(h, &d.attrs); err != nil {
+		return nil, err
+	}
+
+	preparsedData, err := _HidD_GetPreparsedData(h)
+	if err != nil {
+		return nil, err
+	}
+	err = _HidP_GetCaps(preparsedData, &d.caps)
+	_HidD_FreePreparsedData(preparsedData)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.readEvent, err = windows.CreateEvent(nil, 1, 0, nil); err != nil {
+		return nil, fmt.Errorf("gamepad: CreateEvent failed: %w", err)
+	}
+	if d.writeEvent, err = windows.CreateEvent(nil, 1, 0, nil); err != nil {
+		return nil, fmt.Errorf("gamepad: CreateEvent failed: %w", err)
+	}
+	d.readOverlapped.HEvent = d.readEvent
+	d.writeOverlapped.HEvent = d.writeEvent
+	d.readBuf = make([]byte, d.caps.InputReportByteLength)
+	d.writeBuf = make([]byte, d.caps.OutputReportByteLength)
+
+	success = true
+	return d, nil
+}
+
+// read returns an input report if available without blocking.
+// read returns nil when no report is available yet.
+// The returned slice is valid until the next call of read.
+func (d *hidDevice) read() ([]byte, error) {
+	if len(d.readBuf) == 0 {
+		return nil, nil
+	}
+	if !d.reading {
+		if err := windows.ResetEvent(d.readEvent); err != nil {
+			return nil, fmt.Errorf("gamepad: ResetEvent failed: %w", err)
+		}
+		if err := windows.ReadFile(d.handle, d.readBuf, nil, &d.readOverlapped); err != nil && !errors.Is(err, windows.ERROR_IO_PENDING) {
+			return nil, fmt.Errorf("gamepad: ReadFile failed: %w", err)
+		}
+		d.reading = true
+	}
+
+	var n uint32
+	if err := windows.GetOverlappedResult(d.handle, &d.readOverlapped, &n, false); err != nil {
+		if errors.Is(err, windows.ERROR_IO_INCOMPLETE) {
+			return nil, nil
+		}
+		d.reading = false
+		return nil, fmt.Errorf("gamepad: GetOverlappedResult failed: %w", err)
+	}
+	d.reading = false
+	return d.readBuf[:n], nil
+}
+
+// write writes an output report. The report is padded to the output report length of the device.
+func (d *hidDevice) write(report []byte) error {
+	if len(d.writeBuf) == 0 {
+		return fmt.Errorf("gamepad: the device doesn't have output reports")
+	}
+	n := copy(d.writeBuf, report)
+	for i := n; i < len(d.writeBuf); i++ {
+		d.writeBuf[i] = 0
+	}
+
+	if err := windows.ResetEvent(d.writeEvent); err != nil {
+		return fmt.Errorf("gamepad: ResetEvent failed: %w", err)
+	}
+	if err := windows.WriteFile(d.handle, d.writeBuf, nil, &d.writeOverlapped); err != nil && !errors.Is(err, windows.ERROR_IO_PENDING) {
+		return fmt.Errorf("gamepad: WriteFile failed: %w", err)
+	}
+	var written uint32
+	if err := windows.GetOverlappedResult(d.handle, &d.writeOverlapped, &written, true); err != nil {
+		return fmt.Errorf("gamepad: GetOverlappedResult failed: %w", err)
+	}
+	return nil
+}
+
+// getFeature reads the feature report of the given ID.
+func (d *hidDevice) getFeature(reportID byte) ([]byte, error) {
+	if d.caps.FeatureReportByteLength == 0 {
+		return nil, fmt.Errorf("gamepad: the device doesn't have feature reports")
+	}
+	buf := make([]byte, d.caps.FeatureReportByteLength)
+	buf[0] = reportID
+	if err := _HidD_GetFeature(d.handle, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (d *hidDevice) close() {
+	if d.handle != 0 {
+		if d.reading {
+			// Wait for the cancellation so that the buffer is no longer written by the system.
+			_ = windows.CancelIoEx(d.handle, &d.readOverlapped)
+			var n uint32
+			_ = windows.GetOverlappedResult(d.handle, &d.readOverlapped, &n, true)
+			d.reading = false
+		}
+		_ = windows.CloseHandle(d.handle)
+		d.handle = 0
+	}
+	if d.readEvent != 0 {
+		_ = windows.CloseHandle(d.readEvent)
+		d.readEvent = 0
+	}
+	if d.writeEvent != 0 {
+		_ = windows.CloseHandle(d.writeEvent)
+		d.writeEvent = 0
+	}
+}
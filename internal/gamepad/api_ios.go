@@ -15,9 +15,11 @@
 package gamepad
 
 // #cgo CFLAGS: -x objective-c
-// #cgo LDFLAGS: -framework Foundation -framework GameController
+// #cgo LDFLAGS: -framework Foundation -framework CoreHaptics -framework GameController
 //
+// #import <CoreHaptics/CoreHaptics.h>
 // #import <GameController/GameController.h>
+// #include <stdatomic.h>
 //
 // static NSString* GCInputXboxShareButton = @"Button Share";
 //
@@ -83,8 +85,281 @@ package gamepad
 //   bool hasDualshockTouchpad;
 //   bool hasXboxPaddles;
 //   bool hasXboxShareButton;
+//   bool isMicroGamepad;
+//   bool isVirtual;
+//   int8_t playerIndex;
 // };
 //
+// enum VirtualElement {
+//   kVirtualElementLeftThumbstick  = 1 << 0,
+//   kVirtualElementRightThumbstick = 1 << 1,
+//   kVirtualElementDirectionPad    = 1 << 2,
+//   kVirtualElementButtonA         = 1 << 3,
+//   kVirtualElementButtonB         = 1 << 4,
+//   kVirtualElementButtonX         = 1 << 5,
+//   kVirtualElementButtonY         = 1 << 6,
+//   kVirtualElementLeftShoulder    = 1 << 7,
+//   kVirtualElementRightShoulder   = 1 << 8,
+//   kVirtualElementLeftTrigger     = 1 << 9,
+//   kVirtualElementRightTrigger    = 1 << 10,
+// };
+//
+// // virtualController is a GCVirtualController, which is available on iOS 15.0 or later.
+// // virtualController is accessed only on the main thread.
+// static id virtualController = nil;
+//
+// static bool isVirtualController(GCController* controller) {
+// #if !TARGET_OS_TV
+//   if (@available(iOS 15.0, *)) {
+//     return virtualController && [(GCVirtualController*)virtualController controller] == controller;
+//   }
+// #endif
+//   return false;
+// }
+//
+// static void releaseVirtualController(void) {
+// #if !TARGET_OS_TV
+//   if (!virtualController) {
+//     return;
+//   }
+//   if (@available(iOS 15.0, *)) {
+//     // Disconnecting removes the controller view from the window.
+//     [(GCVirtualController*)virtualController disconnect];
+//   }
+//   [virtualController release];
+//   virtualController = nil;
+// #endif
+// }
+//
+// static void connectVirtualController(uint32_t elements) {
+// #if !TARGET_OS_TV
+//   dispatch_async(dispatch_get_main_queue(), ^{
+//     releaseVirtualController();
+//     if (@available(iOS 15.0, *)) {
+//       @autoreleasepool {
+//         NSMutableSet<NSString*>* set = [NSMutableSet set];
+//         if (elements & kVirtualElementLeftThumbstick) {
+//           [set addObject:GCInputLeftThumbstick];
+//         }
+//         if (elements & kVirtualElementRightThumbstick) {
+//           [set addObject:GCInputRightThumbstick];
+//         }
+//         if (elements & kVirtualElementDirectionPad) {
+//           [set addObject:GCInputDirectionPad];
+//         }
+//         if (elements & kVirtualElementButtonA) {
+//           [set addObject:GCInputButtonA];
+//         }
+//         if (elements & kVirtualElementButtonB) {
+//           [set addObject:GCInputButtonB];
+//         }
+//         if (elements & kVirtualElementButtonX) {
+//           [set addObject:GCInputButtonX];
+//         }
+//         if (elements & kVirtualElementButtonY) {
+//           [set addObject:GCInputButtonY];
+//         }
+//         if (elements & kVirtualElementLeftShoulder) {
+//           [set addObject:GCInputLeftShoulder];
+//         }
+//         if (elements & kVirtualElementRightShoulder) {
+//           [set addObject:GCInputRightShoulder];
+//         }
+//         if (elements & kVirtualElementLeftTrigger) {
+//           [set addObject:GCInputLeftTrigger];
+//         }
+//         if (elements & kVirtualElementRightTrigger) {
+//           [set addObject:GCInputRightTrigger];
+//         }
+//
+//         GCVirtualControllerConfiguration* configuration = [[GCVirtualControllerConfiguration alloc] init];
+//         configuration.elements = set;
+//         virtualController = [[GCVirtualController alloc] initWithConfiguration:configuration];
+//         [configuration release];
+//
+//         // The controller is notified via GCControllerDidConnectNotification like a physical controller.
+//         [(GCVirtualController*)virtualController connectWithReplyHandler:nil];
+//       }
+//     }
+//   });
+// #endif
+// }
+//
+// static void disconnectVirtualController(void) {
+// #if !TARGET_OS_TV
+//   dispatch_async(dispatch_get_main_queue(), ^{
+//     releaseVirtualController();
+//   });
+// #endif
+// }
+//
+// // EbitenHapticsEngineState is the state of a haptic engine shared with the engine's handlers.
+// // The handlers are called on an arbitrary queue, even while the Haptics is being released.
+// // The handlers retain this object so that the state outlives them.
+// @interface EbitenHapticsEngineState : NSObject {
+// @public
+//   // needsRestart is set when the system stops or resets the engine, e.g., when the application goes to background
+//   // or an audio session is interrupted by a phone call.
+//   atomic_bool needsRestart;
+// }
+// @end
+//
+// @implementation EbitenHapticsEngineState
+// @end
+//
+// // Haptics plays a continuous haptic event on the default locality of a controller.
+// // The engine and the player are created lazily and are reused, as creating an engine is expensive.
+// struct Haptics {
+//   id haptics; // GCDeviceHaptics
+//   id engine;  // CHHapticEngine
+//   id player;  // id<CHHapticAdvancedPatternPlayer>
+//   bool playing;
+//   EbitenHapticsEngineState* state;
+// };
+//
+// static uintptr_t newHaptics(uintptr_t controller_ptr) {
+//   if (@available(iOS 14.0, tvOS 14.0, *)) {
+//     GCController* controller = (GCController*)(controller_ptr);
+//     // haptics is nil if the controller doesn't support haptics.
+//     GCDeviceHaptics* haptics = controller.haptics;
+//     if (!haptics) {
+//       return 0;
+//     }
+//     struct Haptics* h = calloc(1, sizeof(struct Haptics));
+//     h->haptics = [haptics retain];
+//     h->state = [[EbitenHapticsEngineState alloc] init];
+//     return (uintptr_t)h;
+//   }
+//   return 0;
+// }
+//
+// #pragma clang diagnostic push
+// #pragma clang diagnostic ignored "-Wunguarded-availability-new"
+//
+// static void releaseHapticsPlayer(struct Haptics* h) {
+//   if (!h->player) {
+//     return;
+//   }
+//   [h->player release];
+//   h->player = nil;
+//   h->playing = false;
+// }
+//
+// static bool startHapticsEngine(struct Haptics* h) {
+//   if (!h->engine) {
+//     CHHapticEngine* engine = [(GCDeviceHaptics*)h->haptics createEngineWithLocality:GCHapticsLocalityDefault];
+//     if (!engine) {
+//       return false;
+//     }
+//     engine.playsHapticsOnly = YES;
+//     // The players are invalidated when the engine is stopped or reset. Mark the engine to be restarted lazily.
+//     // The handlers capture only the state, which they retain, as h might be freed while a handler is running.
+//     EbitenHapticsEngineState* state = h->state;
+//     engine.stoppedHandler = ^(CHHapticEngineStoppedReason reason) {
+//       atomic_store(&state->needsRestart, true);
+//     };
+//     engine.resetHandler = ^{
+//       atomic_store(&state->needsRestart, true);
+//     };
+//     h->engine = [engine retain];
+//   } else if (!atomic_exchange(&h->state->needsRestart, false)) {
+//     return true;
+//   } else {
+//     releaseHapticsPlayer(h);
+//   }
+//
+//   if (![(CHHapticEngine*)h->engine startAndReturnError:nil]) {
+//     // Try again at the next call.
+//     atomic_store(&h->state->needsRestart, true);
+//     return false;
+//   }
+//   return true;
+// }
+//
+// static bool playHapticsOnce(struct Haptics* h, float intensity, float sharpness) {
+//   if (!startHapticsEngine(h)) {
+//     return false;
+//   }
+//
+//   if (!h->player) {
+//     // Create a player of a continuous event with the full intensity and the zero sharpness.
+//     // The actual intensity and sharpness are given as the dynamic parameters.
+//     CHHapticEventParameter* intensityParam = [[[CHHapticEventParameter alloc] initWithParameterID:CHHapticEventParameterIDHapticIntensity value:1] autorelease];
+//     CHHapticEventParameter* sharpnessParam = [[[CHHapticEventParameter alloc] initWithParameterID:CHHapticEventParameterIDHapticSharpness value:0] autorelease];
+//     CHHapticEvent* event = [[[CHHapticEvent alloc] initWithEventType:CHHapticEventTypeHapticContinuous
+//                                                           parameters:@[intensityParam, sharpnessParam]
+//                                                         relativeTime:0
+//                                                             duration:GCHapticDurationInfinite] autorelease];
+//     CHHapticPattern* pattern = [[[CHHapticPattern alloc] initWithEvents:@[event] parameters:@[] error:nil] autorelease];
+//     if (!pattern) {
+//       return false;
+//     }
+//     id<CHHapticAdvancedPatternPlayer> player = [(CHHapticEngine*)h->engine createAdvancedPlayerWithPattern:pattern error:nil];
+//     if (!player) {
+//       return false;
+//     }
+//     h->player = [player retain];
+//   }
+//
+//   // Update the running event in place with the dynamic parameters.
+//   CHHapticDynamicParameter* intensityParam = [[[CHHapticDynamicParameter alloc] initWithParameterID:CHHapticDynamicParameterIDHapticIntensityControl value:intensity relativeTime:0] autorelease];
+//   CHHapticDynamicParameter* sharpnessParam = [[[CHHapticDynamicParameter alloc] initWithParameterID:CHHapticDynamicParameterIDHapticSharpnessControl value:sharpness relativeTime:0] autorelease];
+//   if (![(id<CHHapticAdvancedPatternPlayer>)h->player sendParameters:@[intensityParam, sharpnessParam] atTime:0 error:nil]) {
+//     return false;
+//   }
+//
+//   if (!h->playing) {
+//     if (![(id<CHHapticAdvancedPatternPlayer>)h->player startAtTime:0 error:nil]) {
+//       return false;
+//     }
+//     h->playing = true;
+//   }
+//   return true;
+// }
+//
+// static bool playHaptics(uintptr_t haptics_ptr, float intensity, float sharpness) {
+//   struct Haptics* h = (struct Haptics*)(haptics_ptr);
+//   @autoreleasepool {
+//     if (playHapticsOnce(h, intensity, sharpness)) {
+//       return true;
+//     }
+//     // The engine might be stopped by the system before the handlers are called.
+//     // Restart the engine with a new player and retry once.
+//     if (h->engine) {
+//       atomic_store(&h->state->needsRestart, true);
+//     }
+//     return playHapticsOnce(h, intensity, sharpness);
+//   }
+// }
+//
+// static bool stopHaptics(uintptr_t haptics_ptr) {
+//   struct Haptics* h = (struct Haptics*)(haptics_ptr);
+//   if (!h->player || !h->playing) {
+//     return true;
+//   }
+//   h->playing = false;
+//   // The player is already stopped if the engine is stopped.
+//   if (atomic_load(&h->state->needsRestart)) {
+//     return true;
+//   }
+//   return [(id<CHHapticAdvancedPatternPlayer>)h->player stopAtTime:0 error:nil];
+// }
+//
+// static void releaseHaptics(uintptr_t haptics_ptr) {
+//   struct Haptics* h = (struct Haptics*)(haptics_ptr);
+//   releaseHapticsPlayer(h);
+//   if (h->engine) {
+//     [(CHHapticEngine*)h->engine stopWithCompletionHandler:nil];
+//     [h->engine release];
+//   }
+//   [h->haptics release];
+//   // The engine's handlers might still refer to the state, which they retain.
+//   [h->state release];
+//   free(h);
+// }
+//
+// #pragma clang diagnostic pop
+//
 // void ebitenAddGamepad(uintptr_t controller, struct ControllerProperty* prop);
 // void ebitenRemoveGamepad(uintptr_t controller);
 //
@@ -106,6 +381,8 @@ package gamepad
 //       name = "MFi Gamepad";
 //     }
 //     memcpy(property->name, name, min(sizeof(property->name), strlen(name)));
+//     property->isVirtual = isVirtualController(controller);
+//     property->playerIndex = (int8_t)(controller.playerIndex);
 //
 //     if (controller.extendedGamepad) {
 //       GCExtendedGamepad* gamepad = controller.extendedGamepad;
@@ -231,11 +508,37 @@ package gamepad
 //
 //       property->nAxes = 6;
 //       property->nHats = 1;
+//     } else if (controller.microGamepad) {
+//       // The micro gamepad profile is for devices like Siri Remote.
+//       GCMicroGamepad* gamepad = controller.microGamepad;
+//       property->isMicroGamepad = true;
+//
+//       property->buttonMask |= (1 << kControllerButtonA);
+//       property->buttonMask |= (1 << kControllerButtonB);
+//       property->nButtons += 2;
+//
+// #pragma clang diagnostic push
+// #pragma clang diagnostic ignored "-Wunguarded-availability-new"
+//
+//       if ([gamepad respondsToSelector:@selector(buttonMenu)] && gamepad.buttonMenu) {
+//         property->buttonMask |= (1 << kControllerButtonStart);
+//         property->nButtons++;
+//       }
+//
+// #pragma clang diagnostic pop
+//
+//       vendor = kUSBVendorApple;
+//       product = 1;
+//       subtype = 2;
+//
+//       property->nAxes = 2;
+//       property->nHats = 0;
 //     }
 //
 //     const int kSDLHardwareBusBluetooth = 0x05;
 //     property->guid[0] = (uint8_t)(kSDLHardwareBusBluetooth);
 //     property->guid[1] = (uint8_t)(kSDLHardwareBusBluetooth >> 8);
+//     // The CRC of the name at the bytes 2 and 3 is set on the Go side.
 //     property->guid[2] = 0;
 //     property->guid[3] = 0;
 //     property->guid[4] = (uint8_t)(vendor);
@@ -258,8 +561,9 @@ package gamepad
 // }
 //
 // static void addController(GCController* controller) {
-//   // Ignore if the controller is not an actual controller.
-//   if (!controller.extendedGamepad && controller.microGamepad) {
+//   // Ignore if the controller doesn't have a gamepad profile, like a keyboard.
+//   // A controller with only the micro gamepad profile is filtered out on the Go side unless it is enabled.
+//   if (!controller.extendedGamepad && !controller.microGamepad) {
 //     return;
 //   }
 //
@@ -269,9 +573,20 @@ package gamepad
 // }
 //
 // static void removeController(GCController* controller) {
+//   controller.playerIndex = GCControllerPlayerIndexUnset;
 //   ebitenRemoveGamepad((uintptr_t)(controller));
 // }
 //
+// static void setControllerPlayerIndex(uintptr_t controller_ptr, int index) {
+//   GCController* controller = (GCController*)(controller_ptr);
+//   // GCControllerPlayerIndex supports only four players.
+//   if (index < 0 || index > 3) {
+//     controller.playerIndex = GCControllerPlayerIndexUnset;
+//     return;
+//   }
+//   controller.playerIndex = (GCControllerPlayerIndex)(index);
+// }
+//
 // struct ControllerState {
 //   uint8_t buttons[32];
 //   float axes[32];
@@ -361,6 +676,24 @@ package gamepad
 //       if (nHats) {
 //         controllerState->hat = getHatState(gamepad.dpad);
 //       }
+//     } else if (controller.microGamepad) {
+//       GCMicroGamepad* gamepad = controller.microGamepad;
+//
+//       controllerState->axes[0] = gamepad.dpad.xAxis.value;
+//       controllerState->axes[1] = -gamepad.dpad.yAxis.value;
+//
+//       int buttonCount = 0;
+//       controllerState->buttons[buttonCount++] = gamepad.buttonA.isPressed;
+//       controllerState->buttons[buttonCount++] = gamepad.buttonX.isPressed;
+//
+// #pragma clang diagnostic push
+// #pragma clang diagnostic ignored "-Wunguarded-availability-new"
+//
+//       if (buttonMask & (1 << kControllerButtonStart)) {
+//         controllerState->buttons[buttonCount++] = gamepad.buttonMenu.isPressed;
+//       }
+//
+// #pragma clang diagnostic pop
 //     }
 //   }
 // }
@@ -388,7 +721,9 @@ package gamepad
 import "C"
 
 import (
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"unsafe"
 )
 
@@ -406,10 +741,18 @@ func (g *gamepads) addIOSGamepad(controller C.uintptr_t, prop *C.struct_Controll
 	g.m.Lock()
 	defer g.m.Unlock()
 
+	// Devices with only the micro gamepad profile, like Siri Remote, are not treated as gamepads by default,
+	// as they have only a few buttons and a touch surface.
+	if bool(prop.isMicroGamepad) && !g.microGamepadEnabled {
+		return
+	}
+
 	name := C.GoString(&prop.name[0])
-	sdlID := hex.EncodeToString(C.GoBytes(unsafe.Pointer(&prop.guid[0]), 16))
+	guid := C.GoBytes(unsafe.Pointer(&prop.guid[0]), 16)
+	binary.LittleEndian.PutUint16(guid[2:4], sdlCRC16(name))
+	sdlID := hex.EncodeToString(guid)
 	gp := g.add(name, sdlID)
-	gp.native = &nativeGamepadImpl{
+	n := &nativeGamepadImpl{
 		controller:           uintptr(controller),
 		axes:                 make([]float64, prop.nAxes),
 		buttons:              make([]bool, prop.nButtons+prop.nHats*4),
@@ -418,6 +761,16 @@ func (g *gamepads) addIOSGamepad(controller C.uintptr_t, prop *C.struct_Controll
 		hasDualshockTouchpad: bool(prop.hasDualshockTouchpad),
 		hasXboxPaddles:       bool(prop.hasXboxPaddles),
 		hasXboxShareButton:   bool(prop.hasXboxShareButton),
+		isVirtual:            bool(prop.isVirtual),
+	}
+	if n.haptics = uintptr(C.newHaptics(controller)); n.haptics != 0 {
+		n.rumble.setMotorSpeeds = n.setIOSMotorSpeeds
+	}
+	gp.native = n
+
+	// Keep the player index the system remembers for the controller, e.g., when the controller is reconnected.
+	if index := int(prop.playerIndex); index >= 0 && index <= 3 {
+		gp.restorePlayerIndex(index)
 	}
 }
 
@@ -426,7 +779,12 @@ func (g *gamepads) removeIOSGamepad(controller C.uintptr_t) {
 	defer g.m.Unlock()
 
 	g.remove(func(gamepad *Gamepad) bool {
-		return gamepad.native.(*nativeGamepadImpl).controller == uintptr(controller)
+		n, ok := gamepad.native.(*nativeGamepadImpl)
+		if !ok || n.controller != uintptr(controller) {
+			return false
+		}
+		n.releaseIOSHaptics()
+		return true
 	})
 }
 
@@ -434,6 +792,52 @@ func initializeIOSGamepads() {
 	C.initializeGamepads()
 }
 
+func connectIOSVirtualGamepad(elements VirtualGamepadElement) {
+	C.connectVirtualController(C.uint32_t(elements))
+}
+
+func disconnectIOSVirtualGamepad() {
+	C.disconnectVirtualController()
+}
+
+func (g *nativeGamepadImpl) setIOSPlayerIndex(index int) {
+	C.setControllerPlayerIndex(C.uintptr_t(g.controller), C.int(index))
+}
+
+// setIOSMotorSpeeds starts or updates the haptic event. This is used as rumble's setMotorSpeeds.
+//
+// The strong (low-frequency) and the weak (high-frequency) magnitudes are blended into one event:
+// the intensity is the larger magnitude, and the sharpness is the weak magnitude's proportion.
+func (g *nativeGamepadImpl) setIOSMotorSpeeds(low, high uint16) error {
+	if low == 0 && high == 0 {
+		if !C.stopHaptics(C.uintptr_t(g.haptics)) {
+			return errors.New("gamepad: stopping the haptics failed")
+		}
+		return nil
+	}
+
+	intensity := float32(low) / 0xffff
+	if v := float32(high) / 0xffff; intensity < v {
+		intensity = v
+	}
+	sharpness := float32(high) / (float32(low) + float32(high))
+
+	// If the engine was stopped by the system, e.g., by an app switch or a phone call, the engine is restarted here.
+	if !C.playHaptics(C.uintptr_t(g.haptics), C.float(intensity), C.float(sharpness)) {
+		return errors.New("gamepad: playing the haptics failed")
+	}
+	return nil
+}
+
+func (g *nativeGamepadImpl) releaseIOSHaptics() {
+	if g.haptics == 0 {
+		return
+	}
+	_ = g.rumble.stop()
+	C.releaseHaptics(C.uintptr_t(g.haptics))
+	g.haptics = 0
+}
+
 func (g *nativeGamepadImpl) updateIOSGamepad() {
 	var state C.struct_ControllerState
 	C.getControllerState(C.uintptr_t(g.controller), &state, C.uint16_t(g.buttonMask), C.uint8_t(len(g.hats)),
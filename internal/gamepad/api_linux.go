@@ -23,30 +23,59 @@ import (
 )
 
 const (
-	_ABS_X     = 0x00
-	_ABS_Y     = 0x01
-	_ABS_Z     = 0x02
-	_ABS_RX    = 0x03
-	_ABS_RY    = 0x04
-	_ABS_RZ    = 0x05
-	_ABS_HAT0X = 0x10
-	_ABS_HAT0Y = 0x11
-	_ABS_HAT1X = 0x12
-	_ABS_HAT1Y = 0x13
-	_ABS_HAT2X = 0x14
-	_ABS_HAT2Y = 0x15
-	_ABS_HAT3Y = 0x17
-	_ABS_MAX   = 0x3f
-	_ABS_CNT   = _ABS_MAX + 1
+	_ABS_X        = 0x00
+	_ABS_Y        = 0x01
+	_ABS_Z        = 0x02
+	_ABS_RX       = 0x03
+	_ABS_RY       = 0x04
+	_ABS_RZ       = 0x05
+	_ABS_THROTTLE = 0x06
+	_ABS_RUDDER   = 0x07
+	_ABS_WHEEL    = 0x08
+	_ABS_GAS      = 0x09
+	_ABS_BRAKE    = 0x0a
+	_ABS_HAT0X    = 0x10
+	_ABS_HAT0Y    = 0x11
+	_ABS_HAT1X    = 0x12
+	_ABS_HAT1Y    = 0x13
+	_ABS_HAT2X    = 0x14
+	_ABS_HAT2Y    = 0x15
+	_ABS_HAT3X    = 0x16
+	_ABS_HAT3Y    = 0x17
+
+	_ABS_MT_SLOT        = 0x2f
+	_ABS_MT_POSITION_X  = 0x35
+	_ABS_MT_POSITION_Y  = 0x36
+	_ABS_MT_TRACKING_ID = 0x39
+
+	_ABS_MAX = 0x3f
+	_ABS_CNT = _ABS_MAX + 1
 
 	_BTN_MISC       = 0x100
+	_BTN_0          = 0x100
+	_BTN_9          = 0x109
+	_BTN_LEFT       = 0x110
+	_BTN_JOYSTICK   = 0x120
+	_BTN_TRIGGER    = 0x120
+	_BTN_THUMB      = 0x121
+	_BTN_THUMB2     = 0x122
+	_BTN_TOP        = 0x123
+	_BTN_TOP2       = 0x124
+	_BTN_PINKIE     = 0x125
+	_BTN_BASE       = 0x126
+	_BTN_BASE2      = 0x127
+	_BTN_BASE6      = 0x12b
 	_BTN_GAMEPAD    = 0x130
+	_BTN_SOUTH      = 0x130
 	_BTN_A          = 0x130
+	_BTN_EAST       = 0x131
 	_BTN_B          = 0x131
+	_BTN_C          = 0x132
 	_BTN_NORTH      = 0x133
 	_BTN_X          = 0x133
 	_BTN_WEST       = 0x134
 	_BTN_Y          = 0x134
+	_BTN_Z          = 0x135
 	_BTN_TL         = 0x136
 	_BTN_TR         = 0x137
 	_BTN_TL2        = 0x138
@@ -56,11 +85,36 @@ const (
 	_BTN_MODE       = 0x13c
 	_BTN_THUMBL     = 0x13d
 	_BTN_THUMBR     = 0x13e
+	_BTN_DIGI       = 0x140
 	_BTN_DPAD_UP    = 0x220
 	_BTN_DPAD_DOWN  = 0x221
 	_BTN_DPAD_LEFT  = 0x222
 	_BTN_DPAD_RIGHT = 0x223
 
+	_BTN_TRIGGER_HAPPY1  = 0x2c0
+	_BTN_TRIGGER_HAPPY2  = 0x2c1
+	_BTN_TRIGGER_HAPPY3  = 0x2c2
+	_BTN_TRIGGER_HAPPY4  = 0x2c3
+	_BTN_TRIGGER_HAPPY40 = 0x2e7
+
+	_BUS_USB       = 0x03
+	_BUS_BLUETOOTH = 0x05
+	_BUS_VIRTUAL   = 0x06
+
+	_FF_RUMBLE = 0x50
+	_FF_GAIN   = 0x60
+	_FF_MAX    = 0x7f
+	_FF_CNT    = _FF_MAX + 1
+
+	_INPUT_PROP_POINTER        = 0x00
+	_INPUT_PROP_BUTTONPAD      = 0x02
+	_INPUT_PROP_SEMI_MT        = 0x03
+	_INPUT_PROP_TOPBUTTONPAD   = 0x04
+	_INPUT_PROP_POINTING_STICK = 0x05
+	_INPUT_PROP_ACCELEROMETER  = 0x06
+	_INPUT_PROP_MAX            = 0x1f
+	_INPUT_PROP_CNT            = _INPUT_PROP_MAX + 1
+
 	_IOC_NONE  = 0
 	_IOC_WRITE = 1
 	_IOC_READ  = 2
@@ -75,6 +129,12 @@ const (
 	_IOC_SIZESHIFT = _IOC_TYPESHIFT + _IOC_TYPEBITS
 	_IOC_DIRSHIFT  = _IOC_SIZESHIFT + _IOC_SIZEBITS
 
+	_JS_EVENT_BUTTON = 0x01
+	_JS_EVENT_AXIS   = 0x02
+	_JS_EVENT_INIT   = 0x80
+
+	_KEY_ESC = 1
+	_KEY_S   = 31
 	_KEY_MAX = 0x2ff
 	_KEY_CNT = _KEY_MAX + 1
 
@@ -90,6 +150,10 @@ func _IOR(typ, nr, size uint) uint {
 	return _IOC(_IOC_READ, typ, nr, size)
 }
 
+func _IOW(typ, nr, size uint) uint {
+	return _IOC(_IOC_WRITE, typ, nr, size)
+}
+
 func _EVIOCGABS(abs uint) uint {
 	return _IOR('E', 0x40+abs, uint(unsafe.Sizeof(input_absinfo{})))
 }
@@ -98,14 +162,111 @@ func _EVIOCGBIT(ev, len uint) uint {
 	return _IOC(_IOC_READ, 'E', 0x20+ev, len)
 }
 
+func _EVIOCGRAB() uint {
+	return _IOW('E', 0x90, uint(unsafe.Sizeof(int32(0))))
+}
+
 func _EVIOCGID() uint {
 	return _IOR('E', 0x02, uint(unsafe.Sizeof(input_id{})))
 }
 
+func _EVIOCGKEY(len uint) uint {
+	return _IOC(_IOC_READ, 'E', 0x18, len)
+}
+
+func _EVIOCGMTSLOTS(len uint) uint {
+	return _IOC(_IOC_READ, 'E', 0x0a, len)
+}
+
 func _EVIOCGNAME(len uint) uint {
 	return _IOC(_IOC_READ, 'E', 0x06, len)
 }
 
+func _EVIOCGPHYS(len uint) uint {
+	return _IOC(_IOC_READ, 'E', 0x07, len)
+}
+
+func _EVIOCGPROP(len uint) uint {
+	return _IOC(_IOC_READ, 'E', 0x09, len)
+}
+
+func _EVIOCGUNIQ(len uint) uint {
+	return _IOC(_IOC_READ, 'E', 0x08, len)
+}
+
+func _EVIOCRMFF() uint {
+	return _IOW('E', 0x81, uint(unsafe.Sizeof(int32(0))))
+}
+
+func _EVIOCSFF() uint {
+	return _IOW('E', 0x80, uint(unsafe.Sizeof(ff_effect{})))
+}
+
+func _JSIOCGAXES() uint {
+	return _IOR('j', 0x11, uint(unsafe.Sizeof(uint8(0))))
+}
+
+func _JSIOCGAXMAP() uint {
+	return _IOR('j', 0x32, _ABS_CNT)
+}
+
+func _JSIOCGBTNMAP() uint {
+	return _IOR('j', 0x34, uint(unsafe.Sizeof(uint16(0)))*(_KEY_MAX-_BTN_MISC+1))
+}
+
+func _JSIOCGBUTTONS() uint {
+	return _IOR('j', 0x12, uint(unsafe.Sizeof(uint8(0))))
+}
+
+func _JSIOCGNAME(len uint) uint {
+	return _IOC(_IOC_READ, 'j', 0x13, len)
+}
+
+type ff_envelope struct {
+	attack_length uint16
+	attack_level  uint16
+	fade_length   uint16
+	fade_level    uint16
+}
+
+type ff_effect struct {
+	typ       uint16
+	id        int16
+	direction uint16
+	trigger   ff_trigger
+	replay    ff_replay
+
+	// u is a union of the effect parameters.
+	// ff_periodic_effect is the largest member and has the strictest alignment.
+	u ff_periodic_effect
+}
+
+type ff_periodic_effect struct {
+	waveform    uint16
+	period      uint16
+	magnitude   int16
+	offset      int16
+	phase       uint16
+	envelope    ff_envelope
+	custom_len  uint32
+	custom_data unsafe.Pointer
+}
+
+type ff_replay struct {
+	length uint16
+	delay  uint16
+}
+
+type ff_rumble_effect struct {
+	strong_magnitude uint16
+	weak_magnitude   uint16
+}
+
+type ff_trigger struct {
+	button   uint16
+	interval uint16
+}
+
 type input_absinfo struct {
 	value      int32
 	minimum    int32
@@ -122,6 +283,13 @@ type input_event struct {
 	value int32
 }
 
+type js_event struct {
+	time   uint32
+	value  int16
+	typ    uint8
+	number uint8
+}
+
 type input_id struct {
 	bustype uint16
 	vendor  uint16
@@ -130,9 +298,8 @@ type input_id struct {
 }
 
 func ioctl(fd int, request uint, ptr unsafe.Pointer) error {
-	r, _, e := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(request), uintptr(ptr))
-	if r < 0 {
-		return unix.Errno(e)
+	if _, _, e := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(request), uintptr(ptr)); e != 0 {
+		return e
 	}
 	return nil
 }
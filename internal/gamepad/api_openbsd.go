@@ -0,0 +1,127 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nintendosdk && !playstation5
+
+package gamepad
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	_IOC_OUT   = 0x40000000
+	_IOC_IN    = 0x80000000
+	_IOC_INOUT = _IOC_IN | _IOC_OUT
+
+	_IOCPARM_MASK = 0x1fff
+
+	_USB_MAX_STRING_LEN       = 127
+	_USB_MAX_DEVNAMES         = 4
+	_USB_MAX_DEVNAMELEN       = 16
+	_USB_MAX_REPORT_DESC_SIZE = 1024
+)
+
+func _IOC(inout, group, num, len uint) uint {
+	return inout | (len&_IOCPARM_MASK)<<16 | group<<8 | num
+}
+
+func _IOR(group, num, len uint) uint {
+	return _IOC(_IOC_OUT, group, num, len)
+}
+
+func _USB_GET_REPORT_DESC() uint {
+	return _IOR('U', 21, uint(unsafe.Sizeof(usb_ctl_report_desc{})))
+}
+
+func _USB_GET_REPORT_ID() uint {
+	return _IOR('U', 25, uint(unsafe.Sizeof(int32(0))))
+}
+
+func _USB_GET_DEVICEINFO() uint {
+	return _IOR('U', 112, uint(unsafe.Sizeof(usb_device_info{})))
+}
+
+type usb_ctl_report_desc struct {
+	ucrd_size int32
+	ucrd_data [_USB_MAX_REPORT_DESC_SIZE]byte
+}
+
+type usb_device_info struct {
+	udi_bus       uint8
+	udi_addr      uint8
+	udi_cookie    uint32
+	udi_product   [_USB_MAX_STRING_LEN]byte
+	udi_vendor    [_USB_MAX_STRING_LEN]byte
+	udi_release   [8]byte
+	udi_productNo uint16
+	udi_vendorNo  uint16
+	udi_releaseNo uint16
+	udi_class     uint8
+	udi_subclass  uint8
+	udi_protocol  uint8
+	udi_config    uint8
+	udi_speed     uint8
+	udi_power     int32
+	udi_nports    int32
+	udi_devnames  [_USB_MAX_DEVNAMES][_USB_MAX_DEVNAMELEN]byte
+	udi_ports     [8]uint32
+	udi_serial    [_USB_MAX_STRING_LEN]byte
+}
+
+func ioctl(fd int, request uint, ptr unsafe.Pointer) error {
+	if _, _, e := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(request), uintptr(ptr)); e != 0 {
+		return e
+	}
+	return nil
+}
+
+// usbReportDescriptor returns the report descriptor of the uhid device.
+func usbReportDescriptor(fd int) ([]byte, error) {
+	var desc usb_ctl_report_desc
+	if err := ioctl(fd, _USB_GET_REPORT_DESC(), unsafe.Pointer(&desc)); err != nil {
+		return nil, err
+	}
+	size := int(desc.ucrd_size)
+	if size < 0 || size > len(desc.ucrd_data) {
+		size = len(desc.ucrd_data)
+	}
+	return desc.ucrd_data[:size], nil
+}
+
+// usbReportID returns the ID of the input reports read from the uhid device, or 0 if the device doesn't use report IDs.
+func usbReportID(fd int) (uint8, error) {
+	var id int32
+	if err := ioctl(fd, _USB_GET_REPORT_ID(), unsafe.Pointer(&id)); err != nil {
+		return 0, err
+	}
+	return uint8(id), nil
+}
+
+// usbDeviceInfo returns the information of the USB device of the uhid device.
+func usbDeviceInfo(fd int) (usbDevice, error) {
+	var info usb_device_info
+	if err := ioctl(fd, _USB_GET_DEVICEINFO(), unsafe.Pointer(&info)); err != nil {
+		return usbDevice{}, err
+	}
+	return usbDevice{
+		vendor:  info.udi_vendorNo,
+		product: info.udi_productNo,
+		release: info.udi_releaseNo,
+		name:    unix.ByteSliceToString(info.udi_product[:]),
+		serial:  unix.ByteSliceToString(info.udi_serial[:]),
+	}, nil
+}
@@ -0,0 +1,558 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Windows.Gaming.Input (WGI) APIs. WGI is available on Windows 10 or later.
+
+var (
+	combase = windows.NewLazySystemDLL("combase.dll")
+
+	procRoGetActivationFactory = combase.NewProc("RoGetActivationFactory")
+	procWindowsCreateString    = combase.NewProc("WindowsCreateString")
+	procWindowsDeleteString    = combase.NewProc("WindowsDeleteString")
+
+	procWindowsGetStringRawBuffer = combase.NewProc("WindowsGetStringRawBuffer")
+)
+
+var (
+	_IID_IGameController            = windows.GUID{Data1: 0x1baf6522, Data2: 0x5f64, Data3: 0x42c5, Data4: [...]byte{0x82, 0x67, 0xb9, 0xfe, 0x22, 0x15, 0xbf, 0xbd}}
+	_IID_IGameControllerBatteryInfo = windows.GUID{Data1: 0xdcecc681, Data2: 0x3963, Data3: 0x4da6, Data4: [...]byte{0x95, 0x5d, 0x55, 0x3f, 0x3b, 0x6f, 0x61, 0x61}}
+	_IID_IGamepadStatics            = windows.GUID{Data1: 0x8bbce529, Data2: 0xd49c, Data3: 0x39e9, Data4: [...]byte{0x95, 0x60, 0xe4, 0x7d, 0xde, 0x96, 0xb7, 0xc8}}
+	_IID_IRawGameController2        = windows.GUID{Data1: 0x43c0c035, Data2: 0xbb73, Data3: 0x4756, Data4: [...]byte{0xa7, 0x87, 0x3e, 0xd6, 0xbe, 0xa6, 0x17, 0xbd}}
+	_IID_IRawGameControllerStatics  = windows.GUID{Data1: 0xeb8d0792, Data2: 0xe95a, Data3: 0x4b19, Data4: [...]byte{0xaf, 0xc7, 0x0a, 0x6e, 0x2e, 0x7e, 0x4d, 0x40}}
+)
+
+type _GamepadButtons uint32
+
+const (
+	_GamepadButtonsNone            _GamepadButtons = 0x00000000
+	_GamepadButtonsMenu            _GamepadButtons = 0x00000001
+	_GamepadButtonsView            _GamepadButtons = 0x00000002
+	_GamepadButtonsA               _GamepadButtons = 0x00000004
+	_GamepadButtonsB               _GamepadButtons = 0x00000008
+	_GamepadButtonsX               _GamepadButtons = 0x00000010
+	_GamepadButtonsY               _GamepadButtons = 0x00000020
+	_GamepadButtonsDPadUp          _GamepadButtons = 0x00000040
+	_GamepadButtonsDPadDown        _GamepadButtons = 0x00000080
+	_GamepadButtonsDPadLeft        _GamepadButtons = 0x00000100
+	_GamepadButtonsDPadRight       _GamepadButtons = 0x00000200
+	_GamepadButtonsLeftShoulder    _GamepadButtons = 0x00000400
+	_GamepadButtonsRightShoulder   _GamepadButtons = 0x00000800
+	_GamepadButtonsLeftThumbstick  _GamepadButtons = 0x00001000
+	_GamepadButtonsRightThumbstick _GamepadButtons = 0x00002000
+)
+
+type _BatteryStatus int32
+
+const (
+	_BatteryStatusNotPresent  _BatteryStatus = 0
+	_BatteryStatusDischarging _BatteryStatus = 1
+	_BatteryStatusIdle        _BatteryStatus = 2
+	_BatteryStatusCharging    _BatteryStatus = 3
+)
+
+type _HSTRING uintptr
+
+type _GamepadReading struct {
+	Timestamp        uint64
+	Buttons          _GamepadButtons
+	_                uint32 // Padding. A double is 8-byte aligned even on 32-bit Windows.
+	LeftTrigger      float64
+	RightTrigger     float64
+	LeftThumbstickX  float64
+	LeftThumbstickY  float64
+	RightThumbstickX float64
+	RightThumbstickY float64
+}
+
+type _GamepadVibration struct {
+	LeftMotor    float64
+	RightMotor   float64
+	LeftTrigger  float64
+	RightTrigger float64
+}
+
+func isWGIAvailable() bool {
+	return procRoGetActivationFactory.Find() == nil && procWindowsCreateString.Find() == nil && procWindowsDeleteString.Find() == nil
+}
+
+func _WindowsCreateString(str string) (_HSTRING, error) {
+	s, err := windows.UTF16FromString(str)
+	if err != nil {
+		return 0, err
+	}
+	var h _HSTRING
+	// The length doesn't include the null terminator.
+	r, _, _ := procWindowsCreateString.Call(uintptr(unsafe.Pointer(&s[0])), uintptr(len(s)-1), uintptr(unsafe.Pointer(&h)))
+	runtime.KeepAlive(s)
+	if uint32(r) != uint32(windows.S_OK) {
+		return 0, fmt.Errorf("gamepad: WindowsCreateString failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return h, nil
+}
+
+func _WindowsDeleteString(str _HSTRING) {
+	_, _, _ = procWindowsDeleteString.Call(uintptr(str))
+}
+
+func _WindowsGetStringRawBuffer(str _HSTRING) string {
+	if procWindowsGetStringRawBuffer.Find() != nil {
+		return ""
+	}
+	var length uint32
+	r, _, _ := procWindowsGetStringRawBuffer.Call(uintptr(str), uintptr(unsafe.Pointer(&length)))
+	if r == 0 || length == 0 {
+		return ""
+	}
+	// Reinterpret the returned address as a pointer. The buffer is owned by the HSTRING.
+	p := *(**uint16)(unsafe.Pointer(&r))
+	return windows.UTF16ToString(unsafe.Slice(p, length))
+}
+
+func _RoGetActivationFactory(activatableClassId string, iid *windows.GUID, factory unsafe.Pointer) error {
+	h, err := _WindowsCreateString(activatableClassId)
+	if err != nil {
+		return err
+	}
+	defer _WindowsDeleteString(h)
+
+	r, _, _ := procRoGetActivationFactory.Call(uintptr(h), uintptr(unsafe.Pointer(iid)), uintptr(factory))
+	if uint32(r) != uint32(windows.S_OK) {
+		return fmt.Errorf("gamepad: RoGetActivationFactory failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return nil
+}
+
+type _IGamepadStatics struct {
+	vtbl *_IGamepadStatics_Vtbl
+}
+
+type _IGamepadStatics_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	add_GamepadAdded      uintptr
+	remove_GamepadAdded   uintptr
+	add_GamepadRemoved    uintptr
+	remove_GamepadRemoved uintptr
+	get_Gamepads          uintptr
+}
+
+func (i *_IGamepadStatics) GetGamepads() (*_IVectorViewGamepad, error) {
+	var gamepads *_IVectorViewGamepad
+	r, _, _ := syscall.Syscall(i.vtbl.get_Gamepads, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&gamepads)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return nil, fmt.Errorf("gamepad: IGamepadStatics::get_Gamepads failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return gamepads, nil
+}
+
+// _IVectorViewGamepad is IVectorView<Gamepad>.
+type _IVectorViewGamepad struct {
+	vtbl *_IVectorViewGamepad_Vtbl
+}
+
+type _IVectorViewGamepad_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	GetAt    uintptr
+	get_Size uintptr
+	IndexOf  uintptr
+	GetMany  uintptr
+}
+
+func (i *_IVectorViewGamepad) GetAt(index uint32) (*_IGamepad, error) {
+	var gamepad *_IGamepad
+	r, _, _ := syscall.Syscall(i.vtbl.GetAt, 3, uintptr(unsafe.Pointer(i)), uintptr(index), uintptr(unsafe.Pointer(&gamepad)))
+	if uint32(r) != uint32(windows.S_OK) {
+		return nil, fmt.Errorf("gamepad: IVectorView<Gamepad>::GetAt failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return gamepad, nil
+}
+
+func (i *_IVectorViewGamepad) GetSize() (uint32, error) {
+	var size uint32
+	r, _, _ := syscall.Syscall(i.vtbl.get_Size, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&size)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return 0, fmt.Errorf("gamepad: IVectorView<Gamepad>::get_Size failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return size, nil
+}
+
+func (i *_IVectorViewGamepad) Release() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
+type _IGamepad struct {
+	vtbl *_IGamepad_Vtbl
+}
+
+type _IGamepad_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	get_Vibration     uintptr
+	put_Vibration     uintptr
+	GetCurrentReading uintptr
+}
+
+func (i *_IGamepad) QueryInterface(iid *windows.GUID, ppvObject unsafe.Pointer) error {
+	r, _, _ := syscall.Syscall(i.vtbl.QueryInterface, 3, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(iid)), uintptr(ppvObject))
+	if uint32(r) != uint32(windows.S_OK) {
+		return fmt.Errorf("gamepad: IGamepad::QueryInterface failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return nil
+}
+
+func (i *_IGamepad) GetCurrentReading() (_GamepadReading, error) {
+	var reading _GamepadReading
+	r, _, _ := syscall.Syscall(i.vtbl.GetCurrentReading, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&reading)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return _GamepadReading{}, fmt.Errorf("gamepad: IGamepad::GetCurrentReading failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return reading, nil
+}
+
+func (i *_IGamepad) PutVibration(vibration _GamepadVibration) error {
+	var r uintptr
+	switch runtime.GOARCH {
+	case "386":
+		// A struct is passed by value on the stack.
+		v := (*[8]uintptr)(unsafe.Pointer(&vibration))
+		r, _, _ = syscall.Syscall9(i.vtbl.put_Vibration, 9, uintptr(unsafe.Pointer(i)),
+			v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7])
+	case "amd64":
+		// A struct larger than 8 bytes is passed as a pointer to a copy.
+		r, _, _ = syscall.Syscall(i.vtbl.put_Vibration, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&vibration)), 0)
+	default:
+		// On arm64, a struct of four doubles is passed by floating-point registers, which syscall cannot do.
+		return errors.New("gamepad: IGamepad::put_Vibration is not supported on this architecture")
+	}
+	runtime.KeepAlive(vibration)
+	if uint32(r) != uint32(windows.S_OK) {
+		return fmt.Errorf("gamepad: IGamepad::put_Vibration failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return nil
+}
+
+func (i *_IGamepad) AddRef() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.AddRef, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
+func (i *_IGamepad) Release() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
+type _IGameController struct {
+	vtbl *_IGameController_Vtbl
+}
+
+type _IGameController_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	add_HeadsetConnected       uintptr
+	remove_HeadsetConnected    uintptr
+	add_HeadsetDisconnected    uintptr
+	remove_HeadsetDisconnected uintptr
+	add_UserChanged            uintptr
+	remove_UserChanged         uintptr
+	get_Headset                uintptr
+	get_IsWireless             uintptr
+	get_User                   uintptr
+}
+
+func (i *_IGameController) GetIsWireless() (bool, error) {
+	var wireless bool
+	r, _, _ := syscall.Syscall(i.vtbl.get_IsWireless, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&wireless)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return false, fmt.Errorf("gamepad: IGameController::get_IsWireless failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return wireless, nil
+}
+
+func (i *_IGameController) Release() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
+type _IGameControllerBatteryInfo struct {
+	vtbl *_IGameControllerBatteryInfo_Vtbl
+}
+
+type _IGameControllerBatteryInfo_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	TryGetBatteryReport uintptr
+}
+
+func (i *_IGameControllerBatteryInfo) TryGetBatteryReport() (*_IBatteryReport, error) {
+	var report *_IBatteryReport
+	r, _, _ := syscall.Syscall(i.vtbl.TryGetBatteryReport, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&report)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return nil, fmt.Errorf("gamepad: IGameControllerBatteryInfo::TryGetBatteryReport failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return report, nil
+}
+
+func (i *_IGameControllerBatteryInfo) Release() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
+type _IBatteryReport struct {
+	vtbl *_IBatteryReport_Vtbl
+}
+
+type _IBatteryReport_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	get_ChargeRateInMilliwatts             uintptr
+	get_DesignCapacityInMilliwattHours     uintptr
+	get_FullChargeCapacityInMilliwattHours uintptr
+	get_RemainingCapacityInMilliwattHours  uintptr
+	get_Status                             uintptr
+}
+
+// GetFullChargeCapacityInMilliwattHours returns the full charge capacity, or false if it is unknown.
+func (i *_IBatteryReport) GetFullChargeCapacityInMilliwattHours() (int32, bool) {
+	return i.getInt32Reference(i.vtbl.get_FullChargeCapacityInMilliwattHours)
+}
+
+// GetRemainingCapacityInMilliwattHours returns the remaining capacity, or false if it is unknown.
+func (i *_IBatteryReport) GetRemainingCapacityInMilliwattHours() (int32, bool) {
+	return i.getInt32Reference(i.vtbl.get_RemainingCapacityInMilliwattHours)
+}
+
+func (i *_IBatteryReport) getInt32Reference(method uintptr) (int32, bool) {
+	var ref *_IReferenceInt32
+	r, _, _ := syscall.Syscall(method, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&ref)), 0)
+	if uint32(r) != uint32(windows.S_OK) || ref == nil {
+		return 0, false
+	}
+	defer ref.Release()
+	return ref.GetValue()
+}
+
+func (i *_IBatteryReport) GetStatus() (_BatteryStatus, error) {
+	var status _BatteryStatus
+	r, _, _ := syscall.Syscall(i.vtbl.get_Status, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&status)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return 0, fmt.Errorf("gamepad: IBatteryReport::get_Status failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return status, nil
+}
+
+func (i *_IBatteryReport) Release() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
+// _IReferenceInt32 is IReference<int>.
+type _IReferenceInt32 struct {
+	vtbl *_IReferenceInt32_Vtbl
+}
+
+type _IReferenceInt32_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	get_Value uintptr
+}
+
+func (i *_IReferenceInt32) GetValue() (int32, bool) {
+	var v int32
+	r, _, _ := syscall.Syscall(i.vtbl.get_Value, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&v)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return 0, false
+	}
+	return v, true
+}
+
+func (i *_IReferenceInt32) Release() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
+type _IRawGameControllerStatics struct {
+	vtbl *_IRawGameControllerStatics_Vtbl
+}
+
+type _IRawGameControllerStatics_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	add_RawGameControllerAdded      uintptr
+	remove_RawGameControllerAdded   uintptr
+	add_RawGameControllerRemoved    uintptr
+	remove_RawGameControllerRemoved uintptr
+	get_RawGameControllers          uintptr
+	FromGameController              uintptr
+}
+
+func (i *_IRawGameControllerStatics) FromGameController(gameController *_IGameController) (*_IRawGameController, error) {
+	var raw *_IRawGameController
+	r, _, _ := syscall.Syscall(i.vtbl.FromGameController, 3, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(gameController)), uintptr(unsafe.Pointer(&raw)))
+	runtime.KeepAlive(gameController)
+	if uint32(r) != uint32(windows.S_OK) {
+		return nil, fmt.Errorf("gamepad: IRawGameControllerStatics::FromGameController failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return raw, nil
+}
+
+type _IRawGameController struct {
+	vtbl *_IRawGameController_Vtbl
+}
+
+type _IRawGameController_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	get_AxisCount           uintptr
+	get_ButtonCount         uintptr
+	get_ForceFeedbackMotors uintptr
+	get_HardwareProductId   uintptr
+	get_HardwareVendorId    uintptr
+	get_SwitchCount         uintptr
+	GetButtonLabel          uintptr
+	GetCurrentReading       uintptr
+	GetSwitchKind           uintptr
+}
+
+func (i *_IRawGameController) GetHardwareProductId() (uint16, error) {
+	var id uint16
+	r, _, _ := syscall.Syscall(i.vtbl.get_HardwareProductId, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&id)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return 0, fmt.Errorf("gamepad: IRawGameController::get_HardwareProductId failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return id, nil
+}
+
+func (i *_IRawGameController) GetHardwareVendorId() (uint16, error) {
+	var id uint16
+	r, _, _ := syscall.Syscall(i.vtbl.get_HardwareVendorId, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&id)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return 0, fmt.Errorf("gamepad: IRawGameController::get_HardwareVendorId failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return id, nil
+}
+
+func (i *_IRawGameController) QueryInterface(iid *windows.GUID, ppvObject unsafe.Pointer) error {
+	r, _, _ := syscall.Syscall(i.vtbl.QueryInterface, 3, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(iid)), uintptr(ppvObject))
+	if uint32(r) != uint32(windows.S_OK) {
+		return fmt.Errorf("gamepad: IRawGameController::QueryInterface failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return nil
+}
+
+func (i *_IRawGameController) Release() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
+// _IRawGameController2 is available on Windows 10 version 1803 or later.
+type _IRawGameController2 struct {
+	vtbl *_IRawGameController2_Vtbl
+}
+
+type _IRawGameController2_Vtbl struct {
+	QueryInterface      uintptr
+	AddRef              uintptr
+	Release             uintptr
+	GetIids             uintptr
+	GetRuntimeClassName uintptr
+	GetTrustLevel       uintptr
+
+	get_SimpleHapticsControllers uintptr
+	get_NonRoamableId            uintptr
+	get_DisplayName              uintptr
+}
+
+func (i *_IRawGameController2) GetNonRoamableId() (string, error) {
+	var h _HSTRING
+	r, _, _ := syscall.Syscall(i.vtbl.get_NonRoamableId, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&h)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return "", fmt.Errorf("gamepad: IRawGameController2::get_NonRoamableId failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	defer _WindowsDeleteString(h)
+	return _WindowsGetStringRawBuffer(h), nil
+}
+
+func (i *_IRawGameController2) GetDisplayName() (string, error) {
+	var h _HSTRING
+	r, _, _ := syscall.Syscall(i.vtbl.get_DisplayName, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&h)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return "", fmt.Errorf("gamepad: IRawGameController2::get_DisplayName failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	defer _WindowsDeleteString(h)
+	return _WindowsGetStringRawBuffer(h), nil
+}
+
+func (i *_IRawGameController2) Release() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
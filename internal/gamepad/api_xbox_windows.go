@@ -102,6 +102,13 @@ const (
 	_GameInputKindAny              _GameInputKind = 0x0FFFFFFF
 )
 
+// _GameInputDeviceInfo is the head part of GameInputDeviceInfo. Only the fields in use are declared.
+type _GameInputDeviceInfo struct {
+	infoSize  uint32
+	vendorId  uint16
+	productId uint16
+}
+
 type _GameInputGamepadState struct {
 	buttons          _GameInputGamepadButtons
 	leftTrigger      float32
@@ -119,6 +126,10 @@ type _GameInputRumbleParams struct {
 	rightTrigger  float32
 }
 
+func isGameInputAvailable() bool {
+	return procGameInputCreate.Find() == nil
+}
+
 func _GameInputCreate() (*_IGameInput, error) {
 	var gameInput *_IGameInput
 	r, _, _ := procGameInputCreate.Call(uintptr(unsafe.Pointer(&gameInput)))
@@ -189,6 +200,21 @@ func (i *_IGameInput) RegisterDeviceCallback(device *_IGameInputDevice,
 	return nil
 }
 
+func (i *_IGameInput) RegisterGuideButtonCallback(device *_IGameInputDevice,
+	context unsafe.Pointer,
+	callbackFunc uintptr,
+	callbackToken *_GameInputCallbackToken) error {
+	r, _, _ := syscall.Syscall6(i.vtbl.RegisterGuideButtonCallback, 5, uintptr(unsafe.Pointer(i)),
+		uintptr(unsafe.Pointer(device)), uintptr(context), callbackFunc,
+		uintptr(unsafe.Pointer(callbackToken)), 0)
+	runtime.KeepAlive(device)
+	runtime.KeepAlive(callbackToken)
+	if uint32(r) != uint32(windows.S_OK) {
+		return fmt.Errorf("gamepad: IGameInput::RegisterGuideButtonCallback failed: %w", handleError(windows.Handle(uint32(r))))
+	}
+	return nil
+}
+
 type _IGameInputDevice struct {
 	vtbl *_IGameInputDevice_Vtbl
 }
@@ -218,6 +244,22 @@ type _IGameInputDevice_Vtbl struct {
 	ReleaseExclusiveRawDeviceAccess uintptr
 }
 
+func (i *_IGameInputDevice) AddRef() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.AddRef, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
+func (i *_IGameInputDevice) GetDeviceInfo() *_GameInputDeviceInfo {
+	r, _, _ := syscall.Syscall(i.vtbl.GetDeviceInfo, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	// The returned pointer is owned by the device and is not managed by Go.
+	return *(**_GameInputDeviceInfo)(unsafe.Pointer(&r))
+}
+
+func (i *_IGameInputDevice) Release() uint32 {
+	r, _, _ := syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+	return uint32(r)
+}
+
 func (i *_IGameInputDevice) SetRumbleState(params *_GameInputRumbleParams, timestamp uint64) {
 	_, _, _ = syscall.Syscall(i.vtbl.SetRumbleState, 3, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(params)), uintptr(timestamp))
 	runtime.KeepAlive(params)
@@ -52,3 +52,7 @@ const (
 )
 
 const ButtonCount = 32
+
+// maxButtonCount is the maximum number of the buttons of a gamepad.
+// A device with more buttons is not treated as a gamepad.
+const maxButtonCount = 128
@@ -0,0 +1,88 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"math"
+)
+
+// AxisCalibration represents the observed range of an axis.
+// The values are normalized values in [-1, 1] without the calibration and the dead zone.
+type AxisCalibration struct {
+	// Min is the minimum value the axis reaches.
+	Min float64 `json:"min"`
+
+	// Center is the value of the axis at rest.
+	Center float64 `json:"center"`
+
+	// Max is the maximum value the axis reaches.
+	Max float64 `json:"max"`
+}
+
+// Calibration represents the calibration of the axes of a gamepad.
+type Calibration struct {
+	// SDLID is the SDL GUID of the calibrated gamepad.
+	SDLID string `json:"sdlId"`
+
+	// Serial is the serial number of the calibrated gamepad, or an empty string if the serial number is not available.
+	Serial string `json:"serial,omitempty"`
+
+	// Axes is the calibrations of the axes by their indices.
+	Axes map[int]AxisCalibration `json:"axes"`
+}
+
+func (c *Calibration) clone() *Calibration {
+	if c == nil {
+		return nil
+	}
+	c2 := *c
+	c2.Axes = make(map[int]AxisCalibration, len(c.Axes))
+	for i, a := range c.Axes {
+		c2.Axes[i] = a
+	}
+	return &c2
+}
+
+// calibrationKey returns the key to identify a physical gamepad for calibrations.
+func calibrationKey(sdlID, serial string) string {
+	return sdlID + "/" + serial
+}
+
+// apply returns the calibrated value of the normalized value v.
+//
+// Min, Center and Max are mapped to -1, 0 and 1 respectively.
+// If Center is not in the middle half of the range, e.g., for a trigger resting at one end,
+// the axis is regarded as having no center and is calibrated linearly so that Min and Max are mapped to -1 and 1.
+func (c AxisCalibration) apply(v float64) float64 {
+	if c.Max <= c.Min {
+		return v
+	}
+
+	var r float64
+	if quarter := (c.Max - c.Min) / 4; c.Center < c.Min+quarter || c.Center > c.Max-quarter {
+		r = (v-c.Min)/(c.Max-c.Min)*2 - 1
+	} else if v >= c.Center {
+		r = (v - c.Center) / (c.Max - c.Center)
+	} else {
+		r = (v - c.Center) / (c.Center - c.Min)
+	}
+	return math.Max(math.Min(r, 1), -1)
+}
+
+// record extends the range with the observed value v.
+func (c *AxisCalibration) record(v float64) {
+	c.Min = math.Min(c.Min, v)
+	c.Max = math.Max(c.Max, v)
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+// AndroidGamepadsForTesting feeds Android input events to gamepads without the Java side.
+type AndroidGamepadsForTesting struct {
+	gamepads gamepads
+}
+
+func NewAndroidGamepadsForTesting() *AndroidGamepadsForTesting {
+	return &AndroidGamepadsForTesting{}
+}
+
+// Add adds a gamepad as if the Java side enumerated the device.
+func (g *AndroidGamepadsForTesting) Add(androidDeviceID int, axisCount, hatCount int) {
+	g.gamepads.addAndroidGamepad(androidDeviceID, "", "", axisCount, hatCount)
+}
+
+// UpdateButton updates the button as if the Java side received a key event.
+func (g *AndroidGamepadsForTesting) UpdateButton(androidDeviceID int, button Button, pressed bool) {
+	g.gamepads.updateAndroidGamepadButton(androidDeviceID, button, pressed)
+}
+
+// UpdateHat updates the hat as if the Java side received a motion event.
+func (g *AndroidGamepadsForTesting) UpdateHat(androidDeviceID int, hat int, xValue, yValue int) {
+	g.gamepads.updateAndroidGamepadHat(androidDeviceID, hat, xValue, yValue)
+}
+
+// Gamepad returns the gamepad for the device, or nil if not found.
+func (g *AndroidGamepadsForTesting) Gamepad(androidDeviceID int) *Gamepad {
+	return g.gamepads.find(func(gamepad *Gamepad) bool {
+		return gamepad.native.(*nativeGamepadImpl).androidDeviceID == androidDeviceID
+	})
+}
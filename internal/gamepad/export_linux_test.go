@@ -0,0 +1,574 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !android && !nintendosdk && !playstation5
+
+package gamepad
+
+import (
+	"sort"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func NormalizeAbsValueForTesting(value, minimum, maximum, flat int32, deadZone bool) float64 {
+	return normalizeAbsValue(&input_absinfo{
+		minimum: minimum,
+		maximum: maximum,
+		flat:    flat,
+	}, value, deadZone)
+}
+
+var DefuzzAbsValueForTesting = defuzzAbsValue
+
+var IsGamepadDeviceForTesting = isGamepadDevice
+
+var IsGamepadDeviceByPropertiesForTesting = isGamepadDeviceByProperties
+
+var ParseUeventForTesting = parseUevent
+
+var PlayerLEDNumberForTesting = playerLEDNumber
+
+var IsSameInputDeviceForTesting = isSameInputDevice
+
+var ParseSysfsBitmapForTesting = parseSysfsBitmap
+
+var IsAuxiliaryDeviceNameForTesting = isAuxiliaryDeviceName
+
+var ResolveGamepadNameForTesting = resolveGamepadName
+
+var USBDeviceStringsForTesting = usbDeviceStrings
+
+// InotifyEventForTesting is an inotify event parsed by ParseInotifyEventsForTesting.
+type InotifyEventForTesting struct {
+	Mask uint32
+	Name string
+}
+
+func ParseInotifyEventsForTesting(buf []byte) []InotifyEventForTesting {
+	return ParseInotifyEventsFromReadsForTesting([][]byte{buf})
+}
+
+// ParseInotifyEventsFromReadsForTesting parses the inotify events in the buffers of multiple reads in the same way as updates.
+func ParseInotifyEventsFromReadsForTesting(reads [][]byte) []InotifyEventForTesting {
+	var parsed []inotifyEvent
+	for _, buf := range reads {
+		parsed = parseInotifyEvents(parsed, buf)
+	}
+	var events []InotifyEventForTesting
+	for _, e := range parsed {
+		events = append(events, InotifyEventForTesting{
+			Mask: e.mask,
+			Name: e.name,
+		})
+	}
+	return events
+}
+
+func ParseSDLIgnoreDevicesForTesting(str string) [][2]uint16 {
+	var devices [][2]uint16
+	for d := range parseSDLIgnoreDevices(str) {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i][0] != devices[j][0] {
+			return devices[i][0] < devices[j][0]
+		}
+		return devices[i][1] < devices[j][1]
+	})
+	return devices
+}
+
+func MotionSensorValueForTesting(axis MotionSensorAxis, value int32, resolution int32) float64 {
+	return motionSensorValue(_ABS_X+int(axis), value, resolution)
+}
+
+type NativeGamepadForTesting struct {
+	g *nativeGamepadImpl
+}
+
+// NewNativeGamepadForTesting creates a gamepad reading input events from fd.
+// The gamepad has only one button for BTN_A.
+func NewNativeGamepadForTesting(fd int) *NativeGamepadForTesting {
+	keyBits := make([]byte, (_KEY_CNT+7)/8)
+	keyBits[_BTN_A/8] |= 1 << (_BTN_A % 8)
+	return NewNativeGamepadWithBitsForTesting(fd, keyBits, nil)
+}
+
+// NewNativeGamepadWithBitsForTesting creates a gamepad reading input events from fd.
+// The gamepad has the buttons in keyBits and the axes and the hats in absBits.
+func NewNativeGamepadWithBitsForTesting(fd int, keyBits, absBits []byte) *NativeGamepadForTesting {
+	g := &nativeGamepadImpl{
+		fd:         fd,
+		dev:        fdEvdevDevice(fd),
+		poller:     ioctlEvdevPoller{},
+		ffEffectID: -1,
+	}
+	g.mapButtons(keyBits)
+	g.mapAxesAndHats(absBits)
+	return &NativeGamepadForTesting{g: g}
+}
+
+func (n *NativeGamepadForTesting) Update() error {
+	return n.g.update(&gamepads{})
+}
+
+func (n *NativeGamepadForTesting) Button() bool {
+	return n.g.isButtonPressed(0)
+}
+
+func (n *NativeGamepadForTesting) ButtonCount() int {
+	return n.g.buttonCount()
+}
+
+func (n *NativeGamepadForTesting) IsButtonPressed(button int) bool {
+	return n.g.isButtonPressed(button)
+}
+
+func (n *NativeGamepadForTesting) AxisCount() int {
+	return n.g.axisCount()
+}
+
+func (n *NativeGamepadForTesting) HatCount() int {
+	return n.g.hatCount()
+}
+
+func (n *NativeGamepadForTesting) HatState(hat int) int {
+	return n.g.hatState(hat)
+}
+
+func (n *NativeGamepadForTesting) ButtonNativeCode(button int) (int, string, bool) {
+	return n.g.buttonNativeCode(button)
+}
+
+// SetForceFeedbackForTesting makes the gamepad support rumble with the fake force feedback.
+func (n *NativeGamepadForTesting) SetForceFeedbackForTesting(ff *ForceFeedbackForTesting) {
+	n.g.rumble = true
+	n.g.ffGain = ff.GainSupported
+	n.g.ff = ff
+}
+
+func (n *NativeGamepadForTesting) SetVibrationGain(gain float64) {
+	n.g.setVibrationGain(gain)
+}
+
+func (n *NativeGamepadForTesting) Vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+	n.g.vibrate(duration, strongMagnitude, weakMagnitude)
+}
+
+// ExpireVibration makes the current vibration end.
+func (n *NativeGamepadForTesting) ExpireVibration() {
+	n.g.ffEffectEnd = time.Time{}
+}
+
+func (n *NativeGamepadForTesting) Close() {
+	n.g.close()
+}
+
+// SetKeyStateForTesting makes the gamepad read the key state from keyBits instead of the device, after events are dropped.
+func (n *NativeGamepadForTesting) SetKeyStateForTesting(keyBits []byte) {
+	n.g.poller = &evdevPollerForTesting{
+		keyBits: keyBits,
+	}
+}
+
+type evdevPollerForTesting struct {
+	keyBits []byte
+}
+
+func (*evdevPollerForTesting) absInfo(dev evdevDevice, code int, info *input_absinfo) error {
+	return nil
+}
+
+func (e *evdevPollerForTesting) keyState(dev evdevDevice, keyBits []byte) error {
+	copy(keyBits, e.keyBits)
+	return nil
+}
+
+func (e *evdevPollerForTesting) mtSlots(dev evdevDevice, code int, n int) ([]int32, error) {
+	values := make([]int32, n)
+	if code == _ABS_MT_TRACKING_ID {
+		for i := range values {
+			values[i] = -1
+		}
+	}
+	return values, nil
+}
+
+// ForceFeedbackForTesting is a fake of force feedback effects of a device.
+type ForceFeedbackForTesting struct {
+	// MaxEffects is the number of the effect slots.
+	MaxEffects int
+
+	// Effects is the lengths in milliseconds of the uploaded effects by their IDs.
+	Effects map[int16]uint16
+
+	// Playing reports whether the effects are playing by their IDs.
+	Playing map[int16]bool
+
+	// Magnitudes is the strong and weak magnitudes of the uploaded effects by their IDs.
+	Magnitudes map[int16][2]uint16
+
+	// GainSupported reports whether the device supports FF_GAIN.
+	GainSupported bool
+
+	// Gains is the written gains in order.
+	Gains []uint16
+}
+
+func (f *ForceFeedbackForTesting) upload(fd int, e *ff_effect) error {
+	if f.Effects == nil {
+		f.Effects = map[int16]uint16{}
+	}
+	if e.id >= 0 {
+		if _, ok := f.Effects[e.id]; !ok {
+			return unix.EINVAL
+		}
+		f.Effects[e.id] = e.replay.length
+		f.setMagnitudes(e)
+		return nil
+	}
+	for id := int16(0); int(id) < f.MaxEffects; id++ {
+		if _, ok := f.Effects[id]; ok {
+			continue
+		}
+		f.Effects[id] = e.replay.length
+		e.id = id
+		f.setMagnitudes(e)
+		return nil
+	}
+	return unix.ENOSPC
+}
+
+func (f *ForceFeedbackForTesting) remove(fd int, id int16) error {
+	if _, ok := f.Effects[id]; !ok {
+		return unix.EINVAL
+	}
+	delete(f.Effects, id)
+	delete(f.Playing, id)
+	return nil
+}
+
+func (f *ForceFeedbackForTesting) play(fd int, id int16, play bool) error {
+	if _, ok := f.Effects[id]; !ok {
+		return unix.EINVAL
+	}
+	if f.Playing == nil {
+		f.Playing = map[int16]bool{}
+	}
+	f.Playing[id] = play
+	return nil
+}
+
+func (f *ForceFeedbackForTesting) setGain(fd int, gain uint16) error {
+	if !f.GainSupported {
+		return unix.EINVAL
+	}
+	f.Gains = append(f.Gains, gain)
+	return nil
+}
+
+func (f *ForceFeedbackForTesting) setMagnitudes(e *ff_effect) {
+	if f.Magnitudes == nil {
+		f.Magnitudes = map[int16][2]uint16{}
+	}
+	r := (*ff_rumble_effect)(unsafe.Pointer(&e.u))
+	f.Magnitudes[e.id] = [2]uint16{r.strong_magnitude, r.weak_magnitude}
+}
+
+type TouchpadForTesting struct {
+	t *touchpad
+}
+
+// NewTouchpadForTesting creates a touchpad reading input events from fd.
+// The touch positions range from 0 to maxX and maxY.
+// The touchpad reads the state from keyBits and reports no touches, after events are dropped.
+func NewTouchpadForTesting(fd int, maxX, maxY int32, keyBits []byte) *TouchpadForTesting {
+	return &TouchpadForTesting{
+		t: &touchpad{
+			fd: fd,
+			absInfoX: input_absinfo{
+				maximum: maxX,
+			},
+			absInfoY: input_absinfo{
+				maximum: maxY,
+			},
+			poller: &evdevPollerForTesting{
+				keyBits: keyBits,
+			},
+		},
+	}
+}
+
+func (t *TouchpadForTesting) Update() error {
+	return t.t.update()
+}
+
+func (t *TouchpadForTesting) IsPressed() bool {
+	return t.t.pressed
+}
+
+func (t *TouchpadForTesting) TouchPosition(index int) (float64, float64, bool) {
+	return t.t.touchPosition(index)
+}
+
+// AppendInputEventForTesting appends the bytes of an input event to buf.
+func AppendInputEventForTesting(buf []byte, typ, code uint16, value int32) []byte {
+	e := input_event{
+		typ:   typ,
+		code:  code,
+		value: value,
+	}
+	return append(buf, (*[unsafe.Sizeof(input_event{})]byte)(unsafe.Pointer(&e))[:]...)
+}
+
+// AbsInfoForTesting is the state of an absolute axis of EvdevDeviceForTesting.
+type AbsInfoForTesting struct {
+	Value   int32
+	Minimum int32
+	Maximum int32
+	Fuzz    int32
+	Flat    int32
+}
+
+// EvdevDeviceForTesting is a scripted fake of an evdev device.
+type EvdevDeviceForTesting struct {
+	Name    string
+	Uniq    string
+	Phys    string
+	BusType uint16
+	Vendor  uint16
+	Product uint16
+	Version uint16
+
+	// Props is the list of the input properties like INPUT_PROP_ACCELEROMETER.
+	Props []int
+
+	// Keys is the list of the supported key codes.
+	Keys []int
+
+	// PressedKeys is the list of the pressed key codes.
+	PressedKeys []int
+
+	// Axes is the states of the supported absolute axes by their codes.
+	Axes map[int]AbsInfoForTesting
+
+	// Events is the bytes of the input events to be read. The read events are removed.
+	Events []byte
+
+	// ReadSize is the maximum byte size of a read, which simulates short reads. 0 means no limit.
+	ReadSize int
+
+	// EOF makes reading the device return 0 bytes after all the events are read.
+	EOF bool
+
+	// Disconnected makes reading the device fail with ENODEV.
+	Disconnected bool
+
+	// ReadErr makes reading the device fail with the error.
+	ReadErr error
+
+	// Closed reports whether the device is closed.
+	Closed bool
+}
+
+func (d *EvdevDeviceForTesting) read(buf []byte) (int, error) {
+	if d.Disconnected {
+		return 0, unix.ENODEV
+	}
+	if d.ReadErr != nil {
+		return 0, d.ReadErr
+	}
+	if len(d.Events) == 0 {
+		if d.EOF {
+			return 0, nil
+		}
+		return 0, unix.EAGAIN
+	}
+	// evdev never returns an incomplete event, unless ReadSize is specified.
+	eventSize := int(unsafe.Sizeof(input_event{}))
+	size := len(buf) / eventSize * eventSize
+	if d.ReadSize > 0 && d.ReadSize < len(buf) {
+		size = d.ReadSize
+	}
+	n := copy(buf[:size], d.Events)
+	d.Events = d.Events[n:]
+	return n, nil
+}
+
+func (d *EvdevDeviceForTesting) ioctl(request uint, ptr unsafe.Pointer) error {
+	if (request>>_IOC_TYPESHIFT)&(1<<_IOC_TYPEBITS-1) != 'E' {
+		return unix.ENOTTY
+	}
+	nr := (request >> _IOC_NRSHIFT) & (1<<_IOC_NRBITS - 1)
+	size := (request >> _IOC_SIZESHIFT) & (1<<_IOC_SIZEBITS - 1)
+	buf := unsafe.Slice((*byte)(ptr), size)
+
+	switch {
+	case request == _EVIOCGID():
+		*(*input_id)(ptr) = input_id{
+			bustype: d.BusType,
+			vendor:  d.Vendor,
+			product: d.Product,
+			version: d.Version,
+		}
+	case request == _EVIOCGNAME(size):
+		copyCStringForTesting(buf, d.Name)
+	case request == _EVIOCGPHYS(size):
+		copyCStringForTesting(buf, d.Phys)
+	case request == _EVIOCGUNIQ(size):
+		copyCStringForTesting(buf, d.Uniq)
+	case request == _EVIOCGPROP(size):
+		setBitsForTesting(buf, d.Props)
+	case request == _EVIOCGKEY(size):
+		setBitsForTesting(buf, d.PressedKeys)
+	case request == _EVIOCGBIT(0, size):
+		evs := []int{unix.EV_SYN}
+		if len(d.Keys) > 0 {
+			evs = append(evs, unix.EV_KEY)
+		}
+		if len(d.Axes) > 0 {
+			evs = append(evs, unix.EV_ABS)
+		}
+		setBitsForTesting(buf, evs)
+	case request == _EVIOCGBIT(unix.EV_KEY, size):
+		setBitsForTesting(buf, d.Keys)
+	case request == _EVIOCGBIT(unix.EV_ABS, size):
+		var codes []int
+		for code := range d.Axes {
+			codes = append(codes, code)
+		}
+		setBitsForTesting(buf, codes)
+	case nr >= 0x40 && nr < 0x40+_ABS_CNT && request == _EVIOCGABS(nr-0x40):
+		info, ok := d.Axes[int(nr-0x40)]
+		if !ok {
+			return unix.EINVAL
+		}
+		*(*input_absinfo)(ptr) = input_absinfo{
+			value:   info.Value,
+			minimum: info.Minimum,
+			maximum: info.Maximum,
+			fuzz:    info.Fuzz,
+			flat:    info.Flat,
+		}
+	default:
+		return unix.ENOTTY
+	}
+	return nil
+}
+
+func (d *EvdevDeviceForTesting) close() error {
+	d.Closed = true
+	return nil
+}
+
+func copyCStringForTesting(buf []byte, str string) {
+	if len(buf) == 0 {
+		return
+	}
+	n := copy(buf[:len(buf)-1], str)
+	buf[n] = 0
+}
+
+func setBitsForTesting(buf []byte, codes []int) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	for _, code := range codes {
+		if code/8 >= len(buf) {
+			continue
+		}
+		buf[code/8] |= 1 << (code % 8)
+	}
+}
+
+// EvdevGamepadsForTesting opens fake evdev devices as gamepads without scanning the actual devices.
+type EvdevGamepadsForTesting struct {
+	gamepads gamepads
+	native   *nativeGamepadsImpl
+}
+
+func NewEvdevGamepadsForTesting() *EvdevGamepadsForTesting {
+	n := &nativeGamepadsImpl{}
+	g := &EvdevGamepadsForTesting{
+		native: n,
+	}
+	g.gamepads.native = n
+	g.gamepads.inited = true
+	return g
+}
+
+// Open opens the fake device dev as if it were the device file at path.
+func (g *EvdevGamepadsForTesting) Open(path string, dev *EvdevDeviceForTesting) error {
+	return g.native.openEvdevDevice(&g.gamepads, path, -1, dev, false)
+}
+
+// SetSteamInputDevices sets the value of SDL_GAMECONTROLLER_IGNORE_DEVICES.
+func (g *EvdevGamepadsForTesting) SetSteamInputDevices(str string) {
+	g.native.steamInputDevices = parseSDLIgnoreDevices(str)
+}
+
+// HiddenPaths returns the sorted paths of the hidden physical gamepads.
+func (g *EvdevGamepadsForTesting) HiddenPaths() []string {
+	var paths []string
+	for path := range g.native.hiddenPaths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// IgnoredPaths returns the sorted paths of the ignored devices.
+func (g *EvdevGamepadsForTesting) IgnoredPaths() []string {
+	var paths []string
+	for path := range g.native.ignoredPaths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// RemoveGamepadsWithTooManyButtons removes the gamepads that have too many buttons.
+func (g *EvdevGamepadsForTesting) RemoveGamepadsWithTooManyButtons() {
+	g.gamepads.removeGamepadsWithTooManyButtons()
+}
+
+// RestoreHiddenGamepads tries to open the hidden physical gamepads again.
+func (g *EvdevGamepadsForTesting) RestoreHiddenGamepads() {
+	g.native.restoreHiddenGamepads(&g.gamepads)
+}
+
+// DeviceErrors returns the recorded device errors.
+func (g *EvdevGamepadsForTesting) DeviceErrors() []DeviceError {
+	return g.gamepads.appendDeviceErrors(nil)
+}
+
+// Gamepads returns the connected gamepads.
+func (g *EvdevGamepadsForTesting) Gamepads() []*Gamepad {
+	var gps []*Gamepad
+	for _, gp := range g.gamepads.gamepads {
+		if gp == nil {
+			continue
+		}
+		gps = append(gps, gp)
+	}
+	return gps
+}
+
+// Update reads the events of the gamepads.
+func (g *EvdevGamepadsForTesting) Update() error {
+	return g.gamepads.updateGamepads()
+}
@@ -0,0 +1,557 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
+)
+
+type GamepadsForTesting struct {
+	g gamepads
+}
+
+func NewGamepadsForTesting(reconnectionGracePeriod time.Duration) *GamepadsForTesting {
+	return &GamepadsForTesting{
+		g: gamepads{
+			reconnectionGracePeriod: reconnectionGracePeriod,
+		},
+	}
+}
+
+func (g *GamepadsForTesting) Add(key string) ID {
+	gp := g.g.addWithReconnectionKey("", "", key)
+	for i, gp2 := range g.g.gamepads {
+		if gp == gp2 {
+			return ID(i)
+		}
+	}
+	panic("not reached")
+}
+
+func (g *GamepadsForTesting) Remove(id ID) {
+	gp := g.g.gamepads[id]
+	g.g.remove(func(gamepad *Gamepad) bool {
+		return gamepad == gp
+	})
+}
+
+func (g *GamepadsForTesting) PlayerIndex(id ID) int {
+	return g.g.gamepads[id].PlayerIndex()
+}
+
+// FlushConnectionEvents makes the connection events so far visible as an update does.
+func (g *GamepadsForTesting) FlushConnectionEvents() {
+	g.g.flushConnectionEvents()
+}
+
+func (g *GamepadsForTesting) AppendConnectionEvents(events []ConnectionEvent) []ConnectionEvent {
+	return g.g.appendConnectionEvents(events)
+}
+
+func (g *GamepadsForTesting) AppendGamepadIDs(ids []ID) []ID {
+	return g.g.appendGamepadIDs(ids)
+}
+
+// UpdateGamepad updates the gamepad as an update of the gamepads does.
+func (g *GamepadsForTesting) UpdateGamepad(gamepad *GamepadForTesting) error {
+	return gamepad.update(&g.g)
+}
+
+func (g *GamepadsForTesting) AppendUnmappedGamepads(gamepads []UnmappedGamepad) []UnmappedGamepad {
+	return g.g.appendUnmappedGamepads(gamepads)
+}
+
+func (c AxisCalibration) ApplyForTesting(v float64) float64 {
+	return c.apply(v)
+}
+
+type RumbleForTesting struct {
+	r rumble
+}
+
+func NewRumbleForTesting(setMotorSpeeds func(low, high uint16) error) *RumbleForTesting {
+	return &RumbleForTesting{
+		r: rumble{
+			setMotorSpeeds: setMotorSpeeds,
+		},
+	}
+}
+
+func (r *RumbleForTesting) Start(now time.Time, duration time.Duration, strongMagnitude, weakMagnitude float64) error {
+	return r.r.start(now, duration, strongMagnitude, weakMagnitude)
+}
+
+func (r *RumbleForTesting) Update(now time.Time) error {
+	return r.r.update(now)
+}
+
+func (r *RumbleForTesting) Stop() error {
+	return r.r.stop()
+}
+
+func SDLGUIDForTesting(bus, vendor, product, version uint16, name string, driverSignature, driverData byte) string {
+	return sdlGUID(bus, vendor, product, version, name, driverSignature, driverData)
+}
+
+var XInputSDLGUIDForTesting = xinputSDLGUID
+
+var AndroidSDLGUIDForTesting = androidSDLGUID
+
+var WGISDLGUIDForTesting = wgiSDLGUID
+
+var SDLCRC16ForTesting = sdlCRC16
+
+var EmscriptenSDLGUIDForTesting = emscriptenSDLGUID
+
+type InputSnapshotForTesting struct {
+	s inputSnapshot
+}
+
+// Update updates the state with a snapshot of the given axis values and button values.
+// A button is pressed if its value is not zero.
+func (s *InputSnapshotForTesting) Update(axes []float64, buttons []float64) bool {
+	return s.s.update(len(axes), len(buttons), func(index int) float64 {
+		return axes[index]
+	}, func(index int) (float64, bool) {
+		return buttons[index], buttons[index] != 0
+	})
+}
+
+func (s *InputSnapshotForTesting) AxisCount() int {
+	return s.s.axisCount()
+}
+
+func (s *InputSnapshotForTesting) ButtonCount() int {
+	return s.s.buttonCount()
+}
+
+func (s *InputSnapshotForTesting) AxisValue(axis int) float64 {
+	return s.s.axisValue(axis)
+}
+
+func (s *InputSnapshotForTesting) IsButtonPressed(button int) bool {
+	return s.s.isButtonPressed(button)
+}
+
+type TouchGamepadForTesting struct {
+	n nativeGamepadTouch
+}
+
+func NewTouchGamepadForTesting(elements VirtualGamepadElement) *TouchGamepadForTesting {
+	return &TouchGamepadForTesting{
+		n: nativeGamepadTouch{
+			elements: elements,
+		},
+	}
+}
+
+func (t *TouchGamepadForTesting) Update(touches []VirtualGamepadTouch, width, height float64) {
+	t.n.updateTouches(&virtualGamepadTouchState{
+		touches: touches,
+		width:   width,
+		height:  height,
+	})
+}
+
+func (t *TouchGamepadForTesting) AxisValue(axis gamepaddb.StandardAxis) float64 {
+	return t.n.axisValue(int(axis))
+}
+
+func (t *TouchGamepadForTesting) IsButtonPressed(button gamepaddb.StandardButton) bool {
+	return t.n.isButtonPressed(int(button))
+}
+
+type HIDGamepadForTesting struct {
+	desc *hidReportDescriptor
+	g    *hidGamepad
+}
+
+func NewHIDGamepadForTesting(desc []byte, reportID uint8) (*HIDGamepadForTesting, error) {
+	d, err := parseHIDReportDescriptor(desc)
+	if err != nil {
+		return nil, err
+	}
+	return &HIDGamepadForTesting{
+		desc: d,
+		g:    newHIDGamepad(d, reportID),
+	}, nil
+}
+
+func (h *HIDGamepadForTesting) IsGamepad() bool {
+	return h.desc.isGamepad()
+}
+
+func (h *HIDGamepadForTesting) InputReportSize(reportID uint8) int {
+	return h.desc.inputReportSize(reportID)
+}
+
+func (h *HIDGamepadForTesting) SetReport(data []byte) {
+	h.g.setReport(data)
+}
+
+func (h *HIDGamepadForTesting) AxisCount() int {
+	return h.g.axisCount()
+}
+
+func (h *HIDGamepadForTesting) ButtonCount() int {
+	return h.g.buttonCount()
+}
+
+func (h *HIDGamepadForTesting) HatCount() int {
+	return h.g.hatCount()
+}
+
+func (h *HIDGamepadForTesting) AxisValue(axis int) float64 {
+	return h.g.axisValue(axis)
+}
+
+func (h *HIDGamepadForTesting) IsButtonPressed(button int) bool {
+	return h.g.isButtonPressed(button)
+}
+
+func (h *HIDGamepadForTesting) HatState(hat int) int {
+	return h.g.hatState(hat)
+}
+
+var EncodeSwitchProRumbleForTesting = encodeSwitchProRumble
+
+// SwitchProDeviceForTesting is a fake of the raw HID device of a Switch Pro Controller.
+// The device replies to the commands and the subcommands immediately.
+type SwitchProDeviceForTesting struct {
+	// SPI is the content of the SPI flash by addresses. The other addresses have 0xff.
+	SPI map[uint32]byte
+
+	// NoReply makes the device ignore the commands and the subcommands.
+	NoReply bool
+
+	// Written is the written output reports in order.
+	Written [][]byte
+
+	reports [][]byte
+	closed  bool
+}
+
+// PushReport queues an input report.
+func (d *SwitchProDeviceForTesting) PushReport(report []byte) {
+	d.reports = append(d.reports, append([]byte(nil), report...))
+}
+
+func (d *SwitchProDeviceForTesting) read() ([]byte, error) {
+	if d.closed {
+		return nil, errors.New("closed")
+	}
+	if len(d.reports) == 0 {
+		return nil, nil
+	}
+	r := d.reports[0]
+	d.reports = d.reports[1:]
+	return r, nil
+}
+
+func (d *SwitchProDeviceForTesting) write(report []byte) error {
+	if d.closed {
+		return errors.New("closed")
+	}
+	d.Written = append(d.Written, append([]byte(nil), report...))
+	if d.NoReply {
+		return nil
+	}
+
+	switch report[0] {
+	case 0x80:
+		if report[1] != switchProUSBCommandForceUSB {
+			d.PushReport([]byte{0x81, report[1]})
+		}
+	case 0x01:
+		reply := make([]byte, 64)
+		reply[0] = 0x21
+		// The sticks are at the center.
+		copy(reply[6:12], []byte{0x00, 0x08, 0x80, 0x00, 0x08, 0x80})
+		reply[13] = 0x80
+		reply[14] = report[10]
+		if report[10] == switchProSubcommandReadSPI {
+			reply[13] = 0x90
+			copy(reply[15:20], report[11:16])
+			address := uint32(report[11]) | uint32(report[12])<<8 | uint32(report[13])<<16 | uint32(report[14])<<24
+			for i := 0; i < int(report[15]); i++ {
+				v, ok := d.SPI[address+uint32(i)]
+				if !ok {
+					v = 0xff
+				}
+				reply[20+i] = v
+			}
+		}
+		d.PushReport(reply)
+	}
+	return nil
+}
+
+func (d *SwitchProDeviceForTesting) close() {
+	d.closed = true
+}
+
+type SwitchProGamepadForTesting struct {
+	n   *nativeGamepadSwitchPro
+	now time.Time
+}
+
+// NewSwitchProGamepadForTesting initializes a Switch Pro Controller with the fake device d.
+// The time of the gamepad advances only by Advance and by waiting for replies.
+func NewSwitchProGamepadForTesting(d *SwitchProDeviceForTesting, bluetooth bool) (*SwitchProGamepadForTesting, error) {
+	g := &SwitchProGamepadForTesting{}
+	n := &nativeGamepadSwitchPro{
+		device:        d,
+		bluetooth:     bluetooth,
+		leftStick:     defaultSwitchProStickCalibration,
+		rightStick:    defaultSwitchProStickCalibration,
+		batteryLevel_: -1,
+		now: func() time.Time {
+			return g.now
+		},
+		sleep: func(duration time.Duration) {
+			g.now = g.now.Add(duration)
+		},
+	}
+	if err := n.init(); err != nil {
+		return nil, err
+	}
+	g.n = n
+	return g, nil
+}
+
+func (g *SwitchProGamepadForTesting) Advance(duration time.Duration) {
+	g.now = g.now.Add(duration)
+}
+
+func (g *SwitchProGamepadForTesting) Update() error {
+	return g.n.update(&gamepads{})
+}
+
+func (g *SwitchProGamepadForTesting) AxisValue(axis gamepaddb.StandardAxis) float64 {
+	return g.n.axisValue(int(axis))
+}
+
+func (g *SwitchProGamepadForTesting) IsButtonPressed(button int) bool {
+	return g.n.isButtonPressed(button)
+}
+
+func (g *SwitchProGamepadForTesting) MotionSensorValue(axis MotionSensorAxis) float64 {
+	return g.n.motionSensorValue(axis)
+}
+
+func (g *SwitchProGamepadForTesting) BatteryLevel() (int, bool) {
+	return g.n.batteryLevel()
+}
+
+func (g *SwitchProGamepadForTesting) PowerState() PowerState {
+	return g.n.powerState()
+}
+
+func (g *SwitchProGamepadForTesting) Vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+	g.n.vibrate(duration, strongMagnitude, weakMagnitude)
+}
+
+func (g *SwitchProGamepadForTesting) SetPlayerIndex(index int) {
+	g.n.setPlayerIndex(index)
+}
+
+const SwitchProButtonCaptureForTesting = switchProButtonCapture
+
+// SonyDeviceForTesting is a fake of the raw HID device of DualShock 4 or DualSense.
+type SonyDeviceForTesting struct {
+	// Written is the written output reports in order.
+	Written [][]byte
+}
+
+func (d *SonyDeviceForTesting) read() ([]byte, error) {
+	return nil, nil
+}
+
+func (d *SonyDeviceForTesting) write(report []byte) error {
+	d.Written = append(d.Written, append([]byte(nil), report...))
+	return nil
+}
+
+func (d *SonyDeviceForTesting) getFeature(reportID byte) ([]byte, error) {
+	return []byte{reportID}, nil
+}
+
+func (d *SonyDeviceForTesting) close() {
+}
+
+type SonyGamepadForTesting struct {
+	n *nativeGamepadSony
+}
+
+// NewDualSenseForTesting initializes a DualSense with the fake device d.
+func NewDualSenseForTesting(d *SonyDeviceForTesting, bluetooth bool) *SonyGamepadForTesting {
+	return &SonyGamepadForTesting{
+		n: newNativeGamepadSony(d, sonyDeviceInfo{
+			vendor:    sonyVendorID,
+			product:   sonyProductDualSense,
+			bluetooth: bluetooth,
+		}, sonyModelDualSense),
+	}
+}
+
+func (g *SonyGamepadForTesting) Vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+	g.n.vibrate(duration, strongMagnitude, weakMagnitude)
+}
+
+func (g *SonyGamepadForTesting) SetPlayerIndex(index int) {
+	g.n.setPlayerIndex(index)
+}
+
+// testNativeGamepad is a native gamepad whose inputs are set directly.
+// If ownMapping is true, the gamepad has its own standard layout mapping in the same way as XInput.
+type testNativeGamepad struct {
+	axes       []float64
+	buttons    []bool
+	hats       []int
+	ownMapping bool
+}
+
+func (*testNativeGamepad) update(gamepads *gamepads) error {
+	return nil
+}
+
+func (n *testNativeGamepad) hasOwnStandardLayoutMapping() bool {
+	return n.ownMapping
+}
+
+func (n *testNativeGamepad) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
+	if !n.ownMapping {
+		return nil
+	}
+	return axisMappingInput{g: n, axis: int(axis)}
+}
+
+func (n *testNativeGamepad) standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput {
+	if !n.ownMapping {
+		return nil
+	}
+	switch button {
+	case gamepaddb.StandardButtonFrontBottomLeft:
+		return axisMappingInput{g: n, axis: 4}
+	case gamepaddb.StandardButtonFrontBottomRight:
+		return axisMappingInput{g: n, axis: 5}
+	case gamepaddb.StandardButtonLeftTop:
+		return hatMappingInput{g: n, hat: 0, direction: hatUp}
+	case gamepaddb.StandardButtonLeftBottom:
+		return hatMappingInput{g: n, hat: 0, direction: hatDown}
+	case gamepaddb.StandardButtonLeftLeft:
+		return hatMappingInput{g: n, hat: 0, direction: hatLeft}
+	case gamepaddb.StandardButtonLeftRight:
+		return hatMappingInput{g: n, hat: 0, direction: hatRight}
+	}
+	return buttonMappingInput{g: n, button: int(button)}
+}
+
+func (n *testNativeGamepad) axisCount() int {
+	return len(n.axes)
+}
+
+func (n *testNativeGamepad) buttonCount() int {
+	return len(n.buttons)
+}
+
+func (n *testNativeGamepad) hatCount() int {
+	return len(n.hats)
+}
+
+func (n *testNativeGamepad) axisValue(axis int) float64 {
+	if axis < 0 || axis >= len(n.axes) {
+		return 0
+	}
+	return n.axes[axis]
+}
+
+func (n *testNativeGamepad) buttonValue(button int) float64 {
+	if n.isButtonPressed(button) {
+		return 1
+	}
+	return 0
+}
+
+func (n *testNativeGamepad) isButtonPressed(button int) bool {
+	if button < 0 || button >= len(n.buttons) {
+		return false
+	}
+	return n.buttons[button]
+}
+
+func (n *testNativeGamepad) hatState(hat int) int {
+	if hat < 0 || hat >= len(n.hats) {
+		return 0
+	}
+	return n.hats[hat]
+}
+
+func (*testNativeGamepad) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+}
+
+func (*testNativeGamepad) setPlayerIndex(index int) {
+}
+
+func (*testNativeGamepad) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (*testNativeGamepad) powerState() PowerState {
+	return PowerStateUnknown
+}
+
+type GamepadForTesting struct {
+	*Gamepad
+	n *testNativeGamepad
+}
+
+// NewGamepadForTesting creates a gamepad with the given numbers of the inputs.
+// If ownMapping is true, the gamepad has its own standard layout mapping in the same way as XInput.
+func NewGamepadForTesting(sdlID string, axisCount, buttonCount, hatCount int, ownMapping bool) *GamepadForTesting {
+	n := &testNativeGamepad{
+		axes:       make([]float64, axisCount),
+		buttons:    make([]bool, buttonCount),
+		hats:       make([]int, hatCount),
+		ownMapping: ownMapping,
+	}
+	return &GamepadForTesting{
+		Gamepad: &Gamepad{
+			name:   "Test Gamepad",
+			sdlID:  sdlID,
+			native: n,
+		},
+		n: n,
+	}
+}
+
+func (g *GamepadForTesting) SetAxis(axis int, value float64) {
+	g.n.axes[axis] = value
+}
+
+func (g *GamepadForTesting) SetButton(button int, pressed bool) {
+	g.n.buttons[button] = pressed
+}
+
+func (g *GamepadForTesting) SetHat(hat int, state int) {
+	g.n.hats[hat] = state
+}
+
+func (g *GamepadForTesting) SetHeuristicMappingEnabled(enabled bool) {
+	g.heuristicMappingEnabled = enabled
+}
@@ -18,6 +18,11 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
 )
 
+// AndroidSDLGUID returns an SDL GUID of an Android input device in the same way as SDL's Android backend.
+func AndroidSDLGUID(vendor, product uint16, descriptor string, buttonMask, axisMask uint16) string {
+	return androidSDLGUID(vendor, product, descriptor, buttonMask, axisMask)
+}
+
 func AddAndroidGamepad(androidDeviceID int, name, sdlID string, axisCount, hatCount int) {
 	theGamepads.addAndroidGamepad(androidDeviceID, name, sdlID, axisCount, hatCount)
 }
@@ -26,6 +31,18 @@ func RemoveAndroidGamepad(androidDeviceID int) {
 	theGamepads.removeAndroidGamepad(androidDeviceID)
 }
 
+// SuspendAndroidGamepads marks all the gamepads suspended and releases their inputs.
+// This should be called when the application is paused, as the input events are not delivered while the application is paused.
+func SuspendAndroidGamepads() {
+	theGamepads.suspendAndroidGamepads()
+}
+
+// ResumeAndroidGamepads removes the gamepads that are still suspended.
+// This should be called after AddAndroidGamepad is called for all the present devices when the application is resumed.
+func ResumeAndroidGamepads() {
+	theGamepads.resumeAndroidGamepads()
+}
+
 func UpdateAndroidGamepadAxis(androidDeviceID int, axis int, value float64) {
 	theGamepads.updateAndroidGamepadAxis(androidDeviceID, axis, value)
 }
@@ -42,6 +59,19 @@ func (g *gamepads) addAndroidGamepad(androidDeviceID int, name, sdlID string, ax
 	g.m.Lock()
 	defer g.m.Unlock()
 
+	// A gamepad enumerated again keeps its ID, e.g., after the application is resumed or the device is reconfigured.
+	if gp := g.find(func(gamepad *Gamepad) bool {
+		return gamepad.native.(*nativeGamepadImpl).androidDeviceID == androidDeviceID
+	}); gp != nil {
+		if gp.sdlID == sdlID {
+			gp.resumeAndroidGamepad(axisCount, hatCount)
+			return
+		}
+		g.remove(func(gamepad *Gamepad) bool {
+			return gamepad == gp
+		})
+	}
+
 	gp := g.add(name, sdlID)
 	gp.native = &nativeGamepadImpl{
 		androidDeviceID: androidDeviceID,
@@ -51,6 +81,28 @@ func (g *gamepads) addAndroidGamepad(androidDeviceID int, name, sdlID string, ax
 	}
 }
 
+func (g *gamepads) suspendAndroidGamepads() {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	for _, gp := range g.gamepads {
+		if gp == nil {
+			continue
+		}
+		gp.suspendAndroidGamepad()
+	}
+}
+
+func (g *gamepads) resumeAndroidGamepads() {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	// The gamepads that are not enumerated again are gone while the application is paused.
+	g.remove(func(gamepad *Gamepad) bool {
+		return gamepad.native.(*nativeGamepadImpl).suspended
+	})
+}
+
 func (g *gamepads) removeAndroidGamepad(androidDeviceID int) {
 	g.m.Lock()
 	defer g.m.Unlock()
@@ -99,11 +151,40 @@ func (g *gamepads) updateAndroidGamepadHat(androidDeviceID int, hat int, xValue,
 	gp.updateAndroidGamepadHat(hat, xValue, yValue)
 }
 
+func (g *Gamepad) suspendAndroidGamepad() {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	n := g.native.(*nativeGamepadImpl)
+	n.suspended = true
+	n.releaseInputs()
+}
+
+func (g *Gamepad) resumeAndroidGamepad(axisCount, hatCount int) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	n := g.native.(*nativeGamepadImpl)
+	n.suspended = false
+	// The device might be reconfigured while the application is paused.
+	if len(n.axes) != axisCount {
+		n.axes = make([]float64, axisCount)
+	}
+	if len(n.hats) != hatCount {
+		n.hats = make([]int, hatCount)
+		n.hatMoved = false
+	}
+	n.releaseInputs()
+}
+
 func (g *Gamepad) updateAndroidGamepadAxis(axis int, value float64) {
 	g.m.Lock()
 	defer g.m.Unlock()
 
 	n := g.native.(*nativeGamepadImpl)
+	if n.suspended {
+		return
+	}
 	if axis < 0 || axis >= len(n.axes) {
 		return
 	}
@@ -115,17 +196,39 @@ func (g *Gamepad) updateAndroidGamepadButton(button Button, pressed bool) {
 	defer g.m.Unlock()
 
 	n := g.native.(*nativeGamepadImpl)
+	if n.suspended {
+		return
+	}
 	if button < 0 || int(button) >= len(n.buttons) {
 		return
 	}
+	// Once the first hat reports motion, the D-pad buttons are synthesized from the hat so that the hat and the buttons always agree.
+	// Android might also deliver D-pad key events for the hat axes, but their timings are different from the motion events.
+	if n.hatMoved && isAndroidDPadButton(button) {
+		return
+	}
 	n.buttons[button] = pressed
 }
 
+func isAndroidDPadButton(button Button) bool {
+	switch button {
+	case gamepaddb.SDLControllerButtonDpadUp,
+		gamepaddb.SDLControllerButtonDpadDown,
+		gamepaddb.SDLControllerButtonDpadLeft,
+		gamepaddb.SDLControllerButtonDpadRight:
+		return true
+	}
+	return false
+}
+
 func (g *Gamepad) updateAndroidGamepadHat(hat int, xValue, yValue int) {
 	g.m.Lock()
 	defer g.m.Unlock()
 
 	n := g.native.(*nativeGamepadImpl)
+	if n.suspended {
+		return
+	}
 	if hat < 0 || hat >= len(n.hats) {
 		return
 	}
@@ -144,7 +247,17 @@ func (g *Gamepad) updateAndroidGamepadHat(hat int, xValue, yValue int) {
 	}
 	n.hats[hat] = v
 
-	// Update the gamepad buttons in addition to hats.
+	// Update the D-pad buttons in addition to the first hat, as the standard layout mapping uses the buttons.
+	// Until the first hat reports motion, the D-pad buttons are driven by the key events.
+	if hat != 0 {
+		return
+	}
+	if v != 0 {
+		n.hatMoved = true
+	}
+	if !n.hatMoved {
+		return
+	}
 	// See https://github.com/libsdl-org/SDL/blob/47f2373dc13b66c48bf4024fcdab53cd0bdd59bb/src/joystick/android/SDL_sysjoystick.c#L290-L301
 	n.buttons[gamepaddb.SDLControllerButtonDpadLeft] = v&hatLeft != 0
 	n.buttons[gamepaddb.SDLControllerButtonDpadRight] = v&hatRight != 0
@@ -15,14 +15,20 @@
 package gamepad
 
 import (
+	"errors"
 	"sync"
 	"time"
 
+	"github.com/hajimehoshi/ebiten/v2/internal/debug"
 	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
 )
 
 type ID int
 
+// errDisconnected is returned by a native gamepad's update when the device turns out to be disconnected.
+// The gamepad is removed in the same update.
+var errDisconnected = errors.New("gamepad: the device is disconnected")
+
 const (
 	hatCentered  = 0
 	hatUp        = 1
@@ -35,21 +41,209 @@ const (
 	hatLeftDown  = hatLeft | hatDown
 )
 
+// defaultReconnectionGracePeriod is the default duration to keep the ID of a disconnected gamepad for its reconnection.
+const defaultReconnectionGracePeriod = 5 * time.Second
+
+// PowerState represents the power state of a gamepad.
+type PowerState int
+
+const (
+	PowerStateUnknown PowerState = iota
+	PowerStateOnBattery
+	PowerStateNoBattery
+	PowerStateCharging
+	PowerStateCharged
+)
+
+// BusType represents how a gamepad is connected.
+type BusType int
+
+const (
+	BusTypeUnknown BusType = iota
+	BusTypeUSB
+	BusTypeBluetooth
+	BusTypeVirtual
+)
+
+// MotionSensorAxis represents an axis of the motion sensors of a gamepad.
+// The accelerometer values are in m/s^2, and the gyroscope values are in rad/s.
+type MotionSensorAxis int
+
+const (
+	MotionSensorAxisAccelerometerX MotionSensorAxis = iota
+	MotionSensorAxisAccelerometerY
+	MotionSensorAxisAccelerometerZ
+	MotionSensorAxisGyroscopeX
+	MotionSensorAxisGyroscopeY
+	MotionSensorAxisGyroscopeZ
+	MotionSensorAxisCount
+)
+
+// TouchpadTouchCount is the maximum number of touches on the touchpad of a gamepad.
+const TouchpadTouchCount = 2
+
+// VirtualGamepadElement represents an element of an on-screen virtual gamepad.
+// The values are bit flags and can be combined.
+type VirtualGamepadElement int
+
+const (
+	VirtualGamepadElementLeftThumbstick VirtualGamepadElement = 1 << iota
+	VirtualGamepadElementRightThumbstick
+	VirtualGamepadElementDirectionPad
+	VirtualGamepadElementButtonA
+	VirtualGamepadElementButtonB
+	VirtualGamepadElementButtonX
+	VirtualGamepadElementButtonY
+	VirtualGamepadElementLeftShoulder
+	VirtualGamepadElementRightShoulder
+	VirtualGamepadElementLeftTrigger
+	VirtualGamepadElementRightTrigger
+)
+
 type gamepads struct {
 	inited   bool
 	gamepads []*Gamepad
 	m        sync.Mutex
 
+	axisDeadZoneDisabled bool
+
+	// triggerAxisZeroToOne reports whether trigger axis values are reported in [0, 1] instead of [-1, 1].
+	triggerAxisZeroToOne bool
+
+	// heuristicMappingEnabled reports whether a standard layout mapping is guessed for unknown gamepads.
+	heuristicMappingEnabled bool
+
+	// microGamepadEnabled reports whether devices with only the micro gamepad profile, like Siri Remote, are treated as gamepads.
+	microGamepadEnabled bool
+
+	// virtualGamepadElements is the elements of the on-screen virtual gamepad. 0 means no virtual gamepad is requested.
+	virtualGamepadElements VirtualGamepadElement
+
+	// virtualGamepadTouchState is the state of the touches for a touch-driven virtual gamepad.
+	virtualGamepadTouchState virtualGamepadTouchState
+
+	// exclusiveGrab reports whether the gamepad devices are grabbed exclusively while the window is focused.
+	// unfocused reports whether the window is not focused.
+	exclusiveGrab bool
+	unfocused     bool
+
+	// vibrationAttenuation is 1 minus the gain of vibrations, so that the zero value means the full gain.
+	vibrationAttenuation float64
+
+	// connectionEvents is the list of the connection events in the last update.
+	// pendingConnectionEvents is the list of the connection events to be reported at the next update.
+	// Gamepads can be added or removed outside of update on some platforms.
+	connectionEvents        []ConnectionEvent
+	pendingConnectionEvents []ConnectionEvent
+
+	// disconnected is the list of recently disconnected gamepads.
+	// Their IDs are reserved so that the same gamepads get the same IDs when they are reconnected.
+	disconnected            []disconnectedGamepad
+	reconnectionGracePeriod time.Duration
+
+	// inaccessibleDevices is the list of the devices that look like gamepads but cannot be opened.
+	inaccessibleDevices []InaccessibleDevice
+
+	// deviceErrors is the list of the recent errors of the devices, which were closed due to the errors.
+	deviceErrors []DeviceError
+
+	// unmappedGamepads is the list of the recently connected gamepads without standard layout mappings.
+	unmappedGamepads []UnmappedGamepad
+
+	// calibrations is the calibrations of the gamepads by the keys identifying physical gamepads.
+	// The calibrations are kept after the gamepads are disconnected so that they are applied again at reconnections.
+	calibrations map[string]*Calibration
+
+	// overrides is the persistent standard layout overrides of the gamepads by the keys identifying physical gamepads.
+	overrides map[string]*gamepaddb.Override
+
 	native nativeGamepads
 }
 
+// InaccessibleDevice represents a device that looks like a gamepad but cannot be opened e.g. due to the permission.
+type InaccessibleDevice struct {
+	// Path is the path of the device file.
+	Path string
+
+	// Name is the name of the device, or an empty string if the name is not available.
+	Name string
+
+	// Err is the error at opening the device file, e.g. syscall.EACCES.
+	Err error
+}
+
+// maxDeviceErrors is the maximum number of the recorded device errors. Older errors are discarded.
+const maxDeviceErrors = 16
+
+// DeviceError represents an error of a device, which made the device unavailable.
+// A device error doesn't stop the game, and the other gamepads keep working.
+type DeviceError struct {
+	// Path is the path of the device file, or an empty string if the path is not available.
+	Path string
+
+	// Name is the name of the device, or an empty string if the name is not available.
+	Name string
+
+	// Err is the error of the device.
+	Err error
+
+	// Time is the time when the error happened.
+	Time time.Time
+}
+
+// maxUnmappedGamepads is the maximum number of the recorded unmapped gamepads. Older records are discarded.
+const maxUnmappedGamepads = 16
+
+// UnmappedGamepad represents a connected gamepad that has neither a mapping in the gamepad database nor a mapping by the platform.
+type UnmappedGamepad struct {
+	// SDLID is the SDL-compatible GUID of the gamepad.
+	SDLID string
+
+	// Name is the name of the gamepad.
+	Name string
+
+	// AxisCount, ButtonCount, and HatCount are the numbers of the axes, the buttons, and the hats of the gamepad.
+	AxisCount   int
+	ButtonCount int
+	HatCount    int
+
+	// MappingTemplate is a line of SDL_GameControllerDB without mappings for the gamepad like "<GUID>,<Name>,platform:Linux,".
+	// The mappings can be filled e.g. with a remapping tool.
+	MappingTemplate string
+
+	// Time is the time when the gamepad was connected.
+	Time time.Time
+}
+
+// ConnectionEvent represents a connection or a disconnection of a gamepad.
+type ConnectionEvent struct {
+	// ID is the ID of the gamepad.
+	ID ID
+
+	// Name is the name of the gamepad.
+	Name string
+
+	// Connected is true if the gamepad is connected, and false if the gamepad is disconnected.
+	Connected bool
+
+	// Time is the time when the connection or the disconnection is detected.
+	Time time.Time
+}
+
 type nativeGamepads interface {
 	init(gamepads *gamepads) error
 	update(gamepads *gamepads) error
 }
 
+type disconnectedGamepad struct {
+	id   ID
+	key  string
+	time time.Time
+}
+
 var theGamepads = gamepads{
-	native: newNativeGamepadsImpl(),
+	native:                  newNativeGamepadsImpl(),
+	reconnectionGracePeriod: defaultReconnectionGracePeriod,
 }
 
 // AppendGamepadIDs is concurrent-safe.
@@ -67,10 +261,101 @@ func Get(id ID) *Gamepad {
 	return theGamepads.get(id)
 }
 
+// Terminate closes all the gamepads and releases the resources to detect gamepads.
+// After Terminate, Update detects gamepads again from scratch.
+//
+// Terminate is concurrent-safe.
+func Terminate() {
+	theGamepads.terminate()
+}
+
 func SetNativeWindow(nativeWindow uintptr) {
 	theGamepads.setNativeWindow(nativeWindow)
 }
 
+// AppendInaccessibleDevices is concurrent-safe.
+func AppendInaccessibleDevices(devices []InaccessibleDevice) []InaccessibleDevice {
+	return theGamepads.appendInaccessibleDevices(devices)
+}
+
+// AppendDeviceErrors is concurrent-safe.
+func AppendDeviceErrors(errs []DeviceError) []DeviceError {
+	return theGamepads.appendDeviceErrors(errs)
+}
+
+// AppendConnectionEvents is concurrent-safe.
+func AppendConnectionEvents(events []ConnectionEvent) []ConnectionEvent {
+	return theGamepads.appendConnectionEvents(events)
+}
+
+// SetExclusiveGrabEnabled is concurrent-safe.
+func SetExclusiveGrabEnabled(enabled bool) {
+	theGamepads.setExclusiveGrabEnabled(enabled)
+}
+
+// SetFocused is concurrent-safe.
+func SetFocused(focused bool) {
+	theGamepads.setFocused(focused)
+}
+
+// SetVirtualGamepadElements is concurrent-safe.
+func SetVirtualGamepadElements(elements VirtualGamepadElement) {
+	theGamepads.setVirtualGamepadElements(elements)
+}
+
+// SetVirtualGamepadElementZone is concurrent-safe.
+func SetVirtualGamepadElementZone(element VirtualGamepadElement, zone VirtualGamepadZone) {
+	theGamepads.setVirtualGamepadElementZone(element, zone)
+}
+
+// SetVirtualGamepadTouches sets the current touches for a touch-driven virtual gamepad.
+// width and height are the size of the screen in the same unit as the touches' positions.
+//
+// SetVirtualGamepadTouches is concurrent-safe.
+func SetVirtualGamepadTouches(touches []VirtualGamepadTouch, width, height float64) {
+	theGamepads.setVirtualGamepadTouches(touches, width, height)
+}
+
+// SetVibrationGain is concurrent-safe.
+func SetVibrationGain(gain float64) {
+	theGamepads.setVibrationGain(gain)
+}
+
+// StopAllVibration is concurrent-safe.
+func StopAllVibration() {
+	theGamepads.stopAllVibration()
+}
+
+// SetReconnectionGracePeriod is concurrent-safe.
+func SetReconnectionGracePeriod(period time.Duration) {
+	theGamepads.setReconnectionGracePeriod(period)
+}
+
+// SetAxisDeadZoneEnabled is concurrent-safe.
+func SetAxisDeadZoneEnabled(enabled bool) {
+	theGamepads.setAxisDeadZoneEnabled(enabled)
+}
+
+// SetTriggerAxisRangeZeroToOne is concurrent-safe.
+func SetTriggerAxisRangeZeroToOne(enabled bool) {
+	theGamepads.setTriggerAxisRangeZeroToOne(enabled)
+}
+
+// SetHeuristicMappingEnabled is concurrent-safe.
+func SetHeuristicMappingEnabled(enabled bool) {
+	theGamepads.setHeuristicMappingEnabled(enabled)
+}
+
+// SetMicroGamepadEnabled is concurrent-safe.
+func SetMicroGamepadEnabled(enabled bool) {
+	theGamepads.setMicroGamepadEnabled(enabled)
+}
+
+// RequestEnhancedAccess is concurrent-safe.
+func RequestEnhancedAccess() {
+	theGamepads.requestEnhancedAccess()
+}
+
 func (g *gamepads) appendGamepadIDs(ids []ID) []ID {
 	g.m.Lock()
 	defer g.m.Unlock()
@@ -94,23 +379,42 @@ func (g *gamepads) update() error {
 		g.inited = true
 	}
 
+	// Reload the modified mapping files before updating the gamepads, so that the gamepads use the new mappings in this tick.
+	gamepaddb.UpdateWatchedFiles()
+
 	if err := g.native.update(g); err != nil {
 		return err
 	}
+	g.updateVirtualGamepad()
 
-	// A gamepad can be detected even though there are not. Apparently, some special devices are
-	// recognized as gamepads by OSes. In this case, the number of the 'buttons' can exceed the
-	// maximum. Skip such devices as a tentative solution (#1173, #2039).
-	g.remove(func(gamepad *Gamepad) bool {
-		return gamepad.ButtonCount() > ButtonCount
-	})
+	g.removeGamepadsWithTooManyButtons()
+
+	if err := g.updateGamepads(); err != nil {
+		return err
+	}
+
+	g.flushConnectionEvents()
+	return nil
+}
 
+func (g *gamepads) updateGamepads() error {
 	for _, gp := range g.gamepads {
 		if gp == nil {
 			continue
 		}
 		if err := gp.update(g); err != nil {
-			return err
+			// An error of one gamepad must not stop the game. Close and remove the gamepad, and record the error.
+			if !errors.Is(err, errDisconnected) {
+				g.addDeviceError(DeviceError{
+					Path: gp.devicePath(),
+					Name: gp.Name(),
+					Err:  err,
+				})
+				gp.close()
+			}
+			g.remove(func(gamepad *Gamepad) bool {
+				return gamepad == gp
+			})
 		}
 	}
 	return nil
@@ -139,25 +443,111 @@ func (g *gamepads) find(cond func(*Gamepad) bool) *Gamepad {
 }
 
 func (g *gamepads) add(name, sdlID string) *Gamepad {
-	for i, gp := range g.gamepads {
-		if gp == nil {
-			gp := &Gamepad{
-				name:  name,
-				sdlID: sdlID,
+	return g.addWithReconnectionKey(name, sdlID, "")
+}
+
+// addWithReconnectionKey adds a new gamepad.
+// key identifies a physical gamepad. If a gamepad with the same key was disconnected recently, its ID is reused.
+// If key is empty, a new ID is always used.
+func (g *gamepads) addWithReconnectionKey(name, sdlID, key string) *Gamepad {
+	gp := &Gamepad{
+		name:            name,
+		sdlID:           sdlID,
+		reconnectionKey: key,
+	}
+
+	g.forgetDisconnectedGamepads()
+
+	if key != "" {
+		for i, d := range g.disconnected {
+			if d.key != key {
+				continue
 			}
-			g.gamepads[i] = gp
+			g.disconnected = append(g.disconnected[:i], g.disconnected[i+1:]...)
+			g.gamepads[d.id] = gp
+			gp.initPlayerIndex(d.id)
+			g.addConnectionEvent(d.id, gp, true)
 			return gp
 		}
 	}
 
-	gp := &Gamepad{
-		name:  name,
-		sdlID: sdlID,
+	for i, gp2 := range g.gamepads {
+		if gp2 != nil {
+			continue
+		}
+		if g.isReserved(ID(i)) {
+			continue
+		}
+		g.gamepads[i] = gp
+		gp.initPlayerIndex(ID(i))
+		g.addConnectionEvent(ID(i), gp, true)
+		return gp
 	}
+
 	g.gamepads = append(g.gamepads, gp)
+	gp.initPlayerIndex(ID(len(g.gamepads) - 1))
+	g.addConnectionEvent(ID(len(g.gamepads)-1), gp, true)
 	return gp
 }
 
+func (g *gamepads) addConnectionEvent(id ID, gp *Gamepad, connected bool) {
+	// A gamepad removed just after being added, e.g., a device that is not actually a gamepad, is not reported at all.
+	if !connected {
+		for i := len(g.pendingConnectionEvents) - 1; i >= 0; i-- {
+			e := g.pendingConnectionEvents[i]
+			if e.ID != id {
+				continue
+			}
+			if e.Connected {
+				g.pendingConnectionEvents = append(g.pendingConnectionEvents[:i], g.pendingConnectionEvents[i+1:]...)
+				return
+			}
+			break
+		}
+	}
+
+	g.pendingConnectionEvents = append(g.pendingConnectionEvents, ConnectionEvent{
+		ID:        id,
+		Name:      gp.Name(),
+		Connected: connected,
+		Time:      time.Now(),
+	})
+}
+
+// flushConnectionEvents makes the pending connection events visible until the next update.
+func (g *gamepads) flushConnectionEvents() {
+	g.connectionEvents = append(g.connectionEvents[:0], g.pendingConnectionEvents...)
+	g.pendingConnectionEvents = g.pendingConnectionEvents[:0]
+}
+
+func (g *gamepads) appendConnectionEvents(events []ConnectionEvent) []ConnectionEvent {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return append(events, g.connectionEvents...)
+}
+
+func (g *gamepads) removeGamepadsWithTooManyButtons() {
+	// A gamepad can be detected even though there are not. Apparently, some special devices are
+	// recognized as gamepads by OSes. In this case, the number of the 'buttons' can exceed the
+	// maximum. Skip such devices as a tentative solution (#1173, #2039).
+	// The maximum is not ButtonCount, as arcade encoders and flight panels can have more buttons.
+	// The device is closed, and is not opened again by the next scan until it is disconnected.
+	g.remove(func(gamepad *Gamepad) bool {
+		if gamepad.ButtonCount() <= maxButtonCount {
+			return false
+		}
+		debug.Logf("gamepad: %s has more than %d buttons and is ignored\n", gamepad.Name(), maxButtonCount)
+		gamepad.close()
+		if path := gamepad.devicePath(); path != "" {
+			if n, ok := g.native.(interface{ ignore(path string) }); ok {
+				n.ignore(path)
+			}
+		}
+		return true
+	})
+}
+
 func (g *gamepads) remove(cond func(*Gamepad) bool) {
 	for i, gp := range g.gamepads {
 		if gp == nil {
@@ -165,151 +555,914 @@ func (g *gamepads) remove(cond func(*Gamepad) bool) {
 		}
 		if cond(gp) {
 			g.gamepads[i] = nil
+			g.addConnectionEvent(ID(i), gp, false)
+			if gp.reconnectionKey != "" && g.reconnectionGracePeriod > 0 {
+				g.disconnected = append(g.disconnected, disconnectedGamepad{
+					id:   ID(i),
+					key:  gp.reconnectionKey,
+					time: time.Now(),
+				})
+			}
 		}
 	}
 }
 
-func (g *gamepads) setNativeWindow(nativeWindow uintptr) {
-	g.m.Lock()
-	defer g.m.Unlock()
-
-	var n any = g.native
-	if n, ok := n.(interface{ setNativeWindow(uintptr) }); ok {
-		n.setNativeWindow(nativeWindow)
+func (g *gamepads) isReserved(id ID) bool {
+	for _, d := range g.disconnected {
+		if d.id == id {
+			return true
+		}
 	}
+	return false
 }
 
-type Gamepad struct {
-	name  string
-	sdlID string
-	m     sync.Mutex
-
-	native nativeGamepad
+func (g *gamepads) forgetDisconnectedGamepads() {
+	now := time.Now()
+	var n int
+	for _, d := range g.disconnected {
+		if now.Sub(d.time) >= g.reconnectionGracePeriod {
+			continue
+		}
+		g.disconnected[n] = d
+		n++
+	}
+	g.disconnected = g.disconnected[:n]
 }
 
-type mappingInput interface {
-	Pressed() bool
-	Value() float64 // Normalized to range: 0..1.
-}
+func (g *gamepads) appendInaccessibleDevices(devices []InaccessibleDevice) []InaccessibleDevice {
+	g.m.Lock()
+	defer g.m.Unlock()
 
-type axisMappingInput struct {
-	g    nativeGamepad
-	axis int
+	return append(devices, g.inaccessibleDevices...)
 }
 
-func (a axisMappingInput) Pressed() bool {
-	return a.g.axisValue(a.axis) > gamepaddb.ButtonPressedThreshold
+// addInaccessibleDevice records the device that cannot be opened. The existing record for the same path is replaced.
+func (g *gamepads) addInaccessibleDevice(device InaccessibleDevice) {
+	for i, d := range g.inaccessibleDevices {
+		if d.Path == device.Path {
+			g.inaccessibleDevices[i] = device
+			return
+		}
+	}
+	g.inaccessibleDevices = append(g.inaccessibleDevices, device)
 }
 
-func (a axisMappingInput) Value() float64 {
-	return a.g.axisValue(a.axis)*0.5 + 0.5
+// removeInaccessibleDevice removes the record of the device at path, e.g. when the device is opened or disconnected.
+func (g *gamepads) removeInaccessibleDevice(path string) {
+	for i, d := range g.inaccessibleDevices {
+		if d.Path == path {
+			g.inaccessibleDevices = append(g.inaccessibleDevices[:i], g.inaccessibleDevices[i+1:]...)
+			return
+		}
+	}
 }
 
-type buttonMappingInput struct {
-	g      nativeGamepad
-	button int
-}
+func (g *gamepads) appendDeviceErrors(errs []DeviceError) []DeviceError {
+	g.m.Lock()
+	defer g.m.Unlock()
 
-func (b buttonMappingInput) Pressed() bool {
-	return b.g.isButtonPressed(b.button)
+	return append(errs, g.deviceErrors...)
 }
 
-func (b buttonMappingInput) Value() float64 {
-	return b.g.buttonValue(b.button)
+// AppendUnmappedGamepads is concurrent-safe.
+func AppendUnmappedGamepads(gamepads []UnmappedGamepad) []UnmappedGamepad {
+	return theGamepads.appendUnmappedGamepads(gamepads)
 }
 
-type hatMappingInput struct {
-	g         nativeGamepad
-	hat       int
-	direction int
-}
+func (g *gamepads) appendUnmappedGamepads(gamepads []UnmappedGamepad) []UnmappedGamepad {
+	g.m.Lock()
+	defer g.m.Unlock()
 
-func (h hatMappingInput) Pressed() bool {
-	return h.g.hatState(h.hat)&h.direction != 0
+	return append(gamepads, g.unmappedGamepads...)
 }
 
-func (h hatMappingInput) Value() float64 {
-	if h.Pressed() {
-		return 1
+// addUnmappedGamepad records the unmapped gamepad. A gamepad with the same GUID is recorded only once.
+func (g *gamepads) addUnmappedGamepad(gamepad UnmappedGamepad) {
+	for _, u := range g.unmappedGamepads {
+		if u.SDLID == gamepad.SDLID {
+			return
+		}
 	}
-	return 0
+	if gamepad.Time.IsZero() {
+		gamepad.Time = time.Now()
+	}
+	if len(g.unmappedGamepads) >= maxUnmappedGamepads {
+		n := copy(g.unmappedGamepads, g.unmappedGamepads[len(g.unmappedGamepads)-maxUnmappedGamepads+1:])
+		g.unmappedGamepads = g.unmappedGamepads[:n]
+	}
+	g.unmappedGamepads = append(g.unmappedGamepads, gamepad)
 }
 
-type nativeGamepad interface {
-	update(gamepads *gamepads) error
-	hasOwnStandardLayoutMapping() bool
-	standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput
-	standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput
-	axisCount() int
-	buttonCount() int
-	hatCount() int
-	axisValue(axis int) float64
-	buttonValue(button int) float64
-	isButtonPressed(button int) bool
-	hatState(hat int) int
-	vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64)
+// addDeviceError records the error of the device.
+func (g *gamepads) addDeviceError(err DeviceError) {
+	if err.Time.IsZero() {
+		err.Time = time.Now()
+	}
+	if len(g.deviceErrors) >= maxDeviceErrors {
+		n := copy(g.deviceErrors, g.deviceErrors[len(g.deviceErrors)-maxDeviceErrors+1:])
+		g.deviceErrors = g.deviceErrors[:n]
+	}
+	g.deviceErrors = append(g.deviceErrors, err)
 }
 
-func (g *Gamepad) update(gamepads *gamepads) error {
+func (g *gamepads) setReconnectionGracePeriod(period time.Duration) {
 	g.m.Lock()
 	defer g.m.Unlock()
 
-	return g.native.update(gamepads)
+	g.reconnectionGracePeriod = period
+	g.forgetDisconnectedGamepads()
 }
 
-// Name is concurrent-safe.
-func (g *Gamepad) Name() string {
-	// This is immutable and doesn't have to be protected by a mutex.
-	if name := gamepaddb.Name(g.sdlID); name != "" {
-		return name
-	}
-	return g.name
-}
+func (g *gamepads) setAxisDeadZoneEnabled(enabled bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
 
-// SDLID is concurrent-safe.
-func (g *Gamepad) SDLID() string {
-	// This is immutable and doesn't have to be protected by a mutex.
-	return g.sdlID
+	g.axisDeadZoneDisabled = !enabled
 }
 
-// AxisCount is concurrent-safe.
-func (g *Gamepad) AxisCount() int {
+func (g *gamepads) setTriggerAxisRangeZeroToOne(enabled bool) {
 	g.m.Lock()
 	defer g.m.Unlock()
 
-	return g.native.axisCount()
+	g.triggerAxisZeroToOne = enabled
 }
 
-// ButtonCount is concurrent-safe.
-func (g *Gamepad) ButtonCount() int {
+func (g *gamepads) setHeuristicMappingEnabled(enabled bool) {
 	g.m.Lock()
 	defer g.m.Unlock()
 
-	return g.native.buttonCount()
+	g.heuristicMappingEnabled = enabled
 }
 
-// HatCount is concurrent-safe.
-func (g *Gamepad) HatCount() int {
+func (g *gamepads) setMicroGamepadEnabled(enabled bool) {
 	g.m.Lock()
 	defer g.m.Unlock()
 
-	return g.native.hatCount()
+	g.microGamepadEnabled = enabled
 }
 
-// Axis is concurrent-safe.
-func (g *Gamepad) Axis(axis int) float64 {
+func (g *gamepads) setVirtualGamepadElements(elements VirtualGamepadElement) {
 	g.m.Lock()
 	defer g.m.Unlock()
 
-	return g.native.axisValue(axis)
+	g.virtualGamepadElements = elements
+	g.updateVirtualGamepad()
 }
 
-// Button is concurrent-safe.
-func (g *Gamepad) Button(button int) bool {
+func (g *gamepads) setVirtualGamepadElementZone(element VirtualGamepadElement, zone VirtualGamepadZone) {
 	g.m.Lock()
 	defer g.m.Unlock()
 
-	return g.native.isButtonPressed(button)
+	if g.virtualGamepadTouchState.zones == nil {
+		g.virtualGamepadTouchState.zones = map[VirtualGamepadElement]VirtualGamepadZone{}
+	}
+	g.virtualGamepadTouchState.zones[element] = zone
+}
+
+func (g *gamepads) setVirtualGamepadTouches(touches []VirtualGamepadTouch, width, height float64) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.virtualGamepadTouchState.touches = append(g.virtualGamepadTouchState.touches[:0], touches...)
+	g.virtualGamepadTouchState.width = width
+	g.virtualGamepadTouchState.height = height
+}
+
+// updateVirtualGamepad shows or hides the on-screen virtual gamepad based on the current state.
+func (g *gamepads) updateVirtualGamepad() {
+	// The native implementation must be initialized so that the virtual gamepad's connection is observed.
+	if !g.inited {
+		return
+	}
+	var n any = g.native
+	if n, ok := n.(interface{ updateVirtualGamepad(gamepads *gamepads) }); ok {
+		n.updateVirtualGamepad(g)
+	}
+}
+
+func (g *gamepads) setExclusiveGrabEnabled(enabled bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.exclusiveGrab = enabled
+	g.updateExclusiveGrab()
+}
+
+func (g *gamepads) setFocused(focused bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.unfocused = !focused
+	g.updateExclusiveGrab()
+	g.updateVirtualGamepad()
+
+	// Vibrations must not continue while the player is away from the game.
+	if !focused {
+		g.stopAllVibrationLocked()
+	}
+}
+
+func (g *gamepads) setVibrationGain(gain float64) {
+	if gain < 0 {
+		gain = 0
+	}
+	if gain > 1 {
+		gain = 1
+	}
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.vibrationAttenuation = 1 - gain
+
+	// Apply the gain immediately so that the playing vibrations are attenuated.
+	for _, gp := range g.gamepads {
+		if gp == nil {
+			continue
+		}
+		gp.m.Lock()
+		gp.setVibrationAttenuation(g.vibrationAttenuation)
+		gp.m.Unlock()
+	}
+}
+
+func (g *gamepads) stopAllVibration() {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.stopAllVibrationLocked()
+}
+
+func (g *gamepads) stopAllVibrationLocked() {
+	for _, gp := range g.gamepads {
+		if gp == nil {
+			continue
+		}
+		gp.m.Lock()
+		gp.native.vibrate(0, 0, 0)
+		gp.m.Unlock()
+	}
+}
+
+// shouldGrabExclusively reports whether the gamepad devices should be grabbed exclusively now.
+// The devices are released while the window is not focused so that other applications can use them.
+func (g *gamepads) shouldGrabExclusively() bool {
+	return g.exclusiveGrab && !g.unfocused
+}
+
+// updateExclusiveGrab applies the exclusive grab state to the gamepads immediately,
+// as the gamepads might not be updated while the window is not focused.
+func (g *gamepads) updateExclusiveGrab() {
+	grab := g.shouldGrabExclusively()
+	for _, gp := range g.gamepads {
+		if gp == nil {
+			continue
+		}
+		gp.m.Lock()
+		var n any = gp.native
+		if n, ok := n.(interface{ setExclusiveGrab(grab bool) }); ok {
+			n.setExclusiveGrab(grab)
+		}
+		gp.m.Unlock()
+	}
+}
+
+func (g *gamepads) terminate() {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if !g.inited {
+		return
+	}
+
+	var n any = g.native
+	if n, ok := n.(interface{ terminate(gamepads *gamepads) }); ok {
+		n.terminate(g)
+	}
+	g.gamepads = nil
+	g.disconnected = nil
+	g.inaccessibleDevices = nil
+	g.deviceErrors = nil
+	g.unmappedGamepads = nil
+	g.connectionEvents = nil
+	g.pendingConnectionEvents = nil
+	g.inited = false
+}
+
+func (g *gamepads) setNativeWindow(nativeWindow uintptr) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	if n, ok := n.(interface{ setNativeWindow(uintptr) }); ok {
+		n.setNativeWindow(nativeWindow)
+	}
+}
+
+func (g *gamepads) requestEnhancedAccess() {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	if n, ok := n.(interface{ requestEnhancedAccess() }); ok {
+		n.requestEnhancedAccess()
+	}
+}
+
+type Gamepad struct {
+	name  string
+	sdlID string
+	m     sync.Mutex
+
+	reconnectionKey string
+
+	// playerIndex is the index shown by the player indicator LEDs. -1 means no index.
+	// playerIndexDirty is true if playerIndex has not been applied to the native gamepad yet.
+	playerIndex      int
+	playerIndexDirty bool
+
+	// triggerAxisZeroToOne is a copy of gamepads' triggerAxisZeroToOne, which is updated at every update.
+	triggerAxisZeroToOne bool
+
+	// heuristicMappingEnabled is a copy of gamepads' heuristicMappingEnabled, which is updated at every update.
+	heuristicMappingEnabled bool
+
+	// vibrationAttenuation is a copy of gamepads' vibrationAttenuation.
+	vibrationAttenuation float64
+
+	// calibration is the calibration of the axes, or nil if the gamepad is not calibrated.
+	// calibrationDirty is true if calibration has not been applied to the native gamepad and remembered by gamepads yet.
+	// calibrationLoaded is true if the calibration remembered by gamepads has been looked up.
+	calibration       *Calibration
+	calibrationDirty  bool
+	calibrationLoaded bool
+
+	// mappingChecked is true if whether the gamepad has a standard layout mapping has been checked.
+	mappingChecked bool
+
+	// standardButtonsPressed is the last results of IsStandardButtonPressed for the hysteresis of the buttons mapped from axes.
+	standardButtonsPressed [gamepaddb.StandardButtonMax + 1]bool
+
+	// override is the standard layout mapping used instead of the database and the native mapping, or nil.
+	// overridePersistent is true if override is applied again when the same gamepad is reconnected.
+	// overrideDirty is true if override has not been remembered or forgotten by gamepads yet.
+	// overrideLoaded is true if the override remembered by gamepads has been looked up.
+	override           *gamepaddb.Override
+	overridePersistent bool
+	overrideDirty      bool
+	overrideLoaded     bool
+
+	native nativeGamepad
+}
+
+type mappingInput interface {
+	Pressed() bool
+	Value() float64 // Normalized to range: 0..1.
+}
+
+type axisMappingInput struct {
+	g    nativeGamepad
+	axis int
+}
+
+func (a axisMappingInput) Pressed() bool {
+	return a.g.axisValue(a.axis) > gamepaddb.ButtonPressedThreshold
+}
+
+// pressedWithHysteresis is the same as Pressed, but the axis is kept pressed
+// until the value falls below the threshold by ButtonReleaseHysteresis, if wasPressed is true.
+func (a axisMappingInput) pressedWithHysteresis(wasPressed bool) bool {
+	threshold := gamepaddb.ButtonPressedThreshold
+	if wasPressed {
+		threshold -= gamepaddb.ButtonReleaseHysteresis
+	}
+	return a.g.axisValue(a.axis) > threshold
+}
+
+func (a axisMappingInput) Value() float64 {
+	return a.g.axisValue(a.axis)*0.5 + 0.5
+}
+
+type buttonMappingInput struct {
+	g      nativeGamepad
+	button int
+}
+
+func (b buttonMappingInput) Pressed() bool {
+	return b.g.isButtonPressed(b.button)
+}
+
+func (b buttonMappingInput) Value() float64 {
+	return b.g.buttonValue(b.button)
+}
+
+type hatMappingInput struct {
+	g         nativeGamepad
+	hat       int
+	direction int
+}
+
+func (h hatMappingInput) Pressed() bool {
+	return h.g.hatState(h.hat)&h.direction != 0
+}
+
+func (h hatMappingInput) Value() float64 {
+	if h.Pressed() {
+		return 1
+	}
+	return 0
+}
+
+type nativeGamepad interface {
+	update(gamepads *gamepads) error
+	hasOwnStandardLayoutMapping() bool
+	standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput
+	standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput
+	axisCount() int
+	buttonCount() int
+	hatCount() int
+	axisValue(axis int) float64
+	buttonValue(button int) float64
+	isButtonPressed(button int) bool
+	hatState(hat int) int
+	vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64)
+
+	// setPlayerIndex sets the index shown by the player indicator LEDs. A negative index turns the LEDs off.
+	// setPlayerIndex does nothing if the gamepad doesn't have such LEDs.
+	setPlayerIndex(index int)
+
+	// batteryLevel returns the battery level in percent. The second value is false if the battery level is unknown.
+	batteryLevel() (int, bool)
+	powerState() PowerState
+}
+
+// initPlayerIndex sets the initial player index of the gamepad based on its ID, i.e., in the connection order.
+func (g *Gamepad) initPlayerIndex(id ID) {
+	g.playerIndex = int(id)
+	g.playerIndexDirty = true
+}
+
+// restorePlayerIndex overrides the initial player index with the one remembered by the system.
+// restorePlayerIndex must be called just after the gamepad is added.
+func (g *Gamepad) restorePlayerIndex(index int) {
+	g.playerIndex = index
+	g.playerIndexDirty = true
+}
+
+func (g *Gamepad) update(gamepads *gamepads) error {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if g.playerIndexDirty {
+		g.native.setPlayerIndex(g.playerIndex)
+		g.playerIndexDirty = false
+	}
+
+	g.triggerAxisZeroToOne = gamepads.triggerAxisZeroToOne
+	g.heuristicMappingEnabled = gamepads.heuristicMappingEnabled
+	g.setVibrationAttenuation(gamepads.vibrationAttenuation)
+	g.updateCalibration(gamepads)
+	g.updateOverride(gamepads)
+
+	if err := g.native.update(gamepads); err != nil {
+		return err
+	}
+
+	if !g.mappingChecked {
+		g.checkMapping(gamepads)
+		g.mappingChecked = true
+	}
+	return nil
+}
+
+// checkMapping records the gamepad as an unmapped gamepad if the gamepad doesn't have a standard layout mapping.
+// checkMapping is called after the first update so that the numbers of the inputs are available.
+func (g *Gamepad) checkMapping(gamepads *gamepads) {
+	if g.sdlID == "" {
+		return
+	}
+	if g.hasStandardLayoutMappingInDB() || g.native.hasOwnStandardLayoutMapping() {
+		return
+	}
+	gamepads.addUnmappedGamepad(UnmappedGamepad{
+		SDLID:           g.sdlID,
+		Name:            g.name,
+		AxisCount:       g.native.axisCount(),
+		ButtonCount:     g.native.buttonCount(),
+		HatCount:        g.native.hatCount(),
+		MappingTemplate: gamepaddb.MappingTemplate(g.sdlID, g.name),
+	})
+}
+
+// updateOverride remembers or forgets the standard layout override by gamepads.
+// A gamepad reconnected after a persistent override is set gets the same override.
+func (g *Gamepad) updateOverride(gamepads *gamepads) {
+	key := calibrationKey(g.sdlID, g.Serial())
+
+	if !g.overrideLoaded {
+		if o, ok := gamepads.overrides[key]; ok {
+			g.override = o
+			g.overridePersistent = true
+		}
+		g.overrideLoaded = true
+	}
+
+	if !g.overrideDirty {
+		return
+	}
+	g.overrideDirty = false
+
+	if g.override != nil && g.overridePersistent {
+		if gamepads.overrides == nil {
+			gamepads.overrides = map[string]*gamepaddb.Override{}
+		}
+		gamepads.overrides[key] = g.override
+	} else {
+		delete(gamepads.overrides, key)
+	}
+}
+
+// SetStandardLayoutOverride sets the standard layout mapping used only for this gamepad. nil resets the override.
+// The override is preferred to the gamepad database and the native mapping, and takes effect immediately.
+// If persistent is true, the override is applied again when the same gamepad is reconnected.
+//
+// SetStandardLayoutOverride is concurrent-safe.
+func (g *Gamepad) SetStandardLayoutOverride(o *gamepaddb.Override, persistent bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.override = o
+	g.overridePersistent = persistent
+	g.overrideDirty = true
+	g.overrideLoaded = true
+}
+
+// standardLayoutOverride returns the standard layout override, or nil.
+func (g *Gamepad) standardLayoutOverride() *gamepaddb.Override {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.override
+}
+
+// updateCalibration applies the calibration to the native gamepad.
+// A gamepad reconnected after being calibrated gets the same calibration, as gamepads remembers the calibrations.
+func (g *Gamepad) updateCalibration(gamepads *gamepads) {
+	key := calibrationKey(g.sdlID, g.Serial())
+
+	if !g.calibrationLoaded {
+		if c, ok := gamepads.calibrations[key]; ok {
+			g.calibration = c.clone()
+			g.calibrationDirty = true
+		}
+		g.calibrationLoaded = true
+	}
+
+	if !g.calibrationDirty {
+		return
+	}
+	g.calibrationDirty = false
+
+	if g.calibration != nil {
+		if gamepads.calibrations == nil {
+			gamepads.calibrations = map[string]*Calibration{}
+		}
+		gamepads.calibrations[key] = g.calibration.clone()
+	} else {
+		delete(gamepads.calibrations, key)
+	}
+
+	var n any = g.native
+	if n, ok := n.(interface {
+		setCalibration(axes map[int]AxisCalibration)
+	}); ok {
+		var axes map[int]AxisCalibration
+		if g.calibration != nil {
+			axes = g.calibration.Axes
+		}
+		n.setCalibration(axes)
+	}
+}
+
+// setVibrationAttenuation sets the attenuation of vibrations, i.e., 1 minus the gain.
+// If the native gamepad can attenuate vibrations by itself e.g. at the device level, the gain is passed to the native gamepad.
+// Otherwise, the magnitudes are attenuated at Vibrate.
+func (g *Gamepad) setVibrationAttenuation(attenuation float64) {
+	g.vibrationAttenuation = attenuation
+	var n any = g.native
+	if n, ok := n.(interface{ setVibrationGain(gain float64) }); ok {
+		n.setVibrationGain(1 - attenuation)
+	}
+}
+
+// devicePath returns the path of the device file, or an empty string if the path is not available.
+func (g *Gamepad) devicePath() string {
+	// This is immutable and doesn't have to be protected by a mutex.
+	var n any = g.native
+	if n, ok := n.(interface{ devicePath() string }); ok {
+		return n.devicePath()
+	}
+	return ""
+}
+
+// close releases the resources of the device, e.g., after an error.
+func (g *Gamepad) close() {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	if n, ok := n.(interface{ close() }); ok {
+		n.close()
+	}
+}
+
+// Name is concurrent-safe.
+func (g *Gamepad) Name() string {
+	// This is immutable and doesn't have to be protected by a mutex.
+	if name := gamepaddb.Name(g.sdlID); name != "" {
+		return name
+	}
+	return g.name
+}
+
+// KernelName returns the name of the device reported by the OS kernel.
+// This might be different from Name, as Name can be resolved from other information like the gamepad database.
+// KernelName returns an empty string if the information is not available.
+//
+// KernelName is concurrent-safe.
+func (g *Gamepad) KernelName() string {
+	// This is immutable and doesn't have to be protected by a mutex.
+	var n any = g.native
+	if n, ok := n.(interface{ kernelName() string }); ok {
+		return n.kernelName()
+	}
+	return ""
+}
+
+// SDLID is concurrent-safe.
+func (g *Gamepad) SDLID() string {
+	// This is immutable and doesn't have to be protected by a mutex.
+	return g.sdlID
+}
+
+// Serial is concurrent-safe.
+func (g *Gamepad) Serial() string {
+	// This is immutable and doesn't have to be protected by a mutex.
+	var n any = g.native
+	if n, ok := n.(interface{ serial() string }); ok {
+		return n.serial()
+	}
+	return ""
+}
+
+// ButtonNativeCode returns the platform-specific code and its human-readable label of the button.
+//
+// ButtonNativeCode is concurrent-safe.
+func (g *Gamepad) ButtonNativeCode(button int) (code int, label string, ok bool) {
+	// This is immutable and doesn't have to be protected by a mutex.
+	var n any = g.native
+	if n, ok := n.(interface {
+		buttonNativeCode(button int) (int, string, bool)
+	}); ok {
+		return n.buttonNativeCode(button)
+	}
+	return 0, "", false
+}
+
+// AxisNativeCode returns the platform-specific code and its human-readable label of the axis.
+//
+// AxisNativeCode is concurrent-safe.
+func (g *Gamepad) AxisNativeCode(axis int) (code int, label string, ok bool) {
+	// This is immutable and doesn't have to be protected by a mutex.
+	var n any = g.native
+	if n, ok := n.(interface {
+		axisNativeCode(axis int) (int, string, bool)
+	}); ok {
+		return n.axisNativeCode(axis)
+	}
+	return 0, "", false
+}
+
+// BusType is concurrent-safe.
+func (g *Gamepad) BusType() BusType {
+	// This is immutable and doesn't have to be protected by a mutex.
+	var n any = g.native
+	if n, ok := n.(interface{ busType() BusType }); ok {
+		return n.busType()
+	}
+	return BusTypeUnknown
+}
+
+// LastActiveTime returns the time when a button, an axis, or a hat of the gamepad changed its state last time.
+// LastActiveTime returns the zero time if the state has never changed.
+// The second value is false if the information is not available.
+//
+// LastActiveTime is concurrent-safe.
+func (g *Gamepad) LastActiveTime() (time.Time, bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	if n, ok := n.(interface{ lastActive() time.Time }); ok {
+		return n.lastActive(), true
+	}
+	return time.Time{}, false
+}
+
+// IsMotionSensorAvailable is concurrent-safe.
+func (g *Gamepad) IsMotionSensorAvailable() bool {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	if n, ok := n.(interface{ hasMotionSensor() bool }); ok {
+		return n.hasMotionSensor()
+	}
+	return false
+}
+
+// MotionSensorValue is concurrent-safe.
+func (g *Gamepad) MotionSensorValue(axis MotionSensorAxis) float64 {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if axis < 0 || axis >= MotionSensorAxisCount {
+		return 0
+	}
+	var n any = g.native
+	if n, ok := n.(interface {
+		motionSensorValue(axis MotionSensorAxis) float64
+	}); ok {
+		return n.motionSensorValue(axis)
+	}
+	return 0
+}
+
+// IsTouchpadAvailable is concurrent-safe.
+func (g *Gamepad) IsTouchpadAvailable() bool {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	if n, ok := n.(interface{ hasTouchpad() bool }); ok {
+		return n.hasTouchpad()
+	}
+	return false
+}
+
+// IsTouchpadPressed is concurrent-safe.
+func (g *Gamepad) IsTouchpadPressed() bool {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	if n, ok := n.(interface{ isTouchpadPressed() bool }); ok {
+		return n.isTouchpadPressed()
+	}
+	return false
+}
+
+// TouchpadTouchPosition is concurrent-safe.
+func (g *Gamepad) TouchpadTouchPosition(index int) (x, y float64, ok bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	if n, ok := n.(interface {
+		touchpadTouchPosition(index int) (float64, float64, bool)
+	}); ok {
+		return n.touchpadTouchPosition(index)
+	}
+	return 0, 0, false
+}
+
+// AxisCount is concurrent-safe.
+func (g *Gamepad) AxisCount() int {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.native.axisCount()
+}
+
+// ButtonCount is concurrent-safe.
+func (g *Gamepad) ButtonCount() int {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.native.buttonCount()
+}
+
+// HatCount is concurrent-safe.
+func (g *Gamepad) HatCount() int {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.native.hatCount()
+}
+
+// Axis is concurrent-safe.
+func (g *Gamepad) Axis(axis int) float64 {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.native.axisValue(axis)
+}
+
+// AxisValue returns the axis value for users.
+// Unlike Axis, AxisValue returns a trigger axis value in [0, 1] when SetTriggerAxisRangeZeroToOne is enabled.
+// Axis is still used for the standard layout mappings, which assume [-1, 1].
+//
+// AxisValue is concurrent-safe.
+func (g *Gamepad) AxisValue(axis int) float64 {
+	v := g.Axis(axis)
+
+	g.m.Lock()
+	zeroToOne := g.triggerAxisZeroToOne
+	g.m.Unlock()
+
+	if zeroToOne && g.IsTriggerAxis(axis) {
+		return (v + 1) / 2
+	}
+	return v
+}
+
+// IsTriggerAxis reports whether the axis is an analog trigger, whose value is the minimum when the trigger is released.
+//
+// IsTriggerAxis is concurrent-safe.
+func (g *Gamepad) IsTriggerAxis(axis int) bool {
+	if o := g.standardLayoutOverride(); o != nil {
+		return o.IsTriggerAxis(axis)
+	}
+	// This is immutable and doesn't have to be protected by a mutex.
+	if g.hasStandardLayoutMappingInDB() {
+		return gamepaddb.IsTriggerAxis(g.sdlID, axis)
+	}
+	var n any = g.native
+	if n, ok := n.(interface{ isTriggerAxis(axis int) bool }); ok {
+		return n.isTriggerAxis(axis)
+	}
+	return false
+}
+
+// Button is concurrent-safe.
+func (g *Gamepad) Button(button int) bool {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.native.isButtonPressed(button)
+}
+
+// ButtonValue returns the value of the button in [0, 1]. The value is analog if the button is pressure-sensitive.
+//
+// ButtonValue is concurrent-safe.
+func (g *Gamepad) ButtonValue(button int) float64 {
+	if axis, ok := g.analogButtonAxis(button); ok {
+		// Adjust [-1, 1] to [0, 1].
+		return g.Axis(axis)*0.5 + 0.5
+	}
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.native.buttonValue(button)
+}
+
+// analogButtonAxis returns the axis reporting the analog value of the trigger button, if any.
+// The axis is resolved through the standard layout mapping in the same way as IsTriggerAxis, as the axis differs among devices.
+//
+// analogButtonAxis is concurrent-safe.
+func (g *Gamepad) analogButtonAxis(button int) (int, bool) {
+	// This is immutable and doesn't have to be protected by a mutex.
+	var n any = g.native
+	t, ok := n.(interface {
+		triggerButton(button int) (gamepaddb.StandardButton, bool)
+	})
+	if !ok {
+		return 0, false
+	}
+	b, ok := t.triggerButton(button)
+	if !ok {
+		return 0, false
+	}
+
+	if o := g.standardLayoutOverride(); o != nil {
+		return o.TriggerAxis(b)
+	}
+	if g.hasStandardLayoutMappingInDB() {
+		return gamepaddb.TriggerAxis(g.sdlID, b)
+	}
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	a, ok := g.standardButtonInNativeMapping(b).(axisMappingInput)
+	if !ok {
+		return 0, false
+	}
+	if n, ok := n.(interface{ isTriggerAxis(axis int) bool }); !ok || !n.isTriggerAxis(a.axis) {
+		return 0, false
+	}
+	return a.axis, true
 }
 
 // Hat is concurrent-safe.
@@ -325,10 +1478,42 @@ func (g *Gamepad) IsStandardLayoutAvailable() bool {
 	g.m.Lock()
 	defer g.m.Unlock()
 
-	if gamepaddb.HasStandardLayoutMapping(g.sdlID) {
+	if g.override != nil {
+		return g.override.HasStandardLayoutMapping()
+	}
+	if g.hasStandardLayoutMappingInDB() {
+		return true
+	}
+	if g.native.hasOwnStandardLayoutMapping() {
 		return true
 	}
-	return g.native.hasOwnStandardLayoutMapping()
+	return g.isHeuristicMappingUsed()
+}
+
+// IsStandardLayoutHeuristic reports whether the standard layout is available only by a guess from the native layout.
+// The guess might be wrong, and a game might want to suggest the player to check or remap the buttons.
+//
+// IsStandardLayoutHeuristic is concurrent-safe.
+func (g *Gamepad) IsStandardLayoutHeuristic() bool {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if g.override != nil {
+		return false
+	}
+	if g.hasStandardLayoutMappingInDB() {
+		return false
+	}
+	if g.native.hasOwnStandardLayoutMapping() {
+		return false
+	}
+	return g.isHeuristicMappingUsed()
+}
+
+// isHeuristicMappingUsed reports whether the heuristic mapping is enabled and the native layout looks like a gamepad's.
+// isHeuristicMappingUsed doesn't consider the other mappings.
+func (g *Gamepad) isHeuristicMappingUsed() bool {
+	return g.heuristicMappingEnabled && hasHeuristicStandardLayout(g.native)
 }
 
 // IsStandardAxisAvailable is concurrent safe.
@@ -336,10 +1521,20 @@ func (g *Gamepad) IsStandardAxisAvailable(axis gamepaddb.StandardAxis) bool {
 	g.m.Lock()
 	defer g.m.Unlock()
 
-	if gamepaddb.HasStandardLayoutMapping(g.sdlID) {
+	if g.override != nil {
+		return g.override.HasStandardAxis(axis)
+	}
+	if g.hasStandardLayoutMappingInDB() {
 		return gamepaddb.HasStandardAxis(g.sdlID, axis)
 	}
-	return g.native.standardAxisInOwnMapping(axis) != nil
+	if g.native.hasOwnStandardLayoutMapping() {
+		return g.native.standardAxisInOwnMapping(axis) != nil
+	}
+	// The heuristic mapping is not reported as available unless enabled explicitly, as it is just a guess.
+	if !g.isHeuristicMappingUsed() {
+		return false
+	}
+	return standardAxisInHeuristicMapping(g.native, axis, true) != nil
 }
 
 // IsStandardButtonAvailable is concurrent safe.
@@ -347,18 +1542,34 @@ func (g *Gamepad) IsStandardButtonAvailable(button gamepaddb.StandardButton) boo
 	g.m.Lock()
 	defer g.m.Unlock()
 
-	if gamepaddb.HasStandardLayoutMapping(g.sdlID) {
+	if g.override != nil {
+		return g.override.HasStandardButton(button)
+	}
+	if g.hasStandardLayoutMappingInDB() {
 		return gamepaddb.HasStandardButton(g.sdlID, button)
 	}
-	return g.native.standardButtonInOwnMapping(button) != nil
+	if g.native.hasOwnStandardLayoutMapping() {
+		return g.native.standardButtonInOwnMapping(button) != nil
+	}
+	// The heuristic mapping is not reported as available unless enabled explicitly, as it is just a guess.
+	if !g.isHeuristicMappingUsed() {
+		return false
+	}
+	return standardButtonInHeuristicMapping(g.native, button, true) != nil
 }
 
 // StandardAxisValue is concurrent-safe.
 func (g *Gamepad) StandardAxisValue(axis gamepaddb.StandardAxis) float64 {
-	if gamepaddb.HasStandardLayoutMapping(g.sdlID) {
+	if o := g.standardLayoutOverride(); o != nil {
+		return o.AxisValue(axis, g)
+	}
+	if g.hasStandardLayoutMappingInDB() {
 		return gamepaddb.AxisValue(g.sdlID, axis, g)
 	}
-	if m := g.native.standardAxisInOwnMapping(axis); m != nil {
+	g.m.Lock()
+	m := g.standardAxisInNativeMapping(axis)
+	g.m.Unlock()
+	if m != nil {
 		return m.Value()*2 - 1
 	}
 	return 0
@@ -366,10 +1577,16 @@ func (g *Gamepad) StandardAxisValue(axis gamepaddb.StandardAxis) float64 {
 
 // StandardButtonValue is concurrent-safe.
 func (g *Gamepad) StandardButtonValue(button gamepaddb.StandardButton) float64 {
-	if gamepaddb.HasStandardLayoutMapping(g.sdlID) {
+	if o := g.standardLayoutOverride(); o != nil {
+		return o.ButtonValue(button, g)
+	}
+	if g.hasStandardLayoutMappingInDB() {
 		return gamepaddb.ButtonValue(g.sdlID, button, g)
 	}
-	if m := g.native.standardButtonInOwnMapping(button); m != nil {
+	g.m.Lock()
+	m := g.standardButtonInNativeMapping(button)
+	g.m.Unlock()
+	if m != nil {
 		return m.Value()
 	}
 	return 0
@@ -377,19 +1594,442 @@ func (g *Gamepad) StandardButtonValue(button gamepaddb.StandardButton) float64 {
 
 // IsStandardButtonPressed is concurrent-safe.
 func (g *Gamepad) IsStandardButtonPressed(button gamepaddb.StandardButton) bool {
-	if gamepaddb.HasStandardLayoutMapping(g.sdlID) {
-		return gamepaddb.IsButtonPressed(g.sdlID, button, g)
+	if o := g.standardLayoutOverride(); o != nil {
+		return g.updateStandardButtonPressed(button, func(wasPressed bool) bool {
+			return o.IsButtonPressed(button, g, wasPressed)
+		})
 	}
-	if m := g.native.standardButtonInOwnMapping(button); m != nil {
+	if g.hasStandardLayoutMappingInDB() {
+		return g.updateStandardButtonPressed(button, func(wasPressed bool) bool {
+			return gamepaddb.IsButtonPressedWithHysteresis(g.sdlID, button, g, wasPressed)
+		})
+	}
+	g.m.Lock()
+	m := g.standardButtonInNativeMapping(button)
+	g.m.Unlock()
+	if a, ok := m.(axisMappingInput); ok {
+		return g.updateStandardButtonPressed(button, a.pressedWithHysteresis)
+	}
+	if m != nil {
 		return m.Pressed()
 	}
 	return false
 }
 
+// updateStandardButtonPressed returns the result of isPressed with the last result for the standard button, and remembers the result.
+// This gives hysteresis to a standard button mapped from an axis.
+func (g *Gamepad) updateStandardButtonPressed(button gamepaddb.StandardButton, isPressed func(wasPressed bool) bool) bool {
+	if button < 0 || button > gamepaddb.StandardButtonMax {
+		return false
+	}
+
+	g.m.Lock()
+	wasPressed := g.standardButtonsPressed[button]
+	g.m.Unlock()
+
+	// isPressed cannot be called with g.m locked, as isPressed accesses the gamepad state via g.
+	pressed := isPressed(wasPressed)
+
+	g.m.Lock()
+	g.standardButtonsPressed[button] = pressed
+	g.m.Unlock()
+
+	return pressed
+}
+
+// StandardLayoutMapping returns the standard layout mapping in use in the format of SDL_GameControllerDB,
+// or an empty string if the gamepad doesn't have the standard layout.
+//
+// StandardLayoutMapping is concurrent-safe.
+func (g *Gamepad) StandardLayoutMapping() string {
+	if g.sdlID == "" {
+		return ""
+	}
+	if o := g.standardLayoutOverride(); o != nil {
+		if !o.HasStandardLayoutMapping() {
+			return ""
+		}
+		return o.MappingString(g.sdlID, g.name)
+	}
+	if g.hasStandardLayoutMappingInDB() {
+		return gamepaddb.MappingString(g.sdlID)
+	}
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if !g.native.hasOwnStandardLayoutMapping() && !g.isHeuristicMappingUsed() {
+		return ""
+	}
+
+	m := &gamepaddb.Mapping{
+		Buttons: map[gamepaddb.StandardButton]gamepaddb.Input{},
+		Axes:    map[gamepaddb.StandardAxis]gamepaddb.Input{},
+	}
+	for b := gamepaddb.StandardButton(0); b <= gamepaddb.StandardButtonMax; b++ {
+		if in, ok := toDBInput(g.standardButtonInNativeMapping(b)); ok {
+			m.Buttons[b] = in
+		}
+	}
+	for a := gamepaddb.StandardAxis(0); a <= gamepaddb.StandardAxisMax; a++ {
+		if in, ok := toDBInput(g.standardAxisInNativeMapping(a)); ok {
+			m.Axes[a] = in
+		}
+	}
+	str, err := gamepaddb.FormatMapping(g.sdlID, g.name, m)
+	if err != nil {
+		return ""
+	}
+	return str
+}
+
+// toDBInput converts the mapping input to the gamepad database's input.
+func toDBInput(input mappingInput) (gamepaddb.Input, bool) {
+	switch input := input.(type) {
+	case axisMappingInput:
+		return gamepaddb.Input{
+			Type:  gamepaddb.InputTypeAxis,
+			Index: input.axis,
+		}, true
+	case buttonMappingInput:
+		return gamepaddb.Input{
+			Type:  gamepaddb.InputTypeButton,
+			Index: input.button,
+		}, true
+	case hatMappingInput:
+		return gamepaddb.Input{
+			Type:     gamepaddb.InputTypeHat,
+			Index:    input.hat,
+			HatState: input.direction,
+		}, true
+	}
+	return gamepaddb.Input{}, false
+}
+
+// hasStandardLayoutMappingInDB reports whether the gamepad database's standard layout mapping is used for the gamepad.
+//
+// The database is not used when the platform already normalizes the inputs to the standard layout,
+// like a browser's gamepad with the "standard" mapping, as the database's mapping would be applied twice.
+func (g *Gamepad) hasStandardLayoutMappingInDB() bool {
+	var n any = g.native
+	if n, ok := n.(interface{ isNormalizedToStandardLayout() bool }); ok && n.isNormalizedToStandardLayout() {
+		return false
+	}
+	return gamepaddb.HasStandardLayoutMapping(g.sdlID)
+}
+
+// standardAxisInNativeMapping returns the input of the standard axis when the gamepad database doesn't have a mapping.
+// standardAxisInNativeMapping must be called with g.m locked.
+func (g *Gamepad) standardAxisInNativeMapping(axis gamepaddb.StandardAxis) mappingInput {
+	if g.native.hasOwnStandardLayoutMapping() {
+		return g.native.standardAxisInOwnMapping(axis)
+	}
+	return standardAxisInHeuristicMapping(g.native, axis, g.heuristicMappingEnabled)
+}
+
+// standardButtonInNativeMapping returns the input of the standard button when the gamepad database doesn't have a mapping.
+// standardButtonInNativeMapping must be called with g.m locked.
+func (g *Gamepad) standardButtonInNativeMapping(button gamepaddb.StandardButton) mappingInput {
+	if g.native.hasOwnStandardLayoutMapping() {
+		return g.native.standardButtonInOwnMapping(button)
+	}
+	return standardButtonInHeuristicMapping(g.native, button, g.heuristicMappingEnabled)
+}
+
+// hasHeuristicStandardLayout reports whether the native layout looks like a gamepad's enough to guess the standard layout.
+func hasHeuristicStandardLayout(n nativeGamepad) bool {
+	return n.buttonCount() >= 4 && (n.axisCount() >= 2 || n.hatCount() > 0)
+}
+
+// heuristicTriggersAreButtons reports whether the triggers are guessed as buttons rather than axes.
+// Gamepads without separate trigger axes, like many DirectInput gamepads, usually report the triggers as the buttons 6 and 7.
+func heuristicTriggersAreButtons(n nativeGamepad) bool {
+	return n.axisCount() < 6 && n.buttonCount() >= 10
+}
+
+// standardAxisInHeuristicMapping returns the input of the standard axis guessed from the native layout,
+// or nil if the axis cannot be guessed.
+//
+// This is a heuristic for unknown gamepads, similar to browsers' standard mapping:
+// the axes 0 and 1 are treated as the left stick.
+// If full is true, the other axes are guessed in the Xbox controller's convention:
+// the axes 3 and 4 are treated as the right stick, or the axes 2 and 3 if there are only 4 axes.
+func standardAxisInHeuristicMapping(n nativeGamepad, axis gamepaddb.StandardAxis, full bool) mappingInput {
+	var index int
+	switch axis {
+	case gamepaddb.StandardAxisLeftStickHorizontal:
+		index = 0
+	case gamepaddb.StandardAxisLeftStickVertical:
+		index = 1
+	case gamepaddb.StandardAxisRightStickHorizontal, gamepaddb.StandardAxisRightStickVertical:
+		if !full || n.axisCount() < 4 {
+			return nil
+		}
+		// With 5 or more axes, the axis 2 is a trigger.
+		index = 3
+		if n.axisCount() == 4 {
+			index = 2
+		}
+		if axis == gamepaddb.StandardAxisRightStickVertical {
+			index++
+		}
+	default:
+		return nil
+	}
+	if index >= n.axisCount() {
+		return nil
+	}
+	return axisMappingInput{g: n, axis: index}
+}
+
+// heuristicButtons is the native buttons of the standard buttons in the Xbox controller's convention,
+// used when the triggers are axes.
+var heuristicButtons = map[gamepaddb.StandardButton]int{
+	gamepaddb.StandardButtonRightBottom:   0,
+	gamepaddb.StandardButtonRightRight:    1,
+	gamepaddb.StandardButtonRightLeft:     2,
+	gamepaddb.StandardButtonRightTop:      3,
+	gamepaddb.StandardButtonFrontTopLeft:  4,
+	gamepaddb.StandardButtonFrontTopRight: 5,
+	gamepaddb.StandardButtonCenterLeft:    6,
+	gamepaddb.StandardButtonCenterRight:   7,
+	gamepaddb.StandardButtonLeftStick:     8,
+	gamepaddb.StandardButtonRightStick:    9,
+}
+
+// heuristicButtonsWithGuide is the same as heuristicButtons, but for gamepads with the guide button like Linux's xpad driver.
+var heuristicButtonsWithGuide = map[gamepaddb.StandardButton]int{
+	gamepaddb.StandardButtonRightBottom:   0,
+	gamepaddb.StandardButtonRightRight:    1,
+	gamepaddb.StandardButtonRightLeft:     2,
+	gamepaddb.StandardButtonRightTop:      3,
+	gamepaddb.StandardButtonFrontTopLeft:  4,
+	gamepaddb.StandardButtonFrontTopRight: 5,
+	gamepaddb.StandardButtonCenterLeft:    6,
+	gamepaddb.StandardButtonCenterRight:   7,
+	gamepaddb.StandardButtonCenterCenter:  8,
+	gamepaddb.StandardButtonLeftStick:     9,
+	gamepaddb.StandardButtonRightStick:    10,
+}
+
+// heuristicButtonsWithTriggerButtons is the same as heuristicButtons, but for gamepads reporting the triggers as buttons.
+var heuristicButtonsWithTriggerButtons = map[gamepaddb.StandardButton]int{
+	gamepaddb.StandardButtonRightBottom:      0,
+	gamepaddb.StandardButtonRightRight:       1,
+	gamepaddb.StandardButtonRightLeft:        2,
+	gamepaddb.StandardButtonRightTop:         3,
+	gamepaddb.StandardButtonFrontTopLeft:     4,
+	gamepaddb.StandardButtonFrontTopRight:    5,
+	gamepaddb.StandardButtonFrontBottomLeft:  6,
+	gamepaddb.StandardButtonFrontBottomRight: 7,
+	gamepaddb.StandardButtonCenterLeft:       8,
+	gamepaddb.StandardButtonCenterRight:      9,
+	gamepaddb.StandardButtonLeftStick:        10,
+	gamepaddb.StandardButtonRightStick:       11,
+}
+
+// standardButtonInHeuristicMapping returns the input of the standard button guessed from the native layout,
+// or nil if the button cannot be guessed.
+//
+// This is a heuristic for unknown gamepads: the hat 0 is treated as the D-pad.
+// If full is true, the other buttons are guessed in the Xbox controller's convention:
+// the buttons 0-3 are A, B, X, and Y, and the triggers are the axes 2 and 5 if there are 6 or more axes.
+func standardButtonInHeuristicMapping(n nativeGamepad, button gamepaddb.StandardButton, full bool) mappingInput {
+	switch button {
+	case gamepaddb.StandardButtonLeftTop:
+		return heuristicDPadInput(n, hatUp)
+	case gamepaddb.StandardButtonLeftBottom:
+		return heuristicDPadInput(n, hatDown)
+	case gamepaddb.StandardButtonLeftLeft:
+		return heuristicDPadInput(n, hatLeft)
+	case gamepaddb.StandardButtonLeftRight:
+		return heuristicDPadInput(n, hatRight)
+	}
+
+	if !full {
+		return nil
+	}
+
+	buttons := heuristicButtons
+	switch {
+	case heuristicTriggersAreButtons(n):
+		buttons = heuristicButtonsWithTriggerButtons
+	case n.buttonCount() >= 11:
+		buttons = heuristicButtonsWithGuide
+	}
+	if index, ok := buttons[button]; ok {
+		if index >= n.buttonCount() {
+			return nil
+		}
+		return buttonMappingInput{g: n, button: index}
+	}
+
+	if n.axisCount() < 6 {
+		return nil
+	}
+	switch button {
+	case gamepaddb.StandardButtonFrontBottomLeft:
+		return axisMappingInput{g: n, axis: 2}
+	case gamepaddb.StandardButtonFrontBottomRight:
+		return axisMappingInput{g: n, axis: 5}
+	}
+	return nil
+}
+
+// heuristicDPadInput returns the input of the D-pad's direction guessed as the hat 0, or nil if there is no hat.
+func heuristicDPadInput(n nativeGamepad, direction int) mappingInput {
+	if n.hatCount() == 0 {
+		return nil
+	}
+	return hatMappingInput{g: n, hat: 0, direction: direction}
+}
+
 // Vibrate is concurrent-safe.
 func (g *Gamepad) Vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+	g.VibrateWithTriggers(duration, strongMagnitude, weakMagnitude, 0, 0)
+}
+
+// VibrateWithTriggers vibrates the gamepad including the motors in the triggers.
+// The trigger magnitudes are ignored if the gamepad doesn't have such motors.
+//
+// VibrateWithTriggers is concurrent-safe.
+func (g *Gamepad) VibrateWithTriggers(duration time.Duration, strongMagnitude, weakMagnitude, leftTriggerMagnitude, rightTriggerMagnitude float64) {
 	g.m.Lock()
 	defer g.m.Unlock()
 
+	var n any = g.native
+	if _, ok := n.(interface{ setVibrationGain(gain float64) }); !ok {
+		gain := 1 - g.vibrationAttenuation
+		strongMagnitude *= gain
+		weakMagnitude *= gain
+		leftTriggerMagnitude *= gain
+		rightTriggerMagnitude *= gain
+	}
+	if n, ok := n.(interface {
+		vibrateWithTriggers(duration time.Duration, strongMagnitude, weakMagnitude, leftTriggerMagnitude, rightTriggerMagnitude float64)
+	}); ok {
+		n.vibrateWithTriggers(duration, strongMagnitude, weakMagnitude, leftTriggerMagnitude, rightTriggerMagnitude)
+		return
+	}
 	g.native.vibrate(duration, strongMagnitude, weakMagnitude)
 }
+
+// PlayerIndex is concurrent-safe.
+func (g *Gamepad) PlayerIndex() int {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.playerIndex
+}
+
+// SetPlayerIndex is concurrent-safe.
+func (g *Gamepad) SetPlayerIndex(index int) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if index < 0 {
+		index = -1
+	}
+	if g.playerIndex == index {
+		return
+	}
+	g.playerIndex = index
+	g.playerIndexDirty = true
+}
+
+// StartCalibration starts recording the observed ranges of the axes.
+// The ranges are recorded until FinishCalibration is called.
+// StartCalibration returns false if the calibration is not available.
+//
+// StartCalibration is concurrent-safe.
+func (g *Gamepad) StartCalibration() bool {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	n2, ok := n.(interface{ startCalibration() })
+	if !ok {
+		return false
+	}
+	n2.startCalibration()
+	return true
+}
+
+// FinishCalibration finishes recording the observed ranges of the axes and returns the result.
+// FinishCalibration returns nil if the calibration is not available or StartCalibration is not called.
+//
+// The result is not applied until SetCalibration is called.
+//
+// FinishCalibration is concurrent-safe.
+func (g *Gamepad) FinishCalibration() *Calibration {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	n2, ok := n.(interface {
+		finishCalibration() map[int]AxisCalibration
+	})
+	if !ok {
+		return nil
+	}
+	axes := n2.finishCalibration()
+	if axes == nil {
+		return nil
+	}
+	return &Calibration{
+		SDLID:  g.sdlID,
+		Serial: g.Serial(),
+		Axes:   axes,
+	}
+}
+
+// Calibration returns the current calibration, or nil if the gamepad is not calibrated.
+//
+// Calibration is concurrent-safe.
+func (g *Gamepad) Calibration() *Calibration {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.calibration.clone()
+}
+
+// SetCalibration sets the calibration of the axes. nil resets the calibration.
+// The calibration is applied at the next update, and is applied again when the same gamepad is reconnected.
+//
+// SDLID and Serial of c are not used. The calibration is applied to this gamepad.
+//
+// SetCalibration returns false and does nothing if the calibration is not available.
+//
+// SetCalibration is concurrent-safe.
+func (g *Gamepad) SetCalibration(c *Calibration) bool {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	var n any = g.native
+	if _, ok := n.(interface {
+		setCalibration(axes map[int]AxisCalibration)
+	}); !ok {
+		return false
+	}
+
+	g.calibration = c.clone()
+	g.calibrationDirty = true
+	g.calibrationLoaded = true
+	return true
+}
+
+// BatteryLevel is concurrent-safe.
+func (g *Gamepad) BatteryLevel() (int, bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.native.batteryLevel()
+}
+
+// PowerState is concurrent-safe.
+func (g *Gamepad) PowerState() PowerState {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	return g.native.powerState()
+}
@@ -40,6 +40,33 @@ type nativeGamepadImpl struct {
 	axes    []float64
 	buttons []bool
 	hats    []int
+
+	// hatMoved reports whether the first hat has reported any motion.
+	// Some devices have hat axes that never move and report the D-pad as key events instead.
+	hatMoved bool
+
+	// suspended reports whether the application is paused and the gamepad is not enumerated again yet.
+	// The inputs are ignored while the gamepad is suspended.
+	suspended bool
+}
+
+// releaseInputs resets the inputs to the neutral state.
+// The input events for releasing buttons are not delivered while the application is paused.
+func (g *nativeGamepadImpl) releaseInputs() {
+	for i := range g.axes {
+		g.axes[i] = 0
+	}
+	// The trigger axes rest at -1. See also EbitenView.java.
+	if len(g.axes) >= 6 {
+		g.axes[4] = -1
+		g.axes[5] = -1
+	}
+	for i := range g.buttons {
+		g.buttons[i] = false
+	}
+	for i := range g.hats {
+		g.hats[i] = 0
+	}
 }
 
 func (*nativeGamepadImpl) update(gamepad *gamepads) error {
@@ -100,5 +127,34 @@ func (g *nativeGamepadImpl) hatState(hat int) int {
 }
 
 func (g *nativeGamepadImpl) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
-	// TODO: Implement this (#1452)
+	strong := androidVibrationAmplitude(strongMagnitude)
+	weak := androidVibrationAmplitude(weakMagnitude)
+	// A duration shorter than a millisecond cancels the vibrations.
+	if duration < time.Millisecond || (strong == 0 && weak == 0) {
+		duration = 0
+	}
+	// The vibration stops automatically when the duration elapses.
+	vibrateAndroidGamepad(g.androidDeviceID, duration, strong, weak)
+}
+
+// androidVibrationAmplitude converts a magnitude to an amplitude of VibrationEffect in between 0 and 255.
+func androidVibrationAmplitude(magnitude float64) int {
+	if magnitude <= 0 {
+		return 0
+	}
+	if magnitude >= 1 {
+		return 255
+	}
+	return int(magnitude * 255)
+}
+
+func (g *nativeGamepadImpl) setPlayerIndex(index int) {
+}
+
+func (g *nativeGamepadImpl) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (g *nativeGamepadImpl) powerState() PowerState {
+	return PowerStateUnknown
 }
@@ -0,0 +1,110 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad_test
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepad"
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
+)
+
+func TestAndroidHat(t *testing.T) {
+	const (
+		hatUp    = 1
+		hatRight = 2
+		hatDown  = 4
+		hatLeft  = 8
+	)
+
+	dpad := func(gp *gamepad.Gamepad) [4]bool {
+		return [4]bool{
+			gp.Button(gamepaddb.SDLControllerButtonDpadUp),
+			gp.Button(gamepaddb.SDLControllerButtonDpadDown),
+			gp.Button(gamepaddb.SDLControllerButtonDpadLeft),
+			gp.Button(gamepaddb.SDLControllerButtonDpadRight),
+		}
+	}
+
+	t.Run("hat axes", func(t *testing.T) {
+		g := gamepad.NewAndroidGamepadsForTesting()
+		g.Add(1, 0, 1)
+		gp := g.Gamepad(1)
+
+		g.UpdateHat(1, 0, -1, -1)
+		if got, want := gp.Hat(0), hatUp|hatLeft; got != want {
+			t.Errorf("Hat(0): got: %d, want: %d", got, want)
+		}
+		if got, want := dpad(gp), [4]bool{true, false, true, false}; got != want {
+			t.Errorf("D-pad: got: %v, want: %v", got, want)
+		}
+
+		// The D-pad key events are ignored once the hat reports motion, so that the hat and the buttons agree.
+		g.UpdateButton(1, gamepaddb.SDLControllerButtonDpadDown, true)
+		if got, want := dpad(gp), [4]bool{true, false, true, false}; got != want {
+			t.Errorf("D-pad: got: %v, want: %v", got, want)
+		}
+
+		g.UpdateHat(1, 0, 1, 1)
+		if got, want := gp.Hat(0), hatDown|hatRight; got != want {
+			t.Errorf("Hat(0): got: %d, want: %d", got, want)
+		}
+		if got, want := dpad(gp), [4]bool{false, true, false, true}; got != want {
+			t.Errorf("D-pad: got: %v, want: %v", got, want)
+		}
+
+		g.UpdateHat(1, 0, 0, 0)
+		if got, want := gp.Hat(0), 0; got != want {
+			t.Errorf("Hat(0): got: %d, want: %d", got, want)
+		}
+		if got, want := dpad(gp), [4]bool{}; got != want {
+			t.Errorf("D-pad: got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("key events with idle hat axes", func(t *testing.T) {
+		g := gamepad.NewAndroidGamepadsForTesting()
+		g.Add(1, 0, 1)
+		gp := g.Gamepad(1)
+
+		// The device has hat axes, but reports the D-pad as key events.
+		g.UpdateButton(1, gamepaddb.SDLControllerButtonDpadUp, true)
+		g.UpdateHat(1, 0, 0, 0)
+		if got, want := dpad(gp), [4]bool{true, false, false, false}; got != want {
+			t.Errorf("D-pad: got: %v, want: %v", got, want)
+		}
+
+		g.UpdateButton(1, gamepaddb.SDLControllerButtonDpadUp, false)
+		if got, want := dpad(gp), [4]bool{}; got != want {
+			t.Errorf("D-pad: got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("second hat", func(t *testing.T) {
+		g := gamepad.NewAndroidGamepadsForTesting()
+		g.Add(1, 0, 2)
+		gp := g.Gamepad(1)
+
+		// Only the first hat drives the D-pad buttons.
+		g.UpdateHat(1, 1, 1, 0)
+		if got, want := gp.Hat(1), hatRight; got != want {
+			t.Errorf("Hat(1): got: %d, want: %d", got, want)
+		}
+		g.UpdateButton(1, gamepaddb.SDLControllerButtonDpadLeft, true)
+		if got, want := dpad(gp), [4]bool{false, false, true, false}; got != want {
+			t.Errorf("D-pad: got: %v, want: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,332 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (freebsd || openbsd) && !nintendosdk && !playstation5
+
+package gamepad
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
+)
+
+const uhidDirName = "/dev"
+
+// uhidRescanInterval is the interval to rescan the uhid device files for hotplugging.
+//
+// There is no portable way to be notified of new device files like inotify on Linux, and devd is not always available.
+// Opening the device files once a second is cheap enough.
+const uhidRescanInterval = time.Second
+
+// usbDevice is the information of a USB device.
+type usbDevice struct {
+	vendor  uint16
+	product uint16
+	release uint16
+	name    string
+	serial  string
+}
+
+type nativeGamepadsImpl struct {
+	lastScanTime time.Time
+
+	// ignoredPaths is the set of the device files that turned out not to be gamepads or failed to be opened.
+	// A device file is tried again after it disappears once.
+	ignoredPaths map[string]struct{}
+}
+
+func newNativeGamepadsImpl() nativeGamepads {
+	return &nativeGamepadsImpl{}
+}
+
+func (g *nativeGamepadsImpl) init(gamepads *gamepads) error {
+	return g.scan(gamepads)
+}
+
+func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
+	if time.Since(g.lastScanTime) < uhidRescanInterval {
+		return nil
+	}
+	return g.scan(gamepads)
+}
+
+func (g *nativeGamepadsImpl) scan(gamepads *gamepads) error {
+	g.lastScanTime = time.Now()
+
+	paths, err := filepath.Glob(filepath.Join(uhidDirName, "uhid[0-9]*"))
+	if err != nil {
+		return fmt.Errorf("gamepad: Glob failed: %w", err)
+	}
+
+	current := map[string]struct{}{}
+	for _, path := range paths {
+		current[path] = struct{}{}
+	}
+	for path := range g.ignoredPaths {
+		if _, ok := current[path]; !ok {
+			delete(g.ignoredPaths, path)
+		}
+	}
+	var removed []string
+	for _, d := range gamepads.inaccessibleDevices {
+		if _, ok := current[d.Path]; !ok {
+			removed = append(removed, d.Path)
+		}
+	}
+	for _, path := range removed {
+		gamepads.removeInaccessibleDevice(path)
+	}
+
+	for _, path := range paths {
+		if _, ok := g.ignoredPaths[path]; ok {
+			continue
+		}
+		if gamepads.find(func(gamepad *Gamepad) bool {
+			return gamepad.devicePath() == path
+		}) != nil {
+			continue
+		}
+		g.openGamepad(gamepads, path)
+	}
+	return nil
+}
+
+func (g *nativeGamepadsImpl) ignore(path string) {
+	if g.ignoredPaths == nil {
+		g.ignoredPaths = map[string]struct{}{}
+	}
+	g.ignoredPaths[path] = struct{}{}
+}
+
+// openGamepad opens the uhid device file at path, and adds a gamepad if the device is a gamepad.
+// An error of one device doesn't stop the game, and is recorded as a device error instead.
+func (g *nativeGamepadsImpl) openGamepad(gamepads *gamepads, path string) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		// The uhid device files are accessible only by root by default.
+		// Keep trying to open the file in case its permission is changed later.
+		if err == unix.EACCES || err == unix.EPERM {
+			gamepads.addInaccessibleDevice(InaccessibleDevice{
+				Path: path,
+				Err:  err,
+			})
+			return
+		}
+		// The device might be being detached, or be opened exclusively by another process.
+		if err == unix.ENOENT || err == unix.ENXIO || err == unix.EBUSY {
+			return
+		}
+		g.addDeviceError(gamepads, path, "", fmt.Errorf("gamepad: Open failed: %w", err))
+		return
+	}
+	gamepads.removeInaccessibleDevice(path)
+
+	n, name, sdlID, key, err := newNativeGamepadImpl(fd, path)
+	if err != nil {
+		_ = unix.Close(fd)
+		g.addDeviceError(gamepads, path, name, err)
+		return
+	}
+	if n == nil {
+		_ = unix.Close(fd)
+		g.ignore(path)
+		return
+	}
+
+	gp := gamepads.addWithReconnectionKey(name, sdlID, key)
+	gp.native = n
+	runtime.SetFinalizer(gp, func(gp *Gamepad) {
+		n.close()
+	})
+}
+
+func (g *nativeGamepadsImpl) addDeviceError(gamepads *gamepads, path, name string, err error) {
+	g.ignore(path)
+	gamepads.addDeviceError(DeviceError{
+		Path: path,
+		Name: name,
+		Err:  err,
+	})
+}
+
+type nativeGamepadImpl struct {
+	fd      int
+	path    string
+	serial_ string
+
+	hid *hidGamepad
+	buf []byte
+}
+
+// newNativeGamepadImpl creates a native gamepad from the opened uhid device file.
+// newNativeGamepadImpl returns nil without an error if the device is not a gamepad.
+func newNativeGamepadImpl(fd int, path string) (n *nativeGamepadImpl, name, sdlID, key string, err error) {
+	rawDesc, err := usbReportDescriptor(fd)
+	if err != nil {
+		return nil, "", "", "", fmt.Errorf("gamepad: getting the report descriptor failed: %w", err)
+	}
+	desc, err := parseHIDReportDescriptor(rawDesc)
+	if err != nil {
+		return nil, "", "", "", err
+	}
+	if !desc.isGamepad() {
+		return nil, "", "", "", nil
+	}
+
+	// A uhid device is attached to one input report ID, and the report ID is not included in the data read from the device.
+	reportID, err := usbReportID(fd)
+	if err != nil {
+		return nil, "", "", "", fmt.Errorf("gamepad: getting the report ID failed: %w", err)
+	}
+	size := desc.inputReportSize(reportID)
+	if size == 0 {
+		return nil, "", "", "", nil
+	}
+
+	// The device information is not mandatory. Without it, the gamepad is identified by its name in the same way as SDL.
+	info, err := usbDeviceInfo(fd)
+	if err != nil {
+		info = usbDevice{}
+	}
+	name = info.name
+	if name == "" {
+		name = "Unknown"
+	}
+
+	// SDL's BSD backend uses the USB bus type and the release number as the version.
+	sdlID = sdlGUID(sdlHardwareBusUSB, info.vendor, info.product, info.release, name, sdlDriverSignatureNone, 0)
+
+	// Identify the physical gamepad by its serial so that the gamepad keeps its ID after reconnecting.
+	if info.serial != "" {
+		key = fmt.Sprintf("%04x:%04x:%s", info.vendor, info.product, info.serial)
+	}
+
+	return &nativeGamepadImpl{
+		fd:      fd,
+		path:    path,
+		serial_: info.serial,
+		hid:     newHIDGamepad(desc, reportID),
+		buf:     make([]byte, size),
+	}, name, sdlID, key, nil
+}
+
+func (g *nativeGamepadImpl) close() {
+	if g.fd != 0 {
+		_ = unix.Close(g.fd)
+	}
+	g.fd = 0
+}
+
+func (g *nativeGamepadImpl) devicePath() string {
+	return g.path
+}
+
+func (g *nativeGamepadImpl) serial() string {
+	return g.serial_
+}
+
+func (g *nativeGamepadImpl) busType() BusType {
+	return BusTypeUSB
+}
+
+func (g *nativeGamepadImpl) update(gamepads *gamepads) error {
+	if g.fd == 0 {
+		return errDisconnected
+	}
+
+	// Read all the queued reports. Each read returns one report.
+	for {
+		n, err := unix.Read(g.fd, g.buf)
+		if err != nil {
+			if err == unix.EAGAIN {
+				return nil
+			}
+			if err == unix.ENXIO || err == unix.ENODEV || err == unix.EIO {
+				g.close()
+				return errDisconnected
+			}
+			return fmt.Errorf("gamepad: Read failed: %w", err)
+		}
+		// The end of the file means that the device is gone.
+		if n == 0 {
+			g.close()
+			return errDisconnected
+		}
+		g.hid.setReport(g.buf[:n])
+	}
+}
+
+func (*nativeGamepadImpl) hasOwnStandardLayoutMapping() bool {
+	return false
+}
+
+func (*nativeGamepadImpl) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
+	return nil
+}
+
+func (*nativeGamepadImpl) standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput {
+	return nil
+}
+
+func (g *nativeGamepadImpl) axisCount() int {
+	return g.hid.axisCount()
+}
+
+func (g *nativeGamepadImpl) buttonCount() int {
+	return g.hid.buttonCount()
+}
+
+func (g *nativeGamepadImpl) hatCount() int {
+	return g.hid.hatCount()
+}
+
+func (g *nativeGamepadImpl) axisValue(axis int) float64 {
+	return g.hid.axisValue(axis)
+}
+
+func (g *nativeGamepadImpl) buttonValue(button int) float64 {
+	if g.isButtonPressed(button) {
+		return 1
+	}
+	return 0
+}
+
+func (g *nativeGamepadImpl) isButtonPressed(button int) bool {
+	return g.hid.isButtonPressed(button)
+}
+
+func (g *nativeGamepadImpl) hatState(hat int) int {
+	return g.hid.hatState(hat)
+}
+
+func (g *nativeGamepadImpl) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+	// Vibration requires device-specific output reports, and is not supported via uhid yet.
+}
+
+func (g *nativeGamepadImpl) setPlayerIndex(index int) {
+}
+
+func (g *nativeGamepadImpl) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (g *nativeGamepadImpl) powerState() PowerState {
+	return PowerStateUnknown
+}
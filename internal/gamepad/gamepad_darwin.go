@@ -18,7 +18,6 @@ package gamepad
 
 import (
 	"errors"
-	"fmt"
 	"sort"
 	"strings"
 	"sync"
@@ -29,6 +28,9 @@ import (
 )
 
 type nativeGamepadsImpl struct {
+	// gc is the GameController framework backend. gc is nil if GameController is not available.
+	gc *gcGamepads
+
 	hidManager      _IOHIDManagerRef
 	devicesToAdd    []_IOHIDDeviceRef
 	devicesToRemove []_IOHIDDeviceRef
@@ -47,6 +49,11 @@ func (g *nativeGamepadsImpl) init(gamepads *gamepads) error {
 		return err
 	}
 
+	if gc, err := newGCGamepads(); err == nil {
+		g.gc = gc
+		g.gc.init()
+	}
+
 	var dicts []_CFDictionaryRef
 
 	page := kHIDPage_GenericDesktop
@@ -128,6 +135,12 @@ func ebitenGamepadRemovalCallback(ctx unsafe.Pointer, res _IOReturn, sender unsa
 }
 
 func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
+	if g.gc != nil {
+		if err := g.gc.update(gamepads); err != nil {
+			return err
+		}
+	}
+
 	n := theGamepads.native.(*nativeGamepadsImpl)
 	n.devicesM.Lock()
 	defer n.devicesM.Unlock()
@@ -137,7 +150,13 @@ func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
 	}
 	for _, device := range g.devicesToRemove {
 		gamepads.remove(func(g *Gamepad) bool {
-			return g.native.(*nativeGamepadImpl).device == device
+			if iokitDevice(g) != device {
+				return false
+			}
+			if n, ok := g.native.(*nativeGamepadSony); ok {
+				n.close()
+			}
+			return true
 		})
 	}
 	g.devicesToAdd = g.devicesToAdd[:0]
@@ -147,53 +166,51 @@ func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
 
 func (g *nativeGamepadsImpl) addDevice(device _IOHIDDeviceRef, gamepads *gamepads) {
 	if gamepads.find(func(g *Gamepad) bool {
-		return g.native.(*nativeGamepadImpl).device == device
+		return iokitDevice(g) == device
 	}) != nil {
 		return
 	}
 
-	name := "Unknown"
-	if prop := _IOHIDDeviceGetProperty(device, _CFStringCreateWithCString(kCFAllocatorDefault, kIOHIDProductKey, kCFStringEncodingUTF8)); prop != 0 {
-		var cstr [256]byte
-		_CFStringGetCString(_CFStringRef(prop), cstr[:], kCFStringEncodingUTF8)
-		name = strings.TrimRight(string(cstr[:]), "\x00")
+	vendor := hidDeviceUint32Property(device, kIOHIDVendorIDKey)
+	product := hidDeviceUint32Property(device, kIOHIDProductIDKey)
+	version := hidDeviceUint32Property(device, kIOHIDVersionNumberKey)
+
+	busType := BusTypeUnknown
+	if transport, ok := hidDeviceStringProperty(device, kIOHIDTransportKey); ok {
+		busType = transportToBusType(transport)
 	}
 
-	var vendor uint32
-	if prop := _IOHIDDeviceGetProperty(device, _CFStringCreateWithCString(kCFAllocatorDefault, kIOHIDVendorIDKey, kCFStringEncodingUTF8)); prop != 0 {
-		_CFNumberGetValue(_CFNumberRef(prop), kCFNumberSInt32Type, unsafe.Pointer(&vendor))
+	// GCController doesn't provide the haptics of DualSense via Bluetooth on older macOS.
+	// Handle DualSense via raw HID reports in this case, so that the rumble and the lightbar work.
+	if model, ok := sonyGamepadModel(uint16(vendor), uint16(product)); ok && model == sonyModelDualSense && (g.gc == nil || !g.gc.dualSenseHapticsAvailable) {
+		g.addSonyDevice(device, gamepads, uint16(vendor), uint16(product), uint16(version), model, busType)
+		return
 	}
 
-	var product uint32
-	if prop := _IOHIDDeviceGetProperty(device, _CFStringCreateWithCString(kCFAllocatorDefault, kIOHIDProductIDKey, kCFStringEncodingUTF8)); prop != 0 {
-		_CFNumberGetValue(_CFNumberRef(prop), kCFNumberSInt32Type, unsafe.Pointer(&product))
+	// The device is handled by GCController.
+	if g.gc != nil && g.gc.supportsHIDDevice(device) {
+		return
 	}
 
-	var version uint32
-	if prop := _IOHIDDeviceGetProperty(device, _CFStringCreateWithCString(kCFAllocatorDefault, kIOHIDVersionNumberKey, kCFStringEncodingUTF8)); prop != 0 {
-		_CFNumberGetValue(_CFNumberRef(prop), kCFNumberSInt32Type, unsafe.Pointer(&version))
+	name, ok := hidDeviceStringProperty(device, kIOHIDProductKey)
+	if !ok {
+		name = "Unknown"
 	}
 
-	var sdlID string
-	if vendor != 0 && product != 0 {
-		sdlID = fmt.Sprintf("03000000%02x%02x0000%02x%02x0000%02x%02x0000",
-			byte(vendor), byte(vendor>>8),
-			byte(product), byte(product>>8),
-			byte(version), byte(version>>8))
-	} else {
-		bs := []byte(name)
-		if len(bs) < 12 {
-			bs = append(bs, make([]byte, 12-len(bs))...)
-		}
-		sdlID = fmt.Sprintf("05000000%02x%02x%02x%02x%02x%02x%02x%02x%02x%02x%02x%02x",
-			bs[0], bs[1], bs[2], bs[3], bs[4], bs[5], bs[6], bs[7], bs[8], bs[9], bs[10], bs[11])
+	// SDL's IOKit backend always uses the USB bus type with the vendor and the product,
+	// and the Bluetooth bus type with the name.
+	bus := uint16(sdlHardwareBusUSB)
+	if vendor == 0 || product == 0 {
+		bus = sdlHardwareBusBluetooth
 	}
+	sdlID := sdlGUID(bus, uint16(vendor), uint16(product), uint16(version), name, sdlDriverSignatureNone, 0)
 
 	elements := _IOHIDDeviceCopyMatchingElements(device, 0, kIOHIDOptionsTypeNone)
 	defer _CFRelease(_CFTypeRef(elements))
 
 	n := &nativeGamepadImpl{
-		device: device,
+		device:   device,
+		busType_: busType,
 	}
 	gp := gamepads.add(name, sdlID)
 	gp.native = n
@@ -272,6 +289,33 @@ func (g *nativeGamepadsImpl) addDevice(device _IOHIDDeviceRef, gamepads *gamepad
 	sort.Stable(n.hats)
 }
 
+// hidDeviceStringProperty returns the string property of the HID device.
+// The second value is false if the device doesn't have the property.
+func hidDeviceStringProperty(device _IOHIDDeviceRef, key []byte) (string, bool) {
+	keyRef := _CFStringCreateWithCString(kCFAllocatorDefault, key, kCFStringEncodingUTF8)
+	defer _CFRelease(_CFTypeRef(keyRef))
+
+	prop := _IOHIDDeviceGetProperty(device, keyRef)
+	if prop == 0 {
+		return "", false
+	}
+	var cstr [256]byte
+	_CFStringGetCString(_CFStringRef(prop), cstr[:], kCFStringEncodingUTF8)
+	return strings.TrimRight(string(cstr[:]), "\x00"), true
+}
+
+// hidDeviceUint32Property returns the number property of the HID device, or 0 if the device doesn't have the property.
+func hidDeviceUint32Property(device _IOHIDDeviceRef, key []byte) uint32 {
+	keyRef := _CFStringCreateWithCString(kCFAllocatorDefault, key, kCFStringEncodingUTF8)
+	defer _CFRelease(_CFTypeRef(keyRef))
+
+	var v uint32
+	if prop := _IOHIDDeviceGetProperty(device, keyRef); prop != 0 {
+		_CFNumberGetValue(_CFNumberRef(prop), kCFNumberSInt32Type, unsafe.Pointer(&v))
+	}
+	return v
+}
+
 type element struct {
 	native  _IOHIDElementRef
 	usage   int
@@ -301,16 +345,34 @@ func (e elements) Swap(i, j int) {
 }
 
 type nativeGamepadImpl struct {
-	device  _IOHIDDeviceRef
-	axes    elements
-	buttons elements
-	hats    elements
+	device   _IOHIDDeviceRef
+	busType_ BusType
+	axes     elements
+	buttons  elements
+	hats     elements
 
 	axisValues   []float64
 	buttonValues []bool
 	hatValues    []int
 }
 
+// transportToBusType converts the transport property of a HID device to a BusType.
+func transportToBusType(transport string) BusType {
+	switch transport {
+	case "USB":
+		return BusTypeUSB
+	case "Bluetooth", "Bluetooth Low Energy":
+		return BusTypeBluetooth
+	case "Virtual":
+		return BusTypeVirtual
+	}
+	return BusTypeUnknown
+}
+
+func (g *nativeGamepadImpl) busType() BusType {
+	return g.busType_
+}
+
 func (g *nativeGamepadImpl) elementValue(e *element) int {
 	var valueRef _IOHIDValueRef
 	if _IOHIDDeviceGetValue(g.device, e.native, &valueRef) == kIOReturnSuccess {
@@ -430,3 +492,14 @@ func (g *nativeGamepadImpl) hatState(hat int) int {
 func (g *nativeGamepadImpl) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
 	// TODO: Implement this (#1452)
 }
+
+func (g *nativeGamepadImpl) setPlayerIndex(index int) {
+}
+
+func (g *nativeGamepadImpl) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (g *nativeGamepadImpl) powerState() PowerState {
+	return PowerStateUnknown
+}
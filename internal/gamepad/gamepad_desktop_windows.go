@@ -17,6 +17,7 @@ package gamepad
 import (
 	"errors"
 	"fmt"
+	"math"
 	"sort"
 	"strings"
 	"sync/atomic"
@@ -26,6 +27,7 @@ import (
 
 	"golang.org/x/sys/windows"
 
+	"github.com/hajimehoshi/ebiten/v2/internal/debug"
 	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
 )
 
@@ -98,6 +100,16 @@ var xinputButtons = []uint16{
 	_XINPUT_GAMEPAD_RIGHT_THUMB,
 }
 
+// xuserMaxCount is the maximum number of the XInput devices.
+const xuserMaxCount = 4
+
+// xinputRescanInterval is the interval to query the XInput slots without gamepads again.
+//
+// Querying an empty slot is expensive, as XInput enumerates the devices internally.
+// Then, the empty slots are queried only when WM_DEVICECHANGE notifies changes of the devices, and at this interval.
+// The interval is needed as a wireless controller connecting to an already plugged-in receiver doesn't cause WM_DEVICECHANGE.
+const xinputRescanInterval = 2 * time.Second
+
 type nativeGamepadsDesktop struct {
 	dinput8    windows.Handle
 	dinput8API *_IDirectInput8W
@@ -106,6 +118,30 @@ type nativeGamepadsDesktop struct {
 	procDirectInput8Create    uintptr
 	procXInputGetCapabilities uintptr
 	procXInputGetState        uintptr
+	procXInputSetState        uintptr
+
+	// procXInputGetBatteryInformation is XInputGetBatteryInformation, or 0 if it is not available.
+	// XInputGetBatteryInformation is available only in xinput1_3.dll or later.
+	procXInputGetBatteryInformation uintptr
+
+	// procXInputGetStateEx is the hidden XInputGetStateEx reporting the Guide button, or 0 if it is not available.
+	procXInputGetStateEx uintptr
+
+	// gameInput is the GameInput backend, or nil if GameInput is not available.
+	// If gameInput is available, the other backends are not used.
+	gameInput *nativeGamepadsXbox
+
+	// wgi is the Windows.Gaming.Input backend, or nil if it is not available.
+	// If wgi is available, wgi is used for XInput devices instead of XInput.
+	wgi *wgiGamepads
+
+	// sony is the HID backend for DualShock 4 and DualSense, or nil if hid.dll is not available.
+	// If sony is available, sony is used for these gamepads instead of DirectInput.
+	sony *sonyGamepads
+
+	// switchPro is the HID backend for Switch Pro Controllers, or nil if hid.dll is not available.
+	// If switchPro is available, switchPro is used for these gamepads instead of DirectInput.
+	switchPro *switchProGamepads
 
 	origWndProc         uintptr
 	wndProcCallback     uintptr
@@ -115,6 +151,13 @@ type nativeGamepadsDesktop struct {
 	nativeWindow  windows.HWND
 	deviceChanged int32
 	err           error
+
+	// xinputDevices is the set of the vendor and product IDs of the XInput devices, in the same form as Data1 of guidProduct.
+	// xinputDevices is updated before DirectInput enumerates the devices, so that DirectInput can skip them.
+	xinputDevices map[uint32]struct{}
+
+	// xinputScanTime is the last time when the XInput slots without gamepads were queried.
+	xinputScanTime time.Time
 }
 
 type dinputObject struct {
@@ -132,6 +175,16 @@ type enumObjectsContext struct {
 }
 
 func (g *nativeGamepadsDesktop) init(gamepads *gamepads) error {
+	// GameInput is preferred when it is available, as it unifies the modern controllers and supports background input.
+	// GameInput.dll is not a part of Windows and is installed with the GameInput redistributable.
+	if isGameInputAvailable() {
+		gameInput := &nativeGamepadsXbox{}
+		if err := gameInput.init(gamepads); err == nil {
+			g.gameInput = gameInput
+			return nil
+		}
+	}
+
 	// As there is no guarantee that the DLL exists, NewLazySystemDLL is not available.
 	// TODO: Is there a 'system' version of LoadLibrary?
 	if h, err := windows.LoadLibrary("dinput8.dll"); err == nil {
@@ -169,10 +222,36 @@ func (g *nativeGamepadsDesktop) init(gamepads *gamepads) error {
 				}
 				g.procXInputGetState = p
 			}
+			{
+				p, err := windows.GetProcAddress(h, "XInputSetState")
+				if err != nil {
+					return err
+				}
+				g.procXInputSetState = p
+			}
+			if p, err := windows.GetProcAddress(h, "XInputGetBatteryInformation"); err == nil {
+				g.procXInputGetBatteryInformation = p
+			}
+			// XInputGetStateEx is exported only by its ordinal 100 in xinput1_3.dll and xinput1_4.dll.
+			if p, err := windows.GetProcAddressByOrdinal(h, 100); err == nil {
+				g.procXInputGetStateEx = p
+			}
 			break
 		}
 	}
 
+	// WGI is available on Windows 10 or later. Otherwise, XInput is used.
+	if w, err := newWGIGamepads(); err == nil {
+		g.wgi = w
+	}
+
+	if s, err := newSonyGamepads(); err == nil {
+		g.sony = s
+	}
+	if s, err := newSwitchProGamepads(); err == nil {
+		g.switchPro = s
+	}
+
 	if g.dinput8 != 0 {
 		// TODO: Use _GetModuleHandleExW to align with GLFW v3.3.8.
 		m, err := _GetModuleHandleW()
@@ -185,10 +264,10 @@ func (g *nativeGamepadsDesktop) init(gamepads *gamepads) error {
 			return err
 		}
 		g.dinput8API = api
+	}
 
-		if err := g.detectConnection(gamepads); err != nil {
-			return err
-		}
+	if err := g.detectConnection(gamepads); err != nil {
+		return err
 	}
 
 	return nil
@@ -215,20 +294,83 @@ func (g *nativeGamepadsDesktop) xinputGetCapabilities(dwUserIndex uint32, dwFlag
 }
 
 func (g *nativeGamepadsDesktop) xinputGetState(dwUserIndex uint32, pState *_XINPUT_STATE) error {
+	if g.procXInputGetStateEx != 0 {
+		var state _XINPUT_STATE_EX
+		r, _, _ := syscall.Syscall(g.procXInputGetStateEx, 2,
+			uintptr(dwUserIndex), uintptr(unsafe.Pointer(&state)), 0)
+		if e := syscall.Errno(uint32(r)); e != windows.ERROR_SUCCESS {
+			return fmt.Errorf("gamepad: XInputGetStateEx failed: %w", e)
+		}
+		pState.dwPacketNumber = state.dwPacketNumber
+		pState.Gamepad = state.Gamepad
+		return nil
+	}
+
 	// XInputGetState doesn't call SetLastError and returns an error code directly.
 	r, _, _ := syscall.Syscall(g.procXInputGetState, 2,
 		uintptr(dwUserIndex), uintptr(unsafe.Pointer(pState)), 0)
 	if e := syscall.Errno(uint32(r)); e != windows.ERROR_SUCCESS {
-		return fmt.Errorf("gamepad: XInputGetCapabilities failed: %w", e)
+		return fmt.Errorf("gamepad: XInputGetState failed: %w", e)
+	}
+	return nil
+}
+
+func (g *nativeGamepadsDesktop) xinputSetState(dwUserIndex uint32, pVibration *_XINPUT_VIBRATION) error {
+	// XInputSetState doesn't call SetLastError and returns an error code directly.
+	r, _, _ := syscall.Syscall(g.procXInputSetState, 2,
+		uintptr(dwUserIndex), uintptr(unsafe.Pointer(pVibration)), 0)
+	if e := syscall.Errno(uint32(r)); e != windows.ERROR_SUCCESS {
+		return fmt.Errorf("gamepad: XInputSetState failed: %w", e)
 	}
 	return nil
 }
 
+func (g *nativeGamepadsDesktop) xinputGetBatteryInformation(dwUserIndex uint32, devType byte, pBatteryInformation *_XINPUT_BATTERY_INFORMATION) error {
+	// XInputGetBatteryInformation doesn't call SetLastError and returns an error code directly.
+	r, _, _ := syscall.Syscall(g.procXInputGetBatteryInformation, 3,
+		uintptr(dwUserIndex), uintptr(devType), uintptr(unsafe.Pointer(pBatteryInformation)))
+	if e := syscall.Errno(uint32(r)); e != windows.ERROR_SUCCESS {
+		return fmt.Errorf("gamepad: XInputGetBatteryInformation failed: %w", e)
+	}
+	return nil
+}
+
+// xinputMotorSpeedsSetter returns a function to set the motor speeds of the XInput device at the given user index.
+func (g *nativeGamepadsDesktop) xinputMotorSpeedsSetter(index int) func(low, high uint16) error {
+	return func(low, high uint16) error {
+		return g.xinputSetState(uint32(index), &_XINPUT_VIBRATION{
+			wLeftMotorSpeed:  low,
+			wRightMotorSpeed: high,
+		})
+	}
+}
+
 func (g *nativeGamepadsDesktop) detectConnection(gamepads *gamepads) error {
+	// Detect the Sony gamepads and the Switch Pro Controllers first so that DirectInput can skip them.
+	if g.sony != nil {
+		if err := g.sony.detectConnection(gamepads); err != nil {
+			return err
+		}
+	}
+	if g.switchPro != nil {
+		if err := g.switchPro.detectConnection(gamepads); err != nil {
+			return err
+		}
+	}
 	if g.dinput8 != 0 {
 		if g.enumDevicesCallback == 0 {
 			g.enumDevicesCallback = windows.NewCallback(g.dinput8EnumDevicesCallback)
 		}
+		// XInput devices are also listed by DirectInput. Skip them so that they are not added twice.
+		// If neither XInput nor WGI is available, use DirectInput for them instead so that they are still visible.
+		g.xinputDevices = nil
+		if g.xinput != 0 || g.wgi != nil {
+			ds, err := xinputDevices()
+			if err != nil {
+				return err
+			}
+			g.xinputDevices = ds
+		}
 		if err := g.dinput8API.EnumDevices(_DI8DEVCLASS_GAMECTRL, g.enumDevicesCallback, unsafe.Pointer(gamepads), _DIEDFL_ALLDEVICES); err != nil {
 			return err
 		}
@@ -236,52 +378,68 @@ func (g *nativeGamepadsDesktop) detectConnection(gamepads *gamepads) error {
 			return g.err
 		}
 	}
-	if g.xinput != 0 {
-		const xuserMaxCount = 4
+	if g.wgi != nil {
+		// The XInput user indices might be changed.
+		g.wgi.xinputIndicesDirty = true
+	} else if g.xinput != 0 {
+		if err := g.detectXInputConnection(gamepads); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		for i := 0; i < xuserMaxCount; i++ {
-			if gamepads.find(func(g *Gamepad) bool {
-				n := g.native.(*nativeGamepadDesktop)
-				return n.dinputDevice == nil && n.xinputIndex == i
-			}) != nil {
-				continue
-			}
+// detectXInputConnection queries the XInput slots without gamepads, and adds gamepads for the connected devices.
+func (g *nativeGamepadsDesktop) detectXInputConnection(gamepads *gamepads) error {
+	g.xinputScanTime = time.Now()
 
-			var xic _XINPUT_CAPABILITIES
-			if err := g.xinputGetCapabilities(uint32(i), 0, &xic); err != nil {
-				if !errors.Is(err, windows.ERROR_DEVICE_NOT_CONNECTED) {
-					return err
-				}
-				continue
-			}
+	for i := 0; i < xuserMaxCount; i++ {
+		if gamepads.find(func(g *Gamepad) bool {
+			n, ok := g.native.(*nativeGamepadDesktop)
+			return ok && n.dinputDevice == nil && n.xinputIndex == i
+		}) != nil {
+			continue
+		}
 
-			sdlID := fmt.Sprintf("78696e707574%02x000000000000000000", xic.subType&0xff)
-			name := "Unknown XInput Device"
-			switch xic.subType {
-			case _XINPUT_DEVSUBTYPE_GAMEPAD:
-				if xic.flags&_XINPUT_CAPS_WIRELESS != 0 {
-					name = "Wireless Xbox Controller"
-				} else {
-					name = "Xbox Controller"
-				}
-			case _XINPUT_DEVSUBTYPE_WHEEL:
-				name = "XInput Wheel"
-			case _XINPUT_DEVSUBTYPE_ARCADE_STICK:
-				name = "XInput Arcade Stick"
-			case _XINPUT_DEVSUBTYPE_FLIGHT_STICK:
-				name = "XInput Flight Stick"
-			case _XINPUT_DEVSUBTYPE_DANCE_PAD:
-				name = "XInput Dance Pad"
-			case _XINPUT_DEVSUBTYPE_GUITAR:
-				name = "XInput Guitar"
-			case _XINPUT_DEVSUBTYPE_DRUM_KIT:
-				name = "XInput Drum Kit"
+		var xic _XINPUT_CAPABILITIES
+		if err := g.xinputGetCapabilities(uint32(i), 0, &xic); err != nil {
+			if !errors.Is(err, windows.ERROR_DEVICE_NOT_CONNECTED) {
+				return err
 			}
+			continue
+		}
 
-			gp := gamepads.add(name, sdlID)
-			gp.native = &nativeGamepadDesktop{
-				xinputIndex: i,
+		sdlID := xinputSDLGUID(xic.subType)
+		name := "Unknown XInput Device"
+		switch xic.subType {
+		case _XINPUT_DEVSUBTYPE_GAMEPAD:
+			if xic.flags&_XINPUT_CAPS_WIRELESS != 0 {
+				name = "Wireless Xbox Controller"
+			} else {
+				name = "Xbox Controller"
 			}
+		case _XINPUT_DEVSUBTYPE_WHEEL:
+			name = "XInput Wheel"
+		case _XINPUT_DEVSUBTYPE_ARCADE_STICK:
+			name = "XInput Arcade Stick"
+		case _XINPUT_DEVSUBTYPE_FLIGHT_STICK:
+			name = "XInput Flight Stick"
+		case _XINPUT_DEVSUBTYPE_DANCE_PAD:
+			name = "XInput Dance Pad"
+		case _XINPUT_DEVSUBTYPE_GUITAR:
+			name = "XInput Guitar"
+		case _XINPUT_DEVSUBTYPE_DRUM_KIT:
+			name = "XInput Drum Kit"
+		}
+
+		gp := gamepads.add(name, sdlID)
+		gp.native = &nativeGamepadDesktop{
+			xinputIndex: i,
+			xinputGuide: g.procXInputGetStateEx != 0,
+			rumble: rumble{
+				setMotorSpeeds: g.xinputMotorSpeedsSetter(i),
+			},
+			batteryLevel_: -1,
 		}
 	}
 	return nil
@@ -295,17 +453,22 @@ func (g *nativeGamepadsDesktop) dinput8EnumDevicesCallback(lpddi *_DIDEVICEINSTA
 	}
 
 	if gamepads.find(func(g *Gamepad) bool {
-		return g.native.(*nativeGamepadDesktop).dinputGUID == lpddi.guidInstance
+		n, ok := g.native.(*nativeGamepadDesktop)
+		return ok && n.dinputGUID == lpddi.guidInstance
 	}) != nil {
 		return _DIENUM_CONTINUE
 	}
 
-	s, err := supportsXInput(lpddi.guidProduct)
-	if err != nil {
-		g.err = err
-		return _DIENUM_STOP
+	// The Sony gamepads opened by the HID backend are also listed by DirectInput. Skip them.
+	if g.sony != nil && g.sony.isOpened(gamepads, uint16(lpddi.guidProduct.Data1), uint16(lpddi.guidProduct.Data1>>16)) {
+		return _DIENUM_CONTINUE
 	}
-	if s {
+	// The same applies to the Switch Pro Controllers.
+	if g.switchPro != nil && g.switchPro.isOpened(gamepads, uint16(lpddi.guidProduct.Data1), uint16(lpddi.guidProduct.Data1>>16)) {
+		return _DIENUM_CONTINUE
+	}
+
+	if _, ok := g.xinputDevices[lpddi.guidProduct.Data1]; ok {
 		return _DIENUM_CONTINUE
 	}
 
@@ -372,24 +535,16 @@ func (g *nativeGamepadsDesktop) dinput8EnumDevicesCallback(lpddi *_DIDEVICEINSTA
 	})
 
 	name := windows.UTF16ToString(lpddi.tszInstanceName[:])
-	var sdlID string
+	// guidProduct has the vendor in the lower 16 bits and the product in the upper 16 bits of Data1 for HID devices.
+	// DirectInput doesn't provide the version, and the 'version' part is always 0 in the game controller database.
+	var vendor, product uint16
+	bus := uint16(sdlHardwareBusBluetooth)
 	if string(lpddi.guidProduct.Data4[2:8]) == "PIDVID" {
-		// This seems different from the current SDL implementation.
-		// Probably guidProduct includes the vendor and the product information, but this works.
-		// From the game controller database, the 'version' part seems always 0.
-		sdlID = fmt.Sprintf("03000000%02x%02x0000%02x%02x000000000000",
-			byte(lpddi.guidProduct.Data1),
-			byte(lpddi.guidProduct.Data1>>8),
-			byte(lpddi.guidProduct.Data1>>16),
-			byte(lpddi.guidProduct.Data1>>24))
-	} else {
-		bs := []byte(name)
-		if len(bs) < 12 {
-			bs = append(bs, make([]byte, 12-len(bs))...)
-		}
-		sdlID = fmt.Sprintf("05000000%02x%02x%02x%02x%02x%02x%02x%02x%02x%02x%02x%02x",
-			bs[0], bs[1], bs[2], bs[3], bs[4], bs[5], bs[6], bs[7], bs[8], bs[9], bs[10], bs[11])
+		vendor = uint16(lpddi.guidProduct.Data1)
+		product = uint16(lpddi.guidProduct.Data1 >> 16)
+		bus = sdlHardwareBusUSB
 	}
+	sdlID := sdlGUID(bus, vendor, product, 0, name, sdlDriverSignatureNone, 0)
 
 	gp := gamepads.add(name, sdlID)
 	gp.native = &nativeGamepadDesktop{
@@ -404,23 +559,27 @@ func (g *nativeGamepadsDesktop) dinput8EnumDevicesCallback(lpddi *_DIDEVICEINSTA
 	return _DIENUM_CONTINUE
 }
 
-func supportsXInput(guid windows.GUID) (bool, error) {
+// xinputDevices returns the set of the vendor and product IDs of the connected XInput devices.
+// An XInput device is a raw input HID device whose name contains "IG_".
+// A device whose information cannot be retrieved is skipped so that the other devices are still visible.
+func xinputDevices() (map[uint32]struct{}, error) {
 	var count uint32
 	if r, err := _GetRawInputDeviceList(nil, &count); err != nil {
-		return false, err
+		return nil, err
 	} else if r != 0 {
-		return false, nil
+		return nil, nil
 	}
 
 	if count == 0 {
-		return false, nil
+		return nil, nil
 	}
 
 	ridl := make([]_RAWINPUTDEVICELIST, count)
 	if _, err := _GetRawInputDeviceList(&ridl[0], &count); err != nil {
-		return false, err
+		return nil, err
 	}
 
+	var ds map[uint32]struct{}
 	for i := 0; i < int(count); i++ {
 		if ridl[i].dwType != _RIM_TYPEHID {
 			continue
@@ -435,22 +594,22 @@ func supportsXInput(guid windows.GUID) (bool, error) {
 			continue
 		}
 
-		if uint32(rdi.hid.dwVendorId)|(uint32(rdi.hid.dwProductId)<<16) != guid.Data1 {
-			continue
-		}
-
 		var name [256]uint16
 		size = uint32(unsafe.Sizeof(name))
 		if _, err := _GetRawInputDeviceInfoW(ridl[i].hDevice, _RIDI_DEVICENAME, unsafe.Pointer(&name[0]), &size); err != nil {
-			return false, err
+			continue
+		}
+		if !strings.Contains(windows.UTF16ToString(name[:]), "IG_") {
+			continue
 		}
 
-		if strings.Contains(windows.UTF16ToString(name[:]), "IG_") {
-			return true, nil
+		if ds == nil {
+			ds = map[uint32]struct{}{}
 		}
+		ds[uint32(rdi.hid.dwVendorId)|(uint32(rdi.hid.dwProductId)<<16)] = struct{}{}
 	}
 
-	return false, nil
+	return ds, nil
 }
 
 func (g *nativeGamepadsDesktop) dinputDevice8EnumObjectsCallback(lpddoi *_DIDEVICEOBJECTINSTANCEW, pvRef unsafe.Pointer) uintptr {
@@ -505,6 +664,11 @@ func (g *nativeGamepadsDesktop) dinputDevice8EnumObjectsCallback(lpddoi *_DIDEVI
 			index:      index,
 		})
 	case _DIDFT_GETTYPE(lpddoi.dwType)&_DIDFT_BUTTON != 0:
+		// DIJOYSTATE has only 32 buttons. The other buttons cannot be read.
+		if ctx.buttonCount >= len(_DIJOYSTATE{}.rgbButtons) {
+			debug.Logf("gamepad: the device has more than %d buttons and the other buttons are ignored\n", len(_DIJOYSTATE{}.rgbButtons))
+			return _DIENUM_CONTINUE
+		}
 		ctx.objects = append(ctx.objects, dinputObject{
 			objectType: dinputObjectTypeButton,
 			index:      ctx.buttonCount,
@@ -522,6 +686,10 @@ func (g *nativeGamepadsDesktop) dinputDevice8EnumObjectsCallback(lpddoi *_DIDEVI
 }
 
 func (g *nativeGamepadsDesktop) update(gamepads *gamepads) error {
+	if g.gameInput != nil {
+		return g.gameInput.update(gamepads)
+	}
+
 	if g.err != nil {
 		return g.err
 	}
@@ -535,6 +703,18 @@ func (g *nativeGamepadsDesktop) update(gamepads *gamepads) error {
 			return err
 		}
 		g.origWndProc = h
+
+		// Without registrations, WM_DEVICECHANGE might not be sent for the arrivals and the removals of gamepads.
+		for _, guid := range []windows.GUID{_GUID_DEVINTERFACE_HID, _GUID_DEVINTERFACE_XUSB} {
+			filter := _DEV_BROADCAST_DEVICEINTERFACE_W{
+				dbcc_size:       uint32(unsafe.Sizeof(_DEV_BROADCAST_DEVICEINTERFACE_W{})),
+				dbcc_devicetype: _DBT_DEVTYP_DEVICEINTERFACE,
+				dbcc_classguid:  guid,
+			}
+			if _, err := _RegisterDeviceNotificationW(g.nativeWindow, unsafe.Pointer(&filter), _DEVICE_NOTIFY_WINDOW_HANDLE); err != nil {
+				return err
+			}
+		}
 	}
 
 	if atomic.LoadInt32(&g.deviceChanged) != 0 {
@@ -543,6 +723,17 @@ func (g *nativeGamepadsDesktop) update(gamepads *gamepads) error {
 		}
 		atomic.StoreInt32(&g.deviceChanged, 0)
 	}
+	if g.wgi == nil && g.xinput != 0 && time.Since(g.xinputScanTime) >= xinputRescanInterval {
+		if err := g.detectXInputConnection(gamepads); err != nil {
+			g.err = err
+		}
+	}
+
+	if g.wgi != nil {
+		if err := g.wgi.update(gamepads, g); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -550,7 +741,12 @@ func (g *nativeGamepadsDesktop) update(gamepads *gamepads) error {
 func (g *nativeGamepadsDesktop) wndProc(hWnd uintptr, uMsg uint32, wParam, lParam uintptr) uintptr {
 	switch uMsg {
 	case _WM_DEVICECHANGE:
-		atomic.StoreInt32(&g.deviceChanged, 1)
+		// Enumerate the devices again only when the set of the devices might be changed,
+		// as enumerating devices, especially querying empty XInput slots, is expensive.
+		switch wParam {
+		case _DBT_DEVICEARRIVAL, _DBT_DEVICEREMOVECOMPLETE, _DBT_DEVNODES_CHANGED:
+			atomic.StoreInt32(&g.deviceChanged, 1)
+		}
 	}
 	return _CallWindowProcW(g.origWndProc, hWnd, uMsg, wParam, lParam)
 }
@@ -569,17 +765,89 @@ type nativeGamepadDesktop struct {
 
 	xinputIndex int
 	xinputState _XINPUT_STATE
+
+	// xinputGuide reports whether the Guide button is available via XInputGetStateEx.
+	xinputGuide bool
+
+	// axisDeadZoneDisabled reports whether raw axis values are used without the dead zones.
+	axisDeadZoneDisabled bool
+
+	// rumble is the rumble effect via XInputSetState. This is not used for DirectInput devices.
+	rumble rumble
+
+	// The battery information is cached as XInputGetBatteryInformation is not cheap.
+	// These are not used for DirectInput devices.
+	batteryUpdated time.Time
+	batteryLevel_  int
+	powerState_    PowerState
 }
 
-func (*nativeGamepadDesktop) hasOwnStandardLayoutMapping() bool {
-	return false
+func (g *nativeGamepadDesktop) hasOwnStandardLayoutMapping() bool {
+	// XInput devices always have the layout of Xbox controllers.
+	return !g.usesDInput()
 }
 
-func (*nativeGamepadDesktop) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
+func (g *nativeGamepadDesktop) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
+	if !g.hasOwnStandardLayoutMapping() {
+		return nil
+	}
+	switch axis {
+	case gamepaddb.StandardAxisLeftStickHorizontal:
+		return axisMappingInput{g: g, axis: 0}
+	case gamepaddb.StandardAxisLeftStickVertical:
+		return axisMappingInput{g: g, axis: 1}
+	case gamepaddb.StandardAxisRightStickHorizontal:
+		return axisMappingInput{g: g, axis: 2}
+	case gamepaddb.StandardAxisRightStickVertical:
+		return axisMappingInput{g: g, axis: 3}
+	}
 	return nil
 }
 
-func (*nativeGamepadDesktop) standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput {
+func (g *nativeGamepadDesktop) standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput {
+	if !g.hasOwnStandardLayoutMapping() {
+		return nil
+	}
+	switch button {
+	case gamepaddb.StandardButtonRightBottom:
+		return buttonMappingInput{g: g, button: 0}
+	case gamepaddb.StandardButtonRightRight:
+		return buttonMappingInput{g: g, button: 1}
+	case gamepaddb.StandardButtonRightLeft:
+		return buttonMappingInput{g: g, button: 2}
+	case gamepaddb.StandardButtonRightTop:
+		return buttonMappingInput{g: g, button: 3}
+	case gamepaddb.StandardButtonFrontTopLeft:
+		return buttonMappingInput{g: g, button: 4}
+	case gamepaddb.StandardButtonFrontTopRight:
+		return buttonMappingInput{g: g, button: 5}
+	case gamepaddb.StandardButtonFrontBottomLeft:
+		// The triggers are analog and independent of each other.
+		return axisMappingInput{g: g, axis: 4}
+	case gamepaddb.StandardButtonFrontBottomRight:
+		return axisMappingInput{g: g, axis: 5}
+	case gamepaddb.StandardButtonCenterLeft:
+		return buttonMappingInput{g: g, button: 6}
+	case gamepaddb.StandardButtonCenterRight:
+		return buttonMappingInput{g: g, button: 7}
+	case gamepaddb.StandardButtonLeftStick:
+		return buttonMappingInput{g: g, button: 8}
+	case gamepaddb.StandardButtonRightStick:
+		return buttonMappingInput{g: g, button: 9}
+	case gamepaddb.StandardButtonLeftTop:
+		return hatMappingInput{g: g, hat: 0, direction: hatUp}
+	case gamepaddb.StandardButtonLeftBottom:
+		return hatMappingInput{g: g, hat: 0, direction: hatDown}
+	case gamepaddb.StandardButtonLeftLeft:
+		return hatMappingInput{g: g, hat: 0, direction: hatLeft}
+	case gamepaddb.StandardButtonLeftRight:
+		return hatMappingInput{g: g, hat: 0, direction: hatRight}
+	case gamepaddb.StandardButtonCenterCenter:
+		if !g.xinputGuide {
+			return nil
+		}
+		return buttonMappingInput{g: g, button: len(xinputButtons)}
+	}
 	return nil
 }
 
@@ -596,6 +864,10 @@ func (g *nativeGamepadDesktop) update(gamepads *gamepads) (err error) {
 		gamepads.remove(func(gamepad *Gamepad) bool {
 			return gamepad.native == g
 		})
+		if !g.usesDInput() {
+			// The device might be already disconnected, then the error is ignored.
+			_ = g.rumble.stop()
+		}
 		if g.dinputDevice != nil {
 			g.dinputDevice.Release()
 		}
@@ -686,6 +958,8 @@ func (g *nativeGamepadDesktop) update(gamepads *gamepads) (err error) {
 		return nil
 	}
 
+	g.axisDeadZoneDisabled = gamepads.axisDeadZoneDisabled
+
 	var state _XINPUT_STATE
 	if err := gamepads.native.(*nativeGamepadsDesktop).xinputGetState(uint32(g.xinputIndex), &state); err != nil {
 		if !errors.Is(err, windows.ERROR_DEVICE_NOT_CONNECTED) {
@@ -695,9 +969,61 @@ func (g *nativeGamepadDesktop) update(gamepads *gamepads) (err error) {
 		return nil
 	}
 	g.xinputState = state
+
+	// Stop the motors when the duration elapses, even if vibrate is no longer called.
+	if err := g.rumble.update(time.Now()); err != nil {
+		if !errors.Is(err, windows.ERROR_DEVICE_NOT_CONNECTED) {
+			return err
+		}
+		disconnected = true
+		return nil
+	}
+
+	if time.Since(g.batteryUpdated) >= xinputBatteryUpdateInterval {
+		g.updateXInputBattery(gamepads.native.(*nativeGamepadsDesktop))
+	}
 	return nil
 }
 
+// xinputBatteryUpdateInterval is the interval to query the battery information of XInput devices.
+const xinputBatteryUpdateInterval = time.Second
+
+func (g *nativeGamepadDesktop) updateXInputBattery(gamepads *nativeGamepadsDesktop) {
+	g.batteryUpdated = time.Now()
+	g.batteryLevel_ = -1
+	g.powerState_ = PowerStateUnknown
+
+	if gamepads.procXInputGetBatteryInformation == 0 {
+		return
+	}
+	var info _XINPUT_BATTERY_INFORMATION
+	if err := gamepads.xinputGetBatteryInformation(uint32(g.xinputIndex), _BATTERY_DEVTYPE_GAMEPAD, &info); err != nil {
+		return
+	}
+
+	switch info.BatteryType {
+	case _BATTERY_TYPE_WIRED:
+		g.powerState_ = PowerStateNoBattery
+		return
+	case _BATTERY_TYPE_ALKALINE, _BATTERY_TYPE_NIMH:
+		g.powerState_ = PowerStateOnBattery
+	default:
+		return
+	}
+
+	// XInput reports only coarse levels. Convert them to percentages in the same way as SDL.
+	switch info.BatteryLevel {
+	case _BATTERY_LEVEL_EMPTY:
+		g.batteryLevel_ = 10
+	case _BATTERY_LEVEL_LOW:
+		g.batteryLevel_ = 40
+	case _BATTERY_LEVEL_MEDIUM:
+		g.batteryLevel_ = 70
+	case _BATTERY_LEVEL_FULL:
+		g.batteryLevel_ = 100
+	}
+}
+
 func (g *nativeGamepadDesktop) axisCount() int {
 	if g.usesDInput() {
 		return len(g.dinputAxes)
@@ -709,6 +1035,9 @@ func (g *nativeGamepadDesktop) buttonCount() int {
 	if g.usesDInput() {
 		return len(g.dinputButtons)
 	}
+	if g.xinputGuide {
+		return len(xinputButtons) + 1
+	}
 	return len(xinputButtons)
 }
 
@@ -727,22 +1056,67 @@ func (g *nativeGamepadDesktop) axisValue(axis int) float64 {
 		return g.dinputAxes[axis]
 	}
 
-	var v float64
+	gp := &g.xinputState.Gamepad
 	switch axis {
 	case 0:
-		v = (float64(g.xinputState.Gamepad.sThumbLX) + 0.5) / 32767.5
+		x, _ := g.xinputThumbValues(gp.sThumbLX, gp.sThumbLY, _XINPUT_GAMEPAD_LEFT_THUMB_DEADZONE)
+		return x
 	case 1:
-		v = -(float64(g.xinputState.Gamepad.sThumbLY) + 0.5) / 32767.5
+		_, y := g.xinputThumbValues(gp.sThumbLX, gp.sThumbLY, _XINPUT_GAMEPAD_LEFT_THUMB_DEADZONE)
+		return y
 	case 2:
-		v = (float64(g.xinputState.Gamepad.sThumbRX) + 0.5) / 32767.5
+		x, _ := g.xinputThumbValues(gp.sThumbRX, gp.sThumbRY, _XINPUT_GAMEPAD_RIGHT_THUMB_DEADZONE)
+		return x
 	case 3:
-		v = -(float64(g.xinputState.Gamepad.sThumbRY) + 0.5) / 32767.5
+		_, y := g.xinputThumbValues(gp.sThumbRX, gp.sThumbRY, _XINPUT_GAMEPAD_RIGHT_THUMB_DEADZONE)
+		return y
 	case 4:
-		v = float64(g.xinputState.Gamepad.bLeftTrigger)/127.5 - 1.0
+		return g.xinputTriggerValue(gp.bLeftTrigger)
 	case 5:
-		v = float64(g.xinputState.Gamepad.bRightTrigger)/127.5 - 1.0
+		return g.xinputTriggerValue(gp.bRightTrigger)
 	}
-	return v
+	return 0
+}
+
+// xinputThumbValues returns the values of a thumbstick in [-1, 1]. The Y axis is inverted so that the down direction is positive.
+// Unless the dead zones are disabled, the documented dead zone is applied.
+func (g *nativeGamepadDesktop) xinputThumbValues(rawX, rawY int16, deadZone float64) (float64, float64) {
+	x := (float64(rawX) + 0.5) / 32767.5
+	y := -(float64(rawY) + 0.5) / 32767.5
+	if g.axisDeadZoneDisabled {
+		return x, y
+	}
+	return applyThumbstickDeadZone(x, y, deadZone/32767.5)
+}
+
+// xinputTriggerValue returns the value of a trigger in [-1, 1], where -1 means the trigger is released.
+// Unless the dead zones are disabled, the documented threshold is applied.
+func (g *nativeGamepadDesktop) xinputTriggerValue(raw byte) float64 {
+	v := float64(raw) / 255
+	if !g.axisDeadZoneDisabled {
+		v = applyTriggerThreshold(v, _XINPUT_GAMEPAD_TRIGGER_THRESHOLD/255.0)
+	}
+	return v*2 - 1
+}
+
+// applyThumbstickDeadZone applies the dead zone to a thumbstick as a circle.
+// The rest is rescaled so that the values can still reach -1 and 1.
+func applyThumbstickDeadZone(x, y float64, deadZone float64) (float64, float64) {
+	m := math.Hypot(x, y)
+	if m <= deadZone {
+		return 0, 0
+	}
+	s := math.Min((m-deadZone)/(1-deadZone), 1) / m
+	return x * s, y * s
+}
+
+// applyTriggerThreshold applies the threshold to a trigger value in [0, 1].
+// The rest is rescaled so that the value can still reach 1.
+func applyTriggerThreshold(v float64, threshold float64) float64 {
+	if v <= threshold {
+		return 0
+	}
+	return (v - threshold) / (1 - threshold)
 }
 
 func (g *nativeGamepadDesktop) isButtonPressed(button int) bool {
@@ -753,6 +1127,9 @@ func (g *nativeGamepadDesktop) isButtonPressed(button int) bool {
 		return g.dinputButtons[button]
 	}
 
+	if g.xinputGuide && button == len(xinputButtons) {
+		return g.xinputState.Gamepad.wButtons&_XINPUT_GAMEPAD_GUIDE != 0
+	}
 	if button < 0 || button >= len(xinputButtons) {
 		return false
 	}
@@ -794,5 +1171,28 @@ func (g *nativeGamepadDesktop) hatState(hat int) int {
 }
 
 func (g *nativeGamepadDesktop) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
-	// TODO: Implement this (#1452)
+	// TODO: Implement this for DirectInput devices (#1452)
+	// Until then, DirectInput devices don't vibrate, which is documented at VibrateGamepad.
+	if g.usesDInput() {
+		return
+	}
+	// An error is ignored as vibrate has no way to report it. A disconnection is detected at update.
+	_ = g.rumble.start(time.Now(), duration, strongMagnitude, weakMagnitude)
+}
+
+func (g *nativeGamepadDesktop) setPlayerIndex(index int) {
+}
+
+func (g *nativeGamepadDesktop) batteryLevel() (int, bool) {
+	if g.usesDInput() || g.batteryLevel_ < 0 {
+		return 0, false
+	}
+	return g.batteryLevel_, true
+}
+
+func (g *nativeGamepadDesktop) powerState() PowerState {
+	if g.usesDInput() {
+		return PowerStateUnknown
+	}
+	return g.powerState_
 }
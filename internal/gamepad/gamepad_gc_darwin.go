@@ -0,0 +1,577 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !ios
+
+package gamepad
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebitengine/purego/objc"
+	"golang.org/x/sys/unix"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/cocoa"
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
+)
+
+// gcGamepads is the GameController framework backend.
+// GCController provides the standard layout, analog trigger values, and the correct face buttons of
+// controllers like DualSense and Switch Pro Controller. The devices not claimed by GCController are handled by IOKit HID.
+type gcGamepads struct {
+	observer objc.ID
+
+	// hapticsAvailable reports whether CoreHaptics is available for the haptics of the controllers.
+	hapticsAvailable bool
+
+	// dualSenseHapticsAvailable reports whether GCController provides the haptics of DualSense.
+	// If not, DualSense is handled via raw HID reports instead. See addSonyDevice.
+	dualSenseHapticsAvailable bool
+
+	// controllerEvents is the queue of the connection events. The events are processed at update.
+	controllerEvents []gcControllerEvent
+	m                sync.Mutex
+}
+
+type gcControllerEvent struct {
+	controller objc.ID
+	connected  bool
+}
+
+func newGCGamepads() (*gcGamepads, error) {
+	if err := initializeGameController(); err != nil {
+		return nil, err
+	}
+
+	// +[GCController supportsHIDDevice:] is available on macOS 11 or later.
+	// Without this, it is impossible to avoid detecting the same device by both GCController and IOKit HID.
+	if !respondsToSelector(objc.ID(class_GCController), sel_supportsHIDDevice) {
+		return nil, errors.New("gamepad: +[GCController supportsHIDDevice:] is not available")
+	}
+
+	class, err := objc.RegisterClass(
+		"EbitengineGamepadObserver",
+		objc.GetClass("NSObject"),
+		nil,
+		nil,
+		[]objc.MethodDef{
+			{
+				Cmd: sel_controllerDidConnect,
+				Fn: func(id objc.ID, cmd objc.SEL, notification objc.ID) {
+					theGamepads.native.(*nativeGamepadsImpl).gc.addControllerEvent(cocoa.NSNotification{ID: notification}.Object(), true)
+				},
+			},
+			{
+				Cmd: sel_controllerDidDisconnect,
+				Fn: func(id objc.ID, cmd objc.SEL, notification objc.ID) {
+					theGamepads.native.(*nativeGamepadsImpl).gc.addControllerEvent(cocoa.NSNotification{ID: notification}.Object(), false)
+				},
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hapticsAvailable := initializeCoreHaptics() == nil
+	return &gcGamepads{
+		observer:                  objc.ID(class).Send(sel_new),
+		hapticsAvailable:          hapticsAvailable,
+		dualSenseHapticsAvailable: hapticsAvailable && macOSMajorVersion() >= dualSenseHapticsMinMacOSVersion,
+	}, nil
+}
+
+// dualSenseHapticsMinMacOSVersion is the minimum major version of macOS where GCController provides the haptics of DualSense via Bluetooth.
+const dualSenseHapticsMinMacOSVersion = 12
+
+// macOSMajorVersion returns the major version of macOS, or 0 if the version is unknown.
+func macOSMajorVersion() int {
+	v, err := unix.Sysctl("kern.osproductversion")
+	if err != nil {
+		return 0
+	}
+	major, _, _ := strings.Cut(v, ".")
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func (g *gcGamepads) init() {
+	// Receive the inputs even while the application is in the background, as IOKit HID does.
+	// This is available on macOS 11.3 or later.
+	if respondsToSelector(objc.ID(class_GCController), sel_setShouldMonitorBackgroundEvents) {
+		objc.ID(class_GCController).Send(sel_setShouldMonitorBackgroundEvents, true)
+	}
+
+	center := objc.ID(class_NSNotificationCenter).Send(sel_defaultCenter)
+	center.Send(sel_addObserverSelectorNameObject, g.observer, sel_controllerDidConnect, gcControllerDidConnectNotification, objc.ID(0))
+	center.Send(sel_addObserverSelectorNameObject, g.observer, sel_controllerDidDisconnect, gcControllerDidDisconnectNotification, objc.ID(0))
+
+	controllers := objc.ID(class_GCController).Send(sel_controllers)
+	for i := uintptr(0); i < uintptr(controllers.Send(sel_count)); i++ {
+		g.addControllerEvent(controllers.Send(sel_objectAtIndex, i), true)
+	}
+}
+
+func (g *gcGamepads) addControllerEvent(controller objc.ID, connected bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	// The controller is released when its disconnection is processed.
+	if connected {
+		controller.Send(sel_retain)
+	}
+	g.controllerEvents = append(g.controllerEvents, gcControllerEvent{
+		controller: controller,
+		connected:  connected,
+	})
+}
+
+func (g *gcGamepads) update(gamepads *gamepads) error {
+	g.m.Lock()
+	events := g.controllerEvents
+	g.controllerEvents = nil
+	g.m.Unlock()
+
+	for _, e := range events {
+		if !e.connected {
+			gamepads.remove(func(gamepad *Gamepad) bool {
+				n, ok := gamepad.native.(*nativeGamepadGC)
+				if !ok || n.controller != e.controller {
+					return false
+				}
+				// Clear the player LEDs so that a stale index is not shown, e.g., when the controller is paired with another device.
+				n.setPlayerIndex(-1)
+				n.release()
+				return true
+			})
+			continue
+		}
+
+		if gamepads.find(func(gamepad *Gamepad) bool {
+			n, ok := gamepad.native.(*nativeGamepadGC)
+			return ok && n.controller == e.controller
+		}) != nil {
+			e.controller.Send(sel_release)
+			continue
+		}
+
+		// Ignore a controller without the extended gamepad profile, like Siri Remote.
+		// Such devices are not gamepads.
+		extendedGamepad := e.controller.Send(sel_extendedGamepad)
+		if extendedGamepad == 0 {
+			e.controller.Send(sel_release)
+			continue
+		}
+
+		// DualSense without the haptics is handled via raw HID reports instead.
+		// The product category of DualSense Edge is "DualSense Edge", while IOKit HID treats it as DualSense.
+		if !g.dualSenseHapticsAvailable && strings.HasPrefix(gcControllerProductCategory(e.controller), "DualSense") {
+			e.controller.Send(sel_release)
+			continue
+		}
+
+		name, sdlID := gcControllerNameAndSDLID(e.controller)
+		gp := gamepads.add(name, sdlID)
+		gp.native = newNativeGamepadGC(e.controller, extendedGamepad, g.hapticsAvailable)
+
+		// Keep the player index the system remembers for the controller, e.g., when the controller is reconnected.
+		if index := objc.Send[int](e.controller, sel_playerIndex); index >= 0 && index <= 3 {
+			gp.restorePlayerIndex(index)
+		}
+	}
+
+	return nil
+}
+
+// supportsHIDDevice reports whether the HID device is handled by GCController.
+func (g *gcGamepads) supportsHIDDevice(device _IOHIDDeviceRef) bool {
+	return objc.Send[bool](objc.ID(class_GCController), sel_supportsHIDDevice, device)
+}
+
+// gcControllerProductCategory returns the product category of the controller like "DualSense", or an empty string if unknown.
+func gcControllerProductCategory(controller objc.ID) string {
+	if !respondsToSelector(controller, sel_productCategory) {
+		return ""
+	}
+	c := controller.Send(sel_productCategory)
+	if c == 0 {
+		return ""
+	}
+	return cocoa.NSString{ID: c}.String()
+}
+
+// gcControllerNameAndSDLID returns a name and an SDL ID of the controller.
+// The vendor and the product IDs are guessed from the product category, as SDL's MFi backend does.
+// The SDL ID has a driver signature 'm' so that the mappings for IOKit HID are not applied.
+func gcControllerNameAndSDLID(controller objc.ID) (string, string) {
+	name := "MFi Gamepad"
+	if vendorName := controller.Send(sel_vendorName); vendorName != 0 {
+		name = cocoa.NSString{ID: vendorName}.String()
+	}
+
+	var vendor, product uint16
+	switch gcControllerProductCategory(controller) {
+	case "DualShock 4":
+		vendor = 0x054c
+		product = 0x09cc
+	case "DualSense":
+		vendor = 0x054c
+		product = 0x0ce6
+	case "Xbox One":
+		vendor = 0x045e
+		product = 0x02e0
+	case "Switch Pro Controller":
+		vendor = 0x057e
+		product = 0x2009
+	default:
+		vendor = 0x05ac
+		product = 1
+	}
+
+	return name, sdlGUID(sdlHardwareBusBluetooth, vendor, product, 0, name, sdlDriverSignatureMFi, 0)
+}
+
+type nativeGamepadGC struct {
+	controller objc.ID
+
+	// buttons and axes are the elements of the extended gamepad profile for the standard buttons and axes.
+	// An element is 0 if the controller doesn't have it.
+	buttons [gamepaddb.StandardButtonMax + 1]objc.ID
+	axes    [gamepaddb.StandardAxisMax + 1]objc.ID
+
+	buttonValues  [gamepaddb.StandardButtonMax + 1]float64
+	buttonPressed [gamepaddb.StandardButtonMax + 1]bool
+	axisValues    [gamepaddb.StandardAxisMax + 1]float64
+
+	// haptics is nil if the controller doesn't support haptics.
+	haptics *gcHaptics
+	rumble  rumble
+}
+
+func newNativeGamepadGC(controller, extendedGamepad objc.ID, hapticsAvailable bool) *nativeGamepadGC {
+	n := &nativeGamepadGC{
+		controller: controller,
+	}
+
+	// -[GCController haptics] is available on macOS 11 or later, and is nil if the controller doesn't support haptics.
+	if hapticsAvailable && respondsToSelector(controller, sel_haptics) {
+		if haptics := controller.Send(sel_haptics); haptics != 0 {
+			n.haptics = &gcHaptics{
+				haptics: haptics,
+			}
+			n.rumble.setMotorSpeeds = n.haptics.setMotorSpeeds
+		}
+	}
+
+	element := func(sel objc.SEL) objc.ID {
+		if !respondsToSelector(extendedGamepad, sel) {
+			return 0
+		}
+		return extendedGamepad.Send(sel)
+	}
+
+	// The face buttons of GCExtendedGamepad are positional. For example, buttonA is the bottom button
+	// even on DualSense (Cross) and Switch Pro Controller (B).
+	n.buttons[gamepaddb.StandardButtonRightBottom] = element(sel_buttonA)
+	n.buttons[gamepaddb.StandardButtonRightRight] = element(sel_buttonB)
+	n.buttons[gamepaddb.StandardButtonRightLeft] = element(sel_buttonX)
+	n.buttons[gamepaddb.StandardButtonRightTop] = element(sel_buttonY)
+	n.buttons[gamepaddb.StandardButtonFrontTopLeft] = element(sel_leftShoulder)
+	n.buttons[gamepaddb.StandardButtonFrontTopRight] = element(sel_rightShoulder)
+	n.buttons[gamepaddb.StandardButtonFrontBottomLeft] = element(sel_leftTrigger)
+	n.buttons[gamepaddb.StandardButtonFrontBottomRight] = element(sel_rightTrigger)
+	n.buttons[gamepaddb.StandardButtonCenterLeft] = element(sel_buttonOptions)
+	n.buttons[gamepaddb.StandardButtonCenterRight] = element(sel_buttonMenu)
+	n.buttons[gamepaddb.StandardButtonLeftStick] = element(sel_leftThumbstickButton)
+	n.buttons[gamepaddb.StandardButtonRightStick] = element(sel_rightThumbstickButton)
+	n.buttons[gamepaddb.StandardButtonCenterCenter] = element(sel_buttonHome)
+
+	// The elements below are available only on the subclasses of GCExtendedGamepad like GCXboxGamepad and GCDualSenseGamepad.
+	n.buttons[gamepaddb.StandardButtonMisc1] = element(sel_buttonShare)
+	n.buttons[gamepaddb.StandardButtonPaddle1] = element(sel_paddleButton1)
+	n.buttons[gamepaddb.StandardButtonPaddle2] = element(sel_paddleButton2)
+	n.buttons[gamepaddb.StandardButtonPaddle3] = element(sel_paddleButton3)
+	n.buttons[gamepaddb.StandardButtonPaddle4] = element(sel_paddleButton4)
+	n.buttons[gamepaddb.StandardButtonTouchpad] = element(sel_touchpadButton)
+
+	if dpad := element(sel_dpad); dpad != 0 {
+		n.buttons[gamepaddb.StandardButtonLeftTop] = dpad.Send(sel_up)
+		n.buttons[gamepaddb.StandardButtonLeftBottom] = dpad.Send(sel_down)
+		n.buttons[gamepaddb.StandardButtonLeftLeft] = dpad.Send(sel_left)
+		n.buttons[gamepaddb.StandardButtonLeftRight] = dpad.Send(sel_right)
+	}
+	if stick := element(sel_leftThumbstick); stick != 0 {
+		n.axes[gamepaddb.StandardAxisLeftStickHorizontal] = stick.Send(sel_xAxis)
+		n.axes[gamepaddb.StandardAxisLeftStickVertical] = stick.Send(sel_yAxis)
+	}
+	if stick := element(sel_rightThumbstick); stick != 0 {
+		n.axes[gamepaddb.StandardAxisRightStickHorizontal] = stick.Send(sel_xAxis)
+		n.axes[gamepaddb.StandardAxisRightStickVertical] = stick.Send(sel_yAxis)
+	}
+
+	return n
+}
+
+func (n *nativeGamepadGC) release() {
+	if n.haptics != nil {
+		_ = n.rumble.stop()
+		n.haptics.release()
+	}
+	n.controller.Send(sel_release)
+}
+
+func (n *nativeGamepadGC) update(gamepads *gamepads) error {
+	if n.haptics != nil {
+		// Stop the haptics when the duration elapses, even if vibrate is no longer called.
+		_ = n.rumble.update(time.Now())
+	}
+
+	for i, b := range n.buttons {
+		if b == 0 {
+			continue
+		}
+		n.buttonValues[i] = float64(objc.Send[float32](b, sel_value))
+		n.buttonPressed[i] = objc.Send[bool](b, sel_isPressed)
+	}
+	for i, a := range n.axes {
+		if a == 0 {
+			continue
+		}
+		n.axisValues[i] = float64(objc.Send[float32](a, sel_value))
+	}
+	// The Y axes of GCControllerDirectionPad are positive upward, while the standard layout's are positive downward.
+	n.axisValues[gamepaddb.StandardAxisLeftStickVertical] *= -1
+	n.axisValues[gamepaddb.StandardAxisRightStickVertical] *= -1
+	return nil
+}
+
+func (n *nativeGamepadGC) hasOwnStandardLayoutMapping() bool {
+	return true
+}
+
+func (n *nativeGamepadGC) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
+	if axis < 0 || axis > gamepaddb.StandardAxisMax || n.axes[axis] == 0 {
+		return nil
+	}
+	return axisMappingInput{g: n, axis: int(axis)}
+}
+
+func (n *nativeGamepadGC) standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput {
+	if button < 0 || button > gamepaddb.StandardButtonMax || n.buttons[button] == 0 {
+		return nil
+	}
+	return buttonMappingInput{g: n, button: int(button)}
+}
+
+func (n *nativeGamepadGC) axisCount() int {
+	return len(n.axisValues)
+}
+
+func (n *nativeGamepadGC) buttonCount() int {
+	return len(n.buttonValues)
+}
+
+func (n *nativeGamepadGC) hatCount() int {
+	return 0
+}
+
+func (n *nativeGamepadGC) axisValue(axis int) float64 {
+	if axis < 0 || axis >= len(n.axisValues) {
+		return 0
+	}
+	return n.axisValues[axis]
+}
+
+func (n *nativeGamepadGC) buttonValue(button int) float64 {
+	if button < 0 || button >= len(n.buttonValues) {
+		return 0
+	}
+	return n.buttonValues[button]
+}
+
+func (n *nativeGamepadGC) isButtonPressed(button int) bool {
+	if button < 0 || button >= len(n.buttonPressed) {
+		return false
+	}
+	return n.buttonPressed[button]
+}
+
+func (n *nativeGamepadGC) hatState(hat int) int {
+	return hatCentered
+}
+
+func (n *nativeGamepadGC) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+	if n.haptics == nil {
+		return
+	}
+	_ = n.rumble.start(time.Now(), duration, strongMagnitude, weakMagnitude)
+}
+
+func (n *nativeGamepadGC) setPlayerIndex(index int) {
+	// GCControllerPlayerIndex supports only four players. -1 is GCControllerPlayerIndexUnset.
+	if index < 0 || index > 3 {
+		index = -1
+	}
+	n.controller.Send(sel_setPlayerIndex, index)
+}
+
+func (n *nativeGamepadGC) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (n *nativeGamepadGC) powerState() PowerState {
+	return PowerStateUnknown
+}
+
+// gcHaptics plays a continuous haptic event on the default locality of a controller.
+// The engine and the player are created lazily and are reused, as creating an engine is expensive.
+type gcHaptics struct {
+	haptics objc.ID
+	engine  objc.ID
+	player  objc.ID
+	playing bool
+}
+
+// setMotorSpeeds starts or updates the haptic event. This is used as rumble's setMotorSpeeds.
+//
+// The strong (low-frequency) and the weak (high-frequency) magnitudes are blended into one event:
+// the intensity is the larger magnitude, and the sharpness is the weak magnitude's proportion.
+func (h *gcHaptics) setMotorSpeeds(low, high uint16) error {
+	pool := cocoa.NSAutoreleasePool_new()
+	defer pool.Release()
+
+	if low == 0 && high == 0 {
+		return h.stop()
+	}
+
+	intensity := float32(low) / 0xffff
+	if v := float32(high) / 0xffff; intensity < v {
+		intensity = v
+	}
+	sharpness := float32(high) / (float32(low) + float32(high))
+
+	if err := h.play(intensity, sharpness); err != nil {
+		// The engine might be stopped by the system, e.g., when the application is in the background.
+		// CHHapticEngine's stoppedHandler and resetHandler require Objective-C blocks, which are not available here.
+		// Instead, recreate the player with the restarted engine and retry once.
+		h.releasePlayer()
+		if h.engine != 0 && !objc.Send[bool](h.engine, sel_startAndReturnError, objc.ID(0)) {
+			return errors.New("gamepad: -[CHHapticEngine startAndReturnError:] failed")
+		}
+		return h.play(intensity, sharpness)
+	}
+	return nil
+}
+
+func (h *gcHaptics) play(intensity, sharpness float32) error {
+	if h.engine == 0 {
+		engine := h.haptics.Send(sel_createEngineWithLocality, gcHapticsLocalityDefault)
+		if engine == 0 {
+			return errors.New("gamepad: -[GCDeviceHaptics createEngineWithLocality:] failed")
+		}
+		h.engine = engine.Send(sel_retain)
+		if !objc.Send[bool](h.engine, sel_startAndReturnError, objc.ID(0)) {
+			return errors.New("gamepad: -[CHHapticEngine startAndReturnError:] failed")
+		}
+	}
+
+	if h.player == 0 {
+		player, err := h.createPlayer()
+		if err != nil {
+			return err
+		}
+		h.player = player
+	}
+
+	// Update the running event in place with the dynamic parameters.
+	intensityParam := objc.ID(class_CHHapticDynamicParameter).Send(sel_alloc).Send(sel_initWithParameterIDValueTime, chHapticDynamicParameterIDHapticIntensityControl, intensity, float64(0))
+	defer intensityParam.Send(sel_release)
+	sharpnessParam := objc.ID(class_CHHapticDynamicParameter).Send(sel_alloc).Send(sel_initWithParameterIDValueTime, chHapticDynamicParameterIDHapticSharpnessControl, sharpness, float64(0))
+	defer sharpnessParam.Send(sel_release)
+	if !objc.Send[bool](h.player, sel_sendParametersAtTime, nsArray(intensityParam, sharpnessParam), float64(0), objc.ID(0)) {
+		return errors.New("gamepad: -[CHHapticAdvancedPatternPlayer sendParameters:atTime:error:] failed")
+	}
+
+	if !h.playing {
+		if !objc.Send[bool](h.player, sel_startAtTime, float64(0), objc.ID(0)) {
+			return errors.New("gamepad: -[CHHapticAdvancedPatternPlayer startAtTime:error:] failed")
+		}
+		h.playing = true
+	}
+	return nil
+}
+
+// createPlayer creates a player of a continuous event with the full intensity and the zero sharpness.
+// The actual intensity and sharpness are given as the dynamic parameters.
+func (h *gcHaptics) createPlayer() (objc.ID, error) {
+	intensity := objc.ID(class_CHHapticEventParameter).Send(sel_alloc).Send(sel_initWithParameterIDValue, chHapticEventParameterIDHapticIntensity, float32(1))
+	defer intensity.Send(sel_release)
+	sharpness := objc.ID(class_CHHapticEventParameter).Send(sel_alloc).Send(sel_initWithParameterIDValue, chHapticEventParameterIDHapticSharpness, float32(0))
+	defer sharpness.Send(sel_release)
+
+	event := objc.ID(class_CHHapticEvent).Send(sel_alloc).Send(sel_initWithEventType, chHapticEventTypeHapticContinuous, nsArray(intensity, sharpness), float64(0), float64(gcHapticDurationInfinite))
+	if event == 0 {
+		return 0, errors.New("gamepad: -[CHHapticEvent initWithEventType:parameters:relativeTime:duration:] failed")
+	}
+	defer event.Send(sel_release)
+
+	pattern := objc.ID(class_CHHapticPattern).Send(sel_alloc).Send(sel_initWithEventsParameters, nsArray(event), nsArray(), objc.ID(0))
+	if pattern == 0 {
+		return 0, errors.New("gamepad: -[CHHapticPattern initWithEvents:parameters:error:] failed")
+	}
+	defer pattern.Send(sel_release)
+
+	player := h.engine.Send(sel_createAdvancedPlayerWithPattern, pattern, objc.ID(0))
+	if player == 0 {
+		return 0, errors.New("gamepad: -[CHHapticEngine createAdvancedPlayerWithPattern:error:] failed")
+	}
+	return player.Send(sel_retain), nil
+}
+
+func (h *gcHaptics) stop() error {
+	if h.player == 0 || !h.playing {
+		return nil
+	}
+	h.playing = false
+	if !objc.Send[bool](h.player, sel_stopAtTime, float64(0), objc.ID(0)) {
+		return errors.New("gamepad: -[CHHapticAdvancedPatternPlayer stopAtTime:error:] failed")
+	}
+	return nil
+}
+
+func (h *gcHaptics) releasePlayer() {
+	if h.player == 0 {
+		return
+	}
+	h.player.Send(sel_release)
+	h.player = 0
+	h.playing = false
+}
+
+func (h *gcHaptics) release() {
+	h.releasePlayer()
+	if h.engine == 0 {
+		return
+	}
+	h.engine.Send(sel_stopWithCompletionHandler, objc.ID(0))
+	h.engine.Send(sel_release)
+	h.engine = 0
+}
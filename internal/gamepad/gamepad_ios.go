@@ -20,7 +20,10 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
 )
 
-type nativeGamepadsImpl struct{}
+type nativeGamepadsImpl struct {
+	// virtualGamepadElements is the elements of the currently shown virtual gamepad.
+	virtualGamepadElements VirtualGamepadElement
+}
 
 func newNativeGamepadsImpl() nativeGamepads {
 	return &nativeGamepadsImpl{}
@@ -35,6 +38,31 @@ func (*nativeGamepadsImpl) update(gamepads *gamepads) error {
 	return nil
 }
 
+func (g *nativeGamepadsImpl) updateVirtualGamepad(gamepads *gamepads) {
+	elements := gamepads.virtualGamepadElements
+
+	// The virtual gamepad is dismissed while the application is in background or a physical gamepad is connected.
+	if gamepads.unfocused {
+		elements = 0
+	}
+	if gamepads.find(func(gamepad *Gamepad) bool {
+		n, ok := gamepad.native.(*nativeGamepadImpl)
+		return ok && !n.isVirtual
+	}) != nil {
+		elements = 0
+	}
+
+	if g.virtualGamepadElements == elements {
+		return
+	}
+	g.virtualGamepadElements = elements
+	if elements == 0 {
+		disconnectIOSVirtualGamepad()
+		return
+	}
+	connectIOSVirtualGamepad(elements)
+}
+
 type nativeGamepadImpl struct {
 	controller           uintptr
 	buttonMask           uint16
@@ -42,12 +70,23 @@ type nativeGamepadImpl struct {
 	hasXboxPaddles       bool
 	hasXboxShareButton   bool
 
+	// isVirtual reports whether the gamepad is the on-screen virtual gamepad.
+	isVirtual bool
+
+	// haptics is a pointer to the C haptics state, or 0 if the controller doesn't support haptics.
+	haptics uintptr
+	rumble  rumble
+
 	axes    []float64
 	buttons []bool
 	hats    []int
 }
 
 func (g *nativeGamepadImpl) update(gamepad *gamepads) error {
+	if g.haptics != 0 {
+		// Stop the haptics when the duration elapses, even if vibrate is no longer called.
+		_ = g.rumble.update(time.Now())
+	}
 	g.updateIOSGamepad()
 	return nil
 }
@@ -105,5 +144,31 @@ func (g *nativeGamepadImpl) hatState(hat int) int {
 }
 
 func (g *nativeGamepadImpl) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
-	// TODO: Implement this (#1452)
+	if g.haptics == 0 {
+		return
+	}
+	_ = g.rumble.start(time.Now(), duration, strongMagnitude, weakMagnitude)
+}
+
+func (g *nativeGamepadImpl) setPlayerIndex(index int) {
+	// The virtual gamepad doesn't have player LEDs.
+	if g.isVirtual {
+		return
+	}
+	g.setIOSPlayerIndex(index)
+}
+
+func (g *nativeGamepadImpl) busType() BusType {
+	if g.isVirtual {
+		return BusTypeVirtual
+	}
+	return BusTypeUnknown
+}
+
+func (g *nativeGamepadImpl) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (g *nativeGamepadImpl) powerState() PowerState {
+	return PowerStateUnknown
 }
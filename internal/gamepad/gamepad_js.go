@@ -15,7 +15,7 @@
 package gamepad
 
 import (
-	"encoding/hex"
+	"math"
 	"syscall/js"
 	"time"
 
@@ -24,10 +24,30 @@ import (
 
 var (
 	object = js.Global().Get("Object")
+
+	// ignorePromiseRejection is used to ignore the rejections of the vibration promises.
+	// A promise is rejected e.g. when the gamepad is disconnected or the effect is not supported.
+	ignorePromiseRejection = js.FuncOf(func(this js.Value, args []js.Value) any {
+		return nil
+	})
 )
 
 type nativeGamepadsImpl struct {
-	indices map[int]struct{}
+	// events is the queue of the gamepadconnected and gamepaddisconnected events. The events are processed at update.
+	// This doesn't have to be protected by a mutex, as the event handlers are never called during update.
+	events []gamepadEvent
+
+	onGamepadConnected    js.Func
+	onGamepadDisconnected js.Func
+
+	// webHID is used only after the enhanced access is requested or was granted in the previous sessions.
+	webHID webHID
+}
+
+type gamepadEvent struct {
+	index     int
+	id        string
+	connected bool
 }
 
 func newNativeGamepadsImpl() nativeGamepads {
@@ -35,18 +55,6 @@ func newNativeGamepadsImpl() nativeGamepads {
 }
 
 func (g *nativeGamepadsImpl) init(gamepads *gamepads) error {
-	return nil
-}
-
-func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
-	// TODO: Use the gamepad events instead of navigator.getGamepads.
-
-	defer func() {
-		for k := range g.indices {
-			delete(g.indices, k)
-		}
-	}()
-
 	nav := js.Global().Get("navigator")
 	if !nav.Truthy() {
 		return nil
@@ -58,64 +66,244 @@ func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
 		return nil
 	}
 
+	window := js.Global().Get("window")
+	if !window.Truthy() {
+		return nil
+	}
+
+	g.onGamepadConnected = js.FuncOf(func(this js.Value, args []js.Value) any {
+		gp := args[0].Get("gamepad")
+		g.events = append(g.events, gamepadEvent{
+			index:     gp.Get("index").Int(),
+			id:        gp.Get("id").String(),
+			connected: true,
+		})
+		return nil
+	})
+	g.onGamepadDisconnected = js.FuncOf(func(this js.Value, args []js.Value) any {
+		gp := args[0].Get("gamepad")
+		g.events = append(g.events, gamepadEvent{
+			index:     gp.Get("index").Int(),
+			id:        gp.Get("id").String(),
+			connected: false,
+		})
+		return nil
+	})
+	window.Call("addEventListener", "gamepadconnected", g.onGamepadConnected)
+	window.Call("addEventListener", "gamepaddisconnected", g.onGamepadDisconnected)
+
+	g.webHID.init()
+
+	// The gamepads connected before the listeners are added might already be listed.
+	// Note that Chrome doesn't list a gamepad until a button is pressed, and fires gamepadconnected at that time.
 	gps := nav.Call("getGamepads")
 	if !gps.Truthy() {
 		return nil
 	}
-
-	l := gps.Length()
-	for idx := 0; idx < l; idx++ {
-		gp := gps.Index(idx)
+	for i := 0; i < gps.Length(); i++ {
+		gp := gps.Index(i)
 		if !gp.Truthy() {
 			continue
 		}
-		index := gp.Get("index").Int()
+		g.events = append(g.events, gamepadEvent{
+			index:     gp.Get("index").Int(),
+			id:        gp.Get("id").String(),
+			connected: true,
+		})
+	}
+
+	return nil
+}
 
-		if g.indices == nil {
-			g.indices = map[int]struct{}{}
+func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
+	for _, e := range g.events {
+		if !e.connected {
+			gamepads.remove(func(gamepad *Gamepad) bool {
+				n, ok := gamepad.native.(*nativeGamepadImpl)
+				return ok && n.index == e.index
+			})
+			continue
 		}
-		g.indices[index] = struct{}{}
 
-		// The gamepad is not registered yet, register this.
-		gamepad := gamepads.find(func(gamepad *Gamepad) bool {
-			return index == gamepad.native.(*nativeGamepadImpl).index
-		})
-		if gamepad == nil {
-			name := gp.Get("id").String()
+		// An index can be reused by another gamepad after a disconnection.
+		// If the disconnection is missed, replace the gamepad.
+		if gamepad := gamepads.find(func(gamepad *Gamepad) bool {
+			n, ok := gamepad.native.(*nativeGamepadImpl)
+			return ok && n.index == e.index
+		}); gamepad != nil {
+			if gamepad.Name() == e.id {
+				continue
+			}
+			gamepads.remove(func(gp *Gamepad) bool {
+				return gp == gamepad
+			})
+		}
 
-			// This emulates the implementation of EMSCRIPTEN_JoystickGetDeviceGUID.
-			// https://github.com/libsdl-org/SDL/blob/0e9560aea22818884921e5e5064953257bfe7fa7/src/joystick/emscripten/SDL_sysjoystick.c#L385
-			var sdlID [16]byte
-			copy(sdlID[:], []byte(name))
+		gamepad := gamepads.add(e.id, emscriptenSDLGUID(e.id))
+		gamepad.native = &nativeGamepadImpl{
+			index:       e.index,
+			playerIndex: -1,
+		}
+	}
+	g.events = g.events[:0]
 
-			gamepad = gamepads.add(name, hex.EncodeToString(sdlID[:]))
-			gamepad.native = &nativeGamepadImpl{
-				index:   index,
-				mapping: gp.Get("mapping").String(),
-			}
+	if gamepads.find(func(gamepad *Gamepad) bool {
+		_, ok := gamepad.native.(*nativeGamepadImpl)
+		return ok
+	}) == nil {
+		return nil
+	}
+
+	// Read the states only of the connected gamepads. The states are snapshots on some browsers like Chrome,
+	// so getGamepads has to be called every frame.
+	gps := js.Global().Get("navigator").Call("getGamepads")
+	gamepadAt := func(index int) js.Value {
+		if !gps.Truthy() || index >= gps.Length() {
+			return js.Null()
 		}
-		gamepad.native.(*nativeGamepadImpl).value = gp
+		return gps.Index(index)
 	}
 
-	// Remove an unused gamepads.
+	// getGamepads might transiently return no gamepads, e.g., during some browsers' GC pauses.
+	// Keep the last states in this case. Actual disconnections are notified by gamepaddisconnected events.
+	if !hasAnyGamepad(gps) {
+		return nil
+	}
+
+	// The gamepad might be disconnected without an event.
 	gamepads.remove(func(gamepad *Gamepad) bool {
-		_, ok := g.indices[gamepad.native.(*nativeGamepadImpl).index]
-		return !ok
+		n, ok := gamepad.native.(*nativeGamepadImpl)
+		return ok && !gamepadAt(n.index).Truthy()
 	})
 
+	for _, gamepad := range gamepads.gamepads {
+		if gamepad == nil {
+			continue
+		}
+		n, ok := gamepad.native.(*nativeGamepadImpl)
+		if !ok {
+			continue
+		}
+		n.updateSnapshot(gamepadAt(n.index))
+	}
+
+	g.webHID.update(gamepads)
+
 	return nil
 }
 
+// requestEnhancedAccess requests the access to the gamepads via WebHID.
+func (g *nativeGamepadsImpl) requestEnhancedAccess() {
+	g.webHID.requestAccess()
+}
+
+// updateVirtualGamepad adds or removes the touch-driven virtual gamepad.
+// The virtual gamepad is available only while no gamepad is connected via the Gamepad API.
+func (g *nativeGamepadsImpl) updateVirtualGamepad(gamepads *gamepads) {
+	elements := gamepads.virtualGamepadElements & touchGamepadElements
+	if gamepads.find(func(gamepad *Gamepad) bool {
+		_, ok := gamepad.native.(*nativeGamepadImpl)
+		return ok
+	}) != nil {
+		elements = 0
+	}
+
+	if gamepad := gamepads.find(func(gamepad *Gamepad) bool {
+		_, ok := gamepad.native.(*nativeGamepadTouch)
+		return ok
+	}); gamepad != nil {
+		if n := gamepad.native.(*nativeGamepadTouch); n.elements == elements {
+			return
+		}
+		gamepads.remove(func(gp *Gamepad) bool {
+			return gp == gamepad
+		})
+	}
+	if elements == 0 {
+		return
+	}
+
+	// The SDL ID is in the same format as SDL's virtual joysticks, whose bus is 0xff and driver signature is 'v'.
+	gamepad := gamepads.add("Touch Virtual Gamepad", "ff000000000000000000000000007600")
+	gamepad.native = &nativeGamepadTouch{
+		elements: elements,
+	}
+}
+
+// hasAnyGamepad reports whether the result of getGamepads has at least one gamepad.
+func hasAnyGamepad(gps js.Value) bool {
+	if !gps.Truthy() {
+		return false
+	}
+	for i := 0; i < gps.Length(); i++ {
+		if gps.Index(i).Truthy() {
+			return true
+		}
+	}
+	return false
+}
+
 type nativeGamepadImpl struct {
 	value   js.Value
 	index   int
 	mapping string
+
+	// snapshot is the state of the axes and the buttons in the latest valid snapshot.
+	// The numbers of the axes and the buttons might change between frames on some browsers like Firefox.
+	snapshot inputSnapshot
+
+	// hid is the same gamepad via WebHID for the motion sensors, the touchpad, the rumble, and the lights.
+	// hid is nil unless the enhanced access is granted.
+	hid       *nativeGamepadSony
+	hidDevice *webHIDDevice
+
+	playerIndex int
+}
+
+func (g *nativeGamepadImpl) attachHID(device *webHIDDevice) {
+	g.hidDevice = device
+	g.hid = newNativeGamepadSony(device, sonyDeviceInfo{
+		vendor:    device.vendor,
+		product:   device.product,
+		bluetooth: device.bluetooth,
+	}, device.model)
+	g.hid.setPlayerIndex(g.playerIndex)
+}
+
+func (g *nativeGamepadImpl) detachHID() {
+	g.hid = nil
+	g.hidDevice = nil
+}
+
+func (g *nativeGamepadImpl) updateSnapshot(value js.Value) {
+	axes := value.Get("axes")
+	buttons := value.Get("buttons")
+	if !g.snapshot.update(axes.Length(), buttons.Length(), func(index int) float64 {
+		return axes.Index(index).Float()
+	}, func(index int) (float64, bool) {
+		b := buttons.Index(index)
+		return b.Get("value").Float(), b.Get("pressed").Bool()
+	}) {
+		return
+	}
+	g.value = value
+	// The mapping might be updated after the gamepad is connected.
+	g.mapping = value.Get("mapping").String()
 }
 
+// hasOwnStandardLayoutMapping reports whether the browser maps the gamepad to the W3C standard layout.
+// The indices of the standard buttons and axes are the same as the browser's.
+// If the mapping is empty, the gamepad database is used instead.
 func (g *nativeGamepadImpl) hasOwnStandardLayoutMapping() bool {
 	return g.mapping == "standard"
 }
 
+// isNormalizedToStandardLayout reports whether the browser already normalizes the inputs to the standard layout.
+// In this case, a mapping in the gamepad database must not be applied, as the mapping assumes the raw inputs.
+func (g *nativeGamepadImpl) isNormalizedToStandardLayout() bool {
+	return g.hasOwnStandardLayoutMapping()
+}
+
 func (g *nativeGamepadImpl) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
 	if !g.hasOwnStandardLayoutMapping() {
 		return nil
@@ -130,22 +318,30 @@ func (g *nativeGamepadImpl) standardButtonInOwnMapping(button gamepaddb.Standard
 	if !g.hasOwnStandardLayoutMapping() {
 		return nil
 	}
-	if button < 0 || int(button) >= g.buttonCount() {
+	// The standard mapping of the Gamepad API has only the web standard buttons.
+	// Some browsers report more buttons like a touchpad, but their indices are not standardized.
+	if button < 0 || button > gamepaddb.StandardButtonCenterCenter || int(button) >= g.buttonCount() {
 		return nil
 	}
 	return buttonMappingInput{g: g, button: int(button)}
 }
 
 func (g *nativeGamepadImpl) update(gamepads *gamepads) error {
+	if g.hid != nil {
+		// An error of WebHID doesn't disconnect the gamepad, as the Gamepad API is still available.
+		if err := g.hid.update(gamepads); err != nil {
+			g.detachHID()
+		}
+	}
 	return nil
 }
 
 func (g *nativeGamepadImpl) axisCount() int {
-	return g.value.Get("axes").Length()
+	return g.snapshot.axisCount()
 }
 
 func (g *nativeGamepadImpl) buttonCount() int {
-	return g.value.Get("buttons").Length()
+	return g.snapshot.buttonCount()
 }
 
 func (g *nativeGamepadImpl) hatCount() int {
@@ -153,27 +349,15 @@ func (g *nativeGamepadImpl) hatCount() int {
 }
 
 func (g *nativeGamepadImpl) axisValue(axis int) float64 {
-	axes := g.value.Get("axes")
-	if axis < 0 || axis >= axes.Length() {
-		return 0
-	}
-	return axes.Index(axis).Float()
+	return g.snapshot.axisValue(axis)
 }
 
 func (g *nativeGamepadImpl) buttonValue(button int) float64 {
-	buttons := g.value.Get("buttons")
-	if button < 0 || button >= buttons.Length() {
-		return 0
-	}
-	return buttons.Index(button).Get("value").Float()
+	return g.snapshot.buttonValue(button)
 }
 
 func (g *nativeGamepadImpl) isButtonPressed(button int) bool {
-	buttons := g.value.Get("buttons")
-	if button < 0 || button >= buttons.Length() {
-		return false
-	}
-	return buttons.Index(button).Get("pressed").Bool()
+	return g.snapshot.isButtonPressed(button)
 }
 
 func (g *nativeGamepadImpl) hatState(hat int) int {
@@ -181,30 +365,116 @@ func (g *nativeGamepadImpl) hatState(hat int) int {
 }
 
 func (g *nativeGamepadImpl) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
-	// vibrationActuator is available on Chrome.
+	// The output reports via WebHID drive the motors more reliably than the Gamepad API.
+	if g.hid != nil {
+		g.hid.vibrate(duration, strongMagnitude, weakMagnitude)
+		return
+	}
+
+	// value is not set until a valid snapshot is received.
+	if !g.value.Truthy() {
+		return
+	}
+
+	// vibrationActuator is available on Chrome and Edge.
 	if va := g.value.Get("vibrationActuator"); va.Truthy() {
-		if !va.Get("playEffect").Truthy() {
+		// Stop the playing effect explicitly, as an effect with no duration might not replace the playing effect.
+		if duration <= 0 || (strongMagnitude <= 0 && weakMagnitude <= 0) {
+			if va.Get("reset").Truthy() {
+				va.Call("reset").Call("catch", ignorePromiseRejection)
+			}
 			return
 		}
 
+		if !va.Get("playEffect").Truthy() || !supportsDualRumble(va) {
+			return
+		}
+
+		// A new effect preempts the playing effect, so repeated calls don't queue the effects.
 		prop := object.New()
 		prop.Set("startDelay", 0)
 		prop.Set("duration", float64(duration/time.Millisecond))
 		prop.Set("strongMagnitude", strongMagnitude)
 		prop.Set("weakMagnitude", weakMagnitude)
-		va.Call("playEffect", "dual-rumble", prop)
+		va.Call("playEffect", "dual-rumble", prop).Call("catch", ignorePromiseRejection)
 		return
 	}
 
 	// hapticActuators is available on Firefox.
+	// The Gamepad Extensions spec doesn't define which motor each actuator drives, and Firefox usually exposes only one actuator.
+	// Then, pulse all the actuators with the larger magnitude instead of assuming an order.
+	// See https://w3c.github.io/gamepad/extensions.html
 	if ha := g.value.Get("hapticActuators"); ha.Truthy() {
-		// TODO: Is this order correct?
-		if ha.Length() > 0 {
-			ha.Index(0).Call("pulse", strongMagnitude, float64(duration/time.Millisecond))
-		}
-		if ha.Length() > 1 {
-			ha.Index(1).Call("pulse", weakMagnitude, float64(duration/time.Millisecond))
+		m := math.Max(strongMagnitude, weakMagnitude)
+		for i := 0; i < ha.Length(); i++ {
+			a := ha.Index(i)
+			if !a.Get("pulse").Truthy() {
+				continue
+			}
+			if p := a.Call("pulse", m, float64(duration/time.Millisecond)); p.Truthy() && p.Get("catch").Truthy() {
+				p.Call("catch", ignorePromiseRejection)
+			}
 		}
 		return
 	}
 }
+
+// supportsDualRumble reports whether the vibration actuator supports the "dual-rumble" effect.
+func supportsDualRumble(vibrationActuator js.Value) bool {
+	// effects is available on newer browsers.
+	if effects := vibrationActuator.Get("effects"); effects.Truthy() {
+		return effects.Call("includes", "dual-rumble").Bool()
+	}
+	// type is deprecated, but is still available on older browsers.
+	if t := vibrationActuator.Get("type"); t.Type() == js.TypeString {
+		return t.String() == "dual-rumble"
+	}
+	return true
+}
+
+func (g *nativeGamepadImpl) setPlayerIndex(index int) {
+	g.playerIndex = index
+	if g.hid != nil {
+		g.hid.setPlayerIndex(index)
+	}
+}
+
+func (g *nativeGamepadImpl) batteryLevel() (int, bool) {
+	if g.hid != nil {
+		return g.hid.batteryLevel()
+	}
+	return 0, false
+}
+
+func (g *nativeGamepadImpl) powerState() PowerState {
+	if g.hid != nil {
+		return g.hid.powerState()
+	}
+	return PowerStateUnknown
+}
+
+func (g *nativeGamepadImpl) hasMotionSensor() bool {
+	return g.hid != nil && g.hid.hasMotionSensor()
+}
+
+func (g *nativeGamepadImpl) motionSensorValue(axis MotionSensorAxis) float64 {
+	if g.hid == nil {
+		return 0
+	}
+	return g.hid.motionSensorValue(axis)
+}
+
+func (g *nativeGamepadImpl) hasTouchpad() bool {
+	return g.hid != nil && g.hid.hasTouchpad()
+}
+
+func (g *nativeGamepadImpl) isTouchpadPressed() bool {
+	return g.hid != nil && g.hid.isTouchpadPressed()
+}
+
+func (g *nativeGamepadImpl) touchpadTouchPosition(index int) (float64, float64, bool) {
+	if g.hid == nil {
+		return 0, 0, false
+	}
+	return g.hid.touchpadTouchPosition(index)
+}
@@ -17,16 +17,27 @@
 package gamepad
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
+	"math/bits"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
 
+	"github.com/hajimehoshi/ebiten/v2/internal/debug"
 	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
 )
 
@@ -34,13 +45,112 @@ const dirName = "/dev/input"
 
 var reEvent = regexp.MustCompile(`^event[0-9]+$`)
 
+// rePlayerLED matches the names of player indicator LEDs in /sys/class/leds,
+// e.g., "0005:054C:0CE6.0001:white:player-1" (hid-playstation) or "0005:057E:2009.0001:player1" (hid-nintendo).
+var rePlayerLED = regexp.MustCompile(`:player-?([0-9]+)$`)
+
 func isBitSet(s []byte, bit int) bool {
 	return s[bit/8]&(1<<(bit%8)) != 0
 }
 
+// isGamepadDevice reports whether the device with the given capabilities looks like a gamepad or a joystick.
+// Accelerometers, touchpads, tablets, and keyboards also have both EV_KEY and EV_ABS, and must be skipped.
+//
+// This is based on SDL_EVDEV_GuessDeviceClass.
+func isGamepadDevice(evBits, keyBits, absBits []byte) bool {
+	if !isBitSet(evBits, unix.EV_KEY) {
+		return false
+	}
+	if !isBitSet(evBits, unix.EV_ABS) {
+		return false
+	}
+
+	// A keyboard has all the keys from KEY_ESC to KEY_S.
+	isKeyboard := true
+	for code := _KEY_ESC; code <= _KEY_S; code++ {
+		if !isBitSet(keyBits, code) {
+			isKeyboard = false
+			break
+		}
+	}
+	if isKeyboard {
+		return false
+	}
+
+	// A gamepad or a joystick must have at least one button in the BTN_JOYSTICK or BTN_GAMEPAD range.
+	// Some devices like the Xbox Adaptive Controller and arcade sticks have only buttons in the BTN_DPAD or BTN_TRIGGER_HAPPY range.
+	// This is the same as udev's input_id.
+	var hasButton bool
+	for code := _BTN_JOYSTICK; code < _BTN_DIGI; code++ {
+		if isBitSet(keyBits, code) {
+			hasButton = true
+			break
+		}
+	}
+	for code := _BTN_DPAD_UP; code <= _BTN_DPAD_RIGHT && !hasButton; code++ {
+		hasButton = isBitSet(keyBits, code)
+	}
+	for code := _BTN_TRIGGER_HAPPY1; code <= _BTN_TRIGGER_HAPPY40 && !hasButton; code++ {
+		hasButton = isBitSet(keyBits, code)
+	}
+	if !hasButton {
+		return false
+	}
+
+	if isBitSet(absBits, _ABS_X) && isBitSet(absBits, _ABS_Y) {
+		return true
+	}
+	if isBitSet(absBits, _ABS_HAT0X) && isBitSet(absBits, _ABS_HAT0Y) {
+		return true
+	}
+	return false
+}
+
 type nativeGamepadsImpl struct {
 	inotify int
 	watch   int
+
+	// inotifyBuf and inotifyEvents are the buffers to read inotify events, which are reused in every update.
+	inotifyBuf    [16384]byte
+	inotifyEvents []inotifyEvent
+
+	// uevent is a netlink socket to receive uevents.
+	uevent    int
+	ueventBuf [8192]byte
+
+	// epoll is an epoll instance to poll the file descriptors of the devices and the hotplug notifications.
+	// epoll is 0 if epoll is not available. In this case, all the file descriptors are read in every update.
+	epoll       int
+	epollEvents [64]unix.EpollEvent
+
+	// readyFDs is the set of the readable file descriptors at the current update.
+	// allReady reports whether all the file descriptors should be treated as readable.
+	readyFDs map[int32]struct{}
+	allReady bool
+
+	// lastScan is the last time when the device directory was scanned.
+	// The directory is scanned periodically when neither uevents nor inotify is available.
+	lastScan time.Time
+
+	// motionSensors is the list of the opened motion sensor devices.
+	// A motion sensor might exist without its gamepad e.g. just after the gamepad is disconnected.
+	motionSensors []*motionSensor
+
+	// openRetries is the state to retry opening the device files that are not accessible, by their paths.
+	openRetries map[string]*openRetry
+
+	// touchpads is the list of the opened touchpad devices of gamepads.
+	// A touchpad might exist without its gamepad in the same way as a motion sensor.
+	touchpads []*touchpad
+
+	// steamInputDevices is the set of the vendor and product IDs of the physical gamepads that Steam Input exposes as virtual gamepads.
+	// hiddenPaths is the set of the paths of such physical gamepads, which are hidden while a Steam virtual gamepad exists.
+	steamInputDevices map[[2]uint16]struct{}
+	hiddenPaths       map[string]struct{}
+
+	// ignoredPaths is the set of the paths of the devices that are not treated as gamepads, e.g., devices with too many buttons.
+	// A path is opened again after the device is disconnected.
+	ignoredPaths map[string]struct{}
 }
 
 func newNativeGamepadsImpl() nativeGamepads {
@@ -60,24 +170,65 @@ func (g *nativeGamepadsImpl) init(gamepads *gamepads) error {
 		return nil
 	}
 
-	inotify, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
-	if err != nil {
-		return fmt.Errorf("gamepad: InotifyInit1 failed: %w", err)
+	// Steam sets this environment variable for games launched from Steam.
+	g.steamInputDevices = parseSDLIgnoreDevices(os.Getenv("SDL_GAMECONTROLLER_IGNORE_DEVICES"))
+
+	// epoll is optional. If this fails, all the file descriptors are read in every update.
+	if epoll, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC); err == nil {
+		g.epoll = epoll
 	}
-	g.inotify = inotify
 
-	if g.inotify > 0 {
-		// Register for IN_ATTRIB to get notified when udev is done.
-		// This works well in practice but the true way is libudev.
-		watch, err := unix.InotifyAddWatch(g.inotify, dirName, unix.IN_CREATE|unix.IN_ATTRIB|unix.IN_DELETE)
+	// Prefer uevents to detect hotplugging, as udev notifies them after the device is ready to use.
+	// A netlink socket might not be available e.g. in a container. Use inotify in this case.
+	// inotify might not be available either e.g. in a locked-down environment. Rescan the directory periodically in this case.
+	if uevent, err := openUeventSocket(); err == nil {
+		g.uevent = uevent
+		g.watchFD(uevent)
+	} else {
+		_ = g.watchDirectory()
+	}
+
+	if err := g.scanGamepads(gamepads); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// watchDirectory starts to watch the device directory with inotify.
+// If this fails, inotify is not used and the directory is scanned periodically instead.
+func (g *nativeGamepadsImpl) watchDirectory() error {
+	if g.inotify <= 0 {
+		inotify, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
 		if err != nil {
-			return fmt.Errorf("gamepad: InotifyAddWatch failed: %w", err)
+			return fmt.Errorf("gamepad: InotifyInit1 failed: %w", err)
 		}
-		g.watch = watch
+		g.inotify = inotify
+		g.watchFD(inotify)
+	}
+
+	// Register for IN_ATTRIB to get notified when udev is done.
+	// This works well in practice but the true way is uevents.
+	watch, err := unix.InotifyAddWatch(g.inotify, dirName, unix.IN_CREATE|unix.IN_ATTRIB|unix.IN_DELETE)
+	if err != nil {
+		_ = unix.Close(g.inotify)
+		g.inotify = 0
+		g.watch = 0
+		return fmt.Errorf("gamepad: InotifyAddWatch failed: %w", err)
 	}
+	g.watch = watch
+	return nil
+}
+
+func (g *nativeGamepadsImpl) scanGamepads(gamepads *gamepads) error {
+	g.lastScan = time.Now()
 
 	ents, err := os.ReadDir(dirName)
 	if err != nil {
+		// The directory might be removed temporarily.
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
 		return fmt.Errorf("gamepad: ReadDir(%s) failed: %w", dirName, err)
 	}
 	for _, ent := range ents {
@@ -87,28 +238,64 @@ func (g *nativeGamepadsImpl) init(gamepads *gamepads) error {
 		if !reEvent.MatchString(ent.Name()) {
 			continue
 		}
-		if err := g.openGamepad(gamepads, filepath.Join(dirName, ent.Name())); err != nil {
-			return err
-		}
+		g.openGamepad(gamepads, filepath.Join(dirName, ent.Name()))
 	}
-
 	return nil
 }
 
-func (*nativeGamepadsImpl) openGamepad(gamepads *gamepads, path string) (err error) {
+// openGamepad opens the device at path if the device is a gamepad.
+// An error of the device is recorded instead of being returned, so that a broken device doesn't stop the game.
+func (g *nativeGamepadsImpl) openGamepad(gamepads *gamepads, path string) {
+	if err := g.openGamepadDevice(gamepads, path); err != nil {
+		gamepads.addDeviceError(DeviceError{
+			Path: path,
+			Name: readSysfsName(path),
+			Err:  err,
+		})
+	}
+}
+
+func (g *nativeGamepadsImpl) openGamepadDevice(gamepads *gamepads, path string) error {
+	if _, ok := g.ignoredPaths[path]; ok {
+		return nil
+	}
 	if gamepads.find(func(gamepad *Gamepad) bool {
-		return gamepad.native.(*nativeGamepadImpl).path == path
+		return gamepad.devicePath() == path
 	}) != nil {
 		return nil
 	}
-
-	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK, 0)
-	if err != nil {
-		if err == unix.EACCES {
+	for _, s := range g.motionSensors {
+		if s.path == path {
+			return nil
+		}
+	}
+	for _, t := range g.touchpads {
+		if t.path == path {
 			return nil
 		}
-		// This happens with the Snap sandbox.
-		if err == unix.EPERM {
+	}
+
+	// Force feedback requires the write access. Fall back to the read-only access if this is not permitted.
+	writable := true
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_NONBLOCK, 0)
+	if err == unix.EACCES || err == unix.EPERM {
+		writable = false
+		fd, err = unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK, 0)
+	}
+	if err != nil {
+		// Some environments allow reading joydev device files but not evdev device files.
+		// EPERM happens with the Snap sandbox.
+		if err == unix.EACCES || err == unix.EPERM {
+			if err := g.openJoydevGamepad(gamepads, path); err != nil {
+				return err
+			}
+			if gamepads.find(func(gamepad *Gamepad) bool {
+				return gamepad.devicePath() == path
+			}) == nil {
+				g.scheduleOpenRetry(gamepads, path, err)
+			} else {
+				delete(g.openRetries, path)
+			}
 			return nil
 		}
 		// This happens just after a disconnection.
@@ -117,38 +304,84 @@ func (*nativeGamepadsImpl) openGamepad(gamepads *gamepads, path string) (err err
 		}
 		return fmt.Errorf("gamepad: Open failed: %w", err)
 	}
+
+	gamepads.removeInaccessibleDevice(path)
+	delete(g.openRetries, path)
+
+	return g.openEvdevDevice(gamepads, path, fd, fdEvdevDevice(fd), writable)
+}
+
+// openEvdevDevice starts to read the evdev device at path, which is already opened as dev.
+// The device is treated as a gamepad, a motion sensor, or a touchpad, or is closed if it is none of them.
+// fd is the file descriptor of dev to wait for the events.
+func (g *nativeGamepadsImpl) openEvdevDevice(gamepads *gamepads, path string, fd int, dev evdevDevice, writable bool) (err error) {
 	defer func() {
 		if err != nil {
-			_ = unix.Close(fd)
+			_ = dev.close()
 		}
 	}()
 
 	evBits := make([]byte, (unix.EV_CNT+7)/8)
 	keyBits := make([]byte, (_KEY_CNT+7)/8)
 	absBits := make([]byte, (_ABS_CNT+7)/8)
+	ffBits := make([]byte, (_FF_CNT+7)/8)
 	var id input_id
-	if err := ioctl(fd, _EVIOCGBIT(0, uint(len(evBits))), unsafe.Pointer(&evBits[0])); err != nil {
+	if err := dev.ioctl(_EVIOCGBIT(0, uint(len(evBits))), unsafe.Pointer(&evBits[0])); err != nil {
 		return fmt.Errorf("gamepad: ioctl for evBits failed: %w", err)
 	}
-	if err := ioctl(fd, _EVIOCGBIT(unix.EV_KEY, uint(len(keyBits))), unsafe.Pointer(&keyBits[0])); err != nil {
+	if err := dev.ioctl(_EVIOCGBIT(unix.EV_KEY, uint(len(keyBits))), unsafe.Pointer(&keyBits[0])); err != nil {
 		return fmt.Errorf("gamepad: ioctl for keyBits failed: %w", err)
 	}
-	if err := ioctl(fd, _EVIOCGBIT(unix.EV_ABS, uint(len(absBits))), unsafe.Pointer(&absBits[0])); err != nil {
+	if err := dev.ioctl(_EVIOCGBIT(unix.EV_ABS, uint(len(absBits))), unsafe.Pointer(&absBits[0])); err != nil {
 		return fmt.Errorf("gamepad: ioctl for absBits failed: %w", err)
 	}
-	if err := ioctl(fd, _EVIOCGID(), unsafe.Pointer(&id)); err != nil {
+	if isBitSet(evBits, unix.EV_FF) {
+		if err := dev.ioctl(_EVIOCGBIT(unix.EV_FF, uint(len(ffBits))), unsafe.Pointer(&ffBits[0])); err != nil {
+			return fmt.Errorf("gamepad: ioctl for ffBits failed: %w", err)
+		}
+	}
+	if err := dev.ioctl(_EVIOCGID(), unsafe.Pointer(&id)); err != nil {
 		return fmt.Errorf("gamepad: ioctl for an ID failed: %w", err)
 	}
 
-	if !isBitSet(evBits, unix.EV_KEY) {
-		if err := unix.Close(fd); err != nil {
+	// The unique identifier is usually a serial number or a Bluetooth address, and is not available for some devices.
+	cuniq := make([]byte, 256)
+	var serial string
+	if err := dev.ioctl(_EVIOCGUNIQ(uint(len(cuniq))), unsafe.Pointer(&cuniq[0])); err == nil {
+		serial = unix.ByteSliceToString(cuniq)
+	}
+
+	// The physical path is used to associate a motion sensor device with its gamepad device.
+	cphys := make([]byte, 256)
+	var phys string
+	if err := dev.ioctl(_EVIOCGPHYS(uint(len(cphys))), unsafe.Pointer(&cphys[0])); err == nil {
+		phys = unix.ByteSliceToString(cphys)
+	}
+
+	// EVIOCGPROP is not available on old kernels. Treat the device as having no properties in this case.
+	propBits := make([]byte, (_INPUT_PROP_CNT+7)/8)
+	_ = dev.ioctl(_EVIOCGPROP(uint(len(propBits))), unsafe.Pointer(&propBits[0]))
+
+	// Some gamepads like DualShock 4, DualSense, and Switch Pro Controller have a separate device for their motion sensors.
+	if isBitSet(propBits, _INPUT_PROP_ACCELEROMETER) {
+		return g.openMotionSensor(gamepads, fd, path, phys, serial, absBits)
+	}
+
+	// DualShock 4 and DualSense have a separate multi-touch device for their touchpads.
+	if isGamepadTouchpadDevice(id, propBits, keyBits, absBits) {
+		return g.openTouchpad(gamepads, fd, path, phys, serial)
+	}
+
+	// Laptop touchpads and pointing sticks might satisfy the conditions of gamepads.
+	if !isGamepadDeviceByProperties(propBits) {
+		if err := dev.close(); err != nil {
 			return err
 		}
-
 		return nil
 	}
-	if !isBitSet(evBits, unix.EV_ABS) {
-		if err := unix.Close(fd); err != nil {
+
+	if !isGamepadDevice(evBits, keyBits, absBits) {
+		if err := dev.close(); err != nil {
 			return err
 		}
 
@@ -158,283 +391,2107 @@ func (*nativeGamepadsImpl) openGamepad(gamepads *gamepads, path string) (err err
 	cname := make([]byte, 256)
 	name := "Unknown"
 	// TODO: Is it OK to ignore the error here?
-	if err := ioctl(fd, uint(_EVIOCGNAME(uint(len(cname)))), unsafe.Pointer(&cname[0])); err == nil {
+	if err := dev.ioctl(_EVIOCGNAME(uint(len(cname))), unsafe.Pointer(&cname[0])); err == nil {
 		name = unix.ByteSliceToString(cname)
 	}
 
-	var sdlID string
-	if id.vendor != 0 && id.product != 0 && id.version != 0 {
-		sdlID = fmt.Sprintf("%02x%02x0000%02x%02x0000%02x%02x0000%02x%02x0000",
-			byte(id.bustype), byte(id.bustype>>8),
-			byte(id.vendor), byte(id.vendor>>8),
-			byte(id.product), byte(id.product>>8),
-			byte(id.version), byte(id.version>>8))
-	} else {
-		bs := []byte(name)
-		if len(bs) < 12 {
-			bs = append(bs, make([]byte, 12-len(bs))...)
+	// Some drivers split one physical gamepad into multiple devices that look like gamepads,
+	// e.g., a device for the main controls and a device for extra buttons.
+	// Keep only the main device so that the physical gamepad has only one ID.
+	parentPath := parentDevicePath(path)
+	if parentPath != "" {
+		if gamepads.find(func(gamepad *Gamepad) bool {
+			n, ok := gamepad.native.(*nativeGamepadImpl)
+			return ok && n.parentPath == parentPath && isAuxiliaryDeviceName(n.kernelName_, name)
+		}) != nil {
+			if err := dev.close(); err != nil {
+				return err
+			}
+			return nil
+		}
+		for {
+			gp := gamepads.find(func(gamepad *Gamepad) bool {
+				n, ok := gamepad.native.(*nativeGamepadImpl)
+				return ok && n.parentPath == parentPath && isAuxiliaryDeviceName(name, n.kernelName_)
+			})
+			if gp == nil {
+				break
+			}
+			g.closeGamepad(gamepads, gp.devicePath())
 		}
-		sdlID = fmt.Sprintf("%02x%02x0000%02x%02x%02x%02x%02x%02x%02x%02x%02x%02x%02x%02x",
-			byte(id.bustype), byte(id.bustype>>8),
-			bs[0], bs[1], bs[2], bs[3], bs[4], bs[5], bs[6], bs[7], bs[8], bs[9], bs[10], bs[11])
 	}
 
-	n := &nativeGamepadImpl{
-		path: path,
-		fd:   fd,
+	// Steam Input exposes a physical gamepad also as a virtual gamepad.
+	// Hide the physical gamepad while a virtual gamepad exists so that the same gamepad is not counted twice.
+	steamVirtual := isSteamVirtualGamepad(id, name)
+	if steamVirtual {
+		g.hideSteamInputPhysicalGamepads(gamepads)
+	} else if g.isSteamInputPhysicalGamepad(id.vendor, id.product) && hasSteamVirtualGamepad(gamepads) {
+		if g.hiddenPaths == nil {
+			g.hiddenPaths = map[string]struct{}{}
+		}
+		g.hiddenPaths[path] = struct{}{}
+		if err := dev.close(); err != nil {
+			return err
+		}
+		return nil
 	}
-	gp := gamepads.add(name, sdlID)
-	gp.native = n
-	runtime.SetFinalizer(gp, func(gp *Gamepad) {
-		n.close()
-	})
 
-	var axisCount int
-	var buttonCount int
-	var hatCount int
-	for i := range n.keyMap {
-		n.keyMap[i] = -1
+	if isSwitchProController(id.vendor, id.product) && parentPath != "" {
+		if g.openSwitchProController(gamepads, path, parentPath, serial, id) {
+			_ = dev.close()
+			return nil
+		}
 	}
-	for i := range n.absMap {
-		n.absMap[i] = -1
+
+	n := &nativeGamepadImpl{
+		path:         path,
+		parentPath:   parentPath,
+		fd:           fd,
+		dev:          dev,
+		serial_:      serial,
+		phys:         phys,
+		rumble:       writable && isBitSet(ffBits, _FF_RUMBLE),
+		ffGain:       writable && isBitSet(ffBits, _FF_GAIN),
+		poller:       ioctlEvdevPoller{},
+		ff:           evdevForceFeedback{},
+		ffEffectID:   -1,
+		steamVirtual: steamVirtual,
+	}
+	return g.addGamepad(gamepads, n, name, id, keyBits, absBits)
+}
+
+// openJoydevGamepad opens the joydev device file corresponding to the evdev device file at eventPath.
+func (g *nativeGamepadsImpl) openJoydevGamepad(gamepads *gamepads, eventPath string) (err error) {
+	// The joydev device is found in the sysfs directory of the evdev device, e.g., /sys/class/input/event3/device/js0.
+	sysfsPath := filepath.Join("/sys/class/input", filepath.Base(eventPath), "device")
+	matches, err := filepath.Glob(filepath.Join(sysfsPath, "js*"))
+	if err != nil {
+		return fmt.Errorf("gamepad: Glob failed: %w", err)
 	}
-	for code := _BTN_MISC; code < _KEY_CNT; code++ {
-		if !isBitSet(keyBits, code) {
-			continue
+	if len(matches) == 0 {
+		return nil
+	}
+
+	fd, err := unix.Open(filepath.Join(dirName, filepath.Base(matches[0])), unix.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		if err == unix.EACCES || err == unix.EPERM || err == unix.ENOENT {
+			return nil
 		}
-		n.keyMap[code-_BTN_MISC] = buttonCount
-		buttonCount++
+		return fmt.Errorf("gamepad: Open failed: %w", err)
 	}
-	for code := 0; code < _ABS_CNT; code++ {
-		if !isBitSet(absBits, code) {
-			continue
+	defer func() {
+		if err != nil {
+			_ = unix.Close(fd)
 		}
-		if code >= _ABS_HAT0X && code <= _ABS_HAT3Y {
-			// Write the hat index both for the X and the Y hat axis.
-			// That way, the hat can be referenced using either axis, which is used by the code building hatMappingInput.
-			n.absMap[code] = hatCount
-			code++
-			n.absMap[code] = hatCount
-			hatCount++
+	}()
+
+	var axisCount, buttonCount uint8
+	if err := ioctl(fd, _JSIOCGAXES(), unsafe.Pointer(&axisCount)); err != nil {
+		return fmt.Errorf("gamepad: ioctl for axes failed: %w", err)
+	}
+	if err := ioctl(fd, _JSIOCGBUTTONS(), unsafe.Pointer(&buttonCount)); err != nil {
+		return fmt.Errorf("gamepad: ioctl for buttons failed: %w", err)
+	}
+
+	n := &nativeGamepadImpl{
+		path:       eventPath,
+		fd:         fd,
+		dev:        fdEvdevDevice(fd),
+		joydev:     true,
+		ffEffectID: -1,
+	}
+	if err := ioctl(fd, _JSIOCGAXMAP(), unsafe.Pointer(&n.joydevAxisMap[0])); err != nil {
+		return fmt.Errorf("gamepad: ioctl for an axis map failed: %w", err)
+	}
+	if err := ioctl(fd, _JSIOCGBTNMAP(), unsafe.Pointer(&n.joydevButtonMap[0])); err != nil {
+		return fmt.Errorf("gamepad: ioctl for a button map failed: %w", err)
+	}
+
+	// Reconstruct the evdev capabilities from the maps so that the gamepad is treated in the same way as evdev.
+	evBits := make([]byte, (unix.EV_CNT+7)/8)
+	keyBits := make([]byte, (_KEY_CNT+7)/8)
+	absBits := make([]byte, (_ABS_CNT+7)/8)
+	evBits[unix.EV_KEY/8] |= 1 << (unix.EV_KEY % 8)
+	evBits[unix.EV_ABS/8] |= 1 << (unix.EV_ABS % 8)
+	for i := 0; i < int(axisCount) && i < len(n.joydevAxisMap); i++ {
+		code := n.joydevAxisMap[i]
+		absBits[code/8] |= 1 << (code % 8)
+	}
+	for i := 0; i < int(buttonCount) && i < len(n.joydevButtonMap); i++ {
+		code := n.joydevButtonMap[i]
+		if code < _BTN_MISC || code >= _KEY_CNT {
 			continue
 		}
-		if err := ioctl(n.fd, uint(_EVIOCGABS(uint(code))), unsafe.Pointer(&n.absInfo[code])); err != nil {
-			return fmt.Errorf("gamepad: ioctl for an abs at openGamepad failed: %w", err)
-		}
-		n.absMap[code] = axisCount
-		axisCount++
+		keyBits[code/8] |= 1 << (code % 8)
 	}
 
-	n.axisCount_ = axisCount
-	n.buttonCount_ = buttonCount
-	n.hatCount_ = hatCount
+	if !isGamepadDevice(evBits, keyBits, absBits) {
+		if err := unix.Close(fd); err != nil {
+			return err
+		}
 
-	n.computeStandardLayout(id.vendor)
+		return nil
+	}
 
-	if err := n.pollAbsState(); err != nil {
-		return err
+	cname := make([]byte, 256)
+	name := "Unknown"
+	if err := ioctl(fd, _JSIOCGNAME(uint(len(cname))), unsafe.Pointer(&cname[0])); err == nil {
+		name = unix.ByteSliceToString(cname)
 	}
 
-	return nil
+	// The IDs and the unique identifier are not available via joydev. Read them from sysfs instead.
+	id := input_id{
+		bustype: readSysfsHex(filepath.Join(sysfsPath, "id", "bustype")),
+		vendor:  readSysfsHex(filepath.Join(sysfsPath, "id", "vendor")),
+		product: readSysfsHex(filepath.Join(sysfsPath, "id", "product")),
+		version: readSysfsHex(filepath.Join(sysfsPath, "id", "version")),
+	}
+	if uniq, err := os.ReadFile(filepath.Join(sysfsPath, "uniq")); err == nil {
+		n.serial_ = strings.TrimSpace(string(uniq))
+	}
+	if phys, err := os.ReadFile(filepath.Join(sysfsPath, "phys")); err == nil {
+		n.phys = strings.TrimSpace(string(phys))
+	}
+
+	return g.addGamepad(gamepads, n, name, id, keyBits, absBits)
 }
 
-func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
-	if g.inotify <= 0 {
-		return nil
-	}
+const (
+	openRetryInitialDelay = 100 * time.Millisecond
+	openRetryTimeout      = 10 * time.Second
+)
 
-	buf := make([]byte, 16384)
-	n, err := unix.Read(g.inotify, buf[:])
-	if err != nil {
-		if err == unix.EAGAIN {
-			return nil
-		}
-		return fmt.Errorf("gamepad: Read failed: %w", err)
-	}
-	buf = buf[:n]
+// openRetry is the state to retry opening a device file that is not accessible.
+type openRetry struct {
+	start time.Time
+	delay time.Duration
 
-	for len(buf) > 0 {
-		e := unix.InotifyEvent{
-			Wd:     int32(buf[0]) | int32(buf[1])<<8 | int32(buf[2])<<16 | int32(buf[3])<<24,
-			Mask:   uint32(buf[4]) | uint32(buf[5])<<8 | uint32(buf[6])<<16 | uint32(buf[7])<<24,
-			Cookie: uint32(buf[8]) | uint32(buf[9])<<8 | uint32(buf[10])<<16 | uint32(buf[11])<<24,
-			Len:    uint32(buf[12]) | uint32(buf[13])<<8 | uint32(buf[14])<<16 | uint32(buf[15])<<24,
-		}
-		name := unix.ByteSliceToString(buf[16 : 16+e.Len-1]) // len includes the null terminate.
-		buf = buf[16+e.Len:]
-		if !reEvent.MatchString(name) {
-			continue
-		}
+	// next is the time to retry opening the device file next, or the zero value if retrying is given up.
+	next time.Time
+}
 
-		path := filepath.Join(dirName, name)
-		if e.Mask&(unix.IN_CREATE|unix.IN_ATTRIB) != 0 {
-			if err := g.openGamepad(gamepads, path); err != nil {
-				return err
-			}
-			continue
+// scheduleOpenRetry schedules to retry opening the device file at path that failed with err.
+//
+// udev might set the permission of a device file just after the device file is created.
+// The inotify event for the permission change might be missed, so retry opening the device file with exponential backoff.
+// If the device file is still not accessible after openRetryTimeout, the device is recorded as an inaccessible device.
+func (g *nativeGamepadsImpl) scheduleOpenRetry(gamepads *gamepads, path string, err error) {
+	// Retry only the devices that look like gamepads, as e.g. keyboards are usually not accessible.
+	device, ok := readInaccessibleDevice(path, err)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	r, ok := g.openRetries[path]
+	if !ok {
+		r = &openRetry{
+			start: now,
+			delay: openRetryInitialDelay,
 		}
-		if e.Mask&unix.IN_DELETE != 0 {
-			if gp := gamepads.find(func(gamepad *Gamepad) bool {
-				return gamepad.native.(*nativeGamepadImpl).path == path
-			}); gp != nil {
-				gp.native.(*nativeGamepadImpl).close()
-				gamepads.remove(func(gamepad *Gamepad) bool {
-					return gamepad == gp
-				})
-			}
-			continue
+		if g.openRetries == nil {
+			g.openRetries = map[string]*openRetry{}
 		}
+		g.openRetries[path] = r
+	} else if r.next.IsZero() {
+		// Retrying is already given up.
+		gamepads.addInaccessibleDevice(device)
+		return
+	} else {
+		r.delay *= 2
 	}
 
-	return nil
+	if now.Sub(r.start) >= openRetryTimeout {
+		r.next = time.Time{}
+		gamepads.addInaccessibleDevice(device)
+		return
+	}
+	r.next = now.Add(r.delay)
 }
 
-type nativeGamepadImpl struct {
-	fd      int
-	path    string
-	keyMap  [_KEY_CNT - _BTN_MISC]int
-	absMap  [_ABS_CNT]int
-	absInfo [_ABS_CNT]input_absinfo
-	dropped bool
+// retryOpeningGamepads retries opening the device files whose retry time comes.
+func (g *nativeGamepadsImpl) retryOpeningGamepads(gamepads *gamepads) {
+	if len(g.openRetries) == 0 {
+		return
+	}
 
-	axes    [_ABS_CNT]float64
-	buttons [_KEY_CNT - _BTN_MISC]bool
-	hats    [4]int
+	now := time.Now()
+	var paths []string
+	for path, r := range g.openRetries {
+		if r.next.IsZero() || now.Before(r.next) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		g.openGamepad(gamepads, path)
+	}
+}
 
-	axisCount_   int
-	buttonCount_ int
-	hatCount_    int
+// readInaccessibleDevice reads the information of the device at path that cannot be opened.
+// The device is checked with the capabilities in sysfs, which are readable without the permission for the device file.
+// The second value is false if the device doesn't look like a gamepad.
+func readInaccessibleDevice(path string, err error) (InaccessibleDevice, bool) {
+	sysfsPath := filepath.Join("/sys/class/input", filepath.Base(path), "device")
+	evBits, ok := readSysfsBitmap(filepath.Join(sysfsPath, "capabilities", "ev"), unix.EV_CNT)
+	if !ok {
+		return InaccessibleDevice{}, false
+	}
+	keyBits, ok := readSysfsBitmap(filepath.Join(sysfsPath, "capabilities", "key"), _KEY_CNT)
+	if !ok {
+		return InaccessibleDevice{}, false
+	}
+	absBits, ok := readSysfsBitmap(filepath.Join(sysfsPath, "capabilities", "abs"), _ABS_CNT)
+	if !ok {
+		return InaccessibleDevice{}, false
+	}
+	if !isGamepadDevice(evBits, keyBits, absBits) {
+		return InaccessibleDevice{}, false
+	}
 
-	stdAxisMap   map[gamepaddb.StandardAxis]mappingInput
-	stdButtonMap map[gamepaddb.StandardButton]mappingInput
+	return InaccessibleDevice{
+		Path: path,
+		Name: readSysfsName(path),
+		Err:  err,
+	}, true
 }
 
-func (g *nativeGamepadImpl) close() {
-	if g.fd != 0 {
-		_ = unix.Close(g.fd)
+// readSysfsName returns the name of the input device at path in sysfs, or an empty string if the name is not available.
+// This works even without the permission for the device file.
+func readSysfsName(path string) string {
+	bs, err := os.ReadFile(filepath.Join("/sys/class/input", filepath.Base(path), "device", "name"))
+	if err != nil {
+		return ""
 	}
-	g.fd = 0
+	return strings.TrimSpace(string(bs))
 }
 
-func (g *nativeGamepadImpl) update(gamepad *gamepads) error {
-	if g.fd == 0 {
-		return nil
+func readSysfsBitmap(path string, size int) ([]byte, bool) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
 	}
+	return parseSysfsBitmap(string(bs), size)
+}
 
-	for {
-		buf := make([]byte, unsafe.Sizeof(input_event{}))
-		// TODO: Should the returned byte count be cared?
-		if _, err := unix.Read(g.fd, buf); err != nil {
-			if err == unix.EAGAIN {
-				break
+// parseSysfsBitmap parses a bitmap in sysfs like /sys/class/input/event0/device/capabilities/key.
+// The bitmap is hexadecimal words of unsigned long separated by spaces, and the most significant word comes first.
+func parseSysfsBitmap(str string, size int) ([]byte, bool) {
+	bs := make([]byte, (size+7)/8)
+	words := strings.Fields(str)
+	for i := range words {
+		w, err := strconv.ParseUint(words[len(words)-1-i], 16, bits.UintSize)
+		if err != nil {
+			return nil, false
+		}
+		for j := 0; j < bits.UintSize; j++ {
+			if w&(1<<j) == 0 {
+				continue
 			}
-			// Disconnected
-			if err == unix.ENODEV {
-				g.close()
-				return nil
+			bit := i*bits.UintSize + j
+			if bit >= size {
+				continue
 			}
-			return fmt.Errorf("gamepad: Read failed: %w", err)
+			bs[bit/8] |= 1 << (bit % 8)
 		}
+	}
+	return bs, true
+}
 
-		const (
-			offsetTyp   = unsafe.Offsetof(input_event{}.typ)
-			offsetCode  = unsafe.Offsetof(input_event{}.code)
-			offsetValue = unsafe.Offsetof(input_event{}.value)
-		)
-		// time is not used.
-		e := input_event{
-			typ:   uint16(buf[offsetTyp]) | uint16(buf[offsetTyp+1])<<8,
-			code:  uint16(buf[offsetCode]) | uint16(buf[offsetCode+1])<<8,
-			value: int32(buf[offsetValue]) | int32(buf[offsetValue+1])<<8 | int32(buf[offsetValue+2])<<16 | int32(buf[offsetValue+3])<<24,
-		}
-
-		if e.typ == unix.EV_SYN {
-			switch e.code {
-			case _SYN_DROPPED:
-				g.dropped = true
-			case _SYN_REPORT:
-				g.dropped = false
-				if err := g.pollAbsState(); err != nil {
-					return fmt.Errorf("gamepad: poll absolute state: %w", err)
-				}
-			}
-		}
-		if g.dropped {
-			continue
-		}
+func readSysfsHex(path string) uint16 {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(bs)), 16, 16)
+	if err != nil {
+		return 0
+	}
+	return uint16(v)
+}
+
+func (g *nativeGamepadsImpl) addGamepad(gamepads *gamepads, n *nativeGamepadImpl, name string, id input_id, keyBits, absBits []byte) error {
+	sdlID := sdlGUID(id.bustype, id.vendor, id.product, id.version, name, sdlDriverSignatureNone, 0)
+
+	// The kernel name is sometimes too generic or broken, e.g., "Generic X-Box pad" for clone gamepads.
+	// Compose a better name from the strings of the USB device in this case.
+	n.kernelName_ = name
+	if dir, err := filepath.EvalSymlinks(filepath.Join("/sys/class/input", filepath.Base(n.path), "device")); err == nil {
+		if manufacturer, product, ok := usbDeviceStrings(dir); ok {
+			name = resolveGamepadName(name, manufacturer, product)
+		}
+	}
+
+	// Identify the physical gamepad by its serial so that the gamepad keeps its ID after reconnecting.
+	// The serial is often empty for wired gamepads. Such gamepads are not distinguishable and always get a new ID.
+	var key string
+	if n.serial_ != "" {
+		key = fmt.Sprintf("%04x:%04x:%s", id.vendor, id.product, n.serial_)
+	}
+	n.bustype = id.bustype
+	n.vendor = id.vendor
+	n.product = id.product
+	gp := gamepads.addWithReconnectionKey(name, sdlID, key)
+	gp.native = n
+	runtime.SetFinalizer(gp, func(gp *Gamepad) {
+		n.close()
+	})
+
+	n.mapButtons(keyBits)
+	n.mapAxesAndHats(absBits)
+
+	for code := 0; code < _ABS_CNT; code++ {
+		if n.absMap[code] < 0 {
+			continue
+		}
+		if code >= _ABS_HAT0X && code <= _ABS_HAT3Y {
+			continue
+		}
+		if n.joydev {
+			// joydev reports the values already normalized to this range.
+			n.absInfo[code] = input_absinfo{
+				minimum: -32767,
+				maximum: 32767,
+			}
+		} else if err := n.dev.ioctl(_EVIOCGABS(uint(code)), unsafe.Pointer(&n.absInfo[code])); err != nil {
+			return fmt.Errorf("gamepad: ioctl for an abs at openGamepad failed: %w", err)
+		}
+		if n.absInfo[code].minimum == n.absInfo[code].maximum {
+			debug.Logf("gamepad: the axis %s of %s has an empty range and is always 0\n", evdevAxisLabel(code), name)
+		}
+		n.absValues[code] = n.absInfo[code].value
+	}
+
+	n.computeStandardLayout(id.vendor)
+	n.updateBattery()
+
+	if err := n.pollAbsState(); err != nil {
+		return err
+	}
+
+	// Buttons held while the device is opened don't generate events until they are released.
+	// Read the current state so that e.g. a button held at launch or after resuming is reported as pressed.
+	if err := n.pollKeyState(); err != nil {
+		return err
+	}
+
+	// Reading the initial state is not an activity.
+	n.lastActive_ = time.Time{}
+
+	g.watchFD(n.fd)
+	g.attachMotionSensors(gamepads)
+	g.attachTouchpads(gamepads)
+
+	return nil
+}
+
+func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
+	g.pollReadyFDs()
+
+	g.retryOpeningGamepads(gamepads)
+	g.restoreHiddenGamepads(gamepads)
+
+	if g.uevent > 0 {
+		if !g.isReady(g.uevent) {
+			return nil
+		}
+		return g.updateByUevents(gamepads)
+	}
+	if g.inotify > 0 {
+		if !g.isReady(g.inotify) {
+			return nil
+		}
+		return g.updateByInotify(gamepads)
+	}
+	return g.updateByRescan(gamepads)
+}
+
+// watchFD registers fd to the epoll instance.
+// A closed file descriptor is removed from the epoll instance automatically, so fd doesn't have to be unregistered explicitly.
+func (g *nativeGamepadsImpl) watchFD(fd int) {
+	if g.epoll <= 0 {
+		return
+	}
+	if err := unix.EpollCtl(g.epoll, unix.EPOLL_CTL_ADD, fd, &unix.EpollEvent{
+		Events: unix.EPOLLIN,
+		Fd:     int32(fd),
+	}); err != nil {
+		// Give up epoll and read all the file descriptors in every update.
+		_ = unix.Close(g.epoll)
+		g.epoll = 0
+	}
+}
+
+// pollReadyFDs updates the set of the readable file descriptors.
+func (g *nativeGamepadsImpl) pollReadyFDs() {
+	for fd := range g.readyFDs {
+		delete(g.readyFDs, fd)
+	}
+	g.allReady = false
+
+	if g.epoll <= 0 {
+		return
+	}
+
+	n, err := unix.EpollWait(g.epoll, g.epollEvents[:], 0)
+	if err != nil {
+		if err == unix.EINTR {
+			g.allReady = true
+			return
+		}
+		// Give up epoll and read all the file descriptors in every update.
+		_ = unix.Close(g.epoll)
+		g.epoll = 0
+		g.allReady = true
+		return
+	}
+	// Some file descriptors might not be reported when the buffer is full.
+	if n == len(g.epollEvents) {
+		g.allReady = true
+		return
+	}
+
+	if g.readyFDs == nil {
+		g.readyFDs = map[int32]struct{}{}
+	}
+	for _, e := range g.epollEvents[:n] {
+		g.readyFDs[e.Fd] = struct{}{}
+	}
+}
+
+// isReady reports whether fd might be readable at the current update.
+// This includes the case when fd is disconnected, where reading fd reports an error.
+func (g *nativeGamepadsImpl) isReady(fd int) bool {
+	if g.epoll <= 0 || g.allReady {
+		return true
+	}
+	_, ok := g.readyFDs[int32(fd)]
+	return ok
+}
+
+// isReadyFD reports whether fd might be readable at the current update of gamepads.
+func isReadyFD(gamepads *gamepads, fd int) bool {
+	n, ok := gamepads.native.(*nativeGamepadsImpl)
+	if !ok {
+		return true
+	}
+	return n.isReady(fd)
+}
+
+// inotifyEvent is an event read from an inotify instance.
+type inotifyEvent struct {
+	mask uint32
+
+	// name is the name of the file in the watched directory, or an empty string for the events of the directory itself.
+	name string
+}
+
+// parseInotifyEvents parses the inotify events in buf, and appends them to events.
+//
+// Each event is a struct inotify_event, followed by the name padded with null bytes, whose length is in the len field.
+// A truncated event at the end of buf is ignored.
+func parseInotifyEvents(events []inotifyEvent, buf []byte) []inotifyEvent {
+	const headerSize = int(unsafe.Sizeof(unix.InotifyEvent{}))
+	for len(buf) >= headerSize {
+		mask := uint32(buf[4]) | uint32(buf[5])<<8 | uint32(buf[6])<<16 | uint32(buf[7])<<24
+		nameLen := uint32(buf[12]) | uint32(buf[13])<<8 | uint32(buf[14])<<16 | uint32(buf[15])<<24
+		if uint64(nameLen) > uint64(len(buf)-headerSize) {
+			break
+		}
+		size := headerSize + int(nameLen)
+		events = append(events, inotifyEvent{
+			mask: mask,
+			name: unix.ByteSliceToString(buf[headerSize:size]),
+		})
+		buf = buf[size:]
+	}
+	return events
+}
+
+func (g *nativeGamepadsImpl) updateByInotify(gamepads *gamepads) error {
+	// Read all the queued events. Many events can be queued at once e.g. when a docking station is plugged in.
+	events := g.inotifyEvents[:0]
+	for {
+		n, err := unix.Read(g.inotify, g.inotifyBuf[:])
+		if err != nil {
+			if err == unix.EAGAIN {
+				break
+			}
+			// The inotify instance is broken. Fall back to rescanning the directory periodically.
+			_ = unix.Close(g.inotify)
+			g.inotify = 0
+			g.watch = 0
+			g.closeRemovedGamepads(gamepads)
+			return g.scanGamepads(gamepads)
+		}
+		if n == 0 {
+			break
+		}
+		events = parseInotifyEvents(events, g.inotifyBuf[:n])
+	}
+	g.inotifyEvents = events
+
+	for _, e := range events {
+		if e.mask&unix.IN_Q_OVERFLOW != 0 {
+			// The event queue overflowed and some events were lost. Rescan the directory.
+			g.closeRemovedGamepads(gamepads)
+			return g.scanGamepads(gamepads)
+		}
+		if e.mask&unix.IN_IGNORED != 0 {
+			// The watch was removed e.g. as the directory was deleted. Watch the directory again.
+			// The devices created in the meantime are not notified, so rescan the directory.
+			// If watching fails, the directory is rescanned periodically.
+			_ = g.watchDirectory()
+			g.closeRemovedGamepads(gamepads)
+			return g.scanGamepads(gamepads)
+		}
+		if !reEvent.MatchString(e.name) {
+			continue
+		}
+
+		path := filepath.Join(dirName, e.name)
+		if e.mask&(unix.IN_CREATE|unix.IN_ATTRIB) != 0 {
+			g.openGamepad(gamepads, path)
+			continue
+		}
+		if e.mask&unix.IN_DELETE != 0 {
+			g.closeGamepad(gamepads, path)
+			continue
+		}
+	}
+
+	return nil
+}
+
+// rescanInterval is the interval to rescan the device directory when neither uevents nor inotify is available.
+const rescanInterval = 2 * time.Second
+
+func (g *nativeGamepadsImpl) updateByRescan(gamepads *gamepads) error {
+	if time.Since(g.lastScan) < rescanInterval {
+		return nil
+	}
+
+	g.closeRemovedGamepads(gamepads)
+	return g.scanGamepads(gamepads)
+}
+
+// closeRemovedGamepads closes the devices that were disconnected without notifications.
+// A closed device's path might be reused for a new device. Close it too so that the new device is opened by the next scan.
+func (g *nativeGamepadsImpl) closeRemovedGamepads(gamepads *gamepads) {
+	var paths []string
+	for _, gp := range gamepads.gamepads {
+		if gp == nil {
+			continue
+		}
+		n, ok := gp.native.(*nativeGamepadImpl)
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(n.path); n.fd == 0 || errors.Is(err, fs.ErrNotExist) {
+			paths = append(paths, n.path)
+		}
+	}
+	for _, s := range g.motionSensors {
+		if _, err := os.Stat(s.path); s.fd == 0 || errors.Is(err, fs.ErrNotExist) {
+			paths = append(paths, s.path)
+		}
+	}
+	for _, t := range g.touchpads {
+		if _, err := os.Stat(t.path); t.fd == 0 || errors.Is(err, fs.ErrNotExist) {
+			paths = append(paths, t.path)
+		}
+	}
+	for path := range g.ignoredPaths {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range paths {
+		g.closeGamepad(gamepads, path)
+	}
+}
+
+// ignore makes the device at path not opened again until the device is disconnected.
+func (g *nativeGamepadsImpl) ignore(path string) {
+	if g.ignoredPaths == nil {
+		g.ignoredPaths = map[string]struct{}{}
+	}
+	g.ignoredPaths[path] = struct{}{}
+}
+
+func (g *nativeGamepadsImpl) updateByUevents(gamepads *gamepads) error {
+	for {
+		n, err := unix.Read(g.uevent, g.ueventBuf[:])
+		if err != nil {
+			if err == unix.EAGAIN {
+				return nil
+			}
+			// Some uevents were dropped as the socket buffer overflowed. Rescan the devices not to miss them.
+			if err == unix.ENOBUFS {
+				if err := g.scanGamepads(gamepads); err != nil {
+					return err
+				}
+				continue
+			}
+			// The socket is broken. Fall back to inotify, and rescan the directory not to miss devices.
+			_ = unix.Close(g.uevent)
+			g.uevent = 0
+			_ = g.watchDirectory()
+			g.closeRemovedGamepads(gamepads)
+			return g.scanGamepads(gamepads)
+		}
+
+		action, subsystem, devName := parseUevent(g.ueventBuf[:n])
+		if subsystem != "input" {
+			continue
+		}
+		if !reEvent.MatchString(filepath.Base(devName)) {
+			continue
+		}
+
+		// DEVNAME is relative to /dev in kernel uevents, and is absolute in udev uevents.
+		path := devName
+		if !filepath.IsAbs(path) {
+			path = filepath.Join("/dev", path)
+		}
+		switch action {
+		case "add":
+			g.openGamepad(gamepads, path)
+		case "remove":
+			g.closeGamepad(gamepads, path)
+		}
+	}
+}
+
+// terminate closes all the devices and the file descriptors to detect hotplugging.
+// The gamepads are closed deterministically here, while the finalizers are kept as a safety net.
+func (g *nativeGamepadsImpl) terminate(gamepads *gamepads) {
+	for _, gp := range gamepads.gamepads {
+		if gp == nil {
+			continue
+		}
+		gp.close()
+	}
+	for _, s := range g.motionSensors {
+		s.close()
+	}
+	g.motionSensors = nil
+	g.openRetries = nil
+	g.hiddenPaths = nil
+	g.ignoredPaths = nil
+	for _, t := range g.touchpads {
+		t.close()
+	}
+	g.touchpads = nil
+
+	if g.inotify > 0 {
+		_ = unix.Close(g.inotify)
+	}
+	g.inotify = 0
+	g.watch = 0
+	if g.uevent > 0 {
+		_ = unix.Close(g.uevent)
+	}
+	g.uevent = 0
+	g.lastScan = time.Time{}
+	if g.epoll > 0 {
+		_ = unix.Close(g.epoll)
+	}
+	g.epoll = 0
+	g.readyFDs = nil
+	g.allReady = false
+}
+
+func (g *nativeGamepadsImpl) closeGamepad(gamepads *gamepads, path string) {
+	gamepads.removeInaccessibleDevice(path)
+	delete(g.openRetries, path)
+	delete(g.hiddenPaths, path)
+	delete(g.ignoredPaths, path)
+
+	for i, s := range g.motionSensors {
+		if s.path != path {
+			continue
+		}
+		s.close()
+		g.motionSensors = append(g.motionSensors[:i], g.motionSensors[i+1:]...)
+		g.attachMotionSensors(gamepads)
+		return
+	}
+
+	for i, t := range g.touchpads {
+		if t.path != path {
+			continue
+		}
+		t.close()
+		g.touchpads = append(g.touchpads[:i], g.touchpads[i+1:]...)
+		g.attachTouchpads(gamepads)
+		return
+	}
+
+	if gp := gamepads.find(func(gamepad *Gamepad) bool {
+		return gamepad.devicePath() == path
+	}); gp != nil {
+		gp.close()
+		gamepads.remove(func(gamepad *Gamepad) bool {
+			return gamepad == gp
+		})
+	}
+}
+
+// openMotionSensor starts to read the motion sensor device at path, which is already opened as fd.
+func (g *nativeGamepadsImpl) openMotionSensor(gamepads *gamepads, fd int, path string, phys, uniq string, absBits []byte) error {
+	// A motion sensor without the physical path or the unique identifier cannot be associated with its gamepad.
+	if phys == "" && uniq == "" {
+		if err := unix.Close(fd); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	s := &motionSensor{
+		fd:   fd,
+		path: path,
+		phys: phys,
+		uniq: uniq,
+	}
+	for code := _ABS_X; code <= _ABS_RZ; code++ {
+		if !isBitSet(absBits, code) {
+			continue
+		}
+		s.available[code] = true
+	}
+	if err := s.pollAbsState(); err != nil {
+		return err
+	}
+
+	g.motionSensors = append(g.motionSensors, s)
+	g.watchFD(fd)
+	g.attachMotionSensors(gamepads)
+	return nil
+}
+
+// attachMotionSensors associates the motion sensors with the gamepads of the same physical devices.
+func (g *nativeGamepadsImpl) attachMotionSensors(gamepads *gamepads) {
+	for _, gp := range gamepads.gamepads {
+		if gp == nil {
+			continue
+		}
+		n, ok := gp.native.(*nativeGamepadImpl)
+		if !ok {
+			continue
+		}
+		var motion *motionSensor
+		for _, s := range g.motionSensors {
+			if isSameInputDevice(n.phys, n.serial_, s.phys, s.uniq) {
+				motion = s
+				break
+			}
+		}
+		gp.m.Lock()
+		n.motion = motion
+		gp.m.Unlock()
+	}
+}
+
+// isSameInputDevice reports whether two input devices with the given physical paths and unique identifiers belong to the same physical device.
+//
+// The physical path is the same for the devices created by the same driver, but is not sufficient
+// for Bluetooth devices, as the physical path is the address of the Bluetooth adapter.
+// The unique identifier distinguishes such devices, but some drivers set it only to some of the devices.
+func isSameInputDevice(phys1, uniq1, phys2, uniq2 string) bool {
+	if phys1 != phys2 {
+		return false
+	}
+	if uniq1 != "" && uniq2 != "" {
+		return uniq1 == uniq2
+	}
+	return phys1 != ""
+}
+
+// parentDevicePath returns the sysfs directory of the device that created the input device at the given device file path.
+// parentDevicePath returns an empty string if the directory is not found.
+//
+// An input device is registered under the input directory of its parent device,
+// e.g., /sys/devices/.../0003:054C:09CC.0001/input/input12.
+func parentDevicePath(path string) string {
+	dir, err := filepath.EvalSymlinks(filepath.Join("/sys/class/input", filepath.Base(path), "device"))
+	if err != nil {
+		return ""
+	}
+	dir = filepath.Dir(dir)
+	if filepath.Base(dir) != "input" {
+		return ""
+	}
+	return filepath.Dir(dir)
+}
+
+// isAuxiliaryDeviceName reports whether an input device with the given name is an auxiliary device of the primary device
+// created by the same parent device.
+//
+// Drivers name auxiliary devices by appending a suffix to the name of the primary device,
+// e.g., "Sony Interactive Entertainment Wireless Controller Touchpad".
+// Devices with the same names are not auxiliary, as some adapters create such devices for multiple gamepads.
+func isAuxiliaryDeviceName(primary, name string) bool {
+	return strings.HasPrefix(name, primary+" ")
+}
+
+// usbDeviceStrings returns the manufacturer and the product strings of the USB device that the sysfs device directory dir belongs to.
+// The USB device is the closest ancestor directory having idVendor and idProduct.
+// usbDeviceStrings returns false if dir doesn't belong to a USB device.
+func usbDeviceStrings(dir string) (manufacturer, product string, ok bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "idVendor")); err == nil {
+			if _, err := os.Stat(filepath.Join(dir, "idProduct")); err == nil {
+				// The string descriptors are optional.
+				if bs, err := os.ReadFile(filepath.Join(dir, "manufacturer")); err == nil {
+					manufacturer = strings.TrimSpace(string(bs))
+				}
+				if bs, err := os.ReadFile(filepath.Join(dir, "product")); err == nil {
+					product = strings.TrimSpace(string(bs))
+				}
+				return manufacturer, product, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// genericGamepadNames is the list of the names that don't identify gamepads well.
+var genericGamepadNames = []string{
+	"Controller",
+	"Gamepad",
+	"Generic X-Box pad",
+	"Generic USB Joystick",
+	"Joystick",
+	"Unknown",
+	"USB Gamepad",
+	"USB Joystick",
+}
+
+// isGenericGamepadName reports whether the name is empty, generic, or broken.
+func isGenericGamepadName(name string) bool {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return true
+	}
+	if !utf8.ValidString(name) {
+		return true
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	for _, n := range genericGamepadNames {
+		if strings.EqualFold(name, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveGamepadName returns the display name of a gamepad from the kernel name and the strings of its USB device.
+// The kernel name is used unless it is generic and the USB device strings are better.
+func resolveGamepadName(kernelName, manufacturer, product string) string {
+	if !isGenericGamepadName(kernelName) {
+		return kernelName
+	}
+	if isGenericGamepadName(product) {
+		return kernelName
+	}
+	if isGenericGamepadName(manufacturer) || strings.HasPrefix(product, manufacturer) {
+		return product
+	}
+	return manufacturer + " " + product
+}
+
+const (
+	steamVendorID                = 0x28de
+	steamVirtualGamepadProductID = 0x11ff
+)
+
+// isSteamVirtualGamepad reports whether the device is a virtual gamepad created by Steam Input.
+func isSteamVirtualGamepad(id input_id, name string) bool {
+	if id.vendor == steamVendorID && id.product == steamVirtualGamepadProductID {
+		return true
+	}
+	return name == "Steam Virtual Gamepad"
+}
+
+// parseSDLIgnoreDevices parses the value of the environment variable SDL_GAMECONTROLLER_IGNORE_DEVICES,
+// which is a comma-separated list of vendor and product IDs like "0x045e/0x028e,0x054c/0x09cc".
+// Invalid items are ignored.
+func parseSDLIgnoreDevices(str string) map[[2]uint16]struct{} {
+	var devices map[[2]uint16]struct{}
+	for _, item := range strings.Split(str, ",") {
+		tokens := strings.Split(strings.TrimSpace(item), "/")
+		if len(tokens) != 2 {
+			continue
+		}
+		vendor, err := strconv.ParseUint(tokens[0], 0, 16)
+		if err != nil {
+			continue
+		}
+		product, err := strconv.ParseUint(tokens[1], 0, 16)
+		if err != nil {
+			continue
+		}
+		if devices == nil {
+			devices = map[[2]uint16]struct{}{}
+		}
+		devices[[2]uint16{uint16(vendor), uint16(product)}] = struct{}{}
+	}
+	return devices
+}
+
+// isSteamInputPhysicalGamepad reports whether the gamepad is a physical gamepad that Steam Input exposes as a virtual gamepad.
+func (g *nativeGamepadsImpl) isSteamInputPhysicalGamepad(vendor, product uint16) bool {
+	_, ok := g.steamInputDevices[[2]uint16{vendor, product}]
+	return ok
+}
+
+func hasSteamVirtualGamepad(gamepads *gamepads) bool {
+	return gamepads.find(func(gamepad *Gamepad) bool {
+		n, ok := gamepad.native.(*nativeGamepadImpl)
+		return ok && n.steamVirtual
+	}) != nil
+}
+
+// hideSteamInputPhysicalGamepads closes the physical gamepads that Steam Input exposes as virtual gamepads.
+// The gamepads are opened again by restoreHiddenGamepads after all the virtual gamepads are removed.
+func (g *nativeGamepadsImpl) hideSteamInputPhysicalGamepads(gamepads *gamepads) {
+	var paths []string
+	for _, gp := range gamepads.gamepads {
+		if gp == nil {
+			continue
+		}
+		var vendor, product uint16
+		switch n := gp.native.(type) {
+		case *nativeGamepadImpl:
+			if n.steamVirtual {
+				continue
+			}
+			vendor, product = n.vendor, n.product
+		case *nativeGamepadSwitchPro:
+			vendor, product = nintendoVendorID, nintendoProductSwitchPro
+		}
+		if !g.isSteamInputPhysicalGamepad(vendor, product) {
+			continue
+		}
+		paths = append(paths, gp.devicePath())
+	}
+	for _, path := range paths {
+		g.closeGamepad(gamepads, path)
+		if g.hiddenPaths == nil {
+			g.hiddenPaths = map[string]struct{}{}
+		}
+		g.hiddenPaths[path] = struct{}{}
+	}
+}
+
+// restoreHiddenGamepads opens the hidden physical gamepads again if no Steam virtual gamepad exists.
+func (g *nativeGamepadsImpl) restoreHiddenGamepads(gamepads *gamepads) {
+	if len(g.hiddenPaths) == 0 || hasSteamVirtualGamepad(gamepads) {
+		return
+	}
+	paths := make([]string, 0, len(g.hiddenPaths))
+	for path := range g.hiddenPaths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	g.hiddenPaths = nil
+	for _, path := range paths {
+		g.openGamepad(gamepads, path)
+	}
+}
+
+// standardGravity is the standard acceleration of gravity in m/s^2.
+const standardGravity = 9.80665
+
+// motionSensor is an evdev device reporting the accelerometer and gyroscope values of a gamepad.
+// ABS_X, ABS_Y, and ABS_Z are the accelerometer values, and ABS_RX, ABS_RY, and ABS_RZ are the gyroscope values,
+// which correspond to MotionSensorAxis values in this order.
+type motionSensor struct {
+	fd   int
+	path string
+	phys string
+	uniq string
+
+	available [MotionSensorAxisCount]bool
+	absInfo   [MotionSensorAxisCount]input_absinfo
+	values    [MotionSensorAxisCount]float64
+	dropped   bool
+
+	reader inputEventReader
+}
+
+func (s *motionSensor) close() {
+	if s.fd != 0 {
+		_ = unix.Close(s.fd)
+	}
+	s.fd = 0
+}
+
+func (s *motionSensor) update() error {
+	if s.fd == 0 {
+		return nil
+	}
+	if err := s.reader.read(fdEvdevDevice(s.fd), inputEventSize, s.handleEventBytes); err != nil {
+		if err == errDisconnected {
+			s.close()
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *motionSensor) handleEventBytes(buf []byte) error {
+	return s.handleEvent(parseInputEvent(buf))
+}
+
+// inputEventReader reads input events from a device.
+// A read might return an incomplete event e.g. when it is interrupted by a signal.
+// The incomplete event is kept and is completed by the next read.
+type inputEventReader struct {
+	// buf is a buffer to read input events, which is held to avoid allocations in every frame.
+	// rest is the byte size of an incomplete event at the head of buf.
+	buf  [64 * inputEventSize]byte
+	rest int
+}
+
+// read reads the available events of eventSize bytes from dev, and calls f for each event.
+// read returns errDisconnected if the device is disconnected.
+func (r *inputEventReader) read(dev evdevDevice, eventSize int, f func(buf []byte) error) error {
+	for {
+		n, err := dev.read(r.buf[r.rest:])
+		if err != nil {
+			if err == unix.EAGAIN {
+				return nil
+			}
+			if err == unix.ENODEV {
+				r.rest = 0
+				return errDisconnected
+			}
+			return fmt.Errorf("gamepad: Read failed: %w", err)
+		}
+		// The end of the file means that the device is gone.
+		if n == 0 {
+			r.rest = 0
+			return errDisconnected
+		}
+		n += r.rest
+
+		var i int
+		for ; i+eventSize <= n; i += eventSize {
+			if err := f(r.buf[i : i+eventSize]); err != nil {
+				return err
+			}
+		}
+		r.rest = copy(r.buf[:], r.buf[i:n])
+
+		// All the available events have been read, or the read was interrupted.
+		// In the latter case, the rest is read at the next update.
+		if n < len(r.buf) {
+			return nil
+		}
+	}
+}
+
+func (s *motionSensor) handleEvent(e input_event) error {
+	if e.typ == unix.EV_SYN {
+		switch e.code {
+		case _SYN_DROPPED:
+			s.dropped = true
+		case _SYN_REPORT:
+			if s.dropped {
+				s.dropped = false
+				if err := s.pollAbsState(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if s.dropped {
+		return nil
+	}
+	if e.typ != unix.EV_ABS || int(e.code) >= len(s.available) || !s.available[e.code] {
+		return nil
+	}
+	s.setValue(int(e.code), e.value)
+	return nil
+}
+
+func (s *motionSensor) pollAbsState() error {
+	for code := range s.absInfo {
+		if !s.available[code] {
+			continue
+		}
+		if err := ioctl(s.fd, _EVIOCGABS(uint(code)), unsafe.Pointer(&s.absInfo[code])); err != nil {
+			return fmt.Errorf("gamepad: ioctl for an abs of a motion sensor failed: %w", err)
+		}
+		s.setValue(code, s.absInfo[code].value)
+	}
+	return nil
+}
+
+func (s *motionSensor) setValue(code int, value int32) {
+	s.values[code] = motionSensorValue(code, value, s.absInfo[code].resolution)
+}
+
+// motionSensorValue converts an evdev value of a motion sensor to m/s^2 for an accelerometer or rad/s for a gyroscope.
+// The resolution is in units/g for an accelerometer, and in units/(degree/s) for a gyroscope.
+func motionSensorValue(code int, value int32, resolution int32) float64 {
+	if resolution == 0 {
+		return 0
+	}
+	v := float64(value) / float64(resolution)
+	if code <= _ABS_Z {
+		return v * standardGravity
+	}
+	return v * math.Pi / 180
+}
+
+// isGamepadDeviceByProperties reports whether the device with the properties can be a gamepad.
+// Accelerometers including motion sensors of gamepads, touchpads, and pointing sticks are not gamepads.
+func isGamepadDeviceByProperties(propBits []byte) bool {
+	for _, prop := range []int{
+		_INPUT_PROP_ACCELEROMETER,
+		_INPUT_PROP_BUTTONPAD,
+		_INPUT_PROP_SEMI_MT,
+		_INPUT_PROP_TOPBUTTONPAD,
+		_INPUT_PROP_POINTING_STICK,
+	} {
+		if isBitSet(propBits, prop) {
+			return false
+		}
+	}
+	return true
+}
+
+// isGamepadTouchpadDevice reports whether the device is a touchpad of a gamepad.
+// Touchpads of laptops are excluded by the bus types.
+func isGamepadTouchpadDevice(id input_id, propBits, keyBits, absBits []byte) bool {
+	if id.bustype != _BUS_USB && id.bustype != _BUS_BLUETOOTH {
+		return false
+	}
+	if !isBitSet(propBits, _INPUT_PROP_POINTER) && !isBitSet(propBits, _INPUT_PROP_BUTTONPAD) {
+		return false
+	}
+	return isBitSet(keyBits, _BTN_LEFT) &&
+		isBitSet(absBits, _ABS_MT_SLOT) &&
+		isBitSet(absBits, _ABS_MT_POSITION_X) &&
+		isBitSet(absBits, _ABS_MT_POSITION_Y) &&
+		isBitSet(absBits, _ABS_MT_TRACKING_ID)
+}
+
+// openTouchpad starts to read the touchpad device at path, which is already opened as fd.
+func (g *nativeGamepadsImpl) openTouchpad(gamepads *gamepads, fd int, path string, phys, uniq string) error {
+	// A touchpad without the physical path or the unique identifier cannot be associated with its gamepad.
+	if phys == "" && uniq == "" {
+		if err := unix.Close(fd); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	t := &touchpad{
+		fd:     fd,
+		path:   path,
+		phys:   phys,
+		uniq:   uniq,
+		poller: ioctlEvdevPoller{},
+	}
+	if err := ioctl(fd, _EVIOCGABS(_ABS_MT_POSITION_X), unsafe.Pointer(&t.absInfoX)); err != nil {
+		return fmt.Errorf("gamepad: ioctl for an abs of a touchpad failed: %w", err)
+	}
+	if err := ioctl(fd, _EVIOCGABS(_ABS_MT_POSITION_Y), unsafe.Pointer(&t.absInfoY)); err != nil {
+		return fmt.Errorf("gamepad: ioctl for an abs of a touchpad failed: %w", err)
+	}
+	if err := t.pollState(); err != nil {
+		return err
+	}
+
+	g.touchpads = append(g.touchpads, t)
+	g.watchFD(fd)
+	g.attachTouchpads(gamepads)
+	return nil
+}
+
+// attachTouchpads associates the touchpads with the gamepads of the same physical devices.
+func (g *nativeGamepadsImpl) attachTouchpads(gamepads *gamepads) {
+	for _, gp := range gamepads.gamepads {
+		if gp == nil {
+			continue
+		}
+		n, ok := gp.native.(*nativeGamepadImpl)
+		if !ok {
+			continue
+		}
+		var touchpad *touchpad
+		for _, t := range g.touchpads {
+			if isSameInputDevice(n.phys, n.serial_, t.phys, t.uniq) {
+				touchpad = t
+				break
+			}
+		}
+		gp.m.Lock()
+		n.touchpad = touchpad
+		gp.m.Unlock()
+	}
+}
+
+// touchpad is an evdev multi-touch device reporting the touches on the touchpad of a gamepad.
+// The touchpad click is reported as BTN_LEFT.
+type touchpad struct {
+	fd   int
+	path string
+	phys string
+	uniq string
+
+	absInfoX input_absinfo
+	absInfoY input_absinfo
+
+	// slot is the current multi-touch slot. Events for ABS_MT_* codes are applied to this slot.
+	slot    int
+	touches [TouchpadTouchCount]touchpadTouch
+	pressed bool
+	dropped bool
+
+	// poller reads the current state of the device, which is needed after events are dropped.
+	poller   evdevPoller
+	keyState [(_KEY_CNT + 7) / 8]byte
+
+	reader inputEventReader
+}
+
+type touchpadTouch struct {
+	touching bool
+	x        int32
+	y        int32
+}
+
+func (t *touchpad) close() {
+	if t.fd != 0 {
+		_ = unix.Close(t.fd)
+	}
+	t.fd = 0
+}
+
+func (t *touchpad) update() error {
+	if t.fd == 0 {
+		return nil
+	}
+	if err := t.reader.read(fdEvdevDevice(t.fd), inputEventSize, t.handleEventBytes); err != nil {
+		if err == errDisconnected {
+			t.close()
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *touchpad) handleEventBytes(buf []byte) error {
+	return t.handleEvent(parseInputEvent(buf))
+}
+
+func (t *touchpad) handleEvent(e input_event) error {
+	if e.typ == unix.EV_SYN {
+		switch e.code {
+		case _SYN_DROPPED:
+			t.dropped = true
+		case _SYN_REPORT:
+			if t.dropped {
+				t.dropped = false
+				if err := t.pollState(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if t.dropped {
+		return nil
+	}
+
+	switch e.typ {
+	case unix.EV_KEY:
+		if e.code == _BTN_LEFT {
+			t.pressed = e.value != 0
+		}
+	case unix.EV_ABS:
+		if e.code == _ABS_MT_SLOT {
+			t.slot = int(e.value)
+			return nil
+		}
+		// Touches beyond TouchpadTouchCount are ignored.
+		if t.slot < 0 || t.slot >= len(t.touches) {
+			return nil
+		}
+		touch := &t.touches[t.slot]
+		switch e.code {
+		case _ABS_MT_TRACKING_ID:
+			// A tracking ID -1 means the touch is released.
+			touch.touching = e.value >= 0
+		case _ABS_MT_POSITION_X:
+			touch.x = e.value
+		case _ABS_MT_POSITION_Y:
+			touch.y = e.value
+		}
+	}
+	return nil
+}
+
+// pollState reads the current touches and the click state.
+func (t *touchpad) pollState() error {
+	if err := t.poller.keyState(fdEvdevDevice(t.fd), t.keyState[:]); err != nil {
+		return fmt.Errorf("gamepad: ioctl for the key state of a touchpad failed: %w", err)
+	}
+	t.pressed = isBitSet(t.keyState[:], _BTN_LEFT)
+
+	var slot input_absinfo
+	if err := t.poller.absInfo(fdEvdevDevice(t.fd), _ABS_MT_SLOT, &slot); err != nil {
+		return fmt.Errorf("gamepad: ioctl for an abs of a touchpad failed: %w", err)
+	}
+	t.slot = int(slot.value)
+
+	for _, code := range []int{_ABS_MT_TRACKING_ID, _ABS_MT_POSITION_X, _ABS_MT_POSITION_Y} {
+		values, err := t.poller.mtSlots(fdEvdevDevice(t.fd), code, len(t.touches))
+		if err != nil {
+			return fmt.Errorf("gamepad: ioctl for multi-touch slots of a touchpad failed: %w", err)
+		}
+		for i := range t.touches {
+			switch code {
+			case _ABS_MT_TRACKING_ID:
+				t.touches[i].touching = values[i] >= 0
+			case _ABS_MT_POSITION_X:
+				t.touches[i].x = values[i]
+			case _ABS_MT_POSITION_Y:
+				t.touches[i].y = values[i]
+			}
+		}
+	}
+	return nil
+}
+
+// touchPosition returns the position of the touch at index in the range of [0, 1].
+func (t *touchpad) touchPosition(index int) (float64, float64, bool) {
+	if index < 0 || index >= len(t.touches) {
+		return 0, 0, false
+	}
+	touch := &t.touches[index]
+	if !touch.touching {
+		return 0, 0, false
+	}
+	return normalizeTouchValue(&t.absInfoX, touch.x), normalizeTouchValue(&t.absInfoY, touch.y), true
+}
+
+// normalizeTouchValue normalizes a touch position value to the range of [0, 1].
+func normalizeTouchValue(info *input_absinfo, value int32) float64 {
+	if info.maximum <= info.minimum {
+		return 0
+	}
+	v := float64(value-info.minimum) / float64(info.maximum-info.minimum)
+	return math.Max(0, math.Min(1, v))
+}
+
+const (
+	ueventGroupKernel = 1
+	ueventGroupUdev   = 2
+)
+
+func openUeventSocket() (int, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return 0, fmt.Errorf("gamepad: Socket failed: %w", err)
+	}
+
+	// Listen to the uevents from udev if udev is running. udev sends uevents after setting up the device files e.g. their permissions.
+	// Otherwise, listen to the uevents from the kernel.
+	group := uint32(ueventGroupKernel)
+	if _, err := os.Stat("/run/udev/control"); err == nil {
+		group = ueventGroupUdev
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: group,
+	}); err != nil {
+		_ = unix.Close(fd)
+		return 0, fmt.Errorf("gamepad: Bind failed: %w", err)
+	}
+	return fd, nil
+}
+
+// parseUevent parses a uevent message from either the kernel or udev, and returns its properties.
+func parseUevent(buf []byte) (action, subsystem, devName string) {
+	const udevPrefix = "libudev\x00"
+	if len(buf) >= 24 && string(buf[:len(udevPrefix)]) == udevPrefix {
+		// A udev message starts with the header udev_monitor_netlink_header.
+		// The properties are at properties_off.
+		offset := int(uint32(buf[16]) | uint32(buf[17])<<8 | uint32(buf[18])<<16 | uint32(buf[19])<<24)
+		if offset > len(buf) {
+			return "", "", ""
+		}
+		buf = buf[offset:]
+	} else {
+		// A kernel message starts with "ACTION@DEVPATH".
+		idx := bytes.IndexByte(buf, 0)
+		if idx < 0 {
+			return "", "", ""
+		}
+		buf = buf[idx+1:]
+	}
+
+	// The properties are null-separated "KEY=VALUE" strings.
+	for len(buf) > 0 {
+		var prop []byte
+		if idx := bytes.IndexByte(buf, 0); idx >= 0 {
+			prop, buf = buf[:idx], buf[idx+1:]
+		} else {
+			prop, buf = buf, nil
+		}
+		key, value, ok := bytes.Cut(prop, []byte("="))
+		if !ok {
+			continue
+		}
+		switch string(key) {
+		case "ACTION":
+			action = string(value)
+		case "SUBSYSTEM":
+			subsystem = string(value)
+		case "DEVNAME":
+			devName = string(value)
+		}
+	}
+	return action, subsystem, devName
+}
+
+type nativeGamepadImpl struct {
+	fd      int
+	dev     evdevDevice
+	path    string
+	serial_ string
+	phys    string
+
+	// bustype is the bus type of the device like _BUS_USB.
+	bustype uint16
+
+	// vendor and product are the vendor ID and the product ID of the device.
+	vendor  uint16
+	product uint16
+
+	// steamVirtual reports whether the device is a virtual gamepad created by Steam Input.
+	steamVirtual bool
+
+	// kernelName_ is the name of the device reported by the kernel.
+	// The name of the gamepad might be different from this.
+	kernelName_ string
+
+	// parentPath is the sysfs directory of the device that created the input device, e.g., the HID device.
+	parentPath string
+
+	keyMap  [_KEY_CNT - _BTN_MISC]int
+	absMap  [_ABS_CNT]int
+	absInfo [_ABS_CNT]input_absinfo
+	dropped bool
+
+	// joydev reports whether fd is a joydev device instead of an evdev device.
+	// joydevAxisMap and joydevButtonMap map joydev's axis and button numbers to evdev's codes.
+	joydev          bool
+	joydevAxisMap   [_ABS_CNT]uint8
+	joydevButtonMap [_KEY_MAX - _BTN_MISC + 1]uint16
+
+	// absValues is the last absolute values after filtering the noise by fuzz.
+	absValues [_ABS_CNT]int32
+
+	// poller reads the current state of the evdev device, which is needed after events are dropped.
+	// keyState is a buffer to read the key state.
+	poller   evdevPoller
+	keyState [(_KEY_CNT + 7) / 8]byte
+
+	reader inputEventReader
+
+	axes    [_ABS_CNT]float64
+	rawAxes [_ABS_CNT]float64
+	buttons [_KEY_CNT - _BTN_MISC]bool
+	hats    [(_ABS_HAT3Y - _ABS_HAT0X + 1) / 2]int
+
+	axisCount_   int
+	buttonCount_ int
+	hatCount_    int
+
+	stdAxisMap   map[gamepaddb.StandardAxis]mappingInput
+	stdButtonMap map[gamepaddb.StandardButton]mappingInput
+
+	// rumble reports whether the device supports FF_RUMBLE and is opened with the write access.
+	rumble bool
+
+	// ffGain reports whether the device supports FF_GAIN and is opened with the write access.
+	// If the device doesn't support FF_GAIN, the magnitudes of the effects are attenuated instead.
+	ffGain bool
+
+	// ff operates force feedback effects of the device.
+	ff forceFeedback
+
+	// vibrationAttenuation is 1 minus the gain of vibrations, so that the zero value means the full gain.
+	vibrationAttenuation float64
+
+	// ffGainWritten reports whether ffGainValue has been written to the device.
+	ffGainWritten bool
+	ffGainValue   uint16
+
+	// ffEffectID is the ID of the uploaded force feedback effect, or -1 if there is no effect.
+	// The effect is kept while it is playing, and is updated in place by the next vibration.
+	ffEffectID  int16
+	ffEffectEnd time.Time
+
+	// powerSupplyPath is the sysfs directory of the battery, or an empty string if the battery is not found.
+	// The battery information is cached and is refreshed at batteryUpdated+batteryUpdateInterval.
+	powerSupplyPath string
+	batteryUpdated  time.Time
+	batteryLevel_   int
+	powerState_     PowerState
+
+	// motion is the motion sensor device of the gamepad, or nil if the gamepad doesn't have one.
+	motion *motionSensor
+
+	// touchpad is the touchpad device of the gamepad, or nil if the gamepad doesn't have one.
+	touchpad *touchpad
+
+	// playerLEDPaths is the list of the sysfs directories of the player indicator LEDs, ordered by their numbers.
+	playerLEDPaths []string
+
+	// axisDeadZoneDisabled reports whether raw axis values are used without the dead zones and the noise filtering.
+	axisDeadZoneDisabled bool
+
+	// calibration is the calibrations of the axes by their indices.
+	// The calibrated values are passed to the noise filtering and the dead zones.
+	calibration map[int]AxisCalibration
+
+	// calibrationRecord is the observed ranges of the axes by their indices, or nil if the ranges are not being recorded.
+	calibrationRecord map[int]AxisCalibration
+
+	// grabbed reports whether the device is grabbed exclusively by EVIOCGRAB.
+	grabbed bool
+
+	// lastActive_ is the time when a button, an axis, or a hat changed its state last time.
+	// This is zero if the state has never changed since the gamepad was opened.
+	lastActive_ time.Time
+}
+
+func (g *nativeGamepadImpl) close() {
+	if g.fd != 0 {
+		g.removeFFEffect()
+		_ = g.dev.close()
+	}
+	g.fd = 0
+	g.dev = nil
+}
+
+func (g *nativeGamepadImpl) update(gamepads *gamepads) error {
+	g.axisDeadZoneDisabled = gamepads.axisDeadZoneDisabled
+	g.setExclusiveGrab(gamepads.shouldGrabExclusively())
+
+	if g.fd == 0 {
+		return nil
+	}
+
+	// The device stops playing the effect by itself, but the effect still occupies a slot until it is removed.
+	if g.ffEffectID >= 0 && !time.Now().Before(g.ffEffectEnd) {
+		g.removeFFEffect()
+	}
+
+	if time.Since(g.batteryUpdated) >= batteryUpdateInterval {
+		g.updateBattery()
+	}
+
+	// The motion sensor and the touchpad are optional. Close them on errors and keep the gamepad working.
+	if g.motion != nil && isReadyFD(gamepads, g.motion.fd) {
+		if err := g.motion.update(); err != nil {
+			gamepads.addDeviceError(DeviceError{
+				Path: g.motion.path,
+				Err:  err,
+			})
+			g.motion.close()
+		}
+	}
+
+	if g.touchpad != nil && isReadyFD(gamepads, g.touchpad.fd) {
+		if err := g.touchpad.update(); err != nil {
+			gamepads.addDeviceError(DeviceError{
+				Path: g.touchpad.path,
+				Err:  err,
+			})
+			g.touchpad.close()
+		}
+	}
+
+	if !isReadyFD(gamepads, g.fd) {
+		return nil
+	}
+
+	// Read multiple events at once to reduce the number of syscalls.
+	var err error
+	if g.joydev {
+		err = g.reader.read(g.dev, int(unsafe.Sizeof(js_event{})), g.handleJoydevEvent)
+	} else {
+		err = g.reader.read(g.dev, inputEventSize, g.handleEvent)
+	}
+	if err != nil {
+		// Remove the gamepad immediately instead of waiting for the notification of the device file removal,
+		// which might never come e.g. in sandboxed environments.
+		if err == errDisconnected {
+			g.close()
+		}
+		return err
+	}
+	return nil
+}
+
+// inputEventSize is the byte size of an input_event.
+const inputEventSize = int(unsafe.Sizeof(input_event{}))
+
+// parseInputEvent parses an input_event in buf. The time field is not parsed as it is not used.
+func parseInputEvent(buf []byte) input_event {
+	const (
+		offsetTyp   = unsafe.Offsetof(input_event{}.typ)
+		offsetCode  = unsafe.Offsetof(input_event{}.code)
+		offsetValue = unsafe.Offsetof(input_event{}.value)
+	)
+	return input_event{
+		typ:   uint16(buf[offsetTyp]) | uint16(buf[offsetTyp+1])<<8,
+		code:  uint16(buf[offsetCode]) | uint16(buf[offsetCode+1])<<8,
+		value: int32(buf[offsetValue]) | int32(buf[offsetValue+1])<<8 | int32(buf[offsetValue+2])<<16 | int32(buf[offsetValue+3])<<24,
+	}
+}
+
+func (g *nativeGamepadImpl) handleEvent(buf []byte) error {
+	e := parseInputEvent(buf)
+
+	if e.typ == unix.EV_SYN {
+		switch e.code {
+		case _SYN_DROPPED:
+			g.dropped = true
+		case _SYN_REPORT:
+			// Some events were dropped, and the button state might be stale. Read the whole key state.
+			if g.dropped {
+				if err := g.pollKeyState(); err != nil {
+					return fmt.Errorf("gamepad: poll key state: %w", err)
+				}
+			}
+			g.dropped = false
+			if err := g.pollAbsState(); err != nil {
+				return fmt.Errorf("gamepad: poll absolute state: %w", err)
+			}
+		}
+	}
+	if g.dropped {
+		return nil
+	}
+
+	switch e.typ {
+	case unix.EV_KEY:
+		// Key codes below BTN_MISC are keyboard keys, which are not treated as buttons.
+		if e.code < _BTN_MISC || e.code >= _KEY_CNT {
+			return nil
+		}
+		idx := g.keyMap[e.code-_BTN_MISC]
+		if idx < 0 {
+			return nil
+		}
+		g.setButton(idx, e.value != 0)
+	case unix.EV_ABS:
+		g.handleAbsEvent(int(e.code), e.value)
+	}
+	return nil
+}
+
+func (g *nativeGamepadImpl) handleJoydevEvent(buf []byte) error {
+	const (
+		offsetValue  = unsafe.Offsetof(js_event{}.value)
+		offsetTyp    = unsafe.Offsetof(js_event{}.typ)
+		offsetNumber = unsafe.Offsetof(js_event{}.number)
+	)
+	// time is not used.
+	e := js_event{
+		value:  int16(uint16(buf[offsetValue]) | uint16(buf[offsetValue+1])<<8),
+		typ:    buf[offsetTyp],
+		number: buf[offsetNumber],
+	}
+
+	// JS_EVENT_INIT is set for the events reporting the initial state.
+	switch e.typ &^ _JS_EVENT_INIT {
+	case _JS_EVENT_BUTTON:
+		code := g.joydevButtonMap[e.number]
+		if code < _BTN_MISC || code >= _KEY_CNT {
+			return nil
+		}
+		idx := g.keyMap[code-_BTN_MISC]
+		if idx < 0 {
+			return nil
+		}
+		g.setButton(idx, e.value != 0)
+	case _JS_EVENT_AXIS:
+		if int(e.number) >= len(g.joydevAxisMap) {
+			return nil
+		}
+		g.handleAbsEvent(int(g.joydevAxisMap[e.number]), int32(e.value))
+	}
+	return nil
+}
+
+func (g *nativeGamepadImpl) pollAbsState() error {
+	// joydev doesn't have a way to poll the state, but the state is kept up to date by the events.
+	if g.joydev {
+		return nil
+	}
+
+	for code := 0; code < _ABS_CNT; code++ {
+		if g.absMap[code] < 0 {
+			continue
+		}
+		if err := g.poller.absInfo(g.dev, code, &g.absInfo[code]); err != nil {
+			return fmt.Errorf("gamepad: ioctl for an abs at pollAbsState failed: %w", err)
+		}
+		g.handleAbsEvent(code, g.absInfo[code].value)
+	}
+	return nil
+}
+
+func (g *nativeGamepadImpl) pollKeyState() error {
+	// joydev doesn't drop events.
+	if g.joydev {
+		return nil
+	}
+
+	if err := g.poller.keyState(g.dev, g.keyState[:]); err != nil {
+		return fmt.Errorf("gamepad: ioctl for keys at pollKeyState failed: %w", err)
+	}
+	for code := _BTN_MISC; code < _KEY_CNT; code++ {
+		idx := g.keyMap[code-_BTN_MISC]
+		if idx < 0 {
+			continue
+		}
+		g.setButton(idx, isBitSet(g.keyState[:], code))
+	}
+	return nil
+}
+
+// evdevDevice is an opened device file of evdev or joydev.
+type evdevDevice interface {
+	// read reads the available events to buf. read returns unix.EAGAIN when no events are available.
+	read(buf []byte) (int, error)
+
+	// ioctl performs the ioctl request with the argument ptr.
+	ioctl(request uint, ptr unsafe.Pointer) error
+
+	// close closes the device file.
+	close() error
+}
+
+// fdEvdevDevice is a device file opened as the file descriptor.
+type fdEvdevDevice int
+
+func (d fdEvdevDevice) read(buf []byte) (int, error) {
+	return unix.Read(int(d), buf)
+}
+
+func (d fdEvdevDevice) ioctl(request uint, ptr unsafe.Pointer) error {
+	return ioctl(int(d), request, ptr)
+}
+
+func (d fdEvdevDevice) close() error {
+	return unix.Close(int(d))
+}
+
+// evdevPoller reads the current state of an evdev device.
+type evdevPoller interface {
+	// absInfo reads the state of the absolute axis with the code.
+	absInfo(dev evdevDevice, code int, info *input_absinfo) error
+
+	// keyState reads the bitmask of the pressed keys.
+	keyState(dev evdevDevice, keyBits []byte) error
+
+	// mtSlots reads the values of the multi-touch code for the first n slots.
+	mtSlots(dev evdevDevice, code int, n int) ([]int32, error)
+}
+
+type ioctlEvdevPoller struct{}
+
+func (ioctlEvdevPoller) absInfo(dev evdevDevice, code int, info *input_absinfo) error {
+	return dev.ioctl(_EVIOCGABS(uint(code)), unsafe.Pointer(info))
+}
+
+func (ioctlEvdevPoller) keyState(dev evdevDevice, keyBits []byte) error {
+	return dev.ioctl(_EVIOCGKEY(uint(len(keyBits))), unsafe.Pointer(&keyBits[0]))
+}
+
+func (ioctlEvdevPoller) mtSlots(dev evdevDevice, code int, n int) ([]int32, error) {
+	// The buffer is the code followed by the values of the slots.
+	buf := make([]int32, 1+n)
+	buf[0] = int32(code)
+	if err := dev.ioctl(_EVIOCGMTSLOTS(uint(len(buf)*4)), unsafe.Pointer(&buf[0])); err != nil {
+		return nil, err
+	}
+	return buf[1:], nil
+}
+
+func (g *nativeGamepadImpl) handleAbsEvent(code int, value int32) {
+	if code < 0 || code >= len(g.absMap) {
+		return
+	}
+	index := g.absMap[code]
+	if index < 0 {
+		return
+	}
+
+	if code >= _ABS_HAT0X && code <= _ABS_HAT3Y {
+		if index >= len(g.hats) {
+			return
+		}
+		prev := g.hats[index]
+		defer func() {
+			if g.hats[index] != prev {
+				g.lastActive_ = time.Now()
+			}
+		}()
+		axis := (code - _ABS_HAT0X) % 2
+
+		switch axis {
+		case 0:
+			switch {
+			case value < 0:
+				g.hats[index] |= hatLeft
+				g.hats[index] &^= hatRight
+			case value > 0:
+				g.hats[index] &^= hatLeft
+				g.hats[index] |= hatRight
+			default:
+				g.hats[index] &^= hatLeft | hatRight
+			}
+		case 1:
+			switch {
+			case value < 0:
+				g.hats[index] |= hatUp
+				g.hats[index] &^= hatDown
+			case value > 0:
+				g.hats[index] &^= hatUp
+				g.hats[index] |= hatDown
+			default:
+				g.hats[index] &^= hatUp | hatDown
+			}
+		}
+		return
+	}
+
+	info := &g.absInfo[code]
+	if g.calibrationRecord != nil {
+		v := normalizeAbsValue(info, value, false)
+		c, ok := g.calibrationRecord[index]
+		if !ok {
+			c = AxisCalibration{Min: v, Center: v, Max: v}
+		}
+		c.record(v)
+		g.calibrationRecord[index] = c
+	}
+	if c, ok := g.calibration[index]; ok {
+		value = denormalizeAbsValue(info, c.apply(normalizeAbsValue(info, value, false)))
+	}
+	g.rawAxes[index] = normalizeAbsValue(info, value, false)
+
+	value = defuzzAbsValue(value, g.absValues[code], info.fuzz)
+	g.absValues[code] = value
+	// Compare the values with the dead zone so that the noise of a resting stick is not treated as an activity.
+	v := normalizeAbsValue(info, value, true)
+	if g.axes[index] != v {
+		g.lastActive_ = time.Now()
+	}
+	g.axes[index] = v
+}
+
+func (g *nativeGamepadImpl) startCalibration() {
+	g.calibrationRecord = map[int]AxisCalibration{}
+	if g.fd == 0 {
+		return
+	}
+
+	// The current values are regarded as the values at rest.
+	for code, index := range g.absMap {
+		if index < 0 || (code >= _ABS_HAT0X && code <= _ABS_HAT3Y) {
+			continue
+		}
+		var info input_absinfo
+		if err := g.poller.absInfo(g.dev, code, &info); err != nil {
+			continue
+		}
+		v := normalizeAbsValue(&g.absInfo[code], info.value, false)
+		g.calibrationRecord[index] = AxisCalibration{
+			Min:    v,
+			Center: v,
+			Max:    v,
+		}
+	}
+}
+
+func (g *nativeGamepadImpl) finishCalibration() map[int]AxisCalibration {
+	r := g.calibrationRecord
+	g.calibrationRecord = nil
+	return r
+}
+
+func (g *nativeGamepadImpl) setCalibration(axes map[int]AxisCalibration) {
+	g.calibration = axes
+
+	// Apply the calibration to the current values. An error is reported at the next update if the device is broken.
+	if g.fd != 0 {
+		_ = g.pollAbsState()
+	}
+}
+
+func (g *nativeGamepadImpl) setButton(index int, pressed bool) {
+	if g.buttons[index] != pressed {
+		g.lastActive_ = time.Now()
+	}
+	g.buttons[index] = pressed
+}
+
+func (g *nativeGamepadImpl) devicePath() string {
+	return g.path
+}
+
+func (g *nativeGamepadImpl) kernelName() string {
+	return g.kernelName_
+}
+
+func (g *nativeGamepadImpl) lastActive() time.Time {
+	return g.lastActive_
+}
+
+// normalizeAbsValue converts an absolute value to a value in [-1, 1].
+// If deadZone is true, the values within info.flat from the center are treated as 0,
+// and the rest are rescaled so that the values can still reach -1 and 1.
+//
+// Some devices have broken descriptors. An axis whose minimum equals its maximum is always 0.
+// An axis whose minimum is greater than its maximum is treated as an inverted axis.
+// Values out of the range are clamped.
+func normalizeAbsValue(info *input_absinfo, value int32, deadZone bool) float64 {
+	min := float64(info.minimum)
+	max := float64(info.maximum)
+	v := float64(value)
+	if max == min {
+		return 0
+	}
+	if min > max {
+		return -normalizeAbsValueInRange(max, min, v, float64(info.flat), deadZone)
+	}
+	return normalizeAbsValueInRange(min, max, v, float64(info.flat), deadZone)
+}
 
-		switch e.typ {
-		case unix.EV_KEY:
-			if int(e.code-_BTN_MISC) < len(g.keyMap) {
-				idx := g.keyMap[e.code-_BTN_MISC]
-				if idx < 0 {
-					continue
-				}
-				g.buttons[idx] = e.value != 0
-			}
-		case unix.EV_ABS:
-			g.handleAbsEvent(int(e.code), e.value)
-		}
+// denormalizeAbsValue is the inverse of normalizeAbsValue without the dead zone.
+func denormalizeAbsValue(info *input_absinfo, v float64) int32 {
+	min := float64(info.minimum)
+	max := float64(info.maximum)
+	if min > max {
+		min, max = max, min
+		v = -v
 	}
-	return nil
+	return int32(math.Round(min + (v+1)/2*(max-min)))
 }
 
-func (g *nativeGamepadImpl) pollAbsState() error {
-	for code := 0; code < _ABS_CNT; code++ {
-		if g.absMap[code] < 0 {
-			continue
+func normalizeAbsValueInRange(min, max, v, flat float64, deadZone bool) float64 {
+	v = math.Max(math.Min(v, max), min)
+
+	if !deadZone || flat <= 0 {
+		return (v-min)/(max-min)*2 - 1
+	}
+
+	center := (min + max) / 2
+	switch {
+	case v > center+flat:
+		if max <= center+flat {
+			return 1
 		}
-		if err := ioctl(g.fd, uint(_EVIOCGABS(uint(code))), unsafe.Pointer(&g.absInfo[code])); err != nil {
-			return fmt.Errorf("gamepad: ioctl for an abs at pollAbsState failed: %w", err)
+		return math.Min((v-center-flat)/(max-center-flat), 1)
+	case v < center-flat:
+		if min >= center-flat {
+			return -1
 		}
-		g.handleAbsEvent(code, g.absInfo[code].value)
+		return math.Max(-(center-flat-v)/(center-flat-min), -1)
+	default:
+		return 0
 	}
-	return nil
 }
 
-func (g *nativeGamepadImpl) handleAbsEvent(code int, value int32) {
-	index := g.absMap[code]
-	if index < 0 {
-		return
+// defuzzAbsValue suppresses the changes within the noise level.
+// This is the same algorithm as input_defuzz_abs_event in the Linux kernel.
+func defuzzAbsValue(value, old, fuzz int32) int32 {
+	if fuzz <= 0 {
+		return value
 	}
+	if value > old-fuzz/2 && value < old+fuzz/2 {
+		return old
+	}
+	if value > old-fuzz && value < old+fuzz {
+		return (old*3 + value) / 4
+	}
+	if value > old-fuzz*2 && value < old+fuzz*2 {
+		return (old + value) / 2
+	}
+	return value
+}
 
-	if code >= _ABS_HAT0X && code <= _ABS_HAT3Y {
-		axis := (code - _ABS_HAT0X) % 2
-
-		switch axis {
-		case 0:
-			switch {
-			case value < 0:
-				g.hats[index] |= hatLeft
-				g.hats[index] &^= hatRight
-			case value > 0:
-				g.hats[index] &^= hatLeft
-				g.hats[index] |= hatRight
-			default:
-				g.hats[index] &^= hatLeft | hatRight
-			}
-		case 1:
-			switch {
-			case value < 0:
-				g.hats[index] |= hatUp
-				g.hats[index] &^= hatDown
-			case value > 0:
-				g.hats[index] &^= hatUp
-				g.hats[index] |= hatDown
-			default:
-				g.hats[index] &^= hatUp | hatDown
-			}
+// mapButtons assigns button indices to the key codes in keyBits in the ascending order.
+// Key codes from BTN_MISC to KEY_MAX are treated as buttons, which covers the BTN_TRIGGER_HAPPY range.
+func (g *nativeGamepadImpl) mapButtons(keyBits []byte) {
+	var buttonCount int
+	for i := range g.keyMap {
+		g.keyMap[i] = -1
+	}
+	for code := _BTN_MISC; code < _KEY_CNT && code/8 < len(keyBits); code++ {
+		if !isBitSet(keyBits, code) {
+			continue
 		}
-		return
+		g.keyMap[code-_BTN_MISC] = buttonCount
+		buttonCount++
 	}
+	g.buttonCount_ = buttonCount
+}
 
-	info := g.absInfo[code]
-	v := float64(value)
-	if r := float64(info.maximum) - float64(info.minimum); r != 0 {
-		v = (v - float64(info.minimum)) / r
-		v = v*2 - 1
+// mapAxesAndHats assigns axis indices and hat indices to the absolute axis codes in absBits in the ascending order.
+// A pair of ABS_HATnX and ABS_HATnY is treated as one hat even if only one of them exists.
+func (g *nativeGamepadImpl) mapAxesAndHats(absBits []byte) {
+	var axisCount int
+	var hatCount int
+	for i := range g.absMap {
+		g.absMap[i] = -1
 	}
-	g.axes[index] = v
+	for code := 0; code < _ABS_CNT && code/8 < len(absBits); code++ {
+		if !isBitSet(absBits, code) {
+			continue
+		}
+		if code >= _ABS_HAT0X && code <= _ABS_HAT3Y {
+			// Write the hat index both for the X and the Y hat axis.
+			// That way, the hat can be referenced using either axis, which is used by the code building hatMappingInput.
+			x := code - (code-_ABS_HAT0X)%2
+			g.absMap[x] = hatCount
+			g.absMap[x+1] = hatCount
+			hatCount++
+			// Skip the Y axis of the same hat.
+			code = x + 1
+			continue
+		}
+		g.absMap[code] = axisCount
+		axisCount++
+	}
+	g.axisCount_ = axisCount
+	g.hatCount_ = hatCount
 }
 
 func (g *nativeGamepadImpl) computeStandardLayout(vendor uint16) {
@@ -551,6 +2608,23 @@ func (g *nativeGamepadImpl) computeStandardLayout(vendor uint16) {
 		g.stdButtonMap[gamepaddb.StandardButtonLeftRight] = buttonMappingInput{g: g, button: b}
 	}
 
+	// xpad reports the D-pad as BTN_TRIGGER_HAPPY1-4 (left, right, up, and down) for some gamepads like wireless Xbox 360 controllers.
+	// Use them only when the D-pad is not found in the other ways, as arcade sticks might have other buttons in this range.
+	if _, ok := g.stdButtonMap[gamepaddb.StandardButtonLeftLeft]; !ok {
+		if b := g.keyMap[_BTN_TRIGGER_HAPPY1-_BTN_MISC]; b >= 0 {
+			g.stdButtonMap[gamepaddb.StandardButtonLeftLeft] = buttonMappingInput{g: g, button: b}
+		}
+		if b := g.keyMap[_BTN_TRIGGER_HAPPY2-_BTN_MISC]; b >= 0 {
+			g.stdButtonMap[gamepaddb.StandardButtonLeftRight] = buttonMappingInput{g: g, button: b}
+		}
+		if b := g.keyMap[_BTN_TRIGGER_HAPPY3-_BTN_MISC]; b >= 0 {
+			g.stdButtonMap[gamepaddb.StandardButtonLeftTop] = buttonMappingInput{g: g, button: b}
+		}
+		if b := g.keyMap[_BTN_TRIGGER_HAPPY4-_BTN_MISC]; b >= 0 {
+			g.stdButtonMap[gamepaddb.StandardButtonLeftBottom] = buttonMappingInput{g: g, button: b}
+		}
+	}
+
 	// Left stick.
 	if a := g.absMap[_ABS_X]; a >= 0 {
 		g.stdAxisMap[gamepaddb.StandardAxisLeftStickHorizontal] = axisMappingInput{g: g, axis: a}
@@ -568,6 +2642,267 @@ func (g *nativeGamepadImpl) computeStandardLayout(vendor uint16) {
 	}
 }
 
+// batteryUpdateInterval is the interval to read the battery information, which doesn't have to be read every frame.
+const batteryUpdateInterval = 5 * time.Second
+
+func (g *nativeGamepadImpl) updateBattery() {
+	g.batteryUpdated = time.Now()
+	g.batteryLevel_ = -1
+	g.powerState_ = PowerStateUnknown
+
+	// The battery might be registered later than the input device. Retry to find it until it is found.
+	if g.powerSupplyPath == "" {
+		g.powerSupplyPath = findPowerSupplyPath(g.path)
+		if g.powerSupplyPath == "" {
+			return
+		}
+	}
+
+	if bs, err := os.ReadFile(filepath.Join(g.powerSupplyPath, "capacity")); err == nil {
+		if v, err := strconv.Atoi(strings.TrimSpace(string(bs))); err == nil {
+			g.batteryLevel_ = v
+		}
+	}
+	if bs, err := os.ReadFile(filepath.Join(g.powerSupplyPath, "status")); err == nil {
+		switch strings.TrimSpace(string(bs)) {
+		case "Discharging":
+			g.powerState_ = PowerStateOnBattery
+		case "Charging":
+			g.powerState_ = PowerStateCharging
+		case "Full", "Not charging":
+			g.powerState_ = PowerStateCharged
+		}
+	}
+}
+
+// findPowerSupplyPath finds the sysfs directory of the battery of the device at the given device file path.
+// findPowerSupplyPath returns an empty string if the device doesn't have a battery.
+func findPowerSupplyPath(path string) string {
+	for _, dir := range sysfsDeviceAncestors(path) {
+		matches, err := filepath.Glob(filepath.Join(dir, "power_supply", "*", "capacity"))
+		if err == nil && len(matches) > 0 {
+			return filepath.Dir(matches[0])
+		}
+	}
+	return ""
+}
+
+// findPlayerLEDPaths finds the sysfs directories of the player indicator LEDs of the device at the given device file path.
+// The result is ordered by the LED numbers.
+func findPlayerLEDPaths(path string) []string {
+	for _, dir := range sysfsDeviceAncestors(path) {
+		entries, err := os.ReadDir(filepath.Join(dir, "leds"))
+		if err != nil {
+			continue
+		}
+		type led struct {
+			path   string
+			number int
+		}
+		var leds []led
+		for _, e := range entries {
+			n, ok := playerLEDNumber(e.Name())
+			if !ok {
+				continue
+			}
+			leds = append(leds, led{
+				path:   filepath.Join(dir, "leds", e.Name()),
+				number: n,
+			})
+		}
+		if len(leds) == 0 {
+			continue
+		}
+		sort.Slice(leds, func(i, j int) bool {
+			return leds[i].number < leds[j].number
+		})
+		paths := make([]string, len(leds))
+		for i, l := range leds {
+			paths[i] = l.path
+		}
+		return paths
+	}
+	return nil
+}
+
+// playerLEDNumber returns the number of the player indicator LED with the given name.
+// The second value is false if the LED is not a player indicator LED.
+func playerLEDNumber(name string) (int, bool) {
+	m := rePlayerLED.FindStringSubmatch(name)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// sysfsDeviceAncestors returns the sysfs directory of the device at the given device file path and its ancestors.
+// Batteries and LEDs are registered to the HID device, which is an ancestor of the input device,
+// e.g., /sys/devices/.../0005:054C:09CC.0001/power_supply/sony_controller_battery_xx:xx:xx:xx:xx:xx.
+func sysfsDeviceAncestors(path string) []string {
+	dir, err := filepath.EvalSymlinks(filepath.Join("/sys/class/input", filepath.Base(path), "device"))
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for i := 0; i < 4 && dir != "/"; i++ {
+		dirs = append(dirs, dir)
+		dir = filepath.Dir(dir)
+	}
+	return dirs
+}
+
+func (g *nativeGamepadImpl) batteryLevel() (int, bool) {
+	if g.batteryLevel_ < 0 {
+		return 0, false
+	}
+	return g.batteryLevel_, true
+}
+
+func (g *nativeGamepadImpl) powerState() PowerState {
+	return g.powerState_
+}
+
+func (g *nativeGamepadImpl) setPlayerIndex(index int) {
+	// The LEDs might be registered later than the input device. Retry to find them until they are found.
+	if len(g.playerLEDPaths) == 0 {
+		g.playerLEDPaths = findPlayerLEDPaths(g.path)
+		if len(g.playerLEDPaths) == 0 {
+			return
+		}
+	}
+
+	for i, p := range g.playerLEDPaths {
+		v := []byte("0")
+		if index >= 0 && i == index%len(g.playerLEDPaths) {
+			v = []byte("1")
+			if bs, err := os.ReadFile(filepath.Join(p, "max_brightness")); err == nil {
+				v = bytes.TrimSpace(bs)
+			}
+		}
+		// Writing the brightness requires the write permission, which is not granted by default.
+		// Ignore the error as the LEDs are not essential.
+		_ = os.WriteFile(filepath.Join(p, "brightness"), v, 0)
+	}
+}
+
+func (g *nativeGamepadImpl) hasMotionSensor() bool {
+	return g.motion != nil
+}
+
+func (g *nativeGamepadImpl) motionSensorValue(axis MotionSensorAxis) float64 {
+	if g.motion == nil {
+		return 0
+	}
+	return g.motion.values[axis]
+}
+
+// setExclusiveGrab grabs or releases the device exclusively.
+// While the device is grabbed, its events are not delivered to the other clients like the compositor.
+// The grab is released automatically when the device is closed.
+func (g *nativeGamepadImpl) setExclusiveGrab(grab bool) {
+	if g.grabbed == grab {
+		return
+	}
+	// joydev doesn't support grabbing.
+	if g.fd == 0 || g.joydev {
+		return
+	}
+	v := 0
+	if grab {
+		v = 1
+	}
+	// Grabbing fails with EBUSY when another client grabs the device. Don't retry in every update in this case.
+	_ = unix.IoctlSetInt(g.fd, _EVIOCGRAB(), v)
+	g.grabbed = grab
+}
+
+func (g *nativeGamepadImpl) busType() BusType {
+	switch g.bustype {
+	case _BUS_USB:
+		return BusTypeUSB
+	case _BUS_BLUETOOTH:
+		return BusTypeBluetooth
+	case _BUS_VIRTUAL:
+		return BusTypeVirtual
+	}
+	return BusTypeUnknown
+}
+
+func (g *nativeGamepadImpl) hasTouchpad() bool {
+	return g.touchpad != nil
+}
+
+func (g *nativeGamepadImpl) isTouchpadPressed() bool {
+	if g.touchpad == nil {
+		return false
+	}
+	return g.touchpad.pressed
+}
+
+func (g *nativeGamepadImpl) touchpadTouchPosition(index int) (float64, float64, bool) {
+	if g.touchpad == nil {
+		return 0, 0, false
+	}
+	return g.touchpad.touchPosition(index)
+}
+
+func (g *nativeGamepadImpl) buttonNativeCode(button int) (int, string, bool) {
+	if button < 0 || button >= g.buttonCount_ {
+		return 0, "", false
+	}
+	for i, b := range g.keyMap {
+		if b == button {
+			code := _BTN_MISC + i
+			return code, evdevButtonLabel(code), true
+		}
+	}
+	return 0, "", false
+}
+
+func (g *nativeGamepadImpl) isTriggerAxis(axis int) bool {
+	code, _, ok := g.axisNativeCode(axis)
+	if !ok {
+		return false
+	}
+	switch code {
+	case _ABS_GAS, _ABS_BRAKE:
+		return true
+	case _ABS_Z, _ABS_RZ:
+		// Generic HID gamepads might report the right stick as ABS_Z and ABS_RZ.
+		// Trust them as triggers only when the kernel module follows the gamepad specification.
+		// See https://www.kernel.org/doc/html/latest/input/gamepad.html
+		if g.keyMap[_BTN_GAMEPAD-_BTN_MISC] < 0 {
+			return false
+		}
+		return g.absInfo[code].minimum == 0
+	}
+	return false
+}
+
+func (g *nativeGamepadImpl) axisNativeCode(axis int) (int, string, bool) {
+	if axis < 0 || axis >= g.axisCount_ {
+		return 0, "", false
+	}
+	for code, a := range g.absMap {
+		// absMap has hat indices for the hat codes.
+		if code >= _ABS_HAT0X && code <= _ABS_HAT3Y {
+			continue
+		}
+		if a == axis {
+			return code, evdevAxisLabel(code), true
+		}
+	}
+	return 0, "", false
+}
+
+func (g *nativeGamepadImpl) serial() string {
+	return g.serial_
+}
+
 func (g *nativeGamepadImpl) hasOwnStandardLayoutMapping() bool {
 	return len(g.stdAxisMap) != 0 || len(g.stdButtonMap) != 0
 }
@@ -580,6 +2915,21 @@ func (g *nativeGamepadImpl) standardButtonInOwnMapping(button gamepaddb.Standard
 	return g.stdButtonMap[button]
 }
 
+// triggerButton returns the standard button of the trigger if the button is BTN_TL2 or BTN_TR2.
+// The analog value of the trigger is reported by an axis, which is resolved through the standard layout mapping.
+func (g *nativeGamepadImpl) triggerButton(button int) (gamepaddb.StandardButton, bool) {
+	if button < 0 || button >= g.buttonCount_ {
+		return 0, false
+	}
+	switch button {
+	case g.keyMap[_BTN_TL2-_BTN_MISC]:
+		return gamepaddb.StandardButtonFrontBottomLeft, true
+	case g.keyMap[_BTN_TR2-_BTN_MISC]:
+		return gamepaddb.StandardButtonFrontBottomRight, true
+	}
+	return 0, false
+}
+
 func (g *nativeGamepadImpl) axisCount() int {
 	return g.axisCount_
 }
@@ -596,6 +2946,9 @@ func (g *nativeGamepadImpl) axisValue(axis int) float64 {
 	if axis < 0 || axis >= g.axisCount_ {
 		return 0
 	}
+	if g.axisDeadZoneDisabled {
+		return g.rawAxes[axis]
+	}
 	return g.axes[axis]
 }
 
@@ -607,6 +2960,9 @@ func (g *nativeGamepadImpl) isButtonPressed(button int) bool {
 }
 
 func (g *nativeGamepadImpl) buttonValue(button int) float64 {
+	if button < 0 || button >= g.buttonCount_ {
+		return 0
+	}
 	if g.isButtonPressed(button) {
 		return 1
 	}
@@ -621,5 +2977,260 @@ func (g *nativeGamepadImpl) hatState(hat int) int {
 }
 
 func (g *nativeGamepadImpl) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
-	// TODO: Implement this (#1452)
+	if g.fd == 0 || !g.rumble {
+		return
+	}
+
+	if duration <= 0 || (strongMagnitude <= 0 && weakMagnitude <= 0) {
+		g.removeFFEffect()
+		return
+	}
+
+	// The length is in milliseconds and 0 means an infinite effect.
+	length := duration.Milliseconds()
+	if length < 1 {
+		length = 1
+	}
+	if length > 0xffff {
+		length = 0xffff
+	}
+
+	// Update the current effect in place if there is one, so that calling vibrate every frame doesn't exhaust the effect slots.
+	// The ID -1 requests the kernel to allocate a new effect.
+	e := ff_effect{
+		typ: _FF_RUMBLE,
+		id:  g.ffEffectID,
+		replay: ff_replay{
+			length: uint16(length),
+		},
+	}
+	if !g.ffGain {
+		gain := 1 - g.vibrationAttenuation
+		strongMagnitude *= gain
+		weakMagnitude *= gain
+	}
+	r := (*ff_rumble_effect)(unsafe.Pointer(&e.u))
+	r.strong_magnitude = toFFMagnitude(strongMagnitude)
+	r.weak_magnitude = toFFMagnitude(weakMagnitude)
+
+	if err := g.ff.upload(g.fd, &e); err != nil {
+		if g.ffEffectID < 0 {
+			return
+		}
+		// The effect might have been removed by the device. Upload it as a new effect.
+		g.removeFFEffect()
+		e.id = -1
+		if err := g.ff.upload(g.fd, &e); err != nil {
+			return
+		}
+	}
+	// The kernel writes the allocated ID back.
+	if e.id < 0 {
+		return
+	}
+	g.ffEffectID = e.id
+	g.ffEffectEnd = time.Now().Add(time.Duration(length) * time.Millisecond)
+
+	// Playing the effect again restarts it with the new length.
+	if err := g.ff.play(g.fd, e.id, true); err != nil {
+		g.removeFFEffect()
+		return
+	}
+}
+
+// setVibrationGain sets the gain of vibrations in [0, 1].
+// If the device supports FF_GAIN, the gain is written to the device and attenuates all the effects including the playing one.
+func (g *nativeGamepadImpl) setVibrationGain(gain float64) {
+	g.vibrationAttenuation = 1 - gain
+
+	if g.fd == 0 || !g.rumble || !g.ffGain {
+		return
+	}
+
+	v := toFFMagnitude(gain)
+	if g.ffGainWritten && g.ffGainValue == v {
+		return
+	}
+	if err := g.ff.setGain(g.fd, v); err != nil {
+		return
+	}
+	g.ffGainWritten = true
+	g.ffGainValue = v
+}
+
+func (g *nativeGamepadImpl) removeFFEffect() {
+	if g.ffEffectID < 0 {
+		return
+	}
+	// Stop the effect explicitly, though removing an effect also stops it on most devices.
+	// The errors are ignored as the device might be already disconnected.
+	_ = g.ff.play(g.fd, g.ffEffectID, false)
+	_ = g.ff.remove(g.fd, g.ffEffectID)
+	g.ffEffectID = -1
+}
+
+// forceFeedback operates force feedback effects of an evdev device.
+type forceFeedback interface {
+	// upload uploads the effect. If e.id is -1, a new effect is allocated and its ID is written back to e.id.
+	// Otherwise, the existing effect with the ID is updated.
+	upload(fd int, e *ff_effect) error
+
+	// remove removes the effect with the ID.
+	remove(fd int, id int16) error
+
+	// play starts or stops the effect with the ID.
+	play(fd int, id int16, play bool) error
+
+	// setGain sets the gain of all the effects. 0xffff is the full gain.
+	setGain(fd int, gain uint16) error
+}
+
+type evdevForceFeedback struct{}
+
+func (evdevForceFeedback) upload(fd int, e *ff_effect) error {
+	// The error is an errno, which indicates e.g. the effect slots are exhausted.
+	if err := ioctl(fd, _EVIOCSFF(), unsafe.Pointer(e)); err != nil {
+		return fmt.Errorf("gamepad: ioctl for uploading an effect failed: %w", err)
+	}
+	return nil
+}
+
+func (evdevForceFeedback) remove(fd int, id int16) error {
+	if err := unix.IoctlSetInt(fd, _EVIOCRMFF(), int(id)); err != nil {
+		return fmt.Errorf("gamepad: ioctl for removing an effect failed: %w", err)
+	}
+	return nil
+}
+
+func (evdevForceFeedback) play(fd int, id int16, play bool) error {
+	e := input_event{
+		typ:  unix.EV_FF,
+		code: uint16(id),
+	}
+	if play {
+		e.value = 1
+	}
+	if _, err := unix.Write(fd, (*[unsafe.Sizeof(input_event{})]byte)(unsafe.Pointer(&e))[:]); err != nil {
+		return fmt.Errorf("gamepad: Write failed: %w", err)
+	}
+	return nil
+}
+
+func (evdevForceFeedback) setGain(fd int, gain uint16) error {
+	e := input_event{
+		typ:   unix.EV_FF,
+		code:  _FF_GAIN,
+		value: int32(gain),
+	}
+	if _, err := unix.Write(fd, (*[unsafe.Sizeof(input_event{})]byte)(unsafe.Pointer(&e))[:]); err != nil {
+		return fmt.Errorf("gamepad: Write failed: %w", err)
+	}
+	return nil
+}
+
+func toFFMagnitude(v float64) uint16 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 0xffff
+	}
+	return uint16(v * 0xffff)
+}
+
+// evdevButtonLabel returns a human-readable label of the evdev key code for a button.
+func evdevButtonLabel(code int) string {
+	switch {
+	case code >= _BTN_0 && code <= _BTN_9:
+		return fmt.Sprintf("Button %d", code-_BTN_0)
+	case code >= _BTN_BASE2 && code <= _BTN_BASE6:
+		return fmt.Sprintf("Base %d", code-_BTN_BASE2+2)
+	case code >= _BTN_TRIGGER_HAPPY1 && code <= _BTN_TRIGGER_HAPPY40:
+		return fmt.Sprintf("Extra Button %d", code-_BTN_TRIGGER_HAPPY1+1)
+	}
+
+	switch code {
+	case _BTN_TRIGGER:
+		return "Trigger"
+	case _BTN_THUMB:
+		return "Thumb"
+	case _BTN_THUMB2:
+		return "Thumb 2"
+	case _BTN_TOP:
+		return "Top"
+	case _BTN_TOP2:
+		return "Top 2"
+	case _BTN_PINKIE:
+		return "Pinkie"
+	case _BTN_BASE:
+		return "Base"
+	case _BTN_SOUTH:
+		return "South"
+	case _BTN_EAST:
+		return "East"
+	case _BTN_C:
+		return "C"
+	case _BTN_NORTH:
+		return "North"
+	case _BTN_WEST:
+		return "West"
+	case _BTN_Z:
+		return "Z"
+	case _BTN_TL:
+		return "Left Shoulder"
+	case _BTN_TR:
+		return "Right Shoulder"
+	case _BTN_TL2:
+		return "Left Trigger"
+	case _BTN_TR2:
+		return "Right Trigger"
+	case _BTN_SELECT:
+		return "Select"
+	case _BTN_START:
+		return "Start"
+	case _BTN_MODE:
+		return "Mode"
+	case _BTN_THUMBL:
+		return "Left Stick"
+	case _BTN_THUMBR:
+		return "Right Stick"
+	case _BTN_DPAD_UP:
+		return "D-pad Up"
+	case _BTN_DPAD_DOWN:
+		return "D-pad Down"
+	case _BTN_DPAD_LEFT:
+		return "D-pad Left"
+	case _BTN_DPAD_RIGHT:
+		return "D-pad Right"
+	}
+	return fmt.Sprintf("Button 0x%x", code)
+}
+
+// evdevAxisLabel returns a human-readable label of the evdev absolute axis code.
+func evdevAxisLabel(code int) string {
+	switch code {
+	case _ABS_X:
+		return "X"
+	case _ABS_Y:
+		return "Y"
+	case _ABS_Z:
+		return "Z"
+	case _ABS_RX:
+		return "X Rotation"
+	case _ABS_RY:
+		return "Y Rotation"
+	case _ABS_RZ:
+		return "Z Rotation"
+	case _ABS_THROTTLE:
+		return "Throttle"
+	case _ABS_RUDDER:
+		return "Rudder"
+	case _ABS_WHEEL:
+		return "Wheel"
+	case _ABS_GAS:
+		return "Gas"
+	case _ABS_BRAKE:
+		return "Brake"
+	}
+	return fmt.Sprintf("Axis 0x%x", code)
 }
@@ -0,0 +1,2300 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !android && !nintendosdk && !playstation5
+
+package gamepad_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepad"
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
+)
+
+func TestNormalizeAbsValue(t *testing.T) {
+	cases := []struct {
+		Value    int32
+		Min      int32
+		Max      int32
+		Flat     int32
+		DeadZone bool
+		Want     float64
+	}{
+		// Symmetric range without a dead zone.
+		{Value: -32768, Min: -32768, Max: 32767, Flat: 0, DeadZone: true, Want: -1},
+		{Value: 32767, Min: -32768, Max: 32767, Flat: 0, DeadZone: true, Want: 1},
+
+		// Symmetric range with a dead zone.
+		{Value: 100, Min: -32768, Max: 32767, Flat: 128, DeadZone: true, Want: 0},
+		{Value: -128, Min: -32768, Max: 32767, Flat: 128, DeadZone: true, Want: 0},
+		{Value: 32767, Min: -32768, Max: 32767, Flat: 128, DeadZone: true, Want: 1},
+		{Value: -32768, Min: -32768, Max: 32767, Flat: 128, DeadZone: true, Want: -1},
+
+		// Asymmetric range. The center is 127.5.
+		{Value: 0, Min: 0, Max: 255, Flat: 15, DeadZone: true, Want: -1},
+		{Value: 255, Min: 0, Max: 255, Flat: 15, DeadZone: true, Want: 1},
+		{Value: 128, Min: 0, Max: 255, Flat: 15, DeadZone: true, Want: 0},
+		{Value: 140, Min: 0, Max: 255, Flat: 15, DeadZone: true, Want: 0},
+		{Value: 115, Min: 0, Max: 255, Flat: 15, DeadZone: true, Want: 0},
+		{Value: 191, Min: 0, Max: 255, Flat: 15, DeadZone: true, Want: (191 - 142.5) / (255 - 142.5)},
+		{Value: 64, Min: 0, Max: 255, Flat: 15, DeadZone: true, Want: -(112.5 - 64) / 112.5},
+
+		// Asymmetric range whose center is not zero.
+		{Value: 1000, Min: 1000, Max: 3000, Flat: 100, DeadZone: true, Want: -1},
+		{Value: 2050, Min: 1000, Max: 3000, Flat: 100, DeadZone: true, Want: 0},
+		{Value: 2550, Min: 1000, Max: 3000, Flat: 100, DeadZone: true, Want: 0.5},
+
+		// Raw values.
+		{Value: 128, Min: 0, Max: 255, Flat: 15, DeadZone: false, Want: 1.0 / 255},
+		{Value: 0, Min: 0, Max: 255, Flat: 15, DeadZone: false, Want: -1},
+
+		// Values out of the range are clamped.
+		{Value: 300, Min: 0, Max: 255, Flat: 0, DeadZone: true, Want: 1},
+		{Value: -10, Min: 0, Max: 255, Flat: 15, DeadZone: true, Want: -1},
+		{Value: -10, Min: 0, Max: 255, Flat: 15, DeadZone: false, Want: -1},
+
+		// An empty range is always 0.
+		{Value: 127, Min: 0, Max: 0, Flat: 0, DeadZone: true, Want: 0},
+		{Value: 4096, Min: 4096, Max: 4096, Flat: 0, DeadZone: false, Want: 0},
+
+		// An inverted range.
+		{Value: 255, Min: 255, Max: 0, Flat: 0, DeadZone: true, Want: -1},
+		{Value: 0, Min: 255, Max: 0, Flat: 0, DeadZone: true, Want: 1},
+		{Value: 64, Min: 255, Max: 0, Flat: 0, DeadZone: false, Want: (127.5 - 64) / 127.5},
+		{Value: 130, Min: 255, Max: 0, Flat: 15, DeadZone: true, Want: 0},
+
+		// 1-bit ranges.
+		{Value: 0, Min: 0, Max: 1, Flat: 0, DeadZone: true, Want: -1},
+		{Value: 1, Min: 0, Max: 1, Flat: 0, DeadZone: true, Want: 1},
+		{Value: 0, Min: -1, Max: 0, Flat: 0, DeadZone: false, Want: 1},
+	}
+	for _, c := range cases {
+		got := gamepad.NormalizeAbsValueForTesting(c.Value, c.Min, c.Max, c.Flat, c.DeadZone)
+		if math.Abs(got-c.Want) > 1e-9 {
+			t.Errorf("normalizeAbsValue(%d, min: %d, max: %d, flat: %d, deadZone: %t): got: %f, want: %f", c.Value, c.Min, c.Max, c.Flat, c.DeadZone, got, c.Want)
+		}
+	}
+}
+
+func TestDefuzzAbsValue(t *testing.T) {
+	cases := []struct {
+		Value int32
+		Old   int32
+		Fuzz  int32
+		Want  int32
+	}{
+		{Value: 105, Old: 100, Fuzz: 0, Want: 105},
+		{Value: 103, Old: 100, Fuzz: 8, Want: 100},
+		{Value: 97, Old: 100, Fuzz: 8, Want: 100},
+		{Value: 106, Old: 100, Fuzz: 8, Want: 101},
+		{Value: 112, Old: 100, Fuzz: 8, Want: 106},
+		{Value: 120, Old: 100, Fuzz: 8, Want: 120},
+	}
+	for _, c := range cases {
+		if got := gamepad.DefuzzAbsValueForTesting(c.Value, c.Old, c.Fuzz); got != c.Want {
+			t.Errorf("defuzzAbsValue(%d, %d, %d): got: %d, want: %d", c.Value, c.Old, c.Fuzz, got, c.Want)
+		}
+	}
+}
+
+func TestIsGamepadDevice(t *testing.T) {
+	const (
+		evKey = 0x01
+		evAbs = 0x03
+
+		absX     = 0x00
+		absY     = 0x01
+		absZ     = 0x02
+		absHat0X = 0x10
+		absHat0Y = 0x11
+
+		btnLeft           = 0x110
+		btnTrigger        = 0x120
+		btnSouth          = 0x130
+		btnToolPen        = 0x140
+		btnTouch          = 0x14a
+		btnTriggerHappy1  = 0x2c0
+		btnTriggerHappy40 = 0x2e7
+	)
+
+	bits := func(size int, codes ...int) []byte {
+		bs := make([]byte, (size+7)/8)
+		for _, c := range codes {
+			bs[c/8] |= 1 << (c % 8)
+		}
+		return bs
+	}
+	evBits := func(codes ...int) []byte {
+		return bits(0x20, codes...)
+	}
+	keyBits := func(codes ...int) []byte {
+		return bits(0x300, codes...)
+	}
+	absBits := func(codes ...int) []byte {
+		return bits(0x40, codes...)
+	}
+	keyboardKeys := func(codes ...int) []int {
+		// KEY_ESC to KEY_S.
+		for c := 1; c <= 31; c++ {
+			codes = append(codes, c)
+		}
+		return codes
+	}
+
+	cases := []struct {
+		Name    string
+		EvBits  []byte
+		KeyBits []byte
+		AbsBits []byte
+		Want    bool
+	}{
+		{
+			Name:    "gamepad",
+			EvBits:  evBits(evKey, evAbs),
+			KeyBits: keyBits(btnSouth),
+			AbsBits: absBits(absX, absY),
+			Want:    true,
+		},
+		{
+			Name:    "joystick",
+			EvBits:  evBits(evKey, evAbs),
+			KeyBits: keyBits(btnTrigger),
+			AbsBits: absBits(absX, absY, absZ),
+			Want:    true,
+		},
+		{
+			Name:    "gamepad with only a hat",
+			EvBits:  evBits(evKey, evAbs),
+			KeyBits: keyBits(btnSouth),
+			AbsBits: absBits(absHat0X, absHat0Y),
+			Want:    true,
+		},
+		{
+			Name:    "only BTN_TRIGGER_HAPPY buttons",
+			EvBits:  evBits(evKey, evAbs),
+			KeyBits: keyBits(btnTriggerHappy1, btnTriggerHappy40),
+			AbsBits: absBits(absX, absY),
+			Want:    true,
+		},
+		{
+			Name:    "no EV_ABS",
+			EvBits:  evBits(evKey),
+			KeyBits: keyBits(btnSouth),
+			AbsBits: absBits(),
+			Want:    false,
+		},
+		{
+			Name:    "no EV_KEY (accelerometer)",
+			EvBits:  evBits(evAbs),
+			KeyBits: keyBits(),
+			AbsBits: absBits(absX, absY, absZ),
+			Want:    false,
+		},
+		{
+			Name:    "no gamepad buttons",
+			EvBits:  evBits(evKey, evAbs),
+			KeyBits: keyBits(btnLeft),
+			AbsBits: absBits(absX, absY),
+			Want:    false,
+		},
+		{
+			Name:    "only one stick axis",
+			EvBits:  evBits(evKey, evAbs),
+			KeyBits: keyBits(btnSouth),
+			AbsBits: absBits(absX, absZ),
+			Want:    false,
+		},
+		{
+			Name:    "touchpad",
+			EvBits:  evBits(evKey, evAbs),
+			KeyBits: keyBits(btnLeft, btnTouch),
+			AbsBits: absBits(absX, absY),
+			Want:    false,
+		},
+		{
+			Name:    "tablet",
+			EvBits:  evBits(evKey, evAbs),
+			KeyBits: keyBits(btnToolPen, btnTouch),
+			AbsBits: absBits(absX, absY),
+			Want:    false,
+		},
+		{
+			Name:    "keyboard with gamepad-like keys",
+			EvBits:  evBits(evKey, evAbs),
+			KeyBits: keyBits(keyboardKeys(btnSouth)...),
+			AbsBits: absBits(absX, absY),
+			Want:    false,
+		},
+	}
+	for _, c := range cases {
+		if got := gamepad.IsGamepadDeviceForTesting(c.EvBits, c.KeyBits, c.AbsBits); got != c.Want {
+			t.Errorf("%s: got: %t, want: %t", c.Name, got, c.Want)
+		}
+	}
+}
+
+func newEventPipe(tb testing.TB) (r, w int) {
+	var fds [2]int
+	if err := unix.Pipe2(fds[:], unix.O_NONBLOCK|unix.O_CLOEXEC); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		_ = unix.Close(fds[0])
+		_ = unix.Close(fds[1])
+	})
+	return fds[0], fds[1]
+}
+
+func syntheticEvents() []byte {
+	const (
+		evSyn = 0x00
+		evKey = 0x01
+		evAbs = 0x03
+
+		synReport = 0x00
+		absX      = 0x00
+		btnA      = 0x130
+	)
+
+	var buf []byte
+	for i := 0; i < 100; i++ {
+		buf = gamepad.AppendInputEventForTesting(buf, evAbs, absX, int32(i*100))
+		buf = gamepad.AppendInputEventForTesting(buf, evKey, btnA, int32(i%2))
+		buf = gamepad.AppendInputEventForTesting(buf, evSyn, synReport, 0)
+	}
+	buf = gamepad.AppendInputEventForTesting(buf, evAbs, absX, 32767)
+	buf = gamepad.AppendInputEventForTesting(buf, evKey, btnA, 1)
+	buf = gamepad.AppendInputEventForTesting(buf, evSyn, synReport, 0)
+	return buf
+}
+
+func TestNativeGamepadUpdate(t *testing.T) {
+	r, w := newEventPipe(t)
+	g := gamepad.NewNativeGamepadForTesting(r)
+
+	events := syntheticEvents()
+	if _, err := unix.Write(w, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.Button(), true; got != want {
+		t.Errorf("Button(): got: %t, want: %t", got, want)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := unix.Write(w, events); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("allocs: got: %f, want: 0", allocs)
+	}
+}
+
+func TestHats(t *testing.T) {
+	const (
+		evAbs = 0x03
+
+		absX     = 0x00
+		absY     = 0x01
+		absHat0Y = 0x11
+		absHat1X = 0x12
+		absHat1Y = 0x13
+		absHat2X = 0x14
+		absHat3X = 0x16
+		absHat3Y = 0x17
+
+		hatUp    = 1
+		hatRight = 2
+		hatDown  = 4
+		hatLeft  = 8
+	)
+
+	absBits := func(codes ...int) []byte {
+		bs := make([]byte, 0x40/8)
+		for _, c := range codes {
+			bs[c/8] |= 1 << (c % 8)
+		}
+		return bs
+	}
+
+	type event struct {
+		Code  uint16
+		Value int32
+	}
+	cases := []struct {
+		Name      string
+		AbsBits   []byte
+		AxisCount int
+		HatCount  int
+		Events    []event
+		Hats      []int
+	}{
+		{
+			Name:      "four hats",
+			AbsBits:   absBits(absX, absY, absHat0Y, absHat1X, absHat1Y, absHat2X, absHat3X, absHat3Y),
+			AxisCount: 2,
+			HatCount:  4,
+			Events: []event{
+				{Code: absHat1X, Value: -1},
+				{Code: absHat1Y, Value: 1},
+				{Code: absHat3Y, Value: -1},
+			},
+			Hats: []int{0, hatDown | hatLeft, 0, hatUp},
+		},
+		{
+			Name:      "sparse hats",
+			AbsBits:   absBits(absHat0Y, absHat2X),
+			AxisCount: 0,
+			HatCount:  2,
+			Events: []event{
+				{Code: absHat0Y, Value: -1},
+				{Code: absHat2X, Value: 1},
+			},
+			Hats: []int{hatUp, hatRight},
+		},
+	}
+	for _, c := range cases {
+		r, w := newEventPipe(t)
+		g := gamepad.NewNativeGamepadWithBitsForTesting(r, nil, c.AbsBits)
+		if got := g.AxisCount(); got != c.AxisCount {
+			t.Errorf("%s: AxisCount(): got: %d, want: %d", c.Name, got, c.AxisCount)
+		}
+		if got := g.HatCount(); got != c.HatCount {
+			t.Fatalf("%s: HatCount(): got: %d, want: %d", c.Name, got, c.HatCount)
+		}
+
+		// SYN_REPORT is not sent, as polling the absolute state at SYN_REPORT doesn't work with a pipe.
+		var events []byte
+		for _, e := range c.Events {
+			events = gamepad.AppendInputEventForTesting(events, evAbs, e.Code, e.Value)
+		}
+		if _, err := unix.Write(w, events); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range c.Hats {
+			if got := g.HatState(i); got != want {
+				t.Errorf("%s: HatState(%d): got: %d, want: %d", c.Name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestTriggerHappyButtons(t *testing.T) {
+	const (
+		evSyn = 0x00
+		evKey = 0x01
+
+		synReport = 0x00
+
+		btnTriggerHappy1  = 0x2c0
+		btnTriggerHappy2  = 0x2c1
+		btnTriggerHappy40 = 0x2e7
+	)
+
+	keyBits := make([]byte, 0x300/8)
+	for _, code := range []int{btnTriggerHappy1, btnTriggerHappy2, btnTriggerHappy40} {
+		keyBits[code/8] |= 1 << (code % 8)
+	}
+
+	r, w := newEventPipe(t)
+	g := gamepad.NewNativeGamepadWithBitsForTesting(r, keyBits, nil)
+	if got, want := g.ButtonCount(), 3; got != want {
+		t.Fatalf("ButtonCount(): got: %d, want: %d", got, want)
+	}
+
+	var events []byte
+	events = gamepad.AppendInputEventForTesting(events, evKey, btnTriggerHappy2, 1)
+	events = gamepad.AppendInputEventForTesting(events, evKey, btnTriggerHappy40, 1)
+	// Codes out of the button range must be ignored.
+	events = gamepad.AppendInputEventForTesting(events, evKey, 0x300, 1)
+	events = gamepad.AppendInputEventForTesting(events, evKey, 0x01, 1)
+	events = gamepad.AppendInputEventForTesting(events, evSyn, synReport, 0)
+	if _, err := unix.Write(w, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []bool{false, true, true} {
+		if got := g.IsButtonPressed(i); got != want {
+			t.Errorf("IsButtonPressed(%d): got: %t, want: %t", i, got, want)
+		}
+	}
+}
+
+func TestButtonNativeCode(t *testing.T) {
+	const (
+		btnSouth         = 0x130
+		btnDpadUp        = 0x220
+		btnTriggerHappy3 = 0x2c2
+	)
+
+	keyBits := make([]byte, 0x300/8)
+	for _, code := range []int{btnSouth, btnDpadUp, btnTriggerHappy3} {
+		keyBits[code/8] |= 1 << (code % 8)
+	}
+	g := gamepad.NewNativeGamepadWithBitsForTesting(0, keyBits, nil)
+
+	cases := []struct {
+		Button int
+		Code   int
+		Label  string
+		OK     bool
+	}{
+		{
+			Button: 0,
+			Code:   btnSouth,
+			Label:  "South",
+			OK:     true,
+		},
+		{
+			Button: 1,
+			Code:   btnDpadUp,
+			Label:  "D-pad Up",
+			OK:     true,
+		},
+		{
+			Button: 2,
+			Code:   btnTriggerHappy3,
+			Label:  "Extra Button 3",
+			OK:     true,
+		},
+		{
+			Button: 3,
+		},
+	}
+	for _, c := range cases {
+		code, label, ok := g.ButtonNativeCode(c.Button)
+		if code != c.Code || label != c.Label || ok != c.OK {
+			t.Errorf("ButtonNativeCode(%d): got: (0x%x, %q, %t), want: (0x%x, %q, %t)", c.Button, code, label, ok, c.Code, c.Label, c.OK)
+		}
+	}
+}
+
+func TestSynDropped(t *testing.T) {
+	const (
+		evSyn = 0x00
+		evKey = 0x01
+
+		synReport  = 0x00
+		synDropped = 0x03
+		btnA       = 0x130
+	)
+
+	r, w := newEventPipe(t)
+	g := gamepad.NewNativeGamepadForTesting(r)
+	// The device reports that no key is pressed.
+	g.SetKeyStateForTesting(make([]byte, 0x300/8))
+
+	var events []byte
+	events = gamepad.AppendInputEventForTesting(events, evKey, btnA, 1)
+	events = gamepad.AppendInputEventForTesting(events, evSyn, synReport, 0)
+	if _, err := unix.Write(w, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.Button(), true; got != want {
+		t.Errorf("Button() before dropping: got: %t, want: %t", got, want)
+	}
+
+	// The release event is dropped.
+	events = events[:0]
+	events = gamepad.AppendInputEventForTesting(events, evSyn, synDropped, 0)
+	events = gamepad.AppendInputEventForTesting(events, evSyn, synReport, 0)
+	if _, err := unix.Write(w, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.Button(), false; got != want {
+		t.Errorf("Button() after dropping: got: %t, want: %t", got, want)
+	}
+}
+
+func TestOpenEvdevDevice(t *testing.T) {
+	const (
+		absX    = 0x00
+		absY    = 0x01
+		absZ    = 0x02
+		absHat0 = 0x10
+		absHat1 = 0x11
+
+		btnTrigger = 0x120
+		btnThumb   = 0x121
+		btnA       = 0x130
+		btnB       = 0x131
+		btnX       = 0x133
+		btnY       = 0x134
+
+		inputPropPointingStick = 0x05
+	)
+
+	stick := newAbsInfo(-32768, 32767)
+	keyboardKeys := make([]int, 0, 31)
+	for code := 1; code <= 31; code++ {
+		keyboardKeys = append(keyboardKeys, code)
+	}
+
+	testCases := []struct {
+		Name        string
+		Device      gamepad.EvdevDeviceForTesting
+		WantGamepad bool
+		WantName    string
+		WantSDLID   string
+		WantAxes    int
+		WantButtons int
+		WantHats    int
+	}{
+		{
+			Name: "gamepad",
+			Device: gamepad.EvdevDeviceForTesting{
+				Name:    "Gamepad",
+				BusType: 0x03,
+				Vendor:  0x1209,
+				Product: 0x0001,
+				Version: 0x0100,
+				Keys:    []int{btnA, btnB, btnX, btnY},
+				Axes: map[int]gamepad.AbsInfoForTesting{
+					absX:    stick,
+					absY:    stick,
+					absHat0: newAbsInfo(-1, 1),
+					absHat1: newAbsInfo(-1, 1),
+				},
+			},
+			WantGamepad: true,
+			WantName:    "Gamepad",
+			WantSDLID:   "0300a5d0091200000100000000010000",
+			WantAxes:    2,
+			WantButtons: 4,
+			WantHats:    1,
+		},
+		{
+			Name: "joystick without a version",
+			Device: gamepad.EvdevDeviceForTesting{
+				Name:    "Joystick",
+				BusType: 0x03,
+				Vendor:  0x1234,
+				Product: 0x5678,
+				Keys:    []int{btnTrigger, btnThumb},
+				Axes: map[int]gamepad.AbsInfoForTesting{
+					absX: stick,
+					absY: stick,
+					absZ: newAbsInfo(0, 255),
+				},
+			},
+			WantGamepad: true,
+			WantName:    "Joystick",
+			WantSDLID:   "0300b7d8341200007856000000000000",
+			WantAxes:    3,
+			WantButtons: 2,
+		},
+		{
+			Name: "keyboard",
+			Device: gamepad.EvdevDeviceForTesting{
+				Name: "Keyboard",
+				Keys: append(keyboardKeys, btnA),
+				Axes: map[int]gamepad.AbsInfoForTesting{
+					absX: stick,
+				},
+			},
+		},
+		{
+			Name: "no axes",
+			Device: gamepad.EvdevDeviceForTesting{
+				Name: "Buttons",
+				Keys: []int{btnA, btnB},
+			},
+		},
+		{
+			Name: "pointing stick",
+			Device: gamepad.EvdevDeviceForTesting{
+				Name:  "Pointing Stick",
+				Props: []int{inputPropPointingStick},
+				Keys:  []int{btnA},
+				Axes: map[int]gamepad.AbsInfoForTesting{
+					absX: stick,
+					absY: stick,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			g := gamepad.NewEvdevGamepadsForTesting()
+			dev := tc.Device
+			if err := g.Open("/dev/input/event-test", &dev); err != nil {
+				t.Fatal(err)
+			}
+			gps := g.Gamepads()
+			if !tc.WantGamepad {
+				if len(gps) != 0 {
+					t.Errorf("len(Gamepads()): got: %d, want: 0", len(gps))
+				}
+				if !dev.Closed {
+					t.Errorf("the device must be closed")
+				}
+				return
+			}
+			if len(gps) != 1 {
+				t.Fatalf("len(Gamepads()): got: %d, want: 1", len(gps))
+			}
+			if dev.Closed {
+				t.Errorf("the device must not be closed")
+			}
+			gp := gps[0]
+			if got := gp.Name(); got != tc.WantName {
+				t.Errorf("Name(): got: %s, want: %s", got, tc.WantName)
+			}
+			if got := gp.SDLID(); got != tc.WantSDLID {
+				t.Errorf("SDLID(): got: %s, want: %s", got, tc.WantSDLID)
+			}
+			if got := gp.AxisCount(); got != tc.WantAxes {
+				t.Errorf("AxisCount(): got: %d, want: %d", got, tc.WantAxes)
+			}
+			if got := gp.ButtonCount(); got != tc.WantButtons {
+				t.Errorf("ButtonCount(): got: %d, want: %d", got, tc.WantButtons)
+			}
+			if got := gp.HatCount(); got != tc.WantHats {
+				t.Errorf("HatCount(): got: %d, want: %d", got, tc.WantHats)
+			}
+		})
+	}
+}
+
+func TestEvdevEventStream(t *testing.T) {
+	const (
+		evSyn = 0x00
+		evKey = 0x01
+		evAbs = 0x03
+
+		synReport  = 0x00
+		synDropped = 0x03
+
+		absX    = 0x00
+		absHat0 = 0x10
+		absHat1 = 0x11
+
+		btnA = 0x130
+		btnB = 0x131
+
+		hatUp = 1
+	)
+
+	dev := &gamepad.EvdevDeviceForTesting{
+		Name:    "Gamepad",
+		BusType: 0x03,
+		Vendor:  0x045e,
+		Product: 0x028e,
+		Version: 0x0114,
+		Keys:    []int{btnA, btnB},
+		Axes: map[int]gamepad.AbsInfoForTesting{
+			absX:    newAbsInfo(-32768, 32767),
+			absHat0: newAbsInfo(-1, 1),
+			absHat1: newAbsInfo(-1, 1),
+		},
+	}
+	// setAbs emulates the kernel updating the state of the absolute axis with an event.
+	setAbs := func(code int, value int32) {
+		info := dev.Axes[code]
+		info.Value = value
+		dev.Axes[code] = info
+		dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, uint16(code), value)
+	}
+
+	g := gamepad.NewEvdevGamepadsForTesting()
+	if err := g.Open("/dev/input/event-test", dev); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Gamepads()) != 1 {
+		t.Fatalf("len(Gamepads()): got: %d, want: 1", len(g.Gamepads()))
+	}
+	gp := g.Gamepads()[0]
+
+	dev.PressedKeys = []int{btnA}
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evKey, btnA, 1)
+	setAbs(absX, 32767)
+	setAbs(absHat1, -1)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evSyn, synReport, 0)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gp.Button(0), true; got != want {
+		t.Errorf("Button(0): got: %t, want: %t", got, want)
+	}
+	if got, want := gp.Axis(0), 1.0; got != want {
+		t.Errorf("Axis(0): got: %f, want: %f", got, want)
+	}
+	if got, want := gp.Hat(0), hatUp; got != want {
+		t.Errorf("Hat(0): got: %d, want: %d", got, want)
+	}
+
+	// The release of A is dropped, and the events until the next SYN_REPORT are ignored.
+	dev.PressedKeys = nil
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evSyn, synDropped, 0)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evKey, btnB, 1)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evSyn, synReport, 0)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gp.Button(0), false; got != want {
+		t.Errorf("Button(0) after dropping: got: %t, want: %t", got, want)
+	}
+	if got, want := gp.Button(1), false; got != want {
+		t.Errorf("Button(1) after dropping: got: %t, want: %t", got, want)
+	}
+
+	// Reading the device fails with ENODEV after the disconnection.
+	dev.Disconnected = true
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(g.Gamepads()); got != 0 {
+		t.Errorf("len(Gamepads()) after the disconnection: got: %d, want: 0", got)
+	}
+	if !dev.Closed {
+		t.Errorf("the device must be closed after the disconnection")
+	}
+}
+
+func TestLastActiveTime(t *testing.T) {
+	const (
+		evKey = 0x01
+		evAbs = 0x03
+
+		absX = 0x00
+		absY = 0x01
+		btnA = 0x130
+	)
+
+	stick := gamepad.AbsInfoForTesting{
+		Minimum: -32768,
+		Maximum: 32767,
+		Flat:    4096,
+	}
+	dev := &gamepad.EvdevDeviceForTesting{
+		Name: "Gamepad",
+		Keys: []int{btnA},
+		Axes: map[int]gamepad.AbsInfoForTesting{
+			absX: stick,
+			absY: stick,
+		},
+	}
+	g := gamepad.NewEvdevGamepadsForTesting()
+	if err := g.Open("/dev/input/event-test", dev); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Gamepads()) != 1 {
+		t.Fatalf("len(Gamepads()): got: %d, want: 1", len(g.Gamepads()))
+	}
+	gp := g.Gamepads()[0]
+	if got, ok := gp.LastActiveTime(); !ok || !got.IsZero() {
+		t.Errorf("LastActiveTime() after opening: got: %v, %t, want: zero, true", got, ok)
+	}
+
+	// A value within the dead zone is not an activity.
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absX, 1000)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := gp.LastActiveTime(); !got.IsZero() {
+		t.Errorf("LastActiveTime() after a small axis change: got: %v, want: zero", got)
+	}
+
+	before := time.Now()
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evKey, btnA, 1)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := gp.LastActiveTime(); got.Before(before) {
+		t.Errorf("LastActiveTime() after pressing a button: got: %v, want: after %v", got, before)
+	}
+}
+
+func TestHeuristicStandardMapping(t *testing.T) {
+	const (
+		evAbs = 0x03
+
+		absX    = 0x00
+		absY    = 0x01
+		absHat0 = 0x10
+		absHat1 = 0x11
+
+		btnTrigger = 0x120
+	)
+
+	// A joystick without BTN_GAMEPAD doesn't have its own standard layout mapping.
+	dev := &gamepad.EvdevDeviceForTesting{
+		Name:    "Unknown Joystick",
+		BusType: 0x03,
+		Vendor:  0x1209,
+		Product: 0x0002,
+		Version: 0x0100,
+		Keys:    []int{btnTrigger},
+		Axes: map[int]gamepad.AbsInfoForTesting{
+			absX:    newAbsInfo(-32768, 32767),
+			absY:    newAbsInfo(-32768, 32767),
+			absHat0: newAbsInfo(-1, 1),
+			absHat1: newAbsInfo(-1, 1),
+		},
+	}
+	g := gamepad.NewEvdevGamepadsForTesting()
+	if err := g.Open("/dev/input/event-test", dev); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Gamepads()) != 1 {
+		t.Fatalf("len(Gamepads()): got: %d, want: 1", len(g.Gamepads()))
+	}
+	gp := g.Gamepads()[0]
+
+	if got, want := gp.IsStandardLayoutAvailable(), false; got != want {
+		t.Errorf("IsStandardLayoutAvailable(): got: %t, want: %t", got, want)
+	}
+	for _, b := range []gamepaddb.StandardButton{
+		gamepaddb.StandardButtonLeftTop,
+		gamepaddb.StandardButtonLeftBottom,
+		gamepaddb.StandardButtonLeftLeft,
+		gamepaddb.StandardButtonLeftRight,
+	} {
+		// The guessed buttons are not reported as available.
+		if got, want := gp.IsStandardButtonAvailable(b), false; got != want {
+			t.Errorf("IsStandardButtonAvailable(%d): got: %t, want: %t", b, got, want)
+		}
+	}
+	if got, want := gp.IsStandardButtonAvailable(gamepaddb.StandardButtonRightBottom), false; got != want {
+		t.Errorf("IsStandardButtonAvailable(StandardButtonRightBottom): got: %t, want: %t", got, want)
+	}
+	if got, want := gp.IsStandardAxisAvailable(gamepaddb.StandardAxisLeftStickVertical), false; got != want {
+		t.Errorf("IsStandardAxisAvailable(StandardAxisLeftStickVertical): got: %t, want: %t", got, want)
+	}
+	if got, want := gp.IsStandardAxisAvailable(gamepaddb.StandardAxisRightStickHorizontal), false; got != want {
+		t.Errorf("IsStandardAxisAvailable(StandardAxisRightStickHorizontal): got: %t, want: %t", got, want)
+	}
+
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absHat0, -1)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absY, 32767)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gp.IsStandardButtonPressed(gamepaddb.StandardButtonLeftLeft), true; got != want {
+		t.Errorf("IsStandardButtonPressed(StandardButtonLeftLeft): got: %t, want: %t", got, want)
+	}
+	if got, want := gp.IsStandardButtonPressed(gamepaddb.StandardButtonLeftRight), false; got != want {
+		t.Errorf("IsStandardButtonPressed(StandardButtonLeftRight): got: %t, want: %t", got, want)
+	}
+	if got, want := gp.StandardAxisValue(gamepaddb.StandardAxisLeftStickVertical), 1.0; got != want {
+		t.Errorf("StandardAxisValue(StandardAxisLeftStickVertical): got: %f, want: %f", got, want)
+	}
+}
+
+func TestDeviceError(t *testing.T) {
+	const (
+		evKey = 0x01
+
+		absX = 0x00
+		absY = 0x01
+		btnA = 0x130
+	)
+
+	newDevice := func(name string) *gamepad.EvdevDeviceForTesting {
+		return &gamepad.EvdevDeviceForTesting{
+			Name: name,
+			Keys: []int{btnA},
+			Axes: map[int]gamepad.AbsInfoForTesting{
+				absX: newAbsInfo(-32768, 32767),
+				absY: newAbsInfo(-32768, 32767),
+			},
+		}
+	}
+
+	g := gamepad.NewEvdevGamepadsForTesting()
+	broken := newDevice("Broken Gamepad")
+	working := newDevice("Working Gamepad")
+	if err := g.Open("/dev/input/event-broken", broken); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Open("/dev/input/event-working", working); err != nil {
+		t.Fatal(err)
+	}
+
+	// The broken gamepad starts to fail in the middle of the stream.
+	broken.ReadErr = unix.EIO
+	working.Events = gamepad.AppendInputEventForTesting(working.Events, evKey, btnA, 1)
+	if err := g.Update(); err != nil {
+		t.Fatalf("Update must not fail with a device error: %v", err)
+	}
+
+	gps := g.Gamepads()
+	if len(gps) != 1 {
+		t.Fatalf("len(Gamepads()): got: %d, want: 1", len(gps))
+	}
+	if got, want := gps[0].Name(), "Working Gamepad"; got != want {
+		t.Errorf("Name(): got: %s, want: %s", got, want)
+	}
+	if got, want := gps[0].Button(0), true; got != want {
+		t.Errorf("Button(0): got: %t, want: %t", got, want)
+	}
+	if !broken.Closed {
+		t.Errorf("the broken gamepad must be closed")
+	}
+
+	errs := g.DeviceErrors()
+	if len(errs) != 1 {
+		t.Fatalf("len(DeviceErrors()): got: %d, want: 1", len(errs))
+	}
+	if got, want := errs[0].Path, "/dev/input/event-broken"; got != want {
+		t.Errorf("Path: got: %s, want: %s", got, want)
+	}
+	if got, want := errs[0].Name, "Broken Gamepad"; got != want {
+		t.Errorf("Name: got: %s, want: %s", got, want)
+	}
+	if !errors.Is(errs[0].Err, unix.EIO) {
+		t.Errorf("Err: got: %v, want: %v", errs[0].Err, unix.EIO)
+	}
+}
+
+func TestShortRead(t *testing.T) {
+	const (
+		evKey = 0x01
+		evAbs = 0x03
+
+		absX = 0x00
+		absY = 0x01
+		btnA = 0x130
+		btnB = 0x131
+	)
+
+	newDevice := func() (*gamepad.EvdevGamepadsForTesting, *gamepad.EvdevDeviceForTesting) {
+		g := gamepad.NewEvdevGamepadsForTesting()
+		dev := &gamepad.EvdevDeviceForTesting{
+			Name: "Gamepad",
+			Keys: []int{btnA, btnB},
+			Axes: map[int]gamepad.AbsInfoForTesting{
+				absX: newAbsInfo(-32768, 32767),
+				absY: newAbsInfo(-32768, 32767),
+			},
+		}
+		if err := g.Open("/dev/input/event0", dev); err != nil {
+			t.Fatal(err)
+		}
+		return g, dev
+	}
+
+	t.Run("split event", func(t *testing.T) {
+		g, dev := newDevice()
+
+		// Each read returns 1.5 events. The second half of an event is completed by the next read.
+		var events []byte
+		events = gamepad.AppendInputEventForTesting(events, evKey, btnA, 1)
+		events = gamepad.AppendInputEventForTesting(events, evKey, btnB, 1)
+		events = gamepad.AppendInputEventForTesting(events, evAbs, absX, 32767)
+		dev.Events = events
+		dev.ReadSize = len(events) / 2
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		gp := g.Gamepads()[0]
+		if !gp.Button(0) {
+			t.Errorf("Button(0): got: false, want: true")
+		}
+		if gp.Button(1) {
+			t.Errorf("Button(1) before the rest is read: got: true, want: false")
+		}
+
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if !gp.Button(1) {
+			t.Errorf("Button(1): got: false, want: true")
+		}
+		if got, want := gp.Axis(0), 1.0; got != want {
+			t.Errorf("Axis(0): got: %f, want: %f", got, want)
+		}
+	})
+
+	t.Run("truncated event", func(t *testing.T) {
+		g, dev := newDevice()
+
+		// The last event is incomplete and must not be handled with stale bytes.
+		var events []byte
+		events = gamepad.AppendInputEventForTesting(events, evKey, btnA, 1)
+		events = gamepad.AppendInputEventForTesting(events, evKey, btnB, 1)
+		dev.Events = events[:len(events)-1]
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		gp := g.Gamepads()[0]
+		if !gp.Button(0) {
+			t.Errorf("Button(0): got: false, want: true")
+		}
+		if gp.Button(1) {
+			t.Errorf("Button(1) with the truncated event: got: true, want: false")
+		}
+
+		dev.Events = events[len(events)-1:]
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if !gp.Button(1) {
+			t.Errorf("Button(1) after the event is completed: got: false, want: true")
+		}
+	})
+
+	t.Run("EOF", func(t *testing.T) {
+		g, dev := newDevice()
+
+		// A read returning 0 bytes means that the device is gone.
+		dev.EOF = true
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(g.Gamepads()); got != 0 {
+			t.Errorf("len(Gamepads()): got: %d, want: 0", got)
+		}
+		if !dev.Closed {
+			t.Errorf("the device must be closed")
+		}
+	})
+}
+
+func TestCalibration(t *testing.T) {
+	const (
+		evAbs = 0x03
+
+		absX = 0x00
+		absY = 0x01
+		btnA = 0x130
+	)
+
+	newDevice := func() *gamepad.EvdevDeviceForTesting {
+		x := newAbsInfo(0, 255)
+		x.Value = 128
+		y := newAbsInfo(0, 255)
+		y.Value = 128
+		return &gamepad.EvdevDeviceForTesting{
+			Name:    "Worn Gamepad",
+			Uniq:    "00:11:22:33:44:55",
+			BusType: 0x03,
+			Vendor:  0x1209,
+			Product: 0x0001,
+			Keys:    []int{btnA},
+			Axes: map[int]gamepad.AbsInfoForTesting{
+				absX: x,
+				absY: y,
+			},
+		}
+	}
+
+	g := gamepad.NewEvdevGamepadsForTesting()
+	dev := newDevice()
+	if err := g.Open("/dev/input/event0", dev); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	gp := g.Gamepads()[0]
+
+	// Record the range of the X axis reaching only 0.6 on the positive side.
+	if !gp.StartCalibration() {
+		t.Fatal("StartCalibration() should return true")
+	}
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absX, 0)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absX, 204)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absX, 128)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	c := gp.FinishCalibration()
+	if c == nil {
+		t.Fatal("FinishCalibration() must not return nil")
+	}
+	if got, want := c.Serial, "00:11:22:33:44:55"; got != want {
+		t.Errorf("Serial: got: %s, want: %s", got, want)
+	}
+	x := c.Axes[0]
+	if math.Abs(x.Min+1) > 1e-3 || math.Abs(x.Center) > 1e-2 || math.Abs(x.Max-0.6) > 1e-3 {
+		t.Errorf("Axes[0]: got: %+v, want: {Min: -1, Center: ~0, Max: 0.6}", x)
+	}
+
+	// The calibration can be saved and restored.
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored gamepad.Calibration
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&restored, c) {
+		t.Errorf("restored calibration: got: %+v, want: %+v", restored, *c)
+	}
+
+	gp.SetCalibration(&restored)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absX, 204)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gp.Axis(0), 1.0; math.Abs(got-want) > 1e-2 {
+		t.Errorf("Axis(0) with the calibration: got: %f, want: %f", got, want)
+	}
+
+	// The calibration is applied again when the same gamepad is reconnected.
+	dev.Disconnected = true
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(g.Gamepads()); got != 0 {
+		t.Fatalf("len(Gamepads()): got: %d, want: 0", got)
+	}
+	dev = newDevice()
+	if err := g.Open("/dev/input/event1", dev); err != nil {
+		t.Fatal(err)
+	}
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absX, 204)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	gp = g.Gamepads()[0]
+	if gp.Calibration() == nil {
+		t.Errorf("Calibration() after the reconnection must not be nil")
+	}
+	if got, want := gp.Axis(0), 1.0; math.Abs(got-want) > 1e-2 {
+		t.Errorf("Axis(0) after the reconnection: got: %f, want: %f", got, want)
+	}
+
+	// Resetting the calibration.
+	gp.SetCalibration(nil)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absX, 204)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gp.Axis(0), 0.6; math.Abs(got-want) > 1e-2 {
+		t.Errorf("Axis(0) without the calibration: got: %f, want: %f", got, want)
+	}
+}
+
+func TestManyButtons(t *testing.T) {
+	const (
+		evKey = 0x01
+
+		absX              = 0x00
+		absY              = 0x01
+		btnMisc           = 0x100
+		btnTriggerHappy   = 0x2c0
+		btnTriggerHappy40 = 0x2e7
+	)
+
+	// Arcade encoders and flight panels can have many buttons including BTN_TRIGGER_HAPPY*.
+	var keys []int
+	for i := 0; i < 60; i++ {
+		keys = append(keys, btnMisc+i)
+	}
+	for i := 0; i < 40; i++ {
+		keys = append(keys, btnTriggerHappy+i)
+	}
+
+	g := gamepad.NewEvdevGamepadsForTesting()
+	dev := &gamepad.EvdevDeviceForTesting{
+		Name: "Arcade Encoder",
+		Keys: keys,
+		Axes: map[int]gamepad.AbsInfoForTesting{
+			absX: newAbsInfo(-32768, 32767),
+			absY: newAbsInfo(-32768, 32767),
+		},
+	}
+	if err := g.Open("/dev/input/event-arcade", dev); err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range keys {
+		dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evKey, uint16(code), 1)
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	gps := g.Gamepads()
+	if len(gps) != 1 {
+		t.Fatalf("len(Gamepads()): got: %d, want: 1", len(gps))
+	}
+	gp := gps[0]
+	if got, want := gp.ButtonCount(), 100; got != want {
+		t.Errorf("ButtonCount(): got: %d, want: %d", got, want)
+	}
+	for i := 0; i < 100; i++ {
+		if !gp.Button(i) {
+			t.Errorf("Button(%d): got: false, want: true", i)
+		}
+	}
+	for _, i := range []int{-1, 100, 511, 512} {
+		if gp.Button(i) {
+			t.Errorf("Button(%d): got: true, want: false", i)
+		}
+	}
+
+	// The last button code is mapped to the last button index.
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evKey, btnTriggerHappy40, 0)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if gp.Button(99) {
+		t.Errorf("Button(99): got: true, want: false")
+	}
+	if !gp.Button(98) {
+		t.Errorf("Button(98): got: false, want: true")
+	}
+}
+
+func TestAnalogTriggerButtons(t *testing.T) {
+	const (
+		evAbs = 0x03
+
+		absX     = 0x00
+		absY     = 0x01
+		absZ     = 0x02
+		absRZ    = 0x05
+		absGas   = 0x09
+		absBrake = 0x0a
+		btnA     = 0x130
+		btnB     = 0x131
+		btnX     = 0x133
+		btnY     = 0x134
+		btnTL2   = 0x138
+		btnTR2   = 0x139
+	)
+
+	g := gamepad.NewEvdevGamepadsForTesting()
+	dev := &gamepad.EvdevDeviceForTesting{
+		Name:    "Analog Triggers",
+		BusType: 0x03,
+		Vendor:  0x1209,
+		Product: 0x0002,
+		Keys:    []int{btnA, btnB, btnX, btnY, btnTL2, btnTR2},
+		Axes: map[int]gamepad.AbsInfoForTesting{
+			absX:     newAbsInfo(-32768, 32767),
+			absY:     newAbsInfo(-32768, 32767),
+			absZ:     newAbsInfo(0, 255),
+			absRZ:    newAbsInfo(0, 255),
+			absGas:   newAbsInfo(0, 255),
+			absBrake: newAbsInfo(0, 255),
+		},
+	}
+	if err := g.Open("/dev/input/event0", dev); err != nil {
+		t.Fatal(err)
+	}
+	gp := g.Gamepads()[0]
+
+	// The buttons are ordered by the codes, and so are the axes.
+	const (
+		buttonTL2 = 4
+		buttonTR2 = 5
+		axisBrake = 5
+	)
+
+	// Without the database, the triggers are mapped to ABS_Z and ABS_RZ by the kernel's gamepad specification.
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absZ, 255)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absRZ, 0)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gp.ButtonValue(buttonTL2), 1.0; got != want {
+		t.Errorf("ButtonValue(%d): got: %f, want: %f", buttonTL2, got, want)
+	}
+	if got, want := gp.ButtonValue(buttonTR2), 0.0; got != want {
+		t.Errorf("ButtonValue(%d): got: %f, want: %f", buttonTR2, got, want)
+	}
+
+	// With a mapping, the axis of the trigger is resolved through the mapping.
+	// A trigger mapped to a button reports the digital value.
+	o, err := gamepaddb.NewOverride(&gamepaddb.Mapping{
+		Buttons: map[gamepaddb.StandardButton]gamepaddb.Input{
+			gamepaddb.StandardButtonFrontBottomLeft: {
+				Type:  gamepaddb.InputTypeAxis,
+				Index: axisBrake,
+			},
+			gamepaddb.StandardButtonFrontBottomRight: {
+				Type:  gamepaddb.InputTypeButton,
+				Index: buttonTR2,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gp.SetStandardLayoutOverride(o, false)
+
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absZ, 0)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absRZ, 255)
+	dev.Events = gamepad.AppendInputEventForTesting(dev.Events, evAbs, absBrake, 255)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gp.ButtonValue(buttonTL2), 1.0; got != want {
+		t.Errorf("ButtonValue(%d): got: %f, want: %f", buttonTL2, got, want)
+	}
+	if got, want := gp.ButtonValue(buttonTR2), 0.0; got != want {
+		t.Errorf("ButtonValue(%d): got: %f, want: %f", buttonTR2, got, want)
+	}
+}
+
+func TestTooManyButtons(t *testing.T) {
+	const (
+		absX            = 0x00
+		absY            = 0x01
+		btnMisc         = 0x100
+		btnTriggerHappy = 0x2c0
+	)
+
+	// A device with too many buttons is not a gamepad, e.g., a keyboard with a joystick (#1173, #2039).
+	var keys []int
+	for i := 0; i < 0x80; i++ {
+		keys = append(keys, btnMisc+i)
+	}
+	for i := 0; i < 40; i++ {
+		keys = append(keys, btnTriggerHappy+i)
+	}
+
+	g := gamepad.NewEvdevGamepadsForTesting()
+	dev := &gamepad.EvdevDeviceForTesting{
+		Name: "Too Many Buttons",
+		Keys: keys,
+		Axes: map[int]gamepad.AbsInfoForTesting{
+			absX: newAbsInfo(-32768, 32767),
+			absY: newAbsInfo(-32768, 32767),
+		},
+	}
+	const path = "/dev/input/event-too-many-buttons"
+	if err := g.Open(path, dev); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(g.Gamepads()); got != 1 {
+		t.Fatalf("len(Gamepads()): got: %d, want: 1", got)
+	}
+
+	g.RemoveGamepadsWithTooManyButtons()
+
+	if got := len(g.Gamepads()); got != 0 {
+		t.Errorf("len(Gamepads()): got: %d, want: 0", got)
+	}
+	if !dev.Closed {
+		t.Errorf("Closed: got: false, want: true")
+	}
+	if got, want := g.IgnoredPaths(), []string{path}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IgnoredPaths(): got: %v, want: %v", got, want)
+	}
+}
+
+// newAbsInfo returns the state of an absolute axis ranging from minimum to maximum.
+func newAbsInfo(minimum, maximum int32) gamepad.AbsInfoForTesting {
+	return gamepad.AbsInfoForTesting{
+		Minimum: minimum,
+		Maximum: maximum,
+	}
+}
+
+func TestTouchpad(t *testing.T) {
+	const (
+		evSyn = 0x00
+		evKey = 0x01
+		evAbs = 0x03
+
+		synReport       = 0x00
+		synDropped      = 0x03
+		btnLeft         = 0x110
+		absMTSlot       = 0x2f
+		absMTPositionX  = 0x35
+		absMTPositionY  = 0x36
+		absMTTrackingID = 0x39
+
+		maxX = 1920
+		maxY = 1080
+	)
+
+	r, w := newEventPipe(t)
+	tp := gamepad.NewTouchpadForTesting(r, maxX, maxY, make([]byte, 0x300/8))
+
+	var events []byte
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTSlot, 0)
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTTrackingID, 10)
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTPositionX, maxX/2)
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTPositionY, maxY/4)
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTSlot, 1)
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTTrackingID, 11)
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTPositionX, maxX/3)
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTPositionY, maxY)
+	// Touches beyond the second touch are ignored.
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTSlot, 5)
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTTrackingID, 12)
+	events = gamepad.AppendInputEventForTesting(events, evKey, btnLeft, 1)
+	events = gamepad.AppendInputEventForTesting(events, evSyn, synReport, 0)
+	if _, err := unix.Write(w, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := tp.IsPressed(), true; got != want {
+		t.Errorf("IsPressed(): got: %t, want: %t", got, want)
+	}
+	cases := []struct {
+		Index int
+		X     float64
+		Y     float64
+		OK    bool
+	}{
+		{Index: 0, X: 0.5, Y: 0.25, OK: true},
+		{Index: 1, X: 1.0 / 3, Y: 1, OK: true},
+		{Index: 2, OK: false},
+		{Index: -1, OK: false},
+	}
+	for _, c := range cases {
+		x, y, ok := tp.TouchPosition(c.Index)
+		if ok != c.OK || math.Abs(x-c.X) > 1e-6 || math.Abs(y-c.Y) > 1e-6 {
+			t.Errorf("TouchPosition(%d): got: (%f, %f, %t), want: (%f, %f, %t)", c.Index, x, y, ok, c.X, c.Y, c.OK)
+		}
+	}
+
+	// The first touch is released.
+	events = events[:0]
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTSlot, 0)
+	events = gamepad.AppendInputEventForTesting(events, evAbs, absMTTrackingID, -1)
+	events = gamepad.AppendInputEventForTesting(events, evSyn, synReport, 0)
+	if _, err := unix.Write(w, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := tp.TouchPosition(0); ok {
+		t.Errorf("TouchPosition(0) after releasing: got: true, want: false")
+	}
+	if _, _, ok := tp.TouchPosition(1); !ok {
+		t.Errorf("TouchPosition(1) after releasing the other touch: got: false, want: true")
+	}
+
+	// The other events are dropped. The state is read from the device.
+	events = events[:0]
+	events = gamepad.AppendInputEventForTesting(events, evSyn, synDropped, 0)
+	events = gamepad.AppendInputEventForTesting(events, evSyn, synReport, 0)
+	if _, err := unix.Write(w, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tp.IsPressed(), false; got != want {
+		t.Errorf("IsPressed() after dropping: got: %t, want: %t", got, want)
+	}
+	if _, _, ok := tp.TouchPosition(1); ok {
+		t.Errorf("TouchPosition(1) after dropping: got: true, want: false")
+	}
+}
+
+func TestParseSysfsBitmap(t *testing.T) {
+	if bits.UintSize != 64 {
+		t.Skip("the test data assumes 64-bit unsigned long")
+	}
+
+	const (
+		btnA  = 0x130
+		btnB  = 0x131
+		btnTL = 0x136
+		btnTR = 0x13e
+	)
+
+	// The key capabilities of an Xbox 360 controller.
+	keyBits, ok := gamepad.ParseSysfsBitmapForTesting("7cdb000000000000 0 0 0 0\n", 0x300)
+	if !ok {
+		t.Fatal("ParseSysfsBitmap failed")
+	}
+	isSet := func(code int) bool {
+		return keyBits[code/8]&(1<<(code%8)) != 0
+	}
+	for _, code := range []int{btnA, btnB, btnTL, btnTR} {
+		if !isSet(code) {
+			t.Errorf("bit 0x%x: got: false, want: true", code)
+		}
+	}
+	if isSet(0x132) {
+		t.Errorf("bit 0x132: got: true, want: false")
+	}
+
+	// Bits beyond the size are ignored.
+	if _, ok := gamepad.ParseSysfsBitmapForTesting("ffffffffffffffff ffffffffffffffff", 8); !ok {
+		t.Errorf("ParseSysfsBitmap with extra bits failed")
+	}
+
+	if _, ok := gamepad.ParseSysfsBitmapForTesting("xyz", 8); ok {
+		t.Errorf("ParseSysfsBitmap with an invalid input: got: true, want: false")
+	}
+}
+
+func TestVibration(t *testing.T) {
+	r, _ := newEventPipe(t)
+	g := gamepad.NewNativeGamepadForTesting(r)
+	ff := &gamepad.ForceFeedbackForTesting{
+		MaxEffects: 1,
+	}
+	g.SetForceFeedbackForTesting(ff)
+
+	playingID := func() int16 {
+		t.Helper()
+		if len(ff.Effects) != 1 {
+			t.Fatalf("len(Effects): got: %d, want: 1", len(ff.Effects))
+		}
+		for id := range ff.Effects {
+			if !ff.Playing[id] {
+				t.Fatalf("Playing[%d]: got: false, want: true", id)
+			}
+			return id
+		}
+		panic("not reached")
+	}
+
+	// Idle to playing.
+	g.Vibrate(100*time.Millisecond, 1, 0)
+	id := playingID()
+	if got, want := ff.Effects[id], uint16(100); got != want {
+		t.Errorf("length: got: %d, want: %d", got, want)
+	}
+
+	// Playing to replaced. The effect is updated in place even though there is only one slot.
+	for i := 0; i < 10; i++ {
+		g.Vibrate(200*time.Millisecond, 0.5, 0.5)
+	}
+	if got := playingID(); got != id {
+		t.Errorf("ID: got: %d, want: %d", got, id)
+	}
+	if got, want := ff.Effects[id], uint16(200); got != want {
+		t.Errorf("length: got: %d, want: %d", got, want)
+	}
+
+	// The effect is uploaded again if it was removed by the device.
+	delete(ff.Effects, id)
+	g.Vibrate(300*time.Millisecond, 0.5, 0.5)
+	id = playingID()
+	if got, want := ff.Effects[id], uint16(300); got != want {
+		t.Errorf("length: got: %d, want: %d", got, want)
+	}
+
+	// Playing to expired.
+	g.ExpireVibration()
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(ff.Effects); got != 0 {
+		t.Errorf("len(Effects) after expiring: got: %d, want: 0", got)
+	}
+
+	// Stopping by zero magnitudes.
+	g.Vibrate(100*time.Millisecond, 1, 1)
+	playingID()
+	g.Vibrate(100*time.Millisecond, 0, 0)
+	if got := len(ff.Effects); got != 0 {
+		t.Errorf("len(Effects) after stopping: got: %d, want: 0", got)
+	}
+
+	// Playing to closed.
+	g.Vibrate(100*time.Millisecond, 1, 1)
+	playingID()
+	g.Close()
+	if got := len(ff.Effects); got != 0 {
+		t.Errorf("len(Effects) after closing: got: %d, want: 0", got)
+	}
+}
+
+func TestVibrationGain(t *testing.T) {
+	t.Run("software", func(t *testing.T) {
+		r, _ := newEventPipe(t)
+		g := gamepad.NewNativeGamepadForTesting(r)
+		ff := &gamepad.ForceFeedbackForTesting{
+			MaxEffects: 1,
+		}
+		g.SetForceFeedbackForTesting(ff)
+
+		// The magnitudes are attenuated as the device doesn't support FF_GAIN.
+		g.SetVibrationGain(0.5)
+		g.Vibrate(100*time.Millisecond, 1, 0.5)
+		for _, m := range ff.Magnitudes {
+			if got, want := m, [2]uint16{0x7fff, 0x3fff}; got != want {
+				t.Errorf("magnitudes: got: %v, want: %v", got, want)
+			}
+		}
+		if got := len(ff.Gains); got != 0 {
+			t.Errorf("len(Gains): got: %d, want: 0", got)
+		}
+	})
+
+	t.Run("device", func(t *testing.T) {
+		r, _ := newEventPipe(t)
+		g := gamepad.NewNativeGamepadForTesting(r)
+		ff := &gamepad.ForceFeedbackForTesting{
+			MaxEffects:    1,
+			GainSupported: true,
+		}
+		g.SetForceFeedbackForTesting(ff)
+
+		// The gain is written only when it changes.
+		g.SetVibrationGain(0.5)
+		g.SetVibrationGain(0.5)
+		g.SetVibrationGain(1)
+		if got, want := ff.Gains, []uint16{0x7fff, 0xffff}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Gains: got: %v, want: %v", got, want)
+		}
+
+		// The magnitudes are not attenuated as the device attenuates them.
+		g.SetVibrationGain(0.5)
+		g.Vibrate(100*time.Millisecond, 1, 0.5)
+		for _, m := range ff.Magnitudes {
+			if got, want := m, [2]uint16{0xffff, 0x7fff}; got != want {
+				t.Errorf("magnitudes: got: %v, want: %v", got, want)
+			}
+		}
+	})
+}
+
+func BenchmarkNativeGamepadUpdate(b *testing.B) {
+	r, w := newEventPipe(b)
+	g := gamepad.NewNativeGamepadForTesting(r)
+	events := syntheticEvents()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := unix.Write(w, events); err != nil {
+			b.Fatal(err)
+		}
+		if err := g.Update(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestIsGamepadDeviceByProperties(t *testing.T) {
+	const (
+		propPointer       = 0x00
+		propDirect        = 0x01
+		propButtonpad     = 0x02
+		propPointingStick = 0x05
+		propAccelerometer = 0x06
+	)
+
+	cases := []struct {
+		Name  string
+		Props []int
+		Want  bool
+	}{
+		{
+			Name: "no properties",
+			Want: true,
+		},
+		{
+			Name:  "direct",
+			Props: []int{propDirect},
+			Want:  true,
+		},
+		{
+			Name:  "accelerometer",
+			Props: []int{propAccelerometer},
+			Want:  false,
+		},
+		{
+			Name:  "touchpad",
+			Props: []int{propPointer, propButtonpad},
+			Want:  false,
+		},
+		{
+			Name:  "pointing stick",
+			Props: []int{propPointer, propPointingStick},
+			Want:  false,
+		},
+	}
+	for _, c := range cases {
+		propBits := make([]byte, 4)
+		for _, p := range c.Props {
+			propBits[p/8] |= 1 << (p % 8)
+		}
+		if got := gamepad.IsGamepadDeviceByPropertiesForTesting(propBits); got != c.Want {
+			t.Errorf("%s: got: %t, want: %t", c.Name, got, c.Want)
+		}
+	}
+}
+
+func TestParseUevent(t *testing.T) {
+	udevMessage := func(props string) []byte {
+		// udev_monitor_netlink_header is 40 bytes.
+		header := make([]byte, 40)
+		copy(header, "libudev\x00")
+		header[16] = byte(len(header))
+		header[20] = byte(len(props))
+		return append(header, props...)
+	}
+
+	cases := []struct {
+		Name      string
+		Input     []byte
+		Action    string
+		Subsystem string
+		DevName   string
+	}{
+		{
+			Name:      "kernel",
+			Input:     []byte("add@/devices/virtual/input/input10/event5\x00ACTION=add\x00DEVPATH=/devices/virtual/input/input10/event5\x00SUBSYSTEM=input\x00DEVNAME=input/event5\x00SEQNUM=1234\x00"),
+			Action:    "add",
+			Subsystem: "input",
+			DevName:   "input/event5",
+		},
+		{
+			Name:      "udev",
+			Input:     udevMessage("ACTION=remove\x00DEVPATH=/devices/virtual/input/input10/event5\x00SUBSYSTEM=input\x00DEVNAME=/dev/input/event5\x00"),
+			Action:    "remove",
+			Subsystem: "input",
+			DevName:   "/dev/input/event5",
+		},
+		{
+			Name:      "udev without a trailing null",
+			Input:     udevMessage("ACTION=add\x00SUBSYSTEM=usb"),
+			Action:    "add",
+			Subsystem: "usb",
+			DevName:   "",
+		},
+		{
+			Name:  "broken",
+			Input: []byte("add@/devices"),
+		},
+	}
+	for _, c := range cases {
+		action, subsystem, devName := gamepad.ParseUeventForTesting(c.Input)
+		if action != c.Action || subsystem != c.Subsystem || devName != c.DevName {
+			t.Errorf("%s: got: (%q, %q, %q), want: (%q, %q, %q)", c.Name, action, subsystem, devName, c.Action, c.Subsystem, c.DevName)
+		}
+	}
+}
+
+func TestPlayerLEDNumber(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Number int
+		OK     bool
+	}{
+		{
+			Name:   "0005:054C:0CE6.0001:white:player-1",
+			Number: 1,
+			OK:     true,
+		},
+		{
+			Name:   "0005:057E:2009.0001:player4",
+			Number: 4,
+			OK:     true,
+		},
+		{
+			Name:   "0005:057E:2009.0001:green:player-2",
+			Number: 2,
+			OK:     true,
+		},
+		{
+			Name: "0005:054C:09CC.0001:red",
+		},
+		{
+			Name: "input5::capslock",
+		},
+		{
+			Name: "0005:054C:0CE6.0001:white:player-",
+		},
+	}
+	for _, c := range cases {
+		n, ok := gamepad.PlayerLEDNumberForTesting(c.Name)
+		if n != c.Number || ok != c.OK {
+			t.Errorf("PlayerLEDNumber(%q): got: (%d, %t), want: (%d, %t)", c.Name, n, ok, c.Number, c.OK)
+		}
+	}
+}
+
+func TestIsSameInputDevice(t *testing.T) {
+	cases := []struct {
+		Name  string
+		Phys1 string
+		Uniq1 string
+		Phys2 string
+		Uniq2 string
+		Want  bool
+	}{
+		{
+			Name:  "USB",
+			Phys1: "usb-0000:00:14.0-1/input3",
+			Phys2: "usb-0000:00:14.0-1/input3",
+			Uniq2: "a0:ab:51:00:00:01",
+			Want:  true,
+		},
+		{
+			Name:  "different USB ports",
+			Phys1: "usb-0000:00:14.0-1/input3",
+			Phys2: "usb-0000:00:14.0-2/input3",
+			Want:  false,
+		},
+		{
+			Name:  "Bluetooth",
+			Phys1: "00:1a:7d:da:71:13",
+			Uniq1: "a0:ab:51:00:00:01",
+			Phys2: "00:1a:7d:da:71:13",
+			Uniq2: "a0:ab:51:00:00:01",
+			Want:  true,
+		},
+		{
+			Name:  "different Bluetooth devices",
+			Phys1: "00:1a:7d:da:71:13",
+			Uniq1: "a0:ab:51:00:00:01",
+			Phys2: "00:1a:7d:da:71:13",
+			Uniq2: "a0:ab:51:00:00:02",
+			Want:  false,
+		},
+		{
+			Name: "empty",
+			Want: false,
+		},
+	}
+	for _, c := range cases {
+		if got := gamepad.IsSameInputDeviceForTesting(c.Phys1, c.Uniq1, c.Phys2, c.Uniq2); got != c.Want {
+			t.Errorf("%s: got: %t, want: %t", c.Name, got, c.Want)
+		}
+	}
+}
+
+func TestIsAuxiliaryDeviceName(t *testing.T) {
+	cases := []struct {
+		Primary string
+		Name    string
+		Want    bool
+	}{
+		{
+			Primary: "Sony Interactive Entertainment Wireless Controller",
+			Name:    "Sony Interactive Entertainment Wireless Controller Touchpad",
+			Want:    true,
+		},
+		{
+			Primary: "Sony Interactive Entertainment Wireless Controller Touchpad",
+			Name:    "Sony Interactive Entertainment Wireless Controller",
+			Want:    false,
+		},
+		{
+			Primary: "Mayflash GameCube Controller Adapter",
+			Name:    "Mayflash GameCube Controller Adapter",
+			Want:    false,
+		},
+		{
+			Primary: "Pad",
+			Name:    "Paddle",
+			Want:    false,
+		},
+	}
+	for _, c := range cases {
+		if got := gamepad.IsAuxiliaryDeviceNameForTesting(c.Primary, c.Name); got != c.Want {
+			t.Errorf("IsAuxiliaryDeviceName(%q, %q): got: %t, want: %t", c.Primary, c.Name, got, c.Want)
+		}
+	}
+}
+
+func TestResolveGamepadName(t *testing.T) {
+	testCases := []struct {
+		KernelName   string
+		Manufacturer string
+		Product      string
+		Want         string
+	}{
+		{
+			KernelName:   "Sony Interactive Entertainment Wireless Controller",
+			Manufacturer: "Sony Interactive Entertainment",
+			Product:      "Wireless Controller",
+			Want:         "Sony Interactive Entertainment Wireless Controller",
+		},
+		{
+			KernelName:   "Generic X-Box pad",
+			Manufacturer: "PowerA",
+			Product:      "Xbox One Wired Controller",
+			Want:         "PowerA Xbox One Wired Controller",
+		},
+		{
+			KernelName:   "Unknown",
+			Manufacturer: "8BitDo",
+			Product:      "8BitDo Pro 2",
+			Want:         "8BitDo Pro 2",
+		},
+		{
+			KernelName: "  usb   joystick ",
+			Product:    "Arcade Stick",
+			Want:       "Arcade Stick",
+		},
+		{
+			KernelName:   "\x01\x02",
+			Manufacturer: "ACME",
+			Product:      "Pad",
+			Want:         "ACME Pad",
+		},
+		{
+			KernelName:   "USB Gamepad",
+			Manufacturer: "ACME",
+			Product:      "Gamepad",
+			Want:         "USB Gamepad",
+		},
+		{
+			KernelName: "Generic X-Box pad",
+			Want:       "Generic X-Box pad",
+		},
+	}
+	for _, tc := range testCases {
+		if got := gamepad.ResolveGamepadNameForTesting(tc.KernelName, tc.Manufacturer, tc.Product); got != tc.Want {
+			t.Errorf("ResolveGamepadName(%q, %q, %q): got: %q, want: %q", tc.KernelName, tc.Manufacturer, tc.Product, got, tc.Want)
+		}
+	}
+}
+
+func TestUSBDeviceStrings(t *testing.T) {
+	// Build a fake sysfs tree like /sys/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/0003:045E:028E.0001/input/input12.
+	root := t.TempDir()
+	usbDir := filepath.Join(root, "usb1", "1-2")
+	inputDir := filepath.Join(usbDir, "1-2:1.0", "0003:045E:028E.0001", "input", "input12")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"idVendor":     "045e\n",
+		"idProduct":    "028e\n",
+		"manufacturer": "ACME\n",
+		"product":      "Pad Pro\n",
+	} {
+		if err := os.WriteFile(filepath.Join(usbDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manufacturer, product, ok := gamepad.USBDeviceStringsForTesting(inputDir)
+	if !ok {
+		t.Fatalf("USBDeviceStrings(%q) must succeed", inputDir)
+	}
+	if manufacturer != "ACME" {
+		t.Errorf("manufacturer: got: %q, want: %q", manufacturer, "ACME")
+	}
+	if product != "Pad Pro" {
+		t.Errorf("product: got: %q, want: %q", product, "Pad Pro")
+	}
+
+	// A device not on USB, e.g., on Bluetooth.
+	btDir := filepath.Join(root, "bluetooth", "hci0", "0005:054C:09CC.0002", "input", "input13")
+	if err := os.MkdirAll(btDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := gamepad.USBDeviceStringsForTesting(btDir); ok {
+		t.Errorf("USBDeviceStrings(%q) must fail", btDir)
+	}
+}
+
+func TestParseSDLIgnoreDevices(t *testing.T) {
+	testCases := []struct {
+		In   string
+		Want [][2]uint16
+	}{
+		{
+			In: "",
+		},
+		{
+			In:   "0x045e/0x028e",
+			Want: [][2]uint16{{0x045e, 0x028e}},
+		},
+		{
+			In:   "0x054c/0x09cc, 0x045e/0x028e,",
+			Want: [][2]uint16{{0x045e, 0x028e}, {0x054c, 0x09cc}},
+		},
+		{
+			In:   "0x045e,0x045e/0xzzzz,0x10000/0x0001,0x057e/0x2009",
+			Want: [][2]uint16{{0x057e, 0x2009}},
+		},
+	}
+	for _, tc := range testCases {
+		got := gamepad.ParseSDLIgnoreDevicesForTesting(tc.In)
+		if !reflect.DeepEqual(got, tc.Want) {
+			t.Errorf("ParseSDLIgnoreDevices(%q): got: %v, want: %v", tc.In, got, tc.Want)
+		}
+	}
+}
+
+func TestSteamVirtualGamepad(t *testing.T) {
+	const (
+		absX = 0x00
+		absY = 0x01
+		btnA = 0x130
+	)
+
+	newDevice := func(name string, vendor, product uint16) *gamepad.EvdevDeviceForTesting {
+		return &gamepad.EvdevDeviceForTesting{
+			Name:    name,
+			BusType: 0x03,
+			Vendor:  vendor,
+			Product: product,
+			Version: 0x0100,
+			Keys:    []int{btnA},
+			Axes: map[int]gamepad.AbsInfoForTesting{
+				absX: newAbsInfo(-32768, 32767),
+				absY: newAbsInfo(-32768, 32767),
+			},
+		}
+	}
+
+	for _, virtualFirst := range []bool{false, true} {
+		g := gamepad.NewEvdevGamepadsForTesting()
+		g.SetSteamInputDevices("0x1209/0x0003")
+
+		physical := newDevice("Physical Gamepad", 0x1209, 0x0003)
+		other := newDevice("Other Gamepad", 0x1209, 0x0004)
+		virtual := newDevice("Microsoft X-Box 360 pad 0", 0x28de, 0x11ff)
+
+		if err := g.Open("/dev/input/event-other", other); err != nil {
+			t.Fatal(err)
+		}
+		if virtualFirst {
+			if err := g.Open("/dev/input/event-virtual", virtual); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.Open("/dev/input/event-physical", physical); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err := g.Open("/dev/input/event-physical", physical); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := len(g.Gamepads()), 2; got != want {
+				t.Errorf("len(Gamepads()) before the virtual gamepad: got: %d, want: %d", got, want)
+			}
+			if err := g.Open("/dev/input/event-virtual", virtual); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The physical gamepad is hidden, while the other gamepad is not.
+		if got, want := len(g.Gamepads()), 2; got != want {
+			t.Errorf("len(Gamepads()) (virtual first: %t): got: %d, want: %d", virtualFirst, got, want)
+		}
+		if !physical.Closed {
+			t.Errorf("the physical gamepad must be closed (virtual first: %t)", virtualFirst)
+		}
+		if other.Closed {
+			t.Errorf("the other gamepad must not be closed (virtual first: %t)", virtualFirst)
+		}
+		if got, want := g.HiddenPaths(), []string{"/dev/input/event-physical"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("HiddenPaths() (virtual first: %t): got: %v, want: %v", virtualFirst, got, want)
+		}
+
+		// The hidden gamepad is kept hidden while the virtual gamepad exists.
+		g.RestoreHiddenGamepads()
+		if got, want := len(g.HiddenPaths()), 1; got != want {
+			t.Errorf("len(HiddenPaths()) with the virtual gamepad (virtual first: %t): got: %d, want: %d", virtualFirst, got, want)
+		}
+
+		// Removing the virtual gamepad brings the physical gamepad back.
+		virtual.Disconnected = true
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+		g.RestoreHiddenGamepads()
+		if got, want := len(g.HiddenPaths()), 0; got != want {
+			t.Errorf("len(HiddenPaths()) without the virtual gamepad (virtual first: %t): got: %d, want: %d", virtualFirst, got, want)
+		}
+	}
+}
+
+// appendInotifyEvent appends the bytes of an inotify event to buf. The name is padded with null bytes to nameLen bytes.
+func appendInotifyEvent(buf []byte, mask uint32, name string, nameLen int) []byte {
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:], 1)
+	binary.LittleEndian.PutUint32(header[4:], mask)
+	binary.LittleEndian.PutUint32(header[12:], uint32(nameLen))
+	buf = append(buf, header...)
+	n := make([]byte, nameLen)
+	copy(n, name)
+	return append(buf, n...)
+}
+
+func TestParseInotifyEvents(t *testing.T) {
+	var twoEvents []byte
+	twoEvents = appendInotifyEvent(twoEvents, unix.IN_CREATE, "event3", 16)
+	twoEvents = appendInotifyEvent(twoEvents, unix.IN_DELETE, "event12", 16)
+
+	testCases := []struct {
+		Name string
+		Buf  []byte
+		Want []gamepad.InotifyEventForTesting
+	}{
+		{
+			Name: "empty",
+		},
+		{
+			Name: "padded name",
+			Buf:  appendInotifyEvent(nil, unix.IN_CREATE, "event3", 16),
+			Want: []gamepad.InotifyEventForTesting{
+				{Mask: unix.IN_CREATE, Name: "event3"},
+			},
+		},
+		{
+			Name: "name without padding",
+			Buf:  appendInotifyEvent(nil, unix.IN_ATTRIB, "js0", 4),
+			Want: []gamepad.InotifyEventForTesting{
+				{Mask: unix.IN_ATTRIB, Name: "js0"},
+			},
+		},
+		{
+			Name: "zero-length name",
+			Buf:  appendInotifyEvent(appendInotifyEvent(nil, unix.IN_IGNORED, "", 0), unix.IN_CREATE, "event4", 8),
+			Want: []gamepad.InotifyEventForTesting{
+				{Mask: unix.IN_IGNORED},
+				{Mask: unix.IN_CREATE, Name: "event4"},
+			},
+		},
+		{
+			Name: "multiple events",
+			Buf:  twoEvents,
+			Want: []gamepad.InotifyEventForTesting{
+				{Mask: unix.IN_CREATE, Name: "event3"},
+				{Mask: unix.IN_DELETE, Name: "event12"},
+			},
+		},
+		{
+			Name: "truncated name",
+			Buf:  twoEvents[:len(twoEvents)-1],
+			Want: []gamepad.InotifyEventForTesting{
+				{Mask: unix.IN_CREATE, Name: "event3"},
+			},
+		},
+		{
+			Name: "truncated header",
+			Buf:  twoEvents[:32+8],
+			Want: []gamepad.InotifyEventForTesting{
+				{Mask: unix.IN_CREATE, Name: "event3"},
+			},
+		},
+		{
+			Name: "too long name",
+			Buf:  appendInotifyEvent(nil, unix.IN_CREATE, "event3", 16)[:20],
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			got := gamepad.ParseInotifyEventsForTesting(tc.Buf)
+			if !reflect.DeepEqual(got, tc.Want) {
+				t.Errorf("got: %v, want: %v", got, tc.Want)
+			}
+		})
+	}
+}
+
+func TestParseInotifyEventsFromMultipleReads(t *testing.T) {
+	// A burst of events is read by multiple reads. Each read returns only whole events.
+	var reads [][]byte
+	var want []gamepad.InotifyEventForTesting
+	for i := 0; i < 3; i++ {
+		var buf []byte
+		for j := 0; j < 100; j++ {
+			name := fmt.Sprintf("event%d", i*100+j)
+			mask := uint32(unix.IN_CREATE)
+			if j%2 == 1 {
+				mask = unix.IN_DELETE
+			}
+			buf = appendInotifyEvent(buf, mask, name, 16)
+			want = append(want, gamepad.InotifyEventForTesting{Mask: mask, Name: name})
+		}
+		reads = append(reads, buf)
+	}
+	reads = append(reads, appendInotifyEvent(nil, unix.IN_Q_OVERFLOW, "", 0))
+	want = append(want, gamepad.InotifyEventForTesting{Mask: unix.IN_Q_OVERFLOW})
+
+	got := gamepad.ParseInotifyEventsFromReadsForTesting(reads)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestMotionSensorValue(t *testing.T) {
+	cases := []struct {
+		Axis       gamepad.MotionSensorAxis
+		Value      int32
+		Resolution int32
+		Want       float64
+	}{
+		{
+			Axis:       gamepad.MotionSensorAxisAccelerometerZ,
+			Value:      8192,
+			Resolution: 8192,
+			Want:       9.80665,
+		},
+		{
+			Axis:       gamepad.MotionSensorAxisGyroscopeX,
+			Value:      -1024 * 180,
+			Resolution: 1024,
+			Want:       -math.Pi,
+		},
+		{
+			Axis:       gamepad.MotionSensorAxisGyroscopeY,
+			Value:      100,
+			Resolution: 0,
+			Want:       0,
+		},
+	}
+	for _, c := range cases {
+		if got := gamepad.MotionSensorValueForTesting(c.Axis, c.Value, c.Resolution); math.Abs(got-c.Want) > 1e-9 {
+			t.Errorf("motionSensorValue(%d, %d, %d): got: %f, want: %f", c.Axis, c.Value, c.Resolution, got, c.Want)
+		}
+	}
+}
+
+func TestSDLGUIDMatchesDatabaseWithoutCRC(t *testing.T) {
+	// The embedded database has the mapping for Xbox 360 Controller on Linux without the CRC.
+	id := gamepad.SDLGUIDForTesting(0x03, 0x045e, 0x028e, 0x0114, "Microsoft X-Box 360 pad", 0, 0)
+	if got, want := id, "030081b85e0400008e02000014010000"; got != want {
+		t.Fatalf("got: %s, want: %s", got, want)
+	}
+	if !gamepaddb.HasStandardLayoutMapping(id) {
+		t.Errorf("HasStandardLayoutMapping(%q) should be true", id)
+	}
+	if got, want := gamepaddb.Name(id), "Xbox 360 Controller"; got != want {
+		t.Errorf("Name(%q): got: %q, want: %q", id, got, want)
+	}
+}
@@ -30,7 +30,7 @@ import (
 
 type nativeGamepadsImpl struct {
 	gamepads []C.struct_Gamepad
-	ids      map[int]struct{}
+	keys     map[uint64]struct{}
 }
 
 func newNativeGamepadsImpl() nativeGamepads {
@@ -54,22 +54,27 @@ func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
 		C.ebitengine_GetGamepads(&g.gamepads[0])
 	}
 
-	for id := range g.ids {
-		delete(g.ids, id)
+	for key := range g.keys {
+		delete(g.keys, key)
 	}
 
 	for _, gp := range g.gamepads {
-		if g.ids == nil {
-			g.ids = map[int]struct{}{}
+		if g.keys == nil {
+			g.keys = map[uint64]struct{}{}
 		}
-		g.ids[int(gp.id)] = struct{}{}
+		key := nintendoSDKGamepadKey(&gp)
+		g.keys[key] = struct{}{}
 
+		// Find the gamepad by the key instead of the player number, so that the same Gamepad is used
+		// even after the controller applet reassigns the player numbers.
 		gamepad := gamepads.find(func(gamepad *Gamepad) bool {
-			return gamepad.native.(*nativeGamepadImpl).id == int(gp.id)
+			n, ok := gamepad.native.(*nativeGamepadImpl)
+			return ok && n.key == key
 		})
 		if gamepad == nil {
 			gamepad = gamepads.add("", "")
 			gamepad.native = &nativeGamepadImpl{
+				key:           key,
 				id:            int(gp.id),
 				standard:      bool(gp.standard != 0),
 				axisValues:    make([]float64, gp.axis_count),
@@ -80,11 +85,12 @@ func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
 
 		gamepad.m.Lock()
 		n := gamepad.native.(*nativeGamepadImpl)
+		n.id = int(gp.id)
 		for i := range n.axisValues {
-			n.axisValues[i] = float64(gp.axis_values[i])
+			n.axisValues[i] = clampFloat64(float64(gp.axis_values[i]), -1, 1)
 		}
 		for i := range n.buttonValues {
-			n.buttonValues[i] = float64(gp.button_values[i])
+			n.buttonValues[i] = clampFloat64(float64(gp.button_values[i]), 0, 1)
 		}
 		for i := range n.buttonPressed {
 			n.buttonPressed[i] = gp.button_pressed[i] != 0
@@ -94,14 +100,41 @@ func (g *nativeGamepadsImpl) update(gamepads *gamepads) error {
 
 	// Remove an unused gamepads.
 	gamepads.remove(func(gamepad *Gamepad) bool {
-		_, ok := g.ids[gamepad.native.(*nativeGamepadImpl).id]
+		n, ok := gamepad.native.(*nativeGamepadImpl)
+		if !ok {
+			return false
+		}
+		_, ok = g.keys[n.key]
 		return !ok
 	})
 
 	return nil
 }
 
+// nintendoSDKGamepadKey returns a key to identify the physical controller.
+func nintendoSDKGamepadKey(gamepad *C.struct_Gamepad) uint64 {
+	if gamepad.device_id != 0 {
+		return uint64(gamepad.device_id)
+	}
+	// Use the player number with the highest bit so that the key never conflicts with device IDs in practice.
+	return 1<<63 | uint64(uint32(gamepad.id))
+}
+
+func clampFloat64(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
 type nativeGamepadImpl struct {
+	// key identifies the physical controller.
+	key uint64
+
+	// id is the player number, which can change while the controller is connected.
 	id       int
 	standard bool
 
@@ -118,16 +151,22 @@ func (g *nativeGamepadImpl) hasOwnStandardLayoutMapping() bool {
 	return g.standard
 }
 
+// standardAxisInOwnMapping returns the axis as it is, as the C side reports the axes in the standard layout order.
 func (g *nativeGamepadImpl) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
-	// TODO: Implement this on the C side.
+	if !g.standard {
+		return nil
+	}
 	if axis < 0 || int(axis) >= len(g.axisValues) {
 		return nil
 	}
 	return axisMappingInput{g: g, axis: int(axis)}
 }
 
+// standardButtonInOwnMapping returns the button as it is, as the C side reports the buttons in the standard layout order.
 func (g *nativeGamepadImpl) standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput {
-	// TODO: Implement this on the C side.
+	if !g.standard {
+		return nil
+	}
 	if button < 0 || int(button) >= len(g.buttonValues) {
 		return nil
 	}
@@ -172,5 +211,17 @@ func (*nativeGamepadImpl) hatState(hat int) int {
 }
 
 func (g *nativeGamepadImpl) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
-	C.ebitengine_VibrateGamepad(C.int(g.id), C.double(float64(duration)/float64(time.Second)), C.double(strongMagnitude), C.double(weakMagnitude))
+	// The strong and weak magnitudes are used for the low and high frequency amplitudes of HD rumble respectively.
+	C.ebitengine_VibrateGamepad(C.int(g.id), C.double(float64(duration)/float64(time.Second)), C.double(clampFloat64(strongMagnitude, 0, 1)), C.double(clampFloat64(weakMagnitude, 0, 1)))
+}
+
+func (g *nativeGamepadImpl) setPlayerIndex(index int) {
+}
+
+func (g *nativeGamepadImpl) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (g *nativeGamepadImpl) powerState() PowerState {
+	return PowerStateUnknown
 }
@@ -14,6 +14,15 @@
 
 //go:build nintendosdk
 
+// Gamepad is the state of a gamepad.
+//
+// id is the player number, which can be reassigned by the controller applet.
+// device_id is an identifier of the physical controller, which must be stable while the controller is connected
+// even when the player number is reassigned. If device_id is 0, id is used to identify the controller instead.
+//
+// If standard is true, button_pressed and button_values are indexed by the standard buttons, and axis_values is
+// indexed by the standard axes, in the same order as the W3C standard gamepad.
+// The axis values are in between -1 and 1, where -1 means left or up. The button values are in between 0 and 1.
 struct Gamepad {
   int id;
   char standard;
@@ -22,6 +31,7 @@ struct Gamepad {
   char button_pressed[32];
   float button_values[32];
   float axis_values[16];
+  unsigned long long device_id;
 };
 
 #ifdef __cplusplus
@@ -31,6 +41,10 @@ extern "C" {
 void ebitengine_UpdateGamepads();
 int ebitengine_GetGamepadCount();
 void ebitengine_GetGamepads(struct Gamepad *gamepads);
+
+// ebitengine_VibrateGamepad vibrates the gamepad with HD rumble.
+// strongMagnitude is the amplitude of the low frequency, and weakMagnitude is the amplitude of the high frequency.
+// The magnitudes are in between 0 and 1. The vibration stops after durationInSeconds.
 void ebitengine_VibrateGamepad(int id, double durationInSeconds,
                                double strongMagnitude, double weakMagnitude);
 
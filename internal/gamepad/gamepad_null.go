@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:build !darwin && !js && !linux && !windows
+//go:build !darwin && !freebsd && !js && !linux && !openbsd && !windows
 
 package gamepad
 
@@ -84,3 +84,14 @@ func (*nativeGamepadImpl) hatState(hat int) int {
 
 func (g *nativeGamepadImpl) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
 }
+
+func (g *nativeGamepadImpl) setPlayerIndex(index int) {
+}
+
+func (g *nativeGamepadImpl) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (g *nativeGamepadImpl) powerState() PowerState {
+	return PowerStateUnknown
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !ios
+
+package gamepad
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+)
+
+var (
+	// hidInputReportCallback is the callback for all the devices, as a purego callback cannot be released.
+	hidInputReportCallback     uintptr
+	hidInputReportCallbackOnce sync.Once
+
+	// hidDevicesForCallback is the devices that receive input reports, keyed by the device references.
+	hidDevicesForCallback  = map[_IOHIDDeviceRef]*iokitHIDDevice{}
+	hidDevicesForCallbackM sync.Mutex
+)
+
+func ebitenGamepadInputReportCallback(ctx unsafe.Pointer, res _IOReturn, sender _IOHIDDeviceRef, typ _IOHIDReportType, reportID uint32, report *byte, reportLength _CFIndex) {
+	if res != kIOReturnSuccess || reportLength <= 0 {
+		return
+	}
+
+	hidDevicesForCallbackM.Lock()
+	d, ok := hidDevicesForCallback[sender]
+	hidDevicesForCallbackM.Unlock()
+	if !ok {
+		return
+	}
+
+	// The report is valid only during the callback. Copy it.
+	d.push(append([]byte(nil), unsafe.Slice(report, reportLength)...))
+}
+
+// iokitHIDDevice is a raw HID device via IOKit.
+// The input reports are received via a callback on the run loop, and are queued until they are read.
+type iokitHIDDevice struct {
+	device _IOHIDDeviceRef
+
+	// buf is the buffer for the input reports. IOKit keeps using this while the callback is registered.
+	buf []byte
+
+	featureSize int
+
+	reports [][]byte
+	m       sync.Mutex
+}
+
+func newIOKitHIDDevice(device _IOHIDDeviceRef) *iokitHIDDevice {
+	hidInputReportCallbackOnce.Do(func() {
+		hidInputReportCallback = purego.NewCallback(ebitenGamepadInputReportCallback)
+	})
+
+	size := int(hidDeviceUint32Property(device, kIOHIDMaxInputReportSizeKey))
+	if size < 64 {
+		size = 64
+	}
+	featureSize := int(hidDeviceUint32Property(device, kIOHIDMaxFeatureReportSizeKey))
+	if featureSize < 64 {
+		featureSize = 64
+	}
+
+	d := &iokitHIDDevice{
+		device:      device,
+		buf:         make([]byte, size),
+		featureSize: featureSize,
+	}
+
+	hidDevicesForCallbackM.Lock()
+	hidDevicesForCallback[device] = d
+	hidDevicesForCallbackM.Unlock()
+
+	_IOHIDDeviceRegisterInputReportCallback(device, &d.buf[0], _CFIndex(len(d.buf)), hidInputReportCallback, nil)
+	return d
+}
+
+func (d *iokitHIDDevice) push(report []byte) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if len(d.reports) >= maxQueuedHIDReports {
+		d.reports = d.reports[1:]
+	}
+	d.reports = append(d.reports, report)
+}
+
+func (d *iokitHIDDevice) read() ([]byte, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if len(d.reports) == 0 {
+		return nil, nil
+	}
+	report := d.reports[0]
+	d.reports = d.reports[1:]
+	return report, nil
+}
+
+func (d *iokitHIDDevice) write(report []byte) error {
+	if r := _IOHIDDeviceSetReport(d.device, kIOHIDReportTypeOutput, _CFIndex(report[0]), &report[0], _CFIndex(len(report))); r != kIOReturnSuccess {
+		return fmt.Errorf("gamepad: IOHIDDeviceSetReport failed: %d", r)
+	}
+	return nil
+}
+
+func (d *iokitHIDDevice) getFeature(reportID byte) ([]byte, error) {
+	buf := make([]byte, d.featureSize)
+	buf[0] = reportID
+	length := _CFIndex(len(buf))
+	if r := _IOHIDDeviceGetReport(d.device, kIOHIDReportTypeFeature, _CFIndex(reportID), &buf[0], &length); r != kIOReturnSuccess {
+		return nil, fmt.Errorf("gamepad: IOHIDDeviceGetReport failed: %d", r)
+	}
+	return buf[:length], nil
+}
+
+func (d *iokitHIDDevice) close() {
+	hidDevicesForCallbackM.Lock()
+	defer hidDevicesForCallbackM.Unlock()
+
+	if _, ok := hidDevicesForCallback[d.device]; !ok {
+		return
+	}
+	delete(hidDevicesForCallback, d.device)
+	_IOHIDDeviceRegisterInputReportCallback(d.device, &d.buf[0], _CFIndex(len(d.buf)), 0, nil)
+}
+
+// addSonyDevice adds a DualSense handled via raw HID reports.
+// Unlike IOKit HID elements, raw HID reports enable the rumble and the lightbar via output reports.
+// Via Bluetooth, the input reports are switched to the extended format after an output report is sent,
+// and the extended format is not described as HID elements.
+func (g *nativeGamepadsImpl) addSonyDevice(device _IOHIDDeviceRef, gamepads *gamepads, vendor, product, version uint16, model sonyModel, busType BusType) {
+	serial, _ := hidDeviceStringProperty(device, kIOHIDSerialNumberKey)
+	n := newNativeGamepadSony(newIOKitHIDDevice(device), sonyDeviceInfo{
+		vendor:    vendor,
+		product:   product,
+		version:   version,
+		serial:    serial,
+		bluetooth: busType == BusTypeBluetooth,
+	}, model)
+
+	// The serial number is the Bluetooth address or the USB serial number, which identifies the physical gamepad.
+	gp := gamepads.addWithReconnectionKey(n.name(), n.sdlID(), serial)
+	gp.native = n
+}
+
+// iokitDevice returns the IOKit device reference of the gamepad, or 0 if the gamepad is not an IOKit HID device.
+func iokitDevice(gamepad *Gamepad) _IOHIDDeviceRef {
+	switch n := gamepad.native.(type) {
+	case *nativeGamepadImpl:
+		return n.device
+	case *nativeGamepadSony:
+		if d, ok := n.device.(*iokitHIDDevice); ok {
+			return d.device
+		}
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// sonyGamepads is the HID backend for DualShock 4 and DualSense.
+// Via DirectInput, these gamepads don't support rumble nor the lightbar, and their triggers are reported as both buttons and axes.
+type sonyGamepads struct{}
+
+func newSonyGamepads() (*sonyGamepads, error) {
+	if !isHIDAvailable() {
+		return nil, errors.New("gamepad: hid.dll is not available")
+	}
+	return &sonyGamepads{}, nil
+}
+
+// detectConnection opens the Sony gamepads that are not opened yet.
+func (s *sonyGamepads) detectConnection(gamepads *gamepads) error {
+	paths, err := windows.CM_Get_Device_Interface_List("", &_GUID_DEVINTERFACE_HID, windows.CM_GET_DEVICE_INTERFACE_LIST_PRESENT)
+	if err != nil {
+		return fmt.Errorf("gamepad: CM_Get_Device_Interface_List failed: %w", err)
+	}
+
+	// The order of the device interfaces is not stable. Sort them so that identical gamepads get the same IDs across sessions.
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		// Skip the other vendors' devices without opening them. A path is like "\\?\hid#vid_054c&pid_09cc..." for USB
+		// or "\\?\hid#{00001124-0000-1000-8000-00805f9b34fb}_vid&0002054c_pid&09cc..." for Bluetooth.
+		if !strings.Contains(strings.ToLower(path), "054c") {
+			continue
+		}
+		if gamepads.find(func(g *Gamepad) bool {
+			n, ok := g.native.(*nativeGamepadSony)
+			return ok && strings.EqualFold(n.info.path, path)
+		}) != nil {
+			continue
+		}
+
+		// Opening the device can fail e.g. when another application opens it exclusively.
+		// In this case, the device is treated as a DirectInput device.
+		d, err := openHIDDevice(path)
+		if err != nil {
+			continue
+		}
+		model, ok := sonyGamepadModel(d.attrs.VendorID, d.attrs.ProductID)
+		if !ok {
+			d.close()
+			continue
+		}
+
+		// The device path identifies the physical gamepad, e.g., by the Bluetooth address or the USB port.
+		// Use this as the reconnection key so that the gamepad ID is attached to the physical gamepad.
+		n := newNativeGamepadSony(d, sonyDeviceInfo{
+			vendor:  d.attrs.VendorID,
+			product: d.attrs.ProductID,
+			version: d.attrs.VersionNumber,
+			path:    path,
+			serial:  strings.ToLower(path),
+			// The input reports via Bluetooth are longer than the 64 bytes via USB.
+			bluetooth: d.caps.InputReportByteLength > 64,
+		}, model)
+		gp := gamepads.addWithReconnectionKey(n.name(), n.sdlID(), n.serial())
+		gp.native = n
+	}
+	return nil
+}
+
+// isOpened reports whether a Sony gamepad with the given vendor and product IDs is opened by this backend.
+func (s *sonyGamepads) isOpened(gamepads *gamepads, vendor, product uint16) bool {
+	return gamepads.find(func(g *Gamepad) bool {
+		n, ok := g.native.(*nativeGamepadSony)
+		return ok && n.info.vendor == vendor && n.info.product == product
+	}) != nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !android && !nintendosdk && !playstation5
+
+package gamepad
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// hidrawDevice is a hidraw device opened in the non-blocking mode.
+type hidrawDevice struct {
+	fd  int
+	buf [512]byte
+}
+
+func (d *hidrawDevice) read() ([]byte, error) {
+	n, err := unix.Read(d.fd, d.buf[:])
+	if err != nil {
+		if err == unix.EAGAIN {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("gamepad: Read failed: %w", err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return d.buf[:n], nil
+}
+
+func (d *hidrawDevice) write(report []byte) error {
+	if _, err := unix.Write(d.fd, report); err != nil {
+		return fmt.Errorf("gamepad: Write failed: %w", err)
+	}
+	return nil
+}
+
+func (d *hidrawDevice) close() {
+	if d.fd != 0 {
+		_ = unix.Close(d.fd)
+	}
+	d.fd = 0
+}
+
+// openSwitchProController opens the Switch Pro Controller whose evdev device is at path via its hidraw device.
+//
+// The kernel driver hid-nintendo handles the controller well, and the evdev device is used in this case.
+// Without hid-nintendo, the generic HID driver doesn't calibrate the sticks, and supports neither rumble nor the motion sensors.
+//
+// openSwitchProController returns false if the evdev device should be used.
+// A failure to open the hidraw device, e.g. due to the permission, is recorded as a device error.
+func (g *nativeGamepadsImpl) openSwitchProController(gamepads *gamepads, path, parentPath, serial string, id input_id) bool {
+	if driver, err := filepath.EvalSymlinks(filepath.Join(parentPath, "driver")); err == nil && filepath.Base(driver) == "nintendo" {
+		return false
+	}
+
+	// The hidraw device is found in the sysfs directory of the HID device, e.g., /sys/devices/.../0003:057E:2009.0001/hidraw/hidraw0.
+	matches, err := filepath.Glob(filepath.Join(parentPath, "hidraw", "hidraw*"))
+	if err != nil || len(matches) == 0 {
+		return false
+	}
+	hidrawPath := filepath.Join("/dev", filepath.Base(matches[0]))
+
+	fd, err := unix.Open(hidrawPath, unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		gamepads.addDeviceError(DeviceError{
+			Path: hidrawPath,
+			Name: switchProName,
+			Err:  fmt.Errorf("gamepad: Open failed and the evdev device %s is used instead: %w", path, err),
+		})
+		return false
+	}
+
+	d := &hidrawDevice{fd: fd}
+	n, err := newNativeGamepadSwitchPro(d, path, id.bustype == _BUS_BLUETOOTH)
+	if err != nil {
+		d.close()
+		gamepads.addDeviceError(DeviceError{
+			Path: hidrawPath,
+			Name: switchProName,
+			Err:  fmt.Errorf("gamepad: the evdev device %s is used instead: %w", path, err),
+		})
+		return false
+	}
+
+	var key string
+	if serial != "" {
+		key = fmt.Sprintf("%04x:%04x:%s", id.vendor, id.product, serial)
+	}
+	gp := gamepads.addWithReconnectionKey(switchProName, n.sdlID(id.version), key)
+	gp.native = n
+	return true
+}
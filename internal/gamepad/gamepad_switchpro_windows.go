@@ -0,0 +1,123 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// switchProGamepads is the HID backend for Switch Pro Controllers.
+// Via DirectInput, the sticks are not calibrated, and neither rumble nor the motion sensors are available.
+type switchProGamepads struct {
+	// failedPaths is the set of the device paths that failed to be opened, in lower case.
+	// These devices are treated as DirectInput devices until they are disconnected.
+	failedPaths map[string]struct{}
+}
+
+func newSwitchProGamepads() (*switchProGamepads, error) {
+	if !isHIDAvailable() {
+		return nil, errors.New("gamepad: hid.dll is not available")
+	}
+	return &switchProGamepads{}, nil
+}
+
+// detectConnection opens the Switch Pro Controllers that are not opened yet.
+func (s *switchProGamepads) detectConnection(gamepads *gamepads) error {
+	paths, err := windows.CM_Get_Device_Interface_List("", &_GUID_DEVINTERFACE_HID, windows.CM_GET_DEVICE_INTERFACE_LIST_PRESENT)
+	if err != nil {
+		return fmt.Errorf("gamepad: CM_Get_Device_Interface_List failed: %w", err)
+	}
+
+	// The order of the device interfaces is not stable. Sort them so that identical gamepads get the same IDs across sessions.
+	sort.Strings(paths)
+
+	present := map[string]struct{}{}
+	for _, path := range paths {
+		lowerPath := strings.ToLower(path)
+		present[lowerPath] = struct{}{}
+
+		// Skip the other devices without opening them. See sonyGamepads.detectConnection for the formats of the paths.
+		if !strings.Contains(lowerPath, "057e") || !strings.Contains(lowerPath, "2009") {
+			continue
+		}
+		if _, ok := s.failedPaths[lowerPath]; ok {
+			continue
+		}
+		if gamepads.find(func(g *Gamepad) bool {
+			n, ok := g.native.(*nativeGamepadSwitchPro)
+			return ok && n.path == lowerPath
+		}) != nil {
+			continue
+		}
+
+		d, err := openHIDDevice(path)
+		if err != nil {
+			s.addFailedPath(gamepads, lowerPath, err)
+			continue
+		}
+		if !isSwitchProController(d.attrs.VendorID, d.attrs.ProductID) {
+			d.close()
+			continue
+		}
+
+		bluetooth := strings.Contains(lowerPath, "{00001124-0000-1000-8000-00805f9b34fb}")
+		n, err := newNativeGamepadSwitchPro(d, lowerPath, bluetooth)
+		if err != nil {
+			d.close()
+			s.addFailedPath(gamepads, lowerPath, err)
+			continue
+		}
+
+		// The device path identifies the physical gamepad, e.g., by the Bluetooth address or the USB port.
+		gp := gamepads.addWithReconnectionKey(switchProName, n.sdlID(d.attrs.VersionNumber), lowerPath)
+		gp.native = n
+	}
+
+	for path := range s.failedPaths {
+		if _, ok := present[path]; !ok {
+			delete(s.failedPaths, path)
+		}
+	}
+	return nil
+}
+
+// addFailedPath records the failure to open the device. The device is used via DirectInput instead.
+func (s *switchProGamepads) addFailedPath(gamepads *gamepads, path string, err error) {
+	if s.failedPaths == nil {
+		s.failedPaths = map[string]struct{}{}
+	}
+	s.failedPaths[path] = struct{}{}
+	gamepads.addDeviceError(DeviceError{
+		Path: path,
+		Name: switchProName,
+		Err:  fmt.Errorf("gamepad: the raw HID access failed and DirectInput is used instead: %w", err),
+	})
+}
+
+// isOpened reports whether a Switch Pro Controller with the given vendor and product IDs is opened by this backend.
+func (s *switchProGamepads) isOpened(gamepads *gamepads, vendor, product uint16) bool {
+	if !isSwitchProController(vendor, product) {
+		return false
+	}
+	return gamepads.find(func(g *Gamepad) bool {
+		_, ok := g.native.(*nativeGamepadSwitchPro)
+		return ok
+	}) != nil
+}
@@ -0,0 +1,1415 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad_test
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepad"
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
+)
+
+func TestReconnection(t *testing.T) {
+	g := gamepad.NewGamepadsForTesting(time.Hour)
+
+	id0 := g.Add("foo")
+	id1 := g.Add("")
+	if id0 == id1 {
+		t.Fatalf("IDs must be different: %d, %d", id0, id1)
+	}
+
+	g.Remove(id0)
+	if got := g.AppendGamepadIDs(nil); len(got) != 1 || got[0] != id1 {
+		t.Errorf("AppendGamepadIDs(): got: %v, want: [%d]", got, id1)
+	}
+
+	// The ID of the disconnected gamepad is reserved.
+	if id := g.Add("bar"); id == id0 {
+		t.Errorf("Add(%q): got: %d, want: not %d", "bar", id, id0)
+	}
+	if id := g.Add(""); id == id0 {
+		t.Errorf("Add(%q): got: %d, want: not %d", "", id, id0)
+	}
+
+	// The reconnected gamepad gets the same ID.
+	if id := g.Add("foo"); id != id0 {
+		t.Errorf("Add(%q): got: %d, want: %d", "foo", id, id0)
+	}
+}
+
+func TestReconnectionWithoutGracePeriod(t *testing.T) {
+	g := gamepad.NewGamepadsForTesting(0)
+
+	id0 := g.Add("foo")
+	g.Remove(id0)
+
+	// The ID is not reserved and can be used for another gamepad.
+	if id := g.Add("bar"); id != id0 {
+		t.Errorf("Add(%q): got: %d, want: %d", "bar", id, id0)
+	}
+	if id := g.Add("foo"); id == id0 {
+		t.Errorf("Add(%q): got: %d, want: not %d", "foo", id, id0)
+	}
+}
+
+func TestPlayerIndex(t *testing.T) {
+	g := gamepad.NewGamepadsForTesting(time.Hour)
+
+	// Player indices are assigned in the connection order.
+	id0 := g.Add("foo")
+	id1 := g.Add("bar")
+	if got, want := g.PlayerIndex(id0), 0; got != want {
+		t.Errorf("PlayerIndex(%d): got: %d, want: %d", id0, got, want)
+	}
+	if got, want := g.PlayerIndex(id1), 1; got != want {
+		t.Errorf("PlayerIndex(%d): got: %d, want: %d", id1, got, want)
+	}
+
+	// The reconnected gamepad gets the same player index.
+	g.Remove(id0)
+	id0 = g.Add("foo")
+	if got, want := g.PlayerIndex(id0), 0; got != want {
+		t.Errorf("PlayerIndex(%d): got: %d, want: %d", id0, got, want)
+	}
+}
+
+func TestConnectionEvents(t *testing.T) {
+	g := gamepad.NewGamepadsForTesting(time.Hour)
+
+	id0 := g.Add("foo")
+	id1 := g.Add("bar")
+	g.FlushConnectionEvents()
+	events := g.AppendConnectionEvents(nil)
+	if got, want := len(events), 2; got != want {
+		t.Fatalf("len(events): got: %d, want: %d", got, want)
+	}
+	if events[0].ID != id0 || !events[0].Connected {
+		t.Errorf("events[0]: got: %v, want: a connection of %d", events[0], id0)
+	}
+	if events[1].ID != id1 || !events[1].Connected {
+		t.Errorf("events[1]: got: %v, want: a connection of %d", events[1], id1)
+	}
+
+	// The events are reported only for one update.
+	g.Remove(id0)
+	g.FlushConnectionEvents()
+	events = g.AppendConnectionEvents(events[:0])
+	if got, want := len(events), 1; got != want {
+		t.Fatalf("len(events): got: %d, want: %d", got, want)
+	}
+	if events[0].ID != id0 || events[0].Connected {
+		t.Errorf("events[0]: got: %v, want: a disconnection of %d", events[0], id0)
+	}
+
+	// A gamepad removed just after being added is not reported.
+	id2 := g.Add("baz")
+	g.Remove(id2)
+	g.FlushConnectionEvents()
+	if got, want := len(g.AppendConnectionEvents(nil)), 0; got != want {
+		t.Errorf("len(events): got: %d, want: %d", got, want)
+	}
+}
+
+func TestAxisCalibration(t *testing.T) {
+	testCases := []struct {
+		Name        string
+		Calibration gamepad.AxisCalibration
+		In          float64
+		Want        float64
+	}{
+		{
+			Name:        "asymmetric stick, max",
+			Calibration: gamepad.AxisCalibration{Min: -1, Center: 0, Max: 0.8},
+			In:          0.8,
+			Want:        1,
+		},
+		{
+			Name:        "asymmetric stick, half",
+			Calibration: gamepad.AxisCalibration{Min: -1, Center: 0, Max: 0.8},
+			In:          0.4,
+			Want:        0.5,
+		},
+		{
+			Name:        "asymmetric stick, min",
+			Calibration: gamepad.AxisCalibration{Min: -1, Center: 0, Max: 0.8},
+			In:          -1,
+			Want:        -1,
+		},
+		{
+			Name:        "off-center stick",
+			Calibration: gamepad.AxisCalibration{Min: -0.9, Center: 0.1, Max: 1},
+			In:          0.1,
+			Want:        0,
+		},
+		{
+			Name:        "beyond the range",
+			Calibration: gamepad.AxisCalibration{Min: -0.5, Center: 0, Max: 0.5},
+			In:          0.75,
+			Want:        1,
+		},
+		{
+			Name:        "trigger, rest",
+			Calibration: gamepad.AxisCalibration{Min: -1, Center: -0.98, Max: 0.6},
+			In:          -1,
+			Want:        -1,
+		},
+		{
+			Name:        "trigger, max",
+			Calibration: gamepad.AxisCalibration{Min: -1, Center: -0.98, Max: 0.6},
+			In:          0.6,
+			Want:        1,
+		},
+		{
+			Name:        "empty range",
+			Calibration: gamepad.AxisCalibration{Min: 0.2, Center: 0.2, Max: 0.2},
+			In:          0.5,
+			Want:        0.5,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			if got := tc.Calibration.ApplyForTesting(tc.In); math.Abs(got-tc.Want) > 1e-9 {
+				t.Errorf("got: %f, want: %f", got, tc.Want)
+			}
+		})
+	}
+}
+
+func TestRumble(t *testing.T) {
+	type call struct {
+		Low  uint16
+		High uint16
+	}
+	var calls []call
+	r := gamepad.NewRumbleForTesting(func(low, high uint16) error {
+		calls = append(calls, call{Low: low, High: high})
+		return nil
+	})
+
+	now := time.Now()
+
+	// Updating or stopping without a playing effect doesn't set the motors.
+	if err := r.Update(now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The magnitudes are scaled and clamped.
+	if err := r.Start(now, 100*time.Millisecond, 1.5, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	// The motors keep running before the duration elapses.
+	if err := r.Update(now.Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	// The motors are stopped after the duration elapses, only once.
+	if err := r.Update(now.Add(100 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Update(now.Add(200 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new effect extends the expiry time.
+	if err := r.Start(now, 100*time.Millisecond, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(now.Add(50*time.Millisecond), 100*time.Millisecond, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Update(now.Add(120 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	// An effect with zero magnitudes or a zero duration stops the motors.
+	if err := r.Start(now.Add(130*time.Millisecond), 100*time.Millisecond, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(now.Add(140*time.Millisecond), 100*time.Millisecond, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(now.Add(150*time.Millisecond), 0, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stopping explicitly, e.g. on disconnection or losing focus, stops the motors.
+	if err := r.Start(now.Add(160*time.Millisecond), 100*time.Millisecond, 0.25, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Update(now.Add(300 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []call{
+		{Low: 0xffff, High: 0x7fff},
+		{Low: 0, High: 0},
+		{Low: 0, High: 0xffff},
+		{Low: 0xffff, High: 0},
+		{Low: 0, High: 0},
+		{Low: 0xffff, High: 0xffff},
+		{Low: 0, High: 0},
+		{Low: 0x3fff, High: 0},
+		{Low: 0, High: 0},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got: %v, want: %v", calls, want)
+	}
+}
+
+func TestInputSnapshot(t *testing.T) {
+	type snapshot struct {
+		Axes    []float64
+		Buttons []float64
+	}
+	type state struct {
+		AxisCount   int
+		ButtonCount int
+		Axis0       float64
+		Pressed     []int
+	}
+
+	testCases := []struct {
+		Name      string
+		Snapshots []snapshot
+		Want      []state
+	}{
+		{
+			Name: "growing and shrinking counts",
+			// Recorded from Firefox with a generic HID gamepad, whose hat switch is reported as an axis only in some frames.
+			Snapshots: []snapshot{
+				{Axes: []float64{0.5, 0}, Buttons: []float64{1, 0, 0, 0}},
+				{Axes: []float64{0.25, 0, 1.28571}, Buttons: []float64{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0}},
+				{Axes: []float64{0, 0}, Buttons: []float64{0, 1}},
+			},
+			Want: []state{
+				{AxisCount: 2, ButtonCount: 4, Axis0: 0.5, Pressed: []int{0}},
+				{AxisCount: 3, ButtonCount: 12, Axis0: 0.25, Pressed: []int{10}},
+				{AxisCount: 2, ButtonCount: 2, Axis0: 0, Pressed: []int{1}},
+			},
+		},
+		{
+			Name: "transient empty snapshot",
+			// Recorded from Firefox during a GC pause. The buttons must not be released by the empty snapshot.
+			Snapshots: []snapshot{
+				{Axes: []float64{-1, 0, 0, 0}, Buttons: []float64{0, 1, 0}},
+				{},
+				{Axes: []float64{-0.5, 0, 0, 0}, Buttons: []float64{0, 1, 1}},
+			},
+			Want: []state{
+				{AxisCount: 4, ButtonCount: 3, Axis0: -1, Pressed: []int{1}},
+				{AxisCount: 4, ButtonCount: 3, Axis0: -1, Pressed: []int{1}},
+				{AxisCount: 4, ButtonCount: 3, Axis0: -0.5, Pressed: []int{1, 2}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			var s gamepad.InputSnapshotForTesting
+			for i, snapshot := range tc.Snapshots {
+				s.Update(snapshot.Axes, snapshot.Buttons)
+
+				want := tc.Want[i]
+				got := state{
+					AxisCount:   s.AxisCount(),
+					ButtonCount: s.ButtonCount(),
+					Axis0:       s.AxisValue(0),
+				}
+				// Reading out of the range must be safe.
+				for b := -1; b <= got.ButtonCount; b++ {
+					if s.IsButtonPressed(b) {
+						got.Pressed = append(got.Pressed, b)
+					}
+				}
+				if s.AxisValue(got.AxisCount) != 0 {
+					t.Errorf("snapshot %d: AxisValue(%d): got: %f, want: 0", i, got.AxisCount, s.AxisValue(got.AxisCount))
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("snapshot %d: got: %+v, want: %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTouchGamepad(t *testing.T) {
+	const (
+		width  = 800
+		height = 600
+	)
+	g := gamepad.NewTouchGamepadForTesting(gamepad.VirtualGamepadElementLeftThumbstick | gamepad.VirtualGamepadElementButtonA)
+
+	// The thumbstick and a button can be used simultaneously.
+	g.Update([]gamepad.VirtualGamepadTouch{
+		{ID: 1, X: 100, Y: 500},
+		{ID: 2, X: 500, Y: 550},
+	}, width, height)
+	if got := g.AxisValue(gamepaddb.StandardAxisLeftStickHorizontal); got != 0 {
+		t.Errorf("left stick horizontal: got: %f, want: 0", got)
+	}
+	if !g.IsButtonPressed(gamepaddb.StandardButtonRightBottom) {
+		t.Errorf("button A: got: false, want: true")
+	}
+
+	// The thumbstick is tilted relative to where the touch started. The radius is 10% of the shorter side, i.e. 60 pixels.
+	g.Update([]gamepad.VirtualGamepadTouch{
+		{ID: 1, X: 130, Y: 500},
+		{ID: 2, X: 500, Y: 550},
+	}, width, height)
+	if got, want := g.AxisValue(gamepaddb.StandardAxisLeftStickHorizontal), 0.5; math.Abs(got-want) > 1e-9 {
+		t.Errorf("left stick horizontal: got: %f, want: %f", got, want)
+	}
+	g.Update([]gamepad.VirtualGamepadTouch{
+		{ID: 1, X: 100, Y: 200},
+	}, width, height)
+	if got, want := g.AxisValue(gamepaddb.StandardAxisLeftStickVertical), -1.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("left stick vertical: got: %f, want: %f", got, want)
+	}
+	if g.IsButtonPressed(gamepaddb.StandardButtonRightBottom) {
+		t.Errorf("button A: got: true, want: false")
+	}
+
+	// A touch moved into the thumbstick's zone doesn't operate the thumbstick.
+	g.Update(nil, width, height)
+	g.Update([]gamepad.VirtualGamepadTouch{
+		{ID: 3, X: 500, Y: 100},
+	}, width, height)
+	g.Update([]gamepad.VirtualGamepadTouch{
+		{ID: 3, X: 100, Y: 500},
+	}, width, height)
+	g.Update([]gamepad.VirtualGamepadTouch{
+		{ID: 3, X: 200, Y: 500},
+	}, width, height)
+	if got := g.AxisValue(gamepaddb.StandardAxisLeftStickHorizontal); got != 0 {
+		t.Errorf("left stick horizontal: got: %f, want: 0", got)
+	}
+
+	// A disabled button is never pressed.
+	g.Update([]gamepad.VirtualGamepadTouch{
+		{ID: 4, X: 700, Y: 550},
+	}, width, height)
+	if g.IsButtonPressed(gamepaddb.StandardButtonRightRight) {
+		t.Errorf("button B: got: true, want: false")
+	}
+}
+
+func TestHIDGamepad(t *testing.T) {
+	desc := []byte{
+		0x05, 0x01, // Usage Page (Generic Desktop)
+		0x09, 0x05, // Usage (Game Pad)
+		0xa1, 0x01, // Collection (Application)
+		0x85, 0x01, //   Report ID (1)
+		0x05, 0x09, //   Usage Page (Button)
+		0x19, 0x01, //   Usage Minimum (1)
+		0x29, 0x0c, //   Usage Maximum (12)
+		0x15, 0x00, //   Logical Minimum (0)
+		0x25, 0x01, //   Logical Maximum (1)
+		0x75, 0x01, //   Report Size (1)
+		0x95, 0x0c, //   Report Count (12)
+		0x81, 0x02, //   Input (Data, Variable, Absolute)
+		0x95, 0x04, //   Report Count (4)
+		0x81, 0x03, //   Input (Constant, Variable, Absolute)
+		0x05, 0x01, //   Usage Page (Generic Desktop)
+		0x09, 0x39, //   Usage (Hat Switch)
+		0x25, 0x07, //   Logical Maximum (7)
+		0x75, 0x04, //   Report Size (4)
+		0x95, 0x01, //   Report Count (1)
+		0x81, 0x42, //   Input (Data, Variable, Absolute, Null State)
+		0x81, 0x03, //   Input (Constant, Variable, Absolute)
+		0x09, 0x31, //   Usage (Y)
+		0x09, 0x30, //   Usage (X)
+		0x15, 0x81, //   Logical Minimum (-127)
+		0x25, 0x7f, //   Logical Maximum (127)
+		0x75, 0x08, //   Report Size (8)
+		0x95, 0x02, //   Report Count (2)
+		0x81, 0x02, //   Input (Data, Variable, Absolute)
+		0x09, 0x32, //   Usage (Z)
+		0x15, 0x00, //   Logical Minimum (0)
+		0x25, 0xff, //   Logical Maximum (255), which is -1 strictly but is used by actual devices
+		0x95, 0x01, //   Report Count (1)
+		0x81, 0x02, //   Input (Data, Variable, Absolute)
+		0x85, 0x02, //   Report ID (2)
+		0x09, 0x33, //   Usage (Rx)
+		0x81, 0x02, //   Input (Data, Variable, Absolute)
+		0xc0, // End Collection
+	}
+
+	g, err := gamepad.NewHIDGamepadForTesting(desc, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.IsGamepad() {
+		t.Errorf("IsGamepad: got: false, want: true")
+	}
+	if got, want := g.InputReportSize(1), 6; got != want {
+		t.Errorf("InputReportSize(1): got: %d, want: %d", got, want)
+	}
+	if got, want := g.InputReportSize(2), 1; got != want {
+		t.Errorf("InputReportSize(2): got: %d, want: %d", got, want)
+	}
+	// The items of the other report IDs are not included.
+	if got, want := g.AxisCount(), 3; got != want {
+		t.Errorf("AxisCount: got: %d, want: %d", got, want)
+	}
+	if got, want := g.ButtonCount(), 12; got != want {
+		t.Errorf("ButtonCount: got: %d, want: %d", got, want)
+	}
+	if got, want := g.HatCount(), 1; got != want {
+		t.Errorf("HatCount: got: %d, want: %d", got, want)
+	}
+
+	// The axes are ordered by their usages, so X comes before Y.
+	g.SetReport([]byte{0x01, 0x08, 0x02, 0x7f, 0x81, 0xff})
+	for i, want := range []float64{-1, 1, 1} {
+		if got := g.AxisValue(i); math.Abs(got-want) > 1e-9 {
+			t.Errorf("AxisValue(%d): got: %f, want: %f", i, got, want)
+		}
+	}
+	for i := 0; i < g.ButtonCount(); i++ {
+		want := i == 0 || i == 11
+		if got := g.IsButtonPressed(i); got != want {
+			t.Errorf("IsButtonPressed(%d): got: %t, want: %t", i, got, want)
+		}
+	}
+	// 2 is the right direction.
+	if got, want := g.HatState(0), 2; got != want {
+		t.Errorf("HatState(0): got: %d, want: %d", got, want)
+	}
+
+	// The null state of the hat switch is the center.
+	g.SetReport([]byte{0x00, 0x00, 0x08, 0x00, 0x00, 0x80})
+	if got, want := g.HatState(0), 0; got != want {
+		t.Errorf("HatState(0): got: %d, want: %d", got, want)
+	}
+	if got, want := g.AxisValue(0), 0.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("AxisValue(0): got: %f, want: %f", got, want)
+	}
+	if got, want := g.AxisValue(2), 1.0/255; math.Abs(got-want) > 1e-9 {
+		t.Errorf("AxisValue(2): got: %f, want: %f", got, want)
+	}
+
+	// A mouse is not a gamepad.
+	mouse, err := gamepad.NewHIDGamepadForTesting([]byte{
+		0x05, 0x01, // Usage Page (Generic Desktop)
+		0x09, 0x02, // Usage (Mouse)
+		0xa1, 0x01, // Collection (Application)
+		0xc0, // End Collection
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mouse.IsGamepad() {
+		t.Errorf("IsGamepad: got: true, want: false")
+	}
+
+	// A truncated descriptor is an error.
+	if _, err := gamepad.NewHIDGamepadForTesting([]byte{0x05, 0x01, 0x26, 0xff}, 0); err == nil {
+		t.Errorf("NewHIDGamepadForTesting with a truncated descriptor: got: nil, want: an error")
+	}
+}
+
+func TestSDLGUID(t *testing.T) {
+	const (
+		busUSB       = 0x03
+		busBluetooth = 0x05
+	)
+
+	testCases := []struct {
+		Name            string
+		Bus             uint16
+		Vendor          uint16
+		Product         uint16
+		Version         uint16
+		Device          string
+		DriverSignature byte
+		Want            string
+	}{
+		// The GUIDs are the same as SDL 2.26 or later creates, with the CRC16 of the name at the bytes 2 and 3.
+		// The game controller database has the mappings for them without the CRCs.
+		{
+			Name:    "DualShock 4 v2, Linux, USB",
+			Bus:     busUSB,
+			Vendor:  0x054c,
+			Product: 0x09cc,
+			Version: 0x8111,
+			Device:  "Sony Interactive Entertainment Wireless Controller",
+			Want:    "0300d0424c050000cc09000011810000",
+		},
+		{
+			Name:    "DualShock 4 v2, Linux, Bluetooth",
+			Bus:     busBluetooth,
+			Vendor:  0x054c,
+			Product: 0x09cc,
+			Version: 0x8100,
+			Device:  "Wireless Controller",
+			Want:    "05009b514c050000cc09000000810000",
+		},
+		{
+			Name:    "DualShock 4 v2, macOS IOKit",
+			Bus:     busUSB,
+			Vendor:  0x054c,
+			Product: 0x09cc,
+			Version: 0x0100,
+			Device:  "Wireless Controller",
+			Want:    "03009b514c050000cc09000000010000",
+		},
+		{
+			Name:    "DualShock 4 v2, Windows DirectInput",
+			Bus:     busUSB,
+			Vendor:  0x054c,
+			Product: 0x09cc,
+			Device:  "Wireless Controller",
+			Want:    "03009b514c050000cc09000000000000",
+		},
+		{
+			Name:            "DualShock 4 v2, Windows HIDAPI",
+			Bus:             busUSB,
+			Vendor:          0x054c,
+			Product:         0x09cc,
+			Version:         0x0100,
+			Device:          "PS4 Controller",
+			DriverSignature: 'h',
+			Want:            "03008fe54c050000cc09000000016800",
+		},
+		{
+			Name:            "DualShock 4 v2, macOS GameController",
+			Bus:             busBluetooth,
+			Vendor:          0x054c,
+			Product:         0x09cc,
+			Device:          "DUALSHOCK 4 Wireless Controller",
+			DriverSignature: 'm',
+			Want:            "05001ea34c050000cc09000000006d00",
+		},
+		{
+			Name:    "Xbox One S, Linux, USB",
+			Bus:     busUSB,
+			Vendor:  0x045e,
+			Product: 0x02ea,
+			Version: 0x0408,
+			Device:  "Microsoft X-Box One S pad",
+			Want:    "0300e8fb5e040000ea02000008040000",
+		},
+		{
+			Name:    "Xbox One S, Linux, Bluetooth",
+			Bus:     busBluetooth,
+			Vendor:  0x045e,
+			Product: 0x02fd,
+			Version: 0x0903,
+			Device:  "Xbox Wireless Controller",
+			Want:    "050018dc5e040000fd02000003090000",
+		},
+		{
+			Name:    "Xbox One S, macOS IOKit",
+			Bus:     busUSB,
+			Vendor:  0x045e,
+			Product: 0x02fd,
+			Version: 0x0903,
+			Device:  "Xbox Wireless Controller",
+			Want:    "030018dc5e040000fd02000003090000",
+		},
+		{
+			Name:    "Xbox One S, Windows DirectInput",
+			Bus:     busUSB,
+			Vendor:  0x045e,
+			Product: 0x02ea,
+			Device:  "Controller (Xbox One For Windows)",
+			Want:    "0300938d5e040000ea02000000000000",
+		},
+		{
+			Name:            "Xbox One S, Windows.Gaming.Input",
+			Bus:             busUSB,
+			Vendor:          0x045e,
+			Product:         0x02ea,
+			Device:          "Xbox Controller",
+			DriverSignature: 'w',
+			Want:            "0300a53e5e040000ea02000000007700",
+		},
+		{
+			Name:            "Xbox One S, Windows GameInput",
+			Bus:             busUSB,
+			Vendor:          0x045e,
+			Product:         0x02ea,
+			Device:          "Xbox Controller",
+			DriverSignature: 'g',
+			Want:            "0300a53e5e040000ea02000000006700",
+		},
+		{
+			Name:    "8BitDo SN30 Pro, Linux, Bluetooth",
+			Bus:     busBluetooth,
+			Vendor:  0x2dc8,
+			Product: 0x6101,
+			Version: 0x0100,
+			Device:  "8Bitdo SN30 Pro",
+			Want:    "0500d640c82d00000161000000010000",
+		},
+		{
+			Name:    "8BitDo SN30 Pro, macOS IOKit",
+			Bus:     busUSB,
+			Vendor:  0x2dc8,
+			Product: 0x6101,
+			Version: 0x0100,
+			Device:  "8Bitdo SN30 Pro",
+			Want:    "0300d640c82d00000161000000010000",
+		},
+		{
+			Name:    "8BitDo SN30 Pro, Windows DirectInput",
+			Bus:     busUSB,
+			Vendor:  0x2dc8,
+			Product: 0x6101,
+			Device:  "8Bitdo SN30 Pro",
+			Want:    "0300d640c82d00000161000000000000",
+		},
+
+		// The name is used when the vendor or the product is unknown.
+		{
+			Name:    "no version",
+			Bus:     busBluetooth,
+			Vendor:  0x045e,
+			Product: 0x028e,
+			Device:  "Gamepad",
+			Want:    "0500a5d05e0400008e02000000000000",
+		},
+		{
+			Name:   "no product",
+			Bus:    busBluetooth,
+			Vendor: 0x045e,
+			Device: "Gamepad",
+			Want:   "0500a5d047616d657061640000000000",
+		},
+		{
+			Name:   "long name",
+			Bus:    busBluetooth,
+			Device: "Wiimote (00-1f-32-ab-cd-ef)",
+			Want:   "0500d5f65769696d6f74652028303000",
+		},
+		{
+			Name:            "long name with a driver signature",
+			Bus:             busBluetooth,
+			Device:          "Xbox Controller",
+			DriverSignature: 'w',
+			Want:            "0500a53e58626f7820436f6e74007700",
+		},
+		{
+			Name: "empty name",
+			Want: "00000000000000000000000000000000",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			if got := gamepad.SDLGUIDForTesting(tc.Bus, tc.Vendor, tc.Product, tc.Version, tc.Device, tc.DriverSignature, 0); got != tc.Want {
+				t.Errorf("got: %s, want: %s", got, tc.Want)
+			}
+		})
+	}
+
+	if got, want := gamepad.AndroidSDLGUIDForTesting(0x054c, 0x09cc, "a5f1a2b3c4d5e6f7", 0x7fff, 0x003f), "050071ac4c050000cc090000ff7f3f00"; got != want {
+		t.Errorf("Android: got: %s, want: %s", got, want)
+	}
+	if got, want := gamepad.AndroidSDLGUIDForTesting(0, 0, "a5f1a2b3c4d5e6f7", 0x7fff, 0x003f), "050071ac6135663161326233ff7f3f00"; got != want {
+		t.Errorf("Android without the vendor and the product: got: %s, want: %s", got, want)
+	}
+	// SDL's WGI backend with the Xbox Wireless Controller's DisplayName.
+	if got, want := gamepad.WGISDLGUIDForTesting(0x045e, 0x0b13, "Xbox Wireless Controller", false), "030018dc5e040000130b000000007701"; got != want {
+		t.Errorf("WGI: got: %s, want: %s", got, want)
+	}
+	if got, want := gamepad.WGISDLGUIDForTesting(0x045e, 0x0b13, "Xbox Wireless Controller", true), "050018dc5e040000130b000000007701"; got != want {
+		t.Errorf("WGI, wireless: got: %s, want: %s", got, want)
+	}
+	if got, want := gamepad.WGISDLGUIDForTesting(0x045e, 0x0b13, "", false), "030000005e040000130b000000007701"; got != want {
+		t.Errorf("WGI without the display name: got: %s, want: %s", got, want)
+	}
+	// The well-known check value of CRC-16/ARC, which SDL_crc16 implements.
+	if got, want := gamepad.SDLCRC16ForTesting("123456789"), uint16(0xbb3d); got != want {
+		t.Errorf("CRC16: got: %04x, want: %04x", got, want)
+	}
+
+	if got, want := gamepad.XInputSDLGUIDForTesting(1), "78696e70757401000000000000000000"; got != want {
+		t.Errorf("XInput: got: %s, want: %s", got, want)
+	}
+	if got, want := gamepad.EmscriptenSDLGUIDForTesting("Wireless Controller (STANDARD GAMEPAD Vendor: 054c Product: 09cc)"), "576972656c65737320436f6e74726f6c"; got != want {
+		t.Errorf("Emscripten: got: %s, want: %s", got, want)
+	}
+}
+
+// packSwitchProStickValues packs two 12-bit values in 3 bytes.
+func packSwitchProStickValues(a, b int) []byte {
+	return []byte{byte(a), byte(a>>8)&0x0f | byte(b&0x0f)<<4, byte(b >> 4)}
+}
+
+func TestSwitchProHandshake(t *testing.T) {
+	d := &gamepad.SwitchProDeviceForTesting{}
+	if _, err := gamepad.NewSwitchProGamepadForTesting(d, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Via USB, the handshake comes first.
+	want := [][]byte{
+		{0x80, 0x02},
+		{0x80, 0x03},
+		{0x80, 0x02},
+		{0x80, 0x04},
+	}
+	if len(d.Written) < len(want) {
+		t.Fatalf("got: %d reports, want: %d reports or more", len(d.Written), len(want))
+	}
+	if got := d.Written[:len(want)]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %x, want: %x", got, want)
+	}
+
+	// The input report mode is set, and then the calibration is read.
+	var subcommands []byte
+	for _, r := range d.Written[len(want):] {
+		if r[0] == 0x01 {
+			subcommands = append(subcommands, r[10])
+		}
+	}
+	if got, want := subcommands, []byte{0x03, 0x10, 0x10, 0x40, 0x48}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subcommands: got: %x, want: %x", got, want)
+	}
+
+	// Via Bluetooth, there is no handshake.
+	d = &gamepad.SwitchProDeviceForTesting{}
+	if _, err := gamepad.NewSwitchProGamepadForTesting(d, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.Written[0][0], byte(0x01); got != want {
+		t.Errorf("Bluetooth: got: 0x%02x, want: 0x%02x", got, want)
+	}
+
+	// An unresponsive device fails.
+	d = &gamepad.SwitchProDeviceForTesting{
+		NoReply: true,
+	}
+	if _, err := gamepad.NewSwitchProGamepadForTesting(d, false); err == nil {
+		t.Errorf("an unresponsive device must fail")
+	}
+}
+
+func TestSwitchProInputs(t *testing.T) {
+	spi := map[uint32]byte{}
+	// The factory calibration of the left stick: the ranges above the center, the center, and the ranges below the center.
+	for i, b := range append(append(packSwitchProStickValues(1000, 1000), packSwitchProStickValues(2000, 2000)...), packSwitchProStickValues(900, 900)...) {
+		spi[0x603d+uint32(i)] = b
+	}
+	d := &gamepad.SwitchProDeviceForTesting{
+		SPI: spi,
+	}
+	g, err := gamepad.NewSwitchProGamepadForTesting(d, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := make([]byte, 49)
+	report[0] = 0x30
+	report[2] = 0x40 | 0x10 // The battery level 2 of 4, and charging.
+	report[3] = 0x08        // A
+	report[4] = 0x20        // Capture
+	report[5] = 0x02        // Up
+	copy(report[6:9], packSwitchProStickValues(2500, 1100))
+	// The right stick doesn't have a calibration and uses the default one.
+	copy(report[9:12], packSwitchProStickValues(2048+800, 2048))
+	// The latest sample of the motion sensors.
+	report[37] = 0x00
+	report[38] = 0x10 // The accelerometer X: 4096
+	report[47] = 0xe8
+	report[48] = 0x03 // The gyroscope Z: 1000
+	d.PushReport(report)
+
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		Axis gamepaddb.StandardAxis
+		Want float64
+	}{
+		{gamepaddb.StandardAxisLeftStickHorizontal, 0.5},
+		// The vertical values are inverted, and are clamped.
+		{gamepaddb.StandardAxisLeftStickVertical, 1},
+		{gamepaddb.StandardAxisRightStickHorizontal, 0.5},
+		{gamepaddb.StandardAxisRightStickVertical, 0},
+	} {
+		if got := g.AxisValue(tc.Axis); got != tc.Want {
+			t.Errorf("AxisValue(%d): got: %f, want: %f", tc.Axis, got, tc.Want)
+		}
+	}
+
+	for _, tc := range []struct {
+		Button int
+		Want   bool
+	}{
+		{int(gamepaddb.StandardButtonRightRight), true},
+		{int(gamepaddb.StandardButtonRightBottom), false},
+		{int(gamepaddb.StandardButtonLeftTop), true},
+		{gamepad.SwitchProButtonCaptureForTesting, true},
+	} {
+		if got := g.IsButtonPressed(tc.Button); got != tc.Want {
+			t.Errorf("IsButtonPressed(%d): got: %t, want: %t", tc.Button, got, tc.Want)
+		}
+	}
+
+	if got, want := g.MotionSensorValue(gamepad.MotionSensorAxisAccelerometerX), 9.80665; math.Abs(got-want) > 0.01 {
+		t.Errorf("accelerometer X: got: %f, want: %f", got, want)
+	}
+	if got, want := g.MotionSensorValue(gamepad.MotionSensorAxisGyroscopeZ), 70*math.Pi/180; math.Abs(got-want) > 1e-9 {
+		t.Errorf("gyroscope Z: got: %f, want: %f", got, want)
+	}
+
+	if got, ok := g.BatteryLevel(); !ok || got != 50 {
+		t.Errorf("BatteryLevel(): got: %d, %t, want: 50, true", got, ok)
+	}
+	if got, want := g.PowerState(), gamepad.PowerStateCharging; got != want {
+		t.Errorf("PowerState(): got: %d, want: %d", got, want)
+	}
+}
+
+func TestSwitchProUserCalibration(t *testing.T) {
+	spi := map[uint32]byte{}
+	for i, b := range append(append(packSwitchProStickValues(1000, 1000), packSwitchProStickValues(2000, 2000)...), packSwitchProStickValues(1000, 1000)...) {
+		spi[0x603d+uint32(i)] = b
+	}
+	// The user calibration with the magic number overrides the factory calibration.
+	spi[0x8010] = 0xb2
+	spi[0x8011] = 0xa1
+	for i, b := range append(append(packSwitchProStickValues(500, 500), packSwitchProStickValues(1800, 1800)...), packSwitchProStickValues(500, 500)...) {
+		spi[0x8012+uint32(i)] = b
+	}
+	d := &gamepad.SwitchProDeviceForTesting{
+		SPI: spi,
+	}
+	g, err := gamepad.NewSwitchProGamepadForTesting(d, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := make([]byte, 49)
+	report[0] = 0x30
+	copy(report[6:9], packSwitchProStickValues(2050, 1800))
+	copy(report[9:12], packSwitchProStickValues(2048, 2048))
+	d.PushReport(report)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.AxisValue(gamepaddb.StandardAxisLeftStickHorizontal), 0.5; got != want {
+		t.Errorf("got: %f, want: %f", got, want)
+	}
+}
+
+func TestSwitchProRumble(t *testing.T) {
+	if got, want := gamepad.EncodeSwitchProRumbleForTesting(0, 0), [4]byte{0x00, 0x01, 0x40, 0x40}; got != want {
+		t.Errorf("neutral: got: %x, want: %x", got, want)
+	}
+	if got, want := gamepad.EncodeSwitchProRumbleForTesting(1, 1), [4]byte{0x00, 0xc9, 0x40, 0x72}; got != want {
+		t.Errorf("full: got: %x, want: %x", got, want)
+	}
+
+	d := &gamepad.SwitchProDeviceForTesting{}
+	g, err := gamepad.NewSwitchProGamepadForTesting(d, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastRumble := func() []byte {
+		for i := len(d.Written) - 1; i >= 0; i-- {
+			if d.Written[i][0] == 0x10 {
+				return d.Written[i][2:]
+			}
+		}
+		return nil
+	}
+
+	g.Vibrate(time.Second, 1, 1)
+	if got, want := lastRumble(), []byte{0x00, 0xc9, 0x40, 0x72, 0x00, 0xc9, 0x40, 0x72}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %x, want: %x", got, want)
+	}
+
+	// The same rumble is sent again periodically.
+	n := len(d.Written)
+	g.Advance(200 * time.Millisecond)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(d.Written), n+1; got != want {
+		t.Errorf("got: %d reports, want: %d reports", got, want)
+	}
+
+	// The actuators stop after the duration.
+	g.Advance(time.Second)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lastRumble(), []byte{0x00, 0x01, 0x40, 0x40, 0x00, 0x01, 0x40, 0x40}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %x, want: %x", got, want)
+	}
+	n = len(d.Written)
+	g.Advance(time.Second)
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(d.Written), n; got != want {
+		t.Errorf("got: %d reports, want: %d reports", got, want)
+	}
+}
+
+func TestSwitchProPlayerLights(t *testing.T) {
+	d := &gamepad.SwitchProDeviceForTesting{}
+	g, err := gamepad.NewSwitchProGamepadForTesting(d, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		Index int
+		Want  byte
+	}{
+		{0, 0x01},
+		{2, 0x07},
+		{5, 0x03},
+		{-1, 0x00},
+	} {
+		g.SetPlayerIndex(tc.Index)
+		r := d.Written[len(d.Written)-1]
+		if r[0] != 0x01 || r[10] != 0x30 || r[11] != tc.Want {
+			t.Errorf("SetPlayerIndex(%d): got: %x, want: the subcommand 0x30 with 0x%02x", tc.Index, r, tc.Want)
+		}
+	}
+}
+
+func TestDualSenseOutputReport(t *testing.T) {
+	// Via USB, the report doesn't have CRC.
+	d := &gamepad.SonyDeviceForTesting{}
+	g := gamepad.NewDualSenseForTesting(d, false)
+	g.Vibrate(time.Second, 1, 0.5)
+	if len(d.Written) != 1 {
+		t.Fatalf("got: %d reports, want: 1", len(d.Written))
+	}
+	r := d.Written[0]
+	if got, want := len(r), 48; got != want {
+		t.Errorf("USB: length: got: %d, want: %d", got, want)
+	}
+	if got, want := r[0], byte(0x02); got != want {
+		t.Errorf("USB: report ID: got: 0x%02x, want: 0x%02x", got, want)
+	}
+	// The weak (high-frequency) motor comes first.
+	if got, want := r[3:5], []byte{0x7f, 0xff}; !reflect.DeepEqual(got, want) {
+		t.Errorf("USB: motors: got: %x, want: %x", got, want)
+	}
+
+	// Via Bluetooth, the report has CRC-32 of the HID output header and the report.
+	d = &gamepad.SonyDeviceForTesting{}
+	g = gamepad.NewDualSenseForTesting(d, true)
+	g.SetPlayerIndex(1)
+	if len(d.Written) != 1 {
+		t.Fatalf("got: %d reports, want: 1", len(d.Written))
+	}
+	r = d.Written[0]
+	if got, want := len(r), 78; got != want {
+		t.Fatalf("Bluetooth: length: got: %d, want: %d", got, want)
+	}
+	if got, want := r[0], byte(0x31); got != want {
+		t.Errorf("Bluetooth: report ID: got: 0x%02x, want: 0x%02x", got, want)
+	}
+	// The lightbar is red and the player LEDs show the second player.
+	if got, want := r[46:49], []byte{0x40, 0x00, 0x00}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bluetooth: lightbar: got: %x, want: %x", got, want)
+	}
+	if got, want := r[45], byte(0x0a); got != want {
+		t.Errorf("Bluetooth: player LEDs: got: 0x%02x, want: 0x%02x", got, want)
+	}
+	crc := crc32.Update(crc32.ChecksumIEEE([]byte{0xa2}), crc32.IEEETable, r[:74])
+	if got, want := binary.LittleEndian.Uint32(r[74:]), crc; got != want {
+		t.Errorf("Bluetooth: CRC: got: 0x%08x, want: 0x%08x", got, want)
+	}
+}
+
+func TestStandardLayoutMappingRoundTrip(t *testing.T) {
+	const id = "030000000000000000000000000000a1"
+
+	g := gamepad.NewGamepadForTesting(id, 6, 17, 1, true)
+	if g.IsStandardLayoutAvailable() != true {
+		t.Fatal("IsStandardLayoutAvailable() should be true")
+	}
+
+	type result struct {
+		axes    [gamepaddb.StandardAxisMax + 1]float64
+		buttons [gamepaddb.StandardButtonMax + 1]float64
+		pressed [gamepaddb.StandardButtonMax + 1]bool
+	}
+	evaluate := func() result {
+		var r result
+		for a := gamepaddb.StandardAxis(0); a <= gamepaddb.StandardAxisMax; a++ {
+			r.axes[a] = g.StandardAxisValue(a)
+		}
+		for b := gamepaddb.StandardButton(0); b <= gamepaddb.StandardButtonMax; b++ {
+			r.buttons[b] = g.StandardButtonValue(b)
+			r.pressed[b] = g.IsStandardButtonPressed(b)
+		}
+		return r
+	}
+	setStates := []func(){
+		func() {
+			// The triggers rest at -1. The native mapping compares the raw axis value with the threshold,
+			// while the database compares the value in [0, 1], so the two only agree away from the middle.
+			g.SetAxis(4, -1)
+			g.SetAxis(5, -1)
+		},
+		func() {
+			g.SetAxis(0, 0.5)
+			g.SetAxis(3, -0.25)
+			g.SetAxis(4, -0.8)
+			g.SetAxis(5, 0.2)
+			g.SetButton(0, true)
+			g.SetButton(9, true)
+			g.SetHat(0, 1|2)
+		},
+		func() {
+			g.SetAxis(1, -1)
+			g.SetAxis(4, 1)
+			g.SetAxis(5, -1)
+			g.SetButton(0, false)
+			g.SetButton(16, true)
+			g.SetHat(0, 4)
+		},
+	}
+
+	// Evaluate the native mapping.
+	var want []result
+	for _, f := range setStates {
+		f()
+		want = append(want, evaluate())
+	}
+
+	str := g.StandardLayoutMapping()
+	if str == "" {
+		t.Fatal("StandardLayoutMapping() should not be empty")
+	}
+	if _, err := gamepaddb.Update(strings.NewReader(str)); err != nil {
+		t.Fatal(err)
+	}
+	if !gamepaddb.HasStandardLayoutMapping(id) {
+		t.Fatalf("the mapping %q should be added to the database", str)
+	}
+	if got := g.StandardLayoutMapping(); got != str {
+		t.Errorf("StandardLayoutMapping() after a round trip: got: %q, want: %q", got, str)
+	}
+
+	// Evaluate the mapping in the database.
+	for i := range setStates {
+		g.SetHat(0, 0)
+		for b := 0; b < 17; b++ {
+			g.SetButton(b, false)
+		}
+		for a := 0; a < 6; a++ {
+			g.SetAxis(a, 0)
+		}
+		for _, f := range setStates[:i+1] {
+			f()
+		}
+		if got := evaluate(); got != want[i] {
+			t.Errorf("state %d: got: %v, want: %v", i, got, want[i])
+		}
+	}
+}
+
+func TestStandardLayoutAvailability(t *testing.T) {
+	// An unknown gamepad reports nothing available, even though the D-pad and the left stick are guessed.
+	g := gamepad.NewGamepadForTesting("030000000000000000000000000000a2", 2, 4, 1, false)
+	for b := gamepaddb.StandardButton(0); b <= gamepaddb.StandardButtonMax; b++ {
+		if g.IsStandardButtonAvailable(b) {
+			t.Errorf("IsStandardButtonAvailable(%d) should be false", b)
+		}
+	}
+	for a := gamepaddb.StandardAxis(0); a <= gamepaddb.StandardAxisMax; a++ {
+		if g.IsStandardAxisAvailable(a) {
+			t.Errorf("IsStandardAxisAvailable(%d) should be false", a)
+		}
+	}
+	g.SetHat(0, 8)
+	if !g.IsStandardButtonPressed(gamepaddb.StandardButtonLeftLeft) {
+		t.Errorf("IsStandardButtonPressed(StandardButtonLeftLeft) should be true by the heuristic")
+	}
+
+	// A gamepad in the database reports only the mapped buttons and axes.
+	const id = "030000000000000000000000000000a3"
+	if _, err := gamepaddb.Update(strings.NewReader(id + ",SNES-style Gamepad,a:b0,b:b1,x:b2,y:b3,leftx:a0,lefty:a1,\n")); err != nil {
+		t.Fatal(err)
+	}
+	g = gamepad.NewGamepadForTesting(id, 2, 4, 0, false)
+	if !g.IsStandardButtonAvailable(gamepaddb.StandardButtonRightBottom) {
+		t.Errorf("IsStandardButtonAvailable(StandardButtonRightBottom) should be true")
+	}
+	if g.IsStandardButtonAvailable(gamepaddb.StandardButtonLeftStick) {
+		t.Errorf("IsStandardButtonAvailable(StandardButtonLeftStick) should be false")
+	}
+	if !g.IsStandardAxisAvailable(gamepaddb.StandardAxisLeftStickVertical) {
+		t.Errorf("IsStandardAxisAvailable(StandardAxisLeftStickVertical) should be true")
+	}
+	if g.IsStandardAxisAvailable(gamepaddb.StandardAxisRightStickVertical) {
+		t.Errorf("IsStandardAxisAvailable(StandardAxisRightStickVertical) should be false")
+	}
+}
+
+func TestStandardLayoutOverride(t *testing.T) {
+	const id = "030000000000000000000000000000a4"
+	if _, err := gamepaddb.Update(strings.NewReader(id + ",SNES-style Gamepad,a:b0,b:b1,leftx:a0,\n")); err != nil {
+		t.Fatal(err)
+	}
+	g1 := gamepad.NewGamepadForTesting(id, 2, 4, 1, false)
+	g2 := gamepad.NewGamepadForTesting(id, 2, 4, 1, false)
+
+	o, err := gamepaddb.NewOverride(&gamepaddb.Mapping{
+		Buttons: map[gamepaddb.StandardButton]gamepaddb.Input{
+			gamepaddb.StandardButtonRightBottom: {Type: gamepaddb.InputTypeButton, Index: 1},
+			gamepaddb.StandardButtonLeftLeft:    {Type: gamepaddb.InputTypeHat, Index: 0, HatState: gamepaddb.HatLeft},
+		},
+		Axes: map[gamepaddb.StandardAxis]gamepaddb.Input{
+			gamepaddb.StandardAxisLeftStickHorizontal: {Type: gamepaddb.InputTypeAxis, Index: 1, Inverted: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g1.SetStandardLayoutOverride(o, false)
+
+	g1.SetButton(1, true)
+	g2.SetButton(1, true)
+	g1.SetHat(0, gamepaddb.HatLeft)
+	g1.SetAxis(1, 0.5)
+	g2.SetAxis(0, 0.25)
+
+	// The override is used only for the gamepad.
+	if !g1.IsStandardButtonPressed(gamepaddb.StandardButtonRightBottom) {
+		t.Errorf("IsStandardButtonPressed(StandardButtonRightBottom) should be true with the override")
+	}
+	if g2.IsStandardButtonPressed(gamepaddb.StandardButtonRightBottom) {
+		t.Errorf("IsStandardButtonPressed(StandardButtonRightBottom) should be false without the override")
+	}
+	if !g1.IsStandardButtonPressed(gamepaddb.StandardButtonLeftLeft) {
+		t.Errorf("IsStandardButtonPressed(StandardButtonLeftLeft) should be true with the override")
+	}
+	if got, want := g1.StandardAxisValue(gamepaddb.StandardAxisLeftStickHorizontal), -0.5; got != want {
+		t.Errorf("StandardAxisValue: got: %f, want: %f", got, want)
+	}
+	if got, want := g2.StandardAxisValue(gamepaddb.StandardAxisLeftStickHorizontal), 0.25; got != want {
+		t.Errorf("StandardAxisValue: got: %f, want: %f", got, want)
+	}
+
+	// Only the inputs in the override are available, even though the database has other inputs.
+	if g1.IsStandardButtonAvailable(gamepaddb.StandardButtonRightRight) {
+		t.Errorf("IsStandardButtonAvailable(StandardButtonRightRight) should be false with the override")
+	}
+	if !g2.IsStandardButtonAvailable(gamepaddb.StandardButtonRightRight) {
+		t.Errorf("IsStandardButtonAvailable(StandardButtonRightRight) should be true without the override")
+	}
+	if got, want := g1.StandardLayoutMapping(), id+",Test Gamepad,a:b1,dpleft:h0.8,leftx:a1~,"; !strings.HasPrefix(got, want) {
+		t.Errorf("StandardLayoutMapping: got: %q, want: %q", got, want)
+	}
+
+	// Resetting the override restores the database's mapping.
+	g1.SetStandardLayoutOverride(nil, false)
+	if got, want := g1.StandardAxisValue(gamepaddb.StandardAxisLeftStickHorizontal), 0.0; got != want {
+		t.Errorf("StandardAxisValue: got: %f, want: %f", got, want)
+	}
+	if !g1.IsStandardButtonAvailable(gamepaddb.StandardButtonRightRight) {
+		t.Errorf("IsStandardButtonAvailable(StandardButtonRightRight) should be true without the override")
+	}
+}
+
+func TestHeuristicMapping(t *testing.T) {
+	type expectedInput struct {
+		kind  string // "axis" or "button"
+		index int
+	}
+	cases := []struct {
+		Name        string
+		AxisCount   int
+		ButtonCount int
+		HatCount    int
+		Buttons     map[gamepaddb.StandardButton]expectedInput
+		Axes        map[gamepaddb.StandardAxis]int
+	}{
+		{
+			Name:        "6 axes",
+			AxisCount:   6,
+			ButtonCount: 11,
+			HatCount:    1,
+			Buttons: map[gamepaddb.StandardButton]expectedInput{
+				gamepaddb.StandardButtonRightBottom:      {"button", 0},
+				gamepaddb.StandardButtonRightTop:         {"button", 3},
+				gamepaddb.StandardButtonCenterRight:      {"button", 7},
+				gamepaddb.StandardButtonCenterCenter:     {"button", 8},
+				gamepaddb.StandardButtonRightStick:       {"button", 10},
+				gamepaddb.StandardButtonFrontBottomLeft:  {"axis", 2},
+				gamepaddb.StandardButtonFrontBottomRight: {"axis", 5},
+			},
+			Axes: map[gamepaddb.StandardAxis]int{
+				gamepaddb.StandardAxisLeftStickHorizontal:  0,
+				gamepaddb.StandardAxisLeftStickVertical:    1,
+				gamepaddb.StandardAxisRightStickHorizontal: 3,
+				gamepaddb.StandardAxisRightStickVertical:   4,
+			},
+		},
+		{
+			Name:        "4 axes and a hat",
+			AxisCount:   4,
+			ButtonCount: 8,
+			HatCount:    1,
+			Buttons: map[gamepaddb.StandardButton]expectedInput{
+				gamepaddb.StandardButtonRightBottom:  {"button", 0},
+				gamepaddb.StandardButtonFrontTopLeft: {"button", 4},
+				gamepaddb.StandardButtonCenterLeft:   {"button", 6},
+				gamepaddb.StandardButtonCenterRight:  {"button", 7},
+			},
+			Axes: map[gamepaddb.StandardAxis]int{
+				gamepaddb.StandardAxisLeftStickHorizontal:  0,
+				gamepaddb.StandardAxisLeftStickVertical:    1,
+				gamepaddb.StandardAxisRightStickHorizontal: 2,
+				gamepaddb.StandardAxisRightStickVertical:   3,
+			},
+		},
+		{
+			Name:        "triggers as buttons",
+			AxisCount:   4,
+			ButtonCount: 12,
+			HatCount:    1,
+			Buttons: map[gamepaddb.StandardButton]expectedInput{
+				gamepaddb.StandardButtonRightRight:       {"button", 1},
+				gamepaddb.StandardButtonFrontBottomLeft:  {"button", 6},
+				gamepaddb.StandardButtonFrontBottomRight: {"button", 7},
+				gamepaddb.StandardButtonCenterLeft:       {"button", 8},
+				gamepaddb.StandardButtonCenterRight:      {"button", 9},
+				gamepaddb.StandardButtonLeftStick:        {"button", 10},
+				gamepaddb.StandardButtonRightStick:       {"button", 11},
+			},
+			Axes: map[gamepaddb.StandardAxis]int{
+				gamepaddb.StandardAxisRightStickHorizontal: 2,
+				gamepaddb.StandardAxisRightStickVertical:   3,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			g := gamepad.NewGamepadForTesting("030000000000000000000000000000a5", c.AxisCount, c.ButtonCount, c.HatCount, false)
+
+			// The heuristic mapping is disabled by default.
+			if g.IsStandardLayoutAvailable() {
+				t.Errorf("IsStandardLayoutAvailable should be false")
+			}
+			if g.IsStandardLayoutHeuristic() {
+				t.Errorf("IsStandardLayoutHeuristic should be false")
+			}
+
+			g.SetHeuristicMappingEnabled(true)
+			if !g.IsStandardLayoutAvailable() {
+				t.Errorf("IsStandardLayoutAvailable should be true")
+			}
+			if !g.IsStandardLayoutHeuristic() {
+				t.Errorf("IsStandardLayoutHeuristic should be true")
+			}
+			if !g.IsStandardButtonAvailable(gamepaddb.StandardButtonLeftTop) {
+				t.Errorf("IsStandardButtonAvailable(StandardButtonLeftTop) should be true")
+			}
+
+			for b, in := range c.Buttons {
+				if !g.IsStandardButtonAvailable(b) {
+					t.Errorf("IsStandardButtonAvailable(%d) should be true", b)
+					continue
+				}
+				switch in.kind {
+				case "button":
+					g.SetButton(in.index, true)
+					if !g.IsStandardButtonPressed(b) {
+						t.Errorf("IsStandardButtonPressed(%d) should be true by the button %d", b, in.index)
+					}
+					g.SetButton(in.index, false)
+				case "axis":
+					g.SetAxis(in.index, 1)
+					if got, want := g.StandardButtonValue(b), 1.0; got != want {
+						t.Errorf("StandardButtonValue(%d): got: %f, want: %f", b, got, want)
+					}
+					g.SetAxis(in.index, 0)
+				}
+			}
+			for a, index := range c.Axes {
+				if !g.IsStandardAxisAvailable(a) {
+					t.Errorf("IsStandardAxisAvailable(%d) should be true", a)
+					continue
+				}
+				g.SetAxis(index, 0.5)
+				if got, want := g.StandardAxisValue(a), 0.5; got != want {
+					t.Errorf("StandardAxisValue(%d): got: %f, want: %f", a, got, want)
+				}
+				g.SetAxis(index, 0)
+			}
+		})
+	}
+}
+
+func TestUnmappedGamepads(t *testing.T) {
+	const (
+		unmappedID = "030000000000000000000000000000a6"
+		mappedID   = "030000000000000000000000000000a7"
+	)
+	if _, err := gamepaddb.Update(strings.NewReader(mappedID + ",Mapped Gamepad,a:b0,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	gs := gamepad.NewGamepadsForTesting(0)
+	for _, g := range []*gamepad.GamepadForTesting{
+		gamepad.NewGamepadForTesting(unmappedID, 2, 10, 1, false),
+		// The same gamepad is reported only once.
+		gamepad.NewGamepadForTesting(unmappedID, 2, 10, 1, false),
+		gamepad.NewGamepadForTesting(mappedID, 2, 10, 1, false),
+		// A gamepad with the platform's mapping is not reported.
+		gamepad.NewGamepadForTesting("030000000000000000000000000000a8", 6, 10, 1, true),
+	} {
+		// Update twice to make sure a gamepad is checked only once.
+		for i := 0; i < 2; i++ {
+			if err := gs.UpdateGamepad(g); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	gamepads := gs.AppendUnmappedGamepads(nil)
+	if got, want := len(gamepads), 1; got != want {
+		t.Fatalf("len(gamepads): got: %d, want: %d", got, want)
+	}
+	g := gamepads[0]
+	if got, want := g.SDLID, unmappedID; got != want {
+		t.Errorf("SDLID: got: %q, want: %q", got, want)
+	}
+	if g.AxisCount != 2 || g.ButtonCount != 10 || g.HatCount != 1 {
+		t.Errorf("counts: got: (%d, %d, %d), want: (2, 10, 1)", g.AxisCount, g.ButtonCount, g.HatCount)
+	}
+	if want := unmappedID + ",Test Gamepad,"; !strings.HasPrefix(g.MappingTemplate, want) {
+		t.Errorf("MappingTemplate: got: %q, want: prefix %q", g.MappingTemplate, want)
+	}
+	if g.Time.IsZero() {
+		t.Errorf("Time must not be zero")
+	}
+}
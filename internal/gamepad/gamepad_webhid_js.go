@@ -0,0 +1,303 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"syscall/js"
+)
+
+var uint8Array = js.Global().Get("Uint8Array")
+
+// webHIDProducts is the products that the enhanced access via WebHID is requested for.
+var webHIDProducts = []uint16{
+	sonyProductDualShock4,
+	sonyProductDualShock4Slim,
+	sonyProductDualShock4Dongle,
+	sonyProductDualSense,
+	sonyProductDualSenseEdge,
+}
+
+// webHID is the WebHID backend for the features the Gamepad API doesn't provide, like the motion sensors and the touchpad.
+// WebHID is available only on Chrome and Edge, and a device is available only after the user grants the access in the chooser.
+// The buttons and the axes are still read via the Gamepad API.
+type webHID struct {
+	// devices is the opened devices.
+	// This doesn't have to be protected by a mutex, as the event handlers are never called during update.
+	devices []*webHIDDevice
+
+	onDevicesGranted js.Func
+	onDisconnect     js.Func
+}
+
+func (w *webHID) init() {
+	hid := js.Global().Get("navigator").Get("hid")
+	if !hid.Truthy() {
+		return
+	}
+
+	w.onDevicesGranted = js.FuncOf(func(this js.Value, args []js.Value) any {
+		devices := args[0]
+		for i := 0; i < devices.Length(); i++ {
+			w.open(devices.Index(i))
+		}
+		return nil
+	})
+	w.onDisconnect = js.FuncOf(func(this js.Value, args []js.Value) any {
+		device := args[0].Get("device")
+		for i, d := range w.devices {
+			if !d.device.Equal(device) {
+				continue
+			}
+			d.close()
+			w.devices = append(w.devices[:i], w.devices[i+1:]...)
+			break
+		}
+		return nil
+	})
+	hid.Call("addEventListener", "disconnect", w.onDisconnect)
+
+	// The devices granted in the previous sessions are available without the chooser.
+	hid.Call("getDevices").Call("then", w.onDevicesGranted).Call("catch", ignorePromiseRejection)
+}
+
+// requestAccess shows the chooser of WebHID devices.
+// requestAccess must be called in response to a user input like a click, as WebHID requires a user activation.
+func (w *webHID) requestAccess() {
+	hid := js.Global().Get("navigator").Get("hid")
+	if !hid.Truthy() || !w.onDevicesGranted.Truthy() {
+		return
+	}
+
+	filters := js.Global().Get("Array").New()
+	for _, p := range webHIDProducts {
+		f := object.New()
+		f.Set("vendorId", sonyVendorID)
+		f.Set("productId", p)
+		filters.Call("push", f)
+	}
+	options := object.New()
+	options.Set("filters", filters)
+
+	// The promise is rejected when the user activation is missing. When the user cancels the chooser, the promise is resolved with no devices.
+	hid.Call("requestDevice", options).Call("then", w.onDevicesGranted).Call("catch", ignorePromiseRejection)
+}
+
+func (w *webHID) open(device js.Value) {
+	vendor := uint16(device.Get("vendorId").Int())
+	product := uint16(device.Get("productId").Int())
+	model, ok := sonyGamepadModel(vendor, product)
+	if !ok {
+		return
+	}
+	for _, d := range w.devices {
+		if d.device.Equal(device) {
+			return
+		}
+	}
+
+	d := &webHIDDevice{
+		device:    device,
+		vendor:    vendor,
+		product:   product,
+		model:     model,
+		bluetooth: hasWebHIDOutputReport(device, 0x11) || hasWebHIDOutputReport(device, 0x31),
+	}
+	if device.Get("opened").Bool() {
+		d.start()
+		w.devices = append(w.devices, d)
+		return
+	}
+
+	var onOpened js.Func
+	onOpened = js.FuncOf(func(this js.Value, args []js.Value) any {
+		onOpened.Release()
+		d.start()
+		w.devices = append(w.devices, d)
+		return nil
+	})
+	// The device might fail to be opened, e.g., when another tab opens it.
+	device.Call("open").Call("then", onOpened).Call("catch", ignorePromiseRejection)
+}
+
+// update attaches the opened devices to the gamepads via the Gamepad API, and detaches the closed devices.
+// The devices of the same product are attached in order, as the Gamepad API doesn't expose any identity of the device.
+func (w *webHID) update(gamepads *gamepads) {
+	attached := map[*webHIDDevice]struct{}{}
+	for _, gamepad := range gamepads.gamepads {
+		if gamepad == nil {
+			continue
+		}
+		n, ok := gamepad.native.(*nativeGamepadImpl)
+		if !ok || n.hidDevice == nil {
+			continue
+		}
+		if n.hidDevice.closed {
+			n.detachHID()
+			continue
+		}
+		attached[n.hidDevice] = struct{}{}
+	}
+
+	if len(attached) == len(w.devices) {
+		return
+	}
+
+	for _, gamepad := range gamepads.gamepads {
+		if gamepad == nil {
+			continue
+		}
+		n, ok := gamepad.native.(*nativeGamepadImpl)
+		if !ok || n.hidDevice != nil {
+			continue
+		}
+		vendor, product, ok := browserGamepadVendorProduct(gamepad.name)
+		if !ok {
+			continue
+		}
+		for _, d := range w.devices {
+			if _, ok := attached[d]; ok {
+				continue
+			}
+			if d.closed || d.vendor != vendor || d.product != product {
+				continue
+			}
+			attached[d] = struct{}{}
+			n.attachHID(d)
+			break
+		}
+	}
+}
+
+// hasWebHIDOutputReport reports whether the device has an output report of the given ID.
+// The output reports via Bluetooth have different IDs from the ones via USB.
+func hasWebHIDOutputReport(device js.Value, reportID int) bool {
+	collections := device.Get("collections")
+	if !collections.Truthy() {
+		return false
+	}
+	for i := 0; i < collections.Length(); i++ {
+		reports := collections.Index(i).Get("outputReports")
+		if !reports.Truthy() {
+			continue
+		}
+		for j := 0; j < reports.Length(); j++ {
+			if reports.Index(j).Get("reportId").Int() == reportID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// browserGamepadIDPattern matches the vendor and the product IDs in a gamepad ID of Chrome and Edge,
+// like "DualSense Wireless Controller (STANDARD GAMEPAD Vendor: 054c Product: 0ce6)".
+var browserGamepadIDPattern = regexp.MustCompile(`Vendor: ([0-9a-fA-F]{4}) Product: ([0-9a-fA-F]{4})`)
+
+func browserGamepadVendorProduct(id string) (uint16, uint16, bool) {
+	m := browserGamepadIDPattern.FindStringSubmatch(id)
+	if m == nil {
+		return 0, 0, false
+	}
+	vendor, err := strconv.ParseUint(m[1], 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	product, err := strconv.ParseUint(m[2], 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint16(vendor), uint16(product), true
+}
+
+// webHIDDevice is a raw HID device via WebHID.
+// The input reports are received via the inputreport events, and are queued until they are read.
+type webHIDDevice struct {
+	device    js.Value
+	vendor    uint16
+	product   uint16
+	model     sonyModel
+	bluetooth bool
+	closed    bool
+
+	reports       [][]byte
+	onInputReport js.Func
+}
+
+func (d *webHIDDevice) start() {
+	d.onInputReport = js.FuncOf(func(this js.Value, args []js.Value) any {
+		e := args[0]
+		data := e.Get("data")
+		report := make([]byte, 1+data.Get("byteLength").Int())
+		report[0] = byte(e.Get("reportId").Int())
+		js.CopyBytesToGo(report[1:], uint8Array.New(data.Get("buffer"), data.Get("byteOffset"), data.Get("byteLength")))
+
+		if len(d.reports) >= maxQueuedHIDReports {
+			d.reports = d.reports[1:]
+		}
+		d.reports = append(d.reports, report)
+		return nil
+	})
+	d.device.Call("addEventListener", "inputreport", d.onInputReport)
+}
+
+func (d *webHIDDevice) read() ([]byte, error) {
+	if d.closed {
+		return nil, errors.New("gamepad: the WebHID device is closed")
+	}
+	if len(d.reports) == 0 {
+		return nil, nil
+	}
+	report := d.reports[0]
+	d.reports = d.reports[1:]
+	return report, nil
+}
+
+func (d *webHIDDevice) write(report []byte) error {
+	if d.closed {
+		return errors.New("gamepad: the WebHID device is closed")
+	}
+	// WebHID takes the report ID separately from the data.
+	data := uint8Array.New(len(report) - 1)
+	js.CopyBytesToJS(data, report[1:])
+	d.device.Call("sendReport", int(report[0]), data).Call("catch", ignorePromiseRejection)
+	return nil
+}
+
+// getFeature requests a feature report.
+// The result is not available synchronously, and getFeature always returns an error.
+// Requesting a feature report is enough to e.g. switch DualShock 4 and DualSense to the full reports via Bluetooth.
+func (d *webHIDDevice) getFeature(reportID byte) ([]byte, error) {
+	if d.closed {
+		return nil, errors.New("gamepad: the WebHID device is closed")
+	}
+	d.device.Call("receiveFeatureReport", int(reportID)).Call("catch", ignorePromiseRejection)
+	return nil, fmt.Errorf("gamepad: the feature report 0x%02x is not available synchronously via WebHID", reportID)
+}
+
+func (d *webHIDDevice) close() {
+	if d.closed {
+		return
+	}
+	d.closed = true
+	if d.onInputReport.Truthy() {
+		d.device.Call("removeEventListener", "inputreport", d.onInputReport)
+		d.onInputReport.Release()
+	}
+	d.device.Call("close").Call("catch", ignorePromiseRejection)
+}
@@ -0,0 +1,588 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"errors"
+	"sort"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
+)
+
+// wgiGamepads is the Windows.Gaming.Input backend for Xbox-class controllers.
+// Unlike XInput, WGI supports more than four controllers, battery information, and the trigger motors.
+type wgiGamepads struct {
+	statics    *_IGamepadStatics
+	rawStatics *_IRawGameControllerStatics
+
+	current []*_IGamepad
+
+	// xinputIndicesDirty reports whether the XInput user indices of the gamepads have to be updated.
+	xinputIndicesDirty bool
+}
+
+func newWGIGamepads() (*wgiGamepads, error) {
+	if !isWGIAvailable() {
+		return nil, errors.New("gamepad: Windows.Gaming.Input is not available")
+	}
+
+	var statics *_IGamepadStatics
+	if err := _RoGetActivationFactory("Windows.Gaming.Input.Gamepad", &_IID_IGamepadStatics, unsafe.Pointer(&statics)); err != nil {
+		return nil, err
+	}
+
+	// RawGameController is available on Windows 10 version 1709 or later, and is used only to get the vendor and the product IDs.
+	var rawStatics *_IRawGameControllerStatics
+	if err := _RoGetActivationFactory("Windows.Gaming.Input.RawGameController", &_IID_IRawGameControllerStatics, unsafe.Pointer(&rawStatics)); err != nil {
+		rawStatics = nil
+	}
+
+	return &wgiGamepads{
+		statics:    statics,
+		rawStatics: rawStatics,
+	}, nil
+}
+
+func (w *wgiGamepads) update(gamepads *gamepads, desktop *nativeGamepadsDesktop) error {
+	// The list of the gamepads is polled instead of using the GamepadAdded and GamepadRemoved events,
+	// as the events are fired on other threads.
+	vec, err := w.statics.GetGamepads()
+	if err != nil {
+		return err
+	}
+	defer vec.Release()
+
+	size, err := vec.GetSize()
+	if err != nil {
+		return err
+	}
+
+	w.current = w.current[:0]
+	for i := uint32(0); i < size; i++ {
+		gp, err := vec.GetAt(i)
+		if err != nil {
+			return err
+		}
+		w.current = append(w.current, gp)
+	}
+
+	var added []*nativeGamepadWGI
+	for _, gp := range w.current {
+		if gamepads.find(func(g *Gamepad) bool {
+			n, ok := g.native.(*nativeGamepadWGI)
+			return ok && n.gamepad == gp
+		}) != nil {
+			gp.Release()
+			continue
+		}
+
+		// The reference of gp is held by the native gamepad.
+		n := newNativeGamepadWGI(gp)
+		n.sdlID, n.identity = w.sdlIDAndIdentity(gp)
+		added = append(added, n)
+	}
+
+	// The order of the gamepads WGI reports is not stable, e.g., for multiple identical controllers.
+	// Add the gamepads in the order of their identities so that the same controllers get the same IDs across sessions.
+	sort.SliceStable(added, func(i, j int) bool {
+		return added[i].identity < added[j].identity
+	})
+	for _, n := range added {
+		// The gamepad ID is attached to the physical controller, so that the controller gets the same ID after reconnecting.
+		g := gamepads.addWithReconnectionKey("Xbox Controller", n.sdlID, n.identity)
+		g.native = n
+		w.xinputIndicesDirty = true
+	}
+
+	gamepads.remove(func(g *Gamepad) bool {
+		n, ok := g.native.(*nativeGamepadWGI)
+		if !ok {
+			return false
+		}
+		for _, gp := range w.current {
+			if gp == n.gamepad {
+				return false
+			}
+		}
+		n.release()
+		w.xinputIndicesDirty = true
+		return true
+	})
+
+	if w.xinputIndicesDirty {
+		if err := w.updateXInputIndices(gamepads, desktop); err != nil {
+			return err
+		}
+		w.xinputIndicesDirty = false
+	}
+
+	return nil
+}
+
+// sdlIDAndIdentity returns an SDL ID and an identity of the gamepad.
+//
+// The SDL ID is in the same format as SDL's WGI backend, whose driver signature is 'w'.
+// The driver signature prevents the mappings for the other backends from being applied.
+//
+// The identity is RawGameController's NonRoamableId, which is stable for the physical controller on this PC,
+// or an empty string if it is not available.
+func (w *wgiGamepads) sdlIDAndIdentity(gamepad *_IGamepad) (string, string) {
+	var vendor, product uint16
+	var name, identity string
+	var wireless bool
+	var gc *_IGameController
+	if err := gamepad.QueryInterface(&_IID_IGameController, unsafe.Pointer(&gc)); err == nil {
+		wireless, _ = gc.GetIsWireless()
+		if w.rawStatics != nil {
+			if raw, err := w.rawStatics.FromGameController(gc); err == nil && raw != nil {
+				vendor, _ = raw.GetHardwareVendorId()
+				product, _ = raw.GetHardwareProductId()
+				var raw2 *_IRawGameController2
+				if err := raw.QueryInterface(&_IID_IRawGameController2, unsafe.Pointer(&raw2)); err == nil {
+					name, _ = raw2.GetDisplayName()
+					identity, _ = raw2.GetNonRoamableId()
+					raw2.Release()
+				}
+				raw.Release()
+			}
+		}
+		gc.Release()
+	}
+	return wgiSDLGUID(vendor, product, name, wireless), identity
+}
+
+// updateXInputIndices associates the WGI gamepads with the XInput user indices.
+// WGI doesn't report any input while the window is not focused, and XInput is used instead in this case.
+//
+// As there is no reliable way to know the XInput user index of a WGI gamepad, the gamepads are associated
+// in the connection order only when the numbers of the gamepads match.
+func (w *wgiGamepads) updateXInputIndices(gamepads *gamepads, desktop *nativeGamepadsDesktop) error {
+	var natives []*nativeGamepadWGI
+	for _, gp := range gamepads.gamepads {
+		if gp == nil {
+			continue
+		}
+		n, ok := gp.native.(*nativeGamepadWGI)
+		if !ok {
+			continue
+		}
+		if n.xinputIndex >= 0 {
+			_ = n.rumble.stop()
+		}
+		n.xinputIndex = -1
+		n.xinputGuide = false
+		n.guidePressed = false
+		natives = append(natives, n)
+	}
+
+	if desktop.xinput == 0 {
+		return nil
+	}
+
+	var indices []int
+	for i := 0; i < xuserMaxCount; i++ {
+		var xic _XINPUT_CAPABILITIES
+		if err := desktop.xinputGetCapabilities(uint32(i), 0, &xic); err != nil {
+			if !errors.Is(err, windows.ERROR_DEVICE_NOT_CONNECTED) {
+				return err
+			}
+			continue
+		}
+		indices = append(indices, i)
+	}
+
+	if len(indices) != len(natives) {
+		return nil
+	}
+	for i, n := range natives {
+		n.xinputIndex = indices[i]
+		n.xinputGuide = desktop.procXInputGetStateEx != 0
+		n.rumble.setMotorSpeeds = desktop.xinputMotorSpeedsSetter(indices[i])
+	}
+	return nil
+}
+
+// wgiBatteryUpdateInterval is the interval to query the battery report, which involves allocations of WinRT objects.
+const wgiBatteryUpdateInterval = 5 * time.Second
+
+type nativeGamepadWGI struct {
+	gamepad     *_IGamepad
+	batteryInfo *_IGameControllerBatteryInfo
+
+	sdlID string
+
+	// identity identifies the physical controller, or is an empty string if unknown.
+	identity string
+
+	reading _GamepadReading
+
+	// xinputIndex is the XInput user index used while the window is not focused, or -1 if unknown.
+	xinputIndex int
+
+	// xinputGuide reports whether the Guide button is available via XInputGetStateEx with xinputIndex.
+	// WGI itself doesn't report the Guide button.
+	xinputGuide  bool
+	guidePressed bool
+
+	// axisDeadZoneDisabled reports whether raw axis values are used without the dead zones.
+	axisDeadZoneDisabled bool
+
+	vib    bool
+	vibEnd time.Time
+
+	// rumble is the rumble effect via XInput, used when IGamepad::put_Vibration is not available.
+	rumble rumble
+
+	batteryUpdated time.Time
+	batteryLevel_  int
+	powerState_    PowerState
+}
+
+func newNativeGamepadWGI(gamepad *_IGamepad) *nativeGamepadWGI {
+	n := &nativeGamepadWGI{
+		gamepad:       gamepad,
+		xinputIndex:   -1,
+		batteryLevel_: -1,
+	}
+	// IGameControllerBatteryInfo is available on Windows 10 version 1607 or later.
+	var batteryInfo *_IGameControllerBatteryInfo
+	if err := gamepad.QueryInterface(&_IID_IGameControllerBatteryInfo, unsafe.Pointer(&batteryInfo)); err == nil {
+		n.batteryInfo = batteryInfo
+	}
+	return n
+}
+
+func (n *nativeGamepadWGI) release() {
+	if n.batteryInfo != nil {
+		n.batteryInfo.Release()
+		n.batteryInfo = nil
+	}
+	n.gamepad.Release()
+}
+
+func (n *nativeGamepadWGI) update(gamepads *gamepads) error {
+	n.axisDeadZoneDisabled = gamepads.axisDeadZoneDisabled
+
+	desktop := gamepads.native.(*nativeGamepadsDesktop)
+	if (gamepads.unfocused || n.xinputGuide) && n.xinputIndex >= 0 {
+		var state _XINPUT_STATE
+		if err := desktop.xinputGetState(uint32(n.xinputIndex), &state); err != nil {
+			if !errors.Is(err, windows.ERROR_DEVICE_NOT_CONNECTED) {
+				return err
+			}
+		}
+		if gamepads.unfocused {
+			n.reading = wgiReadingFromXInputState(&state)
+		}
+		n.guidePressed = n.xinputGuide && state.Gamepad.wButtons&_XINPUT_GAMEPAD_GUIDE != 0
+	}
+	if !gamepads.unfocused || n.xinputIndex < 0 {
+		r, err := n.gamepad.GetCurrentReading()
+		if err != nil {
+			return err
+		}
+		n.reading = r
+	}
+
+	if n.vib && time.Now().Sub(n.vibEnd) >= 0 {
+		_ = n.gamepad.PutVibration(_GamepadVibration{})
+		n.vib = false
+	}
+	if n.xinputIndex >= 0 {
+		_ = n.rumble.update(time.Now())
+	}
+
+	if time.Since(n.batteryUpdated) >= wgiBatteryUpdateInterval {
+		n.updateBattery()
+	}
+
+	return nil
+}
+
+func wgiReadingFromXInputState(state *_XINPUT_STATE) _GamepadReading {
+	var r _GamepadReading
+	for _, b := range []struct {
+		xinput uint16
+		wgi    _GamepadButtons
+	}{
+		{_XINPUT_GAMEPAD_A, _GamepadButtonsA},
+		{_XINPUT_GAMEPAD_B, _GamepadButtonsB},
+		{_XINPUT_GAMEPAD_X, _GamepadButtonsX},
+		{_XINPUT_GAMEPAD_Y, _GamepadButtonsY},
+		{_XINPUT_GAMEPAD_LEFT_SHOULDER, _GamepadButtonsLeftShoulder},
+		{_XINPUT_GAMEPAD_RIGHT_SHOULDER, _GamepadButtonsRightShoulder},
+		{_XINPUT_GAMEPAD_BACK, _GamepadButtonsView},
+		{_XINPUT_GAMEPAD_START, _GamepadButtonsMenu},
+		{_XINPUT_GAMEPAD_LEFT_THUMB, _GamepadButtonsLeftThumbstick},
+		{_XINPUT_GAMEPAD_RIGHT_THUMB, _GamepadButtonsRightThumbstick},
+		{_XINPUT_GAMEPAD_DPAD_UP, _GamepadButtonsDPadUp},
+		{_XINPUT_GAMEPAD_DPAD_DOWN, _GamepadButtonsDPadDown},
+		{_XINPUT_GAMEPAD_DPAD_LEFT, _GamepadButtonsDPadLeft},
+		{_XINPUT_GAMEPAD_DPAD_RIGHT, _GamepadButtonsDPadRight},
+	} {
+		if state.Gamepad.wButtons&b.xinput != 0 {
+			r.Buttons |= b.wgi
+		}
+	}
+	r.LeftTrigger = float64(state.Gamepad.bLeftTrigger) / 255
+	r.RightTrigger = float64(state.Gamepad.bRightTrigger) / 255
+	r.LeftThumbstickX = (float64(state.Gamepad.sThumbLX) + 0.5) / 32767.5
+	r.LeftThumbstickY = (float64(state.Gamepad.sThumbLY) + 0.5) / 32767.5
+	r.RightThumbstickX = (float64(state.Gamepad.sThumbRX) + 0.5) / 32767.5
+	r.RightThumbstickY = (float64(state.Gamepad.sThumbRY) + 0.5) / 32767.5
+	return r
+}
+
+func (n *nativeGamepadWGI) updateBattery() {
+	n.batteryUpdated = time.Now()
+	n.batteryLevel_ = -1
+	n.powerState_ = PowerStateUnknown
+
+	if n.batteryInfo == nil {
+		return
+	}
+	report, err := n.batteryInfo.TryGetBatteryReport()
+	if err != nil || report == nil {
+		return
+	}
+	defer report.Release()
+
+	if status, err := report.GetStatus(); err == nil {
+		switch status {
+		case _BatteryStatusNotPresent:
+			n.powerState_ = PowerStateNoBattery
+		case _BatteryStatusDischarging:
+			n.powerState_ = PowerStateOnBattery
+		case _BatteryStatusIdle:
+			n.powerState_ = PowerStateCharged
+		case _BatteryStatusCharging:
+			n.powerState_ = PowerStateCharging
+		}
+	}
+
+	full, ok := report.GetFullChargeCapacityInMilliwattHours()
+	if !ok || full <= 0 {
+		return
+	}
+	remaining, ok := report.GetRemainingCapacityInMilliwattHours()
+	if !ok {
+		return
+	}
+	level := int(int64(remaining) * 100 / int64(full))
+	if level < 0 {
+		level = 0
+	}
+	if level > 100 {
+		level = 100
+	}
+	n.batteryLevel_ = level
+}
+
+func standardButtonToWGIGamepadButton(b gamepaddb.StandardButton) (_GamepadButtons, bool) {
+	switch b {
+	case gamepaddb.StandardButtonRightBottom:
+		return _GamepadButtonsA, true
+	case gamepaddb.StandardButtonRightRight:
+		return _GamepadButtonsB, true
+	case gamepaddb.StandardButtonRightLeft:
+		return _GamepadButtonsX, true
+	case gamepaddb.StandardButtonRightTop:
+		return _GamepadButtonsY, true
+	case gamepaddb.StandardButtonFrontTopLeft:
+		return _GamepadButtonsLeftShoulder, true
+	case gamepaddb.StandardButtonFrontTopRight:
+		return _GamepadButtonsRightShoulder, true
+	case gamepaddb.StandardButtonCenterLeft:
+		return _GamepadButtonsView, true
+	case gamepaddb.StandardButtonCenterRight:
+		return _GamepadButtonsMenu, true
+	case gamepaddb.StandardButtonLeftStick:
+		return _GamepadButtonsLeftThumbstick, true
+	case gamepaddb.StandardButtonRightStick:
+		return _GamepadButtonsRightThumbstick, true
+	case gamepaddb.StandardButtonLeftTop:
+		return _GamepadButtonsDPadUp, true
+	case gamepaddb.StandardButtonLeftBottom:
+		return _GamepadButtonsDPadDown, true
+	case gamepaddb.StandardButtonLeftLeft:
+		return _GamepadButtonsDPadLeft, true
+	case gamepaddb.StandardButtonLeftRight:
+		return _GamepadButtonsDPadRight, true
+	}
+	// The triggers and the Guide button are treated separately.
+	return 0, false
+}
+
+func (n *nativeGamepadWGI) serial() string {
+	return n.identity
+}
+
+func (n *nativeGamepadWGI) hasOwnStandardLayoutMapping() bool {
+	return true
+}
+
+func (n *nativeGamepadWGI) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
+	switch axis {
+	case gamepaddb.StandardAxisLeftStickHorizontal,
+		gamepaddb.StandardAxisLeftStickVertical,
+		gamepaddb.StandardAxisRightStickHorizontal,
+		gamepaddb.StandardAxisRightStickVertical:
+		return axisMappingInput{g: n, axis: int(axis)}
+	}
+	return nil
+}
+
+func (n *nativeGamepadWGI) standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput {
+	switch button {
+	case gamepaddb.StandardButtonFrontBottomLeft,
+		gamepaddb.StandardButtonFrontBottomRight:
+		return buttonMappingInput{g: n, button: int(button)}
+	case gamepaddb.StandardButtonCenterCenter:
+		if !n.xinputGuide {
+			return nil
+		}
+		return buttonMappingInput{g: n, button: int(button)}
+	}
+	if _, ok := standardButtonToWGIGamepadButton(button); !ok {
+		return nil
+	}
+	return buttonMappingInput{g: n, button: int(button)}
+}
+
+func (n *nativeGamepadWGI) axisCount() int {
+	return int(gamepaddb.StandardAxisMax) + 1
+}
+
+func (n *nativeGamepadWGI) buttonCount() int {
+	// The buttons not in the web standard, like the paddles, are not reported.
+	return int(gamepaddb.StandardButtonCenterCenter) + 1
+}
+
+func (n *nativeGamepadWGI) hatCount() int {
+	return 0
+}
+
+func (n *nativeGamepadWGI) thumbstickValues(x, y float64, deadZone float64) (float64, float64) {
+	if n.axisDeadZoneDisabled {
+		return x, y
+	}
+	// Use the same dead zones as XInput.
+	return applyThumbstickDeadZone(x, y, deadZone/32767.5)
+}
+
+func (n *nativeGamepadWGI) axisValue(axis int) float64 {
+	switch gamepaddb.StandardAxis(axis) {
+	case gamepaddb.StandardAxisLeftStickHorizontal:
+		x, _ := n.thumbstickValues(n.reading.LeftThumbstickX, n.reading.LeftThumbstickY, _XINPUT_GAMEPAD_LEFT_THUMB_DEADZONE)
+		return x
+	case gamepaddb.StandardAxisLeftStickVertical:
+		_, y := n.thumbstickValues(n.reading.LeftThumbstickX, n.reading.LeftThumbstickY, _XINPUT_GAMEPAD_LEFT_THUMB_DEADZONE)
+		return -y
+	case gamepaddb.StandardAxisRightStickHorizontal:
+		x, _ := n.thumbstickValues(n.reading.RightThumbstickX, n.reading.RightThumbstickY, _XINPUT_GAMEPAD_RIGHT_THUMB_DEADZONE)
+		return x
+	case gamepaddb.StandardAxisRightStickVertical:
+		_, y := n.thumbstickValues(n.reading.RightThumbstickX, n.reading.RightThumbstickY, _XINPUT_GAMEPAD_RIGHT_THUMB_DEADZONE)
+		return -y
+	}
+	return 0
+}
+
+func (n *nativeGamepadWGI) triggerValue(v float64) float64 {
+	if n.axisDeadZoneDisabled {
+		return v
+	}
+	return applyTriggerThreshold(v, _XINPUT_GAMEPAD_TRIGGER_THRESHOLD/255.0)
+}
+
+func (n *nativeGamepadWGI) buttonValue(button int) float64 {
+	switch gamepaddb.StandardButton(button) {
+	case gamepaddb.StandardButtonFrontBottomLeft:
+		return n.triggerValue(n.reading.LeftTrigger)
+	case gamepaddb.StandardButtonFrontBottomRight:
+		return n.triggerValue(n.reading.RightTrigger)
+	}
+	if n.isButtonPressed(button) {
+		return 1
+	}
+	return 0
+}
+
+func (n *nativeGamepadWGI) isButtonPressed(button int) bool {
+	switch gamepaddb.StandardButton(button) {
+	case gamepaddb.StandardButtonFrontBottomLeft:
+		return n.triggerValue(n.reading.LeftTrigger) > gamepaddb.ButtonPressedThreshold
+	case gamepaddb.StandardButtonFrontBottomRight:
+		return n.triggerValue(n.reading.RightTrigger) > gamepaddb.ButtonPressedThreshold
+	case gamepaddb.StandardButtonCenterCenter:
+		return n.guidePressed
+	}
+
+	b, ok := standardButtonToWGIGamepadButton(gamepaddb.StandardButton(button))
+	if !ok {
+		return false
+	}
+	return n.reading.Buttons&b != 0
+}
+
+func (n *nativeGamepadWGI) hatState(hat int) int {
+	return hatCentered
+}
+
+func (n *nativeGamepadWGI) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+	n.vibrateWithTriggers(duration, strongMagnitude, weakMagnitude, 0, 0)
+}
+
+func (n *nativeGamepadWGI) vibrateWithTriggers(duration time.Duration, strongMagnitude, weakMagnitude, leftTriggerMagnitude, rightTriggerMagnitude float64) {
+	if duration <= 0 || (strongMagnitude <= 0 && weakMagnitude <= 0 && leftTriggerMagnitude <= 0 && rightTriggerMagnitude <= 0) {
+		n.vib = false
+		_ = n.gamepad.PutVibration(_GamepadVibration{})
+		if n.xinputIndex >= 0 {
+			_ = n.rumble.stop()
+		}
+		return
+	}
+	n.vib = true
+	n.vibEnd = time.Now().Add(duration)
+	// The trigger motors are just ignored on the controllers without them.
+	if err := n.gamepad.PutVibration(_GamepadVibration{
+		LeftMotor:    strongMagnitude,
+		RightMotor:   weakMagnitude,
+		LeftTrigger:  leftTriggerMagnitude,
+		RightTrigger: rightTriggerMagnitude,
+	}); err != nil && n.xinputIndex >= 0 {
+		// IGamepad::put_Vibration is not available e.g. on arm64. Use XInput instead without the trigger motors.
+		n.vib = false
+		_ = n.rumble.start(time.Now(), duration, strongMagnitude, weakMagnitude)
+	}
+}
+
+func (n *nativeGamepadWGI) setPlayerIndex(index int) {
+}
+
+func (n *nativeGamepadWGI) batteryLevel() (int, bool) {
+	if n.batteryLevel_ < 0 {
+		return 0, false
+	}
+	return n.batteryLevel_, true
+}
+
+func (n *nativeGamepadWGI) powerState() PowerState {
+	return n.powerState_
+}
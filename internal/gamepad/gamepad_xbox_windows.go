@@ -15,6 +15,7 @@
 package gamepad
 
 import (
+	"sync"
 	"time"
 	"unsafe"
 
@@ -57,16 +58,37 @@ func standardButtonToGamepadInputGamepadButton(b gamepaddb.StandardButton) (_Gam
 		return _GameInputGamepadDPadLeft, true
 	case gamepaddb.StandardButtonLeftRight:
 		return _GameInputGamepadDPadRight, true
-	case gamepaddb.StandardButtonCenterCenter:
-		return 0, false
 	}
+	// The Guide button is not a part of the gamepad state, and is reported by a callback if available.
 	return 0, false
 }
 
+// nativeGamepadsXbox is the GameInput backend.
+// This is used on Xbox, and also on desktop Windows when GameInput is available.
 type nativeGamepadsXbox struct {
 	gameInput         *_IGameInput
 	deviceCallbackPtr uintptr
 	token             _GameInputCallbackToken
+
+	// deviceEvents is the queue of the device events. The device callback might be called on another thread,
+	// then the events are processed at update.
+	deviceEvents []gameInputDeviceEvent
+	m            sync.Mutex
+
+	// guideButtonAvailable reports whether the Guide button is reported by the guide button callback.
+	// The callback is not implemented on some platforms, and the Guide button is not available there.
+	guideButtonAvailable   bool
+	guideButtonCallbackPtr uintptr
+	guideButtonToken       _GameInputCallbackToken
+
+	// guideButtonPressed is the state of the Guide buttons by devices, which is updated by the guide button callback.
+	// This is protected by m.
+	guideButtonPressed map[*_IGameInputDevice]bool
+}
+
+type gameInputDeviceEvent struct {
+	device    *_IGameInputDevice
+	connected bool
 }
 
 func (n *nativeGamepadsXbox) init(gamepads *gamepads) error {
@@ -83,51 +105,137 @@ func (n *nativeGamepadsXbox) init(gamepads *gamepads) error {
 		_GameInputKindGamepad,
 		_GameInputDeviceConnected,
 		_GameInputBlockingEnumeration,
-		unsafe.Pointer(gamepads),
+		nil,
 		n.deviceCallbackPtr,
 		&n.token,
 	); err != nil {
 		return err
 	}
+
+	n.guideButtonCallbackPtr = windows.NewCallbackCDecl(n.guideButtonCallback)
+	if err := n.gameInput.RegisterGuideButtonCallback(nil, nil, n.guideButtonCallbackPtr, &n.guideButtonToken); err == nil {
+		n.guideButtonAvailable = true
+	}
 	return nil
 }
 
 func (n *nativeGamepadsXbox) update(gamepads *gamepads) error {
+	n.m.Lock()
+	events := n.deviceEvents
+	n.deviceEvents = nil
+	for _, e := range events {
+		if !e.connected {
+			delete(n.guideButtonPressed, e.device)
+		}
+	}
+	n.m.Unlock()
+
+	for _, e := range events {
+		if e.connected {
+			name, sdlID := gameInputDeviceNameAndSDLID(e.device)
+			gp := gamepads.add(name, sdlID)
+			gp.native = &nativeGamepadXbox{
+				gameInput:            n.gameInput,
+				gameInputDevice:      e.device,
+				guideButtonAvailable: n.guideButtonAvailable,
+			}
+			continue
+		}
+
+		gamepads.remove(func(gamepad *Gamepad) bool {
+			g, ok := gamepad.native.(*nativeGamepadXbox)
+			if !ok || g.gameInputDevice != e.device {
+				return false
+			}
+			g.gameInputDevice.Release()
+			return true
+		})
+	}
+
+	if n.guideButtonAvailable {
+		n.m.Lock()
+		for _, gp := range gamepads.gamepads {
+			if gp == nil {
+				continue
+			}
+			g, ok := gp.native.(*nativeGamepadXbox)
+			if !ok {
+				continue
+			}
+			g.guideButtonPressed = n.guideButtonPressed[g.gameInputDevice]
+		}
+		n.m.Unlock()
+	}
+
 	return nil
 }
 
 func (n *nativeGamepadsXbox) deviceCallback(callbackToken _GameInputCallbackToken, context unsafe.Pointer, device *_IGameInputDevice, timestamp uint64, currentStatus _GameInputDeviceStatus, previousStatus _GameInputDeviceStatus) uintptr {
-	gps := (*gamepads)(context)
+	n.m.Lock()
+	defer n.m.Unlock()
 
 	// Connected.
 	if currentStatus&_GameInputDeviceConnected != 0 {
-		// TODO: Give a good name and a SDL ID.
-		gp := gps.add("", "00000000000000000000000000000000")
-		gp.native = &nativeGamepadXbox{
-			gameInputDevice: device,
-		}
+		// The device is released when its disconnection is processed.
+		device.AddRef()
+		n.deviceEvents = append(n.deviceEvents, gameInputDeviceEvent{
+			device:    device,
+			connected: true,
+		})
 		return 0
 	}
 
 	// Disconnected.
-	gps.remove(func(gamepad *Gamepad) bool {
-		return gamepad.native.(*nativeGamepadXbox).gameInputDevice == device
+	n.deviceEvents = append(n.deviceEvents, gameInputDeviceEvent{
+		device:    device,
+		connected: false,
 	})
+	return 0
+}
 
+func (n *nativeGamepadsXbox) guideButtonCallback(callbackToken _GameInputCallbackToken, context unsafe.Pointer, device *_IGameInputDevice, timestamp uint64, isPressed bool) uintptr {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if n.guideButtonPressed == nil {
+		n.guideButtonPressed = map[*_IGameInputDevice]bool{}
+	}
+	n.guideButtonPressed[device] = isPressed
 	return 0
 }
 
+// gameInputDeviceNameAndSDLID returns a name and an SDL ID of the device.
+// The SDL ID has a driver signature 'g' so that the mappings for the other backends are not applied.
+func gameInputDeviceNameAndSDLID(device *_IGameInputDevice) (string, string) {
+	var vendor, product uint16
+	if info := device.GetDeviceInfo(); info != nil {
+		vendor = info.vendorId
+		product = info.productId
+	}
+
+	name := "GameInput Gamepad"
+	// 0x045e is Microsoft's vendor ID.
+	if vendor == 0x045e {
+		name = "Xbox Controller"
+	}
+	sdlID := sdlGUID(sdlHardwareBusUSB, vendor, product, 0, name, sdlDriverSignatureGameInput, 0)
+	return name, sdlID
+}
+
 type nativeGamepadXbox struct {
+	gameInput       *_IGameInput
 	gameInputDevice *_IGameInputDevice
 	state           _GameInputGamepadState
 
+	guideButtonAvailable bool
+	guideButtonPressed   bool
+
 	vib    bool
 	vibEnd time.Time
 }
 
 func (n *nativeGamepadXbox) update(gamepads *gamepads) error {
-	gameInput := gamepads.native.(*nativeGamepadsXbox).gameInput
-	r, err := gameInput.GetCurrentReading(_GameInputKindGamepad, n.gameInputDevice)
+	r, err := n.gameInput.GetCurrentReading(_GameInputKindGamepad, n.gameInputDevice)
 	if err != nil {
 		return err
 	}
@@ -171,6 +279,11 @@ func (n *nativeGamepadXbox) standardButtonInOwnMapping(button gamepaddb.Standard
 	case gamepaddb.StandardButtonFrontBottomLeft,
 		gamepaddb.StandardButtonFrontBottomRight:
 		return buttonMappingInput{g: n, button: int(button)}
+	case gamepaddb.StandardButtonCenterCenter:
+		if !n.guideButtonAvailable {
+			return nil
+		}
+		return buttonMappingInput{g: n, button: int(button)}
 	}
 	if _, ok := standardButtonToGamepadInputGamepadButton(button); !ok {
 		return nil
@@ -183,7 +296,8 @@ func (n *nativeGamepadXbox) axisCount() int {
 }
 
 func (n *nativeGamepadXbox) buttonCount() int {
-	return int(gamepaddb.StandardButtonMax) + 1
+	// The buttons not in the web standard, like the paddles, are not reported.
+	return int(gamepaddb.StandardButtonCenterCenter) + 1
 }
 
 func (n *nativeGamepadXbox) hatCount() int {
@@ -210,6 +324,11 @@ func (n *nativeGamepadXbox) buttonValue(button int) float64 {
 		return float64(n.state.leftTrigger)
 	case gamepaddb.StandardButtonFrontBottomRight:
 		return float64(n.state.rightTrigger)
+	case gamepaddb.StandardButtonCenterCenter:
+		if n.guideButtonPressed {
+			return 1
+		}
+		return 0
 	}
 	b, ok := standardButtonToGamepadInputGamepadButton(gamepaddb.StandardButton(button))
 	if !ok {
@@ -227,6 +346,8 @@ func (n *nativeGamepadXbox) isButtonPressed(button int) bool {
 		return n.state.leftTrigger > gamepaddb.ButtonPressedThreshold
 	case gamepaddb.StandardButtonFrontBottomRight:
 		return n.state.rightTrigger > gamepaddb.ButtonPressedThreshold
+	case gamepaddb.StandardButtonCenterCenter:
+		return n.guideButtonPressed
 	}
 
 	b, ok := standardButtonToGamepadInputGamepadButton(gamepaddb.StandardButton(button))
@@ -244,7 +365,11 @@ func (n *nativeGamepadXbox) hatState(hat int) int {
 }
 
 func (n *nativeGamepadXbox) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
-	if strongMagnitude <= 0 && weakMagnitude <= 0 {
+	n.vibrateWithTriggers(duration, strongMagnitude, weakMagnitude, 0, 0)
+}
+
+func (n *nativeGamepadXbox) vibrateWithTriggers(duration time.Duration, strongMagnitude, weakMagnitude, leftTriggerMagnitude, rightTriggerMagnitude float64) {
+	if strongMagnitude <= 0 && weakMagnitude <= 0 && leftTriggerMagnitude <= 0 && rightTriggerMagnitude <= 0 {
 		n.vib = false
 		n.gameInputDevice.SetRumbleState(&_GameInputRumbleParams{
 			lowFrequency:  0,
@@ -257,5 +382,18 @@ func (n *nativeGamepadXbox) vibrate(duration time.Duration, strongMagnitude floa
 	n.gameInputDevice.SetRumbleState(&_GameInputRumbleParams{
 		lowFrequency:  float32(strongMagnitude),
 		highFrequency: float32(weakMagnitude),
+		leftTrigger:   float32(leftTriggerMagnitude),
+		rightTrigger:  float32(rightTriggerMagnitude),
 	}, 0)
 }
+
+func (n *nativeGamepadXbox) setPlayerIndex(index int) {
+}
+
+func (n *nativeGamepadXbox) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (n *nativeGamepadXbox) powerState() PowerState {
+	return PowerStateUnknown
+}
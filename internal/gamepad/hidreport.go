@@ -0,0 +1,486 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The usage pages and the usages of HID.
+// See the HID Usage Tables: https://usb.org/document-library/hid-usage-tables-15
+const (
+	hidUsagePageGenericDesktop = 0x01
+	hidUsagePageSimulation     = 0x02
+	hidUsagePageButton         = 0x09
+	hidUsagePageConsumer       = 0x0c
+
+	hidUsageGDJoystick            = 0x04
+	hidUsageGDGamePad             = 0x05
+	hidUsageGDMultiAxisController = 0x08
+	hidUsageGDX                   = 0x30
+	hidUsageGDY                   = 0x31
+	hidUsageGDZ                   = 0x32
+	hidUsageGDRx                  = 0x33
+	hidUsageGDRy                  = 0x34
+	hidUsageGDRz                  = 0x35
+	hidUsageGDSlider              = 0x36
+	hidUsageGDDial                = 0x37
+	hidUsageGDWheel               = 0x38
+	hidUsageGDHatSwitch           = 0x39
+	hidUsageGDStart               = 0x3d
+	hidUsageGDSelect              = 0x3e
+	hidUsageGDDPadUp              = 0x90
+	hidUsageGDDPadDown            = 0x91
+	hidUsageGDDPadRight           = 0x92
+	hidUsageGDDPadLeft            = 0x93
+	hidUsageGDSystemMainMenu      = 0x85
+
+	hidUsageSimRudder      = 0xba
+	hidUsageSimThrottle    = 0xbb
+	hidUsageSimAccelerator = 0xc4
+	hidUsageSimBrake       = 0xc5
+	hidUsageSimSteering    = 0xc8
+)
+
+// The item types and the item tags of HID report descriptors.
+// See the Device Class Definition for HID 1.11, 6.2.2.
+const (
+	hidItemTypeMain   = 0
+	hidItemTypeGlobal = 1
+	hidItemTypeLocal  = 2
+
+	hidMainItemInput         = 0x8
+	hidMainItemCollection    = 0xa
+	hidMainItemEndCollection = 0xc
+
+	hidGlobalItemUsagePage   = 0x0
+	hidGlobalItemLogicalMin  = 0x1
+	hidGlobalItemLogicalMax  = 0x2
+	hidGlobalItemReportSize  = 0x7
+	hidGlobalItemReportID    = 0x8
+	hidGlobalItemReportCount = 0x9
+	hidGlobalItemPush        = 0xa
+	hidGlobalItemPop         = 0xb
+
+	hidLocalItemUsage    = 0x0
+	hidLocalItemUsageMin = 0x1
+	hidLocalItemUsageMax = 0x2
+
+	hidLongItemPrefix = 0xfe
+)
+
+const (
+	hidInputFlagConstant     = 0x01
+	hidInputFlagVariable     = 0x02
+	hidCollectionApplication = 0x01
+)
+
+// hidMaxReportItemBitSize is the maximum bit size of a report item handled here.
+const hidMaxReportItemBitSize = 32
+
+// hidReportItem is a variable input item in an input report.
+type hidReportItem struct {
+	reportID uint8
+
+	// bitOffset is the offset in bits from the head of the report data, excluding the report ID.
+	bitOffset int
+	bitSize   int
+
+	usagePage uint16
+	usage     uint16
+
+	logicalMin int32
+	logicalMax int32
+}
+
+// value returns the value of the item in the report data, excluding the report ID.
+// value returns false if the report data is too short.
+func (h *hidReportItem) value(data []byte) (int32, bool) {
+	if (h.bitOffset+h.bitSize+7)/8 > len(data) {
+		return 0, false
+	}
+	var v uint32
+	for i := 0; i < h.bitSize; i++ {
+		bit := h.bitOffset + i
+		if data[bit/8]&(1<<(bit%8)) != 0 {
+			v |= 1 << i
+		}
+	}
+	// The value is signed only when the logical minimum is negative.
+	if h.logicalMin < 0 && h.bitSize < 32 && v&(1<<(h.bitSize-1)) != 0 {
+		v |= ^uint32(0) << h.bitSize
+	}
+	return int32(v), true
+}
+
+// hidReportDescriptor is a parsed HID report descriptor.
+type hidReportDescriptor struct {
+	// inputs is the list of the variable input items. Array items and padding are not included.
+	inputs []hidReportItem
+
+	// applications is the list of the usages of the top-level application collections.
+	// The usage page is stored in the upper 16 bits.
+	applications []uint32
+}
+
+type hidGlobalState struct {
+	usagePage  uint16
+	logicalMin int32
+	logicalMax int32
+	reportSize int
+	reportID   uint8
+	reportCnt  int
+
+	// logicalMaxUnsigned is the logical maximum interpreted as an unsigned value.
+	// Some devices use e.g. 0xff as the logical maximum in one byte, which is -1 strictly.
+	logicalMaxUnsigned int32
+}
+
+// parseHIDReportDescriptor parses a HID report descriptor.
+func parseHIDReportDescriptor(desc []byte) (*hidReportDescriptor, error) {
+	var r hidReportDescriptor
+
+	var global hidGlobalState
+	var globalStack []hidGlobalState
+
+	var usages []uint32
+	var usageMin, usageMax uint32
+	var usageMinSet, usageMaxSet bool
+
+	var collectionDepth int
+	bitOffsets := map[uint8]int{}
+
+	for i := 0; i < len(desc); {
+		prefix := desc[i]
+		if prefix == hidLongItemPrefix {
+			if i+1 >= len(desc) {
+				return nil, fmt.Errorf("gamepad: truncated long item at %d", i)
+			}
+			i += 3 + int(desc[i+1])
+			continue
+		}
+
+		size := int(prefix & 0x3)
+		if size == 3 {
+			size = 4
+		}
+		typ := (prefix >> 2) & 0x3
+		tag := prefix >> 4
+		if i+1+size > len(desc) {
+			return nil, fmt.Errorf("gamepad: truncated item at %d", i)
+		}
+		var udata uint32
+		for j := 0; j < size; j++ {
+			udata |= uint32(desc[i+1+j]) << (8 * j)
+		}
+		// sdata is the data interpreted as a signed value.
+		sdata := int32(udata)
+		if size > 0 && size < 4 && udata&(1<<(8*size-1)) != 0 {
+			sdata = int32(udata | ^uint32(0)<<(8*size))
+		}
+		i += 1 + size
+
+		switch typ {
+		case hidItemTypeMain:
+			switch tag {
+			case hidMainItemInput:
+				bitOffset := bitOffsets[global.reportID]
+				if udata&(hidInputFlagConstant|hidInputFlagVariable) == hidInputFlagVariable {
+					logicalMax := global.logicalMax
+					if global.logicalMin >= 0 && logicalMax < global.logicalMin {
+						logicalMax = global.logicalMaxUnsigned
+					}
+					for j := 0; j < global.reportCnt; j++ {
+						var usage uint32
+						switch {
+						case len(usages) > 0:
+							if j < len(usages) {
+								usage = usages[j]
+							} else {
+								usage = usages[len(usages)-1]
+							}
+						case usageMinSet:
+							usage = usageMin + uint32(j)
+							if usageMaxSet && usage > usageMax {
+								usage = usageMax
+							}
+						default:
+							continue
+						}
+						page := global.usagePage
+						if usage>>16 != 0 {
+							page = uint16(usage >> 16)
+						}
+						if global.reportSize == 0 || global.reportSize > hidMaxReportItemBitSize {
+							continue
+						}
+						r.inputs = append(r.inputs, hidReportItem{
+							reportID:   global.reportID,
+							bitOffset:  bitOffset + j*global.reportSize,
+							bitSize:    global.reportSize,
+							usagePage:  page,
+							usage:      uint16(usage),
+							logicalMin: global.logicalMin,
+							logicalMax: logicalMax,
+						})
+					}
+				}
+				bitOffsets[global.reportID] = bitOffset + global.reportSize*global.reportCnt
+			case hidMainItemCollection:
+				if collectionDepth == 0 && udata == hidCollectionApplication && len(usages) > 0 {
+					usage := usages[0]
+					if usage>>16 == 0 {
+						usage |= uint32(global.usagePage) << 16
+					}
+					r.applications = append(r.applications, usage)
+				}
+				collectionDepth++
+			case hidMainItemEndCollection:
+				if collectionDepth == 0 {
+					return nil, fmt.Errorf("gamepad: unbalanced end collection at %d", i-1-size)
+				}
+				collectionDepth--
+			}
+			usages = usages[:0]
+			usageMinSet = false
+			usageMaxSet = false
+
+		case hidItemTypeGlobal:
+			switch tag {
+			case hidGlobalItemUsagePage:
+				global.usagePage = uint16(udata)
+			case hidGlobalItemLogicalMin:
+				global.logicalMin = sdata
+			case hidGlobalItemLogicalMax:
+				global.logicalMax = sdata
+				global.logicalMaxUnsigned = int32(udata)
+			case hidGlobalItemReportSize:
+				global.reportSize = int(udata)
+			case hidGlobalItemReportID:
+				global.reportID = uint8(udata)
+			case hidGlobalItemReportCount:
+				global.reportCnt = int(udata)
+			case hidGlobalItemPush:
+				globalStack = append(globalStack, global)
+			case hidGlobalItemPop:
+				if len(globalStack) == 0 {
+					return nil, fmt.Errorf("gamepad: unbalanced pop at %d", i-1-size)
+				}
+				global = globalStack[len(globalStack)-1]
+				globalStack = globalStack[:len(globalStack)-1]
+			}
+
+		case hidItemTypeLocal:
+			switch tag {
+			case hidLocalItemUsage:
+				usages = append(usages, udata)
+			case hidLocalItemUsageMin:
+				usageMin = udata
+				usageMinSet = true
+			case hidLocalItemUsageMax:
+				usageMax = udata
+				usageMaxSet = true
+			}
+		}
+	}
+
+	return &r, nil
+}
+
+// inputReportSize returns the byte size of the input report data with reportID, excluding the report ID.
+func (h *hidReportDescriptor) inputReportSize(reportID uint8) int {
+	var bits int
+	for _, item := range h.inputs {
+		if item.reportID != reportID {
+			continue
+		}
+		if b := item.bitOffset + item.bitSize; bits < b {
+			bits = b
+		}
+	}
+	return (bits + 7) / 8
+}
+
+// isGamepad reports whether the device has a top-level collection of a joystick or a gamepad.
+func (h *hidReportDescriptor) isGamepad() bool {
+	for _, a := range h.applications {
+		if a>>16 != hidUsagePageGenericDesktop {
+			continue
+		}
+		switch a & 0xffff {
+		case hidUsageGDJoystick, hidUsageGDGamePad, hidUsageGDMultiAxisController:
+			return true
+		}
+	}
+	return false
+}
+
+type hidReportItems []hidReportItem
+
+func (h hidReportItems) Len() int {
+	return len(h)
+}
+
+func (h hidReportItems) Less(i, j int) bool {
+	return h[i].usage < h[j].usage
+}
+
+func (h hidReportItems) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+// hidGamepad is the state of a gamepad parsed from HID input reports.
+//
+// The axes, the buttons, and the hats are classified and ordered in the same way as the IOKit backend.
+type hidGamepad struct {
+	axes    hidReportItems
+	buttons hidReportItems
+	hats    hidReportItems
+
+	axisValues   []float64
+	buttonValues []bool
+	hatValues    []int
+}
+
+// newHIDGamepad creates a hidGamepad from the items of the input report with reportID.
+func newHIDGamepad(desc *hidReportDescriptor, reportID uint8) *hidGamepad {
+	var g hidGamepad
+	for _, item := range desc.inputs {
+		if item.reportID != reportID {
+			continue
+		}
+		switch item.usagePage {
+		case hidUsagePageGenericDesktop:
+			switch item.usage {
+			case hidUsageGDX, hidUsageGDY, hidUsageGDZ,
+				hidUsageGDRx, hidUsageGDRy, hidUsageGDRz,
+				hidUsageGDSlider, hidUsageGDDial, hidUsageGDWheel:
+				g.axes = append(g.axes, item)
+			case hidUsageGDHatSwitch:
+				g.hats = append(g.hats, item)
+			case hidUsageGDDPadUp, hidUsageGDDPadRight, hidUsageGDDPadDown, hidUsageGDDPadLeft,
+				hidUsageGDSystemMainMenu, hidUsageGDSelect, hidUsageGDStart:
+				g.buttons = append(g.buttons, item)
+			}
+		case hidUsagePageSimulation:
+			switch item.usage {
+			case hidUsageSimAccelerator, hidUsageSimBrake, hidUsageSimThrottle, hidUsageSimRudder, hidUsageSimSteering:
+				g.axes = append(g.axes, item)
+			}
+		case hidUsagePageButton, hidUsagePageConsumer:
+			g.buttons = append(g.buttons, item)
+		}
+	}
+
+	sort.Stable(g.axes)
+	sort.Stable(g.buttons)
+	sort.Stable(g.hats)
+
+	g.axisValues = make([]float64, len(g.axes))
+	g.buttonValues = make([]bool, len(g.buttons))
+	g.hatValues = make([]int, len(g.hats))
+	return &g
+}
+
+// setReport updates the state with the input report data, excluding the report ID.
+// The items out of the data are not updated.
+func (g *hidGamepad) setReport(data []byte) {
+	for i, a := range g.axes {
+		raw, ok := a.value(data)
+		if !ok {
+			continue
+		}
+		var value float64
+		if size := a.logicalMax - a.logicalMin; size > 0 {
+			value = 2*float64(raw-a.logicalMin)/float64(size) - 1
+		}
+		if value < -1 {
+			value = -1
+		}
+		if value > 1 {
+			value = 1
+		}
+		g.axisValues[i] = value
+	}
+
+	for i, b := range g.buttons {
+		raw, ok := b.value(data)
+		if !ok {
+			continue
+		}
+		g.buttonValues[i] = raw-b.logicalMin > 0
+	}
+
+	hatStates := []int{
+		hatUp,
+		hatRightUp,
+		hatRight,
+		hatRightDown,
+		hatDown,
+		hatLeftDown,
+		hatLeft,
+		hatLeftUp,
+	}
+	for i, h := range g.hats {
+		raw, ok := h.value(data)
+		if !ok {
+			continue
+		}
+		state := int(raw - h.logicalMin)
+		// A hat switch with only four directions doesn't have the diagonal states.
+		if h.logicalMax-h.logicalMin+1 == 4 {
+			state *= 2
+		}
+		if state < 0 || state >= len(hatStates) {
+			g.hatValues[i] = hatCentered
+		} else {
+			g.hatValues[i] = hatStates[state]
+		}
+	}
+}
+
+func (g *hidGamepad) axisCount() int {
+	return len(g.axisValues)
+}
+
+func (g *hidGamepad) buttonCount() int {
+	return len(g.buttonValues)
+}
+
+func (g *hidGamepad) hatCount() int {
+	return len(g.hatValues)
+}
+
+func (g *hidGamepad) axisValue(axis int) float64 {
+	if axis < 0 || axis >= len(g.axisValues) {
+		return 0
+	}
+	return g.axisValues[axis]
+}
+
+func (g *hidGamepad) isButtonPressed(button int) bool {
+	if button < 0 || button >= len(g.buttonValues) {
+		return false
+	}
+	return g.buttonValues[button]
+}
+
+func (g *hidGamepad) hatState(hat int) int {
+	if hat < 0 || hat >= len(g.hatValues) {
+		return hatCentered
+	}
+	return g.hatValues[hat]
+}
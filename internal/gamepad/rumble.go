@@ -0,0 +1,67 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"time"
+)
+
+// rumble manages a rumble effect for an API that only sets the motor speeds without a duration, like XInputSetState.
+// The motors are stopped at update after the duration elapses.
+type rumble struct {
+	// setMotorSpeeds sets the speeds of the low-frequency and the high-frequency motors.
+	setMotorSpeeds func(low, high uint16) error
+
+	active bool
+	end    time.Time
+}
+
+func (r *rumble) start(now time.Time, duration time.Duration, strongMagnitude, weakMagnitude float64) error {
+	if duration <= 0 || (strongMagnitude <= 0 && weakMagnitude <= 0) {
+		return r.stop()
+	}
+
+	if err := r.setMotorSpeeds(rumbleMotorSpeed(strongMagnitude), rumbleMotorSpeed(weakMagnitude)); err != nil {
+		return err
+	}
+	r.active = true
+	r.end = now.Add(duration)
+	return nil
+}
+
+func (r *rumble) update(now time.Time) error {
+	if !r.active || now.Before(r.end) {
+		return nil
+	}
+	return r.stop()
+}
+
+func (r *rumble) stop() error {
+	if !r.active {
+		return nil
+	}
+	r.active = false
+	return r.setMotorSpeeds(0, 0)
+}
+
+func rumbleMotorSpeed(magnitude float64) uint16 {
+	if magnitude <= 0 {
+		return 0
+	}
+	if magnitude >= 1 {
+		return 0xffff
+	}
+	return uint16(magnitude * 0xffff)
+}
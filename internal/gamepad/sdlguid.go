@@ -0,0 +1,132 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+)
+
+// The bus types in SDL GUIDs. These are the same as Linux's BUS_* values.
+const (
+	sdlHardwareBusUSB       = 0x03
+	sdlHardwareBusBluetooth = 0x05
+)
+
+// The driver signatures in SDL GUIDs. A driver signature identifies a backend other than the platform's default one.
+const (
+	sdlDriverSignatureNone      = 0
+	sdlDriverSignatureGameInput = 'g'
+	sdlDriverSignatureHIDAPI    = 'h'
+	sdlDriverSignatureMFi       = 'm'
+	sdlDriverSignatureWGI       = 'w'
+)
+
+// sdlGUID returns an SDL GUID in the same way as SDL_CreateJoystickGUID.
+//
+// The bus, the CRC16 of the name, the vendor, the product, and the version are encoded in little endian on any platform.
+// If the vendor or the product is unknown, the name is stored instead of them.
+// The name is truncated so that the terminating null character fits, as SDL does with SDL_strlcpy.
+//
+// SDL 2.26 or later stores the CRC at the bytes 2 and 3, and newer mappings in the game controller database are keyed with it.
+// Mappings without the CRC still match the GUID, as the database ignores the CRC in that case.
+func sdlGUID(bus, vendor, product, version uint16, name string, driverSignature, driverData byte) string {
+	guid := sdlGUIDBytes(bus, vendor, product, version, name, driverSignature, driverData)
+	return hex.EncodeToString(guid[:])
+}
+
+func sdlGUIDBytes(bus, vendor, product, version uint16, name string, driverSignature, driverData byte) [16]byte {
+	var guid [16]byte
+	binary.LittleEndian.PutUint16(guid[0:2], bus)
+	binary.LittleEndian.PutUint16(guid[2:4], sdlCRC16(name))
+	if vendor != 0 && product != 0 {
+		binary.LittleEndian.PutUint16(guid[4:6], vendor)
+		binary.LittleEndian.PutUint16(guid[8:10], product)
+		binary.LittleEndian.PutUint16(guid[12:14], version)
+		guid[14] = driverSignature
+		guid[15] = driverData
+		return guid
+	}
+
+	nameBytes := guid[4:15]
+	if driverSignature != sdlDriverSignatureNone {
+		nameBytes = guid[4:13]
+		guid[14] = driverSignature
+		guid[15] = driverData
+	}
+	copy(nameBytes, name)
+	return guid
+}
+
+// sdlCRC16 returns the CRC16 of the string in the same way as SDL_crc16 (CRC-16/ARC).
+func sdlCRC16(str string) uint16 {
+	var crc uint16
+	for i := 0; i < len(str); i++ {
+		crc ^= uint16(str[i])
+		for j := 0; j < 8; j++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// androidSDLGUID returns an SDL GUID in the same way as SDL's Android backend.
+// SDL's Android backend gives the device descriptor instead of the name to SDL_CreateJoystickGUID,
+// and then overwrites the bytes 12 to 15 with the masks of the buttons and the axes.
+// https://github.com/libsdl-org/SDL/blob/release-2.26.0/src/joystick/android/SDL_sysjoystick.c
+func androidSDLGUID(vendor, product uint16, descriptor string, buttonMask, axisMask uint16) string {
+	guid := sdlGUIDBytes(sdlHardwareBusBluetooth, vendor, product, 0, descriptor, sdlDriverSignatureNone, 0)
+	binary.LittleEndian.PutUint16(guid[12:14], buttonMask)
+	binary.LittleEndian.PutUint16(guid[14:16], axisMask)
+	return hex.EncodeToString(guid[:])
+}
+
+// sdlJoystickTypeGamepad is SDL_JOYSTICK_TYPE_GAMECONTROLLER, which SDL's WGI backend stores as the driver data.
+const sdlJoystickTypeGamepad = 1
+
+// wgiSDLGUID returns an SDL GUID in the same way as SDL's WGI backend.
+// SDL's WGI backend gives RawGameController's DisplayName as the name, or an empty string if it is not available,
+// and stores the joystick type as the driver data. The version is always 0.
+// https://github.com/libsdl-org/SDL/blob/release-2.26.0/src/joystick/windows/SDL_windows_gaming_input.c
+func wgiSDLGUID(vendor, product uint16, name string, wireless bool) string {
+	bus := uint16(sdlHardwareBusUSB)
+	if wireless {
+		bus = sdlHardwareBusBluetooth
+	}
+	return sdlGUID(bus, vendor, product, 0, strings.TrimSpace(name), sdlDriverSignatureWGI, sdlJoystickTypeGamepad)
+}
+
+// xinputSDLGUID returns an SDL GUID in the legacy format of SDL's XInput backend.
+// This format is used when the vendor and the product are not available, and the game controller database still has mappings for it.
+func xinputSDLGUID(subType byte) string {
+	var guid [16]byte
+	copy(guid[:], "xinput")
+	guid[6] = subType
+	return hex.EncodeToString(guid[:])
+}
+
+// emscriptenSDLGUID returns an SDL GUID in the same way as SDL's Emscripten backend.
+// The GUID is just the first 16 bytes of the name, without any bus type and a terminating null character.
+// https://github.com/libsdl-org/SDL/blob/0e9560aea22818884921e5e5064953257bfe7fa7/src/joystick/emscripten/SDL_sysjoystick.c#L385
+func emscriptenSDLGUID(name string) string {
+	var guid [16]byte
+	copy(guid[:], name)
+	return hex.EncodeToString(guid[:])
+}
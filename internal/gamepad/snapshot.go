@@ -0,0 +1,84 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+// inputSnapshot is the cached state of the axes and the buttons of a gamepad.
+//
+// Some browsers report different numbers of the axes and the buttons of the same gamepad between frames,
+// and even report empty snapshots transiently. inputSnapshot keeps the state consistent with the latest valid snapshot.
+type inputSnapshot struct {
+	axes          []float64
+	buttonValues  []float64
+	buttonPressed []bool
+}
+
+// update updates the state with a new snapshot.
+// axis and button return the values at the given index in the new snapshot.
+//
+// A snapshot without any axes and buttons is treated as no change, rather than all the buttons being released.
+// update reports whether the state is updated.
+func (s *inputSnapshot) update(axisCount, buttonCount int, axis func(index int) float64, button func(index int) (float64, bool)) bool {
+	if axisCount == 0 && buttonCount == 0 {
+		return false
+	}
+
+	if cap(s.axes) < axisCount {
+		s.axes = make([]float64, axisCount)
+	}
+	s.axes = s.axes[:axisCount]
+	for i := range s.axes {
+		s.axes[i] = axis(i)
+	}
+
+	if cap(s.buttonValues) < buttonCount {
+		s.buttonValues = make([]float64, buttonCount)
+		s.buttonPressed = make([]bool, buttonCount)
+	}
+	s.buttonValues = s.buttonValues[:buttonCount]
+	s.buttonPressed = s.buttonPressed[:buttonCount]
+	for i := range s.buttonValues {
+		s.buttonValues[i], s.buttonPressed[i] = button(i)
+	}
+	return true
+}
+
+func (s *inputSnapshot) axisCount() int {
+	return len(s.axes)
+}
+
+func (s *inputSnapshot) buttonCount() int {
+	return len(s.buttonValues)
+}
+
+func (s *inputSnapshot) axisValue(axis int) float64 {
+	if axis < 0 || axis >= len(s.axes) {
+		return 0
+	}
+	return s.axes[axis]
+}
+
+func (s *inputSnapshot) buttonValue(button int) float64 {
+	if button < 0 || button >= len(s.buttonValues) {
+		return 0
+	}
+	return s.buttonValues[button]
+}
+
+func (s *inputSnapshot) isButtonPressed(button int) bool {
+	if button < 0 || button >= len(s.buttonPressed) {
+		return false
+	}
+	return s.buttonPressed[button]
+}
@@ -0,0 +1,608 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
+)
+
+const (
+	nintendoVendorID         = 0x057e
+	nintendoProductSwitchPro = 0x2009
+)
+
+const switchProName = "Nintendo Switch Pro Controller"
+
+func isSwitchProController(vendor, product uint16) bool {
+	return vendor == nintendoVendorID && product == nintendoProductSwitchPro
+}
+
+// switchProDevice is a raw HID device of a Switch Pro Controller.
+type switchProDevice interface {
+	// read returns an input report including the report ID if available without blocking.
+	// read returns nil when no report is available yet.
+	read() ([]byte, error)
+
+	// write writes an output report. The first byte is the report ID.
+	write(report []byte) error
+
+	close()
+}
+
+// The commands of the output report 0x80, which are available only via USB.
+const (
+	switchProUSBCommandHandshake = 0x02
+	switchProUSBCommandHighSpeed = 0x03
+	switchProUSBCommandForceUSB  = 0x04
+)
+
+// The subcommands of the output report 0x01.
+const (
+	switchProSubcommandSetInputReportMode = 0x03
+	switchProSubcommandReadSPI            = 0x10
+	switchProSubcommandSetPlayerLights    = 0x30
+	switchProSubcommandEnableIMU          = 0x40
+	switchProSubcommandEnableVibration    = 0x48
+)
+
+// The addresses of the stick calibrations in the SPI flash.
+const (
+	switchProSPIFactoryStickCalibration = 0x603d
+	switchProSPIUserStickCalibration    = 0x8010
+)
+
+const (
+	// switchProReplyTimeout is the time to wait for a reply to a command or a subcommand.
+	switchProReplyTimeout = 100 * time.Millisecond
+
+	// switchProRumbleRefreshInterval is the interval to send the same rumble again.
+	// The controller stops the actuators by itself when no rumble is sent for a while.
+	switchProRumbleRefreshInterval = 160 * time.Millisecond
+)
+
+const (
+	// switchProButtonCapture is the index of the capture button, which is next to the web standard buttons.
+	switchProButtonCapture = int(gamepaddb.StandardButtonCenterCenter) + 1
+	switchProButtonCount   = switchProButtonCapture + 1
+)
+
+// switchProStickCalibration is the calibration of a stick in the raw 12-bit values.
+type switchProStickCalibration struct {
+	center [2]int
+	above  [2]int
+	below  [2]int
+}
+
+// defaultSwitchProStickCalibration is used when the SPI flash doesn't have a valid calibration.
+var defaultSwitchProStickCalibration = switchProStickCalibration{
+	center: [2]int{2048, 2048},
+	above:  [2]int{1600, 1600},
+	below:  [2]int{1600, 1600},
+}
+
+// switchProStickValue decodes the two 12-bit values packed in 3 bytes.
+func switchProStickValue(data []byte) (int, int) {
+	return int(data[0]) | int(data[1]&0x0f)<<8, int(data[1])>>4 | int(data[2])<<4
+}
+
+// parseSwitchProStickCalibration parses a stick calibration in the SPI flash.
+// The left stick and the right stick store the values in different orders.
+// parseSwitchProStickCalibration returns false if the calibration is not written.
+func parseSwitchProStickCalibration(data []byte, right bool) (switchProStickCalibration, bool) {
+	written := false
+	for _, b := range data[:9] {
+		if b != 0xff {
+			written = true
+			break
+		}
+	}
+	if !written {
+		return switchProStickCalibration{}, false
+	}
+
+	var v [3][2]int
+	for i := range v {
+		v[i][0], v[i][1] = switchProStickValue(data[3*i:])
+	}
+	var c switchProStickCalibration
+	if right {
+		c.center, c.below, c.above = v[0], v[1], v[2]
+	} else {
+		c.above, c.center, c.below = v[0], v[1], v[2]
+	}
+	if c.above[0] == 0 || c.above[1] == 0 || c.below[0] == 0 || c.below[1] == 0 {
+		return switchProStickCalibration{}, false
+	}
+	return c, true
+}
+
+// normalize converts a raw value of the axis (0 for X, 1 for Y) to a value in [-1, 1].
+func (c *switchProStickCalibration) normalize(axis int, raw int) float64 {
+	d := raw - c.center[axis]
+	var v float64
+	if d >= 0 {
+		v = float64(d) / float64(c.above[axis])
+	} else {
+		v = float64(d) / float64(c.below[axis])
+	}
+	return math.Max(-1, math.Min(1, v))
+}
+
+// encodeSwitchProRumble encodes the amplitudes of the low band (160 Hz) and the high band (320 Hz) in the HD rumble format for an actuator.
+// See https://github.com/dekuNukem/Nintendo_Switch_Reverse_Engineering/blob/master/rumble_data_table.md.
+func encodeSwitchProRumble(lowAmplitude, highAmplitude float64) [4]byte {
+	const (
+		// The encoded frequencies are round(log2(frequency / 10) * 32).
+		highFrequency = (0xa0 - 0x60) * 4 // 320 Hz
+		lowFrequency  = 0x80 - 0x40       // 160 Hz
+	)
+	highAmp := int(encodeSwitchProRumbleAmplitude(highAmplitude)) * 2
+	lowAmp := int(encodeSwitchProRumbleAmplitude(lowAmplitude))/2 + 0x40
+	return [4]byte{
+		byte(highFrequency & 0xff),
+		byte(highAmp + (highFrequency>>8)&0xff),
+		byte(lowFrequency + (lowAmp>>8)&0xff),
+		byte(lowAmp & 0xff),
+	}
+}
+
+// encodeSwitchProRumbleAmplitude encodes an amplitude in [0, 1] to a value in [0, 100].
+func encodeSwitchProRumbleAmplitude(amplitude float64) byte {
+	var v float64
+	switch {
+	case amplitude <= 0:
+		return 0
+	case amplitude > 0.23:
+		v = math.Log2(amplitude*8.7) * 32
+	case amplitude > 0.12:
+		v = math.Log2(amplitude*17) * 16
+	default:
+		v = (math.Log2(amplitude)*32 - 96) / (4 - 2*amplitude)
+	}
+	return byte(math.Max(0, math.Min(100, math.Round(v))))
+}
+
+// nativeGamepadSwitchPro is a Switch Pro Controller via its raw HID reports.
+// See https://github.com/dekuNukem/Nintendo_Switch_Reverse_Engineering for the protocol.
+type nativeGamepadSwitchPro struct {
+	device    switchProDevice
+	path      string
+	bluetooth bool
+
+	// packetCounter is the counter of the output reports 0x01 and 0x10, which is incremented in [0, 15].
+	packetCounter byte
+
+	leftStick  switchProStickCalibration
+	rightStick switchProStickCalibration
+
+	sticks  [4]float64
+	buttons [switchProButtonCount]bool
+
+	hasMotion bool
+	motion    [MotionSensorAxisCount]float64
+
+	batteryLevel_ int
+	powerState_   PowerState
+
+	// rumble is the rumble effect via output reports, which don't have durations.
+	rumble         rumble
+	rumbleData     [8]byte
+	rumbleActive   bool
+	rumbleSentTime time.Time
+
+	// now and sleep are used to wait for the replies, and are replaced in tests.
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// newNativeGamepadSwitchPro initializes the controller and reads its stick calibration.
+// newNativeGamepadSwitchPro doesn't close the device even when an error happens.
+func newNativeGamepadSwitchPro(device switchProDevice, path string, bluetooth bool) (*nativeGamepadSwitchPro, error) {
+	n := &nativeGamepadSwitchPro{
+		device:        device,
+		path:          path,
+		bluetooth:     bluetooth,
+		leftStick:     defaultSwitchProStickCalibration,
+		rightStick:    defaultSwitchProStickCalibration,
+		batteryLevel_: -1,
+		now:           time.Now,
+		sleep:         time.Sleep,
+	}
+	if err := n.init(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *nativeGamepadSwitchPro) init() error {
+	n.rumble.setMotorSpeeds = n.setMotorSpeeds
+	n.rumbleData = neutralSwitchProRumbleData()
+
+	// Via USB, the controller doesn't send the input reports until the handshake is done.
+	if !n.bluetooth {
+		for _, c := range []byte{
+			switchProUSBCommandHandshake,
+			switchProUSBCommandHighSpeed,
+			switchProUSBCommandHandshake,
+		} {
+			if err := n.sendUSBCommand(c); err != nil {
+				return err
+			}
+		}
+		// There is no reply to this command.
+		if err := n.device.write([]byte{0x80, switchProUSBCommandForceUSB}); err != nil {
+			return fmt.Errorf("gamepad: writing the USB command 0x%02x failed: %w", switchProUSBCommandForceUSB, err)
+		}
+	}
+
+	if _, err := n.sendSubcommand(switchProSubcommandSetInputReportMode, []byte{0x30}); err != nil {
+		return err
+	}
+
+	factory, err := n.readSPI(switchProSPIFactoryStickCalibration, 18)
+	if err != nil {
+		return err
+	}
+	if c, ok := parseSwitchProStickCalibration(factory[0:9], false); ok {
+		n.leftStick = c
+	}
+	if c, ok := parseSwitchProStickCalibration(factory[9:18], true); ok {
+		n.rightStick = c
+	}
+
+	// The user calibration overrides the factory calibration if the magic number exists.
+	if user, err := n.readSPI(switchProSPIUserStickCalibration, 22); err == nil {
+		if user[0] == 0xb2 && user[1] == 0xa1 {
+			if c, ok := parseSwitchProStickCalibration(user[2:11], false); ok {
+				n.leftStick = c
+			}
+		}
+		if user[11] == 0xb2 && user[12] == 0xa1 {
+			if c, ok := parseSwitchProStickCalibration(user[13:22], true); ok {
+				n.rightStick = c
+			}
+		}
+	}
+
+	// The motion sensors and the vibration are optional.
+	_, _ = n.sendSubcommand(switchProSubcommandEnableIMU, []byte{0x01})
+	_, _ = n.sendSubcommand(switchProSubcommandEnableVibration, []byte{0x01})
+
+	return nil
+}
+
+func neutralSwitchProRumbleData() [8]byte {
+	var data [8]byte
+	r := encodeSwitchProRumble(0, 0)
+	copy(data[0:4], r[:])
+	copy(data[4:8], r[:])
+	return data
+}
+
+func (n *nativeGamepadSwitchPro) nextPacketCounter() byte {
+	c := n.packetCounter
+	n.packetCounter = (n.packetCounter + 1) & 0x0f
+	return c
+}
+
+// waitForReply waits for an input report satisfying cond. The other input reports are parsed as usual.
+func (n *nativeGamepadSwitchPro) waitForReply(cond func(report []byte) bool) ([]byte, error) {
+	deadline := n.now().Add(switchProReplyTimeout)
+	for {
+		report, err := n.device.read()
+		if err != nil {
+			return nil, err
+		}
+		if report != nil {
+			if cond(report) {
+				return report, nil
+			}
+			n.parseReport(report)
+			continue
+		}
+		if !n.now().Before(deadline) {
+			return nil, fmt.Errorf("gamepad: timed out waiting for a reply from the Switch Pro Controller")
+		}
+		n.sleep(time.Millisecond)
+	}
+}
+
+func (n *nativeGamepadSwitchPro) sendUSBCommand(command byte) error {
+	if err := n.device.write([]byte{0x80, command}); err != nil {
+		return fmt.Errorf("gamepad: writing the USB command 0x%02x failed: %w", command, err)
+	}
+	if _, err := n.waitForReply(func(report []byte) bool {
+		return len(report) >= 2 && report[0] == 0x81 && report[1] == command
+	}); err != nil {
+		return fmt.Errorf("gamepad: the USB command 0x%02x failed: %w", command, err)
+	}
+	return nil
+}
+
+// sendSubcommand sends the subcommand and returns the reply data after the subcommand ID.
+func (n *nativeGamepadSwitchPro) sendSubcommand(subcommand byte, args []byte) ([]byte, error) {
+	report := make([]byte, 11+len(args))
+	report[0] = 0x01
+	report[1] = n.nextPacketCounter()
+	copy(report[2:10], n.rumbleData[:])
+	report[10] = subcommand
+	copy(report[11:], args)
+	if err := n.device.write(report); err != nil {
+		return nil, fmt.Errorf("gamepad: writing the subcommand 0x%02x failed: %w", subcommand, err)
+	}
+
+	reply, err := n.waitForReply(func(report []byte) bool {
+		return len(report) >= 15 && report[0] == 0x21 && report[14] == subcommand
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gamepad: the subcommand 0x%02x failed: %w", subcommand, err)
+	}
+	// The reply also has the current inputs.
+	n.parseReport(reply)
+	if reply[13]&0x80 == 0 {
+		return nil, fmt.Errorf("gamepad: the subcommand 0x%02x was not acknowledged", subcommand)
+	}
+	return reply[15:], nil
+}
+
+// readSPI reads the SPI flash of the controller.
+func (n *nativeGamepadSwitchPro) readSPI(address uint32, size int) ([]byte, error) {
+	args := make([]byte, 5)
+	binary.LittleEndian.PutUint32(args, address)
+	args[4] = byte(size)
+	data, err := n.sendSubcommand(switchProSubcommandReadSPI, args)
+	if err != nil {
+		return nil, err
+	}
+	// The data is preceded by the address and the size.
+	if len(data) < 5+size || binary.LittleEndian.Uint32(data) != address {
+		return nil, fmt.Errorf("gamepad: unexpected SPI data at 0x%04x from the Switch Pro Controller", address)
+	}
+	return data[5 : 5+size], nil
+}
+
+// sdlID returns an SDL ID in the same format as SDL's HIDAPI backend, whose driver signature is 'h'.
+func (n *nativeGamepadSwitchPro) sdlID(version uint16) string {
+	bus := uint16(sdlHardwareBusUSB)
+	if n.bluetooth {
+		bus = sdlHardwareBusBluetooth
+	}
+	return sdlGUID(bus, nintendoVendorID, nintendoProductSwitchPro, version, switchProName, sdlDriverSignatureHIDAPI, 0)
+}
+
+func (n *nativeGamepadSwitchPro) devicePath() string {
+	return n.path
+}
+
+func (n *nativeGamepadSwitchPro) close() {
+	n.device.close()
+}
+
+func (n *nativeGamepadSwitchPro) update(gamepads *gamepads) error {
+	// Read all the queued reports so that the latest state is used.
+	for {
+		report, err := n.device.read()
+		if err != nil {
+			// The read fails when the device is removed.
+			n.close()
+			return errDisconnected
+		}
+		if report == nil {
+			break
+		}
+		n.parseReport(report)
+	}
+
+	// Stop the actuators when the duration elapses, even if vibrate is no longer called.
+	now := n.now()
+	_ = n.rumble.update(now)
+	if n.rumbleActive && now.Sub(n.rumbleSentTime) >= switchProRumbleRefreshInterval {
+		_ = n.writeRumble()
+	}
+	return nil
+}
+
+func (n *nativeGamepadSwitchPro) parseReport(report []byte) {
+	// The standard full report 0x30 and the subcommand reply 0x21 have the same inputs.
+	if len(report) < 13 || (report[0] != 0x30 && report[0] != 0x21) {
+		return
+	}
+
+	level := int(report[2] >> 5)
+	n.batteryLevel_ = level * 100 / 4
+	if report[2]&0x10 != 0 {
+		n.powerState_ = PowerStateCharging
+	} else {
+		n.powerState_ = PowerStateOnBattery
+	}
+
+	right, shared, left := report[3], report[4], report[5]
+	n.buttons[gamepaddb.StandardButtonRightLeft] = right&0x01 != 0   // Y
+	n.buttons[gamepaddb.StandardButtonRightTop] = right&0x02 != 0    // X
+	n.buttons[gamepaddb.StandardButtonRightBottom] = right&0x04 != 0 // B
+	n.buttons[gamepaddb.StandardButtonRightRight] = right&0x08 != 0  // A
+	n.buttons[gamepaddb.StandardButtonFrontTopRight] = right&0x40 != 0
+	n.buttons[gamepaddb.StandardButtonFrontBottomRight] = right&0x80 != 0
+
+	n.buttons[gamepaddb.StandardButtonCenterLeft] = shared&0x01 != 0  // Minus
+	n.buttons[gamepaddb.StandardButtonCenterRight] = shared&0x02 != 0 // Plus
+	n.buttons[gamepaddb.StandardButtonRightStick] = shared&0x04 != 0
+	n.buttons[gamepaddb.StandardButtonLeftStick] = shared&0x08 != 0
+	n.buttons[gamepaddb.StandardButtonCenterCenter] = shared&0x10 != 0 // Home
+	n.buttons[switchProButtonCapture] = shared&0x20 != 0
+
+	n.buttons[gamepaddb.StandardButtonLeftBottom] = left&0x01 != 0
+	n.buttons[gamepaddb.StandardButtonLeftTop] = left&0x02 != 0
+	n.buttons[gamepaddb.StandardButtonLeftRight] = left&0x04 != 0
+	n.buttons[gamepaddb.StandardButtonLeftLeft] = left&0x08 != 0
+	n.buttons[gamepaddb.StandardButtonFrontTopLeft] = left&0x40 != 0
+	n.buttons[gamepaddb.StandardButtonFrontBottomLeft] = left&0x80 != 0
+
+	// The raw Y values increase upward, while the standard layout's Y values increase downward.
+	lx, ly := switchProStickValue(report[6:9])
+	rx, ry := switchProStickValue(report[9:12])
+	n.sticks[gamepaddb.StandardAxisLeftStickHorizontal] = n.leftStick.normalize(0, lx)
+	n.sticks[gamepaddb.StandardAxisLeftStickVertical] = -n.leftStick.normalize(1, ly)
+	n.sticks[gamepaddb.StandardAxisRightStickHorizontal] = n.rightStick.normalize(0, rx)
+	n.sticks[gamepaddb.StandardAxisRightStickVertical] = -n.rightStick.normalize(1, ry)
+
+	// The report 0x30 has three samples of the motion sensors in 5 ms intervals. Use the latest one.
+	if report[0] == 0x30 && len(report) >= 49 {
+		n.parseMotion(report[37:49])
+	}
+}
+
+// parseMotion parses the accelerometer and the gyroscope values.
+// The factory calibration is not applied, and the nominal resolutions are used instead.
+func (n *nativeGamepadSwitchPro) parseMotion(sample []byte) {
+	const (
+		accelGPerRes        = 0.000244
+		gyroDegPerSecPerRes = 0.070
+		standardGravity     = 9.80665
+	)
+	for i := 0; i < 3; i++ {
+		a := float64(int16(binary.LittleEndian.Uint16(sample[2*i:])))
+		g := float64(int16(binary.LittleEndian.Uint16(sample[6+2*i:])))
+		n.motion[MotionSensorAxisAccelerometerX+MotionSensorAxis(i)] = a * accelGPerRes * standardGravity
+		n.motion[MotionSensorAxisGyroscopeX+MotionSensorAxis(i)] = g * gyroDegPerSecPerRes * math.Pi / 180
+	}
+	n.hasMotion = true
+}
+
+// writeRumble sends the current rumble data.
+func (n *nativeGamepadSwitchPro) writeRumble() error {
+	report := make([]byte, 10)
+	report[0] = 0x10
+	report[1] = n.nextPacketCounter()
+	copy(report[2:], n.rumbleData[:])
+	n.rumbleSentTime = n.now()
+	return n.device.write(report)
+}
+
+// setMotorSpeeds sets the amplitudes of both the actuators.
+// The low-frequency motor's speed is used for the low band, and the high-frequency motor's speed is used for the high band.
+func (n *nativeGamepadSwitchPro) setMotorSpeeds(low, high uint16) error {
+	r := encodeSwitchProRumble(float64(low)/0xffff, float64(high)/0xffff)
+	copy(n.rumbleData[0:4], r[:])
+	copy(n.rumbleData[4:8], r[:])
+	n.rumbleActive = low != 0 || high != 0
+	return n.writeRumble()
+}
+
+func (n *nativeGamepadSwitchPro) hasOwnStandardLayoutMapping() bool {
+	return true
+}
+
+func (n *nativeGamepadSwitchPro) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
+	if axis < 0 || axis > gamepaddb.StandardAxisMax {
+		return nil
+	}
+	return axisMappingInput{g: n, axis: int(axis)}
+}
+
+func (n *nativeGamepadSwitchPro) standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput {
+	// The capture button is misc1 in SDL's game controller layout.
+	if button == gamepaddb.StandardButtonMisc1 {
+		return buttonMappingInput{g: n, button: switchProButtonCapture}
+	}
+	if button < 0 || button > gamepaddb.StandardButtonCenterCenter {
+		return nil
+	}
+	return buttonMappingInput{g: n, button: int(button)}
+}
+
+func (n *nativeGamepadSwitchPro) axisCount() int {
+	return len(n.sticks)
+}
+
+func (n *nativeGamepadSwitchPro) buttonCount() int {
+	return len(n.buttons)
+}
+
+func (n *nativeGamepadSwitchPro) hatCount() int {
+	return 0
+}
+
+func (n *nativeGamepadSwitchPro) axisValue(axis int) float64 {
+	if axis < 0 || axis >= len(n.sticks) {
+		return 0
+	}
+	return n.sticks[axis]
+}
+
+func (n *nativeGamepadSwitchPro) buttonValue(button int) float64 {
+	if n.isButtonPressed(button) {
+		return 1
+	}
+	return 0
+}
+
+func (n *nativeGamepadSwitchPro) isButtonPressed(button int) bool {
+	if button < 0 || button >= len(n.buttons) {
+		return false
+	}
+	return n.buttons[button]
+}
+
+func (n *nativeGamepadSwitchPro) hatState(hat int) int {
+	return hatCentered
+}
+
+func (n *nativeGamepadSwitchPro) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+	// An error is ignored as vibrate has no way to report it. A disconnection is detected at update.
+	_ = n.rumble.start(n.now(), duration, strongMagnitude, weakMagnitude)
+}
+
+// switchProPlayerLights is the patterns of the four player LEDs for the player indices, in the same way as Nintendo Switch.
+var switchProPlayerLights = []byte{0x01, 0x03, 0x07, 0x0f}
+
+func (n *nativeGamepadSwitchPro) setPlayerIndex(index int) {
+	var lights byte
+	if index >= 0 {
+		lights = switchProPlayerLights[index%len(switchProPlayerLights)]
+	}
+	_, _ = n.sendSubcommand(switchProSubcommandSetPlayerLights, []byte{lights})
+}
+
+func (n *nativeGamepadSwitchPro) batteryLevel() (int, bool) {
+	if n.batteryLevel_ < 0 {
+		return 0, false
+	}
+	return n.batteryLevel_, true
+}
+
+func (n *nativeGamepadSwitchPro) powerState() PowerState {
+	return n.powerState_
+}
+
+func (n *nativeGamepadSwitchPro) busType() BusType {
+	if n.bluetooth {
+		return BusTypeBluetooth
+	}
+	return BusTypeUSB
+}
+
+func (n *nativeGamepadSwitchPro) hasMotionSensor() bool {
+	return n.hasMotion
+}
+
+func (n *nativeGamepadSwitchPro) motionSensorValue(axis MotionSensorAxis) float64 {
+	return n.motion[axis]
+}
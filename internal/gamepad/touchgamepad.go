@@ -0,0 +1,271 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepad
+
+import (
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
+)
+
+// VirtualGamepadZone represents the zone of an element of a touch-driven virtual gamepad.
+// The zone is a rectangle in the normalized coordinates of the screen,
+// where (0, 0) is the upper-left corner and (1, 1) is the lower-right corner.
+type VirtualGamepadZone struct {
+	MinX float64
+	MinY float64
+	MaxX float64
+	MaxY float64
+}
+
+func (z VirtualGamepadZone) contains(x, y float64) bool {
+	return z.MinX <= x && x < z.MaxX && z.MinY <= y && y < z.MaxY
+}
+
+// VirtualGamepadTouch represents a touch for a touch-driven virtual gamepad.
+// X and Y are in pixels on the screen.
+type VirtualGamepadTouch struct {
+	ID int
+	X  float64
+	Y  float64
+}
+
+// touchGamepadElements is the elements a touch-driven virtual gamepad supports.
+const touchGamepadElements = VirtualGamepadElementLeftThumbstick | VirtualGamepadElementButtonA | VirtualGamepadElementButtonB | VirtualGamepadElementButtonX | VirtualGamepadElementButtonY
+
+// touchGamepadStickRadius is the radius of the thumbstick relative to the shorter side of the screen.
+// A touch moved by this distance from where it started tilts the thumbstick fully.
+const touchGamepadStickRadius = 0.1
+
+// defaultVirtualGamepadZones is the default zones of a touch-driven virtual gamepad.
+// The thumbstick is at the lower-left quarter of the screen, and the buttons are at the lower-right quarter in the 2x2 grid.
+var defaultVirtualGamepadZones = map[VirtualGamepadElement]VirtualGamepadZone{
+	VirtualGamepadElementLeftThumbstick: {MinX: 0, MinY: 0.5, MaxX: 0.5, MaxY: 1},
+	VirtualGamepadElementButtonX:        {MinX: 0.5, MinY: 0.5, MaxX: 0.75, MaxY: 0.75},
+	VirtualGamepadElementButtonY:        {MinX: 0.75, MinY: 0.5, MaxX: 1, MaxY: 0.75},
+	VirtualGamepadElementButtonA:        {MinX: 0.5, MinY: 0.75, MaxX: 0.75, MaxY: 1},
+	VirtualGamepadElementButtonB:        {MinX: 0.75, MinY: 0.75, MaxX: 1, MaxY: 1},
+}
+
+// touchGamepadButtons is the pairs of the elements and the standard buttons of a touch-driven virtual gamepad.
+var touchGamepadButtons = []struct {
+	element VirtualGamepadElement
+	button  gamepaddb.StandardButton
+}{
+	{VirtualGamepadElementButtonA, gamepaddb.StandardButtonRightBottom},
+	{VirtualGamepadElementButtonB, gamepaddb.StandardButtonRightRight},
+	{VirtualGamepadElementButtonX, gamepaddb.StandardButtonRightLeft},
+	{VirtualGamepadElementButtonY, gamepaddb.StandardButtonRightTop},
+}
+
+// virtualGamepadTouchState is the state of the touches for a touch-driven virtual gamepad.
+type virtualGamepadTouchState struct {
+	touches []VirtualGamepadTouch
+	width   float64
+	height  float64
+
+	// zones is the zones overriding the default zones.
+	zones map[VirtualGamepadElement]VirtualGamepadZone
+}
+
+func (s *virtualGamepadTouchState) zone(element VirtualGamepadElement) VirtualGamepadZone {
+	if z, ok := s.zones[element]; ok {
+		return z
+	}
+	return defaultVirtualGamepadZones[element]
+}
+
+// nativeGamepadTouch is a virtual gamepad with the standard layout driven by touches.
+// The inputs are updated from the touches in the zones of the elements. Nothing is rendered.
+type nativeGamepadTouch struct {
+	elements VirtualGamepadElement
+
+	// stickTouchID is the ID of the touch operating the thumbstick. stickTouching is false if there is no such touch.
+	// The thumbstick's origin is where the touch started.
+	stickTouchID  int
+	stickTouching bool
+	stickOriginX  float64
+	stickOriginY  float64
+
+	// prevTouchIDs is the IDs of the touches in the previous update.
+	// Only a touch that starts in the thumbstick's zone can operate the thumbstick.
+	prevTouchIDs []int
+
+	axes    [gamepaddb.StandardAxisMax + 1]float64
+	buttons [gamepaddb.StandardButtonMax + 1]bool
+}
+
+func (n *nativeGamepadTouch) update(gamepads *gamepads) error {
+	n.updateTouches(&gamepads.virtualGamepadTouchState)
+	return nil
+}
+
+func (n *nativeGamepadTouch) updateTouches(state *virtualGamepadTouchState) {
+	n.axes = [gamepaddb.StandardAxisMax + 1]float64{}
+	n.buttons = [gamepaddb.StandardButtonMax + 1]bool{}
+	if state.width <= 0 || state.height <= 0 {
+		n.stickTouching = false
+		n.prevTouchIDs = n.prevTouchIDs[:0]
+		return
+	}
+
+	if n.stickTouching {
+		n.stickTouching = false
+		for _, t := range state.touches {
+			if t.ID == n.stickTouchID {
+				n.stickTouching = true
+				break
+			}
+		}
+	}
+
+	if !n.stickTouching && n.elements&VirtualGamepadElementLeftThumbstick != 0 {
+		z := state.zone(VirtualGamepadElementLeftThumbstick)
+		for _, t := range state.touches {
+			if n.isPrevTouch(t.ID) {
+				continue
+			}
+			if !z.contains(t.X/state.width, t.Y/state.height) {
+				continue
+			}
+			n.stickTouchID = t.ID
+			n.stickTouching = true
+			n.stickOriginX = t.X
+			n.stickOriginY = t.Y
+			break
+		}
+	}
+
+	r := math.Min(state.width, state.height) * touchGamepadStickRadius
+	for _, t := range state.touches {
+		if n.stickTouching && t.ID == n.stickTouchID {
+			x := (t.X - n.stickOriginX) / r
+			y := (t.Y - n.stickOriginY) / r
+			if l := math.Hypot(x, y); l > 1 {
+				x /= l
+				y /= l
+			}
+			n.axes[gamepaddb.StandardAxisLeftStickHorizontal] = x
+			n.axes[gamepaddb.StandardAxisLeftStickVertical] = y
+			continue
+		}
+		for _, b := range touchGamepadButtons {
+			if n.elements&b.element == 0 {
+				continue
+			}
+			if state.zone(b.element).contains(t.X/state.width, t.Y/state.height) {
+				n.buttons[b.button] = true
+			}
+		}
+	}
+
+	n.prevTouchIDs = n.prevTouchIDs[:0]
+	for _, t := range state.touches {
+		n.prevTouchIDs = append(n.prevTouchIDs, t.ID)
+	}
+}
+
+func (n *nativeGamepadTouch) isPrevTouch(id int) bool {
+	for _, prevID := range n.prevTouchIDs {
+		if prevID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *nativeGamepadTouch) hasOwnStandardLayoutMapping() bool {
+	return true
+}
+
+func (n *nativeGamepadTouch) standardAxisInOwnMapping(axis gamepaddb.StandardAxis) mappingInput {
+	switch axis {
+	case gamepaddb.StandardAxisLeftStickHorizontal, gamepaddb.StandardAxisLeftStickVertical:
+		if n.elements&VirtualGamepadElementLeftThumbstick == 0 {
+			return nil
+		}
+		return axisMappingInput{g: n, axis: int(axis)}
+	}
+	return nil
+}
+
+func (n *nativeGamepadTouch) standardButtonInOwnMapping(button gamepaddb.StandardButton) mappingInput {
+	for _, b := range touchGamepadButtons {
+		if b.button != button {
+			continue
+		}
+		if n.elements&b.element == 0 {
+			return nil
+		}
+		return buttonMappingInput{g: n, button: int(button)}
+	}
+	return nil
+}
+
+func (n *nativeGamepadTouch) axisCount() int {
+	return len(n.axes)
+}
+
+func (n *nativeGamepadTouch) buttonCount() int {
+	return len(n.buttons)
+}
+
+func (n *nativeGamepadTouch) hatCount() int {
+	return 0
+}
+
+func (n *nativeGamepadTouch) axisValue(axis int) float64 {
+	if axis < 0 || axis >= len(n.axes) {
+		return 0
+	}
+	return n.axes[axis]
+}
+
+func (n *nativeGamepadTouch) buttonValue(button int) float64 {
+	if n.isButtonPressed(button) {
+		return 1
+	}
+	return 0
+}
+
+func (n *nativeGamepadTouch) isButtonPressed(button int) bool {
+	if button < 0 || button >= len(n.buttons) {
+		return false
+	}
+	return n.buttons[button]
+}
+
+func (n *nativeGamepadTouch) hatState(hat int) int {
+	return hatCentered
+}
+
+func (n *nativeGamepadTouch) vibrate(duration time.Duration, strongMagnitude float64, weakMagnitude float64) {
+}
+
+func (n *nativeGamepadTouch) setPlayerIndex(index int) {
+}
+
+func (n *nativeGamepadTouch) batteryLevel() (int, bool) {
+	return 0, false
+}
+
+func (n *nativeGamepadTouch) powerState() PowerState {
+	return PowerStateUnknown
+}
+
+func (n *nativeGamepadTouch) busType() BusType {
+	return BusTypeVirtual
+}
@@ -36,7 +36,16 @@ const (
 	StandardButtonLeftRight
 	StandardButtonCenterCenter
 
-	StandardButtonMax = StandardButtonCenterCenter
+	// The buttons below are not in the web standard, but in SDL's game controller layout.
+	// They are appended so that the values of the buttons above never change.
+	StandardButtonMisc1
+	StandardButtonPaddle1
+	StandardButtonPaddle2
+	StandardButtonPaddle3
+	StandardButtonPaddle4
+	StandardButtonTouchpad
+
+	StandardButtonMax = StandardButtonTouchpad
 )
 
 type StandardAxis int
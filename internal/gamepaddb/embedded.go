@@ -0,0 +1,111 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepaddb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// lineRange is the range of a line in the embedded database.
+type lineRange struct {
+	start int32
+	end   int32
+}
+
+// embeddedLines is the lines of the embedded database that are not parsed yet, keyed by the GUID keys. See guidKey.
+//
+// Parsing the whole database at the initialization takes time and memory, though only a few GUIDs are used in a session.
+// The lines are parsed by loadEmbeddedEntries when a gamepad with the GUID key is looked up.
+var embeddedLines map[string][]lineRange
+
+// indexEmbeddedLines indexes the lines of the database for the platform by the GUID keys without parsing the mappings.
+// The lines for other platforms are skipped.
+func indexEmbeddedLines(data []byte, platform platform) map[string][]lineRange {
+	platformField := []byte("platform:" + platform.name())
+
+	index := map[string][]lineRange{}
+	for start := 0; start < len(data); {
+		end := bytes.IndexByte(data[start:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += start
+		}
+		line := data[start:end]
+		lineStart := start
+		start = end + 1
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		comma := bytes.IndexByte(line, ',')
+		if comma < 0 {
+			continue
+		}
+		// Skip the lines for other platforms cheaply. An entry without the platform field is for any platforms.
+		if bytes.Contains(line, []byte("platform:")) && (platform == platformUnknown || !hasField(line, platformField)) {
+			continue
+		}
+
+		key, _ := guidKey(strings.ToLower(string(line[:comma])))
+		index[key] = append(index[key], lineRange{
+			start: int32(lineStart),
+			end:   int32(end),
+		})
+	}
+	return index
+}
+
+// hasField reports whether the line has the field, which is followed by a comma or the end of the line.
+func hasField(line []byte, field []byte) bool {
+	for {
+		i := bytes.Index(line, field)
+		if i < 0 {
+			return false
+		}
+		line = line[i+len(field):]
+		if len(line) == 0 || line[0] == ',' {
+			return true
+		}
+	}
+}
+
+// loadEmbeddedEntries parses the lines of the embedded database for the GUID key, and adds the entries.
+// loadEmbeddedEntries must be called with mappingsM locked.
+func loadEmbeddedEntries(key string) {
+	lines, ok := embeddedLines[key]
+	if !ok {
+		return
+	}
+	delete(embeddedLines, key)
+
+	for _, r := range lines {
+		line := string(gamecontrollerdb_txt[r.start:r.end])
+		e, err := parseLine(line, currentPlatform)
+		if err != nil {
+			// The embedded database is tested, so this should not happen.
+			addMappingError("gamecontrollerdb.txt", fmt.Errorf("%s: %w", line, err))
+			continue
+		}
+		if e == nil {
+			continue
+		}
+		e.source = sourceEmbedded
+		addEntry(e)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepaddb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/debug"
+)
+
+// maxMappingErrors is the maximum number of the recorded mapping errors. Older errors are discarded.
+const maxMappingErrors = 16
+
+// MappingError represents an error of mappings that are not loaded by a function call, e.g., mappings from an environment variable.
+// The invalid mappings are ignored, and the other mappings keep working.
+type MappingError struct {
+	// Source is where the mappings come from, e.g., the name of an environment variable or the path of a file.
+	Source string
+
+	// Err is the error of the mappings.
+	Err error
+
+	// Time is the time when the error happened.
+	Time time.Time
+}
+
+var (
+	mappingErrors  []MappingError
+	mappingErrorsM sync.Mutex
+)
+
+func addMappingError(source string, err error) {
+	mappingErrorsM.Lock()
+	defer mappingErrorsM.Unlock()
+
+	debug.Logf("gamepaddb: %s: %v\n", source, err)
+
+	if len(mappingErrors) >= maxMappingErrors {
+		n := copy(mappingErrors, mappingErrors[len(mappingErrors)-maxMappingErrors+1:])
+		mappingErrors = mappingErrors[:n]
+	}
+	mappingErrors = append(mappingErrors, MappingError{
+		Source: source,
+		Err:    err,
+		Time:   time.Now(),
+	})
+}
+
+// AppendMappingErrors appends the recorded mapping errors to errs and returns the extended buffer.
+func AppendMappingErrors(errs []MappingError) []MappingError {
+	mappingErrorsM.Lock()
+	defer mappingErrorsM.Unlock()
+
+	return append(errs, mappingErrors...)
+}
+
+// loadEnvironmentMappings loads the mappings specified by the environment variables in the same way as SDL.
+// The mappings have the highest priority, as the environment variables are set by the user or tools like Steam to fix gamepads.
+//
+// Like SDL, the valid lines are applied even if there are invalid lines.
+// An error is recorded as a MappingError for each invalid line, and doesn't stop the game.
+func loadEnvironmentMappings() {
+	const (
+		envConfig     = "SDL_GAMECONTROLLERCONFIG"
+		envConfigFile = "SDL_GAMECONTROLLERCONFIG_FILE"
+	)
+
+	if path := os.Getenv(envConfigFile); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			addMappingError(envConfigFile, err)
+		} else {
+			_, errs, err := updateLeniently(bytes.NewReader(data), sourceEnvironment)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			for _, err := range errs {
+				addMappingError(envConfigFile, fmt.Errorf("%s: %w", path, err))
+			}
+		}
+	}
+
+	// The variable is applied after the file, so that the variable overrides the file for the same GUID like SDL.
+	if config := os.Getenv(envConfig); config != "" {
+		_, errs, err := updateLeniently(strings.NewReader(config), sourceEnvironment)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, err := range errs {
+			addMappingError(envConfig, err)
+		}
+	}
+}
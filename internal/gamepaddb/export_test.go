@@ -0,0 +1,118 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepaddb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"time"
+)
+
+var LoadEnvironmentMappings = loadEnvironmentMappings
+
+const MaxMappingErrors = maxMappingErrors
+
+func ResetWatchTime() {
+	watchedFilesM.Lock()
+	defer watchedFilesM.Unlock()
+	lastWatchTime = time.Time{}
+}
+
+var allPlatforms = []platform{
+	platformUnknown,
+	platformWindows,
+	platformMacOS,
+	platformUnix,
+	platformAndroid,
+	platformIOS,
+}
+
+// CheckEmbeddedDatabase parses all the lines of the embedded database for all the platforms,
+// and checks that the index has exactly the lines used for each platform.
+func CheckEmbeddedDatabase() error {
+	for _, p := range allPlatforms {
+		index := indexEmbeddedLines(gamecontrollerdb_txt, p)
+		indexed := map[lineRange]struct{}{}
+		for _, rs := range index {
+			for _, r := range rs {
+				indexed[r] = struct{}{}
+			}
+		}
+
+		var parsed int
+		var start int
+		s := bufio.NewScanner(bytes.NewReader(gamecontrollerdb_txt))
+		for s.Scan() {
+			line := s.Text()
+			r := lineRange{
+				start: int32(start),
+				end:   int32(start + len(line)),
+			}
+			start += len(line) + 1
+
+			e, err := parseLine(line, p)
+			if err != nil {
+				return fmt.Errorf("gamepaddb: %s: %w", line, err)
+			}
+			if e == nil {
+				continue
+			}
+			parsed++
+			if _, ok := indexed[r]; !ok {
+				return fmt.Errorf("gamepaddb: the line is not indexed for the platform %d: %s", p, line)
+			}
+			found := false
+			for _, r2 := range index[e.guid] {
+				if r2 == r {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("gamepaddb: the line is indexed by a wrong GUID for the platform %d: %s", p, line)
+			}
+		}
+		if err := s.Err(); err != nil {
+			return err
+		}
+		if parsed != len(indexed) {
+			return fmt.Errorf("gamepaddb: %d lines are indexed but %d lines are parsed for the platform %d", len(indexed), parsed, p)
+		}
+	}
+	return nil
+}
+
+func IndexEmbeddedDatabase() {
+	_ = indexEmbeddedLines(gamecontrollerdb_txt, currentPlatform)
+}
+
+func ParseEmbeddedDatabase() error {
+	s := bufio.NewScanner(bytes.NewReader(gamecontrollerdb_txt))
+	for s.Scan() {
+		if _, err := parseLine(s.Text(), currentPlatform); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+func ValidateEmbeddedDatabase() error {
+	return Validate(bytes.NewReader(gamecontrollerdb_txt))
+}
+
+func (h AxisHint) Apply(v float64) float64 {
+	return h.apply(v)
+}
@@ -0,0 +1,245 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepaddb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InputType represents the type of a gamepad's input.
+type InputType int
+
+const (
+	InputTypeButton InputType = iota
+	InputTypeAxis
+	InputTypeHat
+)
+
+// Input is a gamepad's input that a standard button or a standard axis is mapped from.
+type Input struct {
+	Type InputType
+
+	// Index is the index of the button, the axis, or the hat.
+	Index int
+
+	// HatState is the direction of the hat like HatUp, or a combination of them for a diagonal direction.
+	// HatState is used only for InputTypeHat.
+	HatState int
+
+	// Inverted reports whether the axis is inverted. Inverted is used only for InputTypeAxis.
+	Inverted bool
+}
+
+// Mapping is a standard layout mapping of a gamepad.
+type Mapping struct {
+	Buttons map[StandardButton]Input
+	Axes    map[StandardAxis]Input
+}
+
+func (i Input) mapping() (*mapping, error) {
+	switch i.Type {
+	case InputTypeButton:
+		return &mapping{
+			Type:  mappingTypeButton,
+			Index: i.Index,
+		}, nil
+	case InputTypeAxis:
+		m := &mapping{
+			Type:      mappingTypeAxis,
+			Index:     i.Index,
+			AxisScale: 1,
+		}
+		if i.Inverted {
+			m.AxisScale = -1
+		}
+		return m, nil
+	case InputTypeHat:
+		if i.HatState <= 0 || i.HatState > HatUp|HatRight|HatDown|HatLeft {
+			return nil, fmt.Errorf("gamepaddb: unexpected hat state: %d", i.HatState)
+		}
+		return &mapping{
+			Type:     mappingTypeHat,
+			Index:    i.Index,
+			HatState: i.HatState,
+		}, nil
+	}
+	return nil, fmt.Errorf("gamepaddb: unexpected input type: %d", i.Type)
+}
+
+// String returns the mapping element in the format of SDL_GameControllerDB like "a1~".
+func (m *mapping) String() string {
+	switch m.Type {
+	case mappingTypeButton:
+		return fmt.Sprintf("b%d", m.Index)
+	case mappingTypeHat:
+		return fmt.Sprintf("h%d.%d", m.Index, m.HatState)
+	case mappingTypeAxis:
+		// This is the inverse of parseMappingElement.
+		switch {
+		case m.AxisScale == 1 && m.AxisOffset == 0:
+			return fmt.Sprintf("a%d", m.Index)
+		case m.AxisScale == -1 && m.AxisOffset == 0:
+			return fmt.Sprintf("a%d~", m.Index)
+		case m.AxisScale == 2 && m.AxisOffset == -1:
+			return fmt.Sprintf("+a%d", m.Index)
+		case m.AxisScale == -2 && m.AxisOffset == 1:
+			return fmt.Sprintf("+a%d~", m.Index)
+		case m.AxisScale == -2 && m.AxisOffset == -1:
+			return fmt.Sprintf("-a%d", m.Index)
+		case m.AxisScale == 2 && m.AxisOffset == 1:
+			return fmt.Sprintf("-a%d~", m.Index)
+		}
+	}
+	panic(fmt.Sprintf("gamepaddb: unexpected mapping: %v", *m))
+}
+
+// format returns the entry as a line of SDL_GameControllerDB for the GUID.
+// The elements are sorted by the names as the database does.
+func (e *entry) format(guid string) string {
+	var elems []string
+	for b, m := range e.buttons {
+		elems = append(elems, standardButtonName(b)+":"+m.String())
+	}
+	for a, m := range e.axes {
+		elems = append(elems, standardAxisName(a)+":"+m.String())
+	}
+	for a, m := range e.positiveAxes {
+		elems = append(elems, "+"+standardAxisName(a)+":"+m.String())
+	}
+	for a, m := range e.negativeAxes {
+		elems = append(elems, "-"+standardAxisName(a)+":"+m.String())
+	}
+	sort.Strings(elems)
+
+	var sb strings.Builder
+	sb.WriteString(guid)
+	sb.WriteString(",")
+	// A comma cannot be escaped in the format.
+	sb.WriteString(strings.ReplaceAll(e.name, ",", ""))
+	sb.WriteString(",")
+	for _, elem := range elems {
+		sb.WriteString(elem)
+		sb.WriteString(",")
+	}
+	if name := currentPlatform.name(); name != "" {
+		sb.WriteString("platform:")
+		sb.WriteString(name)
+		sb.WriteString(",")
+	}
+	return sb.String()
+}
+
+// name returns the name of the platform used in the platform field.
+func (p platform) name() string {
+	switch p {
+	case platformWindows:
+		return "Windows"
+	case platformMacOS:
+		return "Mac OS X"
+	case platformUnix:
+		return "Linux"
+	case platformAndroid:
+		return "Android"
+	case platformIOS:
+		return "iOS"
+	}
+	return ""
+}
+
+func standardButtonName(button StandardButton) string {
+	for _, name := range standardButtonNames {
+		if b, ok := toStandardGamepadButton(name); ok && b == button {
+			return name
+		}
+	}
+	panic(fmt.Sprintf("gamepaddb: unexpected standard button: %d", button))
+}
+
+func standardAxisName(axis StandardAxis) string {
+	for _, name := range standardAxisNames {
+		if a, ok := toStandardGamepadAxis(name); ok && a == axis {
+			return name
+		}
+	}
+	panic(fmt.Sprintf("gamepaddb: unexpected standard axis: %d", axis))
+}
+
+var (
+	standardButtonNames = []string{"a", "b", "x", "y", "back", "start", "guide", "leftshoulder", "rightshoulder", "leftstick", "rightstick", "dpup", "dpright", "dpdown", "dpleft", "lefttrigger", "righttrigger", "misc1", "paddle1", "paddle2", "paddle3", "paddle4", "touchpad"}
+	standardAxisNames   = []string{"leftx", "lefty", "rightx", "righty"}
+)
+
+// MappingString returns the mapping for the gamepad's GUID in the format of SDL_GameControllerDB,
+// or an empty string if there is no mapping.
+//
+// The result is a line for the GUID and the current platform.
+// Updating the database with the result doesn't change how the gamepad works.
+func MappingString(id string) string {
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	e := lookupEntry(id)
+	if e == nil || !e.hasMappings() {
+		return ""
+	}
+	return e.format(id)
+}
+
+// MappingTemplate returns a line of SDL_GameControllerDB without mappings for the GUID, the name, and the current platform.
+func MappingTemplate(id string, name string) string {
+	e := &entry{
+		name: name,
+	}
+	return e.format(id)
+}
+
+// FormatMapping returns the mapping in the format of SDL_GameControllerDB for the GUID and the current platform.
+func FormatMapping(id string, name string, m *Mapping) (string, error) {
+	e, err := m.entry(name)
+	if err != nil {
+		return "", err
+	}
+	return e.format(id), nil
+}
+
+// entry returns an entry with the mapping.
+func (m *Mapping) entry(name string) (*entry, error) {
+	e := &entry{
+		name: name,
+	}
+	for b, i := range m.Buttons {
+		m, err := i.mapping()
+		if err != nil {
+			return nil, err
+		}
+		if e.buttons == nil {
+			e.buttons = map[StandardButton]*mapping{}
+		}
+		e.buttons[b] = m
+	}
+	for a, i := range m.Axes {
+		m, err := i.mapping()
+		if err != nil {
+			return nil, err
+		}
+		if e.axes == nil {
+			e.axes = map[StandardAxis]*mapping{}
+		}
+		e.axes[a] = m
+	}
+	return e, nil
+}
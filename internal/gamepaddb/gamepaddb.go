@@ -22,10 +22,10 @@ package gamepaddb
 
 import (
 	"bufio"
-	"bytes"
 	_ "embed"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"runtime"
 	"strconv"
 	"strings"
@@ -83,23 +83,9 @@ func init() {
 	}
 }
 
-var additionalGLFWGamepads = []byte(`
-78696e70757401000000000000000000,XInput Gamepad (GLFW),platform:Windows,a:b0,b:b1,x:b2,y:b3,leftshoulder:b4,rightshoulder:b5,back:b6,start:b7,leftstick:b8,rightstick:b9,leftx:a0,lefty:a1,rightx:a2,righty:a3,lefttrigger:a4,righttrigger:a5,dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,
-78696e70757402000000000000000000,XInput Wheel (GLFW),platform:Windows,a:b0,b:b1,x:b2,y:b3,leftshoulder:b4,rightshoulder:b5,back:b6,start:b7,leftstick:b8,rightstick:b9,leftx:a0,lefty:a1,rightx:a2,righty:a3,lefttrigger:a4,righttrigger:a5,dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,
-78696e70757403000000000000000000,XInput Arcade Stick (GLFW),platform:Windows,a:b0,b:b1,x:b2,y:b3,leftshoulder:b4,rightshoulder:b5,back:b6,start:b7,leftstick:b8,rightstick:b9,leftx:a0,lefty:a1,rightx:a2,righty:a3,lefttrigger:a4,righttrigger:a5,dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,
-78696e70757404000000000000000000,XInput Flight Stick (GLFW),platform:Windows,a:b0,b:b1,x:b2,y:b3,leftshoulder:b4,rightshoulder:b5,back:b6,start:b7,leftstick:b8,rightstick:b9,leftx:a0,lefty:a1,rightx:a2,righty:a3,lefttrigger:a4,righttrigger:a5,dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,
-78696e70757405000000000000000000,XInput Dance Pad (GLFW),platform:Windows,a:b0,b:b1,x:b2,y:b3,leftshoulder:b4,rightshoulder:b5,back:b6,start:b7,leftstick:b8,rightstick:b9,leftx:a0,lefty:a1,rightx:a2,righty:a3,lefttrigger:a4,righttrigger:a5,dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,
-78696e70757406000000000000000000,XInput Guitar (GLFW),platform:Windows,a:b0,b:b1,x:b2,y:b3,leftshoulder:b4,rightshoulder:b5,back:b6,start:b7,leftstick:b8,rightstick:b9,leftx:a0,lefty:a1,rightx:a2,righty:a3,lefttrigger:a4,righttrigger:a5,dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,
-78696e70757408000000000000000000,XInput Drum Kit (GLFW),platform:Windows,a:b0,b:b1,x:b2,y:b3,leftshoulder:b4,rightshoulder:b5,back:b6,start:b7,leftstick:b8,rightstick:b9,leftx:a0,lefty:a1,rightx:a2,righty:a3,lefttrigger:a4,righttrigger:a5,dpup:h0.1,dpright:h0.2,dpdown:h0.4,dpleft:h0.8,
-`)
-
 func init() {
-	if err := Update(gamecontrollerdb_txt); err != nil {
-		panic(err)
-	}
-	if err := Update(additionalGLFWGamepads); err != nil {
-		panic(err)
-	}
+	embeddedLines = indexEmbeddedLines(gamecontrollerdb_txt, currentPlatform)
+	loadEnvironmentMappings()
 }
 
 type mappingType int
@@ -125,24 +111,89 @@ type mapping struct {
 	HatState   int
 }
 
+// source represents where an entry comes from. An entry from a greater source is preferred.
+type source int
+
+const (
+	sourceEmbedded source = iota
+	sourceUser
+	sourceWatchedFile
+	sourceEnvironment
+)
+
+// entry is a mapping for a gamepad, which corresponds to a line of the database.
+type entry struct {
+	source source
+
+	// file is the path of the watched file that the entry comes from, or an empty string.
+	file string
+
+	// guid is the GUID without the CRC of the name. See guidKey.
+	guid string
+
+	// crc is the CRC16 of the gamepad name, or 0 if the entry matches any name.
+	crc uint16
+
+	// platform is the platform specified by the platform field, or platformUnknown if the entry is for any platforms.
+	// An entry for another platform is never added.
+	platform platform
+
+	name    string
+	buttons map[StandardButton]*mapping
+	axes    map[StandardAxis]*mapping
+
+	// positiveAxes and negativeAxes are the mappings to the halves of the standard axes, like "+leftx" and "-leftx".
+	positiveAxes map[StandardAxis]*mapping
+	negativeAxes map[StandardAxis]*mapping
+}
+
+// hasMappings reports whether the entry has any mappings.
+func (e *entry) hasMappings() bool {
+	return e.buttons != nil || e.axes != nil || e.positiveAxes != nil || e.negativeAxes != nil
+}
+
+// hasAxis reports whether the entry has a mapping to the standard axis as a whole or by halves.
+func (e *entry) hasAxis(axis StandardAxis) bool {
+	if e == nil {
+		return false
+	}
+	return e.axes[axis] != nil || e.positiveAxes[axis] != nil || e.negativeAxes[axis] != nil
+}
+
 var (
-	gamepadNames          = map[string]string{}
-	gamepadButtonMappings = map[string]map[StandardButton]*mapping{}
-	gamepadAxisMappings   = map[string]map[StandardAxis]*mapping{}
-	mappingsM             sync.RWMutex
+	// entries is the entries keyed by the GUIDs without the CRCs.
+	entries = map[string][]*entry{}
+
+	// resolvedEntries is a cache of the entries resolved for the gamepads' GUIDs.
+	// A nil value means that no entry is found.
+	// resolvedEntries must be cleared whenever entries are updated.
+	resolvedEntries = map[string]*entry{}
+
+	mappingsM sync.Mutex
 )
 
-func parseLine(line string, platform platform) (id string, name string, buttons map[StandardButton]*mapping, axes map[StandardAxis]*mapping, err error) {
+func parseLine(line string, platform platform) (*entry, error) {
 	line = strings.TrimSpace(line)
 	if len(line) == 0 {
-		return "", "", nil, nil, nil
+		return nil, nil
 	}
 	if line[0] == '#' {
-		return "", "", nil, nil, nil
+		return nil, nil
 	}
 	tokens := strings.Split(line, ",")
 	if len(tokens) < 2 {
-		return "", "", nil, nil, fmt.Errorf("gamepaddb: syntax error")
+		return nil, fmt.Errorf("gamepaddb: syntax error")
+	}
+	if !isValidGUID(tokens[0]) {
+		return nil, fmt.Errorf("gamepaddb: invalid GUID: %q", tokens[0])
+	}
+
+	// SDL 2.26 or later embeds the CRC in the GUID. Move the CRC to the field to treat both formats in the same way.
+	guid, crc := guidKey(strings.ToLower(tokens[0]))
+	e := &entry{
+		guid: guid,
+		crc:  crc,
+		name: tokens[1],
 	}
 
 	for _, token := range tokens[2:] {
@@ -151,7 +202,7 @@ func parseLine(line string, platform platform) (id string, name string, buttons
 		}
 		tks := strings.Split(token, ":")
 		if len(tks) < 2 {
-			return "", "", nil, nil, fmt.Errorf("gamepaddb: syntax error")
+			return nil, fmt.Errorf("gamepaddb: syntax error")
 		}
 
 		// Note that the platform part is listed in the definition of SDL_GetPlatform.
@@ -159,68 +210,308 @@ func parseLine(line string, platform platform) (id string, name string, buttons
 			switch tks[1] {
 			case "Windows":
 				if platform != platformWindows {
-					return "", "", nil, nil, nil
+					return nil, nil
 				}
+				e.platform = platformWindows
 			case "Mac OS X":
 				if platform != platformMacOS {
-					return "", "", nil, nil, nil
+					return nil, nil
 				}
+				e.platform = platformMacOS
 			case "Linux":
 				if platform != platformUnix {
-					return "", "", nil, nil, nil
+					return nil, nil
 				}
+				e.platform = platformUnix
 			case "Android":
 				if platform != platformAndroid {
-					return "", "", nil, nil, nil
+					return nil, nil
 				}
+				e.platform = platformAndroid
 			case "iOS":
 				if platform != platformIOS {
-					return "", "", nil, nil, nil
+					return nil, nil
 				}
+				e.platform = platformIOS
 			case "":
 				// Allow any platforms
 			default:
-				return "", "", nil, nil, fmt.Errorf("gamepaddb: unexpected platform: %s", tks[1])
+				return nil, fmt.Errorf("gamepaddb: unexpected platform: %s", tks[1])
+			}
+			continue
+		}
+
+		// The CRC field is used by SDL 2.26 or later. See https://github.com/libsdl-org/SDL/blob/release-2.26.0/src/joystick/SDL_gamecontroller.c.
+		if tks[0] == "crc" {
+			v, err := strconv.ParseUint(tks[1], 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("gamepaddb: invalid CRC: %s", tks[1])
 			}
+			e.crc = uint16(v)
+			continue
+		}
+
+		// A standard axis can be mapped by halves, like "+leftx" and "-leftx".
+		key := tks[0]
+		var half byte
+		if len(key) > 0 && (key[0] == '+' || key[0] == '-') {
+			half = key[0]
+			key = key[1:]
+		}
+
+		// The keys without a corresponding standard button or axis, like SDL 3's "misc2", are ignored.
+		b, isButton := toStandardGamepadButton(key)
+		a, isAxis := toStandardGamepadAxis(key)
+		if half != 0 && !isAxis {
+			continue
+		}
+		if !isButton && !isAxis {
 			continue
 		}
 
 		gb, err := parseMappingElement(tks[1])
 		if err != nil {
-			return "", "", nil, nil, err
+			return nil, err
 		}
 
-		if b, ok := toStandardGamepadButton(tks[0]); ok {
-			if buttons == nil {
-				buttons = map[StandardButton]*mapping{}
+		if isButton {
+			if e.buttons == nil {
+				e.buttons = map[StandardButton]*mapping{}
 			}
-			buttons[b] = gb
+			e.buttons[b] = gb
 			continue
 		}
 
-		if a, ok := toStandardGamepadAxis(tks[0]); ok {
-			if axes == nil {
-				axes = map[StandardAxis]*mapping{}
+		switch half {
+		case '+':
+			if e.positiveAxes == nil {
+				e.positiveAxes = map[StandardAxis]*mapping{}
+			}
+			e.positiveAxes[a] = gb
+		case '-':
+			if e.negativeAxes == nil {
+				e.negativeAxes = map[StandardAxis]*mapping{}
+			}
+			e.negativeAxes[a] = gb
+		default:
+			if e.axes == nil {
+				e.axes = map[StandardAxis]*mapping{}
 			}
-			axes[a] = gb
+			e.axes[a] = gb
+		}
+	}
+
+	return e, nil
+}
+
+// guidKey returns the GUID without the CRC of the name, and the CRC.
+//
+// SDL 2.26 or later stores the CRC16 of the name at the bytes 2 and 3 of a GUID, when the first two bytes are a bus type.
+// The key is used to look up the entries regardless of the CRC.
+func guidKey(guid string) (string, uint16) {
+	if len(guid) != 32 {
+		return guid, 0
+	}
+	bs, err := hex.DecodeString(guid)
+	if err != nil {
+		return guid, 0
+	}
+	if !hasBusType(bs) {
+		return guid, 0
+	}
+	crc := uint16(bs[2]) | uint16(bs[3])<<8
+	bs[2] = 0
+	bs[3] = 0
+	return hex.EncodeToString(bs), crc
+}
+
+// versionlessGUIDKey returns the GUID key with the version zeroed, or false if the GUID doesn't have a version or the version is already zero.
+//
+// A GUID starting with a bus type stores the version at the bytes 12 and 13.
+// Many entries in the database zero the version so that the entries cover all the firmware revisions.
+func versionlessGUIDKey(key string) (string, bool) {
+	if len(key) != 32 {
+		return "", false
+	}
+	bs, err := hex.DecodeString(key)
+	if err != nil {
+		return "", false
+	}
+	if !hasBusType(bs) {
+		return "", false
+	}
+	if bs[12] == 0 && bs[13] == 0 {
+		return "", false
+	}
+	bs[12] = 0
+	bs[13] = 0
+	return hex.EncodeToString(bs), true
+}
+
+// hasBusType reports whether the GUID starts with a bus type, i.e., the GUID is created by SDL_CreateJoystickGUID.
+// Other GUIDs, like the ones of SDL's Emscripten backend, start with a name.
+func hasBusType(guid []byte) bool {
+	bus := uint16(guid[0]) | uint16(guid[1])<<8
+	return bus < ' ' || bus == 0xff
+}
+
+// addEntry adds the entry. The entry overrides the existing entry for the same source, file, GUID, CRC, and platform.
+func addEntry(e *entry) {
+	es := entries[e.guid]
+	for i, e2 := range es {
+		if e2.source != e.source || e2.file != e.file || e2.crc != e.crc || e2.platform != e.platform {
+			continue
+		}
+		es = append(es[:i], es[i+1:]...)
+		break
+	}
+	entries[e.guid] = append(es, e)
+}
+
+// removeFileEntries removes all the entries from the file.
+func removeFileEntries(file string) {
+	for key := range entries {
+		removeEntries(key, func(e *entry) bool { return e.file == file })
+	}
+}
+
+// removeEntries removes the entries for the GUID key that satisfy the condition, and reports whether any entry is removed.
+func removeEntries(key string, cond func(e *entry) bool) bool {
+	es, ok := entries[key]
+	if !ok {
+		return false
+	}
+	var newES []*entry
+	for _, e := range es {
+		if cond(e) {
+			continue
+		}
+		newES = append(newES, e)
+	}
+	if len(newES) == len(es) {
+		return false
+	}
+	if len(newES) == 0 {
+		delete(entries, key)
+		return true
+	}
+	entries[key] = newES
+	return true
+}
+
+// lookupEntry returns the entry for the gamepad's GUID, or nil if not found.
+func lookupEntry(id string) *entry {
+	if e, ok := resolvedEntries[id]; ok {
+		return e
+	}
+	e := findEntry(id)
+	resolvedEntries[id] = e
+	return e
+}
+
+// findEntry finds the entry for the gamepad's GUID in the same way as SDL.
+//
+// An entry from a greater source is preferred regardless of the other conditions.
+// Then, an entry with the same CRC is preferred, and then an entry without a CRC is used.
+// If the gamepad's GUID doesn't have a CRC, an entry with any CRC is used.
+// For the same CRC, an entry for the current platform is preferred to an entry for any platforms.
+//
+// If no entry matches the exact GUID, an entry for the GUID with the version zeroed is used as SDL does.
+func findEntry(id string) *entry {
+	guid, crc := guidKey(id)
+
+	if e := findEntryByGUIDKey(guid, crc); e != nil {
+		return e
+	}
+	if guid, ok := versionlessGUIDKey(guid); ok {
+		if e := findEntryByGUIDKey(guid, crc); e != nil {
+			return e
+		}
+	}
+
+	if currentPlatform == platformAndroid {
+		return androidDefaultEntry(id)
+	}
+	return nil
+}
+
+// findEntryByGUIDKey finds the best entry for the GUID key and the CRC, or returns nil if not found.
+func findEntryByGUIDKey(guid string, crc uint16) *entry {
+	loadEmbeddedEntries(guid)
+
+	var found *entry
+	var foundScore int
+	for _, e := range entries[guid] {
+		score, ok := e.matchScore(crc)
+		if !ok {
 			continue
 		}
+		// For the same score, the entry added later is preferred.
+		if found == nil || score >= foundScore {
+			found = e
+			foundScore = score
+		}
+	}
+	return found
+}
 
-		// The buttons like "misc1" are ignored so far.
-		// There is no corresponding button in the Web standard gamepad layout.
+// matchScore returns how well the entry matches a gamepad with the CRC. A greater score is better.
+// matchScore returns false if the entry doesn't match.
+func (e *entry) matchScore(crc uint16) (int, bool) {
+	var score int
+	switch {
+	case crc != 0 && e.crc == crc:
+		score = 4
+	case e.crc == 0:
+		score = 2
+	case crc == 0:
+		score = 0
+	default:
+		return 0, false
 	}
+	if e.platform != platformUnknown {
+		score++
+	}
+	score += int(e.source) * 8
+	return score, true
+}
 
-	return tokens[0], tokens[1], buttons, axes, nil
+// isValidGUID reports whether the GUID is 32 hexadecimal digits, or "xinput", which SDL uses for the generic XInput mapping.
+func isValidGUID(guid string) bool {
+	if guid == "xinput" {
+		return true
+	}
+	if len(guid) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(guid)
+	return err == nil
 }
 
 func parseMappingElement(str string) (*mapping, error) {
+	if len(str) == 0 {
+		return nil, fmt.Errorf("gamepaddb: empty mapping")
+	}
+
+	// An axis is inverted with '~'. SDL accepts only a suffix like "a1~",
+	// but a prefix like "~a1" is also accepted as some mappings in the wild use it.
+	var tilda bool
+	if str[0] == '~' {
+		str = str[1:]
+		tilda = true
+	} else if str[len(str)-1] == '~' {
+		str = str[:len(str)-1]
+		tilda = true
+	}
+	if len(str) == 0 {
+		return nil, fmt.Errorf("gamepaddb: empty mapping")
+	}
+	if tilda && str[0] != 'a' && str[0] != '+' && str[0] != '-' {
+		return nil, fmt.Errorf("gamepaddb: only an axis can be inverted: %s", str)
+	}
+
 	switch {
 	case str[0] == 'a' || strings.HasPrefix(str, "+a") || strings.HasPrefix(str, "-a"):
-		var tilda bool
-		if str[len(str)-1] == '~' {
-			str = str[:len(str)-1]
-			tilda = true
-		}
 
 		min := -1
 		max := 1
@@ -280,18 +571,25 @@ func parseMappingElement(str string) (*mapping, error) {
 		}, nil
 
 	case str[0] == 'h':
+		// The format is "h<index>.<mask>". The mask is a combination of HatUp, HatRight, HatDown, and HatLeft.
 		tokens := strings.Split(str[1:], ".")
-		if len(tokens) < 2 {
+		if len(tokens) != 2 {
 			return nil, fmt.Errorf("gamepaddb: unexpected hat: %s", str)
 		}
 		index, err := strconv.Atoi(tokens[0])
 		if err != nil {
 			return nil, err
 		}
+		if index < 0 {
+			return nil, fmt.Errorf("gamepaddb: unexpected hat index: %s", str)
+		}
 		hat, err := strconv.Atoi(tokens[1])
 		if err != nil {
 			return nil, err
 		}
+		if hat <= 0 || hat > HatUp|HatRight|HatDown|HatLeft {
+			return nil, fmt.Errorf("gamepaddb: unexpected hat mask: %s", str)
+		}
 		return &mapping{
 			Type:     mappingTypeHat,
 			Index:    index,
@@ -299,7 +597,7 @@ func parseMappingElement(str string) (*mapping, error) {
 		}, nil
 	}
 
-	return nil, fmt.Errorf("gamepaddb: unepxected mapping: %s", str)
+	return nil, fmt.Errorf("gamepaddb: unexpected mapping: %s", str)
 }
 
 func toStandardGamepadButton(str string) (StandardButton, bool) {
@@ -338,6 +636,18 @@ func toStandardGamepadButton(str string) (StandardButton, bool) {
 		return StandardButtonFrontBottomLeft, true
 	case "righttrigger":
 		return StandardButtonFrontBottomRight, true
+	case "misc1":
+		return StandardButtonMisc1, true
+	case "paddle1":
+		return StandardButtonPaddle1, true
+	case "paddle2":
+		return StandardButtonPaddle2, true
+	case "paddle3":
+		return StandardButtonPaddle3, true
+	case "paddle4":
+		return StandardButtonPaddle4, true
+	case "touchpad":
+		return StandardButtonTouchpad, true
 	default:
 		return 0, false
 	}
@@ -358,35 +668,12 @@ func toStandardGamepadAxis(str string) (StandardAxis, bool) {
 	}
 }
 
-func buttonMappings(id string) map[StandardButton]*mapping {
-	if m, ok := gamepadButtonMappings[id]; ok {
-		return m
-	}
-	if currentPlatform == platformAndroid {
-		if addAndroidDefaultMappings(id) {
-			return gamepadButtonMappings[id]
-		}
-	}
-	return nil
-}
-
-func axisMappings(id string) map[StandardAxis]*mapping {
-	if m, ok := gamepadAxisMappings[id]; ok {
-		return m
-	}
-	if currentPlatform == platformAndroid {
-		if addAndroidDefaultMappings(id) {
-			return gamepadAxisMappings[id]
-		}
-	}
-	return nil
-}
-
 func HasStandardLayoutMapping(id string) bool {
-	mappingsM.RLock()
-	defer mappingsM.RUnlock()
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
 
-	return buttonMappings(id) != nil || axisMappings(id) != nil
+	e := lookupEntry(id)
+	return e != nil && e.hasMappings()
 }
 
 type GamepadState interface {
@@ -395,36 +682,53 @@ type GamepadState interface {
 	Hat(index int) int
 }
 
+// analogButtonState is implemented by a GamepadState that can report analog values of the buttons, e.g., pressure-sensitive buttons.
+type analogButtonState interface {
+	// ButtonValue returns the value of the button in [0, 1].
+	ButtonValue(index int) float64
+}
+
 func Name(id string) string {
-	mappingsM.RLock()
-	defer mappingsM.RUnlock()
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
 
-	return gamepadNames[id]
+	if e := lookupEntry(id); e != nil {
+		return e.name
+	}
+	return ""
 }
 
 func HasStandardAxis(id string, axis StandardAxis) bool {
-	mappingsM.RLock()
-	defer mappingsM.RUnlock()
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
 
-	mappings := axisMappings(id)
-	if mappings == nil {
-		return false
-	}
-	return mappings[axis] != nil
+	return lookupEntry(id).hasAxis(axis)
 }
 
+// AxisValue returns the value of the standard axis, which is rescaled with the axis hint for the gamepad if any.
 func AxisValue(id string, axis StandardAxis, state GamepadState) float64 {
-	mappingsM.RLock()
-	defer mappingsM.RUnlock()
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	return applyAxisHint(id, axis, lookupEntry(id).axisValue(axis, state))
+}
 
-	mappings := axisMappings(id)
-	if mappings == nil {
+func (e *entry) axisValue(axis StandardAxis, state GamepadState) float64 {
+	if e == nil {
 		return 0
 	}
 
-	mapping := mappings[axis]
+	mapping := e.axes[axis]
 	if mapping == nil {
-		return 0
+		// The halves of the axis are mapped separately. Each half's input is normalized to [0, 1] as SDL does.
+		var v float64
+		if m := e.positiveAxes[axis]; m != nil {
+			v += m.normalizedValue(state)
+		}
+		if m := e.negativeAxes[axis]; m != nil {
+			v -= m.normalizedValue(state)
+		}
+		return v
 	}
 
 	switch mapping.Type {
@@ -436,55 +740,28 @@ func AxisValue(id string, axis StandardAxis, state GamepadState) float64 {
 			return -1
 		}
 		return v
+	// A standard axis is a stick, so a button or a hat mapped to the whole axis makes the axis 1 or 0 at rest.
+	// A stick driven by two buttons is mapped by halves like "-leftx:b2,+leftx:b3", which makes the axis -1, 0, or 1.
 	case mappingTypeButton:
 		if state.Button(mapping.Index) {
 			return 1
-		} else {
-			return -1
 		}
+		return 0
 	case mappingTypeHat:
-		if state.Hat(mapping.Index)&mapping.HatState != 0 {
+		if mapping.isHatPressed(state) {
 			return 1
-		} else {
-			return -1
 		}
+		return 0
 	}
 
 	return 0
 }
 
-func HasStandardButton(id string, button StandardButton) bool {
-	mappingsM.RLock()
-	defer mappingsM.RUnlock()
-
-	mappings := buttonMappings(id)
-	if mappings == nil {
-		return false
-	}
-	return mappings[button] != nil
-}
-
-func ButtonValue(id string, button StandardButton, state GamepadState) float64 {
-	mappingsM.RLock()
-	defer mappingsM.RUnlock()
-
-	return buttonValue(id, button, state)
-}
-
-func buttonValue(id string, button StandardButton, state GamepadState) float64 {
-	mappings := buttonMappings(id)
-	if mappings == nil {
-		return 0
-	}
-
-	mapping := mappings[button]
-	if mapping == nil {
-		return 0
-	}
-
-	switch mapping.Type {
+// normalizedValue returns the value of the input in [0, 1].
+func (m *mapping) normalizedValue(state GamepadState) float64 {
+	switch m.Type {
 	case mappingTypeAxis:
-		v := state.Axis(mapping.Index)*float64(mapping.AxisScale) + float64(mapping.AxisOffset)
+		v := state.Axis(m.Index)*float64(m.AxisScale) + float64(m.AxisOffset)
 		if v > 1 {
 			v = 1
 		} else if v < -1 {
@@ -493,102 +770,357 @@ func buttonValue(id string, button StandardButton, state GamepadState) float64 {
 		// Adjust [-1, 1] to [0, 1]
 		return (v + 1) / 2
 	case mappingTypeButton:
-		if state.Button(mapping.Index) {
+		if s, ok := state.(analogButtonState); ok {
+			v := s.ButtonValue(m.Index)
+			if v > 1 {
+				v = 1
+			} else if v < 0 {
+				v = 0
+			}
+			return v
+		}
+		if state.Button(m.Index) {
 			return 1
 		}
 		return 0
 	case mappingTypeHat:
-		if state.Hat(mapping.Index)&mapping.HatState != 0 {
+		if m.isHatPressed(state) {
 			return 1
 		}
 		return 0
 	}
-
 	return 0
 }
 
+// isHatPressed reports whether the hat is in the direction of the mapping.
+//
+// A mapping to a combined mask like "h0.3" (up and right) is pressed when any of the directions is pressed, as SDL does.
+func (m *mapping) isHatPressed(state GamepadState) bool {
+	return state.Hat(m.Index)&m.HatState != 0
+}
+
+// IsTriggerAxis reports whether the axis is mapped to an analog trigger as a whole, i.e., from -1 to 1.
+// An axis mapped to triggers by halves is not a trigger axis, as the axis is centered when the triggers are released.
+func IsTriggerAxis(id string, axis int) bool {
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	return lookupEntry(id).isTriggerAxis(axis)
+}
+
+func (e *entry) isTriggerAxis(axis int) bool {
+	for _, b := range []StandardButton{StandardButtonFrontBottomLeft, StandardButtonFrontBottomRight} {
+		if a, ok := e.triggerAxis(b); ok && a == axis {
+			return true
+		}
+	}
+	return false
+}
+
+// TriggerAxis returns the axis mapped to the standard button as a whole, i.e., from -1 to 1.
+// The second value is false if the button is not mapped to such an axis.
+func TriggerAxis(id string, button StandardButton) (int, bool) {
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	return lookupEntry(id).triggerAxis(button)
+}
+
+func (e *entry) triggerAxis(button StandardButton) (int, bool) {
+	if e == nil {
+		return 0, false
+	}
+	m := e.buttons[button]
+	if m == nil || m.Type != mappingTypeAxis {
+		return 0, false
+	}
+	if m.AxisScale != 1 || m.AxisOffset != 0 {
+		return 0, false
+	}
+	return m.Index, true
+}
+
+func HasStandardButton(id string, button StandardButton) bool {
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	return lookupEntry(id).hasButton(button)
+}
+
+func (e *entry) hasButton(button StandardButton) bool {
+	if e == nil {
+		return false
+	}
+	return e.buttons[button] != nil
+}
+
+// ButtonValue returns the value of the standard button in [0, 1].
+//
+// For a button mapped from an axis, the value is the analog value of the axis, respecting the half-axis and the inversion modifiers.
+// For a button mapped from a button, the value is 0 or 1, or the analog value if state can report analog values of the buttons.
+func ButtonValue(id string, button StandardButton, state GamepadState) float64 {
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	return lookupEntry(id).buttonValue(button, state)
+}
+
+func (e *entry) buttonValue(button StandardButton, state GamepadState) float64 {
+	if e == nil {
+		return 0
+	}
+
+	mapping := e.buttons[button]
+	if mapping == nil {
+		return 0
+	}
+	return mapping.normalizedValue(state)
+}
+
 // ButtonPressedThreshold represents the value up to which a button counts as not yet pressed.
 // This has been set to match XInput's trigger dead zone.
 // See https://source.chromium.org/chromium/chromium/src/+/main:device/gamepad/public/cpp/gamepad.h;l=22-23;drc=6997f8a177359bb99598988ed5e900841984d242
 // Note: should be used with >, not >=, comparisons.
 const ButtonPressedThreshold = 30.0 / 255.0
 
+// halfAxisButtonPressedThreshold is the threshold of the value in [0, 1] for a button mapped from a half of an axis like "+a7".
+// This corresponds to the axis value 0.5 or -0.5, i.e., the middle of the half, as SDL does.
+const halfAxisButtonPressedThreshold = 0.5
+
+// ButtonReleaseHysteresis is the margin below the threshold to release a button mapped from an axis.
+// Without this, a button would be pressed and released rapidly when the axis value stays around the threshold.
+const ButtonReleaseHysteresis = 0.05
+
 func IsButtonPressed(id string, button StandardButton, state GamepadState) bool {
-	mappingsM.RLock()
-	defer mappingsM.RUnlock()
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
 
-	mappings, ok := gamepadButtonMappings[id]
-	if !ok {
+	return lookupEntry(id).isButtonPressed(button, state, false)
+}
+
+// IsButtonPressedWithHysteresis is the same as IsButtonPressed, but a button mapped from an axis is kept pressed
+// until the value falls below the threshold by ButtonReleaseHysteresis, if wasPressed is true.
+// wasPressed should be the last result of IsButtonPressedWithHysteresis for the same gamepad and button.
+func IsButtonPressedWithHysteresis(id string, button StandardButton, state GamepadState, wasPressed bool) bool {
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	return lookupEntry(id).isButtonPressed(button, state, wasPressed)
+}
+
+func (e *entry) isButtonPressed(button StandardButton, state GamepadState, wasPressed bool) bool {
+	if e == nil {
 		return false
 	}
 
-	mapping := mappings[button]
+	mapping := e.buttons[button]
 	if mapping == nil {
 		return false
 	}
 
 	switch mapping.Type {
 	case mappingTypeAxis:
-		v := buttonValue(id, button, state)
-		return v > ButtonPressedThreshold
+		threshold := ButtonPressedThreshold
+		if mapping.AxisOffset != 0 {
+			threshold = halfAxisButtonPressedThreshold
+		}
+		if wasPressed {
+			threshold -= ButtonReleaseHysteresis
+		}
+		v := e.buttonValue(button, state)
+		return v > threshold
 	case mappingTypeButton:
 		return state.Button(mapping.Index)
 	case mappingTypeHat:
-		return state.Hat(mapping.Index)&mapping.HatState != 0
+		return mapping.isHatPressed(state)
 	}
 
 	return false
 }
 
-// Update adds new gamepad mappings.
-// The string must be in the format of SDL_GameControllerDB.
+// lineError is an error at a line of the mappings.
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+func (e *lineError) Unwrap() error {
+	return e.err
+}
+
+// lineErrors is the errors at the lines of the mappings.
+type lineErrors []*lineError
+
+func (e lineErrors) Error() string {
+	var strs []string
+	for _, err := range e {
+		strs = append(strs, err.Error())
+	}
+	return "gamepaddb: invalid mappings: " + strings.Join(strs, "; ")
+}
+
+// Update adds new gamepad mappings, and returns the number of the added mappings for the current platform.
+// The data must be in the format of SDL_GameControllerDB.
+// A mapping overrides the existing mapping for the same GUID and CRC.
 //
-// Update works atomically. If an error happens, nothing is updated.
-func Update(mappingData []byte) error {
-	mappingsM.Lock()
-	defer mappingsM.Unlock()
+// Update works atomically. If an error happens, nothing is updated, and the error reports all the invalid lines.
+// In the strict mode, the error is ValidationErrors. See SetStrict.
+func Update(r io.Reader) (int, error) {
+	return update(r, sourceUser, "")
+}
+
+// update parses the mappings and adds them as entries from the source.
+// If file is not empty, the entries from the file are replaced with the new entries.
+func update(r io.Reader, source source, file string) (int, error) {
+	es, errs, err := parseMappings(r, source, file)
+	if err != nil {
+		return 0, err
+	}
+
+	var lineErrs lineErrors
+	var validationErrs ValidationErrors
+	for _, err := range errs {
+		switch err := err.(type) {
+		case *lineError:
+			lineErrs = append(lineErrs, err)
+		case ValidationErrors:
+			validationErrs = append(validationErrs, err...)
+		}
+	}
+	if len(validationErrs) > 0 {
+		return 0, validationErrs
+	}
+	if len(lineErrs) > 0 {
+		return 0, lineErrs
+	}
 
-	buf := bytes.NewBuffer(mappingData)
-	s := bufio.NewScanner(buf)
+	addEntries(es, file)
+	return len(es), nil
+}
 
-	type parsedLine struct {
-		id      string
-		name    string
-		buttons map[StandardButton]*mapping
-		axes    map[StandardAxis]*mapping
+// updateLeniently is the same as update, but adds the valid mappings even if there are invalid lines, as SDL does.
+// The errors at the invalid lines are returned in the line order.
+func updateLeniently(r io.Reader, source source) (int, []error, error) {
+	es, errs, err := parseMappings(r, source, "")
+	if err != nil {
+		return 0, nil, err
 	}
-	var lines []parsedLine
+	addEntries(es, "")
+	return len(es), errs, nil
+}
 
-	for s.Scan() {
+// parseMappings parses the mappings as entries from the source.
+// The errors at the invalid lines are returned in the line order.
+// An error at a line is *lineError, or ValidationErrors in the strict mode.
+func parseMappings(r io.Reader, source source, file string) ([]*entry, []error, error) {
+	s := bufio.NewScanner(r)
+
+	var es []*entry
+	var errs []error
+	strict := isStrict()
+
+	for lineNumber := 1; s.Scan(); lineNumber++ {
 		line := s.Text()
-		id, name, buttons, axes, err := parseLine(line, currentPlatform)
+		if strict {
+			if vErrs := validateLine(line, lineNumber); len(vErrs) > 0 {
+				errs = append(errs, ValidationErrors(vErrs))
+				continue
+			}
+		}
+		e, err := parseLine(line, currentPlatform)
 		if err != nil {
-			return err
-		}
-		if id != "" {
-			lines = append(lines, parsedLine{
-				id:      id,
-				name:    name,
-				buttons: buttons,
-				axes:    axes,
+			errs = append(errs, &lineError{
+				line: lineNumber,
+				err:  err,
 			})
+			continue
+		}
+		if e != nil {
+			e.source = source
+			e.file = file
+			es = append(es, e)
 		}
 	}
 
 	if err := s.Err(); err != nil {
-		return err
+		return nil, nil, err
 	}
+	return es, errs, nil
+}
+
+// addEntries adds the entries.
+// If file is not empty, the entries from the file are replaced with the new entries.
+func addEntries(es []*entry, file string) {
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
 
-	for _, l := range lines {
-		gamepadNames[l.id] = l.name
-		gamepadButtonMappings[l.id] = l.buttons
-		gamepadAxisMappings[l.id] = l.axes
+	if file != "" {
+		removeFileEntries(file)
 	}
+	for _, e := range es {
+		addEntry(e)
+	}
+	resolvedEntries = map[string]*entry{}
+}
 
-	return nil
+// RemoveMapping removes the mappings added at runtime for the GUID, and reports whether any mapping is removed.
+// The mappings from the environment variables and the watched files are also removed, but the embedded mappings are kept.
+// The CRC in the GUID is ignored, so the mappings for any names of the same device are removed.
+//
+// The removed mappings take effect immediately even for already connected gamepads.
+// Note that a mapping from a watched file is added again when the file is modified.
+func RemoveMapping(guid string) bool {
+	if !isValidGUID(guid) {
+		return false
+	}
+	key, _ := guidKey(strings.ToLower(guid))
+
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	if !removeEntries(key, func(e *entry) bool { return e.source != sourceEmbedded }) {
+		return false
+	}
+	resolvedEntries = map[string]*entry{}
+	return true
 }
 
-func addAndroidDefaultMappings(id string) bool {
+// ResetMappingsToDefault removes all the mappings and the axis hints added at runtime, and stops watching the files.
+// After this, only the embedded mappings and the built-in axis hints are used.
+//
+// The embedded mappings take effect immediately even for already connected gamepads.
+func ResetMappingsToDefault() {
+	unwatchFiles()
+
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	for key := range entries {
+		removeEntries(key, func(e *entry) bool { return e.source != sourceEmbedded })
+	}
+	resolvedEntries = map[string]*entry{}
+	removeUserAxisHints()
+}
+
+// androidSDKVersion is the SDK version of Android, which is used for the default mappings on Android.
+var androidSDKVersion int
+
+// SetAndroidSDKVersion sets the SDK version of Android.
+// This must be called before any gamepad is added.
+func SetAndroidSDKVersion(version int) {
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	androidSDKVersion = version
+	resolvedEntries = map[string]*entry{}
+}
+
+// androidDefaultEntry returns the default entry for the gamepad's GUID on Android, or nil if the GUID is not for a gamepad.
+func androidDefaultEntry(id string) *entry {
 	// See https://github.com/libsdl-org/SDL/blob/120c76c84bbce4c1bfed4e9eb74e10678bd83120/src/joystick/SDL_gamecontroller.c#L468-L568
 
 	const faceButtonMask = ((1 << SDLControllerButtonA) |
@@ -597,126 +1129,131 @@ func addAndroidDefaultMappings(id string) bool {
 		(1 << SDLControllerButtonY))
 
 	idBytes, err := hex.DecodeString(id)
-	if err != nil {
-		return false
+	if err != nil || len(idBytes) != 16 {
+		return nil
 	}
 	buttonMask := uint16(idBytes[12]) | (uint16(idBytes[13]) << 8)
 	axisMask := uint16(idBytes[14]) | (uint16(idBytes[15]) << 8)
 	if buttonMask == 0 && axisMask == 0 {
-		return false
+		return nil
 	}
 	if buttonMask&faceButtonMask == 0 {
-		return false
+		return nil
 	}
 
-	gamepadButtonMappings[id] = map[StandardButton]*mapping{}
-	gamepadAxisMappings[id] = map[StandardAxis]*mapping{}
+	buttons := map[StandardButton]*mapping{}
+	axes := map[StandardAxis]*mapping{}
 
 	// For mappings, see mobile/ebitenmobileview/input_android.go.
 
 	if buttonMask&(1<<SDLControllerButtonA) != 0 {
-		gamepadButtonMappings[id][StandardButtonRightBottom] = &mapping{
+		buttons[StandardButtonRightBottom] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonA,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonB) != 0 {
-		gamepadButtonMappings[id][StandardButtonRightRight] = &mapping{
+		buttons[StandardButtonRightRight] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonB,
 		}
 	} else {
 		// Use the back button as "B" for easy UI navigation with TV remotes.
-		gamepadButtonMappings[id][StandardButtonRightRight] = &mapping{
+		buttons[StandardButtonRightRight] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonBack,
 		}
 		buttonMask &^= uint16(1) << SDLControllerButtonBack
 	}
 	if buttonMask&(1<<SDLControllerButtonX) != 0 {
-		gamepadButtonMappings[id][StandardButtonRightLeft] = &mapping{
+		buttons[StandardButtonRightLeft] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonX,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonY) != 0 {
-		gamepadButtonMappings[id][StandardButtonRightTop] = &mapping{
+		buttons[StandardButtonRightTop] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonY,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonBack) != 0 {
-		gamepadButtonMappings[id][StandardButtonCenterLeft] = &mapping{
+		buttons[StandardButtonCenterLeft] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonBack,
 		}
 	}
-	if buttonMask&(1<<SDLControllerButtonGuide) != 0 {
-		// TODO: If SDKVersion >= 30, add this code:
-		//
-		//     gamepadButtonMappings[id][StandardButtonCenterCenter] = &mapping{
-		//         Type:  mappingTypeButton,
-		//         Index: SDLControllerButtonGuide,
-		//     }
+	// The guide button is delivered to applications only on Android 11 (SDK version 30) or later.
+	if buttonMask&(1<<SDLControllerButtonGuide) != 0 && androidSDKVersion >= 30 {
+		buttons[StandardButtonCenterCenter] = &mapping{
+			Type:  mappingTypeButton,
+			Index: SDLControllerButtonGuide,
+		}
 	}
 	if buttonMask&(1<<SDLControllerButtonStart) != 0 {
-		gamepadButtonMappings[id][StandardButtonCenterRight] = &mapping{
+		buttons[StandardButtonCenterRight] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonStart,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonLeftStick) != 0 {
-		gamepadButtonMappings[id][StandardButtonLeftStick] = &mapping{
+		buttons[StandardButtonLeftStick] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonLeftStick,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonRightStick) != 0 {
-		gamepadButtonMappings[id][StandardButtonRightStick] = &mapping{
+		buttons[StandardButtonRightStick] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonRightStick,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonLeftShoulder) != 0 {
-		gamepadButtonMappings[id][StandardButtonFrontTopLeft] = &mapping{
+		buttons[StandardButtonFrontTopLeft] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonLeftShoulder,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonRightShoulder) != 0 {
-		gamepadButtonMappings[id][StandardButtonFrontTopRight] = &mapping{
+		buttons[StandardButtonFrontTopRight] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonRightShoulder,
 		}
 	}
 
 	if buttonMask&(1<<SDLControllerButtonDpadUp) != 0 {
-		gamepadButtonMappings[id][StandardButtonLeftTop] = &mapping{
+		buttons[StandardButtonLeftTop] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonDpadUp,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonDpadDown) != 0 {
-		gamepadButtonMappings[id][StandardButtonLeftBottom] = &mapping{
+		buttons[StandardButtonLeftBottom] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonDpadDown,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonDpadLeft) != 0 {
-		gamepadButtonMappings[id][StandardButtonLeftLeft] = &mapping{
+		buttons[StandardButtonLeftLeft] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonDpadLeft,
 		}
 	}
 	if buttonMask&(1<<SDLControllerButtonDpadRight) != 0 {
-		gamepadButtonMappings[id][StandardButtonLeftRight] = &mapping{
+		buttons[StandardButtonLeftRight] = &mapping{
 			Type:  mappingTypeButton,
 			Index: SDLControllerButtonDpadRight,
 		}
 	}
+	if buttonMask&(1<<SDLControllerButtonMisc1) != 0 {
+		buttons[StandardButtonMisc1] = &mapping{
+			Type:  mappingTypeButton,
+			Index: SDLControllerButtonMisc1,
+		}
+	}
 
 	if axisMask&(1<<SDLControllerAxisLeftX) != 0 {
-		gamepadAxisMappings[id][StandardAxisLeftStickHorizontal] = &mapping{
+		axes[StandardAxisLeftStickHorizontal] = &mapping{
 			Type:       mappingTypeAxis,
 			Index:      SDLControllerAxisLeftX,
 			AxisScale:  1,
@@ -724,7 +1261,7 @@ func addAndroidDefaultMappings(id string) bool {
 		}
 	}
 	if axisMask&(1<<SDLControllerAxisLeftY) != 0 {
-		gamepadAxisMappings[id][StandardAxisLeftStickVertical] = &mapping{
+		axes[StandardAxisLeftStickVertical] = &mapping{
 			Type:       mappingTypeAxis,
 			Index:      SDLControllerAxisLeftY,
 			AxisScale:  1,
@@ -732,7 +1269,7 @@ func addAndroidDefaultMappings(id string) bool {
 		}
 	}
 	if axisMask&(1<<SDLControllerAxisRightX) != 0 {
-		gamepadAxisMappings[id][StandardAxisRightStickHorizontal] = &mapping{
+		axes[StandardAxisRightStickHorizontal] = &mapping{
 			Type:       mappingTypeAxis,
 			Index:      SDLControllerAxisRightX,
 			AxisScale:  1,
@@ -740,7 +1277,7 @@ func addAndroidDefaultMappings(id string) bool {
 		}
 	}
 	if axisMask&(1<<SDLControllerAxisRightY) != 0 {
-		gamepadAxisMappings[id][StandardAxisRightStickVertical] = &mapping{
+		axes[StandardAxisRightStickVertical] = &mapping{
 			Type:       mappingTypeAxis,
 			Index:      SDLControllerAxisRightY,
 			AxisScale:  1,
@@ -748,7 +1285,7 @@ func addAndroidDefaultMappings(id string) bool {
 		}
 	}
 	if axisMask&(1<<SDLControllerAxisTriggerLeft) != 0 {
-		gamepadButtonMappings[id][StandardButtonFrontBottomLeft] = &mapping{
+		buttons[StandardButtonFrontBottomLeft] = &mapping{
 			Type:       mappingTypeAxis,
 			Index:      SDLControllerAxisTriggerLeft,
 			AxisScale:  1,
@@ -756,7 +1293,7 @@ func addAndroidDefaultMappings(id string) bool {
 		}
 	}
 	if axisMask&(1<<SDLControllerAxisTriggerRight) != 0 {
-		gamepadButtonMappings[id][StandardButtonFrontBottomRight] = &mapping{
+		buttons[StandardButtonFrontBottomRight] = &mapping{
 			Type:       mappingTypeAxis,
 			Index:      SDLControllerAxisTriggerRight,
 			AxisScale:  1,
@@ -764,5 +1301,9 @@ func addAndroidDefaultMappings(id string) bool {
 		}
 	}
 
-	return true
+	return &entry{
+		guid:    id,
+		buttons: buttons,
+		axes:    axes,
+	}
 }
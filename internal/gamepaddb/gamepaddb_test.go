@@ -15,7 +15,13 @@
 package gamepaddb_test
 
 import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2/internal/gamepaddb"
 )
@@ -56,7 +62,7 @@ func TestUpdate(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		err := gamepaddb.Update([]byte(c.Input))
+		_, err := gamepaddb.Update(strings.NewReader(c.Input))
 		if err == nil && c.Err {
 			t.Errorf("Update(%q) should return an error but not", c.Input)
 		}
@@ -65,3 +71,1164 @@ func TestUpdate(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdateCountAndErrors(t *testing.T) {
+	cases := []struct {
+		Input string
+		Err   bool
+	}{
+		{
+			Input: "0000000000000000000000000000000g,foo",
+			Err:   true,
+		},
+		{
+			Input: "0000,foo",
+			Err:   true,
+		},
+		{
+			Input: "00000000000000000000000000000000,foo,a:",
+			Err:   true,
+		},
+		{
+			Input: "00000000000000000000000000000000,foo,crc:1234,misc1:b15,",
+			Err:   false,
+		},
+	}
+
+	for _, c := range cases {
+		_, err := gamepaddb.Update(strings.NewReader(c.Input))
+		if err == nil && c.Err {
+			t.Errorf("Update(%q) should return an error but not", c.Input)
+		}
+		if err != nil && !c.Err {
+			t.Errorf("Update(%q) should not return an error but returned %v", c.Input, err)
+		}
+	}
+
+	const id = "03000000000000000000000000000003"
+
+	n, err := gamepaddb.Update(strings.NewReader("# comment\n" +
+		id + ",Old Name,a:b0,\n" +
+		id + ",New Name,a:b1,\n" +
+		"03000000000000000000000000000004,Other Platform,a:b0,platform:Foo,\n"))
+	if err == nil {
+		t.Fatal("Update should return an error but not")
+	}
+	if n != 0 {
+		t.Errorf("got: %d, want: 0", n)
+	}
+	if got := err.Error(); !strings.Contains(got, "line 4:") {
+		t.Errorf("the error should include the line number: %q", got)
+	}
+	if got := gamepaddb.Name(id); got != "" {
+		t.Errorf("nothing should be updated on an error: got: %q", got)
+	}
+
+	// A later mapping overrides an earlier one for the same GUID.
+	n, err = gamepaddb.Update(strings.NewReader("# comment\n" +
+		id + ",Old Name,a:b0,\n" +
+		id + ",New Name,a:b1,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got: %d, want: 2", n)
+	}
+	if got, want := gamepaddb.Name(id), "New Name"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestIsTriggerAxis(t *testing.T) {
+	const (
+		idSeparate = "03000000000000000000000000000001"
+		idCombined = "03000000000000000000000000000002"
+	)
+	if _, err := gamepaddb.Update(strings.NewReader(idSeparate + ",Separate Triggers,a:b0,leftx:a0,lefty:a1,lefttrigger:a2,righttrigger:a5,\n" +
+		idCombined + ",Combined Triggers,a:b0,leftx:a0,lefty:a1,lefttrigger:+a2,righttrigger:-a2,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ID   string
+		Axis int
+		Want bool
+	}{
+		{ID: idSeparate, Axis: 0, Want: false},
+		{ID: idSeparate, Axis: 2, Want: true},
+		{ID: idSeparate, Axis: 5, Want: true},
+		{ID: idCombined, Axis: 2, Want: false},
+	}
+	for _, c := range cases {
+		if got := gamepaddb.IsTriggerAxis(c.ID, c.Axis); got != c.Want {
+			t.Errorf("IsTriggerAxis(%q, %d): got: %t, want: %t", c.ID, c.Axis, got, c.Want)
+		}
+	}
+
+	if a, ok := gamepaddb.TriggerAxis(idSeparate, gamepaddb.StandardButtonFrontBottomRight); !ok || a != 5 {
+		t.Errorf("TriggerAxis(%q, StandardButtonFrontBottomRight): got: (%d, %t), want: (5, true)", idSeparate, a, ok)
+	}
+	if a, ok := gamepaddb.TriggerAxis(idCombined, gamepaddb.StandardButtonFrontBottomLeft); ok {
+		t.Errorf("TriggerAxis(%q, StandardButtonFrontBottomLeft): got: (%d, %t), want: (_, false)", idCombined, a, ok)
+	}
+}
+
+func TestCRC(t *testing.T) {
+	// The same controller in the formats of SDL 2 and SDL 3.
+	// In SDL 3's format, the CRC16 of the name ("Wireless Controller") is in the crc field or in the bytes 2 and 3 of the GUID.
+	const (
+		sdl2       = "030000004c050000cc09000011810000,PS4 Controller,a:b0,b:b1,back:b8,dpdown:h0.4,dpleft:h0.8,dpright:h0.2,dpup:h0.1,guide:b10,leftshoulder:b4,leftstick:b11,lefttrigger:a2,leftx:a0,lefty:a1,rightshoulder:b5,rightstick:b12,righttrigger:a5,rightx:a3,righty:a4,start:b9,x:b3,y:b2,"
+		sdl3       = "030000004c050000cc09000011810000,Wireless Controller,a:b0,b:b1,back:b8,dpdown:h0.4,dpleft:h0.8,dpright:h0.2,dpup:h0.1,guide:b10,leftshoulder:b4,leftstick:b11,lefttrigger:a2,leftx:a0,lefty:a1,rightshoulder:b5,rightstick:b12,righttrigger:a5,rightx:a3,righty:a4,start:b9,x:b3,y:b2,crc:519b,"
+		sdl3InGUID = "03009b514c050000cc09000011810000,Wireless Controller in GUID,a:b0,"
+
+		idWithoutCRC  = "030000004c050000cc09000011810000"
+		idWithCRC     = "03009b514c050000cc09000011810000"
+		idWithUnknown = "030034124c050000cc09000011810000"
+	)
+
+	if _, err := gamepaddb.Update(strings.NewReader(sdl2 + "\n" + sdl3 + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ID   string
+		Name string
+	}{
+		{ID: idWithCRC, Name: "Wireless Controller"},
+		{ID: idWithoutCRC, Name: "PS4 Controller"},
+		{ID: idWithUnknown, Name: "PS4 Controller"},
+	}
+	for _, c := range cases {
+		if got := gamepaddb.Name(c.ID); got != c.Name {
+			t.Errorf("Name(%q): got: %q, want: %q", c.ID, got, c.Name)
+		}
+		if !gamepaddb.HasStandardLayoutMapping(c.ID) {
+			t.Errorf("HasStandardLayoutMapping(%q) should be true", c.ID)
+		}
+	}
+
+	// A CRC in the GUID is the same as the crc field. The entry overrides the one with the same CRC.
+	if _, err := gamepaddb.Update(strings.NewReader(sdl3InGUID + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gamepaddb.Name(idWithCRC), "Wireless Controller in GUID"; got != want {
+		t.Errorf("Name(%q): got: %q, want: %q", idWithCRC, got, want)
+	}
+	if got, want := gamepaddb.Name(idWithoutCRC), "PS4 Controller"; got != want {
+		t.Errorf("Name(%q): got: %q, want: %q", idWithoutCRC, got, want)
+	}
+}
+
+type testGamepadState struct {
+	axes    map[int]float64
+	buttons map[int]bool
+	hats    map[int]int
+}
+
+func (s *testGamepadState) Axis(index int) float64 {
+	return s.axes[index]
+}
+
+func (s *testGamepadState) Button(index int) bool {
+	return s.buttons[index]
+}
+
+func (s *testGamepadState) Hat(index int) int {
+	return s.hats[index]
+}
+
+func TestAxisInversion(t *testing.T) {
+	const id = "03000000000000000000000000000004"
+
+	cases := []struct {
+		Mapping string
+		Input   float64
+		Axis    float64
+		Button  float64
+	}{
+		{Mapping: "a1", Input: 0.5, Axis: 0.5, Button: 0.75},
+		{Mapping: "a1~", Input: 0.5, Axis: -0.5, Button: 0.25},
+		{Mapping: "~a1", Input: 0.5, Axis: -0.5, Button: 0.25},
+		{Mapping: "+a1", Input: 0.5, Axis: 0, Button: 0.5},
+		{Mapping: "+a1", Input: 1, Axis: 1, Button: 1},
+		{Mapping: "+a1", Input: -0.5, Axis: -1, Button: 0},
+		{Mapping: "+a1~", Input: 1, Axis: -1, Button: 0},
+		{Mapping: "~+a1", Input: 1, Axis: -1, Button: 0},
+		{Mapping: "+a1~", Input: 0, Axis: 1, Button: 1},
+		{Mapping: "-a1", Input: -1, Axis: 1, Button: 1},
+		{Mapping: "-a1", Input: 0, Axis: -1, Button: 0},
+		{Mapping: "-a1~", Input: -1, Axis: -1, Button: 0},
+		{Mapping: "~-a1", Input: 0, Axis: 1, Button: 1},
+	}
+	for _, c := range cases {
+		if _, err := gamepaddb.Update(strings.NewReader(id + ",Test,lefty:" + c.Mapping + ",lefttrigger:" + c.Mapping + ",\n")); err != nil {
+			t.Fatal(err)
+		}
+		state := &testGamepadState{
+			axes: map[int]float64{1: c.Input},
+		}
+		if got := gamepaddb.AxisValue(id, gamepaddb.StandardAxisLeftStickVertical, state); got != c.Axis {
+			t.Errorf("%s with %v: axis: got: %v, want: %v", c.Mapping, c.Input, got, c.Axis)
+		}
+		if got := gamepaddb.ButtonValue(id, gamepaddb.StandardButtonFrontBottomLeft, state); got != c.Button {
+			t.Errorf("%s with %v: button: got: %v, want: %v", c.Mapping, c.Input, got, c.Button)
+		}
+	}
+
+	for _, m := range []string{"~b1", "h0.1~", "~"} {
+		if _, err := gamepaddb.Update(strings.NewReader(id + ",Test,lefty:" + m + ",\n")); err == nil {
+			t.Errorf("%s: Update should return an error but not", m)
+		}
+	}
+}
+
+func TestHalfAxes(t *testing.T) {
+	// The lines are taken from gamecontrollerdb.txt. The platform fields are removed so that the lines are used on any platform.
+	const (
+		n64     = "03000000c82d00000290000000000000,8BitDo N64,+rightx:b9,+righty:b3,-rightx:b4,-righty:b8,a:b0,b:b1,dpdown:h0.4,dpleft:h0.8,dpright:h0.2,dpup:h0.1,leftshoulder:b6,lefttrigger:b10,leftx:a0,lefty:a1,rightshoulder:b7,start:b11,platform:Windows,"
+		gravis  = "030000007d0400000840000000000000,Gravis Destroyer Tilt,+leftx:h0.2,+lefty:h0.4,-leftx:h0.8,-lefty:h0.1,a:b1,b:b2,dpdown:+a1,dpleft:-a0,dpright:+a0,dpup:-a1,leftshoulder:b4,rightshoulder:b5,x:b0,y:b3,platform:Windows,"
+		ipega   = "03000000491900000304000000000000,Ipega PG9087,+righty:+a5,-righty:-a4,a:b0,b:b1,back:b10,dpdown:h0.4,dpleft:h0.8,dpright:h0.2,dpup:h0.1,leftshoulder:b6,leftstick:b13,lefttrigger:b8,leftx:a0,lefty:a1,rightshoulder:b7,rightstick:b14,righttrigger:b9,rightx:a3,start:b11,x:b3,y:b4,platform:Windows,"
+		cyborg  = "03000000a306000022f6000000000000,Cyborg V.3 Rumble,a:b1,b:b2,back:b8,dpdown:h0.4,dpleft:h0.8,dpright:h0.2,dpup:h0.1,leftshoulder:b4,leftstick:b10,lefttrigger:+a3,leftx:a0,lefty:a1,rightshoulder:b5,rightstick:b11,righttrigger:-a3,rightx:a2,righty:a4,start:b9,x:b0,y:b3,platform:Windows,"
+		idN64   = "03000000c82d00000290000000000000"
+		idGrav  = "030000007d0400000840000000000000"
+		idIpega = "03000000491900000304000000000000"
+		idCyb   = "03000000a306000022f6000000000000"
+	)
+	var lines []string
+	for _, l := range []string{n64, gravis, ipega, cyborg} {
+		lines = append(lines, strings.Replace(l, "platform:Windows,", "", 1))
+	}
+	if _, err := gamepaddb.Update(strings.NewReader(strings.Join(lines, "\n"))); err != nil {
+		t.Fatal(err)
+	}
+
+	axisCases := []struct {
+		ID    string
+		Axis  gamepaddb.StandardAxis
+		State testGamepadState
+		Want  float64
+	}{
+		{ID: idN64, Axis: gamepaddb.StandardAxisRightStickHorizontal, State: testGamepadState{}, Want: 0},
+		{ID: idN64, Axis: gamepaddb.StandardAxisRightStickHorizontal, State: testGamepadState{buttons: map[int]bool{9: true}}, Want: 1},
+		{ID: idN64, Axis: gamepaddb.StandardAxisRightStickHorizontal, State: testGamepadState{buttons: map[int]bool{4: true}}, Want: -1},
+		{ID: idN64, Axis: gamepaddb.StandardAxisRightStickVertical, State: testGamepadState{buttons: map[int]bool{8: true}}, Want: -1},
+		{ID: idGrav, Axis: gamepaddb.StandardAxisLeftStickHorizontal, State: testGamepadState{hats: map[int]int{0: gamepaddb.HatRight}}, Want: 1},
+		{ID: idGrav, Axis: gamepaddb.StandardAxisLeftStickVertical, State: testGamepadState{hats: map[int]int{0: gamepaddb.HatUp}}, Want: -1},
+		{ID: idGrav, Axis: gamepaddb.StandardAxisLeftStickVertical, State: testGamepadState{hats: map[int]int{0: gamepaddb.HatLeft}}, Want: 0},
+		{ID: idIpega, Axis: gamepaddb.StandardAxisRightStickVertical, State: testGamepadState{}, Want: 0},
+		{ID: idIpega, Axis: gamepaddb.StandardAxisRightStickVertical, State: testGamepadState{axes: map[int]float64{5: 1}}, Want: 1},
+		{ID: idIpega, Axis: gamepaddb.StandardAxisRightStickVertical, State: testGamepadState{axes: map[int]float64{5: 0.5}}, Want: 0.5},
+		{ID: idIpega, Axis: gamepaddb.StandardAxisRightStickVertical, State: testGamepadState{axes: map[int]float64{4: -1}}, Want: -1},
+		{ID: idIpega, Axis: gamepaddb.StandardAxisRightStickVertical, State: testGamepadState{axes: map[int]float64{4: 1}}, Want: 0},
+	}
+	for _, c := range axisCases {
+		c := c
+		if !gamepaddb.HasStandardAxis(c.ID, c.Axis) {
+			t.Errorf("HasStandardAxis(%q, %d) should be true", c.ID, c.Axis)
+		}
+		if got := gamepaddb.AxisValue(c.ID, c.Axis, &c.State); got != c.Want {
+			t.Errorf("AxisValue(%q, %d) with %v: got: %v, want: %v", c.ID, c.Axis, c.State, got, c.Want)
+		}
+	}
+
+	buttonCases := []struct {
+		ID     string
+		Button gamepaddb.StandardButton
+		State  testGamepadState
+		Want   float64
+	}{
+		{ID: idGrav, Button: gamepaddb.StandardButtonLeftBottom, State: testGamepadState{}, Want: 0},
+		{ID: idGrav, Button: gamepaddb.StandardButtonLeftBottom, State: testGamepadState{axes: map[int]float64{1: 1}}, Want: 1},
+		{ID: idGrav, Button: gamepaddb.StandardButtonLeftTop, State: testGamepadState{axes: map[int]float64{1: 1}}, Want: 0},
+		{ID: idGrav, Button: gamepaddb.StandardButtonLeftTop, State: testGamepadState{axes: map[int]float64{1: -1}}, Want: 1},
+		{ID: idCyb, Button: gamepaddb.StandardButtonFrontBottomLeft, State: testGamepadState{axes: map[int]float64{3: 0.5}}, Want: 0.5},
+		{ID: idCyb, Button: gamepaddb.StandardButtonFrontBottomRight, State: testGamepadState{axes: map[int]float64{3: 0.5}}, Want: 0},
+		{ID: idCyb, Button: gamepaddb.StandardButtonFrontBottomRight, State: testGamepadState{axes: map[int]float64{3: -0.5}}, Want: 0.5},
+	}
+	for _, c := range buttonCases {
+		c := c
+		if got := gamepaddb.ButtonValue(c.ID, c.Button, &c.State); got != c.Want {
+			t.Errorf("ButtonValue(%q, %d) with %v: got: %v, want: %v", c.ID, c.Button, c.State, got, c.Want)
+		}
+	}
+}
+
+// platformFields returns the platform field for the current platform, and the one for another platform.
+func platformFields() (current, other string) {
+	switch runtime.GOOS {
+	case "windows":
+		return "platform:Windows", "platform:Linux"
+	case "darwin":
+		return "platform:Mac OS X", "platform:Windows"
+	case "android":
+		return "platform:Android", "platform:Linux"
+	case "ios":
+		return "platform:iOS", "platform:Mac OS X"
+	case "linux", "freebsd", "netbsd", "openbsd":
+		return "platform:Linux", "platform:Windows"
+	}
+	return "", "platform:Windows"
+}
+
+func TestPlatform(t *testing.T) {
+	const id = "03000000000000000000000000000005"
+
+	current, other := platformFields()
+	if current == "" {
+		t.Skipf("no platform field for %s", runtime.GOOS)
+	}
+
+	// An entry for the current platform is preferred regardless of the order.
+	if _, err := gamepaddb.Update(strings.NewReader(id + ",Specific,a:b0," + current + ",\n" +
+		id + ",Generic,a:b1,\n" +
+		id + ",Other,a:b2," + other + ",\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gamepaddb.Name(id), "Specific"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	state := &testGamepadState{buttons: map[int]bool{0: true}}
+	if !gamepaddb.IsButtonPressed(id, gamepaddb.StandardButtonRightBottom, state) {
+		t.Errorf("the mapping for the current platform should be used")
+	}
+
+	// An entry for any platforms doesn't override an entry for the current platform.
+	if _, err := gamepaddb.Update(strings.NewReader(id + ",Generic 2,a:b1,\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gamepaddb.Name(id), "Specific"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	// An entry for another platform is never used.
+	const id2 = "03000000000000000000000000000006"
+	if _, err := gamepaddb.Update(strings.NewReader(id2 + ",Other,a:b2," + other + ",\n")); err != nil {
+		t.Fatal(err)
+	}
+	if gamepaddb.HasStandardLayoutMapping(id2) {
+		t.Errorf("HasStandardLayoutMapping(%q) should be false", id2)
+	}
+}
+
+func TestMappingStringRoundTrip(t *testing.T) {
+	const id = "03000000000000000000000000000007"
+
+	// The result of MappingString has the platform field for the current platform.
+	platform, _ := platformFields()
+	if platform == "" {
+		t.Skipf("no platform field for %s", runtime.GOOS)
+	}
+
+	mappings := []string{
+		"a:b0,b:b1,dpdown:h0.4,dpleft:h0.8,dpright:h0.2,dpup:h0.1,lefttrigger:a2,leftx:a0,lefty:a1~,righttrigger:a5,",
+		"+rightx:b9,+righty:b3,-rightx:b4,-righty:b8,a:b0,dpdown:+a1,dpup:-a1,lefttrigger:+a2~,righttrigger:-a2~,",
+		"+leftx:h0.2,-leftx:h0.8,a:b0,dpdown:h1.4,dpleft:h1.8,dpright:h1.2,dpup:h1.1,",
+	}
+	states := []testGamepadState{
+		{},
+		{axes: map[int]float64{0: 0.25, 1: -0.75, 2: 0.5, 5: -0.5}, buttons: map[int]bool{0: true, 4: true}, hats: map[int]int{0: gamepaddb.HatUp}},
+		{axes: map[int]float64{0: -1, 1: 1, 2: -0.5, 5: 1}, buttons: map[int]bool{3: true, 9: true}, hats: map[int]int{0: gamepaddb.HatRight | gamepaddb.HatDown, 1: gamepaddb.HatLeft}},
+	}
+
+	type result struct {
+		axes    [gamepaddb.StandardAxisMax + 1]float64
+		buttons [gamepaddb.StandardButtonMax + 1]float64
+		pressed [gamepaddb.StandardButtonMax + 1]bool
+	}
+	evaluate := func(state *testGamepadState) result {
+		var r result
+		for a := gamepaddb.StandardAxis(0); a <= gamepaddb.StandardAxisMax; a++ {
+			r.axes[a] = gamepaddb.AxisValue(id, a, state)
+		}
+		for b := gamepaddb.StandardButton(0); b <= gamepaddb.StandardButtonMax; b++ {
+			r.buttons[b] = gamepaddb.ButtonValue(id, b, state)
+			r.pressed[b] = gamepaddb.IsButtonPressed(id, b, state)
+		}
+		return r
+	}
+
+	for _, m := range mappings {
+		line := id + ",Round Trip," + m + platform + ","
+		if _, err := gamepaddb.Update(strings.NewReader(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		var want []result
+		for i := range states {
+			want = append(want, evaluate(&states[i]))
+		}
+
+		str := gamepaddb.MappingString(id)
+		if str != line {
+			t.Errorf("MappingString(%q): got: %q, want: %q", id, str, line)
+		}
+		// Reset the mapping to make sure the result is actually used.
+		if _, err := gamepaddb.Update(strings.NewReader(id + ",Reset,a:b15," + platform + ",\n")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gamepaddb.Update(strings.NewReader(str)); err != nil {
+			t.Fatal(err)
+		}
+		if got := gamepaddb.MappingString(id); got != str {
+			t.Errorf("MappingString(%q) after a round trip: got: %q, want: %q", id, got, str)
+		}
+		for i := range states {
+			if got := evaluate(&states[i]); got != want[i] {
+				t.Errorf("%s with %v: got: %v, want: %v", m, states[i], got, want[i])
+			}
+		}
+	}
+}
+
+func TestEnvironmentMappings(t *testing.T) {
+	const (
+		id1 = "03000000000000000000000000000007"
+		id2 = "03000000000000000000000000000008"
+		id3 = "03000000000000000000000000000017"
+		id4 = "03000000000000000000000000000018"
+	)
+
+	path := filepath.Join(t.TempDir(), "gamecontrollerdb.txt")
+	if err := os.WriteFile(path, []byte(id1+",From File,a:b0,\ninvalid file line\n"+id2+",From File,a:b0,\n"+id3+",From File,a:b0,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SDL_GAMECONTROLLERCONFIG_FILE", path)
+	t.Setenv("SDL_GAMECONTROLLERCONFIG", id2+",From Variable,a:b1,\ninvalid line\n"+id4+",From Variable,a:b1,\n"+id3+",From Variable,a:zz,\n")
+
+	n := len(gamepaddb.AppendMappingErrors(nil))
+	if n+3 > gamepaddb.MaxMappingErrors {
+		t.Fatalf("too many mapping errors are recorded before the test: %d", n)
+	}
+	gamepaddb.LoadEnvironmentMappings()
+
+	// The valid lines are applied even though there are invalid lines, as SDL does.
+	for _, tc := range []struct {
+		id   string
+		want string
+	}{
+		{id: id1, want: "From File"},
+		{id: id2, want: "From Variable"},
+		{id: id3, want: "From File"},
+		{id: id4, want: "From Variable"},
+	} {
+		if got := gamepaddb.Name(tc.id); got != tc.want {
+			t.Errorf("Name(%q): got: %q, want: %q", tc.id, got, tc.want)
+		}
+	}
+
+	// Each invalid line is reported.
+	errs := gamepaddb.AppendMappingErrors(nil)
+	if got, want := len(errs), n+3; got != want {
+		t.Fatalf("len(errs): got: %d, want: %d", got, want)
+	}
+	for i, want := range []string{"SDL_GAMECONTROLLERCONFIG_FILE", "SDL_GAMECONTROLLERCONFIG", "SDL_GAMECONTROLLERCONFIG"} {
+		if got := errs[n+i].Source; got != want {
+			t.Errorf("errs[%d].Source: got: %q, want: %q", n+i, got, want)
+		}
+	}
+
+	// The environment mappings are preferred to mappings given later by Update.
+	if _, err := gamepaddb.Update(strings.NewReader(id1 + ",From Update,a:b1,\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gamepaddb.Name(id1), "From File"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	// A missing file is reported.
+	t.Setenv("SDL_GAMECONTROLLERCONFIG_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	t.Setenv("SDL_GAMECONTROLLERCONFIG", "")
+	gamepaddb.LoadEnvironmentMappings()
+	errs = gamepaddb.AppendMappingErrors(nil)
+	if got, want := errs[len(errs)-1].Source, "SDL_GAMECONTROLLERCONFIG_FILE"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	const (
+		id1 = "03000000000000000000000000000009"
+		id2 = "0300000000000000000000000000000a"
+	)
+
+	path := filepath.Join(t.TempDir(), "mappings.txt")
+	if err := os.WriteFile(path, []byte(id1+",Version 1,a:b0,\n"+id2+",Version 1,a:b0,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamepaddb.WatchFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gamepaddb.Name(id1), "Version 1"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	// The mappings from the watched file are preferred to the mappings given by Update.
+	if _, err := gamepaddb.Update(strings.NewReader(id1 + ",From Update,a:b1,\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gamepaddb.Name(id1), "Version 1"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	// The mappings are replaced. A mapping removed from the file is removed.
+	if err := os.WriteFile(path, []byte(id1+",Version 2,a:b0,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the modification is detected even when the file system's time resolution is coarse.
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	gamepaddb.ResetWatchTime()
+	gamepaddb.UpdateWatchedFiles()
+	if got, want := gamepaddb.Name(id1), "Version 2"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	if gamepaddb.HasStandardLayoutMapping(id2) {
+		t.Errorf("HasStandardLayoutMapping(%q) should be false", id2)
+	}
+
+	// An invalid file keeps the last valid mappings.
+	n := len(gamepaddb.AppendMappingErrors(nil))
+	if err := os.WriteFile(path, []byte("invalid line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	gamepaddb.ResetWatchTime()
+	gamepaddb.UpdateWatchedFiles()
+	if got, want := gamepaddb.Name(id1), "Version 2"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	errs := gamepaddb.AppendMappingErrors(nil)
+	if got, want := len(errs), n+1; got != want {
+		t.Fatalf("len(errs): got: %d, want: %d", got, want)
+	}
+	if got, want := errs[len(errs)-1].Source, path; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+type testAnalogGamepadState struct {
+	testGamepadState
+	buttonValues map[int]float64
+}
+
+func (s *testAnalogGamepadState) ButtonValue(index int) float64 {
+	return s.buttonValues[index]
+}
+
+func TestButtonValue(t *testing.T) {
+	const id = "0300000000000000000000000000000b"
+	if _, err := gamepaddb.Update(strings.NewReader(id + ",Racing Gamepad,a:b0,lefttrigger:b6,righttrigger:a5,x:-a2,y:+a2~,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Name   string
+		Button gamepaddb.StandardButton
+		State  gamepaddb.GamepadState
+		Value  float64
+	}{
+		{
+			Name:   "digital button",
+			Button: gamepaddb.StandardButtonRightBottom,
+			State:  &testGamepadState{buttons: map[int]bool{0: true}},
+			Value:  1,
+		},
+		{
+			Name:   "analog button",
+			Button: gamepaddb.StandardButtonFrontBottomLeft,
+			State: &testAnalogGamepadState{
+				testGamepadState: testGamepadState{buttons: map[int]bool{6: true}},
+				buttonValues:     map[int]float64{6: 0.25},
+			},
+			Value: 0.25,
+		},
+		{
+			Name:   "analog button without analog values",
+			Button: gamepaddb.StandardButtonFrontBottomLeft,
+			State:  &testGamepadState{buttons: map[int]bool{6: true}},
+			Value:  1,
+		},
+		{
+			Name:   "axis",
+			Button: gamepaddb.StandardButtonFrontBottomRight,
+			State:  &testGamepadState{axes: map[int]float64{5: 0}},
+			Value:  0.5,
+		},
+		{
+			Name:   "negative half axis",
+			Button: gamepaddb.StandardButtonRightLeft,
+			State:  &testGamepadState{axes: map[int]float64{2: -0.5}},
+			Value:  0.5,
+		},
+		{
+			Name:   "inverted positive half axis",
+			Button: gamepaddb.StandardButtonRightTop,
+			State:  &testGamepadState{axes: map[int]float64{2: 0.25}},
+			Value:  0.75,
+		},
+	}
+	for _, c := range cases {
+		if got, want := gamepaddb.ButtonValue(id, c.Button, c.State), c.Value; got != want {
+			t.Errorf("%s: got: %f, want: %f", c.Name, got, want)
+		}
+	}
+}
+
+func TestHats(t *testing.T) {
+	const id = "0300000000000000000000000000000c"
+	if _, err := gamepaddb.Update(strings.NewReader(id + ",Flight Stick,a:h1.1,b:h2.8,x:h0.3,y:h0.12,dpup:h0.1,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Name    string
+		Hats    map[int]int
+		Pressed []gamepaddb.StandardButton
+	}{
+		{
+			Name: "released",
+		},
+		{
+			Name:    "second hat",
+			Hats:    map[int]int{1: gamepaddb.HatUp},
+			Pressed: []gamepaddb.StandardButton{gamepaddb.StandardButtonRightBottom},
+		},
+		{
+			Name:    "third hat",
+			Hats:    map[int]int{2: gamepaddb.HatLeft},
+			Pressed: []gamepaddb.StandardButton{gamepaddb.StandardButtonRightRight},
+		},
+		{
+			Name:    "another hat's direction",
+			Hats:    map[int]int{0: gamepaddb.HatUp, 2: gamepaddb.HatUp},
+			Pressed: []gamepaddb.StandardButton{gamepaddb.StandardButtonRightLeft, gamepaddb.StandardButtonLeftTop},
+		},
+		{
+			Name:    "diagonal",
+			Hats:    map[int]int{0: gamepaddb.HatUp | gamepaddb.HatRight},
+			Pressed: []gamepaddb.StandardButton{gamepaddb.StandardButtonRightLeft, gamepaddb.StandardButtonLeftTop},
+		},
+		{
+			Name:    "another diagonal",
+			Hats:    map[int]int{0: gamepaddb.HatDown | gamepaddb.HatLeft},
+			Pressed: []gamepaddb.StandardButton{gamepaddb.StandardButtonRightTop},
+		},
+		{
+			// As SDL does, a combined mask is pressed by any of the directions.
+			Name:    "a part of a diagonal",
+			Hats:    map[int]int{0: gamepaddb.HatDown},
+			Pressed: []gamepaddb.StandardButton{gamepaddb.StandardButtonRightTop},
+		},
+	}
+	buttons := []gamepaddb.StandardButton{
+		gamepaddb.StandardButtonRightBottom,
+		gamepaddb.StandardButtonRightRight,
+		gamepaddb.StandardButtonRightLeft,
+		gamepaddb.StandardButtonRightTop,
+		gamepaddb.StandardButtonLeftTop,
+	}
+	for _, c := range cases {
+		state := &testGamepadState{hats: c.Hats}
+		for _, b := range buttons {
+			var want bool
+			for _, p := range c.Pressed {
+				if p == b {
+					want = true
+					break
+				}
+			}
+			if got := gamepaddb.IsButtonPressed(id, b, state); got != want {
+				t.Errorf("%s: IsButtonPressed(%d): got: %t, want: %t", c.Name, b, got, want)
+			}
+		}
+	}
+
+	for _, mapping := range []string{"h0", "h0.", "h0.0", "h0.16", "h-1.1", "h0.1.2"} {
+		if _, err := gamepaddb.Update(strings.NewReader(id + ",Invalid Hat,a:" + mapping + ",\n")); err == nil {
+			t.Errorf("%s: Update must return an error", mapping)
+		}
+	}
+}
+
+func TestVersionlessGUID(t *testing.T) {
+	// DualShock 4 with an unusual bus type so that the embedded database doesn't interfere.
+	if _, err := gamepaddb.Update(strings.NewReader("060000004c050000cc09000000000000,Versionless DS4,a:b1,\n" +
+		"060000004c050000cc09000011010000,Exact DS4,a:b1,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ID   string
+		Name string
+	}{
+		{
+			ID:   "060000004c050000cc09000011010000",
+			Name: "Exact DS4",
+		},
+		{
+			// The version 0x0203 is not in the database.
+			ID:   "060000004c050000cc09000003020000",
+			Name: "Versionless DS4",
+		},
+		{
+			// The CRC of "Wireless Controller" with the version 0x0203.
+			ID:   "06009b514c050000cc09000003020000",
+			Name: "Versionless DS4",
+		},
+		{
+			// The versionless entry is not used for another product.
+			ID:   "060000004c050000c405000003020000",
+			Name: "",
+		},
+	}
+	for _, c := range cases {
+		if got, want := gamepaddb.Name(c.ID), c.Name; got != want {
+			t.Errorf("Name(%q): got: %q, want: %q", c.ID, got, want)
+		}
+	}
+}
+
+func TestEmbeddedDatabase(t *testing.T) {
+	if err := gamepaddb.CheckEmbeddedDatabase(); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkIndexEmbeddedDatabase(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		gamepaddb.IndexEmbeddedDatabase()
+	}
+}
+
+func BenchmarkParseEmbeddedDatabase(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := gamepaddb.ParseEmbeddedDatabase(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAxisToButtonHysteresis(t *testing.T) {
+	const id = "0300000000000000000000000000000d"
+	if _, err := gamepaddb.Update(strings.NewReader(id + ",Axis D-pad,dpdown:+a7,dpup:-a7,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The axis moves around the threshold 0.5. Without the hysteresis, the button would toggle at every step.
+	cases := []struct {
+		Axis    float64
+		Pressed bool
+	}{
+		{Axis: 0.4, Pressed: false},
+		{Axis: 0.52, Pressed: true},
+		{Axis: 0.48, Pressed: true},
+		{Axis: 0.51, Pressed: true},
+		{Axis: 0.47, Pressed: true},
+		{Axis: 0.44, Pressed: false},
+		{Axis: 0.48, Pressed: false},
+		{Axis: 0.49, Pressed: false},
+		{Axis: 0.52, Pressed: true},
+		{Axis: 0, Pressed: false},
+	}
+	var pressed bool
+	for i, c := range cases {
+		state := &testGamepadState{axes: map[int]float64{7: c.Axis}}
+		pressed = gamepaddb.IsButtonPressedWithHysteresis(id, gamepaddb.StandardButtonLeftBottom, state, pressed)
+		if pressed != c.Pressed {
+			t.Errorf("step %d (axis: %f): got: %t, want: %t", i, c.Axis, pressed, c.Pressed)
+		}
+		if gamepaddb.IsButtonPressedWithHysteresis(id, gamepaddb.StandardButtonLeftTop, state, false) {
+			t.Errorf("step %d (axis: %f): the opposite direction must not be pressed", i, c.Axis)
+		}
+	}
+
+	// Without the last state, the threshold is simply 0.5.
+	for _, c := range []struct {
+		Axis    float64
+		Pressed bool
+	}{
+		{Axis: 0.48, Pressed: false},
+		{Axis: 0.52, Pressed: true},
+		{Axis: -0.52, Pressed: false},
+	} {
+		state := &testGamepadState{axes: map[int]float64{7: c.Axis}}
+		if got, want := gamepaddb.IsButtonPressed(id, gamepaddb.StandardButtonLeftBottom, state), c.Pressed; got != want {
+			t.Errorf("axis: %f: got: %t, want: %t", c.Axis, got, want)
+		}
+	}
+	state := &testGamepadState{axes: map[int]float64{7: -0.52}}
+	if !gamepaddb.IsButtonPressed(id, gamepaddb.StandardButtonLeftTop, state) {
+		t.Errorf("the negative half must be pressed")
+	}
+}
+
+func TestButtonToAxis(t *testing.T) {
+	const (
+		id1 = "0300000000000000000000000000000e"
+		id2 = "0300000000000000000000000000000f"
+	)
+	if _, err := gamepaddb.Update(strings.NewReader(id1 + ",Button Stick,leftx:b3,lefty:h0.4,\n" +
+		id2 + ",Two Button Stick,-leftx:b2,+leftx:b3,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ID      string
+		Axis    gamepaddb.StandardAxis
+		Buttons map[int]bool
+		Hats    map[int]int
+		Value   float64
+	}{
+		{ID: id1, Axis: gamepaddb.StandardAxisLeftStickHorizontal, Value: 0},
+		{ID: id1, Axis: gamepaddb.StandardAxisLeftStickHorizontal, Buttons: map[int]bool{3: true}, Value: 1},
+		{ID: id1, Axis: gamepaddb.StandardAxisLeftStickVertical, Value: 0},
+		{ID: id1, Axis: gamepaddb.StandardAxisLeftStickVertical, Hats: map[int]int{0: gamepaddb.HatDown}, Value: 1},
+		{ID: id2, Axis: gamepaddb.StandardAxisLeftStickHorizontal, Value: 0},
+		{ID: id2, Axis: gamepaddb.StandardAxisLeftStickHorizontal, Buttons: map[int]bool{2: true}, Value: -1},
+		{ID: id2, Axis: gamepaddb.StandardAxisLeftStickHorizontal, Buttons: map[int]bool{3: true}, Value: 1},
+		{ID: id2, Axis: gamepaddb.StandardAxisLeftStickHorizontal, Buttons: map[int]bool{2: true, 3: true}, Value: 0},
+	}
+	for i, c := range cases {
+		state := &testGamepadState{buttons: c.Buttons, hats: c.Hats}
+		if got, want := gamepaddb.AxisValue(c.ID, c.Axis, state), c.Value; got != want {
+			t.Errorf("case %d: got: %f, want: %f", i, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	const guid = "03000000000000000000000000000010"
+
+	type expectedError struct {
+		Line   int
+		Field  string
+		Reason gamepaddb.ValidationReason
+	}
+	cases := []struct {
+		Name   string
+		Input  string
+		Errors []expectedError
+	}{
+		{
+			Name:  "valid",
+			Input: "# comment\n\n" + guid + ",Valid,a:b0,-leftx:a0,+leftx:a1,lefty:a1~,dpup:h0.1,misc1:b15,crc:1234,platform:Linux,\n",
+		},
+		{
+			Name:  "unknown key",
+			Input: guid + ",Typo,a:b0,lefttriger:a2,\n",
+			Errors: []expectedError{
+				{Line: 1, Field: "lefttriger:a2", Reason: gamepaddb.ValidationReasonUnknownKey},
+			},
+		},
+		{
+			Name:  "half of a button",
+			Input: guid + ",Half Button,+a:b0,\n",
+			Errors: []expectedError{
+				{Line: 1, Field: "+a:b0", Reason: gamepaddb.ValidationReasonUnknownKey},
+			},
+		},
+		{
+			Name:  "invalid values",
+			Input: "\n" + guid + ",Invalid,a:c0,b:h0,x:,platform:Amiga,crc:xyz,\n",
+			Errors: []expectedError{
+				{Line: 2, Field: "a:c0", Reason: gamepaddb.ValidationReasonInvalidValue},
+				{Line: 2, Field: "b:h0", Reason: gamepaddb.ValidationReasonInvalidValue},
+				{Line: 2, Field: "x:", Reason: gamepaddb.ValidationReasonInvalidValue},
+				{Line: 2, Field: "platform:Amiga", Reason: gamepaddb.ValidationReasonInvalidValue},
+				{Line: 2, Field: "crc:xyz", Reason: gamepaddb.ValidationReasonInvalidValue},
+			},
+		},
+		{
+			Name:  "duplicates",
+			Input: guid + ",Duplicate,a:b0,a:b1,leftx:a0,+leftx:a0,+lefty:a1,+lefty:a2,-lefty:a3,\n",
+			Errors: []expectedError{
+				{Line: 1, Field: "a:b1", Reason: gamepaddb.ValidationReasonDuplicate},
+				{Line: 1, Field: "+leftx:a0", Reason: gamepaddb.ValidationReasonDuplicate},
+				{Line: 1, Field: "+lefty:a2", Reason: gamepaddb.ValidationReasonDuplicate},
+			},
+		},
+		{
+			Name:  "out of range",
+			Input: guid + ",Out of Range,a:b768,leftx:a64,dpup:h16.1,b:b767,\n",
+			Errors: []expectedError{
+				{Line: 1, Field: "a:b768", Reason: gamepaddb.ValidationReasonOutOfRange},
+				{Line: 1, Field: "leftx:a64", Reason: gamepaddb.ValidationReasonOutOfRange},
+				{Line: 1, Field: "dpup:h16.1", Reason: gamepaddb.ValidationReasonOutOfRange},
+			},
+		},
+		{
+			Name:  "syntax",
+			Input: "invalid line\n" + guid + ",Syntax,a,\n",
+			Errors: []expectedError{
+				{Line: 1, Field: "invalid line", Reason: gamepaddb.ValidationReasonSyntax},
+				{Line: 2, Field: "a", Reason: gamepaddb.ValidationReasonSyntax},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			err := gamepaddb.Validate(strings.NewReader(c.Input))
+			if len(c.Errors) == 0 {
+				if err != nil {
+					t.Errorf("Validate must not return an error: %v", err)
+				}
+				return
+			}
+			errs, ok := err.(gamepaddb.ValidationErrors)
+			if !ok {
+				t.Fatalf("Validate must return ValidationErrors: %v", err)
+			}
+			if got, want := len(errs), len(c.Errors); got != want {
+				t.Fatalf("len(errs): got: %d, want: %d: %v", got, want, errs)
+			}
+			for i, e := range errs {
+				if got, want := (expectedError{Line: e.Line, Field: e.Field, Reason: e.Reason}), c.Errors[i]; got != want {
+					t.Errorf("errs[%d]: got: %v, want: %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateEmbeddedDatabase(t *testing.T) {
+	if err := gamepaddb.ValidateEmbeddedDatabase(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	const id = "03000000000000000000000000000011"
+	input := id + ",Typo,a:b0,lefttriger:a2,\n"
+
+	gamepaddb.SetStrict(true)
+	defer gamepaddb.SetStrict(false)
+
+	_, err := gamepaddb.Update(strings.NewReader(input))
+	if _, ok := err.(gamepaddb.ValidationErrors); !ok {
+		t.Fatalf("Update must return ValidationErrors in the strict mode: %v", err)
+	}
+	if gamepaddb.HasStandardLayoutMapping(id) {
+		t.Errorf("the mapping must be rejected in the strict mode")
+	}
+
+	gamepaddb.SetStrict(false)
+	if _, err := gamepaddb.Update(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if !gamepaddb.HasStandardLayoutMapping(id) {
+		t.Errorf("the mapping must be accepted without the strict mode")
+	}
+}
+
+func TestRemoveMapping(t *testing.T) {
+	const (
+		embeddedID = "030000005e0400008e02000010010000"
+		userID     = "03000000000000000000000000000012"
+	)
+
+	if got, want := gamepaddb.Name(embeddedID), "Xbox 360 Controller"; got != want {
+		t.Fatalf("got: %q, want: %q", got, want)
+	}
+	if _, err := gamepaddb.Update(strings.NewReader(embeddedID + ",Overridden,a:b0,\n" + userID + ",User,a:b0,\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gamepaddb.Name(embeddedID), "Overridden"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	// Removing a mapping restores the embedded mapping.
+	if !gamepaddb.RemoveMapping(embeddedID) {
+		t.Errorf("RemoveMapping(%q) should return true", embeddedID)
+	}
+	if got, want := gamepaddb.Name(embeddedID), "Xbox 360 Controller"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	// The embedded mapping is never removed.
+	if gamepaddb.RemoveMapping(embeddedID) {
+		t.Errorf("RemoveMapping(%q) should return false", embeddedID)
+	}
+	if !gamepaddb.HasStandardLayoutMapping(embeddedID) {
+		t.Errorf("HasStandardLayoutMapping(%q) should be true", embeddedID)
+	}
+
+	// The GUID is case-insensitive.
+	if !gamepaddb.RemoveMapping(strings.ToUpper(userID)) {
+		t.Errorf("RemoveMapping(%q) should return true", userID)
+	}
+	if gamepaddb.HasStandardLayoutMapping(userID) {
+		t.Errorf("HasStandardLayoutMapping(%q) should be false", userID)
+	}
+
+	if gamepaddb.RemoveMapping("invalid") {
+		t.Errorf("RemoveMapping should return false for an invalid GUID")
+	}
+}
+
+func TestResetMappingsToDefault(t *testing.T) {
+	const (
+		embeddedID = "030000005e0400008e02000014010000"
+		userID     = "03000000000000000000000000000013"
+		fileID     = "03000000000000000000000000000014"
+	)
+
+	path := filepath.Join(t.TempDir(), "mappings.txt")
+	if err := os.WriteFile(path, []byte(fileID+",From File,a:b0,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := gamepaddb.WatchFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gamepaddb.Update(strings.NewReader(embeddedID + ",Overridden,a:b0,\n" + userID + ",User,a:b0,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	gamepaddb.ResetMappingsToDefault()
+
+	if got, want := gamepaddb.Name(embeddedID), "Xbox 360 Controller"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	for _, id := range []string{userID, fileID} {
+		if gamepaddb.HasStandardLayoutMapping(id) {
+			t.Errorf("HasStandardLayoutMapping(%q) should be false", id)
+		}
+	}
+
+	// The file is no longer watched.
+	if err := os.WriteFile(path, []byte(fileID+",From File 2,a:b0,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	gamepaddb.ResetWatchTime()
+	gamepaddb.UpdateWatchedFiles()
+	if gamepaddb.HasStandardLayoutMapping(fileID) {
+		t.Errorf("HasStandardLayoutMapping(%q) should be false", fileID)
+	}
+}
+
+func TestAxisHintApply(t *testing.T) {
+	cases := []struct {
+		Hint  gamepaddb.AxisHint
+		Input float64
+		Want  float64
+	}{
+		{Hint: gamepaddb.AxisHint{}, Input: 0.5, Want: 0.5},
+		{Hint: gamepaddb.AxisHint{}, Input: -1, Want: -1},
+		{Hint: gamepaddb.AxisHint{Saturation: 0.8}, Input: 0.4, Want: 0.5},
+		{Hint: gamepaddb.AxisHint{Saturation: 0.8}, Input: -0.8, Want: -1},
+		{Hint: gamepaddb.AxisHint{Saturation: 0.8}, Input: 0.9, Want: 1},
+		{Hint: gamepaddb.AxisHint{DeadZone: 0.2}, Input: 0.2, Want: 0},
+		{Hint: gamepaddb.AxisHint{DeadZone: 0.2}, Input: -0.1, Want: 0},
+		{Hint: gamepaddb.AxisHint{DeadZone: 0.2}, Input: 0.6, Want: 0.5},
+		{Hint: gamepaddb.AxisHint{DeadZone: 0.2, Saturation: 0.6}, Input: -0.4, Want: -0.5},
+		{Hint: gamepaddb.AxisHint{DeadZone: 0.2, Saturation: 0.6}, Input: 0.7, Want: 1},
+	}
+	for _, c := range cases {
+		if got := c.Hint.Apply(c.Input); math.Abs(got-c.Want) > 1e-9 {
+			t.Errorf("%+v.Apply(%f): got: %f, want: %f", c.Hint, c.Input, got, c.Want)
+		}
+	}
+}
+
+func TestAxisHint(t *testing.T) {
+	// A Nintendo Switch Pro Controller, which has a built-in hint.
+	const id = "050000007e0500000920000001800000"
+
+	if _, err := gamepaddb.Update(strings.NewReader(id + ",Pro Controller,leftx:a0,lefty:a1,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &testGamepadState{
+		axes: map[int]float64{
+			0: 0.425,
+			1: -0.85,
+		},
+	}
+	if got, want := gamepaddb.AxisValue(id, gamepaddb.StandardAxisLeftStickHorizontal, state), 0.5; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got: %f, want: %f", got, want)
+	}
+	if got, want := gamepaddb.AxisValue(id, gamepaddb.StandardAxisLeftStickVertical, state), -1.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got: %f, want: %f", got, want)
+	}
+
+	// A hint given by SetAxisHint is preferred, and the other axes keep the built-in hints.
+	if err := gamepaddb.SetAxisHint(id, gamepaddb.StandardAxisLeftStickHorizontal, gamepaddb.AxisHint{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gamepaddb.AxisValue(id, gamepaddb.StandardAxisLeftStickHorizontal, state), 0.425; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got: %f, want: %f", got, want)
+	}
+	if got, want := gamepaddb.AxisValue(id, gamepaddb.StandardAxisLeftStickVertical, state), -1.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("got: %f, want: %f", got, want)
+	}
+
+	// Raw axis values are not affected.
+	if got, want := state.Axis(0), 0.425; got != want {
+		t.Errorf("got: %f, want: %f", got, want)
+	}
+
+	for _, h := range []gamepaddb.AxisHint{
+		{DeadZone: -0.1},
+		{DeadZone: 1},
+		{DeadZone: 0.5, Saturation: 0.5},
+		{Saturation: 1.1},
+	} {
+		if err := gamepaddb.SetAxisHint(id, gamepaddb.StandardAxisLeftStickHorizontal, h); err == nil {
+			t.Errorf("SetAxisHint with %+v must return an error", h)
+		}
+	}
+	if err := gamepaddb.SetAxisHint("invalid", gamepaddb.StandardAxisLeftStickHorizontal, gamepaddb.AxisHint{}); err == nil {
+		t.Errorf("SetAxisHint with an invalid GUID must return an error")
+	}
+}
+
+func TestExtendedButtons(t *testing.T) {
+	const (
+		idExtended = "03000000000000000000000000000015"
+		idStandard = "03000000000000000000000000000016"
+	)
+
+	if _, err := gamepaddb.Update(strings.NewReader(
+		idExtended + ",Extended,a:b0,misc1:b15,paddle1:b16,paddle2:b17,paddle3:b18,paddle4:b19,touchpad:b20,\n" +
+			idStandard + ",Standard,a:b0,\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	extended := []gamepaddb.StandardButton{
+		gamepaddb.StandardButtonMisc1,
+		gamepaddb.StandardButtonPaddle1,
+		gamepaddb.StandardButtonPaddle2,
+		gamepaddb.StandardButtonPaddle3,
+		gamepaddb.StandardButtonPaddle4,
+		gamepaddb.StandardButtonTouchpad,
+	}
+	for i, b := range extended {
+		if !gamepaddb.HasStandardButton(idExtended, b) {
+			t.Errorf("HasStandardButton(%q, %d) should be true", idExtended, b)
+		}
+		if gamepaddb.HasStandardButton(idStandard, b) {
+			t.Errorf("HasStandardButton(%q, %d) should be false", idStandard, b)
+		}
+
+		state := &testGamepadState{
+			buttons: map[int]bool{15 + i: true},
+		}
+		if !gamepaddb.IsButtonPressed(idExtended, b, state) {
+			t.Errorf("IsButtonPressed(%q, %d) should be true", idExtended, b)
+		}
+		if got, want := gamepaddb.ButtonValue(idExtended, b, state), 1.0; got != want {
+			t.Errorf("ButtonValue(%q, %d): got: %f, want: %f", idExtended, b, got, want)
+		}
+	}
+
+	// The extended buttons are appended so that the existing buttons keep their values.
+	if got, want := gamepaddb.StandardButtonMisc1, gamepaddb.StandardButtonCenterCenter+1; got != want {
+		t.Errorf("got: %d, want: %d", got, want)
+	}
+}
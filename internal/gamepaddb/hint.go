@@ -0,0 +1,185 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepaddb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// AxisHint is a hint about the range of a standard axis of a device.
+// A standard axis value is rescaled with the hint so that the value is 0 within the dead zone and reaches 1 at the saturation.
+//
+// The zero value means no rescaling.
+type AxisHint struct {
+	// DeadZone is the magnitude below which the axis value is treated as 0. DeadZone must be in [0, 1).
+	DeadZone float64
+
+	// Saturation is the magnitude at which the axis value reaches 1.
+	// Saturation must be in (DeadZone, 1], or 0 that means 1.
+	Saturation float64
+}
+
+func (h AxisHint) validate() error {
+	if h.DeadZone < 0 || h.DeadZone >= 1 {
+		return fmt.Errorf("gamepaddb: the dead zone must be in [0, 1) but %f", h.DeadZone)
+	}
+	if h.Saturation == 0 {
+		return nil
+	}
+	if h.Saturation <= h.DeadZone || h.Saturation > 1 {
+		return fmt.Errorf("gamepaddb: the saturation must be in (%f, 1] but %f", h.DeadZone, h.Saturation)
+	}
+	return nil
+}
+
+// apply rescales the axis value v in [-1, 1] with the hint.
+func (h AxisHint) apply(v float64) float64 {
+	saturation := h.Saturation
+	if saturation == 0 {
+		saturation = 1
+	}
+	a := math.Abs(v)
+	if a <= h.DeadZone {
+		return 0
+	}
+	a = math.Min((a-h.DeadZone)/(saturation-h.DeadZone), 1)
+	return math.Copysign(a, v)
+}
+
+// axisHints is the hints for all the standard axes of a device.
+type axisHints [StandardAxisMax + 1]AxisHint
+
+// vendorProduct is a pair of USB vendor and product IDs.
+type vendorProduct struct {
+	vendor  uint16
+	product uint16
+}
+
+// builtinAxisHints is the hints for devices whose sticks are known not to cover the full range.
+var builtinAxisHints = map[vendorProduct]*axisHints{
+	// Nintendo Switch Pro Controller. The sticks saturate around 0.85.
+	{vendor: 0x057e, product: 0x2009}: {
+		StandardAxisLeftStickHorizontal:  {Saturation: 0.85},
+		StandardAxisLeftStickVertical:    {Saturation: 0.85},
+		StandardAxisRightStickHorizontal: {Saturation: 0.85},
+		StandardAxisRightStickVertical:   {Saturation: 0.85},
+	},
+	// Nintendo GameCube Controller Adapter. The sticks are octagonal and have large slop at the center.
+	{vendor: 0x057e, product: 0x0337}: {
+		StandardAxisLeftStickHorizontal:  {DeadZone: 0.15, Saturation: 0.75},
+		StandardAxisLeftStickVertical:    {DeadZone: 0.15, Saturation: 0.75},
+		StandardAxisRightStickHorizontal: {DeadZone: 0.15, Saturation: 0.75},
+		StandardAxisRightStickVertical:   {DeadZone: 0.15, Saturation: 0.75},
+	},
+	// Mayflash GameCube Controller Adapter.
+	{vendor: 0x0079, product: 0x1846}: {
+		StandardAxisLeftStickHorizontal:  {DeadZone: 0.15, Saturation: 0.75},
+		StandardAxisLeftStickVertical:    {DeadZone: 0.15, Saturation: 0.75},
+		StandardAxisRightStickHorizontal: {DeadZone: 0.15, Saturation: 0.75},
+		StandardAxisRightStickVertical:   {DeadZone: 0.15, Saturation: 0.75},
+	},
+}
+
+var (
+	// userAxisHints is the hints given by SetAxisHint, keyed by the GUIDs without the CRCs.
+	userAxisHints = map[string]*axisHints{}
+
+	// resolvedAxisHints is a cache of the hints resolved for the gamepads' GUIDs.
+	// A nil value means that no hint is found.
+	// resolvedAxisHints must be cleared whenever userAxisHints is updated.
+	resolvedAxisHints = map[string]*axisHints{}
+)
+
+// SetAxisHint sets the hint for the standard axis of the devices with the GUID.
+// The hint is preferred to the built-in hints for the devices.
+// The CRC in the GUID is ignored.
+//
+// Giving the zero value disables the rescaling for the axis, even if there is a built-in hint.
+func SetAxisHint(guid string, axis StandardAxis, hint AxisHint) error {
+	if !isValidGUID(guid) || guid == "xinput" {
+		return fmt.Errorf("gamepaddb: invalid GUID: %s", guid)
+	}
+	if axis < 0 || axis > StandardAxisMax {
+		return fmt.Errorf("gamepaddb: invalid axis: %d", axis)
+	}
+	if err := hint.validate(); err != nil {
+		return err
+	}
+
+	key, _ := guidKey(strings.ToLower(guid))
+
+	mappingsM.Lock()
+	defer mappingsM.Unlock()
+
+	hs, ok := userAxisHints[key]
+	if !ok {
+		// Start with the built-in hints so that the other axes are not affected.
+		hs = &axisHints{}
+		if b := builtinAxisHintsForGUID(key); b != nil {
+			*hs = *b
+		}
+		userAxisHints[key] = hs
+	}
+	hs[axis] = hint
+	resolvedAxisHints = map[string]*axisHints{}
+	return nil
+}
+
+// removeUserAxisHints removes all the hints given by SetAxisHint.
+func removeUserAxisHints() {
+	userAxisHints = map[string]*axisHints{}
+	resolvedAxisHints = map[string]*axisHints{}
+}
+
+// lookupAxisHints returns the hints for the gamepad's GUID, or nil if not found.
+func lookupAxisHints(id string) *axisHints {
+	if hs, ok := resolvedAxisHints[id]; ok {
+		return hs
+	}
+	key, _ := guidKey(id)
+	hs, ok := userAxisHints[key]
+	if !ok {
+		hs = builtinAxisHintsForGUID(key)
+	}
+	resolvedAxisHints[id] = hs
+	return hs
+}
+
+// builtinAxisHintsForGUID returns the built-in hints for the GUID, or nil if not found.
+func builtinAxisHintsForGUID(guid string) *axisHints {
+	bs, err := hex.DecodeString(guid)
+	if err != nil || len(bs) != 16 || !hasBusType(bs) {
+		return nil
+	}
+	return builtinAxisHints[vendorProduct{
+		vendor:  uint16(bs[4]) | uint16(bs[5])<<8,
+		product: uint16(bs[8]) | uint16(bs[9])<<8,
+	}]
+}
+
+// applyAxisHint rescales the standard axis value v of the gamepad with the hint for the gamepad.
+func applyAxisHint(id string, axis StandardAxis, v float64) float64 {
+	if axis < 0 || axis > StandardAxisMax {
+		return v
+	}
+	hs := lookupAxisHints(id)
+	if hs == nil {
+		return v
+	}
+	return hs[axis].apply(v)
+}
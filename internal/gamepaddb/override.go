@@ -0,0 +1,73 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepaddb
+
+// Override is a standard layout mapping for a specific gamepad, which is used instead of the database.
+//
+// Override is immutable and concurrent-safe.
+type Override struct {
+	entry *entry
+}
+
+// NewOverride creates an Override from the mapping.
+func NewOverride(m *Mapping) (*Override, error) {
+	e, err := m.entry("")
+	if err != nil {
+		return nil, err
+	}
+	return &Override{
+		entry: e,
+	}, nil
+}
+
+func (o *Override) HasStandardLayoutMapping() bool {
+	return o.entry.hasMappings()
+}
+
+func (o *Override) HasStandardAxis(axis StandardAxis) bool {
+	return o.entry.hasAxis(axis)
+}
+
+func (o *Override) AxisValue(axis StandardAxis, state GamepadState) float64 {
+	return o.entry.axisValue(axis, state)
+}
+
+func (o *Override) IsTriggerAxis(axis int) bool {
+	return o.entry.isTriggerAxis(axis)
+}
+
+func (o *Override) TriggerAxis(button StandardButton) (int, bool) {
+	return o.entry.triggerAxis(button)
+}
+
+func (o *Override) HasStandardButton(button StandardButton) bool {
+	return o.entry.hasButton(button)
+}
+
+func (o *Override) ButtonValue(button StandardButton, state GamepadState) float64 {
+	return o.entry.buttonValue(button, state)
+}
+
+// IsButtonPressed works in the same way as IsButtonPressedWithHysteresis.
+func (o *Override) IsButtonPressed(button StandardButton, state GamepadState, wasPressed bool) bool {
+	return o.entry.isButtonPressed(button, state, wasPressed)
+}
+
+// MappingString returns the override in the format of SDL_GameControllerDB for the GUID and the name.
+func (o *Override) MappingString(id string, name string) string {
+	e := *o.entry
+	e.name = name
+	return e.format(id)
+}
@@ -0,0 +1,268 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepaddb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// The maximum indices of the inputs that a mapping can refer to.
+const (
+	// maxButtonIndex is the same as KEY_MAX of Linux's evdev.
+	// Some mappings in the database refer to buttons whose indices are larger than the number of buttons
+	// Ebiten handles, so the index is checked against the key codes instead.
+	maxButtonIndex = 0x2ff
+
+	// maxAxisIndex is the same as the number of the absolute axes of Linux's evdev (ABS_CNT).
+	maxAxisIndex = 63
+
+	// maxHatIndex is large enough for the hats of DirectInput and HID devices.
+	maxHatIndex = 15
+)
+
+// ValidationReason represents the reason of a ValidationError.
+type ValidationReason int
+
+const (
+	// ValidationReasonSyntax indicates that the line or the field is malformed.
+	ValidationReasonSyntax ValidationReason = iota
+
+	// ValidationReasonUnknownKey indicates that the key of the field is unknown, e.g., a typo like "lefttriger".
+	ValidationReasonUnknownKey
+
+	// ValidationReasonInvalidValue indicates that the value of the field is invalid.
+	ValidationReasonInvalidValue
+
+	// ValidationReasonDuplicate indicates that the same key is assigned more than once.
+	ValidationReasonDuplicate
+
+	// ValidationReasonOutOfRange indicates that the value refers to an input with a too large index.
+	ValidationReasonOutOfRange
+)
+
+func (r ValidationReason) String() string {
+	switch r {
+	case ValidationReasonSyntax:
+		return "syntax error"
+	case ValidationReasonUnknownKey:
+		return "unknown key"
+	case ValidationReasonInvalidValue:
+		return "invalid value"
+	case ValidationReasonDuplicate:
+		return "duplicate assignment"
+	case ValidationReasonOutOfRange:
+		return "out-of-range input"
+	}
+	return fmt.Sprintf("ValidationReason(%d)", int(r))
+}
+
+// ValidationError is an error of a field in mappings.
+type ValidationError struct {
+	// Line is the line number starting with 1.
+	Line int
+
+	// Field is the field like "lefttriger:a2", or the whole line for an error of the line.
+	Field string
+
+	// Reason is the reason of the error.
+	Reason ValidationReason
+
+	// Err is the detail of the error, or nil.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("line %d: %q: %s: %v", e.Line, e.Field, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("line %d: %q: %s", e.Line, e.Field, e.Reason)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors is the errors of mappings.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	var strs []string
+	for _, err := range e {
+		strs = append(strs, err.Error())
+	}
+	return "gamepaddb: invalid mappings: " + strings.Join(strs, "; ")
+}
+
+// strict is not 0 if mappings with any validation errors are rejected by Update and the other loading functions.
+var strict int32
+
+// SetStrict sets whether mappings are validated strictly.
+//
+// In the strict mode, any error reported by Validate, including an unknown key, rejects the whole input,
+// and the error is ValidationErrors.
+// Otherwise, unknown keys are ignored, and only malformed mappings are rejected.
+func SetStrict(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&strict, v)
+}
+
+func isStrict() bool {
+	return atomic.LoadInt32(&strict) != 0
+}
+
+// Validate validates the mappings in the format of SDL_GameControllerDB for all the platforms,
+// and returns ValidationErrors if the mappings have errors.
+func Validate(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	var errs ValidationErrors
+	for lineNumber := 1; s.Scan(); lineNumber++ {
+		errs = append(errs, validateLine(s.Text(), lineNumber)...)
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ignoredKeys is the keys that SDL knows but Ebitengine ignores, as the standard layout doesn't have them.
+var ignoredKeys = map[string]struct{}{
+	"misc2": {},
+	"misc3": {},
+	"misc4": {},
+	"misc5": {},
+	"misc6": {},
+}
+
+// validateLine validates the line and returns all the errors.
+func validateLine(line string, lineNumber int) []*ValidationError {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 || line[0] == '#' {
+		return nil
+	}
+
+	var errs []*ValidationError
+	addError := func(field string, reason ValidationReason, err error) {
+		errs = append(errs, &ValidationError{
+			Line:   lineNumber,
+			Field:  field,
+			Reason: reason,
+			Err:    err,
+		})
+	}
+
+	tokens := strings.Split(line, ",")
+	if len(tokens) < 2 {
+		addError(line, ValidationReasonSyntax, nil)
+		return errs
+	}
+	if !isValidGUID(tokens[0]) {
+		addError(tokens[0], ValidationReasonInvalidValue, fmt.Errorf("invalid GUID"))
+	}
+
+	// wholeKeys is the assigned keys, and halfKeys is the assigned halves of the axes like "+leftx".
+	wholeKeys := map[string]struct{}{}
+	halfKeys := map[string]struct{}{}
+	for _, token := range tokens[2:] {
+		if len(token) == 0 {
+			continue
+		}
+		key, value, ok := strings.Cut(token, ":")
+		if !ok {
+			addError(token, ValidationReasonSyntax, nil)
+			continue
+		}
+
+		// A standard axis can be mapped by halves. The whole axis and its halves cannot be mixed.
+		assigned := key
+		if len(key) > 0 && (key[0] == '+' || key[0] == '-') {
+			assigned = key[1:]
+		}
+		if assigned != key {
+			_, dupWhole := wholeKeys[assigned]
+			_, dupHalf := halfKeys[key]
+			if dupWhole || dupHalf {
+				addError(token, ValidationReasonDuplicate, nil)
+				continue
+			}
+			halfKeys[key] = struct{}{}
+		} else {
+			_, dupWhole := wholeKeys[key]
+			_, dupPositive := halfKeys["+"+key]
+			_, dupNegative := halfKeys["-"+key]
+			if dupWhole || dupPositive || dupNegative {
+				addError(token, ValidationReasonDuplicate, nil)
+				continue
+			}
+			wholeKeys[key] = struct{}{}
+		}
+
+		switch key {
+		case "platform":
+			switch value {
+			case "Windows", "Mac OS X", "Linux", "Android", "iOS", "":
+			default:
+				addError(token, ValidationReasonInvalidValue, fmt.Errorf("unknown platform"))
+			}
+			continue
+		case "crc":
+			if _, err := strconv.ParseUint(value, 16, 16); err != nil {
+				addError(token, ValidationReasonInvalidValue, err)
+			}
+			continue
+		}
+
+		_, isButton := toStandardGamepadButton(assigned)
+		_, isAxis := toStandardGamepadAxis(assigned)
+		_, isIgnored := ignoredKeys[assigned]
+		if assigned != key && !isAxis {
+			addError(token, ValidationReasonUnknownKey, fmt.Errorf("only an axis can be mapped by halves"))
+			continue
+		}
+		if !isButton && !isAxis && !isIgnored {
+			addError(token, ValidationReasonUnknownKey, nil)
+			continue
+		}
+
+		m, err := parseMappingElement(value)
+		if err != nil {
+			addError(token, ValidationReasonInvalidValue, err)
+			continue
+		}
+		var max int
+		switch m.Type {
+		case mappingTypeButton:
+			max = maxButtonIndex
+		case mappingTypeAxis:
+			max = maxAxisIndex
+		case mappingTypeHat:
+			max = maxHatIndex
+		}
+		if m.Index < 0 || m.Index > max {
+			addError(token, ValidationReasonOutOfRange, fmt.Errorf("the index must be in [0, %d]", max))
+		}
+	}
+	return errs
+}
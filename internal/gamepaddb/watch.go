@@ -0,0 +1,125 @@
+// Copyright 2022 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gamepaddb
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+)
+
+// watchInterval is the interval to check the watched files.
+const watchInterval = time.Second
+
+type watchedFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+var (
+	watchedFiles  []*watchedFile
+	lastWatchTime time.Time
+	watchedFilesM sync.Mutex
+)
+
+// WatchFile loads the mappings in the file, and watches the file to reload the mappings when the file is modified.
+// The mappings from the file are replaced atomically on every reload.
+// If the file is invalid, the last valid mappings are kept and the error is recorded as a MappingError.
+//
+// WatchFile returns an error of the initial load, but the file is watched even in this case.
+func WatchFile(path string) error {
+	watchedFilesM.Lock()
+	defer watchedFilesM.Unlock()
+
+	for _, f := range watchedFiles {
+		if f.path == path {
+			return nil
+		}
+	}
+
+	f := &watchedFile{
+		path: path,
+	}
+	watchedFiles = append(watchedFiles, f)
+	return f.load()
+}
+
+// unwatchFiles stops watching all the files.
+// The mappings already loaded from the files are kept.
+func unwatchFiles() {
+	watchedFilesM.Lock()
+	defer watchedFilesM.Unlock()
+
+	watchedFiles = nil
+}
+
+// UpdateWatchedFiles reloads the watched files that are modified.
+// UpdateWatchedFiles checks the files at most once per watchInterval, so this can be called every tick.
+func UpdateWatchedFiles() {
+	watchedFilesM.Lock()
+	defer watchedFilesM.Unlock()
+
+	if len(watchedFiles) == 0 {
+		return
+	}
+	now := time.Now()
+	if now.Sub(lastWatchTime) < watchInterval {
+		return
+	}
+	lastWatchTime = now
+
+	for _, f := range watchedFiles {
+		if err := f.reloadIfModified(); err != nil {
+			addMappingError(f.path, err)
+		}
+	}
+}
+
+func (f *watchedFile) reloadIfModified() error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		// The file might be being replaced by an editor. Keep the last mappings.
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return nil
+	}
+	return f.load()
+}
+
+func (f *watchedFile) load() error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	// Record the stat before reading the file, so that a modification during the reading is detected next time.
+	// The stat is recorded even if the file is invalid, so that the same error is not reported again.
+	f.modTime = fi.ModTime()
+	f.size = fi.Size()
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	if _, err := update(bytes.NewReader(data), sourceWatchedFile, f.path); err != nil {
+		return err
+	}
+	return nil
+}
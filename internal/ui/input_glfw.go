@@ -51,6 +51,13 @@ func (u *UserInterface) registerInputCallbacks() error {
 		return err
 	}
 
+	if _, err := u.window.SetFocusCallback(func(w *glfw.Window, focused bool) {
+		// The exclusive grab of gamepads must be released even when the game is not updated while the window is not focused.
+		gamepad.SetFocused(focused)
+	}); err != nil {
+		return err
+	}
+
 	return nil
 }
 
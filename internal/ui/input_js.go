@@ -18,6 +18,8 @@ import (
 	"math"
 	"syscall/js"
 	"unicode"
+
+	"github.com/hajimehoshi/ebiten/v2/internal/gamepad"
 )
 
 var (
@@ -210,6 +212,21 @@ func (u *UserInterface) updateTouchesFromEvent(e js.Value) {
 	}
 }
 
+// updateVirtualGamepadTouches passes the touches to the touch-driven virtual gamepad.
+// The touches are in the client coordinates, and the zones of the virtual gamepad are relative to the outside size.
+func (u *UserInterface) updateVirtualGamepadTouches() {
+	u.virtualGamepadTouches = u.virtualGamepadTouches[:0]
+	for _, t := range u.touchesInClient {
+		u.virtualGamepadTouches = append(u.virtualGamepadTouches, gamepad.VirtualGamepadTouch{
+			ID: int(t.id),
+			X:  t.x,
+			Y:  t.y,
+		})
+	}
+	w, h := u.outsideSize()
+	gamepad.SetVirtualGamepadTouches(u.virtualGamepadTouches, w, h)
+}
+
 func isKeyString(str string) bool {
 	// From https://www.w3.org/TR/uievents-key/#keys-unicode,
 	//
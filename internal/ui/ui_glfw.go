@@ -1405,6 +1405,7 @@ func (u *UserInterface) update() (float64, float64, error) {
 func (u *UserInterface) loopGame() (ferr error) {
 	defer func() {
 		graphicscommand.Terminate()
+		gamepad.Terminate()
 		u.mainThread.Call(func() {
 			if err := glfw.Terminate(); err != nil {
 				ferr = err
@@ -109,6 +109,9 @@ type userInterfaceImpl struct {
 	origCursorYInClient       float64
 	touchesInClient           []touchInClient
 
+	// virtualGamepadTouches is the touches for the touch-driven virtual gamepad.
+	virtualGamepadTouches []gamepad.VirtualGamepadTouch
+
 	savedCursorX              float64
 	savedCursorY              float64
 	savedOutsideWidth         float64
@@ -352,6 +355,7 @@ func (u *UserInterface) updateImpl(force bool) error {
 		return nil
 	}
 
+	u.updateVirtualGamepadTouches()
 	if err := gamepad.Update(); err != nil {
 		return err
 	}
@@ -455,15 +459,21 @@ func (u *UserInterface) loopGame() error {
 			time.Sleep(interval)
 		}()
 
+		var suspended bool
 		for {
 			select {
 			case <-t.C:
 				if u.suspended() {
+					if !suspended {
+						gamepad.StopAllVibration()
+						suspended = true
+					}
 					if err := hook.SuspendAudio(); err != nil {
 						errCh <- err
 						return
 					}
 				} else {
+					suspended = false
 					if err := hook.ResumeAudio(); err != nil {
 						errCh <- err
 						return
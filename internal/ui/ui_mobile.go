@@ -114,6 +114,9 @@ func (u *UserInterface) SetForeground(foreground bool) error {
 	}
 	atomic.StoreInt32(&u.foreground, v)
 
+	// This stops the gamepad vibrations and hides the virtual gamepad in background.
+	gamepad.SetFocused(foreground)
+
 	if foreground {
 		return hook.ResumeAudio()
 	} else {
@@ -15,8 +15,6 @@
 package ebitenmobileview
 
 import (
-	"encoding/hex"
-	"hash/crc32"
 	"unicode"
 
 	"github.com/hajimehoshi/ebiten/v2/internal/gamepad"
@@ -176,35 +174,29 @@ func OnGamepadHatChanged(deviceID int, hatID int, xValue, yValue int) {
 	gamepad.UpdateAndroidGamepadHat(deviceID, hatID, xValue, yValue)
 }
 
+// SetAndroidSDKVersion sets the SDK version of Android, i.e., Build.VERSION.SDK_INT.
+func SetAndroidSDKVersion(version int) {
+	gamepaddb.SetAndroidSDKVersion(version)
+}
+
 func OnGamepadAdded(deviceID int, name string, axisCount int, hatCount int, descriptor string, vendorID int, productID int, buttonMask int, axisMask int) {
 	// This emulates the implementation of Android_AddJoystick.
-	// https://github.com/libsdl-org/SDL/blob/0e9560aea22818884921e5e5064953257bfe7fa7/src/joystick/android/SDL_sysjoystick.c#L386
-	const SDL_HARDWARE_BUS_BLUETOOTH = 0x05
-
-	var sdlid [16]byte
-	sdlid[0] = byte(SDL_HARDWARE_BUS_BLUETOOTH)
-	sdlid[1] = byte(SDL_HARDWARE_BUS_BLUETOOTH >> 8)
-	if vendorID != 0 && productID != 0 {
-		sdlid[4] = byte(vendorID)
-		sdlid[5] = byte(vendorID >> 8)
-		sdlid[8] = byte(productID)
-		sdlid[9] = byte(productID >> 8)
-	} else {
-		crc := crc32.ChecksumIEEE(([]byte)(descriptor))
-		copy(sdlid[4:8], ([]byte)(descriptor))
-		sdlid[8] = byte(crc)
-		sdlid[9] = byte(crc >> 8)
-		sdlid[10] = byte(crc >> 16)
-		sdlid[11] = byte(crc >> 24)
-	}
-	sdlid[12] = byte(buttonMask)
-	sdlid[13] = byte(buttonMask >> 8)
-	sdlid[14] = byte(axisMask)
-	sdlid[15] = byte(axisMask >> 8)
-
-	gamepad.AddAndroidGamepad(deviceID, name, hex.EncodeToString(sdlid[:]), axisCount, hatCount)
+	// https://github.com/libsdl-org/SDL/blob/release-2.26.0/src/joystick/android/SDL_sysjoystick.c
+	sdlID := gamepad.AndroidSDLGUID(uint16(vendorID), uint16(productID), descriptor, uint16(buttonMask), uint16(axisMask))
+	gamepad.AddAndroidGamepad(deviceID, name, sdlID, axisCount, hatCount)
 }
 
 func OnInputDeviceRemoved(deviceID int) {
 	gamepad.RemoveAndroidGamepad(deviceID)
 }
+
+// OnGamepadsSuspended is called when the game is suspended.
+// The input device events are not delivered until the game is resumed.
+func OnGamepadsSuspended() {
+	gamepad.SuspendAndroidGamepads()
+}
+
+// OnGamepadsResumed is called after OnGamepadAdded is called for all the present devices when the game is resumed.
+func OnGamepadsResumed() {
+	gamepad.ResumeAndroidGamepads()
+}
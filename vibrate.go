@@ -66,11 +66,40 @@ type VibrateGamepadOptions struct {
 	// WeakMagnitude is the rumble intensity of a high-frequency rumble motor.
 	// The value is in between 0 and 1.
 	WeakMagnitude float64
+
+	// LeftTriggerMagnitude is the rumble intensity of a motor in the left trigger, like impulse triggers of Xbox One controllers.
+	// The value is in between 0 and 1.
+	//
+	// LeftTriggerMagnitude works only on Windows and Xbox so far. LeftTriggerMagnitude is ignored if the gamepad doesn't have such a motor.
+	LeftTriggerMagnitude float64
+
+	// RightTriggerMagnitude is the rumble intensity of a motor in the right trigger, like impulse triggers of Xbox One controllers.
+	// The value is in between 0 and 1.
+	//
+	// RightTriggerMagnitude works only on Windows and Xbox so far. RightTriggerMagnitude is ignored if the gamepad doesn't have such a motor.
+	RightTriggerMagnitude float64
 }
 
 // VibrateGamepad vibrates the specified gamepad with the specified options.
 //
-// VibrateGamepad works only on browsers and Nintendo Switch so far.
+// VibrateGamepad works only on browsers, Android, iOS, Linux, macOS, Windows, Xbox, and Nintendo Switch so far.
+//
+// On Linux, VibrateGamepad requires the write permission for the gamepad device file.
+//
+// On Android, VibrateGamepad works only for gamepads with vibrators exposed via InputDevice.
+// The two motors are vibrated separately on Android 12 (API Level 31) or newer when the gamepad has them.
+// Otherwise, the stronger magnitude is used.
+//
+// On macOS, VibrateGamepad requires macOS 11 or later, and works only for gamepads with haptics supported by the GameController framework.
+// DualSense works on older macOS too, as its rumble motors are driven by HID output reports when the GameController framework doesn't support its haptics.
+//
+// On iOS, VibrateGamepad requires iOS 14 or later, and works only for gamepads with haptics supported by the GameController framework.
+//
+// On browsers, VibrateGamepad works only when the browser supports the vibration of the gamepad, like Chrome and Edge.
+// For DualShock 4 and DualSense, the vibration works via WebHID after RequestGamepadEnhancedAccess is granted.
+//
+// On Windows, VibrateGamepad works for XInput-compatible gamepads like Xbox controllers, DualShock 4, DualSense, and Switch Pro Controller.
+// Gamepads handled via DirectInput, like many older gamepads, don't vibrate, as DirectInput force feedback is not supported yet.
 //
 // VibrateGamepad is concurrent-safe.
 func VibrateGamepad(gamepadID GamepadID, options *VibrateGamepadOptions) {
@@ -78,5 +107,27 @@ func VibrateGamepad(gamepadID GamepadID, options *VibrateGamepadOptions) {
 	if g == nil {
 		return
 	}
-	g.Vibrate(options.Duration, options.StrongMagnitude, options.WeakMagnitude)
+	g.VibrateWithTriggers(options.Duration, options.StrongMagnitude, options.WeakMagnitude, options.LeftTriggerMagnitude, options.RightTriggerMagnitude)
+}
+
+// SetGamepadVibrationGain sets the strength of all the gamepad vibrations in between 0 and 1.
+// The magnitudes of the vibrations are multiplied by the gain.
+// This is useful to provide a vibration strength setting to players.
+//
+// On Linux, the gain is applied at the device level if the device supports it, so that the playing vibrations are attenuated too.
+//
+// The default value is 1.
+//
+// SetGamepadVibrationGain is concurrent-safe.
+func SetGamepadVibrationGain(gain float64) {
+	gamepad.SetVibrationGain(gain)
+}
+
+// StopAllGamepadVibrations stops the vibrations of all the connected gamepads.
+//
+// The vibrations are stopped automatically when the window loses focus or the game is paused e.g. by going to the background.
+//
+// StopAllGamepadVibrations is concurrent-safe.
+func StopAllGamepadVibrations() {
+	gamepad.StopAllVibration()
 }